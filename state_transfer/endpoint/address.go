@@ -0,0 +1,81 @@
+package endpoint
+
+// BindAddresser knows the local address a server should listen on when it
+// differs from the address clients are given to connect to
+type BindAddresser interface {
+	// BindAddress returns the address the server listens on
+	BindAddress() string
+}
+
+// addressedEndpoint wraps an Endpoint and overrides the address advertised to
+// clients, independently from the address the server binds to
+type addressedEndpoint struct {
+	Endpoint
+	advertisedHostname string
+	advertisedPort     int32
+	bindAddress        string
+}
+
+// NewAddressedEndpoint wraps the given Endpoint so that clients connect to the
+// advertised hostname and port while the server listens on bindAddress. This is
+// useful for NAT/DNAT topologies where the externally reachable address differs
+// from the pod's listen address. Empty or zero values fall back to the wrapped
+// Endpoint's values.
+func NewAddressedEndpoint(e Endpoint, advertisedHostname string, advertisedPort int32, bindAddress string) Endpoint {
+	return &addressedEndpoint{
+		Endpoint:           e,
+		advertisedHostname: advertisedHostname,
+		advertisedPort:     advertisedPort,
+		bindAddress:        bindAddress,
+	}
+}
+
+// Hostname returns the advertised hostname clients connect to
+func (a *addressedEndpoint) Hostname() string {
+	if a.advertisedHostname == "" {
+		return a.Endpoint.Hostname()
+	}
+	return a.advertisedHostname
+}
+
+// ExposedPort returns the advertised port clients connect to
+func (a *addressedEndpoint) ExposedPort() int32 {
+	if a.advertisedPort == 0 {
+		return a.Endpoint.ExposedPort()
+	}
+	return a.advertisedPort
+}
+
+// BindAddress returns the address the server listens on
+func (a *addressedEndpoint) BindAddress() string {
+	return a.bindAddress
+}
+
+// ClusterLocal returns false, clients connect to the advertised address rather than to the
+// cluster local name of the wrapped Endpoint
+func (a *addressedEndpoint) ClusterLocal() bool {
+	return false
+}
+
+// Unwrap returns the wrapped Endpoint, see Unwrapper
+func (a *addressedEndpoint) Unwrap() Endpoint {
+	return a.Endpoint
+}
+
+// Copy returns a copy of the endpoint wrapping a copy of the wrapped Endpoint
+func (a *addressedEndpoint) Copy() Endpoint {
+	copied := *a
+	copied.Endpoint = Copy(a.Endpoint)
+	return &copied
+}
+
+// BindAddress returns the address a server for the given Endpoint should listen on,
+// an empty string means the server listens on all addresses
+func BindAddress(e Endpoint) string {
+	for _, w := range chain(e) {
+		if b, ok := w.(BindAddresser); ok {
+			return b.BindAddress()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package endpoint
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ClusterLocalEndpoint knows whether an Endpoint is only reachable from within the cluster of the
+// transfer server, for transfers between namespaces of a single cluster
+type ClusterLocalEndpoint interface {
+	// ClusterLocal returns whether the endpoint is only reachable from within the cluster
+	ClusterLocal() bool
+}
+
+// IsClusterLocal returns whether the given Endpoint is only reachable from within the cluster of
+// the transfer server, endpoints which do not implement ClusterLocalEndpoint are not
+func IsClusterLocal(e Endpoint) bool {
+	for _, w := range chain(e) {
+		if local, ok := w.(ClusterLocalEndpoint); ok {
+			return local.ClusterLocal()
+		}
+	}
+	return false
+}
+
+// ClusterLocalHostname returns the DNS name of the Service with the given name within its cluster
+func ClusterLocalHostname(service types.NamespacedName) string {
+	return fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
+}
@@ -1,15 +1,24 @@
 package endpoint
 
 import (
+	"context"
+	"errors"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrEndpointNotReady is returned when the resources of an endpoint exist but do not route
+// connections yet, for instance while a Route is not admitted or a load balancer has no address
+var ErrEndpointNotReady = errors.New("endpoint not ready")
+
 // Endpoint knows how to connect with a Transport or a Transfer
 type Endpoint interface {
 	// Create given a client, creates all kube resources
 	// required for the endpoint to work and returns err
-	Create(client.Client) error
+	Create(context.Context, client.Client) error
 	// Hostname returns a hostname for the endpoint
 	Hostname() string
 	// Port returns a backend port to which endpoint can connect
@@ -23,18 +32,116 @@ type Endpoint interface {
 	// Labels returns labels used by this endpoint
 	Labels() map[string]string
 	// IsHealthy returns whether or not all Kube resources used by endpoint are healthy
-	IsHealthy(c client.Client) (bool, error)
+	IsHealthy(ctx context.Context, c client.Client) (bool, error)
+}
+
+// SchemeAdder knows which API types an Endpoint needs to be registered in a scheme
+type SchemeAdder interface {
+	// AddToScheme adds the API types used by the endpoint to the given scheme
+	AddToScheme(*runtime.Scheme) error
+}
+
+// AddToScheme adds the API types used by the given Endpoint to the scheme,
+// endpoints which do not implement SchemeAdder are assumed to only use core types
+func AddToScheme(e Endpoint, s *runtime.Scheme) error {
+	for _, w := range chain(e) {
+		if adder, ok := w.(SchemeAdder); ok {
+			return adder.AddToScheme(s)
+		}
+	}
+	return nil
+}
+
+// DNSNamer is implemented by endpoints which know the DNS names clients connect to before their
+// hostname is assigned, e.g. a Route with a subdomain before it is admitted
+type DNSNamer interface {
+	// DNSNames returns the DNS names clients will connect to, nil when they are not known yet
+	DNSNames() []string
+}
+
+// DNSNames returns the DNS names clients of the given Endpoint connect to, such as the names a
+// server certificate must be valid for: its hostname when it is known, otherwise the names the
+// endpoint expects as a DNSNamer. It returns nil when neither is known yet.
+func DNSNames(e Endpoint) []string {
+	if e.Hostname() != "" {
+		return []string{e.Hostname()}
+	}
+	for _, w := range chain(e) {
+		if namer, ok := w.(DNSNamer); ok {
+			return namer.DNSNames()
+		}
+	}
+	return nil
+}
+
+// Unwrapper is implemented by endpoints wrapping another Endpoint, such as the endpoints returned
+// by NewAddressedEndpoint. The optional interfaces of this package, e.g. UserLabeler, are looked up
+// on the wrapped endpoints when the wrapper does not implement them.
+type Unwrapper interface {
+	// Unwrap returns the wrapped endpoint
+	Unwrap() Endpoint
+}
+
+// Unwrap returns the innermost Endpoint wrapped by e, e itself when it does not wrap another one.
+// Type assertions on the concrete type of an endpoint must be made on the unwrapped endpoint.
+func Unwrap(e Endpoint) Endpoint {
+	for {
+		u, ok := e.(Unwrapper)
+		if !ok {
+			return e
+		}
+		e = u.Unwrap()
+	}
+}
+
+// chain returns e followed by the endpoints it wraps, outermost first
+func chain(e Endpoint) []Endpoint {
+	endpoints := []Endpoint{e}
+	for {
+		u, ok := e.(Unwrapper)
+		if !ok {
+			return endpoints
+		}
+		e = u.Unwrap()
+		endpoints = append(endpoints, e)
+	}
+}
+
+// Copier is implemented by endpoints which can be copied, so that their resources can be rendered
+// without changing them, e.g. a Route endpoint resets its hostname when created
+type Copier interface {
+	// Copy returns a copy of the endpoint, creating the copy leaves the endpoint unchanged
+	Copy() Endpoint
+}
+
+// Copy returns a copy of the given Endpoint, endpoints which do not implement Copier are returned
+// as they are
+func Copy(e Endpoint) Endpoint {
+	if c, ok := e.(Copier); ok {
+		return c.Copy()
+	}
+	return e
 }
 
 // Create creates a new endpoint
-func Create(e Endpoint, c client.Client) (Endpoint, error) {
-	err := e.Create(c)
+func Create(ctx context.Context, e Endpoint, c client.Client) (Endpoint, error) {
+	err := e.Create(ctx, c)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
+// Render returns the resources Create would create with the given client without creating them,
+// see meta.NewRenderClient. A copy of the endpoint is rendered, see Copy.
+func Render(ctx context.Context, e Endpoint, c client.Client) ([]client.Object, error) {
+	rc := meta.NewRenderClient(c)
+	if err := Copy(e).Create(ctx, rc); err != nil {
+		return nil, err
+	}
+	return rc.Objects(), nil
+}
+
 // Destroy destroys a given endpoint
 func Destroy(e Endpoint) error {
 	return nil
@@ -0,0 +1,332 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RouteType is the kind of the Gateway API route exposing the transfer server
+type RouteType string
+
+const (
+	// RouteTypeTLS exposes the server with a TLSRoute, the Gateway routes connections on their TLS
+	// server name to the server without terminating TLS
+	RouteTypeTLS RouteType = "TLSRoute"
+	// RouteTypeTCP exposes the server with a TCPRoute, the Gateway routes every connection of its
+	// listener to the server, which therefore needs a listener of its own
+	RouteTypeTCP RouteType = "TCPRoute"
+)
+
+const (
+	// defaultTLSPort is the port of the Gateway listener of TLSRoutes when none is given
+	defaultTLSPort = int32(443)
+	backendPort    = int32(6443)
+)
+
+// GroupVersion is the version of the Gateway API the routes are created with
+var GroupVersion = schema.GroupVersion{Group: "gateway.networking.k8s.io", Version: "v1alpha2"}
+
+// Options defines how the routes attach to their Gateway
+type Options struct {
+	// Hostname is the hostname clients connect to. It is required with RouteTypeTLS, where it is
+	// also the hostname of the TLSRoute. With RouteTypeTCP it defaults to the first address of
+	// the Gateway once the route is accepted.
+	Hostname string
+	// SectionName is the name of the Gateway listener the route attaches to, the route attaches
+	// to every listener allowing it when empty
+	SectionName string
+	// Port is the port of the Gateway listener, it is required with RouteTypeTCP and defaults
+	// to 443 with RouteTypeTLS
+	Port int32
+}
+
+// GatewayEndpoint exposes the transfer server through an existing Gateway with a Gateway API
+// route and a ClusterIP Service. The Gateway API types are not vendored, the routes are managed
+// as unstructured objects.
+type GatewayEndpoint struct {
+	namespacedName types.NamespacedName
+	gateway        types.NamespacedName
+	routeType      RouteType
+	hostname       string
+	sectionName    string
+	exposedPort    int32
+
+	labels         map[string]string
+	userLabels     map[string]string
+	selectorLabels map[string]string
+
+	log logr.Logger
+}
+
+// NewEndpoint returns an endpoint creating a route of the given type attached to the given Gateway,
+// the route and its Service are named after namespacedName
+func NewEndpoint(namespacedName types.NamespacedName, routeType RouteType, labels map[string]string, gateway types.NamespacedName, log logr.Logger, options Options) (endpoint.Endpoint, error) {
+	if gateway.Name == "" {
+		return nil, fmt.Errorf("gateway of endpoint %s must be set", namespacedName)
+	}
+	if gateway.Namespace == "" {
+		gateway.Namespace = namespacedName.Namespace
+	}
+	port := options.Port
+	switch routeType {
+	case RouteTypeTLS:
+		if options.Hostname == "" {
+			return nil, fmt.Errorf("a %s requires a hostname", routeType)
+		}
+		if port == 0 {
+			port = defaultTLSPort
+		}
+	case RouteTypeTCP:
+		if port == 0 {
+			return nil, fmt.Errorf("a %s requires the port of the gateway listener", routeType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported route type %s for gateway endpoints", routeType)
+	}
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid gateway listener port %d", port)
+	}
+	return &GatewayEndpoint{
+		namespacedName: namespacedName,
+		gateway:        gateway,
+		routeType:      routeType,
+		hostname:       options.Hostname,
+		sectionName:    options.SectionName,
+		exposedPort:    port,
+		labels:         labels,
+		log:            endpoint.Logger(log, namespacedName),
+	}, nil
+}
+
+func (g *GatewayEndpoint) Create(ctx context.Context, c client.Client) error {
+	c = meta.NewLoggingClient(c, g.log)
+	errs := []error{}
+
+	err := g.createService(ctx, c)
+	errs = append(errs, err)
+
+	err = g.createRoute(ctx, c)
+	errs = append(errs, err)
+
+	return errorsutil.NewAggregate(errs)
+}
+
+func (g *GatewayEndpoint) Hostname() string {
+	return g.hostname
+}
+
+func (g *GatewayEndpoint) Port() int32 {
+	return backendPort
+}
+
+func (g *GatewayEndpoint) ExposedPort() int32 {
+	return g.exposedPort
+}
+
+func (g *GatewayEndpoint) NamespacedName() types.NamespacedName {
+	return g.namespacedName
+}
+
+func (g *GatewayEndpoint) Labels() map[string]string {
+	return g.labels
+}
+
+// Copy returns a copy of the endpoint, see endpoint.Copier
+func (g *GatewayEndpoint) Copy() endpoint.Endpoint {
+	copied := *g
+	return &copied
+}
+
+// RouteType returns the kind of the route of the endpoint
+func (g *GatewayEndpoint) RouteType() RouteType {
+	return g.routeType
+}
+
+// ValidateProtocol rejects connections which are not TLS for TLSRoutes, which are routed on the TLS
+// server name of the connections, see endpoint.ProtocolValidator
+func (g *GatewayEndpoint) ValidateProtocol(p endpoint.Protocol) error {
+	if g.routeType == RouteTypeTLS && p != endpoint.ProtocolTLS {
+		return fmt.Errorf("the gateway routes TLSRoute connections on their TLS server name, %s connections do not use TLS", p)
+	}
+	return nil
+}
+
+// Gateway returns the Gateway the route of the endpoint attaches to
+func (g *GatewayEndpoint) Gateway() types.NamespacedName {
+	return g.gateway
+}
+
+// SetUserLabels sets additional labels on the route and its Service, both are labelled
+// with the user labels and the managed Labels(), managed labels take precedence
+func (g *GatewayEndpoint) SetUserLabels(labels map[string]string) {
+	g.userLabels = labels
+}
+
+// SetSelectorLabels sets the labels the Service of the route selects the server pods with, in place of Labels()
+func (g *GatewayEndpoint) SetSelectorLabels(labels map[string]string) {
+	g.selectorLabels = labels
+}
+
+// SelectorLabels returns the labels set with SetSelectorLabels
+func (g *GatewayEndpoint) SelectorLabels() map[string]string {
+	return g.selectorLabels
+}
+
+// AddToScheme adds the API types used by the endpoint to the given scheme, the Gateway API
+// routes are unstructured and need no registration: they are rendered, exported and deleted
+// through their apiVersion and kind
+func (g *GatewayEndpoint) AddToScheme(s *runtime.Scheme) error {
+	return corev1.AddToScheme(s)
+}
+
+// IsHealthy returns whether the route was accepted by its Gateway. With a TCPRoute and no
+// hostname, the hostname of the endpoint is set to the first address of the Gateway.
+func (g *GatewayEndpoint) IsHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := g.isHealthy(ctx, c)
+	meta.LogHealthCheck(g.log, healthy, err)
+	return healthy, err
+}
+
+func (g *GatewayEndpoint) isHealthy(ctx context.Context, c client.Client) (bool, error) {
+	route := g.newRoute()
+	err := c.Get(ctx, g.NamespacedName(), route)
+	if err != nil {
+		return false, err
+	}
+	accepted, err := routeAccepted(route, g.gateway)
+	if err != nil || !accepted {
+		return false, err
+	}
+	if g.hostname != "" {
+		return true, nil
+	}
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(GroupVersion.WithKind("Gateway"))
+	err = c.Get(ctx, g.gateway, gw)
+	if err != nil {
+		return false, err
+	}
+	addresses, _, _ := unstructured.NestedSlice(gw.Object, "status", "addresses")
+	for _, a := range addresses {
+		if address, ok := a.(map[string]interface{}); ok {
+			if value, ok := address["value"].(string); ok && value != "" {
+				g.hostname = value
+				return true, nil
+			}
+		}
+	}
+	return false, fmt.Errorf("%w: gateway %s has no address", endpoint.ErrEndpointNotReady, g.gateway)
+}
+
+// routeAccepted returns whether the status of the route reports it as accepted by the given
+// Gateway, an error is returned when the Gateway rejected it
+func routeAccepted(route *unstructured.Unstructured, gateway types.NamespacedName) (bool, error) {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, p := range parents {
+		parent, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(parent, "parentRef", "name")
+		namespace, _, _ := unstructured.NestedString(parent, "parentRef", "namespace")
+		if namespace == "" {
+			namespace = route.GetNamespace()
+		}
+		if name != gateway.Name || namespace != gateway.Namespace {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parent, "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != "Accepted" {
+				continue
+			}
+			if condition["status"] == string(metav1.ConditionTrue) {
+				return true, nil
+			}
+			return false, fmt.Errorf("%w: %s %s/%s is not accepted by gateway %s: %v", endpoint.ErrEndpointNotReady,
+				route.GetKind(), route.GetNamespace(), route.GetName(), gateway, condition["message"])
+		}
+	}
+	return false, nil
+}
+
+// newRoute returns an empty route of the type of the endpoint
+func (g *GatewayEndpoint) newRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(GroupVersion.WithKind(string(g.routeType)))
+	return route
+}
+
+func (g *GatewayEndpoint) createService(ctx context.Context, c client.Client) error {
+	service := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      g.NamespacedName().Name,
+			Namespace: g.NamespacedName().Namespace,
+			Labels:    meta.WithOwnerLabel(endpoint.MergeLabels(g.Labels(), g.userLabels)),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:     g.NamespacedName().Name,
+					Protocol: corev1.ProtocolTCP,
+					Port:     g.Port(),
+					TargetPort: intstr.IntOrString{
+						Type:   intstr.Int,
+						IntVal: g.Port()},
+				},
+			},
+			Selector: endpoint.SelectorLabels(g),
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}
+	return meta.Apply(ctx, c, &service)
+}
+
+func (g *GatewayEndpoint) createRoute(ctx context.Context, c client.Client) error {
+	parentRef := map[string]interface{}{
+		"group":     GroupVersion.Group,
+		"kind":      "Gateway",
+		"name":      g.gateway.Name,
+		"namespace": g.gateway.Namespace,
+	}
+	if g.sectionName != "" {
+		parentRef["sectionName"] = g.sectionName
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": g.NamespacedName().Name,
+						"port": int64(g.Port()),
+					},
+				},
+			},
+		},
+	}
+	if g.routeType == RouteTypeTLS {
+		spec["hostnames"] = []interface{}{g.hostname}
+	}
+	route := g.newRoute()
+	route.SetName(g.NamespacedName().Name)
+	route.SetNamespace(g.NamespacedName().Namespace)
+	route.SetLabels(meta.WithOwnerLabel(endpoint.MergeLabels(g.Labels(), g.userLabels)))
+	route.Object["spec"] = spec
+
+	return meta.Apply(ctx, c, route)
+}
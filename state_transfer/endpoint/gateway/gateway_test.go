@@ -0,0 +1,240 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var (
+	testName    = types.NamespacedName{Namespace: "test-namespace", Name: "test-gateway-route"}
+	testGateway = types.NamespacedName{Namespace: "gateways", Name: "transfer"}
+)
+
+func TestNewEndpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		routeType RouteType
+		gateway   types.NamespacedName
+		options   Options
+		wantPort  int32
+		wantErr   bool
+	}{
+		{
+			name:      "tls route with the default port",
+			routeType: RouteTypeTLS,
+			gateway:   testGateway,
+			options:   Options{Hostname: "transfer.example.com"},
+			wantPort:  443,
+		},
+		{
+			name:      "tls route without hostname",
+			routeType: RouteTypeTLS,
+			gateway:   testGateway,
+			wantErr:   true,
+		},
+		{
+			name:      "tcp route",
+			routeType: RouteTypeTCP,
+			gateway:   testGateway,
+			options:   Options{Port: 9000},
+			wantPort:  9000,
+		},
+		{
+			name:      "tcp route without port",
+			routeType: RouteTypeTCP,
+			gateway:   testGateway,
+			wantErr:   true,
+		},
+		{
+			name:      "missing gateway",
+			routeType: RouteTypeTCP,
+			options:   Options{Port: 9000},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown route type",
+			routeType: "HTTPRoute",
+			gateway:   testGateway,
+			options:   Options{Hostname: "transfer.example.com"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEndpoint(testName, tt.routeType, nil, tt.gateway, nil, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && e.ExposedPort() != tt.wantPort {
+				t.Errorf("exposed port %d, want %d", e.ExposedPort(), tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestCreateTLSRoute(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	e, err := NewEndpoint(testName, RouteTypeTLS, map[string]string{"app": "crane"}, testGateway, nil,
+		Options{Hostname: "transfer.example.com", SectionName: "tls"})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+
+	service := &corev1.Service{}
+	if err := c.Get(context.TODO(), testName, service); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	if service.Spec.Type != corev1.ServiceTypeClusterIP || service.Spec.Ports[0].Port != 6443 || service.Spec.Selector["app"] != "crane" {
+		t.Errorf("unexpected service spec %+v", service.Spec)
+	}
+
+	route := e.(*GatewayEndpoint).newRoute()
+	if err := c.Get(context.TODO(), testName, route); err != nil {
+		t.Fatalf("unable to get route: %v", err)
+	}
+	if !meta.IsOwned(route.GetLabels()) || route.GetLabels()["app"] != "crane" {
+		t.Errorf("unexpected route labels %v", route.GetLabels())
+	}
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hostnames) != 1 || hostnames[0] != "transfer.example.com" {
+		t.Errorf("unexpected route hostnames %v", hostnames)
+	}
+	parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if len(parents) != 1 {
+		t.Fatalf("unexpected route parents %v", parents)
+	}
+	parent := parents[0].(map[string]interface{})
+	if parent["name"] != "transfer" || parent["namespace"] != "gateways" || parent["sectionName"] != "tls" {
+		t.Errorf("unexpected route parent %v", parent)
+	}
+
+	if healthy, err := e.IsHealthy(context.TODO(), c); healthy || err != nil {
+		t.Errorf("IsHealthy() = %t, %v before the route is accepted", healthy, err)
+	}
+	setRouteStatus(t, c, route, "False")
+	if healthy, err := e.IsHealthy(context.TODO(), c); healthy || !errors.Is(err, endpoint.ErrEndpointNotReady) {
+		t.Errorf("IsHealthy() = %t, %v when the route is rejected, want %v", healthy, err, endpoint.ErrEndpointNotReady)
+	}
+	setRouteStatus(t, c, route, "True")
+	if healthy, err := e.IsHealthy(context.TODO(), c); !healthy || err != nil {
+		t.Errorf("IsHealthy() = %t, %v once the route is accepted", healthy, err)
+	}
+}
+
+func TestTCPRouteHostnameFromGateway(t *testing.T) {
+	gw := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "IPAddress", "value": "192.0.2.10"},
+			},
+		},
+	}}
+	gw.SetGroupVersionKind(GroupVersion.WithKind("Gateway"))
+	gw.SetNamespace(testGateway.Namespace)
+	gw.SetName(testGateway.Name)
+	c := fake.NewClientBuilder().WithRuntimeObjects(gw).Build()
+
+	e, err := NewEndpoint(testName, RouteTypeTCP, nil, testGateway, nil, Options{Port: 9000})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+	route := e.(*GatewayEndpoint).newRoute()
+	if err := c.Get(context.TODO(), testName, route); err != nil {
+		t.Fatalf("unable to get route: %v", err)
+	}
+	if _, found, _ := unstructured.NestedSlice(route.Object, "spec", "hostnames"); found {
+		t.Errorf("a TCPRoute should not have hostnames")
+	}
+	setRouteStatus(t, c, route, "True")
+	if healthy, err := e.IsHealthy(context.TODO(), c); !healthy || err != nil {
+		t.Fatalf("IsHealthy() = %t, %v once the route is accepted", healthy, err)
+	}
+	if e.Hostname() != "192.0.2.10" {
+		t.Errorf("hostname %q, want the address of the gateway", e.Hostname())
+	}
+}
+
+func TestRender(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	e, err := NewEndpoint(testName, RouteTypeTCP, nil, testGateway, nil, Options{Port: 9000})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	objects, err := endpoint.Render(context.TODO(), e, c)
+	if err != nil {
+		t.Fatalf("Render() unexpected error %v", err)
+	}
+	kinds := []string{}
+	for _, obj := range objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	if len(kinds) != 2 || kinds[0] != "Service" || kinds[1] != "TCPRoute" {
+		t.Errorf("Render() rendered %v, want the Service and the TCPRoute", kinds)
+	}
+}
+
+func TestAddToScheme(t *testing.T) {
+	e, err := NewEndpoint(testName, RouteTypeTCP, nil, testGateway, nil, Options{Port: 9000})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	s := runtime.NewScheme()
+	if err := e.(*GatewayEndpoint).AddToScheme(s); err != nil {
+		t.Fatalf("AddToScheme() unexpected error %v", err)
+	}
+	if !s.Recognizes(corev1.SchemeGroupVersion.WithKind("Service")) {
+		t.Errorf("scheme does not recognize services")
+	}
+}
+
+func setRouteStatus(t *testing.T, c client.Client, route *unstructured.Unstructured, accepted string) {
+	route.Object["status"] = map[string]interface{}{
+		"parents": []interface{}{
+			map[string]interface{}{
+				"parentRef": map[string]interface{}{"name": testGateway.Name, "namespace": testGateway.Namespace},
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Accepted", "status": accepted, "message": "listener not found"},
+				},
+			},
+		},
+	}
+	if err := c.Update(context.TODO(), route); err != nil {
+		t.Fatalf("unable to update route status: %v", err)
+	}
+}
+
+func TestValidateProtocol(t *testing.T) {
+	tls, err := NewEndpoint(testName, RouteTypeTLS, nil, testGateway, nil, Options{Hostname: "transfer.example.com"})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	if err := endpoint.ValidateProtocol(tls, endpoint.ProtocolTLS); err != nil {
+		t.Errorf("TLSRoute should accept TLS connections: %v", err)
+	}
+	if err := endpoint.ValidateProtocol(tls, endpoint.ProtocolSSH); err == nil {
+		t.Errorf("TLSRoute should reject connections which are not TLS")
+	}
+	tcp, err := NewEndpoint(testName, RouteTypeTCP, nil, testGateway, nil, Options{Hostname: "transfer.example.com", Port: 9000})
+	if err != nil {
+		t.Fatalf("NewEndpoint() unexpected error %v", err)
+	}
+	if err := endpoint.ValidateProtocol(tcp, endpoint.ProtocolSSH); err != nil {
+		t.Errorf("TCPRoute should accept every protocol: %v", err)
+	}
+}
@@ -1,19 +1,25 @@
 package ingress
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"text/template"
 
+	"github.com/go-logr/logr"
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -21,17 +27,27 @@ type IngressEndpoint struct {
 	hostname string
 
 	labels         map[string]string
+	userLabels     map[string]string
+	selectorLabels map[string]string
 	port           int32
 	namespacedName types.NamespacedName
+
+	ingressClassName string
+
+	log logr.Logger
 }
 
-func (i *IngressEndpoint) Create(c client.Client) error {
+// defaultHostnameTemplate builds the hostname NewEndpoint uses
+const defaultHostnameTemplate = "{{ .Prefix }}.{{ .Subdomain }}"
+
+func (i *IngressEndpoint) Create(ctx context.Context, c client.Client) error {
+	c = meta.NewLoggingClient(c, i.log)
 	errs := []error{}
 
-	err := i.createIngressService(c)
+	err := i.createIngressService(ctx, c)
 	errs = append(errs, err)
 
-	err = i.createIngress(c)
+	err = i.createIngress(ctx, c)
 	errs = append(errs, err)
 
 	return errorsutil.NewAggregate(errs)
@@ -57,30 +73,84 @@ func (i *IngressEndpoint) Labels() map[string]string {
 	return i.labels
 }
 
-func (i *IngressEndpoint) IsHealthy(c client.Client) (bool, error) {
+// Copy returns a copy of the endpoint, see endpoint.Copier
+func (i *IngressEndpoint) Copy() endpoint.Endpoint {
+	copied := *i
+	return &copied
+}
+
+// ValidateProtocol rejects connections which are not TLS, the ingress controller routes ssl
+// passthrough connections on their TLS server name, see endpoint.ProtocolValidator
+func (i *IngressEndpoint) ValidateProtocol(p endpoint.Protocol) error {
+	if p != endpoint.ProtocolTLS {
+		return fmt.Errorf("the ingress controller routes ssl passthrough connections on their TLS server name, %s connections do not use TLS", p)
+	}
+	return nil
+}
+
+// SetUserLabels sets additional labels on the Ingress and its Service, both are labelled
+// with the user labels and the managed Labels(), managed labels take precedence
+func (i *IngressEndpoint) SetUserLabels(labels map[string]string) {
+	i.userLabels = labels
+}
+
+// SetSelectorLabels sets the labels the Service of the Ingress selects the server pods with, in place of Labels()
+func (i *IngressEndpoint) SetSelectorLabels(labels map[string]string) {
+	i.selectorLabels = labels
+}
+
+// SelectorLabels returns the labels set with SetSelectorLabels
+func (i *IngressEndpoint) SelectorLabels() map[string]string {
+	return i.selectorLabels
+}
+
+// AddToScheme adds the Ingress API used by the endpoint to the given scheme
+func (i *IngressEndpoint) AddToScheme(s *runtime.Scheme) error {
+	if err := networkingv1.AddToScheme(s); err != nil {
+		return err
+	}
+	return corev1.AddToScheme(s)
+}
+
+// WatchedTypes returns the Ingress and Service lists, the endpoint is healthy once its Ingress has
+// a load balancer address, see endpoint.WaitForReady
+func (i *IngressEndpoint) WatchedTypes() []client.ObjectList {
+	return []client.ObjectList{&networkingv1.IngressList{}, &corev1.ServiceList{}}
+}
+
+func (i *IngressEndpoint) IsHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := i.isHealthy(ctx, c)
+	meta.LogHealthCheck(i.log, healthy, err)
+	return healthy, err
+}
+
+func (i *IngressEndpoint) isHealthy(ctx context.Context, c client.Client) (bool, error) {
 	ing := &networkingv1.Ingress{}
-	err := c.Get(context.TODO(), i.NamespacedName(), ing)
+	err := c.Get(ctx, i.NamespacedName(), ing)
 	if err != nil {
 		return false, err
 	}
 	if len(ing.Spec.Rules) > 0 && ing.Spec.Rules[0].Host == "" {
-		return false, fmt.Errorf("hostname not set for ingress: %s", ing)
+		return false, fmt.Errorf("%w: hostname not set for ingress: %s", endpoint.ErrEndpointNotReady, ing)
 	}
 
-	if len(ing.Status.LoadBalancer.Ingress) > 0 && ing.Status.LoadBalancer.Ingress[0].Hostname != "" {
-		return true, nil
+	// load balancers expose either a hostname or an IP
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" || lb.IP != "" {
+			return true, nil
+		}
 	}
 	return false, nil
 }
 
-func (i *IngressEndpoint) createIngressService(c client.Client) error {
-	serviceSelector := i.Labels()
+func (i *IngressEndpoint) createIngressService(ctx context.Context, c client.Client) error {
+	serviceSelector := endpoint.SelectorLabels(i)
 
 	service := corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      i.NamespacedName().Name,
 			Namespace: i.NamespacedName().Namespace,
-			Labels:    i.Labels(),
+			Labels:    meta.WithOwnerLabel(endpoint.MergeLabels(i.Labels(), i.userLabels)),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -97,22 +167,16 @@ func (i *IngressEndpoint) createIngressService(c client.Client) error {
 			Type:     corev1.ServiceTypeNodePort,
 		},
 	}
-	// TODO: consider patching an existing object if it already exists
-	err := c.Create(context.TODO(), &service, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	}
-
-	return nil
+	return meta.Apply(ctx, c, &service)
 }
 
-func (i *IngressEndpoint) createIngress(c client.Client) error {
+func (i *IngressEndpoint) createIngress(ctx context.Context, c client.Client) error {
 	pathType := networkingv1.PathTypePrefix
 	ing := networkingv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      i.NamespacedName().Name,
 			Namespace: i.NamespacedName().Namespace,
-			Labels:    i.Labels(),
+			Labels:    meta.WithOwnerLabel(endpoint.MergeLabels(i.Labels(), i.userLabels)),
 			Annotations: map[string]string{
 				"nginx.ingress.kubernetes.io/ssl-passthrough": "true",
 			},
@@ -140,11 +204,11 @@ func (i *IngressEndpoint) createIngress(c client.Client) error {
 		},
 	}
 
-	err := c.Create(context.TODO(), &ing, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
+	if i.ingressClassName != "" {
+		ing.Spec.IngressClassName = &i.ingressClassName
 	}
-	return nil
+
+	return meta.Apply(ctx, c, &ing)
 }
 
 func getMD5Hash(s string) string {
@@ -152,31 +216,109 @@ func getMD5Hash(s string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func NewEndpoint(namespacedName types.NamespacedName, labels map[string]string, subdomain string) endpoint.Endpoint {
-	ingressPrefix := fmt.Sprintf("%s-%s", namespacedName.Name, namespacedName.Namespace)
-	if len(ingressPrefix) > 62 {
-		ingressPrefix = fmt.Sprintf("%s-%s", namespacedName.Name, getMD5Hash(namespacedName.Namespace))
-	}
-
+func NewEndpoint(namespacedName types.NamespacedName, labels map[string]string, subdomain string, log logr.Logger) endpoint.Endpoint {
 	i := &IngressEndpoint{
 		namespacedName: namespacedName,
 		labels:         labels,
 		port:           6443,
-		hostname:       ingressPrefix + "." + subdomain,
+		hostname:       defaultHostnamePrefix(namespacedName) + "." + subdomain,
+		log:            endpoint.Logger(log, namespacedName),
 	}
 	return i
 }
 
-func (i *IngressEndpoint) setFields(c client.Client) error {
+// defaultHostnamePrefix returns the first label of the default hostname of the Ingress, the
+// namespace is hashed when the label would be too long
+func defaultHostnamePrefix(namespacedName types.NamespacedName) string {
+	ingressPrefix := fmt.Sprintf("%s-%s", namespacedName.Name, namespacedName.Namespace)
+	if len(ingressPrefix) > 62 {
+		ingressPrefix = fmt.Sprintf("%s-%s", namespacedName.Name, getMD5Hash(namespacedName.Namespace))
+	}
+	return ingressPrefix
+}
+
+// Options defines the hostname and the class of the Ingress
+type Options struct {
+	// Subdomain is the domain the hostname of the Ingress is built in
+	Subdomain string
+	// HostnameTemplate is a text/template building the hostname from the fields of HostnameFields,
+	// "{{ .Prefix }}.{{ .Subdomain }}" when empty
+	HostnameTemplate string
+	// IngressClassName is the class of the ingress controller serving the Ingress, ingress-nginx
+	// must run with --enable-ssl-passthrough. The default class of the cluster is used when empty.
+	IngressClassName string
+}
+
+// HostnameFields are the fields available to a hostname template
+type HostnameFields struct {
+	// Name is the name of the endpoint
+	Name string
+	// Namespace is the namespace of the endpoint
+	Namespace string
+	// Subdomain is the subdomain of the options
+	Subdomain string
+	// Prefix is "<name>-<namespace>", with the namespace hashed when it would be too long for a label
+	Prefix string
+}
+
+// NewEndpointWithOptions returns an Ingress endpoint for ingress-nginx with ssl passthrough, the
+// hostname is built from the template of the options. An error is returned when the template
+// is invalid or does not build a valid hostname.
+func NewEndpointWithOptions(namespacedName types.NamespacedName, labels map[string]string, log logr.Logger, options Options) (endpoint.Endpoint, error) {
+	hostnameTemplate := options.HostnameTemplate
+	if hostnameTemplate == "" {
+		hostnameTemplate = defaultHostnameTemplate
+	}
+	tmpl, err := template.New("hostname").Option("missingkey=error").Parse(hostnameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostname template: %w", err)
+	}
+	var hostname bytes.Buffer
+	err = tmpl.Execute(&hostname, HostnameFields{
+		Name:      namespacedName.Name,
+		Namespace: namespacedName.Namespace,
+		Subdomain: options.Subdomain,
+		Prefix:    defaultHostnamePrefix(namespacedName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostname template: %w", err)
+	}
+	if errs := validation.IsDNS1123Subdomain(hostname.String()); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid ingress hostname %q: %s", hostname.String(), strings.Join(errs, ", "))
+	}
+	for _, label := range strings.Split(hostname.String(), ".") {
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid ingress hostname %q: %s", hostname.String(), strings.Join(errs, ", "))
+		}
+	}
+	if options.IngressClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(options.IngressClassName); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid ingress class name %q: %s", options.IngressClassName, strings.Join(errs, ", "))
+		}
+	}
+	return &IngressEndpoint{
+		namespacedName:   namespacedName,
+		labels:           labels,
+		port:             6443,
+		hostname:         hostname.String(),
+		ingressClassName: options.IngressClassName,
+		log:              endpoint.Logger(log, namespacedName),
+	}, nil
+}
+
+func (i *IngressEndpoint) setFields(ctx context.Context, c client.Client) error {
 	i.port = 6443
 
 	ing := &networkingv1.Ingress{}
-	err := c.Get(context.TODO(), i.NamespacedName(), ing)
+	err := c.Get(ctx, i.NamespacedName(), ing)
 	if err != nil {
 		return err
 	}
 
 	i.labels = ing.Labels
+	if ing.Spec.IngressClassName != nil {
+		i.ingressClassName = *ing.Spec.IngressClassName
+	}
 	if len(ing.Spec.Rules) > 0 {
 		i.hostname = ing.Spec.Rules[0].Host
 		return nil
@@ -186,18 +328,21 @@ func (i *IngressEndpoint) setFields(c client.Client) error {
 
 // GetEndpointFromKubeObjects check if the required Ingress is created and healthy. It populates the fields
 // for the Endpoint needed for transfer and transport objects.
-func GetEndpointFromKubeObjects(c client.Client, obj types.NamespacedName) (endpoint.Endpoint, error) {
-	i := &IngressEndpoint{namespacedName: obj}
+func GetEndpointFromKubeObjects(ctx context.Context, c client.Client, obj types.NamespacedName, log logr.Logger) (endpoint.Endpoint, error) {
+	i := &IngressEndpoint{namespacedName: obj, log: endpoint.Logger(log, obj)}
 
-	healthy, err := i.IsHealthy(c)
+	healthy, err := i.IsHealthy(ctx, c)
 	if err != nil {
 		return nil, err
 	}
 	if !healthy {
-		return nil, fmt.Errorf("ingress %s not healthy", obj)
+		return nil, fmt.Errorf("%w: ingress %s not healthy", endpoint.ErrEndpointNotReady, obj)
 	}
 
-	err = i.setFields(c)
+	err = i.setFields(ctx, c)
+	if err != nil {
+		return nil, err
+	}
 
 	return i, nil
 }
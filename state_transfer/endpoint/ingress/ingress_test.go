@@ -0,0 +1,117 @@
+package ingress
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testName = types.NamespacedName{Namespace: "test-namespace", Name: "test-ingress"}
+
+func TestNewEndpointWithOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      Options
+		wantHostname string
+		wantErr      bool
+	}{
+		{
+			name:         "default template",
+			options:      Options{Subdomain: "apps.example.com"},
+			wantHostname: "test-ingress-test-namespace.apps.example.com",
+		},
+		{
+			name:         "custom template",
+			options:      Options{Subdomain: "example.com", HostnameTemplate: "crane-{{ .Namespace }}.{{ .Subdomain }}"},
+			wantHostname: "crane-test-namespace.example.com",
+		},
+		{
+			name:    "unknown field",
+			options: Options{Subdomain: "example.com", HostnameTemplate: "{{ .Cluster }}.{{ .Subdomain }}"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid hostname",
+			options: Options{Subdomain: "example.com", HostnameTemplate: "{{ .Name }}_{{ .Namespace }}.{{ .Subdomain }}"},
+			wantErr: true,
+		},
+		{
+			name:    "label too long",
+			options: Options{Subdomain: "example.com", HostnameTemplate: "{{ .Name }}-{{ .Namespace }}-" + strings.Repeat("x", 40) + ".{{ .Subdomain }}"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid class",
+			options: Options{Subdomain: "example.com", IngressClassName: "Nginx Class"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEndpointWithOptions(testName, nil, nil, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewEndpointWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && e.Hostname() != tt.wantHostname {
+				t.Errorf("hostname %q, want %q", e.Hostname(), tt.wantHostname)
+			}
+		})
+	}
+	if got := NewEndpoint(testName, nil, "apps.example.com", nil).Hostname(); got != "test-ingress-test-namespace.apps.example.com" {
+		t.Errorf("NewEndpoint() hostname %q does not match the default template", got)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s := runtime.NewScheme()
+	e, err := NewEndpointWithOptions(testName, map[string]string{"app": "crane"}, nil, Options{Subdomain: "example.com", IngressClassName: "nginx"})
+	if err != nil {
+		t.Fatalf("NewEndpointWithOptions() unexpected error %v", err)
+	}
+	if err := e.(*IngressEndpoint).AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+
+	ing := &networkingv1.Ingress{}
+	if err := c.Get(context.TODO(), testName, ing); err != nil {
+		t.Fatalf("unable to get ingress: %v", err)
+	}
+	if ing.Annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] != "true" {
+		t.Errorf("ingress is not annotated for ssl passthrough: %v", ing.Annotations)
+	}
+	if ing.Spec.IngressClassName == nil || *ing.Spec.IngressClassName != "nginx" {
+		t.Errorf("unexpected ingress class %v", ing.Spec.IngressClassName)
+	}
+	if ing.Spec.Rules[0].Host != e.Hostname() {
+		t.Errorf("ingress host %s, want %s", ing.Spec.Rules[0].Host, e.Hostname())
+	}
+
+	if healthy, err := e.IsHealthy(context.TODO(), c); healthy || err != nil {
+		t.Errorf("IsHealthy() = %t, %v before the load balancer is set", healthy, err)
+	}
+	ing.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.0.2.10"}}
+	if err := c.Update(context.TODO(), ing); err != nil {
+		t.Fatalf("unable to update ingress status: %v", err)
+	}
+	if healthy, err := e.IsHealthy(context.TODO(), c); !healthy || err != nil {
+		t.Errorf("IsHealthy() = %t, %v once the load balancer has an IP", healthy, err)
+	}
+
+	found, err := GetEndpointFromKubeObjects(context.TODO(), c, testName, nil)
+	if err != nil {
+		t.Fatalf("GetEndpointFromKubeObjects() unexpected error %v", err)
+	}
+	if found.Hostname() != e.Hostname() || found.(*IngressEndpoint).ingressClassName != "nginx" {
+		t.Errorf("endpoint read from the ingress does not match the created one")
+	}
+}
@@ -0,0 +1,89 @@
+package endpoint
+
+import (
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+)
+
+// UserLabeler knows how to apply additional, user supplied labels to the resources of an Endpoint
+type UserLabeler interface {
+	// SetUserLabels sets the labels added to the resources created by the endpoint
+	SetUserLabels(labels map[string]string)
+}
+
+// SetUserLabels adds the given labels to the resources created for the Endpoint, which
+// allows NetworkPolicies to select the Service, Route or Ingress carrying the transfer.
+// It must be called before the endpoint is created. The resources are labelled with the
+// union of the user labels and the managed Labels(), managed labels take precedence on
+// conflicting keys. Service selectors only ever use the SelectorLabels of the endpoint.
+func SetUserLabels(e Endpoint, labels map[string]string) error {
+	for _, w := range chain(e) {
+		if labeler, ok := w.(UserLabeler); ok {
+			labeler.SetUserLabels(labels)
+			return nil
+		}
+	}
+	return fmt.Errorf("endpoint %s does not support user labels", e.NamespacedName())
+}
+
+// SelectorLabeler knows how to select the transfer server pods with labels distinct from the
+// labels of the resources of an Endpoint
+type SelectorLabeler interface {
+	// SetSelectorLabels sets the labels the Service of the endpoint selects the server pods with
+	SetSelectorLabels(labels map[string]string)
+	// SelectorLabels returns the labels set with SetSelectorLabels, if any
+	SelectorLabels() map[string]string
+}
+
+// SetSelectorLabels sets the labels the Service of the Endpoint selects the transfer server pods
+// with, which NetworkPolicies and PodDisruptionBudgets can use to target the server pods precisely.
+// It must be called before the endpoint and the transfer server are created, transfers add the
+// selector labels to the server pods so that they always match the Service selector. The
+// resources of the endpoint keep the managed Labels() and the user labels.
+func SetSelectorLabels(e Endpoint, labels map[string]string) error {
+	labeler, ok := selectorLabeler(e)
+	if !ok {
+		return fmt.Errorf("endpoint %s does not support selector labels", e.NamespacedName())
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("selector labels of endpoint %s must not be empty", e.NamespacedName())
+	}
+	if err := meta.ValidateLabels(labels); err != nil {
+		return err
+	}
+	labeler.SetSelectorLabels(labels)
+	return nil
+}
+
+// SelectorLabels returns the labels the Service of the Endpoint selects the transfer server pods
+// with, the managed Labels() unless selector labels were set with SetSelectorLabels
+func SelectorLabels(e Endpoint) map[string]string {
+	if labeler, ok := selectorLabeler(e); ok && len(labeler.SelectorLabels()) > 0 {
+		return labeler.SelectorLabels()
+	}
+	return e.Labels()
+}
+
+// selectorLabeler returns the SelectorLabeler among e and the endpoints it wraps
+func selectorLabeler(e Endpoint) (SelectorLabeler, bool) {
+	for _, w := range chain(e) {
+		if labeler, ok := w.(SelectorLabeler); ok {
+			return labeler, true
+		}
+	}
+	return nil, false
+}
+
+// MergeLabels returns the labels set on the resources of an endpoint, the managed
+// labels take precedence over the user labels
+func MergeLabels(managed, user map[string]string) map[string]string {
+	labels := make(map[string]string, len(managed)+len(user))
+	for key, val := range user {
+		labels[key] = val
+	}
+	for key, val := range managed {
+		labels[key] = val
+	}
+	return labels
+}
@@ -0,0 +1,16 @@
+package endpoint
+
+import (
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Logger returns the logger an endpoint given to its constructor logs the resources it creates
+// and its health checks with, the name of the endpoint is added as a value. Nothing is logged
+// when the given logger is nil.
+func Logger(log logr.Logger, namespacedName types.NamespacedName) logr.Logger {
+	if log == nil {
+		return nil
+	}
+	return log.WithValues("endpoint", namespacedName.String())
+}
@@ -0,0 +1,33 @@
+package endpoint
+
+// Protocol is the protocol of the connections clients open to an Endpoint
+type Protocol string
+
+const (
+	// ProtocolTCP is a protocol which is neither TLS nor HTTP, like the rsync daemon protocol
+	ProtocolTCP Protocol = "tcp"
+	// ProtocolHTTP is plain HTTP, like the protocol of the rclone server
+	ProtocolHTTP Protocol = "http"
+	// ProtocolTLS is TLS with the hostname of the endpoint as server name, like stunnel tunnels
+	ProtocolTLS Protocol = "tls"
+	// ProtocolSSH is the protocol of ssh tunnels
+	ProtocolSSH Protocol = "ssh"
+)
+
+// ProtocolValidator is implemented by endpoints which cannot carry connections of every protocol
+type ProtocolValidator interface {
+	// ValidateProtocol returns an error explaining why connections of the given protocol cannot
+	// reach the server through the endpoint
+	ValidateProtocol(p Protocol) error
+}
+
+// ValidateProtocol returns an error when connections of the given protocol cannot reach the server
+// through the Endpoint, endpoints which do not implement ProtocolValidator accept every protocol
+func ValidateProtocol(e Endpoint, p Protocol) error {
+	for _, w := range chain(e) {
+		if v, ok := w.(ProtocolValidator); ok {
+			return v.ValidateProtocol(p)
+		}
+	}
+	return nil
+}
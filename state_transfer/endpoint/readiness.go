@@ -0,0 +1,117 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultReadinessInterval is the interval WaitForReady checks the health of an endpoint at when
+// no watched resource changed, and when its interval is not set
+const DefaultReadinessInterval = 5 * time.Second
+
+// ErrEndpointUnreachable is returned when no connection can be opened to the exposed hostname and
+// port of an endpoint
+var ErrEndpointUnreachable = errors.New("endpoint unreachable")
+
+// ReadinessWatcher knows the API types the health of an Endpoint depends on, so that WaitForReady
+// checks it again as soon as one of them changes
+type ReadinessWatcher interface {
+	// WatchedTypes returns the lists of the API types watched in the namespace of the endpoint
+	WatchedTypes() []client.ObjectList
+}
+
+// WatchedTypes returns the lists of the API types the health of the given Endpoint depends on,
+// endpoints which do not implement ReadinessWatcher are assumed to depend on Services and Pods
+func WatchedTypes(e Endpoint) []client.ObjectList {
+	for _, wrapped := range chain(e) {
+		if w, ok := wrapped.(ReadinessWatcher); ok {
+			return w.WatchedTypes()
+		}
+	}
+	return []client.ObjectList{&corev1.ServiceList{}, &corev1.PodList{}}
+}
+
+// WaitForReady waits until the given Endpoint is healthy, so that callers do not need to poll
+// IsHealthy themselves. The health is checked again whenever a resource of the types returned by
+// WatchedTypes changes in the namespace of the endpoint, and at least every interval, which
+// defaults to DefaultReadinessInterval. Types which cannot be watched, e.g. because the client
+// does not implement client.WithWatch or lacks the watch verb, are only polled. An error wrapping
+// ErrEndpointNotReady and the last health check error is returned when the endpoint is not healthy
+// within the timeout, a zero timeout waits until the context is done.
+func WaitForReady(ctx context.Context, c client.Client, e Endpoint, interval time.Duration, timeout time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultReadinessInterval
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	changed := make(chan struct{}, 1)
+	if wc, ok := c.(client.WithWatch); ok {
+		for _, list := range WatchedTypes(e) {
+			w, err := wc.Watch(ctx, list, client.InNamespace(e.NamespacedName().Namespace))
+			if err != nil {
+				continue
+			}
+			defer w.Stop()
+			go notifyChanges(w, changed)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		healthy, err := e.IsHealthy(ctx, c)
+		if healthy {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: endpoint %s did not become ready: %v", ErrEndpointNotReady, e.NamespacedName(), lastErr)
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// notifyChanges signals every event of the watch without blocking, until the watch is stopped
+func notifyChanges(w watch.Interface, changed chan<- struct{}) {
+	for range w.ResultChan() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// CheckReachable opens a TCP connection to the exposed hostname and port of the given Endpoint and
+// closes it, so that callers can confirm that the endpoint is reachable end to end, e.g. from the
+// source cluster, before starting a transfer. The endpoint must be healthy so that its hostname is
+// known, and a server must be listening behind it. An error wrapping ErrEndpointUnreachable is
+// returned when the connection cannot be opened within the timeout.
+func CheckReachable(ctx context.Context, e Endpoint, timeout time.Duration) error {
+	if e.Hostname() == "" {
+		return fmt.Errorf("%w: endpoint %s has no hostname", ErrEndpointUnreachable, e.NamespacedName())
+	}
+	address := net.JoinHostPort(e.Hostname(), strconv.Itoa(int(e.ExposedPort())))
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("%w: endpoint %s at %s: %v", ErrEndpointUnreachable, e.NamespacedName(), address, err)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,48 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// staticEndpoint is an Endpoint at a fixed address without resources
+type staticEndpoint struct {
+	hostname string
+	port     int32
+}
+
+func (s *staticEndpoint) Create(context.Context, client.Client) error { return nil }
+func (s *staticEndpoint) Hostname() string                            { return s.hostname }
+func (s *staticEndpoint) Port() int32                                 { return s.port }
+func (s *staticEndpoint) ExposedPort() int32                          { return s.port }
+func (s *staticEndpoint) Labels() map[string]string                   { return nil }
+func (s *staticEndpoint) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: "ns", Name: "static"}
+}
+func (s *staticEndpoint) IsHealthy(context.Context, client.Client) (bool, error) { return true, nil }
+
+func TestCheckReachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	port := int32(listener.Addr().(*net.TCPAddr).Port)
+	e := &staticEndpoint{hostname: "127.0.0.1", port: port}
+	if err := CheckReachable(context.TODO(), e, time.Second); err != nil {
+		t.Errorf("CheckReachable() unexpected error %v", err)
+	}
+
+	listener.Close()
+	if err := CheckReachable(context.TODO(), e, time.Second); !errors.Is(err, ErrEndpointUnreachable) {
+		t.Errorf("CheckReachable() error = %v, want %v", err, ErrEndpointUnreachable)
+	}
+	if err := CheckReachable(context.TODO(), &staticEndpoint{}, time.Second); !errors.Is(err, ErrEndpointUnreachable) {
+		t.Errorf("CheckReachable() error = %v, want %v without hostname", err, ErrEndpointUnreachable)
+	}
+}
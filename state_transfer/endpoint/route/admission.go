@@ -0,0 +1,116 @@
+package route
+
+import (
+	"context"
+	"fmt"
+
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AdmissionPhase is the admission state of a Route reported by the router
+type AdmissionPhase string
+
+const (
+	// AdmissionPhasePending means no router has reported on the Route yet
+	AdmissionPhasePending AdmissionPhase = "Pending"
+	// AdmissionPhaseAdmitted means the router admitted the Route
+	AdmissionPhaseAdmitted AdmissionPhase = "Admitted"
+	// AdmissionPhaseRejected means the router rejected the Route, Reason and
+	// Message explain why, for example a host conflict or an invalid TLS config
+	AdmissionPhaseRejected AdmissionPhase = "Rejected"
+)
+
+// AdmissionStatus is the admission state of a Route
+type AdmissionStatus struct {
+	Phase      AdmissionPhase
+	RouterName string
+	Host       string
+	Reason     string
+	Message    string
+}
+
+func (a AdmissionStatus) String() string {
+	if a.Phase != AdmissionPhaseRejected {
+		return string(a.Phase)
+	}
+	return fmt.Sprintf("%s by router %s: %s: %s", a.Phase, a.RouterName, a.Reason, a.Message)
+}
+
+// GetAdmissionStatus returns the admission state of the given Route as reported by the first router
+func GetAdmissionStatus(route *routev1.Route) AdmissionStatus {
+	if len(route.Status.Ingress) == 0 {
+		return AdmissionStatus{Phase: AdmissionPhasePending}
+	}
+	ingress := route.Status.Ingress[0]
+	status := AdmissionStatus{
+		Phase:      AdmissionPhasePending,
+		RouterName: ingress.RouterName,
+		Host:       ingress.Host,
+	}
+	for _, c := range ingress.Conditions {
+		if c.Type != routev1.RouteAdmitted {
+			continue
+		}
+		switch c.Status {
+		case corev1.ConditionTrue:
+			status.Phase = AdmissionPhaseAdmitted
+		case corev1.ConditionFalse:
+			status.Phase = AdmissionPhaseRejected
+		}
+		status.Reason = c.Reason
+		status.Message = c.Message
+	}
+	return status
+}
+
+// WatchAdmission watches the Route with the given name and calls onTransition with the current
+// admission state and every time it changes afterwards, so that router rejections can be
+// surfaced to users. It blocks until the context is done or the watch is closed by the server.
+func WatchAdmission(ctx context.Context, c client.WithWatch, name types.NamespacedName, onTransition func(AdmissionStatus)) error {
+	var last *AdmissionStatus
+	report := func(route *routev1.Route) {
+		status := GetAdmissionStatus(route)
+		if last != nil && *last == status {
+			return
+		}
+		last = &status
+		onTransition(status)
+	}
+
+	route := &routev1.Route{}
+	err := c.Get(ctx, name, route)
+	if err != nil {
+		return err
+	}
+	report(route)
+
+	w, err := c.Watch(ctx, &routev1.RouteList{},
+		client.InNamespace(name.Namespace),
+		client.MatchingFields{"metadata.name": name.Name})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			route, ok := event.Object.(*routev1.Route)
+			if !ok || route.Name != name.Name {
+				continue
+			}
+			report(route)
+		}
+	}
+}
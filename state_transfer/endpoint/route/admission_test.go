@@ -0,0 +1,110 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func admittedCondition(status corev1.ConditionStatus, reason string) routev1.RouteIngress {
+	return routev1.RouteIngress{
+		RouterName: "default",
+		Host:       "test.example.com",
+		Conditions: []routev1.RouteIngressCondition{
+			{Type: routev1.RouteAdmitted, Status: status, Reason: reason},
+		},
+	}
+}
+
+func TestWatchAdmission(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := routev1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-route"}
+	route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name}}
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(route).Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	transitions := make(chan AdmissionStatus, 10)
+	done := make(chan error)
+	go func() {
+		done <- WatchAdmission(ctx, c, name, func(s AdmissionStatus) { transitions <- s })
+	}()
+
+	next := func() AdmissionStatus {
+		select {
+		case s := <-transitions:
+			return s
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for an admission transition")
+		}
+		return AdmissionStatus{}
+	}
+	if s := next(); s.Phase != AdmissionPhasePending {
+		t.Fatalf("expected Pending initial status, got %s", s)
+	}
+
+	route.Status.Ingress = []routev1.RouteIngress{admittedCondition(corev1.ConditionFalse, "HostAlreadyClaimed")}
+	if err := c.Update(ctx, route); err != nil {
+		t.Fatalf("unable to update route: %v", err)
+	}
+	if s := next(); s.Phase != AdmissionPhaseRejected || s.Reason != "HostAlreadyClaimed" {
+		t.Fatalf("expected Rejected status with reason HostAlreadyClaimed, got %s", s)
+	}
+
+	route.Status.Ingress = []routev1.RouteIngress{admittedCondition(corev1.ConditionTrue, "")}
+	if err := c.Update(ctx, route); err != nil {
+		t.Fatalf("unable to update route: %v", err)
+	}
+	if s := next(); s.Phase != AdmissionPhaseAdmitted {
+		t.Fatalf("expected Admitted status, got %s", s)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchAdmission() unexpected error %v", err)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-route"}
+	e := NewEndpoint(name, EndpointTypePassthrough, nil, "test.domain", nil)
+	s := runtime.NewScheme()
+	if err := e.(*RouteEndpoint).AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+
+	err := endpoint.WaitForReady(context.TODO(), c, e, time.Hour, 20*time.Millisecond)
+	if !errors.Is(err, endpoint.ErrEndpointNotReady) {
+		t.Errorf("WaitForReady() error = %v, want %v", err, endpoint.ErrEndpointNotReady)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		route := &routev1.Route{}
+		if err := c.Get(context.TODO(), name, route); err != nil {
+			return
+		}
+		route.Status.Ingress = []routev1.RouteIngress{admittedCondition(corev1.ConditionTrue, "")}
+		_ = c.Update(context.TODO(), route)
+	}()
+	// the polling interval is too long for the test to pass without the watch
+	if err := endpoint.WaitForReady(context.TODO(), c, e, time.Hour, 5*time.Second); err != nil {
+		t.Errorf("WaitForReady() unexpected error %v", err)
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	"github.com/go-logr/logr"
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
 	routev1 "github.com/openshift/api/route/v1"
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -20,6 +23,11 @@ import (
 const (
 	EndpointTypePassthrough  = "EndpointTypePassthrough"
 	EndpointTypeInsecureEdge = "EndpointTypeInsecureEdge"
+	// EndpointTypeEdge terminates TLS at the router and rejects insecure connections
+	EndpointTypeEdge = "EndpointTypeEdge"
+	// EndpointTypeReencrypt terminates TLS at the router and opens a new TLS connection to
+	// the service, verified with the destination CA certificate when one is given
+	EndpointTypeReencrypt = "EndpointTypeReencrypt"
 )
 
 type RouteEndpointType string
@@ -29,12 +37,25 @@ type RouteEndpoint struct {
 	subdomain string
 
 	labels         map[string]string
+	userLabels     map[string]string
+	selectorLabels map[string]string
 	port           int32
 	endpointType   RouteEndpointType
 	namespacedName types.NamespacedName
+
+	destinationCACertificate string
+
+	log logr.Logger
+}
+
+// TLSOptions defines the TLS configuration of a Route endpoint
+type TLSOptions struct {
+	// DestinationCACertificate is the PEM encoded CA the router uses to verify the service
+	// certificate, only valid with EndpointTypeReencrypt
+	DestinationCACertificate string
 }
 
-func NewEndpoint(namespacedName types.NamespacedName, eType RouteEndpointType, labels map[string]string, subdomain string) endpoint.Endpoint {
+func NewEndpoint(namespacedName types.NamespacedName, eType RouteEndpointType, labels map[string]string, subdomain string, log logr.Logger) endpoint.Endpoint {
 	if eType != EndpointTypePassthrough && eType != EndpointTypeInsecureEdge {
 		panic("unsupported endpoint type for routes")
 	}
@@ -43,16 +64,89 @@ func NewEndpoint(namespacedName types.NamespacedName, eType RouteEndpointType, l
 		subdomain:      subdomain,
 		labels:         labels,
 		endpointType:   eType,
+		log:            endpoint.Logger(log, namespacedName),
+	}
+}
+
+// NewEndpointWithTLS returns a Route endpoint with the given termination type and TLS options.
+// Unlike NewEndpoint it returns an error for invalid configurations instead of panicking.
+// Transfers reject terminations which cannot carry their transport, see ValidateProtocol.
+func NewEndpointWithTLS(namespacedName types.NamespacedName, eType RouteEndpointType, labels map[string]string, subdomain string, log logr.Logger, tlsOptions TLSOptions) (endpoint.Endpoint, error) {
+	switch eType {
+	case EndpointTypePassthrough, EndpointTypeInsecureEdge, EndpointTypeEdge, EndpointTypeReencrypt:
+	default:
+		return nil, fmt.Errorf("unsupported endpoint type %s for routes", eType)
+	}
+	if tlsOptions.DestinationCACertificate != "" {
+		if eType != EndpointTypeReencrypt {
+			return nil, fmt.Errorf("destination CA certificate is only supported with %s", EndpointTypeReencrypt)
+		}
+		if err := transport.ValidateCABundle([]byte(tlsOptions.DestinationCACertificate)); err != nil {
+			return nil, err
+		}
+	}
+	return &RouteEndpoint{
+		namespacedName:           namespacedName,
+		subdomain:                subdomain,
+		labels:                   labels,
+		endpointType:             eType,
+		destinationCACertificate: tlsOptions.DestinationCACertificate,
+		log:                      endpoint.Logger(log, namespacedName),
+	}, nil
+}
+
+// ValidateProtocol returns an error when the termination of the Route cannot carry connections of
+// the given protocol, see endpoint.ProtocolValidator. TLS tunnels, like the connections of the
+// stunnel transport, require passthrough termination, otherwise the router terminates the tunnel
+// with its own certificate. Other protocols are only accepted as HTTP by insecure edge routes.
+func (r *RouteEndpoint) ValidateProtocol(p endpoint.Protocol) error {
+	switch r.endpointType {
+	case EndpointTypePassthrough:
+		if p != endpoint.ProtocolTLS {
+			return fmt.Errorf("the router routes passthrough connections on their TLS server name, %s connections do not use TLS", p)
+		}
+	case EndpointTypeEdge, EndpointTypeReencrypt:
+		if p == endpoint.ProtocolTLS {
+			return fmt.Errorf("the router terminates TLS with its own certificate, a tunnel requires %s", EndpointTypePassthrough)
+		}
+		return fmt.Errorf("the router only accepts TLS connections with %s, %s connections do not use TLS", r.endpointType, p)
+	case EndpointTypeInsecureEdge:
+		if p != endpoint.ProtocolHTTP {
+			return fmt.Errorf("insecure connections to a route must be HTTP, not %s", p)
+		}
 	}
+	return nil
+}
+
+// SetUserLabels sets additional labels on the Route and its Service, both are labelled
+// with the user labels and the managed Labels(), managed labels take precedence
+func (r *RouteEndpoint) SetUserLabels(labels map[string]string) {
+	r.userLabels = labels
 }
 
-func (r *RouteEndpoint) Create(c client.Client) error {
+// SetSelectorLabels sets the labels the Service of the Route selects the server pods with, in place of Labels()
+func (r *RouteEndpoint) SetSelectorLabels(labels map[string]string) {
+	r.selectorLabels = labels
+}
+
+// SelectorLabels returns the labels set with SetSelectorLabels
+func (r *RouteEndpoint) SelectorLabels() map[string]string {
+	return r.selectorLabels
+}
+
+// EndpointType returns the termination type of the Route
+func (r *RouteEndpoint) EndpointType() RouteEndpointType {
+	return r.endpointType
+}
+
+func (r *RouteEndpoint) Create(ctx context.Context, c client.Client) error {
+	c = meta.NewLoggingClient(c, r.log)
 	errs := []error{}
 
-	err := r.createRoute(c)
+	err := r.createRoute(ctx, c)
 	errs = append(errs, err)
 
-	err = r.createRouteService(c)
+	err = r.createRouteService(ctx, c)
 	errs = append(errs, err)
 
 	return errorsutil.NewAggregate(errs)
@@ -78,41 +172,66 @@ func (r *RouteEndpoint) Labels() map[string]string {
 	return r.labels
 }
 
+// Copy returns a copy of the endpoint, see endpoint.Copier
+func (r *RouteEndpoint) Copy() endpoint.Endpoint {
+	copied := *r
+	return &copied
+}
+
 func (r *RouteEndpoint) ExposedPort() int32 {
 	return 443
 }
 
-func (r *RouteEndpoint) IsHealthy(c client.Client) (bool, error) {
+// AddToScheme adds the OpenShift Route API used by the endpoint to the given scheme
+func (r *RouteEndpoint) AddToScheme(s *runtime.Scheme) error {
+	if err := routev1.AddToScheme(s); err != nil {
+		return err
+	}
+	return corev1.AddToScheme(s)
+}
+
+// WatchedTypes returns the Route and Service lists, the endpoint is healthy once its Route is
+// admitted, see endpoint.WaitForReady
+func (r *RouteEndpoint) WatchedTypes() []client.ObjectList {
+	return []client.ObjectList{&routev1.RouteList{}, &corev1.ServiceList{}}
+}
+
+func (r *RouteEndpoint) IsHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := r.isHealthy(ctx, c)
+	meta.LogHealthCheck(r.log, healthy, err)
+	return healthy, err
+}
+
+func (r *RouteEndpoint) isHealthy(ctx context.Context, c client.Client) (bool, error) {
 	route := &routev1.Route{}
-	err := c.Get(context.TODO(), r.NamespacedName(), route)
+	err := c.Get(ctx, r.NamespacedName(), route)
 	if err != nil {
 		return false, err
 	}
 	if route.Spec.Host == "" {
-		return false, fmt.Errorf("hostname not set for rsync route: %s", route)
+		return false, fmt.Errorf("%w: hostname not set for rsync route: %s", endpoint.ErrEndpointNotReady, route)
 	}
 
-	if len(route.Status.Ingress) > 0 && len(route.Status.Ingress[0].Conditions) > 0 {
-		for _, c := range route.Status.Ingress[0].Conditions {
-			if c.Type == routev1.RouteAdmitted && c.Status == corev1.ConditionTrue {
-				// TODO: remove setHostname and configure the hostname after this condition has been satisfied,
-				//  this is the implementation detail that we dont need the users of the interface work with
-				return true, nil
-			}
-		}
+	// TODO: remove setHostname and configure the hostname after the route is admitted,
+	//  this is the implementation detail that we dont need the users of the interface work with
+	admission := GetAdmissionStatus(route)
+	switch admission.Phase {
+	case AdmissionPhaseAdmitted:
+		return true, nil
+	case AdmissionPhaseRejected:
+		return false, fmt.Errorf("%w: route %s is %s", endpoint.ErrEndpointNotReady, r.NamespacedName(), admission)
 	}
-	// TODO: probably using error.Wrap/Unwrap here makes much more sense
-	return false, fmt.Errorf("route status is not in valid state: %s", route.Status)
+	return false, fmt.Errorf("%w: route status is not in valid state: %s", endpoint.ErrEndpointNotReady, route.Status)
 }
 
-func (r *RouteEndpoint) createRouteService(c client.Client) error {
-	serviceSelector := r.Labels()
+func (r *RouteEndpoint) createRouteService(ctx context.Context, c client.Client) error {
+	serviceSelector := endpoint.SelectorLabels(r)
 
 	service := corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      r.NamespacedName().Name,
 			Namespace: r.NamespacedName().Namespace,
-			Labels:    r.Labels(),
+			Labels:    meta.WithOwnerLabel(endpoint.MergeLabels(r.Labels(), r.userLabels)),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -129,16 +248,10 @@ func (r *RouteEndpoint) createRouteService(c client.Client) error {
 			Type:     corev1.ServiceTypeClusterIP,
 		},
 	}
-	// TODO: consider patching an existing object if it already exists
-	err := c.Create(context.TODO(), &service, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	}
-
-	return nil
+	return meta.Apply(ctx, c, &service)
 }
 
-func (r *RouteEndpoint) createRoute(c client.Client) error {
+func (r *RouteEndpoint) createRoute(ctx context.Context, c client.Client) error {
 	termination := &routev1.TLSConfig{}
 	switch r.endpointType {
 	case EndpointTypeInsecureEdge:
@@ -147,18 +260,30 @@ func (r *RouteEndpoint) createRoute(c client.Client) error {
 			InsecureEdgeTerminationPolicy: "Allow",
 		}
 		r.port = int32(8080)
+	case EndpointTypeEdge:
+		termination = &routev1.TLSConfig{
+			Termination:                   routev1.TLSTerminationEdge,
+			InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyNone,
+		}
+		r.port = int32(8080)
 	case EndpointTypePassthrough:
 		termination = &routev1.TLSConfig{
 			Termination: routev1.TLSTerminationPassthrough,
 		}
 		r.port = int32(6443)
+	case EndpointTypeReencrypt:
+		termination = &routev1.TLSConfig{
+			Termination:              routev1.TLSTerminationReencrypt,
+			DestinationCACertificate: r.destinationCACertificate,
+		}
+		r.port = int32(6443)
 	}
 
 	route := routev1.Route{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      r.NamespacedName().Name,
 			Namespace: r.NamespacedName().Namespace,
-			Labels:    r.Labels(),
+			Labels:    meta.WithOwnerLabel(endpoint.MergeLabels(r.Labels(), r.userLabels)),
 		},
 		Spec: routev1.RouteSpec{
 			Subdomain: r.subdomain,
@@ -173,29 +298,20 @@ func (r *RouteEndpoint) createRoute(c client.Client) error {
 		},
 	}
 
-	// Ensure route prefix will not exceed 63 characters.
-	routePrefix := fmt.Sprintf("%s-%s", r.NamespacedName().Name, r.NamespacedName().Namespace)
-	if len(routePrefix) > 62 {
-		if r.subdomain == "" {
-			return fmt.Errorf("no subdomain specified and route hostname \"%s\" is more than 63 characters", routePrefix)
-		}
-
-		routePrefix = r.NamespacedName().Name + "-" + getMD5Hash(r.NamespacedName().Namespace)
-		if len(routePrefix) > 62 {
-			routePrefix = routePrefix[0:62]
-		}
+	routePrefix, err := r.routePrefix()
+	if err != nil {
+		return err
 	}
-
 	if r.subdomain != "" {
 		route.Spec.Host = routePrefix + "." + r.subdomain
 	}
 
-	err := c.Create(context.TODO(), &route, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
+	err = meta.Apply(ctx, c, &route)
+	if err != nil {
 		return err
 	}
 
-	err = c.Get(context.TODO(), types.NamespacedName{Name: r.NamespacedName().Name, Namespace: r.NamespacedName().Namespace}, &route)
+	err = c.Get(ctx, types.NamespacedName{Name: r.NamespacedName().Name, Namespace: r.NamespacedName().Namespace}, &route)
 	if err != nil {
 		return err
 	}
@@ -205,17 +321,48 @@ func (r *RouteEndpoint) createRoute(c client.Client) error {
 	return nil
 }
 
-func (r *RouteEndpoint) getRoute(c client.Client) (*routev1.Route, error) {
+// routePrefix returns the first label of the hostname of the Route
+func (r *RouteEndpoint) routePrefix() (string, error) {
+	// Ensure route prefix will not exceed 63 characters.
+	routePrefix := fmt.Sprintf("%s-%s", r.NamespacedName().Name, r.NamespacedName().Namespace)
+	if len(routePrefix) > 62 {
+		if r.subdomain == "" {
+			return "", fmt.Errorf("no subdomain specified and route hostname \"%s\" is more than 63 characters", routePrefix)
+		}
+
+		routePrefix = r.NamespacedName().Name + "-" + getMD5Hash(r.NamespacedName().Namespace)
+		if len(routePrefix) > 62 {
+			routePrefix = routePrefix[0:62]
+		}
+	}
+	return routePrefix, nil
+}
+
+// DNSNames returns the hostname the Route is created with when it has a subdomain, so that it is
+// known before the Route is admitted, see endpoint.DNSNamer. Without a subdomain the hostname is
+// assigned by the router and nil is returned until it is.
+func (r *RouteEndpoint) DNSNames() []string {
+	if r.hostname != "" {
+		return []string{r.hostname}
+	}
+	routePrefix, err := r.routePrefix()
+	if r.subdomain == "" || err != nil {
+		return nil
+	}
+	return []string{routePrefix + "." + r.subdomain}
+}
+
+func (r *RouteEndpoint) getRoute(ctx context.Context, c client.Client) (*routev1.Route, error) {
 	route := &routev1.Route{}
-	err := c.Get(context.TODO(), types.NamespacedName{Name: r.NamespacedName().Name, Namespace: r.NamespacedName().Namespace}, route)
+	err := c.Get(ctx, types.NamespacedName{Name: r.NamespacedName().Name, Namespace: r.NamespacedName().Namespace}, route)
 	if err != nil {
 		return nil, err
 	}
 	return route, err
 }
 
-func (r *RouteEndpoint) setFields(c client.Client) error {
-	route, err := r.getRoute(c)
+func (r *RouteEndpoint) setFields(ctx context.Context, c client.Client) error {
+	route, err := r.getRoute(ctx, c)
 	if err != nil {
 		return err
 	}
@@ -236,8 +383,14 @@ func (r *RouteEndpoint) setFields(c client.Client) error {
 	switch route.Spec.TLS.Termination {
 	case routev1.TLSTerminationEdge:
 		r.endpointType = EndpointTypeInsecureEdge
+		if route.Spec.TLS.InsecureEdgeTerminationPolicy == routev1.InsecureEdgeTerminationPolicyNone {
+			r.endpointType = EndpointTypeEdge
+		}
 	case routev1.TLSTerminationPassthrough:
 		r.endpointType = EndpointTypePassthrough
+	case routev1.TLSTerminationReencrypt:
+		r.endpointType = EndpointTypeReencrypt
+		r.destinationCACertificate = route.Spec.TLS.DestinationCACertificate
 	default:
 		return fmt.Errorf("route %s has unsupported spec.spec.tls.termination value", r.NamespacedName())
 	}
@@ -247,18 +400,18 @@ func (r *RouteEndpoint) setFields(c client.Client) error {
 
 // GetEndpointFromKubeObjects check if the required Route is created and healthy. It populates the fields
 // for the Endpoint needed for transfer and transport objects.
-func GetEndpointFromKubeObjects(c client.Client, obj types.NamespacedName) (endpoint.Endpoint, error) {
-	r := &RouteEndpoint{namespacedName: obj}
+func GetEndpointFromKubeObjects(ctx context.Context, c client.Client, obj types.NamespacedName, log logr.Logger) (endpoint.Endpoint, error) {
+	r := &RouteEndpoint{namespacedName: obj, log: endpoint.Logger(log, obj)}
 
-	healthy, err := r.IsHealthy(c)
+	healthy, err := r.IsHealthy(ctx, c)
 	if err != nil {
 		return nil, err
 	}
 	if !healthy {
-		return nil, fmt.Errorf("route %s not healthy", obj)
+		return nil, fmt.Errorf("%w: route %s not healthy", endpoint.ErrEndpointNotReady, obj)
 	}
 
-	err = r.setFields(c)
+	err = r.setFields(ctx, c)
 	if err != nil {
 		return nil, err
 	}
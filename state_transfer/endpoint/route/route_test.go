@@ -0,0 +1,120 @@
+package route
+
+import (
+	"context"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewEndpointWithTLS(t *testing.T) {
+	_, ca, _, err := transport.GenerateSSLCert()
+	if err != nil {
+		t.Fatalf("unable to generate certificates: %v", err)
+	}
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-route"}
+
+	if _, err := NewEndpointWithTLS(name, "EndpointTypeUnknown", nil, "test.domain", nil, TLSOptions{}); err == nil {
+		t.Errorf("unknown endpoint type should return an error")
+	}
+	if _, err := NewEndpointWithTLS(name, EndpointTypePassthrough, nil, "test.domain", nil, TLSOptions{DestinationCACertificate: ca.String()}); err == nil {
+		t.Errorf("destination CA certificate should only be allowed with reencrypt")
+	}
+	if _, err := NewEndpointWithTLS(name, EndpointTypeReencrypt, nil, "test.domain", nil, TLSOptions{DestinationCACertificate: "not a certificate"}); err == nil {
+		t.Errorf("invalid destination CA certificate should return an error")
+	}
+
+	e, err := NewEndpointWithTLS(name, EndpointTypeReencrypt, map[string]string{"app": "test"}, "test.domain", nil, TLSOptions{DestinationCACertificate: ca.String()})
+	if err != nil {
+		t.Fatalf("NewEndpointWithTLS() unexpected error %v", err)
+	}
+	s := runtime.NewScheme()
+	if err := e.(*RouteEndpoint).AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	route := &routev1.Route{}
+	if err := c.Get(context.TODO(), name, route); err != nil {
+		t.Fatalf("unable to get route: %v", err)
+	}
+	if route.Spec.TLS.Termination != routev1.TLSTerminationReencrypt || route.Spec.TLS.DestinationCACertificate != ca.String() {
+		t.Errorf("route does not use reencrypt termination with the destination CA: %v", route.Spec.TLS)
+	}
+
+	if err := e.(*RouteEndpoint).ValidateProtocol(endpoint.ProtocolTLS); err == nil {
+		t.Errorf("TLS tunnels should require passthrough termination")
+	}
+	if err := e.(*RouteEndpoint).ValidateProtocol(endpoint.ProtocolTCP); err == nil {
+		t.Errorf("reencrypt termination should only accept TLS connections")
+	}
+	passthrough := NewEndpoint(name, EndpointTypePassthrough, nil, "test.domain", nil)
+	if err := passthrough.(*RouteEndpoint).ValidateProtocol(endpoint.ProtocolTLS); err != nil {
+		t.Errorf("TLS tunnels should be accepted with passthrough termination: %v", err)
+	}
+}
+
+func TestValidateProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		eType    RouteEndpointType
+		protocol endpoint.Protocol
+		wantErr  bool
+	}{
+		{name: "tls through passthrough", eType: EndpointTypePassthrough, protocol: endpoint.ProtocolTLS},
+		{name: "tcp through passthrough", eType: EndpointTypePassthrough, protocol: endpoint.ProtocolTCP, wantErr: true},
+		{name: "ssh through passthrough", eType: EndpointTypePassthrough, protocol: endpoint.ProtocolSSH, wantErr: true},
+		{name: "tls through edge", eType: EndpointTypeEdge, protocol: endpoint.ProtocolTLS, wantErr: true},
+		{name: "http through edge", eType: EndpointTypeEdge, protocol: endpoint.ProtocolHTTP, wantErr: true},
+		{name: "http through insecure edge", eType: EndpointTypeInsecureEdge, protocol: endpoint.ProtocolHTTP},
+		{name: "tcp through insecure edge", eType: EndpointTypeInsecureEdge, protocol: endpoint.ProtocolTCP, wantErr: true},
+		{name: "ssh through insecure edge", eType: EndpointTypeInsecureEdge, protocol: endpoint.ProtocolSSH, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEndpointWithTLS(types.NamespacedName{Namespace: "test-namespace", Name: "test-route"}, tt.eType, nil, "test.domain", nil, TLSOptions{})
+			if err != nil {
+				t.Fatalf("NewEndpointWithTLS() unexpected error %v", err)
+			}
+			if err := endpoint.ValidateProtocol(e, tt.protocol); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateProtocol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddressedEndpoint(t *testing.T) {
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-route"}
+	e, err := NewEndpointWithTLS(name, EndpointTypeEdge, nil, "test.domain", nil, TLSOptions{})
+	if err != nil {
+		t.Fatalf("NewEndpointWithTLS() unexpected error %v", err)
+	}
+	addressed := endpoint.NewAddressedEndpoint(e, "advertised.example.com", 443, "")
+
+	if endpoint.Unwrap(addressed) != e {
+		t.Errorf("Unwrap() should return the route endpoint")
+	}
+	if err := endpoint.SetSelectorLabels(addressed, map[string]string{"app": "server"}); err != nil {
+		t.Errorf("SetSelectorLabels() unexpected error %v", err)
+	}
+	if labels := endpoint.SelectorLabels(addressed); labels["app"] != "server" {
+		t.Errorf("SelectorLabels() = %v, want the labels set on the wrapped endpoint", labels)
+	}
+	s := runtime.NewScheme()
+	if err := endpoint.AddToScheme(addressed, s); err != nil || !s.IsGroupRegistered(routev1.GroupName) {
+		t.Errorf("AddToScheme() should register the route types of the wrapped endpoint, err %v", err)
+	}
+	if types := endpoint.WatchedTypes(addressed); len(types) != 2 {
+		t.Errorf("WatchedTypes() = %v, want the types watched by the wrapped endpoint", types)
+	}
+	if err := endpoint.ValidateProtocol(addressed, endpoint.ProtocolTLS); err == nil {
+		t.Errorf("TLS tunnels should require passthrough termination of the wrapped endpoint")
+	}
+}
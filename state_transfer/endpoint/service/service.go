@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/go-logr/logr"
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -18,12 +19,18 @@ type ServiceEndpoint struct {
 	hostname       string
 	svcType        corev1.ServiceType
 
-	labels      map[string]string
-	backendPort int32
-	exposedPort int32
+	labels                map[string]string
+	userLabels            map[string]string
+	selectorLabels        map[string]string
+	backendPort           int32
+	exposedPort           int32
+	externalTrafficPolicy corev1.ServiceExternalTrafficPolicyType
+	clusterLocal          bool
+
+	log logr.Logger
 }
 
-func NewEndpoint(namespacedName types.NamespacedName, labels map[string]string, hostname string, svcType corev1.ServiceType) endpoint.Endpoint {
+func NewEndpoint(namespacedName types.NamespacedName, labels map[string]string, hostname string, svcType corev1.ServiceType, log logr.Logger) endpoint.Endpoint {
 	return &ServiceEndpoint{
 		namespacedName: namespacedName,
 		labels:         labels,
@@ -31,11 +38,29 @@ func NewEndpoint(namespacedName types.NamespacedName, labels map[string]string,
 		hostname:       hostname,
 		backendPort:    int32(6443),
 		exposedPort:    int32(6443),
+		log:            endpoint.Logger(log, namespacedName),
 	}
 }
 
-func (s *ServiceEndpoint) Create(c client.Client) error {
-	err := s.createService(c)
+// NewClusterIPEndpoint returns an endpoint exposing the transfer server with a ClusterIP Service,
+// for transfers between namespaces of a single cluster which need neither a Route nor a load
+// balancer. Clients connect to the DNS name of the Service.
+func NewClusterIPEndpoint(namespacedName types.NamespacedName, labels map[string]string, log logr.Logger) endpoint.Endpoint {
+	return &ServiceEndpoint{
+		namespacedName: namespacedName,
+		labels:         labels,
+		svcType:        corev1.ServiceTypeClusterIP,
+		hostname:       endpoint.ClusterLocalHostname(namespacedName),
+		backendPort:    int32(6443),
+		exposedPort:    int32(6443),
+		clusterLocal:   true,
+		log:            endpoint.Logger(log, namespacedName),
+	}
+}
+
+func (s *ServiceEndpoint) Create(ctx context.Context, c client.Client) error {
+	c = meta.NewLoggingClient(c, s.log)
+	err := s.createService(ctx, c)
 	if err != nil {
 		return err
 	}
@@ -59,13 +84,62 @@ func (s *ServiceEndpoint) Labels() map[string]string {
 	return s.labels
 }
 
+// Copy returns a copy of the endpoint, see endpoint.Copier
+func (s *ServiceEndpoint) Copy() endpoint.Endpoint {
+	copied := *s
+	return &copied
+}
+
+// SetUserLabels sets additional labels on the Service. The Service is labelled with the user
+// labels, the managed Labels() and a "hostname" label, in increasing order of precedence.
+func (s *ServiceEndpoint) SetUserLabels(labels map[string]string) {
+	s.userLabels = labels
+}
+
+// SetSelectorLabels sets the labels the Service selects the server pods with, in place of Labels()
+func (s *ServiceEndpoint) SetSelectorLabels(labels map[string]string) {
+	s.selectorLabels = labels
+}
+
+// SelectorLabels returns the labels set with SetSelectorLabels
+func (s *ServiceEndpoint) SelectorLabels() map[string]string {
+	return s.selectorLabels
+}
+
+// SetExternalTrafficPolicy sets the external traffic policy of the Service, only NodePort and
+// LoadBalancer Services have one. Services are created with the Cluster policy when not set.
+func (s *ServiceEndpoint) SetExternalTrafficPolicy(policy corev1.ServiceExternalTrafficPolicyType) error {
+	if s.svcType != corev1.ServiceTypeNodePort && s.svcType != corev1.ServiceTypeLoadBalancer {
+		return fmt.Errorf("external traffic policy is not supported by %s services", s.svcType)
+	}
+	switch policy {
+	case corev1.ServiceExternalTrafficPolicyTypeCluster, corev1.ServiceExternalTrafficPolicyTypeLocal:
+		s.externalTrafficPolicy = policy
+		return nil
+	}
+	return fmt.Errorf("unsupported external traffic policy %q, must be %s or %s", policy,
+		corev1.ServiceExternalTrafficPolicyTypeCluster, corev1.ServiceExternalTrafficPolicyTypeLocal)
+}
+
+// ClusterLocal returns whether the endpoint was created with NewClusterIPEndpoint, or read from
+// the Service of such an endpoint
+func (s *ServiceEndpoint) ClusterLocal() bool {
+	return s.clusterLocal
+}
+
 func (s *ServiceEndpoint) ExposedPort() int32 {
 	return s.exposedPort
 }
 
-func (s *ServiceEndpoint) IsHealthy(c client.Client) (bool, error) {
+func (s *ServiceEndpoint) IsHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := s.isHealthy(ctx, c)
+	meta.LogHealthCheck(s.log, healthy, err)
+	return healthy, err
+}
+
+func (s *ServiceEndpoint) isHealthy(ctx context.Context, c client.Client) (bool, error) {
 	svc := corev1.Service{}
-	err := c.Get(context.TODO(), types.NamespacedName{
+	err := c.Get(ctx, types.NamespacedName{
 		Name:      s.NamespacedName().Name,
 		Namespace: s.NamespacedName().Namespace}, &svc)
 	if err != nil {
@@ -94,6 +168,7 @@ func (s *ServiceEndpoint) IsHealthy(c client.Client) (bool, error) {
 		if svc.Labels["hostname"] != "" {
 			s.hostname = svc.Labels["hostname"]
 		}
+		s.clusterLocal = s.hostname == endpoint.ClusterLocalHostname(s.NamespacedName())
 		return true, nil
 	case corev1.ServiceTypeNodePort:
 		if svc.Spec.ClusterIP != "" {
@@ -113,15 +188,15 @@ func (s *ServiceEndpoint) IsHealthy(c client.Client) (bool, error) {
 		return false, fmt.Errorf("unsupported service type %s", s.svcType)
 	}
 
-	return false, fmt.Errorf("service status is not in valid state: %s", svc.Status.String())
+	return false, fmt.Errorf("%w: service status is not in valid state: %s", endpoint.ErrEndpointNotReady, svc.Status.String())
 }
 
-func (s *ServiceEndpoint) createService(c client.Client) error {
+func (s *ServiceEndpoint) createService(ctx context.Context, c client.Client) error {
 	service := corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      s.NamespacedName().Name,
 			Namespace: s.NamespacedName().Namespace,
-			Labels:    s.getSvcLabels(),
+			Labels:    meta.WithOwnerLabel(s.getSvcLabels()),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -132,40 +207,38 @@ func (s *ServiceEndpoint) createService(c client.Client) error {
 					TargetPort: intstr.IntOrString{Type: intstr.Int, IntVal: s.Port()},
 				},
 			},
-			Selector: s.Labels(),
+			Selector: endpoint.SelectorLabels(s),
 			Type:     s.svcType,
 		},
 	}
-
-	err := c.Create(context.TODO(), &service, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
+	if s.svcType == corev1.ServiceTypeNodePort || s.svcType == corev1.ServiceTypeLoadBalancer {
+		service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		if s.externalTrafficPolicy != "" {
+			service.Spec.ExternalTrafficPolicy = s.externalTrafficPolicy
+		}
 	}
 
-	return nil
+	return meta.Apply(ctx, c, &service)
 
 }
 
 func (s *ServiceEndpoint) getSvcLabels() map[string]string {
-	labels := make(map[string]string)
-	for key, val := range s.labels {
-		labels[key] = val
-	}
+	labels := endpoint.MergeLabels(s.labels, s.userLabels)
 	labels["hostname"] = s.hostname
 	return labels
 }
 
 // GetEndpointFromKubeObjects check if the required svc is created and healthy. It populates the fields
 // for the Endpoint needed for transfer and transport objects.
-func GetEndpointFromKubeObjects(c client.Client, obj types.NamespacedName) (endpoint.Endpoint, error) {
-	r := &ServiceEndpoint{namespacedName: obj}
+func GetEndpointFromKubeObjects(ctx context.Context, c client.Client, obj types.NamespacedName, log logr.Logger) (endpoint.Endpoint, error) {
+	r := &ServiceEndpoint{namespacedName: obj, log: endpoint.Logger(log, obj)}
 
-	healthy, err := r.IsHealthy(c)
+	healthy, err := r.IsHealthy(ctx, c)
 	if err != nil {
 		return nil, err
 	}
 	if !healthy {
-		return nil, fmt.Errorf("endpoint %s not healthy", obj)
+		return nil, fmt.Errorf("%w: endpoint %s not healthy", endpoint.ErrEndpointNotReady, obj)
 	}
 
 	return r, nil
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreateWithUserLabels(t *testing.T) {
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-service"}
+	managed := map[string]string{"app": "crane2"}
+	e := NewEndpoint(name, managed, "test.host", corev1.ServiceTypeClusterIP, nil)
+	err := endpoint.SetUserLabels(e, map[string]string{"team": "storage", "app": "overridden"})
+	if err != nil {
+		t.Fatalf("unable to set user labels: %v", err)
+	}
+
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	svc := &corev1.Service{}
+	if err := c.Get(context.TODO(), name, svc); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	want := map[string]string{"app": "crane2", "team": "storage", "hostname": "test.host", meta.OwnerLabel: meta.OwnerLabelValue}
+	if len(svc.Labels) != len(want) {
+		t.Errorf("service labels %v, want %v", svc.Labels, want)
+	}
+	for k, v := range want {
+		if svc.Labels[k] != v {
+			t.Errorf("service label %s = %q, want %q", k, svc.Labels[k], v)
+		}
+	}
+	if len(svc.Spec.Selector) != 1 || svc.Spec.Selector["app"] != "crane2" {
+		t.Errorf("service selector should only use the managed labels: %v", svc.Spec.Selector)
+	}
+}
+
+func TestExternalTrafficPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		svcType corev1.ServiceType
+		policy  corev1.ServiceExternalTrafficPolicyType
+		want    corev1.ServiceExternalTrafficPolicyType
+		wantErr bool
+	}{
+		{
+			name:    "node port defaults to cluster",
+			svcType: corev1.ServiceTypeNodePort,
+			want:    corev1.ServiceExternalTrafficPolicyTypeCluster,
+		},
+		{
+			name:    "load balancer with local policy",
+			svcType: corev1.ServiceTypeLoadBalancer,
+			policy:  corev1.ServiceExternalTrafficPolicyTypeLocal,
+			want:    corev1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+		{
+			name:    "cluster ip has no external traffic",
+			svcType: corev1.ServiceTypeClusterIP,
+		},
+		{
+			name:    "cluster ip rejects a policy",
+			svcType: corev1.ServiceTypeClusterIP,
+			policy:  corev1.ServiceExternalTrafficPolicyTypeLocal,
+			wantErr: true,
+		},
+		{
+			name:    "unknown policy",
+			svcType: corev1.ServiceTypeNodePort,
+			policy:  "Nearest",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := types.NamespacedName{Namespace: "test-namespace", Name: "test-service"}
+			e := NewEndpoint(name, map[string]string{"app": "crane2"}, "test.host", tt.svcType, nil)
+			if tt.policy != "" {
+				err := endpoint.SetExternalTrafficPolicy(e, tt.policy)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("SetExternalTrafficPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+			}
+			c := fake.NewClientBuilder().Build()
+			if err := e.Create(context.TODO(), c); err != nil {
+				t.Fatalf("unable to create endpoint: %v", err)
+			}
+			svc := &corev1.Service{}
+			if err := c.Get(context.TODO(), name, svc); err != nil {
+				t.Fatalf("unable to get service: %v", err)
+			}
+			if svc.Spec.ExternalTrafficPolicy != tt.want {
+				t.Errorf("external traffic policy %q, want %q", svc.Spec.ExternalTrafficPolicy, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateWithSelectorLabels(t *testing.T) {
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-service"}
+	e := NewEndpoint(name, map[string]string{"app": "crane2"}, "test.host", corev1.ServiceTypeClusterIP, nil)
+	if got := endpoint.SelectorLabels(e); len(got) != 1 || got["app"] != "crane2" {
+		t.Errorf("selector labels should default to the managed labels, got %v", got)
+	}
+	if err := endpoint.SetSelectorLabels(e, map[string]string{}); err == nil {
+		t.Errorf("empty selector labels should be rejected")
+	}
+	if err := endpoint.SetSelectorLabels(e, map[string]string{"crane.konveyor.io/server": "bad value!"}); err == nil {
+		t.Errorf("invalid selector labels should be rejected")
+	}
+	selector := map[string]string{"crane.konveyor.io/server": "test-service"}
+	if err := endpoint.SetSelectorLabels(e, selector); err != nil {
+		t.Fatalf("unable to set selector labels: %v", err)
+	}
+
+	c := fake.NewClientBuilder().Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	svc := &corev1.Service{}
+	if err := c.Get(context.TODO(), name, svc); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	if len(svc.Spec.Selector) != 1 || svc.Spec.Selector["crane.konveyor.io/server"] != "test-service" {
+		t.Errorf("service should select the selector labels, got %v", svc.Spec.Selector)
+	}
+	if svc.Labels["app"] != "crane2" || svc.Labels["crane.konveyor.io/server"] != "" {
+		t.Errorf("service should be labelled with the managed labels only, got %v", svc.Labels)
+	}
+}
+
+func TestClusterIPEndpoint(t *testing.T) {
+	name := types.NamespacedName{Namespace: "test-namespace", Name: "test-service"}
+	e := NewClusterIPEndpoint(name, map[string]string{"app": "crane2"}, nil)
+	if !endpoint.IsClusterLocal(e) || e.Hostname() != "test-service.test-namespace.svc" {
+		t.Fatalf("unexpected cluster ip endpoint %s, cluster local %t", e.Hostname(), endpoint.IsClusterLocal(e))
+	}
+	if endpoint.IsClusterLocal(NewEndpoint(name, nil, "test.host", corev1.ServiceTypeClusterIP, nil)) {
+		t.Errorf("services with a hostname should not be cluster local")
+	}
+
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).Build()
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	svc := &corev1.Service{}
+	if err := c.Get(context.TODO(), name, svc); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	if svc.Spec.Type != corev1.ServiceTypeClusterIP {
+		t.Errorf("service type %s, want %s", svc.Spec.Type, corev1.ServiceTypeClusterIP)
+	}
+	svc.Spec.ClusterIP = "10.0.0.1"
+	if err := c.Update(context.TODO(), svc); err != nil {
+		t.Fatalf("unable to update service: %v", err)
+	}
+
+	found, err := GetEndpointFromKubeObjects(context.TODO(), c, name, nil)
+	if err != nil {
+		t.Fatalf("GetEndpointFromKubeObjects() unexpected error %v", err)
+	}
+	if !endpoint.IsClusterLocal(found) || found.Hostname() != e.Hostname() {
+		t.Errorf("endpoint read from the service should be cluster local with hostname %s, got %s", e.Hostname(), found.Hostname())
+	}
+}
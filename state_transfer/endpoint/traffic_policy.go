@@ -0,0 +1,26 @@
+package endpoint
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ExternalTrafficPolicySetter knows how to set the external traffic policy of the Service of an Endpoint
+type ExternalTrafficPolicySetter interface {
+	// SetExternalTrafficPolicy sets the external traffic policy of the Service created by the endpoint
+	SetExternalTrafficPolicy(policy corev1.ServiceExternalTrafficPolicyType) error
+}
+
+// SetExternalTrafficPolicy sets the externalTrafficPolicy of the NodePort or LoadBalancer Service
+// created for the Endpoint, it must be called before the endpoint is created. With Local, traffic
+// is only routed to the transfer pod through the node it runs on, which preserves the client source
+// IP and avoids an extra hop. The policy defaults to Cluster.
+func SetExternalTrafficPolicy(e Endpoint, policy corev1.ServiceExternalTrafficPolicyType) error {
+	for _, w := range chain(e) {
+		if setter, ok := w.(ExternalTrafficPolicySetter); ok {
+			return setter.SetExternalTrafficPolicy(policy)
+		}
+	}
+	return fmt.Errorf("endpoint %s does not support an external traffic policy", e.NamespacedName())
+}
@@ -73,8 +73,8 @@ func TestExample_basicTransfer(t *testing.T) {
 		types.NamespacedName{
 			Namespace: pvc.Namespace,
 			Name:      pvc.Name,
-		}, route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain")
-	e, err := endpoint.Create(r, destClient)
+		}, route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	e, err := endpoint.Create(context.TODO(), r, destClient)
 	if err != nil {
 		t.Fatalf("unable to create route endpoint: %v", err)
 	}
@@ -102,7 +102,7 @@ func TestExample_basicTransfer(t *testing.T) {
 		t.Fatalf("unable to update route status: %v", err)
 	}
 
-	ready, err := e.IsHealthy(destClient)
+	ready, err := e.IsHealthy(context.TODO(), destClient)
 	if err != nil {
 		t.Fatalf("unable to check route health: %v", err)
 	}
@@ -116,24 +116,24 @@ func TestExample_basicTransfer(t *testing.T) {
 			Name: pvc.Name, Namespace: pvc.Namespace},
 		types.NamespacedName{
 			Name: destPVC.Name, Namespace: destPVC.Namespace},
-	), &transport.Options{})
-	_, err = transport.CreateServer(s, destClient, "fs", e)
+	), nil, &transport.Options{})
+	_, err = transport.CreateServer(context.TODO(), s, destClient, "fs", e)
 	if err != nil {
 		t.Fatalf("error creating stunnel server: %v", err)
 	}
 
-	s, err = transport.CreateClient(s, srcClient, "fs", e)
+	s, err = transport.CreateClient(context.TODO(), s, srcClient, "fs", e)
 	if err != nil {
 		t.Fatalf("error creating stunnel client: %v", err)
 	}
 
 	// Create Rclone Transfer Pod
-	tr, err := rclone.NewTransfer(s, r, srcClient, destClient, pvcList)
+	tr, err := rclone.NewTransfer(s, r, srcClient, destClient, pvcList, nil)
 	if err != nil {
 		t.Fatalf("errror creating rclone transfer: %v", err)
 	}
 
-	err = transfer.CreateServer(tr)
+	err = transfer.CreateServer(context.TODO(), tr)
 	if err != nil {
 		t.Fatalf("error creating rclone server: %v", err)
 	}
@@ -154,7 +154,7 @@ func TestExample_basicTransfer(t *testing.T) {
 	}
 
 	// Create Rclone Client Pod
-	err = transfer.CreateClient(tr)
+	err = transfer.CreateClient(context.TODO(), tr)
 	if err != nil {
 		log.Fatal(err, "error creating rclone client")
 	}
@@ -191,7 +191,7 @@ func Example_getFromCreatedObjects() {
 		log.Fatal(err, "invalid pvc list")
 	}
 
-	e, err := route.GetEndpointFromKubeObjects(destClient, types.NamespacedName{Namespace: srcNamespace, Name: srcPVC})
+	e, err := route.GetEndpointFromKubeObjects(context.TODO(), destClient, types.NamespacedName{Namespace: srcNamespace, Name: srcPVC}, nil)
 	if err != nil {
 		log.Fatal(err, "error getting route endpoint")
 	}
@@ -200,7 +200,7 @@ func Example_getFromCreatedObjects() {
 		types.NamespacedName{Namespace: srcNamespace, Name: srcPVC},
 		types.NamespacedName{Namespace: srcNamespace, Name: srcPVC},
 	)
-	s, err := stunnel.GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, &transport.Options{})
+	s, err := stunnel.GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, &transport.Options{})
 	if err != nil {
 		log.Fatal(err, "error getting stunnel transport")
 	}
@@ -213,18 +213,18 @@ func Example_getFromCreatedObjects() {
 	}
 
 	// Create Rclone Transfer Pod
-	t, err := rclone.NewTransfer(s, e, srcClient, destClient, pvcList)
+	t, err := rclone.NewTransfer(s, e, srcClient, destClient, pvcList, nil)
 	if err != nil {
 		log.Fatal(err, "errror creating rclone transfer")
 	}
-	err = transfer.CreateServer(t)
+	err = transfer.CreateServer(context.TODO(), t)
 	if err != nil {
 		log.Fatal(err, "error creating rclone server")
 	}
 
 	// check if the server is healthy before creating the client
 	_ = wait.PollUntil(time.Second*5, func() (done bool, err error) {
-		isHealthy, err := t.IsServerHealthy(destClient)
+		isHealthy, err := t.IsServerHealthy(context.TODO(), destClient)
 		if err != nil {
 			log.Println(err, "unable to check server health, retrying...")
 			return false, nil
@@ -233,7 +233,7 @@ func Example_getFromCreatedObjects() {
 	}, make(<-chan struct{}))
 
 	// Create Rclone Client Pod
-	err = transfer.CreateClient(t)
+	err = transfer.CreateClient(context.TODO(), t)
 	if err != nil {
 		log.Fatal(err, "error creating rclone client")
 	}
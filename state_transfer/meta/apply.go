@@ -0,0 +1,187 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// FieldManager is the field manager of the resources applied by the library
+	FieldManager = "crane-lib"
+)
+
+// ApplyOptions configures how the resources of the library are applied, see NewApplyClient
+type ApplyOptions struct {
+	// FieldManager is the field manager of the applied resources, defaults to FieldManager
+	FieldManager string
+	// Force takes the ownership of the fields set by the library from other field managers
+	// instead of failing with a conflict
+	Force bool
+}
+
+type applyClient struct {
+	client.Client
+	options ApplyOptions
+}
+
+// NewApplyClient returns a client applying the resources reconciled by the library with the given
+// options. Pass it to transfers, transports and endpoints in place of their client, it can be
+// combined with NewMetadataClient and NewLoggingClient.
+func NewApplyClient(c client.Client, options ApplyOptions) client.Client {
+	return &applyClient{Client: c, options: options}
+}
+
+func (a *applyClient) unwrap() client.Client {
+	return a.Client
+}
+
+// unwrapper is implemented by the clients of this package wrapping another client
+type unwrapper interface {
+	unwrap() client.Client
+}
+
+// applyOptions returns the options of the first apply client wrapped by c
+func applyOptions(c client.Client) ApplyOptions {
+	for c != nil {
+		if a, ok := c.(*applyClient); ok {
+			return a.options
+		}
+		u, ok := c.(unwrapper)
+		if !ok {
+			break
+		}
+		c = u.unwrap()
+	}
+	return ApplyOptions{}
+}
+
+// Apply creates or updates obj with a server-side apply patch, so that only the fields set in obj
+// are owned by the library and changes made concurrently to other fields are kept. obj is updated
+// with the applied resource. The field manager and whether conflicts are forced are taken from
+// the options of NewApplyClient. Clients which do not support server-side apply, such as API
+// servers before 1.16 or the controller-runtime fake client, fall back to creating obj, or to
+// merging it into the existing resource. Any other error of the apply patch is returned.
+func Apply(ctx context.Context, c client.Client, obj client.Object) error {
+	options := applyOptions(c)
+	manager := options.FieldManager
+	if manager == "" {
+		manager = FieldManager
+	}
+	// apply patches must carry the apiVersion and kind, typed objects usually do not set them
+	if obj.GetObjectKind().GroupVersionKind().Empty() && c.Scheme() != nil {
+		gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+		if err != nil {
+			return err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	desired := obj.DeepCopyObject().(client.Object)
+
+	opts := []client.PatchOption{client.FieldOwner(manager)}
+	if options.Force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	err := c.Patch(ctx, obj, client.Apply, opts...)
+	if !applyUnsupported(c, err) {
+		return err
+	}
+
+	// the failed create may set fields of the object, such as its resourceVersion
+	created := desired.DeepCopyObject().(client.Object)
+	err = c.Create(ctx, created, client.FieldOwner(manager))
+	if err == nil {
+		setObject(obj, created)
+		return nil
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	if err := c.Patch(ctx, desired, mergeObject{}, client.FieldOwner(manager)); err != nil {
+		return err
+	}
+	setObject(obj, desired)
+	return nil
+}
+
+// setObject sets obj to the value of from, both must point to objects of the same type
+func setObject(obj, from client.Object) {
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(from).Elem())
+}
+
+// fakeClientPackage is the package of the controller-runtime fake client
+const fakeClientPackage = "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+// applyUnsupported returns whether err means that the client c does not support apply patches.
+// API servers without server-side apply reject the patch type as an unsupported media type. The
+// fake client does not handle it either and fails with a NotFound error for missing resources, or
+// with an error which is not an API status for existing ones. Other errors are left to the caller.
+func applyUnsupported(c client.Client, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case k8serrors.IsUnsupportedMediaType(err):
+		return true
+	case !isFakeClient(c):
+		return false
+	case k8serrors.IsNotFound(err):
+		return true
+	}
+	_, isStatus := err.(k8serrors.APIStatus)
+	return !isStatus
+}
+
+// isFakeClient returns whether c is the controller-runtime fake client, or wraps it with the
+// clients of this package or embeds it
+func isFakeClient(c client.Client) bool {
+	for c != nil {
+		t := reflect.TypeOf(c)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.PkgPath() == fakeClientPackage {
+			return true
+		}
+		if u, ok := c.(unwrapper); ok {
+			c = u.unwrap()
+			continue
+		}
+		c = embeddedClient(c)
+	}
+	return false
+}
+
+// embeddedClient returns the client embedded in the struct c points to, nil if there is none
+func embeddedClient(c client.Client) client.Client {
+	v := reflect.ValueOf(c)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field, ok := v.Type().FieldByName("Client")
+	if !ok || !field.Anonymous || len(field.Index) != 1 {
+		return nil
+	}
+	embedded, _ := v.Field(field.Index[0]).Interface().(client.Client)
+	return embedded
+}
+
+// mergeObject is a JSON merge patch setting every field of the patched object
+type mergeObject struct{}
+
+func (mergeObject) Type() types.PatchType {
+	return types.MergePatchType
+}
+
+func (mergeObject) Data(obj client.Object) ([]byte, error) {
+	return json.Marshal(obj)
+}
@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// applyRecordingClient records the options of the apply patches sent through it
+type applyRecordingClient struct {
+	client.Client
+	options []client.PatchOptions
+}
+
+func (a *applyRecordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() == types.ApplyPatchType {
+		options := client.PatchOptions{}
+		options.ApplyOptions(opts)
+		a.options = append(a.options, options)
+	}
+	return a.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestApply(t *testing.T) {
+	recorder := &applyRecordingClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+	c := NewMetadataClient(NewApplyClient(recorder, ApplyOptions{Force: true}), Metadata{Labels: map[string]string{"team": "storage"}})
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "config"},
+		Data:       map[string]string{"key": "value"},
+	}
+	if err := Apply(context.TODO(), c, cm); err != nil {
+		t.Fatalf("Apply() unexpected error %v", err)
+	}
+	if cm.ResourceVersion == "" {
+		t.Errorf("Apply() should update the object with the created resource")
+	}
+
+	// fields set concurrently by other managers are kept
+	existing := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(cm), existing); err != nil {
+		t.Fatalf("unable to get config map: %v", err)
+	}
+	existing.Data["other"] = "kept"
+	if err := c.Update(context.TODO(), existing); err != nil {
+		t.Fatalf("unable to update config map: %v", err)
+	}
+	cm = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "config"},
+		Data:       map[string]string{"key": "updated"},
+	}
+	if err := Apply(context.TODO(), c, cm); err != nil {
+		t.Fatalf("Apply() unexpected error %v", err)
+	}
+	if cm.Data["key"] != "updated" || cm.Data["other"] != "kept" || cm.Labels["team"] != "storage" {
+		t.Errorf("Apply() = %v %v, want the updated key, the concurrent key and the caller labels", cm.Data, cm.Labels)
+	}
+
+	if len(recorder.options) != 2 {
+		t.Fatalf("expected 2 apply patches, got %d", len(recorder.options))
+	}
+	for _, options := range recorder.options {
+		if options.FieldManager != FieldManager || options.Force == nil || !*options.Force {
+			t.Errorf("apply patch sent with field manager %q and force %v, want %q and true", options.FieldManager, options.Force, FieldManager)
+		}
+	}
+}
+
+// failingClient fails every patch sent through it, other calls panic
+type failingClient struct {
+	client.Client
+	err error
+}
+
+func (f *failingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return f.err
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "connection error", err: errors.New("connection refused")},
+		{name: "not found", err: k8serrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "dest")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "config"}}
+			cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			// the failing client panics if Apply falls back to creating the resource
+			if err := Apply(context.TODO(), &failingClient{err: tt.err}, cm); err != tt.err {
+				t.Errorf("Apply() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package meta
+
+import (
+	"context"
+	"time"
+)
+
+// cleanupTimeout bounds the requests made with the contexts returned by CleanupContext once the
+// context of the caller is done
+const cleanupTimeout = 30 * time.Second
+
+// CleanupContext returns the context to delete the resources created with ctx: ctx itself while it
+// is active, otherwise a context with a short timeout, so that temporary resources are cleaned up
+// even when the caller was cancelled or timed out
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Err() == nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(context.Background(), cleanupTimeout)
+}
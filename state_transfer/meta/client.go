@@ -0,0 +1,106 @@
+package meta
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Metadata is the metadata added to every resource created or updated through a client returned
+// by NewMetadataClient
+type Metadata struct {
+	// Labels are merged into the labels of the resources, the labels set by the library take
+	// precedence as selectors depend on them
+	Labels map[string]string
+	// Annotations are merged into the annotations of the resources, the annotations set by the
+	// library take precedence
+	Annotations map[string]string
+	// OwnerReferences are added to every resource, their owners must be cluster scoped or in the
+	// namespace of the resources
+	OwnerReferences []metav1.OwnerReference
+	// Owner is set as an owner of the resources in its namespace so that they are garbage collected
+	// with it, resources in other namespaces are not owned by it. A cluster scoped Owner owns every
+	// resource. Its kind must be registered in the scheme of the client.
+	Owner client.Object
+}
+
+type metadataClient struct {
+	client.Client
+	metadata Metadata
+}
+
+// NewMetadataClient returns a client adding the given metadata to the resources it creates,
+// updates and patches. Pass it to transfers, transports and endpoints in place of their client so that every
+// ConfigMap, Secret, Pod, Service, Route and other resource they create carries the labels and
+// annotations of the caller, for cost attribution, and is owned by the caller, for garbage
+// collection.
+func NewMetadataClient(c client.Client, metadata Metadata) client.Client {
+	return &metadataClient{Client: c, metadata: metadata}
+}
+
+func (m *metadataClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := m.applyMetadata(obj); err != nil {
+		return err
+	}
+	return m.Client.Create(ctx, obj, opts...)
+}
+
+func (m *metadataClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := m.applyMetadata(obj); err != nil {
+		return err
+	}
+	return m.Client.Update(ctx, obj, opts...)
+}
+
+func (m *metadataClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := m.applyMetadata(obj); err != nil {
+		return err
+	}
+	return m.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (m *metadataClient) unwrap() client.Client {
+	return m.Client
+}
+
+func (m *metadataClient) applyMetadata(obj client.Object) error {
+	obj.SetLabels(mergeMaps(obj.GetLabels(), m.metadata.Labels))
+	obj.SetAnnotations(mergeMaps(obj.GetAnnotations(), m.metadata.Annotations))
+	refs := obj.GetOwnerReferences()
+	for _, ref := range m.metadata.OwnerReferences {
+		found := false
+		for _, existing := range refs {
+			if existing.UID == ref.UID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	owner := m.metadata.Owner
+	if owner == nil || (owner.GetNamespace() != "" && owner.GetNamespace() != obj.GetNamespace()) {
+		return nil
+	}
+	return controllerutil.SetOwnerReference(owner, obj, m.Scheme())
+}
+
+// mergeMaps returns the union of both maps, the values of managed take precedence, nil when both
+// are empty
+func mergeMaps(managed, user map[string]string) map[string]string {
+	if len(managed) == 0 && len(user) == 0 {
+		return managed
+	}
+	merged := make(map[string]string, len(managed)+len(user))
+	for key, val := range user {
+		merged[key] = val
+	}
+	for key, val := range managed {
+		merged[key] = val
+	}
+	return merged
+}
@@ -0,0 +1,68 @@
+package meta
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMetadataClient(t *testing.T) {
+	owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "migration", UID: types.UID("owner-uid")}}
+	external := metav1.OwnerReference{APIVersion: "v1", Kind: "Namespace", Name: "dest", UID: types.UID("ns-uid")}
+	c := NewMetadataClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), Metadata{
+		Labels:          map[string]string{"cost-center": "migrations", "app": "user"},
+		Annotations:     map[string]string{"team": "storage"},
+		OwnerReferences: []metav1.OwnerReference{external},
+		Owner:           owner,
+	})
+
+	for _, ns := range []string{"dest", "source"} {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      "secret",
+			Labels:    map[string]string{"app": "crane2"},
+		}}
+		if err := c.Create(context.TODO(), secret); err != nil {
+			t.Fatalf("Create() unexpected error %v", err)
+		}
+		created := &corev1.Secret{}
+		if err := c.Get(context.TODO(), client.ObjectKeyFromObject(secret), created); err != nil {
+			t.Fatalf("unable to get secret: %v", err)
+		}
+		if created.Labels["cost-center"] != "migrations" || created.Labels["app"] != "crane2" {
+			t.Errorf("secret in %s labels %v, the labels of the library should take precedence", ns, created.Labels)
+		}
+		if created.Annotations["team"] != "storage" {
+			t.Errorf("secret in %s annotations %v", ns, created.Annotations)
+		}
+		uids := map[types.UID]bool{}
+		for _, ref := range created.OwnerReferences {
+			uids[ref.UID] = true
+		}
+		if !uids[external.UID] {
+			t.Errorf("secret in %s should have the given owner references, got %v", ns, created.OwnerReferences)
+		}
+		if uids[owner.UID] != (ns == owner.Namespace) {
+			t.Errorf("secret in %s owned by %v, only resources in the namespace of the owner should be owned by it", ns, created.OwnerReferences)
+		}
+	}
+
+	// updating a resource built from scratch keeps the metadata and does not duplicate owners
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "secret"}}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Fatalf("unable to get secret: %v", err)
+	}
+	secret.Labels = nil
+	if err := c.Update(context.TODO(), secret); err != nil {
+		t.Fatalf("Update() unexpected error %v", err)
+	}
+	if secret.Labels["cost-center"] != "migrations" || len(secret.OwnerReferences) != 2 {
+		t.Errorf("updated secret labels %v, owners %v", secret.Labels, secret.OwnerReferences)
+	}
+}
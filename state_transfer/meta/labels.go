@@ -9,6 +9,45 @@ import (
 
 var Labels = map[string]string{"app": "crane2"}
 
+// CorrelationIDLabel is the label used to correlate all resources created for a single migration
+const CorrelationIDLabel = "crane.konveyor.io/correlation-id"
+
+// OwnerLabel is set to OwnerLabelValue on every resource created by the library, resources
+// without it are never deleted by the library
+const OwnerLabel = "crane.konveyor.io/owner"
+
+// OwnerLabelValue is the value of OwnerLabel
+const OwnerLabelValue = "crane-lib"
+
+// WithOwnerLabel returns a copy of the given labels with the owner label set
+func WithOwnerLabel(labels map[string]string) map[string]string {
+	newLabels := map[string]string{}
+	for key, val := range labels {
+		newLabels[key] = val
+	}
+	newLabels[OwnerLabel] = OwnerLabelValue
+	return newLabels
+}
+
+// IsOwned returns whether the given labels mark a resource as created by the library
+func IsOwned(labels map[string]string) bool {
+	return labels[OwnerLabel] == OwnerLabelValue
+}
+
+// WithCorrelationID returns a copy of the given labels with the correlation ID label set. The
+// returned labels can be passed to endpoints so that their resources can be correlated too.
+func WithCorrelationID(labels map[string]string, id string) (map[string]string, error) {
+	if errs := validation.IsValidLabelValue(id); len(errs) > 0 {
+		return nil, fmt.Errorf("correlation id %s is not a valid label value", id)
+	}
+	newLabels := map[string]string{}
+	for key, val := range labels {
+		newLabels[key] = val
+	}
+	newLabels[CorrelationIDLabel] = id
+	return newLabels, nil
+}
+
 func ValidateLabels(labels map[string]string) (err error) {
 	var errs []error
 	for key, val := range labels {
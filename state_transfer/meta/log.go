@@ -0,0 +1,129 @@
+package meta
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// LoggerOrDiscard returns the given logger, or a logger discarding every message when it is nil,
+// so that loggers are optional wherever the library accepts one
+func LoggerOrDiscard(log logr.Logger) logr.Logger {
+	if log == nil {
+		return logr.Discard()
+	}
+	return log
+}
+
+// LogHealthCheck logs the result of a health check with the given logger, which may be nil.
+// Resources are polled until they are healthy, so checks are logged at verbosity 1 and the error
+// of an unhealthy resource is logged as the reason rather than as an error.
+func LogHealthCheck(log logr.Logger, healthy bool, err error) {
+	if log == nil {
+		return
+	}
+	if err != nil {
+		log.V(1).Info("health checked", "healthy", healthy, "reason", err.Error())
+		return
+	}
+	log.V(1).Info("health checked", "healthy", healthy)
+}
+
+type loggingClient struct {
+	client.Client
+	log logr.Logger
+}
+
+// NewLoggingClient returns a client logging every resource it creates, updates, patches and
+// deletes with its kind, namespace and name. Resources which already exist when they are created
+// are only logged at verbosity 1, the library creates resources idempotently. The given client is
+// returned as is when the logger is nil or discards every message. When the given client already
+// logs, the given logger replaces its logger so that resources are only logged once, by the
+// innermost component.
+func NewLoggingClient(c client.Client, log logr.Logger) client.Client {
+	if _, discard := log.(logr.DiscardLogger); log == nil || discard {
+		return c
+	}
+	if l, ok := c.(*loggingClient); ok {
+		c = l.Client
+	}
+	return &loggingClient{Client: c, log: log}
+}
+
+func (l *loggingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := l.Client.Create(ctx, obj, opts...)
+	switch {
+	case k8serrors.IsAlreadyExists(err):
+		l.log.V(1).Info("resource already exists", l.keysAndValues(obj)...)
+	case err != nil:
+		l.log.Error(err, "unable to create resource", l.keysAndValues(obj)...)
+	default:
+		l.log.Info("created resource", l.keysAndValues(obj)...)
+	}
+	return err
+}
+
+func (l *loggingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := l.Client.Update(ctx, obj, opts...)
+	if err != nil {
+		l.log.Error(err, "unable to update resource", l.keysAndValues(obj)...)
+		return err
+	}
+	l.log.Info("updated resource", l.keysAndValues(obj)...)
+	return nil
+}
+
+func (l *loggingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	err := l.Client.Patch(ctx, obj, patch, opts...)
+	apply := patch.Type() == types.ApplyPatchType
+	switch {
+	case apply && applyUnsupported(l.Client, err):
+		l.log.V(1).Info("server-side apply not supported, creating resource", append(l.keysAndValues(obj), "reason", err.Error())...)
+	case err != nil:
+		l.log.Error(err, "unable to patch resource", l.keysAndValues(obj)...)
+	case apply:
+		l.log.Info("applied resource", l.keysAndValues(obj)...)
+	default:
+		l.log.Info("patched resource", l.keysAndValues(obj)...)
+	}
+	return err
+}
+
+func (l *loggingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := l.Client.Delete(ctx, obj, opts...)
+	switch {
+	case k8serrors.IsNotFound(err):
+		l.log.V(1).Info("resource already deleted", l.keysAndValues(obj)...)
+	case err != nil:
+		l.log.Error(err, "unable to delete resource", l.keysAndValues(obj)...)
+	default:
+		l.log.Info("deleted resource", l.keysAndValues(obj)...)
+	}
+	return err
+}
+
+func (l *loggingClient) unwrap() client.Client {
+	return l.Client
+}
+
+func (l *loggingClient) keysAndValues(obj client.Object) []interface{} {
+	return []interface{}{"kind", l.kind(obj), "namespace", obj.GetNamespace(), "name", obj.GetName()}
+}
+
+// kind returns the kind of the object, typed objects usually do not have their kind set
+func (l *loggingClient) kind(obj client.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	if l.Scheme() != nil {
+		if gvk, err := apiutil.GVKForObject(obj, l.Scheme()); err == nil {
+			return gvk.Kind
+		}
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
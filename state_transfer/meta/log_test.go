@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingLogger records the messages logged at verbosity 0 with their values
+type recordingLogger struct {
+	messages *[]string
+	level    int
+	values   []interface{}
+}
+
+func (r recordingLogger) Enabled() bool { return r.level == 0 }
+
+func (r recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	if r.Enabled() {
+		*r.messages = append(*r.messages, fmt.Sprint(msg, append(r.values, keysAndValues...)))
+	}
+}
+
+func (r recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	*r.messages = append(*r.messages, fmt.Sprint("error: ", msg, append(r.values, keysAndValues...)))
+}
+
+func (r recordingLogger) V(level int) logr.Logger {
+	r.level += level
+	return r
+}
+
+func (r recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	r.values = append(append([]interface{}{}, r.values...), keysAndValues...)
+	return r
+}
+
+func (r recordingLogger) WithName(name string) logr.Logger { return r }
+
+func TestLoggingClient(t *testing.T) {
+	messages := []string{}
+	c := NewLoggingClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), recordingLogger{messages: &messages})
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "secret"}}
+	if err := c.Create(context.TODO(), secret); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "secret"}}
+	if err := c.Create(context.TODO(), existing); err == nil {
+		t.Fatalf("Create() should fail for an existing secret")
+	}
+	if err := c.Delete(context.TODO(), secret); err != nil {
+		t.Fatalf("Delete() unexpected error %v", err)
+	}
+	want := []string{
+		"created resource[kind Secret namespace dest name secret]",
+		"deleted resource[kind Secret namespace dest name secret]",
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("logged %q, want %q", messages, want)
+	}
+
+	base := fake.NewClientBuilder().Build()
+	if NewLoggingClient(base, nil) != base || NewLoggingClient(base, logr.Discard()) != base {
+		t.Errorf("clients should not be wrapped without a logger")
+	}
+}
@@ -0,0 +1,219 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// renderNameSuffixLength is the length of the suffix appended to generate names, like the API server
+const renderNameSuffixLength = 5
+
+// RenderClient is a client recording the resources created, updated and patched through it
+// instead of sending them to the API server, see NewRenderClient
+type RenderClient struct {
+	// Client is the client resources which were not rendered are read from, nil when offline
+	client.Client
+	scheme  *runtime.Scheme
+	mutex   sync.Mutex
+	keys    []renderKey
+	objects map[renderKey]client.Object
+}
+
+type renderKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// NewRenderClient returns a client rendering the resources created through it rather than
+// creating them. Pass it to CreateServer, CreateClient or the Create of an endpoint to get the
+// resources they would create with Objects, so that they can be reviewed, stored or applied by
+// the caller. Resources are read from the given client unless they were rendered, nothing is
+// written to it: deletes only remove rendered resources and are otherwise ignored.
+func NewRenderClient(c client.Client) *RenderClient {
+	return &RenderClient{Client: c, scheme: c.Scheme(), objects: map[renderKey]client.Object{}}
+}
+
+// NewOfflineRenderClient returns a client rendering resources like NewRenderClient without reading
+// from any cluster: only rendered resources can be read and lists are empty. Unstructured
+// resources are rendered as they are, other types must be registered in the given scheme.
+func NewOfflineRenderClient(scheme *runtime.Scheme) *RenderClient {
+	return &RenderClient{scheme: scheme, objects: map[renderKey]client.Object{}}
+}
+
+// Objects returns the rendered resources in the order they were first rendered, with their kind
+// and the latest changes made to them
+func (r *RenderClient) Objects() []client.Object {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	objects := []client.Object{}
+	for _, key := range r.keys {
+		if obj, ok := r.objects[key]; ok {
+			objects = append(objects, obj.DeepCopyObject().(client.Object))
+		}
+	}
+	return objects
+}
+
+func (r *RenderClient) Scheme() *runtime.Scheme {
+	return r.scheme
+}
+
+func (r *RenderClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	renderKey, err := r.key(obj)
+	if err != nil {
+		return err
+	}
+	renderKey.namespace, renderKey.name = key.Namespace, key.Name
+	r.mutex.Lock()
+	rendered, ok := r.objects[renderKey]
+	r.mutex.Unlock()
+	switch {
+	case ok:
+		return copyObject(obj, rendered)
+	case r.Client == nil:
+		return k8serrors.NewNotFound(schemaResource(renderKey), key.Name)
+	}
+	return r.Client.Get(ctx, key, obj)
+}
+
+func (r *RenderClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if r.Client == nil {
+		return nil
+	}
+	return r.Client.List(ctx, list, opts...)
+}
+
+func (r *RenderClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if obj.GetName() == "" && obj.GetGenerateName() != "" {
+		obj.SetName(obj.GetGenerateName() + utilrand.String(renderNameSuffixLength))
+	}
+	key, err := r.key(obj)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	_, exists := r.objects[key]
+	r.mutex.Unlock()
+	if exists {
+		return k8serrors.NewAlreadyExists(schemaResource(key), key.name)
+	}
+	// the resource is created even though it exists on the API server, it is rendered as a whole
+	return r.record(key, obj)
+}
+
+func (r *RenderClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	// the resource may have been read from the API server, it is rendered as a whole
+	return r.render(obj)
+}
+
+// Patch renders obj as a whole, patches are computed from obj or, for apply and merge patches,
+// hold the fields of obj
+func (r *RenderClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return r.render(obj)
+}
+
+func (r *RenderClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	key, err := r.key(obj)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.objects, key)
+	return nil
+}
+
+func (r *RenderClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return nil
+}
+
+func (r *RenderClient) Status() client.StatusWriter {
+	return &renderStatusWriter{r}
+}
+
+func (r *RenderClient) unwrap() client.Client {
+	return r.Client
+}
+
+func (r *RenderClient) render(obj client.Object) error {
+	key, err := r.key(obj)
+	if err != nil {
+		return err
+	}
+	return r.record(key, obj)
+}
+
+func (r *RenderClient) record(key renderKey, obj client.Object) error {
+	rendered := obj.DeepCopyObject().(client.Object)
+	gvk, err := apiutil.GVKForObject(rendered, r.scheme)
+	if err != nil {
+		return err
+	}
+	rendered.GetObjectKind().SetGroupVersionKind(gvk)
+	// a resourceVersion read from the API server would be a precondition when applied
+	rendered.SetResourceVersion("")
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.objects[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.objects[key] = rendered
+	return nil
+}
+
+func (r *RenderClient) key(obj client.Object) (renderKey, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return renderKey{}, err
+	}
+	return renderKey{kind: gvk.GroupKind().String(), namespace: obj.GetNamespace(), name: obj.GetName()}, nil
+}
+
+// renderStatusWriter renders status updates along with the rest of the resource
+type renderStatusWriter struct {
+	r *RenderClient
+}
+
+func (w *renderStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return w.r.render(obj)
+}
+
+func (w *renderStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return w.r.render(obj)
+}
+
+// copyObject sets obj to a copy of the rendered resource from, converting between typed and
+// unstructured objects when their types differ
+func copyObject(obj client.Object, from client.Object) error {
+	if reflect.TypeOf(obj) == reflect.TypeOf(from) {
+		setObject(obj, from.DeepCopyObject().(client.Object))
+		return nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(from)
+	if err != nil {
+		return err
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.SetUnstructuredContent(content)
+		return nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, obj); err != nil {
+		return fmt.Errorf("unable to read rendered %s %s: %w", from.GetObjectKind().GroupVersionKind().Kind, from.GetName(), err)
+	}
+	return nil
+}
+
+func schemaResource(key renderKey) schema.GroupResource {
+	return schema.ParseGroupResource(key.kind)
+}
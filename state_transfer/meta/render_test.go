@@ -0,0 +1,112 @@
+package meta
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRenderClient(t *testing.T) {
+	existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "existing"}}
+	other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "other"}}
+	base := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing, other).Build()
+	c := NewRenderClient(base)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "config"}, Data: map[string]string{"key": "value"}}
+	if err := Apply(context.TODO(), c, cm); err != nil {
+		t.Fatalf("Apply() unexpected error %v", err)
+	}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("rendered resources should be readable, got %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(existing), secret); err != nil {
+		t.Fatalf("resources should be read from the underlying client, got %v", err)
+	}
+	secret.Data = map[string][]byte{"key": []byte("value")}
+	if err := c.Update(context.TODO(), secret); err != nil {
+		t.Fatalf("Update() unexpected error %v", err)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "dest", Name: "pod"}}
+	if err := c.Create(context.TODO(), pod); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+	if err := c.Delete(context.TODO(), pod); err != nil {
+		t.Fatalf("Delete() unexpected error %v", err)
+	}
+	if err := c.Delete(context.TODO(), other); err != nil {
+		t.Fatalf("Delete() unexpected error %v", err)
+	}
+
+	objects := c.Objects()
+	if len(objects) != 2 {
+		t.Fatalf("expected the config map and the secret to be rendered, got %d objects", len(objects))
+	}
+	if objects[0].GetName() != "config" || objects[0].GetObjectKind().GroupVersionKind().Kind != "ConfigMap" {
+		t.Errorf("unexpected first rendered object %s %s", objects[0].GetObjectKind().GroupVersionKind(), objects[0].GetName())
+	}
+	if rendered, ok := objects[1].(*corev1.Secret); !ok || string(rendered.Data["key"]) != "value" || rendered.ResourceVersion != "" {
+		t.Errorf("unexpected rendered secret %v", objects[1])
+	}
+
+	if err := base.Get(context.TODO(), client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("rendered resources should not be created, got %v", err)
+	}
+	unchanged := &corev1.Secret{}
+	if err := base.Get(context.TODO(), client.ObjectKeyFromObject(existing), unchanged); err != nil || len(unchanged.Data) != 0 {
+		t.Errorf("existing resources should not be updated, got %v", err)
+	}
+	if err := base.Get(context.TODO(), client.ObjectKeyFromObject(other), &corev1.Secret{}); err != nil {
+		t.Errorf("existing resources should not be deleted, got %v", err)
+	}
+}
+
+func TestOfflineRenderClient(t *testing.T) {
+	c := NewOfflineRenderClient(scheme.Scheme)
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "src", GenerateName: "rsync-"}}
+	if err := c.Create(context.TODO(), pod); err != nil {
+		t.Fatalf("Create() unexpected error %v", err)
+	}
+	if pod.Name == "" || pod.Name == pod.GenerateName {
+		t.Errorf("expected a name to be generated, got %q", pod.Name)
+	}
+	if err := c.Create(context.TODO(), pod.DeepCopy()); !k8serrors.IsAlreadyExists(err) {
+		t.Errorf("expected creating a rendered resource again to fail, got %v", err)
+	}
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "src", Name: "missing"}, &corev1.Pod{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("expected resources which were not rendered to be missing, got %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), pods); err != nil || len(pods.Items) != 0 {
+		t.Errorf("expected an empty list, got %v %v", pods.Items, err)
+	}
+
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Kind: "TLSRoute"})
+	route.SetNamespace("dest")
+	route.SetName("route")
+	if err := Apply(context.TODO(), c, route); err != nil {
+		t.Fatalf("Apply() unexpected error %v", err)
+	}
+	read := &unstructured.Unstructured{}
+	read.SetGroupVersionKind(route.GroupVersionKind())
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(route), read); err != nil || read.GetName() != "route" {
+		t.Errorf("expected unstructured resources to be rendered, got %v", err)
+	}
+	typed := &corev1.Pod{}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(pod), typed); err != nil || typed.Name != pod.Name {
+		t.Errorf("expected the rendered pod to be read back, got %v", err)
+	}
+	if objects := c.Objects(); len(objects) != 2 {
+		t.Errorf("expected the pod and the route to be rendered, got %d objects", len(objects))
+	}
+}
@@ -0,0 +1,164 @@
+// Package metrics exposes Prometheus collectors for the state transfers. The transfers update them
+// as they report their status and progress, operators embedding crane-lib register them with the
+// registry they serve with Register.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "crane"
+	metricsSubsystem = "transfer"
+
+	// TransferLabel is the label holding the type of the transfer, rsync for instance
+	TransferLabel = "transfer"
+	// IDLabel is the label identifying a transfer, a bounded identifier, see TransferID
+	IDLabel = "id"
+	// EstimateLabel is the label set to true when the amount of data transferred is an estimate,
+	// see transfer.Progress.Estimated
+	EstimateLabel = "estimate"
+	// NamespaceLabel is the label holding the namespace of the source PVC
+	NamespaceLabel = "namespace"
+	// PVCLabel is the label holding the name of the source PVC
+	PVCLabel = "pvc"
+	// PhaseLabel is the label holding the phase of the transfer of a PVC
+	PhaseLabel = "phase"
+)
+
+var (
+	// BytesTransferred is the amount of data transferred so far by a transfer, the estimate label
+	// tells whether it was counted by the transfer client or estimated from the size of the PVCs
+	BytesTransferred = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "bytes_transferred",
+		Help:      "Amount of data transferred so far, in bytes, estimated from the size of the completed PVCs when estimate is true.",
+	}, []string{TransferLabel, IDLabel, EstimateLabel})
+
+	// Duration is the time the transfer clients of a transfer have been running, or ran once
+	// they completed. It is a gauge as transfers report it every time their status is polled.
+	Duration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "duration_seconds",
+		Help:      "Time since the transfer started, or the time it took once it completed, in seconds.",
+	}, []string{TransferLabel, IDLabel})
+
+	// Retries is the number of times failed transfer clients were retried
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "retries_total",
+		Help:      "Number of times failed transfer clients were retried.",
+	}, []string{TransferLabel, IDLabel})
+
+	// PVCStatus is 1 for the current phase of the transfer of every PVC and 0 for the others, the
+	// series of a PVC are deleted once its transfer completed. The series are labelled with the
+	// namespace and the name of the PVC rather than with the ID of the transfer.
+	PVCStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "pvc_status",
+		Help:      "Phase of the transfer of a PVC, 1 for the current phase and 0 for the others.",
+	}, []string{TransferLabel, NamespaceLabel, PVCLabel, PhaseLabel})
+)
+
+// pvcPhases are the phases PVCStatus is reported for
+var pvcPhases = []transfer.PVCTransferPhase{
+	transfer.PVCTransferPhasePending,
+	transfer.PVCTransferPhaseTransferring,
+	transfer.PVCTransferPhaseCompleted,
+	transfer.PVCTransferPhaseFailed,
+}
+
+// TransferID returns the value of IDLabel for a transfer of the given PVCs, the given identifier
+// supplied by the caller or the ShortID of the PVCs when it is empty, so that the label keeps a
+// bounded length whatever the number of PVCs
+func TransferID(id string, pvcs transfer.PVCPairList) string {
+	if id != "" {
+		return id
+	}
+	return pvcs.ShortID()
+}
+
+// Collectors returns the collectors of the transfer metrics
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{BytesTransferred, Duration, Retries, PVCStatus}
+}
+
+// Register registers the transfer metrics with the given registerer, the registry of
+// controller-runtime for instance. Collectors already registered are not an error so that
+// Register can be called more than once.
+func Register(r prometheus.Registerer) error {
+	for _, c := range Collectors() {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ObserveProgress records the progress of the transfer of the given type and ID
+func ObserveProgress(transferType string, id string, progress *transfer.Progress) {
+	if progress == nil {
+		return
+	}
+	estimate := strconv.FormatBool(progress.Estimated)
+	BytesTransferred.DeleteLabelValues(transferType, id, strconv.FormatBool(!progress.Estimated))
+	BytesTransferred.WithLabelValues(transferType, id, estimate).Set(float64(progress.BytesTransferred))
+}
+
+// ObserveDuration records how long the transfer of the given type and ID has been running, or ran
+// once completed
+func ObserveDuration(transferType string, id string, d time.Duration) {
+	Duration.WithLabelValues(transferType, id).Set(d.Seconds())
+}
+
+// ObserveRetry counts a retry of the failed clients of the transfer of the given type and ID
+func ObserveRetry(transferType string, id string) {
+	Retries.WithLabelValues(transferType, id).Inc()
+}
+
+// ObservePVCStatus records the phase of every PVC of the transfer of the given type, the series of
+// the PVCs whose transfer completed are deleted
+func ObservePVCStatus(transferType string, status map[transfer.PVCPair]transfer.PVCTransferState) {
+	for pvc, state := range status {
+		claim := pvc.Source().Claim()
+		for _, phase := range pvcPhases {
+			labels := []string{transferType, claim.Namespace, claim.Name, string(phase)}
+			if state.Phase == transfer.PVCTransferPhaseCompleted {
+				PVCStatus.DeleteLabelValues(labels...)
+				continue
+			}
+			value := 0.0
+			if phase == state.Phase {
+				value = 1
+			}
+			PVCStatus.WithLabelValues(labels...).Set(value)
+		}
+	}
+}
+
+// Delete deletes every series of the transfer of the given type and ID, once it is finalized for
+// instance
+func Delete(transferType string, id string, pvcs transfer.PVCPairList) {
+	for _, estimate := range []bool{true, false} {
+		BytesTransferred.DeleteLabelValues(transferType, id, strconv.FormatBool(estimate))
+	}
+	Duration.DeleteLabelValues(transferType, id)
+	Retries.DeleteLabelValues(transferType, id)
+	for _, pvc := range pvcs {
+		claim := pvc.Source().Claim()
+		for _, phase := range pvcPhases {
+			PVCStatus.DeleteLabelValues(transferType, claim.Namespace, claim.Name, string(phase))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRegister(t *testing.T) {
+	r := prometheus.NewRegistry()
+	if err := Register(r); err != nil {
+		t.Fatalf("Register() unexpected error %v", err)
+	}
+	if err := Register(r); err != nil {
+		t.Errorf("Register() should not fail when the metrics are already registered: %v", err)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ObserveProgress("test", "migration-1", &transfer.Progress{BytesTransferred: 1024, Estimated: true})
+	ObserveProgress("test", "migration-1", &transfer.Progress{BytesTransferred: 2048})
+	ObserveProgress("test", "migration-1", nil)
+	if got := testutil.ToFloat64(BytesTransferred.WithLabelValues("test", "migration-1", "false")); got != 2048 {
+		t.Errorf("bytes transferred %v, want 2048", got)
+	}
+	if got := testutil.CollectAndCount(BytesTransferred); got != 1 {
+		t.Errorf("the estimated bytes transferred should be replaced by the counted ones, got %d series", got)
+	}
+
+	ObserveDuration("test", "migration-1", 90*time.Second)
+	if got := testutil.ToFloat64(Duration.WithLabelValues("test", "migration-1")); got != 90 {
+		t.Errorf("duration %v, want 90", got)
+	}
+
+	ObserveRetry("test", "migration-1")
+	ObserveRetry("test", "migration-1")
+	if got := testutil.ToFloat64(Retries.WithLabelValues("test", "migration-1")); got != 2 {
+		t.Errorf("retries %v, want 2", got)
+	}
+
+	pvc := transfer.NewPVCPair(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "ns"}}, nil)
+	ObservePVCStatus("test", map[transfer.PVCPair]transfer.PVCTransferState{pvc: {Phase: transfer.PVCTransferPhaseTransferring}})
+	for phase, want := range map[transfer.PVCTransferPhase]float64{
+		transfer.PVCTransferPhasePending:      0,
+		transfer.PVCTransferPhaseTransferring: 1,
+		transfer.PVCTransferPhaseCompleted:    0,
+		transfer.PVCTransferPhaseFailed:       0,
+	} {
+		if got := testutil.ToFloat64(PVCStatus.WithLabelValues("test", "ns", "data", string(phase))); got != want {
+			t.Errorf("pvc status for phase %s %v, want %v", phase, got, want)
+		}
+	}
+	ObservePVCStatus("test", map[transfer.PVCPair]transfer.PVCTransferState{pvc: {Phase: transfer.PVCTransferPhaseCompleted}})
+	if got := testutil.CollectAndCount(PVCStatus); got != 0 {
+		t.Errorf("the pvc status should be deleted once its transfer completed, got %d series", got)
+	}
+
+	Delete("test", "migration-1", transfer.PVCPairList{pvc})
+	for _, c := range Collectors() {
+		if got := testutil.CollectAndCount(c); got != 0 {
+			t.Errorf("Delete() left %d series", got)
+		}
+	}
+}
+
+func TestTransferID(t *testing.T) {
+	pvcs := transfer.PVCPairList{}
+	for i := 0; i < 100; i++ {
+		pvcs = append(pvcs, transfer.NewPVCPair(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: strconv.Itoa(i), Namespace: "ns"}}, nil))
+	}
+	if id := TransferID("", pvcs); id != pvcs.ShortID() || len(id) != 16 {
+		t.Errorf("TransferID() = %s, want the short ID of the PVCs", id)
+	}
+	if id := TransferID("migration-1", pvcs); id != "migration-1" {
+		t.Errorf("TransferID() = %s, want the identifier supplied by the caller", id)
+	}
+}
@@ -0,0 +1,199 @@
+// Package networkprobe checks that a destination endpoint can be reached from a source namespace
+// before a transfer is started, so that firewalls, egress proxies and Route configurations which
+// would block the transfer are reported up front rather than after its clients were created.
+package networkprobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultImage is the image of the probe pod when Probe.Image is not set, it provides bash and
+	// openssl
+	DefaultImage = "quay.io/konveyor/rsync-transfer:latest"
+	// DefaultTimeout is the time the probe is given to connect when Probe.Timeout is not set
+	DefaultTimeout = 10 * time.Second
+	// DefaultPollInterval is the interval the probe pod is polled at when Probe.PollInterval is
+	// not set
+	DefaultPollInterval = 2 * time.Second
+
+	probePodPrefix = "crane2-netprobe-"
+	probeContainer = "probe"
+)
+
+// ErrUnreachable is returned when the probe pod cannot connect to the destination
+var ErrUnreachable = errors.New("destination unreachable")
+
+// Probe is a connection attempt from a pod of a source namespace to a destination hostname and port
+type Probe struct {
+	// Namespace is the namespace the probe pod runs in, usually the source namespace of a transfer
+	// so that its network policies and egress configuration apply
+	Namespace string
+	// Hostname is the hostname or the IP address to connect to
+	Hostname string
+	// Port is the port to connect to
+	Port int32
+	// TLS completes a TLS handshake after connecting, e.g. for passthrough Routes which are routed
+	// on the server name of the connection
+	TLS bool
+	// ServerName is the server name sent in the TLS handshake, defaults to Hostname
+	ServerName string
+	// Image is the image of the probe pod, it must provide bash and, with TLS, openssl. Defaults to
+	// DefaultImage.
+	Image string
+	// Timeout is the time the probe is given to connect, defaults to DefaultTimeout
+	Timeout time.Duration
+	// PollInterval is the interval the probe pod is polled at, defaults to DefaultPollInterval
+	PollInterval time.Duration
+	// Labels are added to the probe pod, e.g. so that network policies select it like the
+	// transfer clients
+	Labels map[string]string
+}
+
+// ForTransfer returns the probe of the connection the clients of the given transfer open to its
+// endpoint from the first source namespace. A TLS handshake is made when the transfer uses the
+// stunnel transport.
+func ForTransfer(t transfer.Transfer) Probe {
+	return Probe{
+		Namespace: t.PVCs().GetSourceNamespaces()[0],
+		Hostname:  t.Endpoint().Hostname(),
+		Port:      t.Endpoint().ExposedPort(),
+		TLS:       t.Transport().Type() == stunnel.TransportTypeStunnel,
+	}
+}
+
+// Validate returns an error when the probe cannot be run, the hostname and the server name must be
+// DNS names or IP addresses since they are used in a shell command
+func (p Probe) Validate() error {
+	if p.Namespace == "" {
+		return fmt.Errorf("network probe requires a namespace")
+	}
+	if p.Port <= 0 || p.Port > 65535 {
+		return fmt.Errorf("invalid network probe port %d", p.Port)
+	}
+	for _, name := range []string{p.Hostname, p.ServerName} {
+		if name == "" || net.ParseIP(name) != nil {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid network probe hostname %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	if p.Hostname == "" {
+		return fmt.Errorf("network probe requires a hostname")
+	}
+	return nil
+}
+
+// command returns the shell command of the probe pod
+func (p Probe) command() string {
+	seconds := int((p.timeout() + time.Second - 1) / time.Second)
+	if !p.TLS {
+		return fmt.Sprintf("timeout %d bash -c '</dev/tcp/%s/%d' && echo connected to %s:%d",
+			seconds, p.Hostname, p.Port, p.Hostname, p.Port)
+	}
+	serverName := p.ServerName
+	if serverName == "" {
+		serverName = p.Hostname
+	}
+	return fmt.Sprintf("echo | timeout %d openssl s_client -connect %s -servername %s -brief 2>&1",
+		seconds, net.JoinHostPort(p.Hostname, fmt.Sprintf("%d", p.Port)), serverName)
+}
+
+func (p Probe) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Run creates a pod running the probe in its namespace, waits for it to complete and deletes it.
+// It returns an error wrapping ErrUnreachable with the output of the probe when the connection or
+// the TLS handshake failed. It requires the create, get and delete verbs on pods in the namespace
+// of the probe.
+func Run(ctx context.Context, c client.Client, p Probe) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	pod := p.pod()
+	if err := c.Create(ctx, pod, &client.CreateOptions{}); err != nil {
+		return err
+	}
+	// the probe is cleaned up even when the context was cancelled
+	defer func() {
+		ctx, cancel := meta.CleanupContext(ctx)
+		defer cancel()
+		_ = c.Delete(ctx, pod, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	}()
+
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	key := client.ObjectKeyFromObject(pod)
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		if err := c.Get(ctx, key, pod); err != nil {
+			return false, err
+		}
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("unable to probe %s:%d from namespace %s: %w", p.Hostname, p.Port, p.Namespace, err)
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Errorf("%w: %s:%d from namespace %s: %s", ErrUnreachable, p.Hostname, p.Port, p.Namespace, probeOutput(pod))
+	}
+	return nil
+}
+
+// probeOutput returns the termination message of the probe container
+func probeOutput(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == probeContainer && status.State.Terminated != nil {
+			if message := strings.TrimSpace(status.State.Terminated.Message); message != "" {
+				return message
+			}
+			return fmt.Sprintf("exit code %d", status.State.Terminated.ExitCode)
+		}
+	}
+	return "probe failed"
+}
+
+func (p Probe) pod() *corev1.Pod {
+	image := p.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: probePodPrefix,
+			Namespace:    p.Namespace,
+			Labels:       meta.WithOwnerLabel(p.Labels),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:                     probeContainer,
+					Image:                    image,
+					Command:                  []string{"/bin/bash", "-c", p.command()},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+		},
+	}
+}
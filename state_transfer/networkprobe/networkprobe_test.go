@@ -0,0 +1,104 @@
+package networkprobe
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   Probe
+		phase   corev1.PodPhase
+		message string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "tcp",
+			probe: Probe{Namespace: "src", Hostname: "rsync.apps.example.com", Port: 443},
+			phase: corev1.PodSucceeded,
+			want:  "timeout 10 bash -c '</dev/tcp/rsync.apps.example.com/443'",
+		},
+		{
+			name:    "tls blocked by a firewall",
+			probe:   Probe{Namespace: "src", Hostname: "10.0.0.1", Port: 443, TLS: true, ServerName: "rsync.apps.example.com", Timeout: 3 * time.Second},
+			phase:   corev1.PodFailed,
+			message: "connect: Connection timed out",
+			want:    "timeout 3 openssl s_client -connect 10.0.0.1:443 -servername rsync.apps.example.com",
+			wantErr: ErrUnreachable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			commands := make(chan string, 1)
+			go completeProbes(ctx, c, tt.phase, tt.message, commands)
+
+			tt.probe.PollInterval = time.Millisecond
+			err := Run(ctx, c, tt.probe)
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.message != "" && (err == nil || !strings.Contains(err.Error(), tt.message)) {
+				t.Errorf("Run() error = %v, want the output of the probe %q", err, tt.message)
+			}
+			if command := <-commands; !strings.Contains(command, tt.want) {
+				t.Errorf("probe command %q does not contain %q", command, tt.want)
+			}
+			pods := &corev1.PodList{}
+			if err := c.List(context.TODO(), pods); err != nil || len(pods.Items) != 0 {
+				t.Errorf("probe pod should be deleted: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, probe := range []Probe{
+		{Hostname: "example.com", Port: 443},
+		{Namespace: "src", Port: 443},
+		{Namespace: "src", Hostname: "example.com"},
+		{Namespace: "src", Hostname: "example.com; rm -rf /", Port: 443},
+		{Namespace: "src", Hostname: "example.com", ServerName: "$(id)", Port: 443},
+	} {
+		if err := probe.Validate(); err == nil {
+			t.Errorf("Validate() should reject %+v", probe)
+		}
+	}
+}
+
+// completeProbes completes the probe pods with the given phase and termination message and sends
+// their command
+func completeProbes(ctx context.Context, c client.Client, phase corev1.PodPhase, message string, commands chan<- string) {
+	for ctx.Err() == nil {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods); err == nil {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != "" {
+					continue
+				}
+				pod.Status.Phase = phase
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:  probeContainer,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+				}}
+				if err := c.Update(ctx, pod); err == nil {
+					commands <- pod.Spec.Containers[0].Command[2]
+				}
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
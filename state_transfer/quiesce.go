@@ -44,8 +44,14 @@ func QuiesceApplications(cfg *rest.Config, ns string) error {
 	if err != nil {
 		return err
 	}
+	return QuiesceApplicationsWithClient(c, ns)
+}
 
-	err = quiesceCronJobs(c, ns)
+// QuiesceApplicationsWithClient quiesces the applications of the namespace like
+// QuiesceApplications with the given client, its scheme must hold the apps, batch and OpenShift
+// apps types. It only returns once the pods of the scaled down workloads terminated.
+func QuiesceApplicationsWithClient(c client.Client, ns string) error {
+	err := quiesceCronJobs(c, ns)
 	if err != nil {
 		return err
 	}
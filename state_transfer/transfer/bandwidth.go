@@ -0,0 +1,62 @@
+package transfer
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// EgressBandwidthAnnotation shapes the egress traffic of a pod when the CNI bandwidth plugin
+	// is enabled, in bits per second
+	EgressBandwidthAnnotation = "kubernetes.io/egress-bandwidth"
+)
+
+// BandwidthLimit limits the rate at which the transfer clients send data, so that migrations can
+// run without saturating the links between clusters
+type BandwidthLimit struct {
+	// Rate is the maximum rate of every client in bytes per second, e.g. 10Mi
+	Rate resource.Quantity
+	// EgressAnnotation also sets EgressBandwidthAnnotation on the client pods, so that the
+	// network plugin enforces the limit for all the containers of the pods, including the
+	// transport. It has no effect when the CNI bandwidth plugin is not enabled.
+	EgressAnnotation bool
+}
+
+// GlobalBandwidthLimit is the bandwidth limit of the transfers created while it is set whose
+// options do not set one. It is read when transfers are created, it must not be changed while
+// transfers are being created concurrently.
+var GlobalBandwidthLimit *BandwidthLimit
+
+// EffectiveBandwidthLimit returns the given limit of a transfer, or GlobalBandwidthLimit when it
+// is nil
+func EffectiveBandwidthLimit(limit *BandwidthLimit) *BandwidthLimit {
+	if limit != nil {
+		return limit
+	}
+	return GlobalBandwidthLimit
+}
+
+// Validate returns an error when the rate of the limit is not a positive number of bytes
+func (b *BandwidthLimit) Validate() error {
+	if b.Rate.Sign() <= 0 {
+		return fmt.Errorf("bandwidth limit %s must be positive", b.Rate.String())
+	}
+	return nil
+}
+
+// KiBPerSecond returns the rate in KiB per second rounded up, as taken by the --bwlimit flag of
+// rsync and rclone
+func (b *BandwidthLimit) KiBPerSecond() int {
+	return int((b.Rate.Value() + 1023) / 1024)
+}
+
+// PodAnnotations returns the annotations of the client pods enforcing the limit, none unless
+// EgressAnnotation is set
+func (b *BandwidthLimit) PodAnnotations() map[string]string {
+	if b == nil || !b.EgressAnnotation {
+		return map[string]string{}
+	}
+	bits := resource.NewQuantity(b.Rate.Value()*8, resource.DecimalSI)
+	return map[string]string{EgressBandwidthAnnotation: bits.String()}
+}
@@ -5,6 +5,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 )
@@ -34,8 +35,11 @@ func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client,
 	if err != nil {
 		return nil, err
 	}
+	if err := transport.ValidateCompatibility(endpoint.ProtocolTCP, t, e); err != nil {
+		return nil, err
+	}
 	return &BlockrsyncTransfer{
-		log:             log,
+		log:             meta.LoggerOrDiscard(log).WithValues("transfer", "blockrsync"),
 		transport:       t,
 		endpoint:        e,
 		source:          src,
@@ -57,6 +61,15 @@ func (r *BlockrsyncTransfer) Transport() transport.Transport {
 	return r.transport
 }
 
+// Copy returns a copy of the transfer with copies of its endpoint and transport, see
+// transfer.Copier
+func (r *BlockrsyncTransfer) Copy() transfer.Transfer {
+	copied := *r
+	copied.endpoint = endpoint.Copy(r.endpoint)
+	copied.transport = transport.Copy(r.transport)
+	return &copied
+}
+
 func (r *BlockrsyncTransfer) Source() client.Client {
 	return r.source
 }
@@ -6,11 +6,13 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -21,24 +23,38 @@ const (
 	proxyListenPort                = "9002"
 )
 
-func (r *BlockrsyncTransfer) CreateClient(c client.Client) error {
-	pvc := r.pvcList[0]
-
-	_, err := transport.CreateClient(r.Transport(), c, "block", r.Endpoint())
+func (r *BlockrsyncTransfer) CreateClient(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetSourceNamespaces()[0])
+	err := r.createClient(ctx, meta.NewLoggingClient(c, log))
 	if err != nil {
-		return err
+		log.Error(err, "unable to create blockrsync client")
 	}
+	return err
+}
 
-	err = createBlockrsyncClient(c, r, pvc)
+func (r *BlockrsyncTransfer) createClient(ctx context.Context, c client.Client) error {
+	_, err := transport.CreateClient(ctx, r.Transport(), c, "block", r.Endpoint())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	customizeTransportContainers(r.Transport())
+	errs := []error{}
+	for _, pvc := range r.pvcList {
+		if err := createBlockrsyncClient(ctx, c, r, pvc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
 }
 
-func createBlockrsyncClient(c client.Client, r *BlockrsyncTransfer, pvc transfer.PVCPair) error {
-	podLabels := r.transferOptions.SourcePodMeta.Labels
+// createBlockrsyncClient creates the client pod of the given PVC, every PVC has its own client
+// pod identified by its pvc label
+func createBlockrsyncClient(ctx context.Context, c client.Client, r *BlockrsyncTransfer, pvc transfer.PVCPair) error {
+	podLabels := map[string]string{}
+	for key, val := range r.transferOptions.SourcePodMeta.Labels {
+		podLabels[key] = val
+	}
 	podLabels["pvc"] = pvc.Source().LabelSafeName()
 
 	containers := []v1.Container{
@@ -63,7 +79,6 @@ func createBlockrsyncClient(c client.Client, r *BlockrsyncTransfer, pvc transfer
 	addVolumeToContainer(pvc.Source().Claim(), pvc.Source().LabelSafeName(), pvc.Source().LabelSafeName(), &containers[1])
 	containers[1].Command = getBlockrsyncCommand(proxyListenPort, containers[1].Env[0].Value)
 
-	customizeTransportContainers(r.Transport().Type(), r.transport.ClientContainers())
 	containers = append(containers, r.Transport().ClientContainers()...)
 
 	volumes := []v1.Volume{
@@ -89,7 +104,7 @@ func createBlockrsyncClient(c client.Client, r *BlockrsyncTransfer, pvc transfer
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: "blockrsync-",
 			Namespace:    pvc.Source().Claim().Namespace,
-			Labels:       podLabels,
+			Labels:       meta.WithOwnerLabel(podLabels),
 		},
 		Spec: v1.PodSpec{
 			Containers:    containers,
@@ -98,7 +113,7 @@ func createBlockrsyncClient(c client.Client, r *BlockrsyncTransfer, pvc transfer
 		},
 	}
 
-	return c.Create(context.TODO(), &pod, &client.CreateOptions{})
+	return c.Create(ctx, &pod, &client.CreateOptions{})
 }
 
 func getProxyCommand(port int32, identifier string) []string {
@@ -168,8 +183,11 @@ func addVolumeToContainer(pvc *v1.PersistentVolumeClaim, header, identifier stri
 	}
 }
 
-func customizeTransportContainers(t transport.TransportType, containers []v1.Container) {
-	switch t {
+// customizeTransportContainers makes the stunnel client exit once blockrsync is done, it changes
+// the containers of the transport in place so they are only customized once for every client pod
+func customizeTransportContainers(t transport.Transport) {
+	containers := t.ClientContainers()
+	switch t.Type() {
 	case stunnel.TransportTypeStunnel:
 		var stunnelContainer *v1.Container
 		for i := range containers {
@@ -181,7 +199,7 @@ func customizeTransportContainers(t transport.TransportType, containers []v1.Con
 		stunnelContainer.Command = []string{
 			"/bin/bash",
 			"-c",
-			`/bin/stunnel /etc/stunnel/stunnel.conf
+			stunnel.ClientStartCommand(t) + `
 while true
 do test -f /usr/share/stunnel-communication/blockrsync-done
 if [ $? -eq 0 ]
@@ -193,6 +211,11 @@ fi
 done
 exit 0`,
 		}
+		for _, mount := range stunnelContainer.VolumeMounts {
+			if mount.Name == stunnelCommunicationVolumeName {
+				return
+			}
+		}
 		stunnelContainer.VolumeMounts = append(
 			stunnelContainer.VolumeMounts,
 			v1.VolumeMount{
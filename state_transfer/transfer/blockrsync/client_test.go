@@ -19,7 +19,7 @@ func TestCreateClient(t *testing.T) {
 	}
 
 	tr, srcClient, _ := createTransfer(transferOptions, t)
-	if err := tr.CreateClient(srcClient); err != nil {
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
 		t.Fatalf("unable to create client: %v", err)
 	}
 
@@ -40,3 +40,33 @@ func TestCreateClient(t *testing.T) {
 		t.Fatalf("client pod image not set correctly")
 	}
 }
+
+func TestCreateClientMultiplePVCs(t *testing.T) {
+	tr, srcClient, _ := createTransfer(&TransferOptions{}, t)
+	tr.pvcList = append(tr.pvcList, &testPVCPair{
+		source: &testPVC{label: "test-pvc-2", pvc: createPVC("test-pvc-2", testNamespace, &block)},
+		dest:   &testPVC{label: "test-pvc-2", pvc: createPVC("test-pvc-2", testNamespace, &block)},
+	})
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	for _, pvc := range tr.pvcList {
+		clientPodList := &corev1.PodList{}
+		if err := srcClient.List(context.TODO(), clientPodList, &client.ListOptions{
+			Namespace:     testNamespace,
+			LabelSelector: labels.SelectorFromSet(labels.Set{"pvc": pvc.Source().LabelSafeName()}),
+		}); err != nil {
+			t.Fatalf("unable to list client pods: %v", err)
+		}
+		if len(clientPodList.Items) != 1 {
+			t.Fatalf("client pods of %s = %d, want 1", pvc.Source().LabelSafeName(), len(clientPodList.Items))
+		}
+		if volume := clientPodList.Items[0].Spec.Volumes[0]; volume.PersistentVolumeClaim.ClaimName != pvc.Source().Claim().Name {
+			t.Errorf("client pod volume = %s, want %s", volume.PersistentVolumeClaim.ClaimName, pvc.Source().Claim().Name)
+		}
+	}
+	if tr.transferOptions.SourcePodMeta.Labels != nil {
+		t.Errorf("client should not change the labels of the options, got %v", tr.transferOptions.SourcePodMeta.Labels)
+	}
+}
@@ -3,8 +3,11 @@ package blockrsync
 import "github.com/konveyor/crane-lib/state_transfer/transfer"
 
 type TransferOptions struct {
-	SourcePodMeta         transfer.ResourceMetadata
-	DestinationPodMeta    transfer.ResourceMetadata
+	SourcePodMeta      transfer.ResourceMetadata
+	DestinationPodMeta transfer.ResourceMetadata
+	// Sparse zeroes the destination devices before the transfer so that the blocks of zeroes of
+	// the source are not sent, it must not be set when the destination already holds a copy
+	Sparse                bool
 	username              string
 	password              string
 	blockrsyncServerImage string
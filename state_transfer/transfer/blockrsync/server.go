@@ -7,7 +7,9 @@ import (
 	"strings"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -19,23 +21,56 @@ const (
 	blockrsyncServerPodName = "blockrsync-server"
 )
 
-func (r *BlockrsyncTransfer) CreateServer(c client.Client) error {
-	err := r.createBlockrysncServer(c)
+func (r *BlockrsyncTransfer) CreateServer(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0])
+	err := r.createServer(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create blockrsync server")
+	}
+	return err
+}
+
+func (r *BlockrsyncTransfer) createServer(ctx context.Context, c client.Client) error {
+	err := r.createBlockrysncServer(ctx, c)
 	if err != nil {
 		return err
 	}
 
-	_, err = endpoint.Create(r.Endpoint(), c)
+	_, err = endpoint.Create(ctx, r.Endpoint(), c)
 	return err
 }
 
-func (r *BlockrsyncTransfer) IsServerHealthy(c client.Client) (bool, error) {
-	deploymentLabels := r.Endpoint().Labels()
-	deploymentLabels["pvc"] = r.pvcList[0].Destination().LabelSafeName()
-	return transfer.AreFilteredPodsHealthy(c, r.pvcList.GetDestinationNamespaces()[0], deploymentLabels)
+func (r *BlockrsyncTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := r.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
+}
+
+// isServerHealthy checks the server pod, a single server receives the devices of every PVC
+func (r *BlockrsyncTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	key := client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: blockrsyncServerPodName}
+	containers := append([]string{BlockRsyncContainer}, transport.ServerContainerNames(r.Transport())...)
+	return transfer.IsPodHealthy(ctx, c, key, containers...)
+}
+
+// zeroDevicesScript returns the script zeroing the destination devices before they are received
+// when the transfer is sparse, blocks of zeroes then match on both sides and are never sent.
+// Zeroing is offloaded to the storage when it supports it and is skipped when it fails. File
+// destinations of VM disks are created sparse by blockrsync and are left as is.
+func (r *BlockrsyncTransfer) zeroDevicesScript() string {
+	if !r.transferOptions.Sparse {
+		return ""
+	}
+	script := ""
+	for _, pvc := range r.pvcList {
+		if isPVCBlock(pvc.Destination().Claim()) {
+			script += fmt.Sprintf("blkdiscard --zeroout /dev/%s || true\n", pvc.Destination().LabelSafeName())
+		}
+	}
+	return script
 }
 
-func (r *BlockrsyncTransfer) createBlockrysncServer(c client.Client) error {
+func (r *BlockrsyncTransfer) createBlockrysncServer(ctx context.Context, c client.Client) error {
 	pvcs := r.PVCs()
 	destNs := r.pvcList.GetDestinationNamespaces()[0]
 	containers := make([]v1.Container, 0)
@@ -84,7 +119,7 @@ func (r *BlockrsyncTransfer) createBlockrysncServer(c client.Client) error {
 	blockRsyncContainerCommand := []string{
 		"/bin/bash",
 		"-c",
-		strings.Join(blockRsyncCommand, " "),
+		r.zeroDevicesScript() + strings.Join(blockRsyncCommand, " "),
 	}
 	container.Command = blockRsyncContainerCommand
 	containers = append(containers, container)
@@ -104,7 +139,7 @@ func (r *BlockrsyncTransfer) createBlockrysncServer(c client.Client) error {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      blockrsyncServerPodName,
 			Namespace: destNs,
-			Labels:    r.transferOptions.SourcePodMeta.Labels,
+			Labels:    meta.WithOwnerLabel(r.transferOptions.SourcePodMeta.Labels),
 		},
 		Spec: v1.PodSpec{
 			Containers:    containers,
@@ -113,5 +148,5 @@ func (r *BlockrsyncTransfer) createBlockrysncServer(c client.Client) error {
 		},
 	}
 
-	return c.Create(context.TODO(), &server, &client.CreateOptions{})
+	return c.Create(ctx, &server, &client.CreateOptions{})
 }
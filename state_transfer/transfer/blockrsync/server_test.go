@@ -3,6 +3,7 @@ package blockrsync
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	routev1 "github.com/openshift/api/route/v1"
@@ -33,11 +34,11 @@ func TestCreateServer(t *testing.T) {
 	}
 
 	tr, _, destClient := createTransfer(transferOptions, t)
-	if err := tr.CreateServer(destClient); err != nil {
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
 		t.Fatalf("CreateServer should not return an error\n %v", err)
 	}
 	// Do it again, should create an error this time due to already existing resource.
-	if err := tr.CreateServer(destClient); err == nil {
+	if err := tr.CreateServer(context.TODO(), destClient); err == nil {
 		t.Fatalf("CreateServer should return an error")
 	}
 
@@ -50,7 +51,7 @@ func TestCreateServer(t *testing.T) {
 	}
 
 	// This will return an error since the pod status is not set in a unit test
-	if _, err := tr.IsServerHealthy(destClient); err == nil {
+	if _, err := tr.IsServerHealthy(context.TODO(), destClient); err == nil {
 		t.Fatalf("IsServerHealthy should return an error\n")
 	}
 }
@@ -76,8 +77,8 @@ func createEndpoint(t *testing.T, name, namespace string, c client.Client) endpo
 		types.NamespacedName{
 			Namespace: namespace,
 			Name:      name,
-		}, route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain")
-	e, err := endpoint.Create(r, c)
+		}, route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	e, err := endpoint.Create(context.TODO(), r, c)
 	if err != nil {
 		t.Fatalf("unable to create route endpoint: %v", err)
 	}
@@ -105,7 +106,7 @@ func createEndpoint(t *testing.T, name, namespace string, c client.Client) endpo
 		t.Fatalf("unable to update route status: %v", err)
 	}
 
-	ready, err := e.IsHealthy(c)
+	ready, err := e.IsHealthy(context.TODO(), c)
 	if err != nil {
 		t.Fatalf("unable to check route health: %v", err)
 	}
@@ -122,7 +123,7 @@ func createTransfer(transferOptions *TransferOptions, t *testing.T) (*Blockrsync
 	if e == nil {
 		t.Fatalf("unable to create endpoint")
 	}
-	transport := null.NewTransport(&testNamespacedNamePair{})
+	transport := null.NewTransport(&testNamespacedNamePair{}, nil)
 	log := klogr.New()
 	pvcList := transfer.PVCPairList{
 		&testPVCPair{
@@ -159,3 +160,31 @@ func (t *testNamespacedNamePair) Source() types.NamespacedName {
 func (t *testNamespacedNamePair) Destination() types.NamespacedName {
 	return t.dst
 }
+
+func TestCreateServerSparse(t *testing.T) {
+	tr, _, destClient := createTransfer(&TransferOptions{Sparse: true}, t)
+	tr.pvcList = append(tr.pvcList, &testPVCPair{
+		source: &testPVC{label: "test-disk", pvc: createPVC("test-disk", testNamespace, &fileSystem)},
+		dest:   &testPVC{label: "test-disk", pvc: createPVC("test-disk", testNamespace, &fileSystem)},
+	})
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("CreateServer should not return an error\n %v", err)
+	}
+
+	serverPod := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: blockrsyncServerPodName}, serverPod); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	command := serverPod.Spec.Containers[0].Command[2]
+	if !strings.HasPrefix(command, "blkdiscard --zeroout /dev/test-pvc || true\n/proxy ") {
+		t.Errorf("server should zero the block device before the transfer, got %q", command)
+	}
+	if strings.Contains(command, "test-disk ||") {
+		t.Errorf("server should not zero file destinations, got %q", command)
+	}
+	for _, id := range []string{"--identifier test-pvc", "--identifier test-disk"} {
+		if !strings.Contains(command, id) {
+			t.Errorf("server command should contain %q, got %q", id, command)
+		}
+	}
+}
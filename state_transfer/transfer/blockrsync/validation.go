@@ -16,11 +16,11 @@ const (
 
 // validatePVCList validates list of PVCs provided to blockrsync transfer
 // list cannot contain pvcs belonging to two or more source/destination namespaces
-// list must contain at exactly one pvc
+// list must contain at least one pvc
 // labelSafeNames of all pvcs must be valid label values
 // labelSafeNames must be unique within the namespace of the pvc
-// volume mode must be block or filesystem if the pvc has an annotation that indicates
-// it is a kubevirt disk pvc.
+// volume mode of every pvc must be block or filesystem if the pvc has an annotation that
+// indicates it is a kubevirt disk pvc, use PVCPairList.Block() to select the block pvcs of a list
 func validatePVCList(pvcList transfer.PVCPairList) error {
 	validationErrors := []error{}
 
@@ -33,15 +33,24 @@ func validatePVCList(pvcList transfer.PVCPairList) error {
 
 	if len(pvcList) == 0 {
 		validationErrors = append(validationErrors, fmt.Errorf("at least one pvc must be provided"))
-	} else {
-		if err := validatePVCName(pvcList[0]); err != nil {
+	}
+	srcLabels := map[string]bool{}
+	destLabels := map[string]bool{}
+	for _, pvc := range pvcList {
+		if err := validatePVCName(pvc); err != nil {
 			validationErrors = append(
 				validationErrors,
 				errorsutil.NewAggregate([]error{
-					fmt.Errorf("pvc name validation failed for pvc %s with error", pvcList[0].Source().Claim().Name),
+					fmt.Errorf("pvc name validation failed for pvc %s with error", pvc.Source().Claim().Name),
 					err,
 				}))
 		}
+		if srcLabels[pvc.Source().LabelSafeName()] || destLabels[pvc.Destination().LabelSafeName()] {
+			validationErrors = append(validationErrors,
+				fmt.Errorf("labelSafeName() for %s must be unique", pvc.Source().Claim().Name))
+		}
+		srcLabels[pvc.Source().LabelSafeName()] = true
+		destLabels[pvc.Destination().LabelSafeName()] = true
 	}
 	return errorsutil.NewAggregate(validationErrors)
 }
@@ -63,7 +72,6 @@ func validatePVCName(pvcPair transfer.PVCPair) error {
 	if err := isBlockOrKubeVirtDisk(pvcPair.Destination().Claim()); err != nil {
 		validationErrors = append(validationErrors, err)
 	}
-	pvcPair.Source().Claim()
 	return errorsutil.NewAggregate(validationErrors)
 }
 
@@ -0,0 +1,143 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CancelledAnnotation is set on transfer pods which were stopped using Cancel
+	CancelledAnnotation = "crane.konveyor.io/cancelled"
+	// cancelledMarkPrefix prefixes the name of the ConfigMap marking a transfer as cancelled
+	cancelledMarkPrefix = "crane2-cancelled-"
+	// cancelledMarkKey holds the ID of the PVC list of the cancelled transfer
+	cancelledMarkKey = "pvcs"
+	// DefaultCancelGracePeriodSeconds is the time given to transfer containers
+	// to finish the file currently being copied before they are killed
+	DefaultCancelGracePeriodSeconds = int64(30)
+)
+
+// Canceller knows how to abort an in-progress transfer
+type Canceller interface {
+	// CancelClient gracefully stops the transfer client pods
+	CancelClient(ctx context.Context, c client.Client) error
+	// CancelServer gracefully stops the transfer server pods
+	CancelServer(ctx context.Context, c client.Client) error
+}
+
+// Cancel aborts an in-progress transfer. The client and the server are sent
+// SIGTERM and given a grace period to finish the file currently being copied,
+// leaving the destination in a known but incomplete state. When cleanup is set,
+// remaining resources of the transfer are deleted afterwards, the transport resources
+// are only deleted when they were created without a prefix. A ConfigMap owned by the
+// library marks the transfer as cancelled in the namespace of its source PVCs, so that it is
+// still reported as cancelled once its pods are deleted, until ClearCancelled is called. Creating
+// the client again and Finalize clear the mark.
+func Cancel(ctx context.Context, t Transfer, cleanup bool) error {
+	canceller, ok := t.(Canceller)
+	if !ok {
+		return fmt.Errorf("transfer does not support cancellation")
+	}
+	// the mark is set first so that no client is retried once its pod is deleted
+	if err := setCancelled(ctx, t.Source(), t.PVCs()); err != nil {
+		return err
+	}
+	errs := []error{}
+	errs = append(errs, canceller.CancelClient(ctx, t.Source()))
+	errs = append(errs, canceller.CancelServer(ctx, t.Destination()))
+	if cleanup {
+		errs = append(errs, DeleteClient(ctx, t, ""))
+		errs = append(errs, DeleteServer(ctx, t, ""))
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// CancelPod is a utility function that can be used by various implementations
+// to mark a transfer pod as cancelled and delete it gracefully
+func CancelPod(ctx context.Context, c client.Client, pod *corev1.Pod) error {
+	if pod.DeletionTimestamp != nil {
+		return nil
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[CancelledAnnotation] = "true"
+	err := c.Update(ctx, pod)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	err = c.Delete(ctx, pod, client.GracePeriodSeconds(DefaultCancelGracePeriodSeconds))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// IsCancelled returns whether the given transfer pod was stopped using Cancel
+func IsCancelled(pod *corev1.Pod) bool {
+	return pod.Annotations[CancelledAnnotation] == "true"
+}
+
+// WasCancelled returns whether the transfer of the given PVCs was stopped using Cancel, that is
+// whether the ConfigMap marking it as cancelled exists in the namespace of its source PVCs
+func WasCancelled(ctx context.Context, c client.Client, pvcs PVCPairList) (bool, error) {
+	mark := cancelledMark(pvcs)
+	if mark == nil {
+		return false, nil
+	}
+	err := c.Get(ctx, client.ObjectKeyFromObject(mark), mark)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClearCancelled removes the mark set by Cancel, so that a transfer of the same PVCs created again
+// is not reported as cancelled
+func ClearCancelled(ctx context.Context, c client.Client, pvcs PVCPairList) error {
+	mark := cancelledMark(pvcs)
+	if mark == nil {
+		return nil
+	}
+	err := c.Delete(ctx, mark)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setCancelled creates the ConfigMap marking the transfer of the given PVCs as cancelled
+func setCancelled(ctx context.Context, c client.Client, pvcs PVCPairList) error {
+	mark := cancelledMark(pvcs)
+	if mark == nil {
+		return nil
+	}
+	return meta.Apply(ctx, c, mark)
+}
+
+// cancelledMark returns the ConfigMap marking the transfer of the given PVCs as cancelled, in the
+// namespace of the first source PVC and named after the ShortID of the list. It is owned by the
+// library but not rendered with the transfer, so that it outlives the resources Cancel deletes.
+func cancelledMark(pvcs PVCPairList) *corev1.ConfigMap {
+	namespaces := pvcs.GetSourceNamespaces()
+	if len(namespaces) == 0 {
+		return nil
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespaces[0],
+			Name:      cancelledMarkPrefix + pvcs.ShortID(),
+			Labels:    meta.WithOwnerLabel(nil),
+		},
+		Data: map[string]string{
+			cancelledMarkKey: pvcs.ID(),
+		},
+	}
+}
@@ -0,0 +1,131 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionServerReady is true when the endpoint, the transport and the server are healthy
+	ConditionServerReady = "ServerReady"
+	// ConditionClientRunning is true while at least one transfer client is running
+	ConditionClientRunning = "ClientRunning"
+	// ConditionCompleted is true once all transfer clients completed successfully
+	ConditionCompleted = "Completed"
+	// ConditionFailed is true when at least one transfer client failed, its message holds the
+	// errors reported by the client
+	ConditionFailed = "Failed"
+	// ConditionStalled is true when a running transfer made no progress for a while, see ETA
+	ConditionStalled = "Stalled"
+)
+
+// componentNotReadyReasons are the reasons of a false ConditionServerReady by unhealthy component
+var componentNotReadyReasons = map[Component]string{
+	ComponentEndpoint:  "EndpointNotReady",
+	ComponentTransport: "TransportNotReady",
+	ComponentServer:    "ServerNotReady",
+}
+
+// Conditions returns the ServerReady, ClientRunning, Completed, Failed and Stalled conditions of
+// the transfer, so that controllers can report them in the status of their resources, typically
+// with meta.SetStatusCondition of k8s.io/apimachinery which keeps the transition time of
+// conditions whose status did not change. The server health is read with the destination client
+// and the status and progress of the clients with the source client. The client conditions are
+// unknown for transfers which do not implement StatusReporter, and Stalled is unknown for
+// transfers which do not implement ProgressReporter. An error is only returned when the state of
+// the transfer could not be read.
+func Conditions(ctx context.Context, t Transfer) ([]metav1.Condition, error) {
+	now := metav1.Now()
+	health, err := ServerHealth(ctx, t, t.Destination())
+	if err != nil {
+		return nil, err
+	}
+	serverReady := metav1.Condition{
+		Type:               ConditionServerReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Healthy",
+		LastTransitionTime: now,
+	}
+	if !health.Healthy() {
+		serverReady.Status = metav1.ConditionFalse
+		serverReady.Reason = componentNotReadyReasons[health.Errors[0].Component]
+		serverReady.Message = health.Err().Error()
+	}
+	conditions := []metav1.Condition{serverReady}
+
+	reporter, ok := t.(StatusReporter)
+	if !ok {
+		for _, conditionType := range []string{ConditionClientRunning, ConditionCompleted, ConditionFailed, ConditionStalled} {
+			conditions = append(conditions, metav1.Condition{
+				Type:               conditionType,
+				Status:             metav1.ConditionUnknown,
+				Reason:             "StatusNotReported",
+				LastTransitionTime: now,
+			})
+		}
+		return conditions, nil
+	}
+	status, err := reporter.Status(ctx, t.Source())
+	if err != nil {
+		return nil, err
+	}
+	reason := "Transfer" + string(status.Phase)
+	for _, c := range []struct {
+		conditionType string
+		phase         TransferPhase
+	}{
+		{ConditionClientRunning, TransferPhaseRunning},
+		{ConditionCompleted, TransferPhaseSucceeded},
+		{ConditionFailed, TransferPhaseFailed},
+	} {
+		condition := metav1.Condition{
+			Type:               c.conditionType,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            status.Message,
+			LastTransitionTime: now,
+		}
+		if status.Phase == c.phase {
+			condition.Status = metav1.ConditionTrue
+		}
+		conditions = append(conditions, condition)
+	}
+	stalled, err := stalledCondition(ctx, t, status, now)
+	if err != nil {
+		return nil, err
+	}
+	return append(conditions, stalled), nil
+}
+
+// stalledCondition returns the Stalled condition of a transfer in the given status
+func stalledCondition(ctx context.Context, t Transfer, status *Status, now metav1.Time) (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:               ConditionStalled,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Transfer" + string(status.Phase),
+		LastTransitionTime: now,
+	}
+	if status.Phase != TransferPhaseRunning {
+		return condition, nil
+	}
+	reporter, ok := t.(ProgressReporter)
+	if !ok {
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "ProgressNotReported"
+		return condition, nil
+	}
+	progress, err := reporter.Progress(ctx, t.Source())
+	if err != nil {
+		return condition, err
+	}
+	condition.Reason = "Progressing"
+	_, err = estimateRemaining(progress, t.PVCs().EstimateSize(), now.Time)
+	if errors.Is(err, ErrTransferStalled) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "NoProgress"
+		condition.Message = err.Error()
+	}
+	return condition, nil
+}
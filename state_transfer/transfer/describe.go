@@ -0,0 +1,110 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// describeStatusTimeout bounds the time Describe waits for the live status of a transfer
+const describeStatusTimeout = 10 * time.Second
+
+// Describe returns a human readable summary of a transfer: its current status, transport, endpoint
+// and PVC pairs. The status is the only information read from the cluster, it is reported
+// as unavailable when the transfer does not implement StatusReporter or the cluster cannot be
+// reached. Credentials of the transport are never included.
+func Describe(t Transfer) string {
+	var out strings.Builder
+	b := tabwriter.NewWriter(&out, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(b, "Transfer:\t%s\n", typeName(t))
+	fmt.Fprintf(b, "Status:\t%s\n", describeStatus(t))
+
+	if tr := t.Transport(); tr != nil {
+		fmt.Fprintf(b, "Transport:\t%s\n", tr.Type())
+		fmt.Fprintf(b, "  Ports:\tlisten %d, exposed %d\n", tr.Port(), tr.ExposedPort())
+		fmt.Fprintf(b, "  Direct:\t%t\n", tr.Direct())
+		if options := tr.Options(); options != nil {
+			if options.ProxyURL != "" {
+				fmt.Fprintf(b, "  Proxy:\t%s\n", redactURL(options.ProxyURL))
+			} else if options.AutoDetectProxy {
+				fmt.Fprintf(b, "  Proxy:\tauto-detected\n")
+			}
+			if options.ProxyType != "" {
+				fmt.Fprintf(b, "  Proxy Type:\t%s\n", options.ProxyType)
+			}
+			fmt.Fprintf(b, "  Verify CA:\t%t\n", !options.NoVerifyCA)
+			if options.CAVerifyLevel != "" {
+				fmt.Fprintf(b, "  CA Verify Level:\t%s\n", options.CAVerifyLevel)
+			}
+			if options.MutualTLS {
+				fmt.Fprintf(b, "  Mutual TLS:\t%t\n", options.MutualTLS)
+			}
+		}
+	}
+
+	if e := t.Endpoint(); e != nil {
+		fmt.Fprintf(b, "Endpoint:\t%s %s\n", typeName(e), e.NamespacedName())
+		fmt.Fprintf(b, "  Hostname:\t%s\n", e.Hostname())
+		fmt.Fprintf(b, "  Ports:\tbackend %d, exposed %d\n", e.Port(), e.ExposedPort())
+	}
+
+	fmt.Fprintf(b, "PVCs:\t%d\n", len(t.PVCs()))
+	for _, pair := range t.PVCs() {
+		src, dest := pair.Source().Claim(), pair.Destination().Claim()
+		fmt.Fprintf(b, "  %s/%s -> %s/%s (%s)\n", src.Namespace, src.Name, dest.Namespace, dest.Name, claimSize(src))
+	}
+
+	_ = b.Flush()
+	return out.String()
+}
+
+func describeStatus(t Transfer) string {
+	reporter, ok := t.(StatusReporter)
+	if !ok {
+		return "unavailable"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), describeStatusTimeout)
+	defer cancel()
+	status, err := reporter.Status(ctx, t.Source())
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+	if status.Message == "" {
+		return string(status.Phase)
+	}
+	return fmt.Sprintf("%s (%s)", status.Phase, status.Message)
+}
+
+func claimSize(claim *corev1.PersistentVolumeClaim) string {
+	if capacity, ok := claim.Status.Capacity[corev1.ResourceStorage]; ok {
+		return capacity.String()
+	}
+	if request, ok := claim.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		return request.String()
+	}
+	return "unknown size"
+}
+
+// typeName returns the name of the concrete type of v without its package
+func typeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// redactURL hides the password of a URL, invalid URLs are hidden entirely
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,141 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DestinationPVCOptions makes a transfer create the destination PVCs which do not exist, from the
+// spec of their source PVC, instead of requiring callers to create every destination PVC
+// beforehand. The destination PVCs are created in the namespace and with the name of the
+// destination of their pair, they are not deleted with the server since they hold the transferred
+// data.
+type DestinationPVCOptions struct {
+	// StorageClassMapping maps the storage class of source PVCs to the storage class of their
+	// destination, e.g. when the destination cluster has different provisioners. Classes which are
+	// not mapped are kept, the "" key maps source PVCs without a storage class.
+	StorageClassMapping map[string]string
+	// AccessModeMapping converts the access modes of source PVCs, e.g. ReadWriteOnce to
+	// ReadWriteMany. Access modes which are not mapped are kept.
+	AccessModeMapping map[corev1.PersistentVolumeAccessMode]corev1.PersistentVolumeAccessMode
+	// SizeFactor multiplies the capacity of the source PVC to size its destination, e.g. 1.1 to
+	// leave room for a file system with a larger overhead. Defaults to 1, cannot be less than 1.
+	SizeFactor float64
+}
+
+// Validate returns an error when the options cannot be used to create destination PVCs
+func (o DestinationPVCOptions) Validate() error {
+	errs := []error{}
+	if o.SizeFactor != 0 && o.SizeFactor < 1 {
+		errs = append(errs, fmt.Errorf("size factor %v of destination pvcs cannot be less than 1", o.SizeFactor))
+	}
+	for source, destination := range o.AccessModeMapping {
+		if !isAccessMode(source) || !isAccessMode(destination) {
+			errs = append(errs, fmt.Errorf("invalid access mode mapping %s to %s", source, destination))
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func isAccessMode(mode corev1.PersistentVolumeAccessMode) bool {
+	switch mode {
+	case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany:
+		return true
+	}
+	return false
+}
+
+// DestinationPVC returns the destination PVC of the given pair built from its source PVC with the
+// options
+func (o DestinationPVCOptions) DestinationPVC(pair PVCPair) *corev1.PersistentVolumeClaim {
+	source := pair.Source().Claim()
+	destination := pair.Destination().Claim()
+
+	storageClassName := source.Spec.StorageClassName
+	sourceClass := ""
+	if storageClassName != nil {
+		sourceClass = *storageClassName
+	}
+	if class, ok := o.StorageClassMapping[sourceClass]; ok {
+		storageClassName = &class
+	}
+
+	accessModes := []corev1.PersistentVolumeAccessMode{}
+	for _, mode := range source.Spec.AccessModes {
+		if mapped, ok := o.AccessModeMapping[mode]; ok {
+			mode = mapped
+		}
+		if !hasAccessMode(accessModes, mode) {
+			accessModes = append(accessModes, mode)
+		}
+	}
+
+	// the destination must be at least as large as the data, which is bound by the capacity of the
+	// source
+	size, ok := source.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		size = source.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+	if o.SizeFactor > 1 {
+		size = expandSize(size, o.SizeFactor)
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   destination.Namespace,
+			Name:        destination.Name,
+			Labels:      destination.Labels,
+			Annotations: destination.Annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: storageClassName,
+			VolumeMode:       source.Spec.VolumeMode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSize multiplies the size by the factor, rounded up to a whole MiB
+func expandSize(size resource.Quantity, factor float64) resource.Quantity {
+	const mib = 1024 * 1024
+	bytes := math.Ceil(float64(size.Value())*factor/mib) * mib
+	return *resource.NewQuantity(int64(bytes), resource.BinarySI)
+}
+
+// CreateDestinationPVCs is a utility function that can be used by various implementations to
+// create the destination PVC of every pair of the list which does not exist yet, see
+// DestinationPVCOptions. Existing destination PVCs are left as they are. Creating them requires the
+// create verb on persistentvolumeclaims in the destination namespaces.
+func CreateDestinationPVCs(ctx context.Context, c client.Client, options *DestinationPVCOptions, pvcList PVCPairList) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, pvc := range pvcList {
+		err := c.Create(ctx, options.DestinationPVC(pvc), &client.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
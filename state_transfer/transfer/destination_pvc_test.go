@@ -0,0 +1,66 @@
+package transfer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCreateDestinationPVCs(t *testing.T) {
+	source := newTestPVC("src", "data", map[string]string{"app": "db"})
+	storageClass := "gp2"
+	block := v1.PersistentVolumeBlock
+	source.Spec.StorageClassName = &storageClass
+	source.Spec.VolumeMode = &block
+	source.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce, v1.ReadWriteMany}
+	source.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}
+	existing := newTestPVC("dst", "logs", nil)
+	existing.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}
+	pvcs := PVCPairList{
+		NewPVCPair(source, newTestPVC("dst", "data", map[string]string{"app": "db"})),
+		NewPVCPair(newTestPVC("src", "logs", nil), existing),
+	}
+	c := buildTestClient(t, existing)
+
+	options := &DestinationPVCOptions{
+		StorageClassMapping: map[string]string{"gp2": "ceph-rbd"},
+		AccessModeMapping:   map[v1.PersistentVolumeAccessMode]v1.PersistentVolumeAccessMode{v1.ReadWriteOnce: v1.ReadWriteMany},
+		SizeFactor:          1.1,
+	}
+	if err := CreateDestinationPVCs(context.TODO(), c, options, pvcs); err != nil {
+		t.Fatalf("CreateDestinationPVCs() unexpected error %v", err)
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "dst", Name: "data"}, pvc); err != nil {
+		t.Fatalf("unable to get destination pvc: %v", err)
+	}
+	if *pvc.Spec.StorageClassName != "ceph-rbd" {
+		t.Errorf("destination storage class = %s, want ceph-rbd", *pvc.Spec.StorageClassName)
+	}
+	if !reflect.DeepEqual(pvc.Spec.AccessModes, []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}) {
+		t.Errorf("destination access modes = %v, want a single ReadWriteMany", pvc.Spec.AccessModes)
+	}
+	if size := pvc.Spec.Resources.Requests[v1.ResourceStorage]; size.String() != "11Gi" {
+		t.Errorf("destination size = %s, want 11Gi", size.String())
+	}
+	if pvc.Spec.VolumeMode == nil || *pvc.Spec.VolumeMode != block || pvc.Labels["app"] != "db" {
+		t.Errorf("destination pvc should keep the volume mode of the source and its own labels")
+	}
+
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "dst", Name: "logs"}, pvc); err != nil {
+		t.Fatalf("unable to get destination pvc: %v", err)
+	}
+	if size := pvc.Spec.Resources.Requests[v1.ResourceStorage]; size.String() != "1Gi" {
+		t.Errorf("existing destination pvc should be left as is, size = %s", size.String())
+	}
+
+	options.SizeFactor = 0.9
+	if err := CreateDestinationPVCs(context.TODO(), c, options, pvcs); err == nil {
+		t.Errorf("CreateDestinationPVCs() should reject a size factor less than 1")
+	}
+}
@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// SecretWarning is written above every Secret in exported manifests
+const SecretWarning = "# WARNING: this Secret contains plaintext credentials, encrypt it before committing it"
+
+// ExportOptions configures the manifests rendered by ExportManifestsWithOptions
+type ExportOptions struct {
+	// TransportPrefix is the prefix given to the transport resources, as passed to transport.CreateServer
+	TransportPrefix string
+	// OmitSecrets leaves Secrets out of the exported manifests, they must then be provided separately
+	OmitSecrets bool
+}
+
+// ExportManifests renders all the objects the endpoint, the transport and the transfer would
+// create as a multi-document YAML stream, so that they can be applied declaratively instead of
+// using CreateServer and CreateClient. Secrets are included and preceded by SecretWarning.
+func ExportManifests(ctx context.Context, t Transfer) ([]byte, error) {
+	return ExportManifestsWithOptions(ctx, t, ExportOptions{})
+}
+
+// ExportManifestsWithOptions renders the objects of the transfer as a multi-document YAML stream.
+// Nothing is created in the clusters, the objects of the destination are written first followed
+// by the objects of the source. A copy of the transfer is rendered, see Copy, so that rendering
+// leaves the endpoint and the transport unchanged: a transport which was not created yet
+// generates a certificate for the manifests only, the manifests are consistent with each other
+// but not with the transport. With a CertificateProvider, the resources requesting the certificate
+// are exported and the Secrets of the transport hold a self signed placeholder until it is issued.
+func ExportManifestsWithOptions(ctx context.Context, t Transfer, options ExportOptions) ([]byte, error) {
+	destination, source, err := render(ctx, t, options.TransportPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, c := range []*meta.RenderClient{destination, source} {
+		objs, err := renderedObjects(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			isSecret := obj.GetAPIVersion() == "v1" && obj.GetKind() == "Secret"
+			if isSecret && options.OmitSecrets {
+				continue
+			}
+			// server side fields are meaningless outside of the cluster the object was read from
+			unstructured.RemoveNestedField(obj.Object, "metadata", "resourceVersion")
+			unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+			unstructured.RemoveNestedField(obj.Object, "status")
+			data, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return nil, err
+			}
+			out.WriteString("---\n")
+			if isSecret {
+				out.WriteString(SecretWarning + "\n")
+			}
+			out.Write(data)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// render creates the objects of a copy of the endpoint, the transport and the transfer in offline
+// destination and source render clients, nothing is read from or created in the clusters of the
+// transfer and the transfer is left unchanged
+func render(ctx context.Context, t Transfer, transportPrefix string) (*meta.RenderClient, *meta.RenderClient, error) {
+	t = Copy(t)
+	if tr := t.Transport(); tr != nil && tr.Options() != nil && tr.Options().CertificateProvider != nil {
+		// the Secrets of the transport are rendered even though its certificate is not issued yet
+		tr.Options().CertificateProvider = transport.PlaceholderCertificateProvider(tr.Options().CertificateProvider)
+	}
+	scheme, err := Scheme(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	destination := meta.NewOfflineRenderClient(scheme)
+	source := meta.NewOfflineRenderClient(scheme)
+
+	// transfers going through an intermediate storage, e.g. restic, have no endpoint nor transport
+	if t.Endpoint() != nil {
+		if err := t.Endpoint().Create(ctx, destination); err != nil {
+			return nil, nil, fmt.Errorf("unable to render endpoint: %w", err)
+		}
+	}
+	if t.Transport() != nil {
+		if _, err := transport.CreateServer(ctx, t.Transport(), destination, transportPrefix, t.Endpoint()); err != nil {
+			return nil, nil, fmt.Errorf("unable to render transport server: %w", err)
+		}
+	}
+	if err := t.CreateServer(ctx, destination); err != nil {
+		return nil, nil, fmt.Errorf("unable to render transfer server: %w", err)
+	}
+	if t.Transport() != nil {
+		if _, err := transport.CreateClient(ctx, t.Transport(), source, transportPrefix, t.Endpoint()); err != nil {
+			return nil, nil, fmt.Errorf("unable to render transport client: %w", err)
+		}
+	}
+	if err := t.CreateClient(ctx, source); err != nil {
+		return nil, nil, fmt.Errorf("unable to render transfer client: %w", err)
+	}
+	return destination, source, nil
+}
+
+// renderedObjects returns the objects rendered in c as unstructured objects, including the kinds
+// which are not registered in its scheme, sorted by kind, namespace and name so that the output
+// is stable
+func renderedObjects(c *meta.RenderClient) ([]unstructured.Unstructured, error) {
+	objs := []unstructured.Unstructured{}
+	for _, obj := range c.Objects() {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, unstructured.Unstructured{Object: content})
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		a, b := objs[i], objs[j]
+		if a.GetAPIVersion()+a.GetKind() != b.GetAPIVersion()+b.GetKind() {
+			return a.GetAPIVersion()+a.GetKind() < b.GetAPIVersion()+b.GetKind()
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+	return objs, nil
+}
@@ -0,0 +1,30 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FileError describes a file which could not be transferred
+type FileError struct {
+	// PVC is the source PVC the file belongs to
+	PVC types.NamespacedName
+	// Path is the path of the file relative to the root of the volume
+	Path string
+	// Reason is the error reported for the file, e.g. Permission denied (13)
+	Reason string
+}
+
+func (f FileError) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.PVC, f.Path, f.Reason)
+}
+
+// FileErrorReporter knows how to report files that a transfer failed to copy
+type FileErrorReporter interface {
+	// FailedFiles returns the files the transfer clients failed to copy, the
+	// client must be able to reach the cluster the transfer client runs in
+	FailedFiles(ctx context.Context, c client.Client) ([]FileError, error)
+}
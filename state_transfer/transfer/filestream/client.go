@@ -0,0 +1,118 @@
+package filestream
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (f *FileStreamTransfer) CreateClient(ctx context.Context, c client.Client) error {
+	log := f.log.WithValues("namespace", f.pvcList.GetSourceNamespaces()[0])
+	err := f.createClient(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create filestream client")
+	}
+	return err
+}
+
+func (f *FileStreamTransfer) createClient(ctx context.Context, c client.Client) error {
+	_, err := transport.CreateClient(ctx, f.Transport(), c, defaultTransportPrefix, f.Endpoint())
+	if err != nil {
+		return err
+	}
+
+	return createFileStreamClient(ctx, c, f)
+}
+
+func createFileStreamClient(ctx context.Context, c client.Client, f *FileStreamTransfer) error {
+	os := osOrDefault(f.transferOptions.SourceOS)
+	address := net.JoinHostPort(transfer.ConnectionHostname(f), strconv.Itoa(int(transfer.ConnectionPort(f))))
+	container := v1.Container{
+		Name:  FileStreamContainer,
+		Image: f.transferOptions.getImage(),
+		Args:  []string{"send", "--connect", address, "--root", dataRoot(os)},
+	}
+	volumes := []v1.Volume{}
+	for _, pvc := range f.pvcList {
+		addPVC(&container, &volumes, pvc.Source(), pvc, os)
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fileStreamClientName,
+			Namespace: f.pvcList.GetSourceNamespaces()[0],
+			// the labels of the endpoint would select the client when both are in the same namespace
+			Labels: meta.WithOwnerLabel(map[string]string{"app": fileStreamClientName}),
+		},
+		Spec: v1.PodSpec{
+			Containers:    append([]v1.Container{container}, f.Transport().ClientContainers()...),
+			Volumes:       append(volumes, f.Transport().ClientVolumes()...),
+			RestartPolicy: v1.RestartPolicyOnFailure,
+		},
+	}
+	scheduleOn(&pod.Spec, os)
+
+	return c.Create(ctx, pod, &client.CreateOptions{})
+}
+
+// dataRoot returns the directory the PVCs are mounted under in the transfer pods
+func dataRoot(os string) string {
+	if os == OSWindows {
+		return windowsDataRoot
+	}
+	return linuxDataRoot
+}
+
+// addPVC mounts the given PVC of the pair in the container, in a directory named after the
+// destination PVC on both sides so that the files of the source PVC land in the destination PVC
+func addPVC(container *v1.Container, volumes *[]v1.Volume, pvc transfer.PVC, pair transfer.PVCPair, os string) {
+	mountPath := dataRoot(os) + "/" + pair.Destination().LabelSafeName()
+	if os == OSWindows {
+		mountPath = strings.ReplaceAll(mountPath, "/", `\`)
+	}
+	container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+		Name:      pvc.LabelSafeName(),
+		MountPath: mountPath,
+	})
+	*volumes = append(*volumes, v1.Volume{
+		Name: pvc.LabelSafeName(),
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pvc.Claim().Name,
+			},
+		},
+	})
+}
+
+// scheduleOn schedules the pod on nodes of the given OS, Windows nodes are commonly tainted so
+// that Linux pods are not scheduled on them
+func scheduleOn(spec *v1.PodSpec, os string) {
+	spec.NodeSelector = map[string]string{osNodeLabel: os}
+	if os == OSWindows {
+		spec.Tolerations = append(spec.Tolerations, v1.Toleration{
+			Key:      osNodeLabel,
+			Operator: v1.TolerationOpEqual,
+			Value:    OSWindows,
+			Effect:   v1.TaintEffectNoSchedule,
+		})
+	}
+}
+
+// podLabels returns the labels of the endpoint, they are copied as they are shared with its
+// resources and select the server pod
+func podLabels(f *FileStreamTransfer) map[string]string {
+	labels := map[string]string{}
+	for key, val := range f.Endpoint().Labels() {
+		labels[key] = val
+	}
+	return labels
+}
@@ -0,0 +1,85 @@
+// Command filestream is the server and the client of the file stream transfer, it is built for
+// Linux and Windows into the image of the transfer pods:
+//
+//	filestream serve --listen :2222 --root /data
+//	filestream send --connect server:2222 --root /data
+//
+// The server receives a single stream and exits, the client retries connecting to the server
+// until it is reachable or the timeout expires.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer/filestream"
+)
+
+const (
+	connectInterval = 2 * time.Second
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: filestream serve|send [flags]")
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serve(os.Args[2:])
+	case "send":
+		err = send(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %s, must be serve or send", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", ":2222", "address to listen on")
+	root := flags.String("root", "", "directory to write the files to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return filestream.Receive(conn, *root)
+}
+
+func send(args []string) error {
+	flags := flag.NewFlagSet("send", flag.ExitOnError)
+	address := flags.String("connect", "", "address of the server")
+	root := flags.String("root", "", "directory to send the files of")
+	timeout := flags.Duration("timeout", 10*time.Minute, "how long to retry connecting to the server")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(*timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", *address, connectInterval)
+		if err == nil {
+			defer conn.Close()
+			return filestream.Send(conn, *root)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("unable to connect to %s: %w", *address, err)
+		}
+		time.Sleep(connectInterval)
+	}
+}
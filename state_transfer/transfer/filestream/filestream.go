@@ -0,0 +1,130 @@
+package filestream
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// FileStreamContainer is the name of the container streaming the files in transfer pods
+	FileStreamContainer = "filestream"
+)
+
+const (
+	// OSLinux runs the transfer pods on Linux nodes
+	OSLinux = "linux"
+	// OSWindows runs the transfer pods on Windows nodes
+	OSWindows = "windows"
+)
+
+const (
+	// fileStreamImage is built from cmd/filestream for Linux and Windows, the OS of the nodes
+	// selects the image of the manifest list
+	fileStreamImage        = "quay.io/konveyor/crane-filestream:latest"
+	fileStreamServerName   = "crane2-filestream-server"
+	fileStreamClientName   = "crane2-filestream-client"
+	linuxDataRoot          = "/data"
+	windowsDataRoot        = `C:\data`
+	osNodeLabel            = "kubernetes.io/os"
+	defaultTransportPrefix = "filestream"
+)
+
+// TransferOptions are the options of a file stream transfer
+type TransferOptions struct {
+	// Image is the image of the transfer pods, its entrypoint must be the filestream command of
+	// cmd/filestream built for the OS of the nodes. Defaults to a multi-OS image.
+	Image string
+	// SourceOS is the OS of the nodes the source PVCs are attached to, OSLinux or OSWindows.
+	// Defaults to OSLinux.
+	SourceOS string
+	// DestinationOS is the OS of the nodes the destination PVCs are attached to, OSLinux or
+	// OSWindows. Defaults to OSLinux.
+	DestinationOS string
+}
+
+func (t *TransferOptions) getImage() string {
+	if t.Image == "" {
+		return fileStreamImage
+	}
+	return t.Image
+}
+
+func osOrDefault(os string) string {
+	if os == "" {
+		return OSLinux
+	}
+	return os
+}
+
+// FileStreamTransfer copies the files of PVCs with a Go-native server and client rather than
+// rsync, so that PVCs attached to Windows nodes can be transferred. The client streams the files
+// of every source PVC as a tar archive and the server writes them to the destination PVCs,
+// converting paths to the OS of the destination. Files are copied in full on every run, their
+// ownership and ACLs are not preserved. Windows pods cannot run the Linux containers of the
+// stunnel and ssh transports, transfers involving Windows nodes must use the null transport.
+type FileStreamTransfer struct {
+	source          client.Client
+	destination     client.Client
+	pvcList         transfer.PVCPairList
+	transport       transport.Transport
+	endpoint        endpoint.Endpoint
+	transferOptions *TransferOptions
+	log             logr.Logger
+}
+
+func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger, options *TransferOptions) (transfer.Transfer, error) {
+	if options == nil {
+		options = &TransferOptions{}
+	}
+	if err := validatePVCList(pvcList); err != nil {
+		return nil, err
+	}
+	if err := validateOptions(options, t); err != nil {
+		return nil, err
+	}
+	if err := transport.ValidateCompatibility(endpoint.ProtocolTCP, t, e); err != nil {
+		return nil, err
+	}
+	return &FileStreamTransfer{
+		log:             meta.LoggerOrDiscard(log).WithValues("transfer", "filestream"),
+		transport:       t,
+		endpoint:        e,
+		source:          src,
+		destination:     dest,
+		pvcList:         pvcList,
+		transferOptions: options,
+	}, nil
+}
+
+func (f *FileStreamTransfer) PVCs() transfer.PVCPairList {
+	return f.pvcList
+}
+
+func (f *FileStreamTransfer) Endpoint() endpoint.Endpoint {
+	return f.endpoint
+}
+
+func (f *FileStreamTransfer) Transport() transport.Transport {
+	return f.transport
+}
+
+// Copy returns a copy of the transfer with copies of its endpoint and transport, see
+// transfer.Copier
+func (f *FileStreamTransfer) Copy() transfer.Transfer {
+	copied := *f
+	copied.endpoint = endpoint.Copy(f.endpoint)
+	copied.transport = transport.Copy(f.transport)
+	return &copied
+}
+
+func (f *FileStreamTransfer) Source() client.Client {
+	return f.source
+}
+
+func (f *FileStreamTransfer) Destination() client.Client {
+	return f.destination
+}
@@ -0,0 +1,194 @@
+package filestream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testPVCName       = "test-pvc"
+)
+
+func TestNewTransferValidation(t *testing.T) {
+	block := corev1.PersistentVolumeBlock
+	pair := transfer.NewPVCPair(testPVC(testNamespace, testPVCName, nil), testPVC(testDestNamespace, testPVCName, nil))
+	tests := []struct {
+		name      string
+		pvcList   transfer.PVCPairList
+		options   *TransferOptions
+		transport transport.Transport
+		wantErr   bool
+	}{
+		{
+			name:    "linux with stunnel",
+			pvcList: transfer.PVCPairList{pair},
+		},
+		{
+			name:      "windows with null transport",
+			pvcList:   transfer.PVCPairList{pair},
+			options:   &TransferOptions{DestinationOS: OSWindows},
+			transport: null.NewTransport(testNamespacedPair(), nil),
+		},
+		{
+			name:    "windows with stunnel",
+			pvcList: transfer.PVCPairList{pair},
+			options: &TransferOptions{SourceOS: OSWindows},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported os",
+			pvcList: transfer.PVCPairList{pair},
+			options: &TransferOptions{SourceOS: "darwin"},
+			wantErr: true,
+		},
+		{
+			name:    "block pvc",
+			pvcList: transfer.PVCPairList{transfer.NewPVCPair(testPVC(testNamespace, testPVCName, &block), nil)},
+			wantErr: true,
+		},
+		{
+			name: "several source namespaces",
+			pvcList: transfer.PVCPairList{pair, transfer.NewPVCPair(
+				testPVC("other-namespace", "other", nil), testPVC(testDestNamespace, "other", nil))},
+			wantErr: true,
+		},
+		{
+			name:    "no pvc",
+			pvcList: transfer.PVCPairList{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := tt.transport
+			if tp == nil {
+				tp = stunnel.NewTransport(testNamespacedPair(), nil, &transport.Options{})
+			}
+			e := service.NewEndpoint(types.NamespacedName{Namespace: testDestNamespace, Name: testPVCName},
+				statetransfermeta.Labels, "test.host", corev1.ServiceTypeLoadBalancer, nil)
+			_, err := NewTransfer(tp, e, buildTestClient(), buildTestClient(), tt.pvcList, nil, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTransfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateServerAndClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       *TransferOptions
+		wantServerDir string
+		wantClientDir string
+	}{
+		{
+			name:          "linux",
+			options:       &TransferOptions{},
+			wantServerDir: "/data/",
+			wantClientDir: "/data/",
+		},
+		{
+			name:          "windows source",
+			options:       &TransferOptions{SourceOS: OSWindows},
+			wantServerDir: "/data/",
+			wantClientDir: `C:\data\`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dest := buildTestClient(), buildTestClient()
+			tr := createTransfer(t, src, dest, tt.options)
+			mountDir := tr.PVCs()[0].Destination().LabelSafeName()
+			if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+				t.Fatalf("CreateServer() unexpected error %v", err)
+			}
+			if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+				t.Fatalf("CreateClient() unexpected error %v", err)
+			}
+
+			server := &corev1.Pod{}
+			if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: fileStreamServerName}, server); err != nil {
+				t.Fatalf("unable to get server pod: %v", err)
+			}
+			clientPod := &corev1.Pod{}
+			if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: fileStreamClientName}, clientPod); err != nil {
+				t.Fatalf("unable to get client pod: %v", err)
+			}
+			for _, check := range []struct {
+				pod     *corev1.Pod
+				os      string
+				command string
+				dir     string
+			}{
+				{server, osOrDefault(tt.options.DestinationOS), "serve", tt.wantServerDir},
+				{clientPod, osOrDefault(tt.options.SourceOS), "send", tt.wantClientDir},
+			} {
+				container := check.pod.Spec.Containers[0]
+				if container.Args[0] != check.command || container.Args[4] != dataRoot(check.os) {
+					t.Errorf("unexpected args of %s: %v", check.pod.Name, container.Args)
+				}
+				if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].MountPath != check.dir+mountDir {
+					t.Errorf("pvc of %s mounted at %v, want %s", check.pod.Name, container.VolumeMounts, check.dir+mountDir)
+				}
+				if check.pod.Spec.NodeSelector[osNodeLabel] != check.os {
+					t.Errorf("%s should be scheduled on %s nodes, node selector %v", check.pod.Name, check.os, check.pod.Spec.NodeSelector)
+				}
+				if tolerates := len(check.pod.Spec.Tolerations) == 1; tolerates != (check.os == OSWindows) {
+					t.Errorf("%s tolerations %v, only windows pods should tolerate windows nodes", check.pod.Name, check.pod.Spec.Tolerations)
+				}
+			}
+		})
+	}
+}
+
+func buildTestClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+}
+
+func testNamespacedPair() statetransfermeta.NamespacedNamePair {
+	return statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testDestNamespace, Name: testPVCName},
+	)
+}
+
+func testPVC(namespace, name string, mode *corev1.PersistentVolumeMode) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeMode: mode},
+	}
+}
+
+func createTransfer(t *testing.T, src, dest client.Client, options *TransferOptions) transfer.Transfer {
+	pvcList := transfer.PVCPairList{transfer.NewPVCPair(testPVC(testNamespace, testPVCName, nil), testPVC(testDestNamespace, testPVCName, nil))}
+	var e endpoint.Endpoint = service.NewEndpoint(types.NamespacedName{Namespace: testDestNamespace, Name: testPVCName},
+		statetransfermeta.Labels, "test.host", corev1.ServiceTypeLoadBalancer, nil)
+	var s transport.Transport = stunnel.NewTransport(testNamespacedPair(), nil, &transport.Options{})
+	if options.SourceOS == OSWindows || options.DestinationOS == OSWindows {
+		s = null.NewTransport(testNamespacedPair(), nil)
+	}
+	if _, err := transport.CreateServer(context.TODO(), s, dest, defaultTransportPrefix, e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	tr, err := NewTransfer(s, e, src, dest, pvcList, nil, options)
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	return tr
+}
@@ -0,0 +1,90 @@
+package filestream
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (f *FileStreamTransfer) CreateServer(ctx context.Context, c client.Client) error {
+	log := f.log.WithValues("namespace", f.pvcList.GetDestinationNamespaces()[0])
+	err := f.createServer(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create filestream server")
+	}
+	return err
+}
+
+func (f *FileStreamTransfer) createServer(ctx context.Context, c client.Client) error {
+	err := createFileStreamServer(ctx, c, f)
+	if err != nil {
+		return err
+	}
+
+	_, err = endpoint.Create(ctx, f.Endpoint(), c)
+	return err
+}
+
+// IsServerHealthy returns whether the server is ready to receive the files or has received them
+func (f *FileStreamTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := f.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(f.log.WithValues("namespace", f.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
+}
+
+func (f *FileStreamTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	key := client.ObjectKey{Namespace: f.pvcList.GetDestinationNamespaces()[0], Name: fileStreamServerName}
+	pod := &v1.Pod{}
+	if err := c.Get(ctx, key, pod); err != nil {
+		return false, err
+	}
+	if pod.Status.Phase == v1.PodSucceeded {
+		return true, nil
+	}
+	containers := append([]string{FileStreamContainer}, transport.ServerContainerNames(f.Transport())...)
+	return transfer.IsPodHealthy(ctx, c, key, containers...)
+}
+
+func createFileStreamServer(ctx context.Context, c client.Client, f *FileStreamTransfer) error {
+	os := osOrDefault(f.transferOptions.DestinationOS)
+	container := v1.Container{
+		Name:  FileStreamContainer,
+		Image: f.transferOptions.getImage(),
+		Args:  []string{"serve", "--listen", fmt.Sprintf(":%d", f.Transport().ExposedPort()), "--root", dataRoot(os)},
+		Ports: []v1.ContainerPort{
+			{
+				Name:          "filestream",
+				Protocol:      v1.ProtocolTCP,
+				ContainerPort: f.Transport().ExposedPort(),
+			},
+		},
+	}
+	volumes := []v1.Volume{}
+	for _, pvc := range f.pvcList {
+		addPVC(&container, &volumes, pvc.Destination(), pvc, os)
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fileStreamServerName,
+			Namespace: f.pvcList.GetDestinationNamespaces()[0],
+			Labels:    meta.WithOwnerLabel(podLabels(f)),
+		},
+		Spec: v1.PodSpec{
+			Containers:    append([]v1.Container{container}, f.Transport().ServerContainers()...),
+			Volumes:       append(volumes, f.Transport().ServerVolumes()...),
+			RestartPolicy: v1.RestartPolicyOnFailure,
+		},
+	}
+	scheduleOn(&pod.Spec, os)
+
+	return c.Create(ctx, pod, &client.CreateOptions{})
+}
@@ -0,0 +1,232 @@
+package filestream
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// statusOK is sent back by the receiving side once every file was written
+	statusOK = "ok"
+	// maxReportedPaths is the number of invalid paths named in the error of Receive
+	maxReportedPaths = 5
+)
+
+var (
+	// ErrInvalidPath is returned by Receive when paths of the stream cannot be written to the
+	// destination, e.g. names which are not valid on Windows. The other files are still written.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrReceiveFailed is returned by Send when the receiving side failed to write the stream
+	ErrReceiveFailed = errors.New("receiving side failed")
+)
+
+// windowsReservedNames are the device names which cannot be used as file names on Windows, with
+// or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Send streams the directories, regular files and symbolic links under root to conn as a tar
+// archive with slash separated relative paths, whatever the OS it runs on, and waits for the
+// receiving side to report that every file was written. Other files, such as sockets, are skipped.
+func Send(conn io.ReadWriter, root string) error {
+	tw := tar.NewWriter(conn)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(rel),
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			Format:  tar.FormatPAX,
+		}
+		switch {
+		case info.IsDir():
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			return tw.WriteHeader(header)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = filepath.ToSlash(target)
+			return tw.WriteHeader(header)
+		case info.Mode().IsRegular():
+			header.Typeflag = tar.TypeReg
+			header.Size = info.Size()
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			return copyFile(tw, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("unable to read the status of the receiving side: %w", err)
+	}
+	if status = strings.TrimSuffix(status, "\n"); status != statusOK {
+		return fmt.Errorf("%w: %s", ErrReceiveFailed, status)
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Receive writes the tar archive streamed by Send from conn under root, and reports to the sending
+// side whether every file was written. Paths are converted to the separators of the OS it runs on.
+// Paths which cannot be written, such as absolute paths, paths escaping root or names which are
+// not valid on Windows when running on Windows, are skipped and reported in an error wrapping
+// ErrInvalidPath. Symbolic links are skipped on Windows, creating them requires a privilege.
+func Receive(conn io.ReadWriter, root string) error {
+	err := receive(conn, root, runtime.GOOS)
+	status := statusOK
+	if err != nil {
+		status = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+	if _, writeErr := io.WriteString(conn, status+"\n"); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func receive(r io.Reader, root, goos string) error {
+	tr := tar.NewReader(r)
+	invalid := []string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		path, err := localPath(root, header.Name, goos)
+		if err != nil {
+			invalid = append(invalid, header.Name)
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(tr, path, header); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if goos == "windows" {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return err
+			}
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	reported := invalid
+	if len(reported) > maxReportedPaths {
+		reported = reported[:maxReportedPaths]
+	}
+	return fmt.Errorf("%w: %d path(s) skipped: %s", ErrInvalidPath, len(invalid), strings.Join(reported, ", "))
+}
+
+func writeFile(r io.Reader, path string, header *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fs.FileMode(header.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, header.ModTime, header.ModTime)
+}
+
+// localPath returns the path under root of the slash separated relative path name of the stream,
+// validating every element of name for the given OS
+func localPath(root, name, goos string) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%w: %q is not a relative path", ErrInvalidPath, name)
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == "" || element == "." || element == ".." {
+			return "", fmt.Errorf("%w: %q is not a clean relative path", ErrInvalidPath, name)
+		}
+		if goos != "windows" {
+			continue
+		}
+		if err := validateWindowsName(element); err != nil {
+			return "", fmt.Errorf("%w: %q: %v", ErrInvalidPath, name, err)
+		}
+	}
+	return filepath.Join(root, filepath.FromSlash(name)), nil
+}
+
+// validateWindowsName returns why the given file name cannot be used on Windows, nil when it can
+func validateWindowsName(name string) error {
+	for _, r := range name {
+		if r < 32 || strings.ContainsRune(`<>:"\|?*`, r) {
+			return fmt.Errorf("name %q contains the character %q", name, r)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Errorf("name %q ends with a dot or a space", name)
+	}
+	base := strings.ToUpper(strings.TrimRight(strings.SplitN(name, ".", 2)[0], " "))
+	if windowsReservedNames[base] {
+		return fmt.Errorf("name %q is reserved", name)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package filestream
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSendReceive(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "pvc", "dir", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "pvc", "dir", "file.txt"), []byte("data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file.txt", filepath.Join(src, "pvc", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver := net.Pipe()
+	errs := make(chan error, 1)
+	go func() {
+		defer receiver.Close()
+		errs <- Receive(receiver, dest)
+	}()
+	if err := Send(sender, src); err != nil {
+		t.Fatalf("Send() unexpected error %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Receive() unexpected error %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "pvc", "dir", "file.txt"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("file received with %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "pvc", "dir", "file.txt")); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("file permissions not preserved: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "pvc", "dir", "empty")); err != nil || !info.IsDir() {
+		t.Errorf("empty directory not received: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "pvc", "link")); err != nil || target != "dir/file.txt" {
+		t.Errorf("symbolic link received as %q, %v", target, err)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		goos    string
+		wantErr bool
+	}{
+		{name: "relative", path: "pvc/dir/file.txt", goos: "linux"},
+		{name: "directory", path: "pvc/dir/", goos: "windows"},
+		{name: "absolute", path: "/etc/passwd", goos: "linux", wantErr: true},
+		{name: "escaping root", path: "pvc/../../etc", goos: "linux", wantErr: true},
+		{name: "colon on linux", path: "pvc/a:b", goos: "linux"},
+		{name: "colon on windows", path: "pvc/a:b", goos: "windows", wantErr: true},
+		{name: "backslash on windows", path: `pvc/a\..\..\b`, goos: "windows", wantErr: true},
+		{name: "reserved name on windows", path: "pvc/nul.txt", goos: "windows", wantErr: true},
+		{name: "trailing dot on windows", path: "pvc/file.", goos: "windows", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := localPath("root", tt.path, tt.goos)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("localPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("localPath() error = %v, want %v", err, ErrInvalidPath)
+			}
+		})
+	}
+}
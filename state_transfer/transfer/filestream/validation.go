@@ -0,0 +1,63 @@
+package filestream
+
+import (
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	validation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validatePVCList validates list of PVCs provided to file stream transfer
+// list must contain at least one pvc
+// source and destination pvcs must each be in a single namespace
+// labelSafeNames of the pvcs must be valid label values
+// pvcs must not have volumeMode: Block
+func validatePVCList(pvcList transfer.PVCPairList) error {
+	if len(pvcList) == 0 {
+		return fmt.Errorf("at least one pvc must be provided")
+	}
+	validationErrors := []error{}
+	if len(pvcList.GetSourceNamespaces()) != 1 {
+		validationErrors = append(validationErrors, fmt.Errorf("source pvcs must belong to a single namespace"))
+	}
+	if len(pvcList.GetDestinationNamespaces()) != 1 {
+		validationErrors = append(validationErrors, fmt.Errorf("destination pvcs must belong to a single namespace"))
+	}
+	for _, pair := range pvcList {
+		for _, pvc := range []transfer.PVC{pair.Source(), pair.Destination()} {
+			if errs := validation.IsValidLabelValue(pvc.LabelSafeName()); len(errs) > 0 {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("labelSafeName() for %s must be a valid label value", pvc.Claim().Name))
+			}
+			if transfer.IsBlock(pvc.Claim()) {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("pvc %s has volumeMode: Block, use the block transfer", pvc.Claim().Name))
+			}
+		}
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+// validateOptions validates the OS of the nodes, Windows pods can only run the containers of the
+// transfer
+func validateOptions(options *TransferOptions, t transport.Transport) error {
+	validationErrors := []error{}
+	windows := false
+	for _, os := range []string{osOrDefault(options.SourceOS), osOrDefault(options.DestinationOS)} {
+		switch os {
+		case OSLinux:
+		case OSWindows:
+			windows = true
+		default:
+			validationErrors = append(validationErrors, fmt.Errorf("unsupported os %s, must be %s or %s", os, OSLinux, OSWindows))
+		}
+	}
+	if windows && t != nil && t.Type() != null.TransportTypeNull {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("windows pods cannot run the linux containers of the %s transport, use the null transport", t.Type()))
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
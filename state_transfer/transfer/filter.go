@@ -0,0 +1,75 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Filter selects the files of the source volume of a PVC pair which are transferred, such as
+// skipping cache directories, lost+found or large temporary files. Patterns are globs matched
+// against paths relative to the root of the transferred directory, a leading / anchors a pattern to
+// that root and a trailing / only matches directories. A file matching an Include pattern is
+// transferred even when it matches an Exclude pattern.
+type Filter struct {
+	// Include are the patterns of files transferred regardless of Exclude
+	Include []string
+	// Exclude are the patterns of files which are not transferred
+	Exclude []string
+}
+
+// IsEmpty returns whether the filter selects every file
+func (f Filter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// FilterPVCPair is a PVCPair which only transfers the files selected by a filter
+type FilterPVCPair interface {
+	PVCPair
+	// Filter returns the filter of the files to transfer
+	Filter() Filter
+}
+
+// NewPVCPairWithFilter returns a copy of the given PVCPair, including its source path, which only
+// transfers the files selected by the given filter. The patterns are validated so that they can be
+// used safely in a transfer command.
+func NewPVCPairWithFilter(pair PVCPair, filter Filter) (PVCPair, error) {
+	errs := []error{}
+	for _, pattern := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		errs = append(errs, validateFilterPattern(pattern))
+	}
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return nil, err
+	}
+	return pvcPair{
+		src:        pair.Source(),
+		dest:       pair.Destination(),
+		sourcePath: GetSourcePath(pair),
+		filter:     &filter,
+	}, nil
+}
+
+// GetFilter returns the filter of the files the given PVCPair transfers, an empty filter means
+// every file is transferred
+func GetFilter(p PVCPair) Filter {
+	if f, ok := p.(FilterPVCPair); ok {
+		return f.Filter()
+	}
+	return Filter{}
+}
+
+// validateFilterPattern rejects empty patterns and patterns which cannot be used safely in a
+// transfer command
+func validateFilterPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("filter pattern cannot be empty")
+	}
+	if strings.ContainsAny(pattern, " \t\n\"'`$;&|<>()\\") {
+		return fmt.Errorf("filter pattern %q contains whitespace, quotes or shell meta characters", pattern)
+	}
+	if strings.Count(pattern, "[") != strings.Count(pattern, "]") {
+		return fmt.Errorf("filter pattern %q has unbalanced brackets", pattern)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	finalizePollInterval = 2 * time.Second
+)
+
+// FinalizeOptions configures the teardown done by FinalizeWithOptions
+type FinalizeOptions struct {
+	// TransportPrefix is the prefix given to the transport resources, as passed to transport.CreateServer
+	TransportPrefix string
+}
+
+// Finalize tears down everything the transfer created in the cluster c points at: the client and
+// the server, the transport and the endpoint resources, client pods left over from previous
+// attempts and the mark set by Cancel. It is meant to be called when the resource owning the transfer is deleted,
+// it only returns once every object is gone and is safe to call repeatedly. When the source and
+// the destination are different clusters, call it once with a client of each cluster.
+func Finalize(ctx context.Context, c client.Client, t Transfer) error {
+	return FinalizeWithOptions(ctx, c, t, FinalizeOptions{})
+}
+
+// FinalizeWithOptions tears down the transfer like Finalize. The objects to delete are found by
+// rendering the transfer like ExportManifests does, so the transport prefix must match the one
+// the transport was created with. Like DeleteServer and DeleteClient, it never deletes objects
+// without the owner label of the library, PVCs, or the Secrets shared through SecretPrefix.
+func FinalizeWithOptions(ctx context.Context, c client.Client, t Transfer, options FinalizeOptions) error {
+	errs := []error{DeleteClient(ctx, t, options.TransportPrefix), DeleteServer(ctx, t, options.TransportPrefix), ClearCancelled(ctx, c, t.PVCs())}
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return err
+	}
+	destination, source, err := render(ctx, t, options.TransportPrefix)
+	if err != nil {
+		return err
+	}
+	rendered := []unstructured.Unstructured{}
+	for _, rc := range []*meta.RenderClient{destination, source} {
+		objs, err := renderedObjects(rc)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, objs...)
+	}
+
+	deleted := map[string]bool{}
+	return wait.PollImmediateUntil(finalizePollInterval, func() (bool, error) {
+		found, err := findRenderedObjects(ctx, c, rendered)
+		if err != nil {
+			return false, err
+		}
+		remaining := deletableObjects(t, found)
+		errs := []error{}
+		for i := range remaining {
+			obj := &remaining[i]
+			key := objectKey(obj)
+			if deleted[key] || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground))
+			if err != nil && !k8serrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("unable to delete %s: %w", key, err))
+				continue
+			}
+			deleted[key] = true
+		}
+		return len(remaining) == 0, errorsutil.NewAggregate(errs)
+	}, ctx.Done())
+}
+
+// deleteOwnedObjects deletes the objects of the cluster c points at matching the objects rendered
+// in the client r, objects kept by deletableObjects are left alone
+func deleteOwnedObjects(ctx context.Context, c client.Client, t Transfer, r *meta.RenderClient) error {
+	rendered, err := renderedObjects(r)
+	if err != nil {
+		return err
+	}
+	found, err := findRenderedObjects(ctx, c, rendered)
+	if err != nil {
+		return err
+	}
+	deletable := deletableObjects(t, found)
+	errs := []error{}
+	for i := range deletable {
+		obj := &deletable[i]
+		if obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("unable to delete %s: %w", objectKey(obj), err))
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// findRenderedObjects returns the objects of the cluster matching the rendered objects of a
+// transfer. Objects created with a generated name are matched by their generate name and labels,
+// which also matches the client pods of previous attempts.
+func findRenderedObjects(ctx context.Context, c client.Client, rendered []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	found := []unstructured.Unstructured{}
+	seen := map[string]bool{}
+	for _, r := range rendered {
+		if r.GetGenerateName() == "" {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(r.GroupVersionKind())
+			err := c.Get(ctx, client.ObjectKey{Namespace: r.GetNamespace(), Name: r.GetName()}, obj)
+			switch {
+			case k8serrors.IsNotFound(err) || apimeta.IsNoMatchError(err):
+				// kinds unknown to the cluster, like Routes outside of OpenShift, have no objects
+				continue
+			case err != nil:
+				return nil, err
+			}
+			if !seen[objectKey(obj)] {
+				seen[objectKey(obj)] = true
+				found = append(found, *obj)
+			}
+			continue
+		}
+		if len(r.GetLabels()) == 0 {
+			// without labels, objects of other transfers would be matched as well
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(schema.GroupVersionKind{Group: r.GroupVersionKind().Group, Version: r.GroupVersionKind().Version, Kind: r.GetKind() + "List"})
+		err := c.List(ctx, list, client.InNamespace(r.GetNamespace()), client.MatchingLabels(r.GetLabels()))
+		if apimeta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range list.Items {
+			if !strings.HasPrefix(obj.GetName(), r.GetGenerateName()) || seen[objectKey(&obj)] {
+				continue
+			}
+			seen[objectKey(&obj)] = true
+			found = append(found, obj)
+		}
+	}
+	return found, nil
+}
+
+// deletableObjects returns the objects of a transfer which may be deleted when tearing it down.
+// Objects without the owner label of the library are kept, as well as PersistentVolumeClaims which
+// hold the data of the user, and the Secrets and Certificates shared with other transfers through
+// the SecretPrefix of the transport.
+func deletableObjects(t Transfer, objs []unstructured.Unstructured) []unstructured.Unstructured {
+	sharedPrefix := ""
+	if t.Transport() != nil && t.Transport().Options() != nil && t.Transport().Options().SecretPrefix != "" {
+		sharedPrefix = t.Transport().Options().SecretPrefix + "-"
+	}
+	deletable := []unstructured.Unstructured{}
+	for _, obj := range objs {
+		switch {
+		case !meta.IsOwned(obj.GetLabels()):
+		case obj.GroupVersionKind().GroupKind() == schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		case sharedPrefix != "" && strings.HasPrefix(obj.GetName(), sharedPrefix):
+		default:
+			deletable = append(deletable, obj)
+		}
+	}
+	return deletable
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
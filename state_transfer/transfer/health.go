@@ -0,0 +1,123 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	corev1 "k8s.io/api/core/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Component is a part of a transfer server which can be unhealthy on its own
+type Component string
+
+const (
+	// ComponentEndpoint is the endpoint exposing the server, e.g. a Route not admitted yet
+	ComponentEndpoint Component = "endpoint"
+	// ComponentTransport is the transport of the server, e.g. stunnel not up yet
+	ComponentTransport Component = "transport"
+	// ComponentServer is the transfer server itself, e.g. the rsync daemon not listening yet
+	ComponentServer Component = "server"
+)
+
+// ComponentError is the reason a component of a transfer server is unhealthy
+type ComponentError struct {
+	Component Component
+	Err       error
+}
+
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("%s is unhealthy: %v", e.Component, e.Err)
+}
+
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// Health is the health of every component of a transfer server
+type Health struct {
+	// Errors holds a ComponentError for every unhealthy component
+	Errors []*ComponentError
+}
+
+// Healthy returns whether all components are healthy
+func (h *Health) Healthy() bool {
+	return len(h.Errors) == 0
+}
+
+// Unhealthy returns the components which are unhealthy
+func (h *Health) Unhealthy() []Component {
+	components := []Component{}
+	for _, e := range h.Errors {
+		components = append(components, e.Component)
+	}
+	return components
+}
+
+// Err returns an aggregate of the errors of all unhealthy components, nil when healthy
+func (h *Health) Err() error {
+	errs := []error{}
+	for _, e := range h.Errors {
+		errs = append(errs, e)
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// Add records the given error for the component, nil errors are ignored
+func (h *Health) Add(component Component, err error) {
+	if err != nil {
+		h.Errors = append(h.Errors, &ComponentError{Component: component, Err: err})
+	}
+}
+
+// HealthChecker knows how to check the health of each component of a transfer server
+type HealthChecker interface {
+	// ServerHealth returns the health of the endpoint, the transport and the server
+	ServerHealth(ctx context.Context, c client.Client) (*Health, error)
+}
+
+// ServerHealth returns the health of each component of the transfer server. Transfers which do
+// not implement HealthChecker report the endpoint and the result of IsServerHealthy as the server.
+// An error is only returned when the health could not be determined.
+func ServerHealth(ctx context.Context, t Transfer, c client.Client) (*Health, error) {
+	if checker, ok := t.(HealthChecker); ok {
+		return checker.ServerHealth(ctx, c)
+	}
+	health := &Health{}
+	health.Add(ComponentEndpoint, EndpointHealth(ctx, t, c))
+	if healthy, err := t.IsServerHealthy(ctx, c); !healthy {
+		if err == nil {
+			err = fmt.Errorf("%w: server is not healthy", ErrPodNotReady)
+		}
+		health.Add(ComponentServer, err)
+	}
+	return health, nil
+}
+
+// EndpointHealth returns why the endpoint of the transfer is unhealthy, nil when it is healthy
+// or when the transfer has no endpoint
+func EndpointHealth(ctx context.Context, t Transfer, c client.Client) error {
+	if t.Endpoint() == nil {
+		return nil
+	}
+	healthy, err := t.Endpoint().IsHealthy(ctx, c)
+	if !healthy && err == nil {
+		err = fmt.Errorf("%w: endpoint %s is not healthy", endpoint.ErrEndpointNotReady, t.Endpoint().NamespacedName())
+	}
+	return err
+}
+
+// PodComponentHealth is a utility function that can be used by various implementations to
+// check the health of a server pod whose containers are grouped by component
+func PodComponentHealth(health *Health, pod *corev1.Pod, components map[Component][]string) {
+	for _, component := range []Component{ComponentEndpoint, ComponentTransport, ComponentServer} {
+		containers, ok := components[component]
+		if !ok || len(containers) == 0 {
+			continue
+		}
+		_, err := areContainersReady(pod, containers)
+		health.Add(component, err)
+	}
+}
@@ -0,0 +1,243 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultHookTimeout is the time Job and scale hooks wait for their Job or workload, when their
+	// Timeout is not set
+	DefaultHookTimeout = 10 * time.Minute
+	// DefaultHookPollInterval is the interval Job and scale hooks poll their Job or workload at,
+	// when their PollInterval is not set
+	DefaultHookPollInterval = 2 * time.Second
+
+	hookJobPrefix = "crane2-hook-"
+)
+
+// ErrHookFailed is returned when a hook run by RunWithHooks fails
+var ErrHookFailed = errors.New("transfer hook failed")
+
+// HookAction is the action of a hook, run against the source or the destination of a transfer.
+// PreTransferHook, JobHook and ScaleHook are provided, callers can implement their own.
+type HookAction interface {
+	Run(ctx context.Context, t Transfer) error
+}
+
+// Hook is a named action run before or after a transfer
+type Hook struct {
+	// Name identifies the hook in errors
+	Name string
+	// Action is what the hook does
+	Action HookAction
+}
+
+// Hooks are the actions run around a transfer by RunWithHooks
+type Hooks struct {
+	// Before hooks are run in order before the client is created, the transfer does not start when
+	// one of them fails
+	Before []Hook
+	// After hooks are run in order once the client succeeded, e.g. to fix the permissions of the
+	// destination
+	After []Hook
+	// Finally hooks are run in order once the before hooks succeeded, after the after hooks and
+	// whatever the outcome of the transfer, e.g. to scale the source workloads scaled down by a
+	// before hook back up
+	Finally []Hook
+}
+
+// RunWithHooks runs the before hooks, the pre-transfer hook of the transfer itself, see
+// PreTransfer, and the client of the transfer until it completes like RunClientWithRetries, then
+// the after and the finally hooks. The server must have been created beforehand. Errors of
+// hooks wrap ErrHookFailed, the errors of the finally hooks are aggregated with the error of the
+// transfer.
+func RunWithHooks(ctx context.Context, t Transfer, hooks Hooks, interval time.Duration) (*Status, error) {
+	if err := runHooks(ctx, t, "before", hooks.Before); err != nil {
+		return nil, err
+	}
+	status, err := runWithHooks(ctx, t, hooks, interval)
+	errs := []error{err, runHooks(ctx, t, "finally", hooks.Finally)}
+	return status, errorsutil.NewAggregate(errs)
+}
+
+func runWithHooks(ctx context.Context, t Transfer, hooks Hooks, interval time.Duration) (*Status, error) {
+	if err := PreTransfer(ctx, t); err != nil {
+		return nil, err
+	}
+	status, err := RunClientWithRetries(ctx, t, interval)
+	if err != nil {
+		return status, err
+	}
+	return status, runHooks(ctx, t, "after", hooks.After)
+}
+
+// runHooks runs the given hooks in order and stops at the first failure
+func runHooks(ctx context.Context, t Transfer, phase string, hooks []Hook) error {
+	for _, hook := range hooks {
+		if hook.Action == nil {
+			return fmt.Errorf("%w: %s hook %s has no action", ErrHookFailed, phase, hook.Name)
+		}
+		if err := hook.Action.Run(ctx, t); err != nil {
+			return fmt.Errorf("%w: %s hook %s: %v", ErrHookFailed, phase, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// hookClient returns the client of the side of the transfer a hook runs against
+func hookClient(t Transfer, destination bool) client.Client {
+	if destination {
+		return t.Destination()
+	}
+	return t.Source()
+}
+
+// hookNamespaces returns the namespaces of the PVCs of the side of the transfer a hook runs against
+func hookNamespaces(t Transfer, destination bool) []string {
+	if destination {
+		return t.PVCs().GetDestinationNamespaces()
+	}
+	return t.PVCs().GetSourceNamespaces()
+}
+
+// JobHook runs a Job and waits for it to complete, such as fixing the permissions of the
+// destination once the data was transferred. It requires the create and get verbs on jobs.
+type JobHook struct {
+	// Job is the Job to create, it is created in the first namespace of the transfer when it has no
+	// namespace and with a generated name when it has no name. The Job is kept once complete, set
+	// its TTLSecondsAfterFinished to delete it.
+	Job *batchv1.Job
+	// Destination creates the Job in the destination cluster instead of the source cluster
+	Destination bool
+	// Timeout is the time the Job is given to complete, defaults to DefaultHookTimeout
+	Timeout time.Duration
+	// PollInterval is the interval the Job is polled at, defaults to DefaultHookPollInterval
+	PollInterval time.Duration
+}
+
+// Run creates the Job and waits for it to complete, it fails when the Job fails
+func (j JobHook) Run(ctx context.Context, t Transfer) error {
+	if j.Job == nil {
+		return fmt.Errorf("job hook requires a job")
+	}
+	c := hookClient(t, j.Destination)
+	job := j.Job.DeepCopy()
+	if job.Namespace == "" {
+		job.Namespace = hookNamespaces(t, j.Destination)[0]
+	}
+	if job.Name == "" && job.GenerateName == "" {
+		job.GenerateName = hookJobPrefix
+	}
+	job.Labels = meta.WithOwnerLabel(job.Labels)
+	if err := c.Create(ctx, job, &client.CreateOptions{}); err != nil {
+		return err
+	}
+	key := client.ObjectKeyFromObject(job)
+	err := pollHook(ctx, j.PollInterval, j.Timeout, func() (bool, error) {
+		if err := c.Get(ctx, key, job); err != nil {
+			return false, err
+		}
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				return false, fmt.Errorf("job %s failed: %s", key, condition.Message)
+			}
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("job %s did not complete in time", key)
+	}
+	return err
+}
+
+// ScaleHook scales a Deployment or a StatefulSet and waits for its pods to be scaled, such as
+// scaling the source workloads down before the transfer so that the data no longer changes. It
+// requires the get and patch verbs on the workload.
+type ScaleHook struct {
+	// Kind is Deployment or StatefulSet
+	Kind string
+	// Workload is the workload to scale
+	Workload types.NamespacedName
+	// Replicas is the number of replicas to scale the workload to
+	Replicas int32
+	// Destination scales the workload in the destination cluster instead of the source cluster
+	Destination bool
+	// Timeout is the time the workload is given to be scaled, defaults to DefaultHookTimeout
+	Timeout time.Duration
+	// PollInterval is the interval the workload is polled at, defaults to DefaultHookPollInterval
+	PollInterval time.Duration
+}
+
+// Run scales the workload and waits until all its replicas are scaled and ready
+func (s ScaleHook) Run(ctx context.Context, t Transfer) error {
+	c := hookClient(t, s.Destination)
+	var obj client.Object
+	var replicas func() (current int32, ready int32)
+	switch s.Kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		obj = deployment
+		replicas = func() (int32, int32) { return deployment.Status.Replicas, deployment.Status.ReadyReplicas }
+	case "StatefulSet":
+		statefulSet := &appsv1.StatefulSet{}
+		obj = statefulSet
+		replicas = func() (int32, int32) { return statefulSet.Status.Replicas, statefulSet.Status.ReadyReplicas }
+	default:
+		return fmt.Errorf("unsupported workload kind %q", s.Kind)
+	}
+	if err := c.Get(ctx, s.Workload, obj); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		workload.Spec.Replicas = &s.Replicas
+	case *appsv1.StatefulSet:
+		workload.Spec.Replicas = &s.Replicas
+	}
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	err := pollHook(ctx, s.PollInterval, s.Timeout, func() (bool, error) {
+		if err := c.Get(ctx, s.Workload, obj); err != nil {
+			return false, err
+		}
+		current, ready := replicas()
+		return current == s.Replicas && ready == s.Replicas, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("%s %s was not scaled to %d replicas in time", s.Kind, s.Workload, s.Replicas)
+	}
+	return err
+}
+
+// pollHook polls the given condition with the interval and the timeout of a hook, or their defaults
+func pollHook(ctx context.Context, interval time.Duration, timeout time.Duration, condition wait.ConditionFunc) error {
+	if interval <= 0 {
+		interval = DefaultHookPollInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return wait.PollImmediateUntil(interval, condition, ctx.Done())
+}
@@ -0,0 +1,231 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// hookTransfer records when its client is created and completes with the given phase
+type hookTransfer struct {
+	source, destination client.Client
+	pvcs                PVCPairList
+	phase               TransferPhase
+	events              *[]string
+}
+
+func (h *hookTransfer) Source() client.Client                             { return h.source }
+func (h *hookTransfer) Destination() client.Client                        { return h.destination }
+func (h *hookTransfer) Endpoint() endpoint.Endpoint                       { return nil }
+func (h *hookTransfer) Transport() transport.Transport                    { return nil }
+func (h *hookTransfer) CreateServer(context.Context, client.Client) error { return nil }
+func (h *hookTransfer) IsServerHealthy(context.Context, client.Client) (bool, error) {
+	return true, nil
+}
+func (h *hookTransfer) PVCs() PVCPairList { return h.pvcs }
+
+func (h *hookTransfer) CreateClient(context.Context, client.Client) error {
+	*h.events = append(*h.events, "client")
+	return nil
+}
+
+func (h *hookTransfer) Status(context.Context, client.Client) (*Status, error) {
+	return &Status{Phase: h.phase, Attempts: 1}, nil
+}
+
+// recordHook records that it ran, and fails when err is set
+type recordHook struct {
+	name   string
+	events *[]string
+	err    error
+}
+
+func (r recordHook) Run(context.Context, Transfer) error {
+	*r.events = append(*r.events, r.name)
+	return r.err
+}
+
+func TestRunWithHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		phase      TransferPhase
+		preErr     error
+		wantEvents []string
+		wantErr    error
+	}{
+		{
+			name:       "succeeded",
+			phase:      TransferPhaseSucceeded,
+			wantEvents: []string{"flush", "scale-down", "client", "fix-permissions", "scale-up"},
+		},
+		{
+			name:       "transfer failed",
+			phase:      TransferPhaseFailed,
+			wantEvents: []string{"flush", "scale-down", "client", "scale-up"},
+			wantErr:    ErrTransferFailed,
+		},
+		{
+			name:       "pre-transfer hook failed",
+			phase:      TransferPhaseSucceeded,
+			preErr:     fmt.Errorf("database is read only"),
+			wantEvents: []string{"flush"},
+			wantErr:    ErrHookFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []string{}
+			tr := &hookTransfer{phase: tt.phase, events: &events}
+			hooks := Hooks{
+				Before: []Hook{
+					{Name: "flush", Action: recordHook{name: "flush", events: &events, err: tt.preErr}},
+					{Name: "scale-down", Action: recordHook{name: "scale-down", events: &events}},
+				},
+				After:   []Hook{{Name: "fix-permissions", Action: recordHook{name: "fix-permissions", events: &events}}},
+				Finally: []Hook{{Name: "scale-up", Action: recordHook{name: "scale-up", events: &events}}},
+			}
+			_, err := RunWithHooks(context.TODO(), tr, hooks, time.Millisecond)
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("RunWithHooks() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("RunWithHooks() ran %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+type hookExecutor struct {
+	pods []string
+}
+
+func (h *hookExecutor) Exec(ctx context.Context, pod types.NamespacedName, container string, command []string) (string, string, error) {
+	h.pods = append(h.pods, pod.Name+"/"+container)
+	return "", "", nil
+}
+
+func TestPreTransferHookAction(t *testing.T) {
+	claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"}}
+	newPod := func(name string, app string, claimName string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "sidecar"},
+				{Name: "db", VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/db"}}},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if claimName != "" {
+			pod.Spec.Volumes = []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			}}}
+		}
+		return pod
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newPod("db-0", "db", "data"),
+		newPod("web-0", "web", ""),
+		newPod("other-0", "other", "other"),
+	).Build()
+	tr := &hookTransfer{source: c, pvcs: PVCPairList{NewPVCPair(claim, nil)}}
+
+	executor := &hookExecutor{}
+	hooks := Hooks{Before: []Hook{{Name: "flush", Action: &PreTransferHook{Executor: executor, Command: []string{"sync"}}}}}
+	if err := runHooks(context.TODO(), tr, "before", hooks.Before); err != nil {
+		t.Fatalf("runHooks() unexpected error %v", err)
+	}
+	if want := []string{"db-0/db"}; !reflect.DeepEqual(executor.pods, want) {
+		t.Errorf("command ran in %v, want the pods mounting the pvcs %v", executor.pods, want)
+	}
+}
+
+func TestJobHook(t *testing.T) {
+	claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"}}
+	for _, failed := range []bool{false, true} {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		tr := &hookTransfer{destination: c, pvcs: PVCPairList{NewPVCPair(claim, nil)}}
+		ctx, cancel := context.WithCancel(context.TODO())
+		go completeJobs(ctx, c, failed)
+		hook := JobHook{
+			Job:          &batchv1.Job{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{}}},
+			Destination:  true,
+			PollInterval: time.Millisecond,
+			Timeout:      5 * time.Second,
+		}
+		err := hook.Run(context.TODO(), tr)
+		cancel()
+		if (err != nil) != failed {
+			t.Errorf("Run() error = %v, job failed %v", err, failed)
+		}
+		jobs := &batchv1.JobList{}
+		if err := c.List(context.TODO(), jobs, client.InNamespace("ns")); err != nil || len(jobs.Items) != 1 {
+			t.Fatalf("expected the job in the destination namespace: %v", err)
+		}
+	}
+}
+
+// completeJobs sets the Complete or the Failed condition of every job
+func completeJobs(ctx context.Context, c client.Client, failed bool) {
+	for ctx.Err() == nil {
+		jobs := &batchv1.JobList{}
+		if err := c.List(ctx, jobs); err == nil {
+			for i := range jobs.Items {
+				condition := batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}
+				if failed {
+					condition = batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}
+				}
+				jobs.Items[i].Status.Conditions = []batchv1.JobCondition{condition}
+				_ = c.Update(ctx, &jobs.Items[i])
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScaleHook(t *testing.T) {
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "db"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 3, ReadyReplicas: 3},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).Build()
+	tr := &hookTransfer{source: c}
+	hook := ScaleHook{
+		Kind:         "Deployment",
+		Workload:     types.NamespacedName{Namespace: "ns", Name: "db"},
+		PollInterval: time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+	}
+	if err := hook.Run(context.TODO(), tr); err == nil {
+		t.Errorf("Run() should time out while the replicas are not scaled down")
+	}
+	if err := c.Get(context.TODO(), hook.Workload, deployment); err != nil || *deployment.Spec.Replicas != 0 {
+		t.Errorf("deployment should be scaled to 0 replicas: %v", err)
+	}
+
+	deployment.Status = appsv1.DeploymentStatus{}
+	if err := c.Update(context.TODO(), deployment); err != nil {
+		t.Fatalf("unable to update deployment: %v", err)
+	}
+	if err := hook.Run(context.TODO(), tr); err != nil {
+		t.Errorf("Run() unexpected error %v", err)
+	}
+	if err := (ScaleHook{Kind: "DaemonSet", Workload: hook.Workload}).Run(context.TODO(), tr); err == nil {
+		t.Errorf("Run() should not scale a DaemonSet")
+	}
+}
@@ -0,0 +1,137 @@
+package kubevirt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ContentTypeAnnotation is set by CDI on the PVCs of DataVolumes, ContentTypeKubeVirt marks
+	// PVCs holding a VM disk image
+	ContentTypeAnnotation = "cdi.kubevirt.io/storage.contentType"
+	ContentTypeKubeVirt   = "kubevirt"
+	// PrePopulatedAnnotation makes CDI adopt an existing PVC as the DataVolume it names without
+	// importing data into it
+	PrePopulatedAnnotation = "cdi.kubevirt.io/storage.prePopulated"
+)
+
+// DataVolumeGroupVersion is the version of the CDI API the DataVolumes are read and created with
+var DataVolumeGroupVersion = schema.GroupVersion{Group: "cdi.kubevirt.io", Version: "v1beta1"}
+
+// PVCPairsFromDataVolumes returns the pairs transferring the PVCs of the given DataVolumes of the
+// source cluster to PVCs of the same name in the destination namespace. The destination PVCs are
+// not created, see CreateDestinationPVCs. They request the capacity of the source PVC and use its
+// storage class unless storageClassName is set.
+func PVCPairsFromDataVolumes(ctx context.Context, c client.Client, dataVolumes []types.NamespacedName, destNamespace, storageClassName string) (transfer.PVCPairList, error) {
+	pvcList := transfer.PVCPairList{}
+	for _, dv := range dataVolumes {
+		if err := c.Get(ctx, dv, newDataVolume()); err != nil {
+			return nil, fmt.Errorf("unable to get data volume %s: %w", dv, err)
+		}
+		// the PVC of a DataVolume has its name
+		source := &corev1.PersistentVolumeClaim{}
+		if err := c.Get(ctx, dv, source); err != nil {
+			return nil, fmt.Errorf("unable to get pvc of data volume %s: %w", dv, err)
+		}
+		pvcList = append(pvcList, transfer.NewPVCPair(source, destinationPVC(source, dv.Name, destNamespace, storageClassName)))
+	}
+	return pvcList, nil
+}
+
+func destinationPVC(source *corev1.PersistentVolumeClaim, dataVolume, namespace, storageClassName string) *corev1.PersistentVolumeClaim {
+	size, ok := source.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		size = source.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+	dest := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      source.Name,
+			Labels:    source.Labels,
+			Annotations: map[string]string{
+				ContentTypeAnnotation:  ContentTypeKubeVirt,
+				PrePopulatedAnnotation: dataVolume,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      source.Spec.AccessModes,
+			StorageClassName: source.Spec.StorageClassName,
+			VolumeMode:       source.Spec.VolumeMode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if storageClassName != "" {
+		dest.Spec.StorageClassName = &storageClassName
+	}
+	return dest
+}
+
+// CreateDestinationPVCs creates the destination PVCs of the list, existing PVCs are left as they
+// are. It must be called before the transfer server is created.
+func CreateDestinationPVCs(ctx context.Context, c client.Client, pvcList transfer.PVCPairList) error {
+	errs := []error{}
+	for _, pvc := range pvcList {
+		dest := pvc.Destination().Claim().DeepCopy()
+		dest.ResourceVersion = ""
+		err := c.Create(ctx, dest, &client.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// CreateDestinationDataVolumes creates a DataVolume for every destination PVC of the list once the
+// disks were transferred, with the spec of the DataVolume of the source PVC but a blank source.
+// CDI adopts the transferred PVC instead of importing the disk again, so that virtual machines
+// can reference the DataVolume in the destination cluster.
+func CreateDestinationDataVolumes(ctx context.Context, src client.Client, dest client.Client, pvcList transfer.PVCPairList) error {
+	errs := []error{}
+	for _, pvc := range pvcList {
+		source := newDataVolume()
+		key := client.ObjectKey{Namespace: pvc.Source().Claim().Namespace, Name: pvc.Source().Claim().Name}
+		if err := src.Get(ctx, key, source); err != nil {
+			errs = append(errs, fmt.Errorf("unable to get data volume %s: %w", key, err))
+			continue
+		}
+		spec, _, err := unstructured.NestedMap(source.Object, "spec")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(spec, "sourceRef")
+		spec["source"] = map[string]interface{}{"blank": map[string]interface{}{}}
+
+		dataVolume := newDataVolume()
+		dataVolume.SetNamespace(pvc.Destination().Claim().Namespace)
+		dataVolume.SetName(pvc.Destination().Claim().Name)
+		dataVolume.SetLabels(source.GetLabels())
+		if err := unstructured.SetNestedMap(dataVolume.Object, spec, "spec"); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = dest.Create(ctx, dataVolume, &client.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func newDataVolume() *unstructured.Unstructured {
+	dataVolume := &unstructured.Unstructured{}
+	dataVolume.SetGroupVersionKind(DataVolumeGroupVersion.WithKind("DataVolume"))
+	return dataVolume
+}
@@ -0,0 +1,67 @@
+package kubevirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ComputeContainer is the container of virt-launcher pods running the virtual machine
+	ComputeContainer = "compute"
+
+	virtLauncherLabel      = "kubevirt.io"
+	virtLauncherLabelValue = "virt-launcher"
+	vmiNameLabel           = "vm.kubevirt.io/name"
+	virtFreezer            = "/usr/bin/virt-freezer"
+)
+
+// ErrFreezeFailed is returned when the filesystems of a virtual machine could not be frozen or thawed
+var ErrFreezeFailed = errors.New("virtual machine freeze failed")
+
+// Freeze freezes the filesystems of the guest of the given VirtualMachineInstance with virt-freezer
+// in its virt-launcher pod, which requires the guest agent. The guest is thawed by KubeVirt after
+// a timeout of 5 minutes if Thaw is not called. The executor needs the create verb on pods/exec
+// and the list verb on pods in the namespace of the VirtualMachineInstance.
+func Freeze(ctx context.Context, c client.Client, executor transfer.PodExecutor, vmi types.NamespacedName) error {
+	return runVirtFreezer(ctx, c, executor, vmi, "--freeze")
+}
+
+// Thaw thaws the filesystems of the guest of the given VirtualMachineInstance frozen by Freeze
+func Thaw(ctx context.Context, c client.Client, executor transfer.PodExecutor, vmi types.NamespacedName) error {
+	return runVirtFreezer(ctx, c, executor, vmi, "--unfreeze")
+}
+
+func runVirtFreezer(ctx context.Context, c client.Client, executor transfer.PodExecutor, vmi types.NamespacedName, action string) error {
+	pod, err := launcherPod(ctx, c, vmi)
+	if err != nil {
+		return err
+	}
+	command := []string{virtFreezer, action, "--name", vmi.Name, "--namespace", vmi.Namespace}
+	_, stderr, err := executor.Exec(ctx, pod, ComputeContainer, command)
+	if err != nil {
+		return fmt.Errorf("%w: %s of %s: %v: %s", ErrFreezeFailed, action, vmi, err, stderr)
+	}
+	return nil
+}
+
+// launcherPod returns the running virt-launcher pod of the given VirtualMachineInstance
+func launcherPod(ctx context.Context, c client.Client, vmi types.NamespacedName) (types.NamespacedName, error) {
+	pods := &corev1.PodList{}
+	err := c.List(ctx, pods, client.InNamespace(vmi.Namespace),
+		client.MatchingLabels{virtLauncherLabel: virtLauncherLabelValue, vmiNameLabel: vmi.Name})
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			return types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, nil
+		}
+	}
+	return types.NamespacedName{}, fmt.Errorf("%w: no running virt-launcher pod for %s", ErrFreezeFailed, vmi)
+}
@@ -0,0 +1,200 @@
+package kubevirt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transfer/blockrsync"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultFreezeTimeout is the time the snapshots are waited for while the guest is frozen, it
+	// matches the time after which KubeVirt thaws a frozen guest
+	DefaultFreezeTimeout = 5 * time.Minute
+
+	snapshotPollInterval = 2 * time.Second
+)
+
+// TransferOptions are the options of a KubeVirt disk transfer
+type TransferOptions struct {
+	// Blockrsync are the options of the blockrsync transfer copying the disk
+	Blockrsync *blockrsync.TransferOptions
+	// VirtualMachineInstance is the running virtual machine the source disk is attached to. When
+	// set, the disk is copied from the clone of a CSI snapshot taken in PreTransfer while the
+	// filesystems of the guest are frozen, so that the copy is consistent while the virtual machine
+	// keeps running. The disk of a stopped virtual machine is copied directly when not set.
+	VirtualMachineInstance *types.NamespacedName
+	// Executor runs virt-freezer in the virt-launcher pod, it is required with VirtualMachineInstance
+	Executor transfer.PodExecutor
+	// Snapshot configures the snapshot and the clone of the source disk taken with
+	// VirtualMachineInstance
+	Snapshot transfer.SnapshotSource
+	// FreezeTimeout is the time the snapshot is waited for while the guest is frozen, defaults to
+	// DefaultFreezeTimeout
+	FreezeTimeout time.Duration
+}
+
+// KubeVirtTransfer copies the raw disk image of a KubeVirt virtual machine, the disk.img file of a
+// Filesystem DataVolume or the device of a Block DataVolume, with blockrsync over the transport.
+// See PVCPairsFromDataVolumes to transfer DataVolumes, and CreateDestinationDataVolumes to adopt the
+// transferred disks once done.
+type KubeVirtTransfer struct {
+	// Transfer is the blockrsync transfer of the disk, or of its clone with VirtualMachineInstance
+	transfer.Transfer
+	pvcList transfer.PVCPairList
+	options *TransferOptions
+	log     logr.Logger
+}
+
+// NewTransfer returns a transfer of the disk of the given pair, one disk is transferred per
+// transfer like with blockrsync
+func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger, options *TransferOptions) (transfer.Transfer, error) {
+	if options == nil {
+		options = &TransferOptions{}
+	}
+	if err := validate(pvcList, options); err != nil {
+		return nil, err
+	}
+	log = meta.LoggerOrDiscard(log).WithValues("transfer", "kubevirt")
+
+	disks := pvcList
+	if options.VirtualMachineInstance != nil {
+		disks = transfer.PVCPairList{}
+		for _, pvc := range pvcList {
+			disks = append(disks, transfer.NewPVCPair(cloneClaim(pvc.Source()), pvc.Destination().Claim()))
+		}
+	}
+	blockrsyncOptions := &blockrsync.TransferOptions{}
+	if options.Blockrsync != nil {
+		copied := *options.Blockrsync
+		blockrsyncOptions = &copied
+	}
+	blockrsyncTransfer, err := blockrsync.NewTransfer(t, e, src, dest, disks, log, blockrsyncOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &KubeVirtTransfer{
+		Transfer: blockrsyncTransfer,
+		pvcList:  pvcList,
+		options:  options,
+		log:      log,
+	}, nil
+}
+
+func validate(pvcList transfer.PVCPairList, options *TransferOptions) error {
+	validationErrors := []error{}
+	if len(pvcList) != 1 {
+		validationErrors = append(validationErrors, fmt.Errorf("exactly one disk must be provided"))
+	}
+	for _, pvc := range pvcList {
+		for _, claim := range []*corev1.PersistentVolumeClaim{pvc.Source().Claim(), pvc.Destination().Claim()} {
+			if !transfer.IsBlock(claim) && claim.Annotations[ContentTypeAnnotation] != ContentTypeKubeVirt {
+				validationErrors = append(validationErrors, fmt.Errorf("pvc %s is not a block or VM disk volume", claim.Name))
+			}
+		}
+	}
+	if options.VirtualMachineInstance != nil && options.Executor == nil {
+		validationErrors = append(validationErrors, fmt.Errorf("freezing virtual machine %s requires an executor", options.VirtualMachineInstance))
+	}
+	if options.FreezeTimeout < 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("freeze timeout must not be negative"))
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+// cloneClaim returns the claim of the clone of the given source disk, see transfer.SnapshotClaimName
+func cloneClaim(pvc transfer.PVC) *corev1.PersistentVolumeClaim {
+	clone := pvc.Claim().DeepCopy()
+	clone.Name = transfer.SnapshotClaimName(pvc)
+	return clone
+}
+
+// PVCs returns the disks of the virtual machine, not their clones
+func (k *KubeVirtTransfer) PVCs() transfer.PVCPairList {
+	return k.pvcList
+}
+
+// Copy returns a copy of the transfer wrapping a copy of the blockrsync transfer, see
+// transfer.Copier
+func (k *KubeVirtTransfer) Copy() transfer.Transfer {
+	copied := *k
+	copied.Transfer = transfer.Copy(k.Transfer)
+	return &copied
+}
+
+// PreTransfer freezes the guest of the VirtualMachineInstance, takes a CSI snapshot of the source
+// disk and provisions its clone, and thaws the guest once the snapshot was taken. It must be called
+// before CreateClient, see transfer.PreTransfer. It is a no-op without VirtualMachineInstance.
+func (k *KubeVirtTransfer) PreTransfer(ctx context.Context, c client.Client) error {
+	vmi := k.options.VirtualMachineInstance
+	if vmi == nil {
+		return nil
+	}
+	log := k.log.WithValues("namespace", vmi.Namespace, "vmi", vmi.Name)
+	if err := Freeze(ctx, c, k.options.Executor, *vmi); err != nil {
+		return err
+	}
+	log.Info("froze virtual machine")
+	errs := []error{k.snapshot(ctx, c)}
+	errs = append(errs, Thaw(ctx, c, k.options.Executor, *vmi))
+	err := errorsutil.NewAggregate(errs)
+	if err != nil {
+		log.Error(err, "unable to snapshot virtual machine disk")
+		return err
+	}
+	log.Info("thawed virtual machine")
+	return nil
+}
+
+// snapshot takes the snapshot of the source disk and waits until it was taken, the clone is
+// provisioned once the snapshot is ready to use while the guest is running again
+func (k *KubeVirtTransfer) snapshot(ctx context.Context, c client.Client) error {
+	if err := transfer.CreateSnapshotSources(ctx, c, &k.options.Snapshot, k.pvcList); err != nil {
+		return err
+	}
+	timeout := k.options.FreezeTimeout
+	if timeout == 0 {
+		timeout = DefaultFreezeTimeout
+	}
+	err := wait.PollImmediate(snapshotPollInterval, timeout, func() (bool, error) {
+		return snapshotsTaken(ctx, c, k.pvcList)
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot of the disk was not taken: %w", err)
+	}
+	return nil
+}
+
+// snapshotsTaken returns whether the snapshots of the source disks were taken, the snapshot
+// controller sets their creation time once the CSI driver has cut the snapshot
+func snapshotsTaken(ctx context.Context, c client.Client, pvcList transfer.PVCPairList) (bool, error) {
+	for _, pvc := range pvcList {
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(transfer.VolumeSnapshotGroupVersion.WithKind("VolumeSnapshot"))
+		key := client.ObjectKey{Namespace: pvc.Source().Claim().Namespace, Name: transfer.SnapshotName(pvc.Source())}
+		if err := c.Get(ctx, key, snapshot); err != nil {
+			return false, err
+		}
+		if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+			return false, fmt.Errorf("%w: snapshot %s: %s", transfer.ErrSnapshotFailed, key, message)
+		}
+		_, taken, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime")
+		ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+		if !taken && !ready {
+			return false, nil
+		}
+	}
+	return true, nil
+}
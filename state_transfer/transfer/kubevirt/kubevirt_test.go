@@ -0,0 +1,281 @@
+package kubevirt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testDiskName      = "rootdisk"
+	testVMIName       = "vm"
+)
+
+func TestDataVolumes(t *testing.T) {
+	src, dest := buildTestClient(testDataVolume(), testDisk()), buildTestClient()
+	pvcList, err := PVCPairsFromDataVolumes(context.TODO(), src,
+		[]types.NamespacedName{{Namespace: testNamespace, Name: testDiskName}}, testDestNamespace, "fast")
+	if err != nil {
+		t.Fatalf("PVCPairsFromDataVolumes() unexpected error %v", err)
+	}
+	if err := CreateDestinationPVCs(context.TODO(), dest, pvcList); err != nil {
+		t.Fatalf("CreateDestinationPVCs() unexpected error %v", err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: testDiskName}, pvc); err != nil {
+		t.Fatalf("unable to get destination pvc: %v", err)
+	}
+	if pvc.Annotations[PrePopulatedAnnotation] != testDiskName || pvc.Annotations[ContentTypeAnnotation] != ContentTypeKubeVirt {
+		t.Errorf("destination pvc should be a pre-populated VM disk, annotations %v", pvc.Annotations)
+	}
+	if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != "10Gi" || *pvc.Spec.StorageClassName != "fast" {
+		t.Errorf("destination pvc requests %s of class %s, want 10Gi of class fast", size.String(), *pvc.Spec.StorageClassName)
+	}
+
+	if err := CreateDestinationDataVolumes(context.TODO(), src, dest, pvcList); err != nil {
+		t.Fatalf("CreateDestinationDataVolumes() unexpected error %v", err)
+	}
+	dataVolume := newDataVolume()
+	if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: testDiskName}, dataVolume); err != nil {
+		t.Fatalf("unable to get destination data volume: %v", err)
+	}
+	source, _, _ := unstructured.NestedMap(dataVolume.Object, "spec", "source")
+	if _, blank := source["blank"]; !blank || len(source) != 1 {
+		t.Errorf("destination data volume should have a blank source, got %v", source)
+	}
+	if size, _, _ := unstructured.NestedString(dataVolume.Object, "spec", "pvc", "resources", "requests", "storage"); size != "10Gi" {
+		t.Errorf("destination data volume should keep the spec of the source, got size %q", size)
+	}
+}
+
+func TestNewTransferValidation(t *testing.T) {
+	filesystem := testDisk()
+	delete(filesystem.Annotations, ContentTypeAnnotation)
+	tests := []struct {
+		name    string
+		pvcList transfer.PVCPairList
+		options *TransferOptions
+		wantErr bool
+	}{
+		{
+			name:    "disk",
+			pvcList: transfer.PVCPairList{transfer.NewPVCPair(testDisk(), nil)},
+		},
+		{
+			name:    "not a disk",
+			pvcList: transfer.PVCPairList{transfer.NewPVCPair(filesystem, nil)},
+			wantErr: true,
+		},
+		{
+			name:    "several disks",
+			pvcList: transfer.PVCPairList{transfer.NewPVCPair(testDisk(), nil), transfer.NewPVCPair(testDisk(), nil)},
+			wantErr: true,
+		},
+		{
+			name:    "freeze without executor",
+			pvcList: transfer.PVCPairList{transfer.NewPVCPair(testDisk(), nil)},
+			options: &TransferOptions{VirtualMachineInstance: &types.NamespacedName{Namespace: testNamespace, Name: testVMIName}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTransfer(testTransport(), testEndpoint(), buildTestClient(), buildTestClient(), tt.pvcList, nil, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTransfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreTransfer(t *testing.T) {
+	pvcList := transfer.PVCPairList{transfer.NewPVCPair(testDisk(), nil)}
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(transfer.VolumeSnapshotGroupVersion.WithKind("VolumeSnapshot"))
+	snapshot.SetNamespace(testNamespace)
+	snapshot.SetName(transfer.SnapshotName(pvcList[0].Source()))
+	_ = unstructured.SetNestedField(snapshot.Object, "2022-01-01T00:00:00Z", "status", "creationTime")
+
+	tests := []struct {
+		name      string
+		objects   []client.Object
+		failWith  string
+		wantErr   error
+		wantCalls []string
+	}{
+		{
+			name:    "snapshot taken",
+			objects: []client.Object{testLauncherPod(), snapshot.DeepCopy()},
+			wantCalls: []string{
+				"virt-launcher-vm-abcde/compute: /usr/bin/virt-freezer --freeze --name vm --namespace test-namespace",
+				"virt-launcher-vm-abcde/compute: /usr/bin/virt-freezer --unfreeze --name vm --namespace test-namespace",
+			},
+		},
+		{
+			name:    "snapshot not taken",
+			objects: []client.Object{testLauncherPod()},
+			wantErr: errors.New("timed out"),
+			wantCalls: []string{
+				"virt-launcher-vm-abcde/compute: /usr/bin/virt-freezer --freeze --name vm --namespace test-namespace",
+				"virt-launcher-vm-abcde/compute: /usr/bin/virt-freezer --unfreeze --name vm --namespace test-namespace",
+			},
+		},
+		{
+			name:     "freeze failed",
+			objects:  []client.Object{testLauncherPod()},
+			failWith: "--freeze",
+			wantErr:  ErrFreezeFailed,
+			wantCalls: []string{
+				"virt-launcher-vm-abcde/compute: /usr/bin/virt-freezer --freeze --name vm --namespace test-namespace",
+			},
+		},
+		{
+			name:    "virtual machine not running",
+			wantErr: ErrFreezeFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &fakeExecutor{failWith: tt.failWith}
+			src, dest := buildTestClient(tt.objects...), buildTestClient()
+			e := testEndpoint()
+			s, err := transport.CreateServer(context.TODO(), testTransport(), dest, "block", e)
+			if err != nil {
+				t.Fatalf("unable to create transport server: %v", err)
+			}
+			tr, err := NewTransfer(s, e, src, dest, pvcList, nil, &TransferOptions{
+				VirtualMachineInstance: &types.NamespacedName{Namespace: testNamespace, Name: testVMIName},
+				Executor:               executor,
+				FreezeTimeout:          100 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewTransfer() unexpected error %v", err)
+			}
+			err = transfer.PreTransfer(context.TODO(), tr)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("PreTransfer() unexpected error %v", err)
+			case tt.wantErr != nil && (err == nil || !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error())):
+				t.Fatalf("PreTransfer() error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(executor.calls, "\n") != strings.Join(tt.wantCalls, "\n") {
+				t.Errorf("virt-freezer calls = %v, want %v", executor.calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+				t.Fatalf("CreateClient() unexpected error %v", err)
+			}
+			clone := transfer.SnapshotClaimName(pvcList[0].Source())
+			if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: clone}, &corev1.PersistentVolumeClaim{}); err != nil {
+				t.Fatalf("unable to get the clone of the disk: %v", err)
+			}
+			pods := &corev1.PodList{}
+			if err := src.List(context.TODO(), pods, client.InNamespace(testNamespace), client.HasLabels{"pvc"}); err != nil || len(pods.Items) != 1 {
+				t.Fatalf("unable to find the blockrsync client pod: %v", err)
+			}
+			for _, v := range pods.Items[0].Spec.Volumes {
+				if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName != clone {
+					t.Errorf("blockrsync client mounts %s, want the clone %s", v.PersistentVolumeClaim.ClaimName, clone)
+				}
+			}
+		})
+	}
+}
+
+type fakeExecutor struct {
+	calls    []string
+	failWith string
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, pod types.NamespacedName, container string, command []string) (string, string, error) {
+	f.calls = append(f.calls, fmt.Sprintf("%s/%s: %s", pod.Name, container, strings.Join(command, " ")))
+	if f.failWith != "" && command[1] == f.failWith {
+		return "", "guest agent not connected", fmt.Errorf("command terminated with exit code 1")
+	}
+	return "", "", nil
+}
+
+func buildTestClient(objects ...client.Object) client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+}
+
+func testDisk() *corev1.PersistentVolumeClaim {
+	storageClass := "standard"
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   testNamespace,
+			Name:        testDiskName,
+			Annotations: map[string]string{ContentTypeAnnotation: ContentTypeKubeVirt},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+		},
+	}
+}
+
+func testDataVolume() *unstructured.Unstructured {
+	dataVolume := newDataVolume()
+	dataVolume.SetNamespace(testNamespace)
+	dataVolume.SetName(testDiskName)
+	_ = unstructured.SetNestedMap(dataVolume.Object, map[string]interface{}{
+		"source": map[string]interface{}{
+			"http": map[string]interface{}{"url": "https://example.com/disk.qcow2"},
+		},
+		"pvc": map[string]interface{}{
+			"accessModes": []interface{}{"ReadWriteOnce"},
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"storage": "10Gi"},
+			},
+		},
+	}, "spec")
+	return dataVolume
+}
+
+func testLauncherPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      "virt-launcher-vm-abcde",
+			Labels:    map[string]string{virtLauncherLabel: virtLauncherLabelValue, vmiNameLabel: testVMIName},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func testTransport() transport.Transport {
+	return stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testDiskName},
+		types.NamespacedName{Namespace: testDestNamespace, Name: testDiskName},
+	), nil, &transport.Options{})
+}
+
+func testEndpoint() *service.ServiceEndpoint {
+	return service.NewEndpoint(types.NamespacedName{Namespace: testDestNamespace, Name: testDiskName},
+		statetransfermeta.Labels, "test.host", corev1.ServiceTypeLoadBalancer, nil).(*service.ServiceEndpoint)
+}
@@ -0,0 +1,300 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// UIDRangeAnnotation is the annotation of OpenShift namespaces holding the range of uids the
+	// pods of the namespace run as, e.g. 1000650000/10000
+	UIDRangeAnnotation = "openshift.io/sa.scc.uid-range"
+	// SupplementalGroupsAnnotation is the annotation of OpenShift namespaces holding the ranges of
+	// the supplemental groups and fsGroups of the pods of the namespace
+	SupplementalGroupsAnnotation = "openshift.io/sa.scc.supplemental-groups"
+	// DefaultChownImage is the image of the Jobs of ChownHook when its Image is not set
+	DefaultChownImage = "quay.io/konveyor/rsync-transfer:latest"
+
+	chownJobPrefix = "crane2-chown-"
+)
+
+// ErrNoUIDRange is returned when a namespace has no uid range annotation, i.e. it is not an
+// OpenShift namespace
+var ErrNoUIDRange = errors.New("namespace has no uid range")
+
+// IDRange is a range of uids or gids
+type IDRange struct {
+	Start int64
+	Size  int64
+}
+
+// ParseIDRange parses a range in the start/size or the start-end form of the OpenShift namespace
+// annotations, only the first of comma separated ranges is used
+func ParseIDRange(value string) (IDRange, error) {
+	value = strings.TrimSpace(strings.Split(value, ",")[0])
+	if start, size, ok := strings.Cut(value, "/"); ok {
+		r, err := newIDRange(start, size, false)
+		if err != nil {
+			return IDRange{}, fmt.Errorf("invalid id range %q: %v", value, err)
+		}
+		return r, nil
+	}
+	if start, end, ok := strings.Cut(value, "-"); ok {
+		r, err := newIDRange(start, end, true)
+		if err != nil {
+			return IDRange{}, fmt.Errorf("invalid id range %q: %v", value, err)
+		}
+		return r, nil
+	}
+	return IDRange{}, fmt.Errorf("invalid id range %q", value)
+}
+
+func newIDRange(start string, second string, isEnd bool) (IDRange, error) {
+	s, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return IDRange{}, err
+	}
+	n, err := strconv.ParseInt(second, 10, 64)
+	if err != nil {
+		return IDRange{}, err
+	}
+	if isEnd {
+		n = n - s + 1
+	}
+	r := IDRange{Start: s, Size: n}
+	return r, r.Validate()
+}
+
+// Validate returns an error when the range is empty or holds negative ids
+func (r IDRange) Validate() error {
+	if r.Start < 0 || r.Size <= 0 {
+		return fmt.Errorf("id range must start at a non negative id and not be empty")
+	}
+	return nil
+}
+
+// Last returns the last id of the range
+func (r IDRange) Last() int64 {
+	return r.Start + r.Size - 1
+}
+
+// Contains returns whether the id is in the range
+func (r IDRange) Contains(id int64) bool {
+	return id >= r.Start && id <= r.Last()
+}
+
+// String returns the range in the start-end form
+func (r IDRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.Last())
+}
+
+// NamespaceIDRanges returns the uid range and the supplemental group range of the given OpenShift
+// namespace. The group range defaults to the uid range, like OpenShift does. An error wrapping
+// ErrNoUIDRange is returned when the namespace has no uid range.
+func NamespaceIDRanges(ctx context.Context, c client.Client, namespace string) (uids IDRange, gids IDRange, err error) {
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return IDRange{}, IDRange{}, err
+	}
+	uidRange, ok := ns.Annotations[UIDRangeAnnotation]
+	if !ok {
+		return IDRange{}, IDRange{}, fmt.Errorf("%w: namespace %s", ErrNoUIDRange, namespace)
+	}
+	if uids, err = ParseIDRange(uidRange); err != nil {
+		return IDRange{}, IDRange{}, err
+	}
+	gids = uids
+	if groups, ok := ns.Annotations[SupplementalGroupsAnnotation]; ok {
+		if gids, err = ParseIDRange(groups); err != nil {
+			return IDRange{}, IDRange{}, err
+		}
+	}
+	return uids, gids, nil
+}
+
+// OwnershipMapping changes the owner and the group of the transferred files, so that they remain
+// readable and writable by the pods of the destination namespace when they run with different ids
+// than the pods of the source namespace, such as on OpenShift where every namespace is assigned its
+// own range of uids.
+type OwnershipMapping struct {
+	// UID is the owner of the transferred files in the destination, the owner is kept when not set
+	UID *int64
+	// GID is the group of the transferred files in the destination, the group is kept when not set
+	GID *int64
+	// SourceUIDs restricts the change of owner to the files owned by a uid of the range, the owner of
+	// every file is changed when not set
+	SourceUIDs *IDRange
+	// SourceGIDs restricts the change of group to the files of a group of the range, the group of
+	// every file is changed when not set
+	SourceGIDs *IDRange
+}
+
+// DiscoverOwnershipMapping returns the mapping of the files owned by the uid range and the group
+// range of the source namespace to the first uid and the first group of the ranges of the
+// destination namespace, which are the ids OpenShift runs the pods of a namespace with by default.
+// The ownership of every file is changed when the source namespace has no uid range. An error
+// wrapping ErrNoUIDRange is returned when the destination namespace has none.
+func DiscoverOwnershipMapping(ctx context.Context, source client.Client, destination client.Client, sourceNamespace string, destinationNamespace string) (*OwnershipMapping, error) {
+	uids, gids, err := NamespaceIDRanges(ctx, destination, destinationNamespace)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &OwnershipMapping{UID: &uids.Start, GID: &gids.Start}
+	sourceUIDs, sourceGIDs, err := NamespaceIDRanges(ctx, source, sourceNamespace)
+	switch {
+	case errors.Is(err, ErrNoUIDRange):
+	case err != nil:
+		return nil, err
+	default:
+		mapping.SourceUIDs = &sourceUIDs
+		mapping.SourceGIDs = &sourceGIDs
+	}
+	return mapping, nil
+}
+
+// Validate returns an error when the mapping changes neither the owner nor the group, or holds
+// negative ids
+func (o OwnershipMapping) Validate() error {
+	errs := []error{}
+	if o.UID == nil && o.GID == nil {
+		errs = append(errs, fmt.Errorf("ownership mapping requires a uid or a gid"))
+	}
+	if o.UID != nil && *o.UID < 0 {
+		errs = append(errs, fmt.Errorf("uid must not be negative"))
+	}
+	if o.GID != nil && *o.GID < 0 {
+		errs = append(errs, fmt.Errorf("gid must not be negative"))
+	}
+	for _, r := range []*IDRange{o.SourceUIDs, o.SourceGIDs} {
+		if r != nil {
+			errs = append(errs, r.Validate())
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// UserMap returns the mapping of owners in the form of the rsync --usermap option, empty when the
+// owner is kept
+func (o OwnershipMapping) UserMap() string {
+	return idMap(o.UID, o.SourceUIDs)
+}
+
+// GroupMap returns the mapping of groups in the form of the rsync --groupmap option, empty when the
+// group is kept
+func (o OwnershipMapping) GroupMap() string {
+	return idMap(o.GID, o.SourceGIDs)
+}
+
+func idMap(id *int64, source *IDRange) string {
+	if id == nil {
+		return ""
+	}
+	if source == nil {
+		return fmt.Sprintf("*:%d", *id)
+	}
+	return fmt.Sprintf("%s:%d", source, *id)
+}
+
+// chownCommands returns the commands changing the ownership of the files under the given path
+func (o OwnershipMapping) chownCommands(path string) []string {
+	commands := []string{}
+	change := func(command string, flag string, id *int64, source *IDRange) {
+		if id == nil {
+			return
+		}
+		if source == nil {
+			commands = append(commands, fmt.Sprintf("%s -R -h %d %s", command, *id, path))
+			return
+		}
+		// find matches ids strictly greater than +n and strictly less than -n
+		commands = append(commands, fmt.Sprintf("find %s %s +%d %s -%d -exec %s -h %d {} +",
+			path, flag, source.Start-1, flag, source.Last()+1, command, *id))
+	}
+	change("chown", "-uid", o.UID, o.SourceUIDs)
+	change("chgrp", "-gid", o.GID, o.SourceGIDs)
+	return commands
+}
+
+// ChownHook is an after hook changing the ownership of the files of every destination PVC
+// with a Job, for transfers which cannot map ownership while copying, see RunWithHooks. It requires
+// the create and get verbs on jobs in the destination namespaces, the Jobs run as root.
+type ChownHook struct {
+	// Ownership is the mapping applied to the files of the destination PVCs
+	Ownership OwnershipMapping
+	// Image is the image of the Jobs, it must provide find, chown and chgrp, defaults to
+	// DefaultChownImage
+	Image string
+	// Timeout is the time each Job is given to complete, defaults to DefaultHookTimeout
+	Timeout time.Duration
+	// PollInterval is the interval the Jobs are polled at, defaults to DefaultHookPollInterval
+	PollInterval time.Duration
+}
+
+// Run runs a Job changing the ownership of the files of each destination PVC in turn
+func (h ChownHook) Run(ctx context.Context, t Transfer) error {
+	if err := h.Ownership.Validate(); err != nil {
+		return err
+	}
+	image := h.Image
+	if image == "" {
+		image = DefaultChownImage
+	}
+	for _, pvc := range t.PVCs() {
+		claim := pvc.Destination().Claim()
+		job := h.job(claim, image)
+		hook := JobHook{Job: job, Destination: true, Timeout: h.Timeout, PollInterval: h.PollInterval}
+		if err := hook.Run(ctx, t); err != nil {
+			return fmt.Errorf("unable to change the ownership of pvc %s/%s: %w", claim.Namespace, claim.Name, err)
+		}
+	}
+	return nil
+}
+
+// job returns the Job changing the ownership of the files of the given destination PVC
+func (h ChownHook) job(claim *corev1.PersistentVolumeClaim, image string) *batchv1.Job {
+	const mountPath = "/mnt/data"
+	runAsRoot := int64(0)
+	backoffLimit := int32(2)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    claim.Namespace,
+			GenerateName: chownJobPrefix,
+			Labels:       meta.WithOwnerLabel(meta.Labels),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    "chown",
+						Image:   image,
+						Command: []string{"/bin/bash", "-c", strings.Join(h.Ownership.chownCommands(mountPath), " && ")},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "data",
+							MountPath: mountPath,
+						}},
+						SecurityContext: &corev1.SecurityContext{RunAsUser: &runAsRoot},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "data",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim.Name},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseIDRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    IDRange
+		wantErr bool
+	}{
+		{value: "1000650000/10000", want: IDRange{Start: 1000650000, Size: 10000}},
+		{value: "1000650000-1000659999", want: IDRange{Start: 1000650000, Size: 10000}},
+		{value: "1000650000/10000,2000000000/10", want: IDRange{Start: 1000650000, Size: 10000}},
+		{value: "1000650000", wantErr: true},
+		{value: "1000650000/0", wantErr: true},
+		{value: "abc/10", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIDRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIDRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIDRange(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverOwnershipMapping(t *testing.T) {
+	newNamespace := func(name string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+	source := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newNamespace("src", map[string]string{UIDRangeAnnotation: "1000650000/10000"}),
+		newNamespace("plain", nil),
+	).Build()
+	destination := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newNamespace("dst", map[string]string{
+			UIDRangeAnnotation:           "1000700000/10000",
+			SupplementalGroupsAnnotation: "1000800000/10000",
+		}),
+		newNamespace("plain", nil),
+	).Build()
+
+	mapping, err := DiscoverOwnershipMapping(context.TODO(), source, destination, "src", "dst")
+	if err != nil {
+		t.Fatalf("DiscoverOwnershipMapping() unexpected error %v", err)
+	}
+	if mapping.UserMap() != "1000650000-1000659999:1000700000" || mapping.GroupMap() != "1000650000-1000659999:1000800000" {
+		t.Errorf("DiscoverOwnershipMapping() maps %s and %s", mapping.UserMap(), mapping.GroupMap())
+	}
+
+	mapping, err = DiscoverOwnershipMapping(context.TODO(), source, destination, "plain", "dst")
+	if err != nil || mapping.SourceUIDs != nil || mapping.UserMap() != "*:1000700000" {
+		t.Errorf("DiscoverOwnershipMapping() = %+v, %v, want every file mapped", mapping, err)
+	}
+
+	if _, err := DiscoverOwnershipMapping(context.TODO(), source, destination, "src", "plain"); !errors.Is(err, ErrNoUIDRange) {
+		t.Errorf("DiscoverOwnershipMapping() error = %v, want %v", err, ErrNoUIDRange)
+	}
+}
+
+func TestChownHook(t *testing.T) {
+	claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"}}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	tr := &hookTransfer{destination: c, pvcs: PVCPairList{NewPVCPair(claim, nil)}}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go completeJobs(ctx, c, false)
+
+	uid, gid := int64(1000700000), int64(1000700000)
+	hook := ChownHook{
+		Ownership:    OwnershipMapping{UID: &uid, GID: &gid, SourceGIDs: &IDRange{Start: 1000650000, Size: 10000}},
+		PollInterval: time.Millisecond,
+		Timeout:      5 * time.Second,
+	}
+	if err := hook.Run(context.TODO(), tr); err != nil {
+		t.Fatalf("Run() unexpected error %v", err)
+	}
+	jobs := &batchv1.JobList{}
+	if err := c.List(context.TODO(), jobs, client.InNamespace("ns")); err != nil || len(jobs.Items) != 1 {
+		t.Fatalf("expected a job in the destination namespace: %v", err)
+	}
+	pod := jobs.Items[0].Spec.Template.Spec
+	if pod.Volumes[0].PersistentVolumeClaim.ClaimName != "data" {
+		t.Errorf("job should mount the destination pvc, got %v", pod.Volumes)
+	}
+	want := []string{
+		"chown -R -h 1000700000 /mnt/data",
+		"find /mnt/data -gid +1000649999 -gid -1000660000 -exec chgrp -h 1000700000 {} +",
+	}
+	if command := pod.Containers[0].Command[2]; !reflect.DeepEqual(strings.Split(command, " && "), want) {
+		t.Errorf("job command = %q, want %q", command, want)
+	}
+}
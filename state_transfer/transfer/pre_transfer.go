@@ -0,0 +1,134 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrPreTransferFailed is returned when the pre-transfer command or its verification fails
+var ErrPreTransferFailed = errors.New("pre-transfer hook failed")
+
+// PodExecutor runs a command in a container of a running pod. Implementations typically use the
+// pods/exec subresource through k8s.io/client-go/tools/remotecommand, which requires the create
+// verb on pods/exec in the source namespace.
+type PodExecutor interface {
+	// Exec runs the command and returns its output, a non-zero exit code must be returned as an error
+	Exec(ctx context.Context, pod types.NamespacedName, container string, command []string) (stdout string, stderr string, err error)
+}
+
+// PreTransferHook is a command run in the live source workload before the transfer begins, such as
+// `sync` or an application specific flush, so that the copy is application consistent
+type PreTransferHook struct {
+	// Executor runs the commands in the source pods
+	Executor PodExecutor
+	// Command is run in every running pod mounting a source PVC
+	Command []string
+	// VerifyCommand is run after Command in the same pods, the hook fails when it does not succeed
+	VerifyCommand []string
+	// Container is the container the commands run in, defaults to the first container mounting a source PVC
+	Container string
+}
+
+// Run runs the hook in the source pods of the transfer, so that it can be used as the action of a
+// before hook of RunWithHooks, see RunPreTransferHook
+func (h *PreTransferHook) Run(ctx context.Context, t Transfer) error {
+	return RunPreTransferHook(ctx, t.Source(), h, t.PVCs())
+}
+
+// PreTransferrer is implemented by transfers which can run a hook in the source workload
+type PreTransferrer interface {
+	// PreTransfer runs the configured pre-transfer hook, it must be called before CreateClient
+	PreTransfer(ctx context.Context, c client.Client) error
+}
+
+// PreTransfer runs the pre-transfer hook of the given transfer using the source client, it is a
+// no-op for transfers which do not implement PreTransferrer
+func PreTransfer(ctx context.Context, t Transfer) error {
+	if p, ok := t.(PreTransferrer); ok {
+		return p.PreTransfer(ctx, t.Source())
+	}
+	return nil
+}
+
+// RunPreTransferHook is a utility function that can be used by various implementations to run a
+// hook in every running pod which mounts a source PVC of the list. Listing the pods requires the
+// list verb on pods in the source namespaces. An error wrapping ErrPreTransferFailed is returned
+// for every pod where a command failed.
+func RunPreTransferHook(ctx context.Context, c client.Client, hook *PreTransferHook, pvcList PVCPairList) error {
+	if hook == nil || len(hook.Command) == 0 {
+		return nil
+	}
+	if hook.Executor == nil {
+		return fmt.Errorf("pre-transfer hook requires an executor")
+	}
+	errs := []error{}
+	for _, ns := range pvcList.GetSourceNamespaces() {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(ns)); err != nil {
+			return err
+		}
+		claims := map[string]bool{}
+		for _, pvc := range pvcList.InSourceNamespace(ns) {
+			claims[pvc.Source().Claim().Name] = true
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			container := hookContainer(pod, claims, hook.Container)
+			if container == "" {
+				continue
+			}
+			errs = append(errs, runHookCommands(ctx, hook, pod, container))
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func runHookCommands(ctx context.Context, hook *PreTransferHook, pod *corev1.Pod, container string) error {
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	for _, command := range [][]string{hook.Command, hook.VerifyCommand} {
+		if len(command) == 0 {
+			continue
+		}
+		_, stderr, err := hook.Executor.Exec(ctx, key, container, command)
+		if err != nil {
+			return fmt.Errorf("%w: command %q in container %s of pod %s: %v: %s",
+				ErrPreTransferFailed, strings.Join(command, " "), container, key, err, strings.TrimSpace(stderr))
+		}
+	}
+	return nil
+}
+
+// hookContainer returns the container of the pod the hook runs in, empty when the pod does
+// not mount any of the claims
+func hookContainer(pod *corev1.Pod, claims map[string]bool, container string) string {
+	volumes := map[string]bool{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && claims[volume.PersistentVolumeClaim.ClaimName] {
+			volumes[volume.Name] = true
+		}
+	}
+	if len(volumes) == 0 {
+		return ""
+	}
+	if container != "" {
+		return container
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, mount := range c.VolumeMounts {
+			if volumes[mount.Name] {
+				return c.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	probePodPrefix    = "crane2-probe-"
+	probeMountPath    = "/mnt/probe"
+	writeProbeFile    = ".crane-write-probe"
+	probePollInterval = 2 * time.Second
+)
+
+// ErrDestinationNotWritable is returned when a destination PVC cannot be written to
+var ErrDestinationNotWritable = errors.New("destination is not writable")
+
+// ErrInsufficientEphemeralStorage is returned when no destination node has enough free ephemeral storage
+var ErrInsufficientEphemeralStorage = errors.New("insufficient ephemeral storage")
+
+// ErrDestinationNotEmpty is returned when a destination PVC already contains data
+var ErrDestinationNotEmpty = errors.New("destination is not empty")
+
+// VerifyDestinationWritable is a preflight check which writes and deletes a small probe file
+// in every destination PVC using short-lived pods running the given image. It catches read-only
+// mounts, permission issues and full volumes before a long transfer is started. It must be run
+// before the transfer server is created so that ReadWriteOnce volumes are not in use. The probe
+// pods are deleted before returning, an error wrapping ErrDestinationNotWritable is returned for
+// every PVC which could not be written to.
+func VerifyDestinationWritable(ctx context.Context, c client.Client, pvcList PVCPairList, image string) error {
+	probeFile := fmt.Sprintf("%s/%s", probeMountPath, writeProbeFile)
+	script := fmt.Sprintf("echo probe > %s && sync && rm -f %s", probeFile, probeFile)
+	return probeDestinations(ctx, c, pvcList, image, script, func(pvc PVC) error {
+		return fmt.Errorf("%w: unable to write to pvc %s/%s", ErrDestinationNotWritable, pvc.Claim().Namespace, pvc.Claim().Name)
+	})
+}
+
+// VerifyDestinationEmpty is a preflight check which verifies that no destination PVC contains data,
+// the lost+found directory created by some filesystems is ignored. Like VerifyDestinationWritable
+// it uses short-lived pods and must be run before the transfer server is created. An error wrapping
+// ErrDestinationNotEmpty is returned for every PVC which already contains data.
+func VerifyDestinationEmpty(ctx context.Context, c client.Client, pvcList PVCPairList, image string) error {
+	script := fmt.Sprintf("[ -z \"$(ls -A %s | grep -v -x lost+found)\" ]", probeMountPath)
+	return probeDestinations(ctx, c, pvcList, image, script, func(pvc PVC) error {
+		return fmt.Errorf("%w: pvc %s/%s already contains data", ErrDestinationNotEmpty, pvc.Claim().Namespace, pvc.Claim().Name)
+	})
+}
+
+// probeDestinations runs the script in a pod mounting each filesystem destination PVC and returns
+// the error built by failed for every PVC where the script did not succeed
+func probeDestinations(ctx context.Context, c client.Client, pvcList PVCPairList, image, script string, failed func(PVC) error) error {
+	probes := map[string]PVC{}
+	errs := []error{}
+	for _, pvc := range pvcList {
+		if isBlockOrVMDisk(pvc.Destination().Claim()) {
+			continue
+		}
+		pod, err := createProbePod(ctx, c, pvc.Destination(), image, script)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		probes[pod.Name] = pvc.Destination()
+		defer deleteProbePod(ctx, c, pod)
+	}
+
+	for name, pvc := range probes {
+		key := client.ObjectKey{Namespace: pvc.Claim().Namespace, Name: name}
+		pod := &corev1.Pod{}
+		err := wait.PollImmediateUntil(probePollInterval, func() (bool, error) {
+			err := c.Get(ctx, key, pod)
+			if err != nil {
+				return false, err
+			}
+			return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+		}, ctx.Done())
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("unable to probe pvc %s/%s: %w", pvc.Claim().Namespace, pvc.Claim().Name, err))
+		case pod.Status.Phase == corev1.PodFailed:
+			errs = append(errs, failed(pvc))
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func createProbePod(ctx context.Context, c client.Client, pvc PVC, image, script string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: probePodPrefix,
+			Namespace:    pvc.Claim().Namespace,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:  "probe",
+					Image: image,
+					Command: []string{
+						"/bin/sh",
+						"-c",
+						script,
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "probe",
+							MountPath: probeMountPath,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "probe",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Claim().Name,
+						},
+					},
+				},
+			},
+		},
+	}
+	err := c.Create(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+func deleteProbePod(ctx context.Context, c client.Client, pod *corev1.Pod) {
+	// the probe is cleaned up even when the context was cancelled
+	ctx, cancel := meta.CleanupContext(ctx)
+	defer cancel()
+	_ = c.Delete(ctx, pod, client.PropagationPolicy(metav1.DeletePropagationBackground))
+}
+
+// VerifyNodeEphemeralStorage is a preflight check which verifies that at least one node the transfer
+// server can be scheduled on has the required amount of ephemeral storage in bytes available. The
+// available storage of a node is its allocatable ephemeral storage minus the ephemeral storage
+// requested by the pods running on it. The node is nodeName when set, otherwise any node matching
+// nodeSelector. An error wrapping ErrInsufficientEphemeralStorage is returned when no node fits.
+func VerifyNodeEphemeralStorage(ctx context.Context, c client.Client, nodeName string, nodeSelector map[string]string, required int64) error {
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes, client.MatchingLabels(nodeSelector)); err != nil {
+		return err
+	}
+	best := int64(0)
+	bestNode := ""
+	for _, node := range nodes.Items {
+		if nodeName != "" && node.Name != nodeName {
+			continue
+		}
+		available, err := availableEphemeralStorage(ctx, c, &node)
+		if err != nil {
+			return err
+		}
+		if available >= required {
+			return nil
+		}
+		if bestNode == "" || available > best {
+			best, bestNode = available, node.Name
+		}
+	}
+	if bestNode == "" {
+		return fmt.Errorf("%w: no node found to hold %s of temporary files", ErrInsufficientEphemeralStorage, resource.NewQuantity(required, resource.BinarySI))
+	}
+	return fmt.Errorf("%w: %s of temporary files are needed, node %s has the most available with %s",
+		ErrInsufficientEphemeralStorage,
+		resource.NewQuantity(required, resource.BinarySI),
+		bestNode,
+		resource.NewQuantity(best, resource.BinarySI))
+}
+
+func availableEphemeralStorage(ctx context.Context, c client.Client, node *corev1.Node) (int64, error) {
+	allocatable := node.Status.Allocatable.StorageEphemeral().Value()
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return 0, err
+	}
+	requested := int64(0)
+	for _, pod := range pods.Items {
+		// not every client supports field selectors
+		if pod.Spec.NodeName != node.Name || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			requested += container.Resources.Requests.StorageEphemeral().Value()
+		}
+	}
+	return allocatable - requested, nil
+}
@@ -0,0 +1,83 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// etaMinElapsed is how long a transfer must have run before its rate is meaningful
+	etaMinElapsed = 30 * time.Second
+	// etaStallTimeout is how long progress may not change before a transfer is considered stalled
+	etaStallTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrETAUnknown is returned by ETA when there is not enough progress data yet to estimate
+	// the time remaining, typically because the transfer just started
+	ErrETAUnknown = errors.New("estimated time remaining is unknown")
+	// ErrTransferStalled is returned by ETA when progress has not changed for a while
+	ErrTransferStalled = errors.New("transfer progress has stalled")
+)
+
+// Progress is the observed progress of a transfer
+type Progress struct {
+	// BytesTransferred is the amount of data transferred so far
+	BytesTransferred int64
+	// StartTime is when the transfer started, zero when it has not started yet
+	StartTime time.Time
+	// LastUpdate is when BytesTransferred last changed, stall detection
+	// is disabled when it is not set
+	LastUpdate time.Time
+	// TotalBytes is the amount of data to transfer as reported by the transfer client, zero when
+	// it does not report it. It may grow while the client discovers files.
+	TotalBytes int64
+	// FilesTransferred is the number of files transferred so far as reported by the transfer client
+	FilesTransferred int64
+	// FilesTotal is the number of files found so far as reported by the transfer client
+	FilesTotal int64
+	// Estimated is set when BytesTransferred is not counted by the transfer client but estimated,
+	// from the size of the PVCs whose transfer completed for instance, see EstimatePVCSize
+	Estimated bool
+}
+
+// ProgressReporter knows how to report the progress of a transfer
+type ProgressReporter interface {
+	// Progress returns the observed progress of the transfer, the client must
+	// be able to reach the cluster the transfer client runs in
+	Progress(ctx context.Context, c client.Client) (*Progress, error)
+}
+
+// ETA returns an estimate of the time remaining for the transfer, based on the estimated size of
+// its PVCs and the average rate since it started. ErrETAUnknown is returned when there is not
+// enough data yet and ErrTransferStalled when progress has not changed for a while.
+func ETA(ctx context.Context, t Transfer, c client.Client) (time.Duration, error) {
+	reporter, ok := t.(ProgressReporter)
+	if !ok {
+		return 0, fmt.Errorf("transfer does not report progress: %w", ErrETAUnknown)
+	}
+	progress, err := reporter.Progress(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	return estimateRemaining(progress, t.PVCs().EstimateSize(), time.Now())
+}
+
+// estimateRemaining returns the time needed to transfer the rest of size bytes at the average rate
+func estimateRemaining(progress *Progress, size int64, now time.Time) (time.Duration, error) {
+	if progress.StartTime.IsZero() || progress.BytesTransferred <= 0 || now.Sub(progress.StartTime) < etaMinElapsed {
+		return 0, ErrETAUnknown
+	}
+	if progress.BytesTransferred >= size {
+		return 0, nil
+	}
+	if !progress.LastUpdate.IsZero() && now.Sub(progress.LastUpdate) > etaStallTimeout {
+		return 0, fmt.Errorf("%w: no progress since %s", ErrTransferStalled, progress.LastUpdate.Format(time.RFC3339))
+	}
+	rate := float64(progress.BytesTransferred) / now.Sub(progress.StartTime).Seconds()
+	return time.Duration(float64(size-progress.BytesTransferred) / rate * float64(time.Second)), nil
+}
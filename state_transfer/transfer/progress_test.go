@@ -0,0 +1,54 @@
+package transfer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	now := time.Now()
+	gi := int64(1024 * 1024 * 1024)
+	tests := []struct {
+		name     string
+		progress Progress
+		want     time.Duration
+		wantErr  error
+	}{
+		{
+			name:    "not started",
+			wantErr: ErrETAUnknown,
+		},
+		{
+			name:     "just started",
+			progress: Progress{BytesTransferred: gi, StartTime: now.Add(-10 * time.Second)},
+			wantErr:  ErrETAUnknown,
+		},
+		{
+			name:     "average rate",
+			progress: Progress{BytesTransferred: 2 * gi, StartTime: now.Add(-10 * time.Minute), LastUpdate: now},
+			want:     40 * time.Minute,
+		},
+		{
+			name:     "stalled",
+			progress: Progress{BytesTransferred: 2 * gi, StartTime: now.Add(-time.Hour), LastUpdate: now.Add(-30 * time.Minute)},
+			wantErr:  ErrTransferStalled,
+		},
+		{
+			name:     "complete",
+			progress: Progress{BytesTransferred: 10 * gi, StartTime: now.Add(-time.Hour)},
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := estimateRemaining(&tt.progress, 10*gi, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("estimateRemaining() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Round(time.Second) != tt.want {
+				t.Errorf("estimateRemaining() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,8 +39,11 @@ func (p pvc) LabelSafeName() string {
 
 // pvcPair defines a source and a destination PersistentVolumeClaim
 type pvcPair struct {
-	src  PVC
-	dest PVC
+	src        PVC
+	dest       PVC
+	sourcePath SourcePath
+	// filter is a pointer so that pairs remain comparable and can be used as map keys
+	filter *Filter
 }
 
 func (p pvcPair) Source() PVC {
@@ -49,6 +54,17 @@ func (p pvcPair) Destination() PVC {
 	return p.dest
 }
 
+func (p pvcPair) SourcePath() SourcePath {
+	return p.sourcePath
+}
+
+func (p pvcPair) Filter() Filter {
+	if p.filter == nil {
+		return Filter{}
+	}
+	return *p.filter
+}
+
 // NewPVCPair when given references to a source and a destination PersistentVolumeClaim,
 // returns a PVCPair to be used in transfers
 func NewPVCPair(src *v1.PersistentVolumeClaim, dest *v1.PersistentVolumeClaim) PVCPair {
@@ -75,6 +91,10 @@ func NewBlockOrVMDiskPVCPairList(pvcs ...PVCPair) (PVCPairList, error) {
 			return nil, fmt.Errorf("source pvc definition cannot be nil")
 		}
 		newPvc.src = p.Source()
+		newPvc.sourcePath = GetSourcePath(p)
+		if filter := GetFilter(p); !filter.IsEmpty() {
+			newPvc.filter = &filter
+		}
 		if p.Destination() == nil {
 			newPvc.dest = p.Source()
 		} else {
@@ -101,6 +121,10 @@ func NewFilesystemPVCPairList(pvcs ...PVCPair) (PVCPairList, error) {
 			return nil, fmt.Errorf("source pvc definition cannot be nil")
 		}
 		newPvc.src = p.Source()
+		newPvc.sourcePath = GetSourcePath(p)
+		if filter := GetFilter(p); !filter.IsEmpty() {
+			newPvc.filter = &filter
+		}
 		if p.Destination() == nil {
 			newPvc.dest = p.Source()
 		} else {
@@ -211,6 +235,82 @@ func (p PVCPairList) GroupBySourceNamespaces() map[string][]PVCPair {
 	return nsToPVCMap
 }
 
+// Block returns the PVCs of the list whose source has volumeMode: Block, they hold raw device
+// contents and are transferred by the blockrsync transfer rather than rsync
+func (p PVCPairList) Block() PVCPairList {
+	pvcList := PVCPairList{}
+	for i := range p {
+		if IsBlock(p[i].Source().Claim()) {
+			pvcList = append(pvcList, p[i])
+		}
+	}
+	return pvcList
+}
+
+// Filesystem returns the PVCs of the list whose source does not have volumeMode: Block
+func (p PVCPairList) Filesystem() PVCPairList {
+	pvcList := PVCPairList{}
+	for i := range p {
+		if !IsBlock(p[i].Source().Claim()) {
+			pvcList = append(pvcList, p[i])
+		}
+	}
+	return pvcList
+}
+
+// IsBlock returns whether the given PVC has volumeMode: Block
+func IsBlock(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc != nil && pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == v1.PersistentVolumeBlock
+}
+
+// EstimateSize returns an estimate of the amount of data in bytes held by the source PVCs in the list
+func (p PVCPairList) EstimateSize() int64 {
+	size := int64(0)
+	for i := range p {
+		if p[i] != nil && p[i].Source() != nil {
+			size += EstimatePVCSize(p[i].Source())
+		}
+	}
+	return size
+}
+
+// ID identifies the transfer of the PVCs of the list, it is made of the sorted namespace/name of
+// the source PVCs so that it is stable across restarts of the process
+func (p PVCPairList) ID() string {
+	names := []string{}
+	for i := range p {
+		if p[i] != nil && p[i].Source() != nil {
+			claim := p[i].Source().Claim()
+			names = append(names, claim.Namespace+"/"+claim.Name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ShortID is a hash of ID, bounded in length so that it can be used in names and label values
+// whatever the number of PVCs in the list
+func (p PVCPairList) ShortID() string {
+	return getMD5Hash(p.ID())[:16]
+}
+
+// EstimatePVCSize returns an estimate of the amount of data in bytes held by a PVC. It is based
+// on the capacity of the bound volume, or the requested storage when the PVC is not bound yet,
+// and is therefore an upper bound of the actual size of the data.
+func EstimatePVCSize(p PVC) int64 {
+	claim := p.Claim()
+	if claim == nil {
+		return 0
+	}
+	if capacity, exists := claim.Status.Capacity[v1.ResourceStorage]; exists {
+		return capacity.Value()
+	}
+	if request, exists := claim.Spec.Resources.Requests[v1.ResourceStorage]; exists {
+		return request.Value()
+	}
+	return 0
+}
+
 func getMD5Hash(s string) string {
 	hash := md5.Sum([]byte(s))
 	return hex.EncodeToString(hash[:])
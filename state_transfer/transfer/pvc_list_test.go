@@ -0,0 +1,184 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPVCPairListBlockAndFilesystem(t *testing.T) {
+	block := v1.PersistentVolumeBlock
+	filesystem := v1.PersistentVolumeFilesystem
+	newClaim := func(name string, mode *v1.PersistentVolumeMode) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeMode: mode},
+		}
+	}
+	pvcList := PVCPairList{
+		NewPVCPair(newClaim("default", nil), nil),
+		NewPVCPair(newClaim("block", &block), nil),
+		NewPVCPair(newClaim("filesystem", &filesystem), nil),
+	}
+
+	if got := pvcList.Block(); len(got) != 1 || got[0].Source().Claim().Name != "block" {
+		t.Errorf("Block() = %v, want the block pvc", got)
+	}
+	got := pvcList.Filesystem()
+	if len(got) != 2 || got[0].Source().Claim().Name != "default" || got[1].Source().Claim().Name != "filesystem" {
+		t.Errorf("Filesystem() = %v, want the default and filesystem pvcs", got)
+	}
+}
+
+func TestPVCPairListValidate(t *testing.T) {
+	block := v1.PersistentVolumeBlock
+	standard, missing := "standard", "missing"
+	newClaim := func(namespace, name, size string, storageClass *string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: storageClass,
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	}
+	blockClaim := newClaim("dest", "block", "1Gi", nil)
+	blockClaim.Spec.VolumeMode = &block
+	src := newClaim("src", "data", "1Gi", nil)
+
+	tests := []struct {
+		name    string
+		pvcList PVCPairList
+		want    []error
+	}{
+		{
+			name: "valid",
+			pvcList: PVCPairList{
+				NewPVCPair(src, newClaim("dest", "data", "2Gi", &standard)),
+				NewPVCPair(newClaim("src", "other", "1Gi", nil), newClaim("dest", "other", "1Gi", nil)),
+			},
+		},
+		{
+			name:    "destination not set",
+			pvcList: PVCPairList{NewPVCPair(newClaim("src", "other", "1Gi", &missing), nil)},
+		},
+		{
+			name: "duplicate pair",
+			pvcList: PVCPairList{
+				NewPVCPair(src, newClaim("dest", "data", "1Gi", nil)),
+				NewPVCPair(src, newClaim("dest", "data", "1Gi", nil)),
+			},
+			want: []error{ErrDuplicatePVCPair},
+		},
+		{
+			name: "destination conflict",
+			pvcList: PVCPairList{
+				NewPVCPair(src, newClaim("dest", "data", "1Gi", nil)),
+				NewPVCPair(newClaim("src", "other", "1Gi", nil), newClaim("dest", "data", "1Gi", nil)),
+			},
+			want: []error{ErrDestinationConflict},
+		},
+		{
+			name:    "insufficient capacity",
+			pvcList: PVCPairList{NewPVCPair(src, newClaim("dest", "data", "512Mi", nil))},
+			want:    []error{ErrInsufficientCapacity},
+		},
+		{
+			name:    "volume mode mismatch",
+			pvcList: PVCPairList{NewPVCPair(src, blockClaim)},
+			want:    []error{ErrVolumeModeMismatch},
+		},
+		{
+			name:    "storage class not found",
+			pvcList: PVCPairList{NewPVCPair(src, newClaim("dest", "data", "1Gi", &missing))},
+			want:    []error{ErrStorageClassNotFound},
+		},
+		{
+			name: "namespace mismatch",
+			pvcList: PVCPairList{
+				NewPVCPair(src, newClaim("dest", "data", "1Gi", nil)),
+				NewPVCPair(newClaim("src", "other", "1Gi", nil), newClaim("other-dest", "other", "1Gi", nil)),
+				NewPVCPair(newClaim("", "data", "1Gi", nil), newClaim("dest", "nons", "1Gi", nil)),
+			},
+			want: []error{ErrNamespaceMismatch, ErrNamespaceMismatch},
+		},
+	}
+	dest := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: standard},
+	}).Build()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pvcList.Validate(context.TODO(), dest)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error %v", err)
+				}
+				return
+			}
+			agg, ok := err.(errorsutil.Aggregate)
+			if !ok || len(agg.Errors()) != len(tt.want) {
+				t.Fatalf("Validate() = %v, want %d errors", err, len(tt.want))
+			}
+			for i, want := range tt.want {
+				pairErr := &PVCPairError{}
+				if !errors.As(agg.Errors()[i], &pairErr) || !errors.Is(pairErr, want) {
+					t.Errorf("error %d = %v, want a pair error wrapping %v", i, agg.Errors()[i], want)
+				}
+			}
+		})
+	}
+
+	// the storage classes are not checked without a destination client
+	pvcList := PVCPairList{NewPVCPair(src, newClaim("dest", "data", "1Gi", &missing))}
+	if err := pvcList.Validate(context.TODO(), nil); err != nil {
+		t.Errorf("Validate() without destination client unexpected error %v", err)
+	}
+}
+
+func TestNewPVCPairWithFilter(t *testing.T) {
+	claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"}}
+	pair, err := NewPVCPairWithSourcePath(claim, nil, SourcePath{Path: "app"})
+	if err != nil {
+		t.Fatalf("NewPVCPairWithSourcePath() unexpected error %v", err)
+	}
+	filter := Filter{Include: []string{"*.db"}, Exclude: []string{"/cache/", "lost+found"}}
+	pair, err = NewPVCPairWithFilter(pair, filter)
+	if err != nil {
+		t.Fatalf("NewPVCPairWithFilter() unexpected error %v", err)
+	}
+	pvcList, err := NewFilesystemPVCPairList(pair)
+	if err != nil {
+		t.Fatalf("NewFilesystemPVCPairList() unexpected error %v", err)
+	}
+	if got := GetFilter(pvcList[0]); !reflect.DeepEqual(got, filter) {
+		t.Errorf("GetFilter() = %v, want %v", got, filter)
+	}
+	if got := GetSourcePath(pvcList[0]); got.Path != "app" {
+		t.Errorf("GetSourcePath() = %v, the source path should be kept", got)
+	}
+	if !GetFilter(NewPVCPair(claim, nil)).IsEmpty() {
+		t.Errorf("a pair without filter should transfer every file")
+	}
+
+	for _, invalid := range []Filter{
+		{Exclude: []string{""}},
+		{Exclude: []string{"my cache"}},
+		{Include: []string{"$(reboot)"}},
+		{Exclude: []string{"[abc"}},
+	} {
+		if _, err := NewPVCPairWithFilter(NewPVCPair(claim, nil), invalid); err == nil {
+			t.Errorf("filter %v should be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PVCTransferPhase is where the transfer of a single PVC is in its lifecycle
+type PVCTransferPhase string
+
+const (
+	// PVCTransferPhasePending means the PVC is not being transferred yet
+	PVCTransferPhasePending PVCTransferPhase = "Pending"
+	// PVCTransferPhaseTransferring means the data of the PVC is being transferred
+	PVCTransferPhaseTransferring PVCTransferPhase = "Transferring"
+	// PVCTransferPhaseCompleted means the data of the PVC was transferred successfully
+	PVCTransferPhaseCompleted PVCTransferPhase = "Completed"
+	// PVCTransferPhaseFailed means the last attempt to transfer the PVC failed or was cancelled
+	PVCTransferPhaseFailed PVCTransferPhase = "Failed"
+)
+
+// PVCTransferState is the observed state of the transfer of a single PVC
+type PVCTransferState struct {
+	// Phase is the current phase of the transfer of the PVC
+	Phase PVCTransferPhase
+	// BytesTransferred is the amount of data of the PVC transferred so far
+	BytesTransferred int64
+}
+
+// PVCStatusReporter knows how to report the state of every PVC of a transfer
+type PVCStatusReporter interface {
+	// PVCStatus returns the state of every PVC pair of the transfer, the client must be able to
+	// reach the cluster the transfer client runs in
+	PVCStatus(ctx context.Context, c client.Client) (map[PVCPair]PVCTransferState, error)
+}
+
+// PVCStatus returns the state of every PVC pair of the transfer, so that a controller can report
+// the progress of a transfer of many PVCs and retry only the PVCs which failed
+func PVCStatus(ctx context.Context, t Transfer, c client.Client) (map[PVCPair]PVCTransferState, error) {
+	reporter, ok := t.(PVCStatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("transfer does not report the status of its PVCs")
+	}
+	return reporter.PVCStatus(ctx, c)
+}
+
+// PVCPhaseFromPod returns the phase of the transfer of a PVC given the latest transfer client pod
+// of the PVC, nil when no client pod was created for it yet
+func PVCPhaseFromPod(pod *corev1.Pod) PVCTransferPhase {
+	switch {
+	case pod == nil:
+		return PVCTransferPhasePending
+	case IsCancelled(pod):
+		return PVCTransferPhaseFailed
+	}
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		return PVCTransferPhaseTransferring
+	case corev1.PodSucceeded:
+		return PVCTransferPhaseCompleted
+	case corev1.PodFailed:
+		return PVCTransferPhaseFailed
+	}
+	return PVCTransferPhasePending
+}
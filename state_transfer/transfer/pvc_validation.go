@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrDuplicatePVCPair is returned when the same source and destination pair is listed twice
+var ErrDuplicatePVCPair = errors.New("duplicate pvc pair")
+
+// ErrDestinationConflict is returned when several source PVCs are transferred to the same destination
+var ErrDestinationConflict = errors.New("destination pvc conflict")
+
+// ErrInsufficientCapacity is returned when a destination PVC is smaller than its source
+var ErrInsufficientCapacity = errors.New("insufficient destination capacity")
+
+// ErrVolumeModeMismatch is returned when the source and the destination PVCs have different volume modes
+var ErrVolumeModeMismatch = errors.New("volume mode mismatch")
+
+// ErrStorageClassNotFound is returned when the StorageClass of a destination PVC does not exist
+var ErrStorageClassNotFound = errors.New("storage class not found")
+
+// ErrNamespaceMismatch is returned when the PVCs of a source namespace are not all transferred to the
+// same destination namespace, or when a PVC has no namespace
+var ErrNamespaceMismatch = errors.New("namespace mismatch")
+
+// PVCPairError is the reason a pair of a PVCPairList is invalid, Err wraps one of the errors above
+type PVCPairError struct {
+	Source      types.NamespacedName
+	Destination types.NamespacedName
+	Err         error
+}
+
+func (e *PVCPairError) Error() string {
+	return fmt.Sprintf("pvc pair %s -> %s: %v", e.Source, e.Destination, e.Err)
+}
+
+func (e *PVCPairError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the list before any resource is created. It reports duplicate pairs, destinations
+// shared by several sources, destinations smaller than their sources, mismatched volume modes, source
+// namespaces transferred to several destination namespaces and, when the destination client is not
+// nil, StorageClasses of destination PVCs which do not exist in the destination cluster. The error is
+// an aggregate holding a *PVCPairError for every problem found, errors.Is can be used with the errors
+// above. Destinations which are not set, and thus are the source, are only checked for conflicts.
+func (p PVCPairList) Validate(ctx context.Context, dest client.Client) error {
+	errs := []error{}
+	pairError := func(pair PVCPair, err error) {
+		errs = append(errs, &PVCPairError{
+			Source:      namespacedName(pair.Source().Claim()),
+			Destination: namespacedName(pair.Destination().Claim()),
+			Err:         err,
+		})
+	}
+
+	seenPairs := map[string]bool{}
+	seenDestinations := map[string]types.NamespacedName{}
+	destinationNamespaces := map[string]string{}
+	storageClasses := map[string]error{}
+	for _, pair := range p {
+		src, dst := pair.Source().Claim(), pair.Destination().Claim()
+		srcName, dstName := namespacedName(src), namespacedName(dst)
+
+		pairKey := srcName.String() + "->" + dstName.String()
+		if seenPairs[pairKey] {
+			pairError(pair, fmt.Errorf("%w: the pair is listed more than once", ErrDuplicatePVCPair))
+			continue
+		}
+		seenPairs[pairKey] = true
+		if other, ok := seenDestinations[dstName.String()]; ok {
+			pairError(pair, fmt.Errorf("%w: %s is also the destination of %s", ErrDestinationConflict, dstName, other))
+		}
+		seenDestinations[dstName.String()] = srcName
+
+		if srcName.Namespace == "" || dstName.Namespace == "" {
+			pairError(pair, fmt.Errorf("%w: the source and the destination must have a namespace", ErrNamespaceMismatch))
+		} else if ns, ok := destinationNamespaces[srcName.Namespace]; ok && ns != dstName.Namespace {
+			pairError(pair, fmt.Errorf("%w: pvcs of namespace %s are transferred to namespaces %s and %s",
+				ErrNamespaceMismatch, srcName.Namespace, ns, dstName.Namespace))
+		} else {
+			destinationNamespaces[srcName.Namespace] = dstName.Namespace
+		}
+
+		if src == dst {
+			continue
+		}
+
+		if volumeMode(src) != volumeMode(dst) {
+			pairError(pair, fmt.Errorf("%w: the source is %s and the destination is %s", ErrVolumeModeMismatch, volumeMode(src), volumeMode(dst)))
+		}
+
+		if request, ok := dst.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+			if size := EstimatePVCSize(pair.Source()); size > request.Value() {
+				pairError(pair, fmt.Errorf("%w: the destination requests %s, the source holds up to %d bytes",
+					ErrInsufficientCapacity, request.String(), size))
+			}
+		}
+
+		if dest == nil || dst.Spec.StorageClassName == nil || *dst.Spec.StorageClassName == "" {
+			continue
+		}
+		name := *dst.Spec.StorageClassName
+		err, checked := storageClasses[name]
+		if !checked {
+			err = dest.Get(ctx, client.ObjectKey{Name: name}, &storagev1.StorageClass{})
+			storageClasses[name] = err
+		}
+		if k8serrors.IsNotFound(err) {
+			pairError(pair, fmt.Errorf("%w: storage class %s does not exist in the destination cluster", ErrStorageClassNotFound, name))
+		} else if err != nil && !checked {
+			errs = append(errs, fmt.Errorf("unable to get storage class %s: %w", name, err))
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func namespacedName(pvc *v1.PersistentVolumeClaim) types.NamespacedName {
+	return types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+}
+
+func volumeMode(pvc *v1.PersistentVolumeClaim) v1.PersistentVolumeMode {
+	if pvc.Spec.VolumeMode == nil {
+		return v1.PersistentVolumeFilesystem
+	}
+	return *pvc.Spec.VolumeMode
+}
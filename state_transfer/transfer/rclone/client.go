@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"text/template"
 
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 
@@ -21,20 +22,40 @@ url = http://{{ .username }}:{{ .password }}@{{ .hostname }}:{{ .port }}
 `
 )
 
-func (r *RcloneTransfer) CreateClient(c client.Client) error {
+func (r *RcloneTransfer) CreateClient(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetSourceNamespaces()[0])
+	err := r.createClient(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create rclone client")
+	}
+	return err
+}
+
+func (r *RcloneTransfer) createClient(ctx context.Context, c client.Client) error {
 	pvc := r.pvcList[0]
 
-	err := createRcloneClientResources(c, r, pvc)
+	if r.options.SnapshotSource != nil {
+		err := transfer.CreateSnapshotSources(ctx, c, r.options.SnapshotSource, r.pvcList)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.options.Remote != nil {
+		return createRemoteClient(ctx, c, r, pvc)
+	}
+
+	err := createRcloneClientResources(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
 
-	_, err = transport.CreateClient(r.Transport(), c, "", r.Endpoint())
+	_, err = transport.CreateClient(ctx, r.Transport(), c, "", r.Endpoint())
 	if err != nil {
 		return err
 	}
 
-	err = createRcloneClient(c, r, pvc)
+	err = createRcloneClient(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
@@ -42,8 +63,8 @@ func (r *RcloneTransfer) CreateClient(c client.Client) error {
 	return nil
 }
 
-func createRcloneClientResources(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
-	err := createRcloneClientConfig(c, r, pvc)
+func createRcloneClientResources(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+	err := createRcloneClientConfig(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
@@ -51,7 +72,7 @@ func createRcloneClientResources(c client.Client, r *RcloneTransfer, pvc transfe
 	return nil
 }
 
-func createRcloneClientConfig(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+func createRcloneClientConfig(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
 	var rcloneConf bytes.Buffer
 	rcloneConfTemplate, err := template.New("config").Parse(rcloneClientConfTemplate)
 	if err != nil {
@@ -69,30 +90,33 @@ func createRcloneClientConfig(c client.Client, r *RcloneTransfer, pvc transfer.P
 	if err != nil {
 		return err
 	}
+	// the config holds the credentials of the server, only the connection is logged
+	r.log.V(1).Info("rendered rclone client config", "namespace", pvc.Source().Claim().Namespace,
+		"pvc", pvc.Source().Claim().Name, "hostname", coordinates["hostname"], "port", coordinates["port"])
 
 	rcloneConfigMap := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: pvc.Source().Claim().Namespace,
 			Name:      rcloneConfigPrefix + pvc.Source().LabelSafeName(),
-			Labels:    r.Endpoint().Labels(),
+			Labels:    meta.WithOwnerLabel(r.Endpoint().Labels()),
 		},
 		Data: map[string]string{
 			"rclone.conf": string(rcloneConf.Bytes()),
 		},
 	}
 
-	return c.Create(context.TODO(), rcloneConfigMap, &client.CreateOptions{})
+	return meta.Apply(ctx, c, rcloneConfigMap)
 }
 
-func createRcloneClient(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+func createRcloneClient(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
 	podLabels := r.Endpoint().Labels()
 	podLabels["pvc"] = pvc.Source().LabelSafeName()
 
 	containers := []v1.Container{
 		{
-			Name:  "rclone",
+			Name:  RcloneContainer,
 			Image: rcloneImage,
-			Command: []string{
+			Command: append([]string{
 				"/usr/bin/rclone",
 				"sync",
 				"remote:/",
@@ -101,7 +125,7 @@ func createRcloneClient(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 				"/etc/rclone.conf",
 				"--http-headers",
 				"Host," + r.Endpoint().Hostname(),
-			},
+			}, r.options.syncFlags()...),
 			VolumeMounts: []v1.VolumeMount{
 				{
 					Name:      "mnt",
@@ -125,7 +149,7 @@ func createRcloneClient(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 			Name: "mnt",
 			VolumeSource: v1.VolumeSource{
 				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-					ClaimName: pvc.Source().Claim().Name,
+					ClaimName: r.sourceClaimName(pvc),
 				},
 			},
 		},
@@ -149,7 +173,9 @@ func createRcloneClient(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      pvc.Source().Claim().Name,
 			Namespace: pvc.Source().Claim().Namespace,
-			Labels:    podLabels,
+			Labels:    meta.WithOwnerLabel(podLabels),
+			// the limit is enforced on the client pod like --bwlimit
+			Annotations: r.options.Bandwidth.PodAnnotations(),
 		},
 		Spec: v1.PodSpec{
 			Containers:    containers,
@@ -158,5 +184,14 @@ func createRcloneClient(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 		},
 	}
 
-	return c.Create(context.TODO(), &pod, &client.CreateOptions{})
+	return meta.Apply(ctx, c, &pod)
+}
+
+// sourceClaimName returns the name of the PVC the client of the given pair mounts, the clone of the
+// source PVC with SnapshotSource
+func (r *RcloneTransfer) sourceClaimName(pvc transfer.PVCPair) string {
+	if r.options.SnapshotSource != nil {
+		return transfer.SnapshotClaimName(pvc.Source())
+	}
+	return pvc.Source().Claim().Name
 }
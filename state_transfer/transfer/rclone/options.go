@@ -0,0 +1,136 @@
+package rclone
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RemoteType is the rclone backend of a remote
+type RemoteType string
+
+const (
+	RemoteTypeS3     RemoteType = "s3"
+	RemoteTypeSFTP   RemoteType = "sftp"
+	RemoteTypeWebDAV RemoteType = "webdav"
+)
+
+var (
+	// parameterKeyRegex matches the option names of rclone backends
+	parameterKeyRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+	// bandwidthLimitRegex matches the values accepted by --bwlimit, including timetables
+	bandwidthLimitRegex = regexp.MustCompile(`^[0-9A-Za-z.:,| -]+$`)
+)
+
+// Remote is an rclone remote the data goes through instead of the rclone HTTP server
+type Remote struct {
+	// Type is the backend of the remote
+	Type RemoteType
+	// Parameters are the options of the backend written in rclone.conf, e.g. provider, region
+	// and endpoint for s3, host, user and port for sftp, url and vendor for webdav. They are
+	// stored in a ConfigMap and must not hold credentials.
+	Parameters map[string]string
+	// Path is the directory of the remote the PVCs are copied to, e.g. bucket/path for s3. It
+	// must be unique to the transfer.
+	Path string
+	// CredentialsSecret is the name of a Secret whose keys are set as environment variables of
+	// the transfer pods, holding the credentials of the remote as RCLONE_CONFIG_REMOTE_<OPTION>
+	// variables, e.g. RCLONE_CONFIG_REMOTE_SECRET_ACCESS_KEY. It must exist in the source and the
+	// destination namespaces. Optional.
+	CredentialsSecret string
+}
+
+// TransferOptions are the options of an rclone transfer
+type TransferOptions struct {
+	// Remote is the remote the data goes through, the source copies the PVC to the remote and the
+	// destination copies it from the remote once done. The transport and the endpoint are not
+	// used. When nil, the destination serves the PVC over HTTP through the transport.
+	Remote *Remote
+	// BandwidthLimit limits the bandwidth of the transfer, e.g. 10M, see --bwlimit. It accepts the
+	// timetables of rclone and takes precedence over Bandwidth.
+	BandwidthLimit string
+	// Bandwidth limits the bandwidth of the transfer with --bwlimit, and optionally with the egress
+	// bandwidth annotation of the client pod. transfer.GlobalBandwidthLimit applies when neither
+	// BandwidthLimit nor Bandwidth is set.
+	Bandwidth *transfer.BandwidthLimit
+	// Checksum compares files by checksum instead of size and modification time, see --checksum
+	Checksum bool
+	// SnapshotSource makes the client copy a clone of the source PVC provisioned from a CSI
+	// VolumeSnapshot taken when the client is created, see transfer.SnapshotSource
+	SnapshotSource *transfer.SnapshotSource
+	// DestinationPVCs makes the server create the destination PVC when it does not exist, see
+	// transfer.DestinationPVCOptions. It is ignored with Remote.
+	DestinationPVCs *transfer.DestinationPVCOptions
+}
+
+// syncFlags returns the flags of rclone sync for the options
+func (o *TransferOptions) syncFlags() []string {
+	flags := []string{}
+	if o.BandwidthLimit != "" {
+		flags = append(flags, "--bwlimit", o.BandwidthLimit)
+	} else if o.Bandwidth != nil {
+		flags = append(flags, "--bwlimit", fmt.Sprintf("%dK", o.Bandwidth.KiBPerSecond()))
+	}
+	if o.Checksum {
+		flags = append(flags, "--checksum")
+	}
+	return flags
+}
+
+// validateOptions validates the options provided to rclone transfer
+func validateOptions(options *TransferOptions) error {
+	validationErrors := []error{}
+	if options.BandwidthLimit != "" && !bandwidthLimitRegex.MatchString(options.BandwidthLimit) {
+		validationErrors = append(validationErrors, fmt.Errorf("invalid bandwidth limit %q", options.BandwidthLimit))
+	}
+	if options.Remote != nil {
+		validationErrors = append(validationErrors, validateRemote(options.Remote))
+	}
+	if options.Bandwidth != nil {
+		validationErrors = append(validationErrors, options.Bandwidth.Validate())
+	}
+	if options.SnapshotSource != nil {
+		for _, name := range []string{options.SnapshotSource.VolumeSnapshotClassName, options.SnapshotSource.StorageClassName} {
+			if errs := validation.IsDNS1123Subdomain(name); name != "" && len(errs) > 0 {
+				validationErrors = append(validationErrors, fmt.Errorf("invalid class name %q of snapshot source", name))
+			}
+		}
+	}
+	if options.DestinationPVCs != nil {
+		validationErrors = append(validationErrors, options.DestinationPVCs.Validate())
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+// validateRemote validates the remote, the parameters and the path are written in rclone.conf
+// and in shell scripts so they cannot hold line breaks nor quotes
+func validateRemote(remote *Remote) error {
+	validationErrors := []error{}
+	switch remote.Type {
+	case RemoteTypeS3, RemoteTypeSFTP, RemoteTypeWebDAV:
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("unsupported remote type %q", remote.Type))
+	}
+	for key, value := range remote.Parameters {
+		if !parameterKeyRegex.MatchString(key) || key == "type" {
+			validationErrors = append(validationErrors, fmt.Errorf("invalid remote parameter %q", key))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			validationErrors = append(validationErrors, fmt.Errorf("remote parameter %s cannot contain line breaks", key))
+		}
+	}
+	if remote.Path == "" || strings.ContainsAny(remote.Path, "'\r\n") {
+		validationErrors = append(validationErrors, fmt.Errorf("invalid remote path %q", remote.Path))
+	}
+	if remote.CredentialsSecret != "" {
+		if errs := validation.IsDNS1123Subdomain(remote.CredentialsSecret); len(errs) > 0 {
+			validationErrors = append(validationErrors,
+				fmt.Errorf("credentials secret %q must be a valid secret name", remote.CredentialsSecret))
+		}
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
@@ -1,17 +1,26 @@
 package rclone
 
 import (
+	"github.com/go-logr/logr"
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// RcloneContainer is the name of the rclone container in transfer pods
+	RcloneContainer = "rclone"
+)
+
 const (
 	rcloneUser         = "crane2"
 	rcloneImage        = "quay.io/jmontleon/rclone-transfer:latest"
 	rclonePort         = int32(8080)
 	rcloneConfigPrefix = "crane2-rclone-config-"
+	// metricsTransferType is the transfer label of the metrics of rclone transfers
+	metricsTransferType = "rclone"
 )
 
 type RcloneTransfer struct {
@@ -23,19 +32,46 @@ type RcloneTransfer struct {
 	transport   transport.Transport
 	endpoint    endpoint.Endpoint
 	port        int32
+	options     *TransferOptions
+	log         logr.Logger
+}
+
+func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger) (transfer.Transfer, error) {
+	return NewTransferWithOptions(t, e, src, dest, pvcList, log, &TransferOptions{})
 }
 
-func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList) (transfer.Transfer, error) {
+// NewTransferWithOptions creates an rclone transfer with the given options. When the options have
+// a remote, the transport and the endpoint are ignored and can be nil.
+func NewTransferWithOptions(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger, options *TransferOptions) (transfer.Transfer, error) {
+	if options == nil {
+		options = &TransferOptions{}
+	}
 	err := validatePVCList(pvcList)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	if options.BandwidthLimit == "" && options.Bandwidth == nil && transfer.GlobalBandwidthLimit != nil {
+		// the options of the caller are left untouched
+		withGlobalLimit := *options
+		withGlobalLimit.Bandwidth = transfer.GlobalBandwidthLimit
+		options = &withGlobalLimit
+	}
+	if options.Remote != nil {
+		t, e = nil, nil
+	} else if err := transport.ValidateCompatibility(endpoint.ProtocolHTTP, t, e); err != nil {
+		return nil, err
+	}
 	return &RcloneTransfer{
+		log:         meta.LoggerOrDiscard(log).WithValues("transfer", "rclone"),
 		transport:   t,
 		endpoint:    e,
 		source:      src,
 		destination: dest,
 		pvcList:     pvcList,
+		options:     options,
 	}, nil
 }
 
@@ -51,6 +87,15 @@ func (r *RcloneTransfer) Transport() transport.Transport {
 	return r.transport
 }
 
+// Copy returns a copy of the transfer with copies of its endpoint and transport, see
+// transfer.Copier
+func (r *RcloneTransfer) Copy() transfer.Transfer {
+	copied := *r
+	copied.endpoint = endpoint.Copy(r.endpoint)
+	copied.transport = transport.Copy(r.transport)
+	return &copied
+}
+
 func (r *RcloneTransfer) Source() client.Client {
 	return r.source
 }
@@ -66,3 +111,8 @@ func (r *RcloneTransfer) Username() string {
 func (r *RcloneTransfer) Password() string {
 	return r.password
 }
+
+// Options returns the options of the transfer
+func (r *RcloneTransfer) Options() *TransferOptions {
+	return r.options
+}
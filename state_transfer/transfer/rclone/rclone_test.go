@@ -0,0 +1,319 @@
+package rclone
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testPVCName       = "test-pvc"
+)
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *TransferOptions
+		wantErr bool
+	}{
+		{
+			name:    "no options",
+			options: &TransferOptions{},
+		},
+		{
+			name:    "bandwidth limit timetable",
+			options: &TransferOptions{BandwidthLimit: "08:00,512k 19:00,off", Checksum: true},
+		},
+		{
+			name:    "invalid bandwidth limit",
+			options: &TransferOptions{BandwidthLimit: "10M'; rm -rf /"},
+			wantErr: true,
+		},
+		{
+			name: "sftp remote",
+			options: &TransferOptions{Remote: &Remote{
+				Type:              RemoteTypeSFTP,
+				Parameters:        map[string]string{"host": "sftp.example.com", "user": "crane"},
+				Path:              "backups",
+				CredentialsSecret: "sftp-credentials",
+			}},
+		},
+		{
+			name:    "unsupported remote",
+			options: &TransferOptions{Remote: &Remote{Type: "ftp", Path: "backups"}},
+			wantErr: true,
+		},
+		{
+			name: "remote parameter with line break",
+			options: &TransferOptions{Remote: &Remote{
+				Type:       RemoteTypeWebDAV,
+				Parameters: map[string]string{"url": "https://dav.example.com\n[other]"},
+				Path:       "backups",
+			}},
+			wantErr: true,
+		},
+		{
+			name: "remote type parameter",
+			options: &TransferOptions{Remote: &Remote{
+				Type:       RemoteTypeS3,
+				Parameters: map[string]string{"type": "local"},
+				Path:       "bucket",
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "remote without path",
+			options: &TransferOptions{Remote: &Remote{Type: RemoteTypeS3}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateOptions(tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("validateOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteTransfer(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	options := &TransferOptions{
+		Remote: &Remote{
+			Type: RemoteTypeS3,
+			Parameters: map[string]string{
+				"provider": "AWS",
+				"region":   "us-east-1",
+			},
+			Path:              "bucket/crane/",
+			CredentialsSecret: "s3-credentials",
+		},
+		BandwidthLimit: "10M",
+		Checksum:       true,
+	}
+	tr, err := NewTransferWithOptions(nil, nil, src, dest, testPVCList(), nil, options)
+	if err != nil {
+		t.Fatalf("NewTransferWithOptions() unexpected error %v", err)
+	}
+	if tr.Endpoint() != nil || tr.Transport() != nil {
+		t.Errorf("remote transfers should not have an endpoint nor a transport")
+	}
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	// the resources are applied, creating them again updates them
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() should apply existing resources, got %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() should apply existing resources, got %v", err)
+	}
+
+	pvc := tr.PVCs()[0]
+	cm := &corev1.ConfigMap{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rcloneConfigPrefix + pvc.Source().LabelSafeName()}, cm); err != nil {
+		t.Fatalf("unable to get client config: %v", err)
+	}
+	if want := "[remote]\ntype = s3\nprovider = AWS\nregion = us-east-1\n"; cm.Data["rclone.conf"] != want {
+		t.Errorf("rclone.conf = %q, want %q", cm.Data["rclone.conf"], want)
+	}
+
+	clientPod := &corev1.Pod{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, clientPod); err != nil {
+		t.Fatalf("unable to get client pod: %v", err)
+	}
+	serverPod := &corev1.Pod{}
+	if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: testPVCName}, serverPod); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	dir := "remote:bucket/crane/" + testNamespace + "/" + testPVCName
+	for pod, want := range map[*corev1.Pod]string{
+		clientPod: "rclone sync /mnt '" + dir + "' '--bwlimit' '10M' '--checksum'",
+		serverPod: "rclone sync '" + dir + "' /mnt '--bwlimit' '10M' '--checksum'",
+	} {
+		container := pod.Spec.Containers[0]
+		if !strings.Contains(container.Command[2], want) {
+			t.Errorf("pod %s script does not contain %q:\n%s", pod.Name, want, container.Command[2])
+		}
+		if !strings.Contains(container.Command[2], dir+rcloneCompleteSuffix) {
+			t.Errorf("pod %s script does not use the completion marker:\n%s", pod.Name, container.Command[2])
+		}
+		if container.EnvFrom[0].SecretRef.Name != "s3-credentials" {
+			t.Errorf("pod %s does not use the credentials secret", pod.Name)
+		}
+	}
+
+	if healthy, err := tr.IsServerHealthy(context.TODO(), dest); healthy || err == nil {
+		t.Errorf("IsServerHealthy() = %v, %v, the server pod is not running", healthy, err)
+	}
+	serverPod.Status.Phase = corev1.PodRunning
+	if err := dest.Status().Update(context.TODO(), serverPod); err != nil {
+		t.Fatalf("unable to update server pod: %v", err)
+	}
+	if healthy, err := tr.IsServerHealthy(context.TODO(), dest); !healthy || err != nil {
+		t.Errorf("IsServerHealthy() = %v, %v, the server pod is running", healthy, err)
+	}
+}
+
+func TestSnapshotSource(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	options := &TransferOptions{
+		Remote: &Remote{
+			Type: RemoteTypeS3,
+			Path: "bucket/crane",
+		},
+		SnapshotSource: &transfer.SnapshotSource{VolumeSnapshotClassName: "csi-snapclass"},
+	}
+	tr, err := NewTransferWithOptions(nil, nil, src, dest, testPVCList(), nil, options)
+	if err != nil {
+		t.Fatalf("NewTransferWithOptions() unexpected error %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+
+	pvc := tr.PVCs()[0].Source()
+	clone := &corev1.PersistentVolumeClaim{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: transfer.SnapshotClaimName(pvc)}, clone); err != nil {
+		t.Fatalf("unable to get the clone of the source pvc: %v", err)
+	}
+	if clone.Spec.DataSource == nil || clone.Spec.DataSource.Name != transfer.SnapshotName(pvc) {
+		t.Errorf("clone should be provisioned from the snapshot, data source %v", clone.Spec.DataSource)
+	}
+	clientPod := &corev1.Pod{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, clientPod); err != nil {
+		t.Fatalf("unable to get client pod: %v", err)
+	}
+	if claim := clientPod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName; claim != clone.Name {
+		t.Errorf("client pod mounts %s, want the clone %s", claim, clone.Name)
+	}
+}
+
+func TestBandwidth(t *testing.T) {
+	transfer.GlobalBandwidthLimit = &transfer.BandwidthLimit{Rate: resource.MustParse("1Mi"), EgressAnnotation: true}
+	defer func() { transfer.GlobalBandwidthLimit = nil }()
+	tests := []struct {
+		name           string
+		options        *TransferOptions
+		wantBwLimit    string
+		wantAnnotation string
+	}{
+		{
+			name:           "global",
+			options:        &TransferOptions{},
+			wantBwLimit:    "'--bwlimit' '1024K'",
+			wantAnnotation: "8388608",
+		},
+		{
+			name:        "bandwidth",
+			options:     &TransferOptions{Bandwidth: &transfer.BandwidthLimit{Rate: resource.MustParse("10Mi")}},
+			wantBwLimit: "'--bwlimit' '10240K'",
+		},
+		{
+			name:        "timetable over global",
+			options:     &TransferOptions{BandwidthLimit: "08:00,512k 19:00,off"},
+			wantBwLimit: "'--bwlimit' '08:00,512k 19:00,off'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dest := buildTestClient(), buildTestClient()
+			tt.options.Remote = &Remote{Type: RemoteTypeS3, Path: "bucket/crane"}
+			tr, err := NewTransferWithOptions(nil, nil, src, dest, testPVCList(), nil, tt.options)
+			if err != nil {
+				t.Fatalf("NewTransferWithOptions() unexpected error %v", err)
+			}
+			if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+				t.Fatalf("CreateClient() unexpected error %v", err)
+			}
+			clientPod := &corev1.Pod{}
+			if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, clientPod); err != nil {
+				t.Fatalf("unable to get client pod: %v", err)
+			}
+			if script := clientPod.Spec.Containers[0].Command[2]; !strings.Contains(script, tt.wantBwLimit) {
+				t.Errorf("client script does not contain %s:\n%s", tt.wantBwLimit, script)
+			}
+			if got := clientPod.Annotations[transfer.EgressBandwidthAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("egress bandwidth annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+
+	if _, err := NewTransferWithOptions(nil, nil, buildTestClient(), buildTestClient(), testPVCList(), nil, &TransferOptions{
+		Remote:    &Remote{Type: RemoteTypeS3, Path: "bucket/crane"},
+		Bandwidth: &transfer.BandwidthLimit{},
+	}); err == nil {
+		t.Errorf("bandwidth without a rate should return an error")
+	}
+}
+
+func buildTestClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+}
+
+func testPVCList() transfer.PVCPairList {
+	return transfer.PVCPairList{transfer.NewPVCPair(
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testPVCName}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: testDestNamespace, Name: testPVCName}},
+	)}
+}
+
+func TestStatus(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	pvcs := testPVCList()
+	pvcs[0].Source().Claim().Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+	tr, err := NewTransferWithOptions(nil, nil, src, dest, pvcs, nil, &TransferOptions{Remote: &Remote{Type: RemoteTypeS3, Path: "bucket/crane"}})
+	if err != nil {
+		t.Fatalf("NewTransferWithOptions() unexpected error %v", err)
+	}
+	r := tr.(*RcloneTransfer)
+	status, err := r.Status(context.TODO(), src)
+	if err != nil || status.Phase != transfer.TransferPhasePending {
+		t.Fatalf("Status() = %v, %v, want pending before the client is created", status, err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	pod := &corev1.Pod{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, pod); err != nil {
+		t.Fatalf("unable to get client pod: %v", err)
+	}
+	start := metav1.Now()
+	pod.Status = corev1.PodStatus{
+		Phase:     corev1.PodSucceeded,
+		StartTime: &start,
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         RcloneContainer,
+			RestartCount: 1,
+			State:        corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: start}},
+		}},
+	}
+	if err := src.Status().Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+	status, err = r.Status(context.TODO(), src)
+	if err != nil || status.Phase != transfer.TransferPhaseSucceeded || status.Attempts != 2 {
+		t.Errorf("Status() = %+v, %v, want succeeded after 2 attempts", status, err)
+	}
+	pvcStatus, err := transfer.PVCStatus(context.TODO(), tr, src)
+	if state := pvcStatus[pvcs[0]]; err != nil || state.Phase != transfer.PVCTransferPhaseCompleted || state.BytesTransferred != 1024*1024*1024 {
+		t.Errorf("PVCStatus() = %v, %v, want the pvc completed", pvcStatus, err)
+	}
+	progress, err := r.Progress(context.TODO(), src)
+	if err != nil || !progress.Estimated || progress.BytesTransferred != 1024*1024*1024 {
+		t.Errorf("Progress() = %+v, %v, want the estimated size of the pvc", progress, err)
+	}
+}
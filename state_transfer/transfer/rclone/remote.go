@@ -0,0 +1,185 @@
+package rclone
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	rcloneRemoteConfTemplate = `[remote]
+type = {{ .Type }}
+{{- range $key, $value := .Parameters }}
+{{ $key }} = {{ $value }}
+{{- end }}
+`
+	// the marker is written once the whole PVC is in the remote
+	rcloneRemoteClientScript = `set -e
+rclone sync /mnt 'remote:%[1]s' %[3]s
+rclone touch 'remote:%[2]s'
+`
+	rcloneRemoteServerScript = `set -e
+until rclone lsf 'remote:%[2]s' >/dev/null 2>&1; do
+  echo "waiting for the source volume to be copied to the remote"
+  sleep 10
+done
+rclone sync 'remote:%[1]s' /mnt %[3]s
+`
+	rcloneCompleteSuffix = ".crane2-complete"
+	rcloneConfigPath     = "/etc/rclone.conf"
+)
+
+// remotePaths returns the directory of the remote holding the source PVC and the path of the
+// marker written once the PVC is copied
+func remotePaths(remote *Remote, pvc transfer.PVCPair) (string, string) {
+	dir := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(remote.Path, "/"),
+		pvc.Source().Claim().Namespace, pvc.Source().Claim().Name)
+	return dir, dir + rcloneCompleteSuffix
+}
+
+func remoteScript(script string, r *RcloneTransfer, pvc transfer.PVCPair) string {
+	dir, marker := remotePaths(r.options.Remote, pvc)
+	flags := []string{}
+	for _, flag := range r.options.syncFlags() {
+		flags = append(flags, "'"+flag+"'")
+	}
+	return fmt.Sprintf(script, dir, marker, strings.Join(flags, " "))
+}
+
+func createRemoteConfig(ctx context.Context, c client.Client, remote *Remote, pvc transfer.PVC) error {
+	var rcloneConf bytes.Buffer
+	rcloneConfTemplate, err := template.New("config").Parse(rcloneRemoteConfTemplate)
+	if err != nil {
+		return err
+	}
+	err = rcloneConfTemplate.Execute(&rcloneConf, remote)
+	if err != nil {
+		return err
+	}
+
+	rcloneConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pvc.Claim().Namespace,
+			Name:      rcloneConfigPrefix + pvc.LabelSafeName(),
+			Labels:    meta.WithOwnerLabel(meta.Labels),
+		},
+		Data: map[string]string{
+			"rclone.conf": rcloneConf.String(),
+		},
+	}
+
+	return meta.Apply(ctx, c, rcloneConfigMap)
+}
+
+// createRemotePod creates a pod running the given rclone script with the given claim mounted in
+// /mnt and the remote configured, the pod is named after the given PVC
+func createRemotePod(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVC, claimName string, annotations map[string]string, script string) error {
+	err := createRemoteConfig(ctx, c, r.options.Remote, pvc)
+	if err != nil {
+		return err
+	}
+
+	podLabels := map[string]string{"pvc": pvc.LabelSafeName()}
+	for key, val := range meta.Labels {
+		podLabels[key] = val
+	}
+
+	container := v1.Container{
+		Name:    RcloneContainer,
+		Image:   rcloneImage,
+		Command: []string{"/bin/sh", "-c", script},
+		Env: []v1.EnvVar{
+			{Name: "RCLONE_CONFIG", Value: rcloneConfigPath},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      "mnt",
+				MountPath: "/mnt",
+			},
+			{
+				Name:      rcloneConfigPrefix + pvc.LabelSafeName(),
+				MountPath: rcloneConfigPath,
+				SubPath:   "rclone.conf",
+			},
+		},
+	}
+	if r.options.Remote.CredentialsSecret != "" {
+		container.EnvFrom = []v1.EnvFromSource{
+			{
+				SecretRef: &v1.SecretEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: r.options.Remote.CredentialsSecret,
+					},
+				},
+			},
+		}
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvc.Claim().Name,
+			Namespace:   pvc.Claim().Namespace,
+			Labels:      meta.WithOwnerLabel(podLabels),
+			Annotations: annotations,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{container},
+			Volumes: []v1.Volume{
+				{
+					Name: "mnt",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: claimName,
+						},
+					},
+				},
+				{
+					Name: rcloneConfigPrefix + pvc.LabelSafeName(),
+					VolumeSource: v1.VolumeSource{
+						ConfigMap: &v1.ConfigMapVolumeSource{
+							LocalObjectReference: v1.LocalObjectReference{
+								Name: rcloneConfigPrefix + pvc.LabelSafeName(),
+							},
+						},
+					},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+		},
+	}
+
+	return meta.Apply(ctx, c, pod)
+}
+
+// createRemoteClient creates the pod copying the source PVC to the remote
+func createRemoteClient(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+	return createRemotePod(ctx, c, r, pvc.Source(), r.sourceClaimName(pvc), r.options.Bandwidth.PodAnnotations(), remoteScript(rcloneRemoteClientScript, r, pvc))
+}
+
+// createRemoteServer creates the pod waiting for the source PVC to be in the remote and copying
+// it to the destination PVC
+func createRemoteServer(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+	return createRemotePod(ctx, c, r, pvc.Destination(), pvc.Destination().Claim().Name, nil, remoteScript(rcloneRemoteServerScript, r, pvc))
+}
+
+// isRemoteServerHealthy returns whether the destination pod is running or has copied the PVC
+func isRemoteServerHealthy(ctx context.Context, c client.Client, pvc transfer.PVCPair) (bool, error) {
+	pod := &v1.Pod{}
+	key := client.ObjectKey{Namespace: pvc.Destination().Claim().Namespace, Name: pvc.Destination().Claim().Name}
+	if err := c.Get(ctx, key, pod); err != nil {
+		return false, err
+	}
+	if pod.Status.Phase != v1.PodRunning && pod.Status.Phase != v1.PodSucceeded {
+		return false, fmt.Errorf("rclone pod %s is not running", key)
+	}
+	return true, nil
+}
@@ -12,7 +12,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
 )
 
 const (
@@ -21,31 +23,61 @@ type = local
 `
 )
 
-func (r *RcloneTransfer) CreateServer(c client.Client) error {
+func (r *RcloneTransfer) CreateServer(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0])
+	err := r.createServer(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create rclone server")
+	}
+	return err
+}
+
+func (r *RcloneTransfer) createServer(ctx context.Context, c client.Client) error {
 	pvc := r.pvcList[0]
 
-	err := createRcloneServerResources(c, r, pvc)
+	if r.options.Remote != nil {
+		return createRemoteServer(ctx, c, r, pvc)
+	}
+
+	if r.options.DestinationPVCs != nil {
+		err := transfer.CreateDestinationPVCs(ctx, c, r.options.DestinationPVCs, transfer.PVCPairList{pvc})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := createRcloneServerResources(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
 
-	err = createRcloneServer(c, r, pvc)
+	err = createRcloneServer(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
 
-	_, err = endpoint.Create(r.Endpoint(), c)
+	_, err = endpoint.Create(ctx, r.Endpoint(), c)
 
 	return err
 }
 
-func (r *RcloneTransfer) IsServerHealthy(c client.Client) (bool, error) {
+func (r *RcloneTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := r.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
+}
+
+func (r *RcloneTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	if r.options.Remote != nil {
+		return isRemoteServerHealthy(ctx, c, r.pvcList[0])
+	}
 	deploymentLabels := r.Endpoint().Labels()
 	deploymentLabels["pvc"] = r.pvcList[0].Destination().LabelSafeName()
-	return transfer.AreFilteredPodsHealthy(c, r.pvcList.GetDestinationNamespaces()[0], deploymentLabels)
+	containers := append([]string{RcloneContainer}, transport.ServerContainerNames(r.Transport())...)
+	return transfer.AreFilteredPodsHealthy(ctx, c, r.pvcList.GetDestinationNamespaces()[0], deploymentLabels, containers...)
 }
 
-func createRcloneServerResources(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+func createRcloneServerResources(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
 	var letters = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	random.Seed(time.Now().UnixNano())
 	password := make([]byte, 24)
@@ -57,7 +89,7 @@ func createRcloneServerResources(c client.Client, r *RcloneTransfer, pvc transfe
 	r.port = rclonePort
 	r.username = rcloneUser
 
-	err := createRcloneServerConfig(c, r, pvc)
+	err := createRcloneServerConfig(ctx, c, r, pvc)
 	if err != nil {
 		return err
 	}
@@ -65,27 +97,27 @@ func createRcloneServerResources(c client.Client, r *RcloneTransfer, pvc transfe
 	return nil
 }
 
-func createRcloneServerConfig(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+func createRcloneServerConfig(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
 	rcloneConfigMap := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: pvc.Destination().Claim().Namespace,
 			Name:      rcloneConfigPrefix + pvc.Destination().LabelSafeName(),
-			Labels:    r.Endpoint().Labels(),
+			Labels:    meta.WithOwnerLabel(r.Endpoint().Labels()),
 		},
 		Data: map[string]string{
 			"rclone.conf": rcloneServerConf,
 		},
 	}
 
-	return c.Create(context.TODO(), rcloneConfigMap, &client.CreateOptions{})
+	return meta.Apply(ctx, c, rcloneConfigMap)
 }
 
-func createRcloneServer(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
+func createRcloneServer(ctx context.Context, c client.Client, r *RcloneTransfer, pvc transfer.PVCPair) error {
 	deploymentLabels := r.Endpoint().Labels()
 	deploymentLabels["pvc"] = pvc.Destination().LabelSafeName()
 	containers := []v1.Container{
 		{
-			Name:  "rclone",
+			Name:  RcloneContainer,
 			Image: rcloneImage,
 			Command: []string{
 				"/usr/bin/rclone",
@@ -151,7 +183,7 @@ func createRcloneServer(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      pvc.Destination().Claim().Name,
 			Namespace: pvc.Destination().Claim().Namespace,
-			Labels:    deploymentLabels,
+			Labels:    meta.WithOwnerLabel(deploymentLabels),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
@@ -169,5 +201,5 @@ func createRcloneServer(c client.Client, r *RcloneTransfer, pvc transfer.PVCPair
 		},
 	}
 
-	return c.Create(context.TODO(), server, &client.CreateOptions{})
+	return meta.Apply(ctx, c, server)
 }
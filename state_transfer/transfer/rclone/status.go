@@ -0,0 +1,105 @@
+package rclone
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/metrics"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Status returns the observed state of the transfer based on the rclone client pod. The pod
+// restarts rclone when it fails, every restart counts as an attempt.
+func (r *RcloneTransfer) Status(ctx context.Context, c client.Client) (*transfer.Status, error) {
+	pod, err := r.clientPod(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	pods := []v1.Pod{}
+	status := &transfer.Status{Parallelism: 1, Message: "rclone client pod not found"}
+	if pod != nil {
+		pods = append(pods, *pod)
+		status.Message = fmt.Sprintf("rclone client pod %s is %s", pod.Name, pod.Status.Phase)
+		status.Attempts = 1
+		if rclone := rcloneStatus(pod); rclone != nil {
+			status.Attempts += int(rclone.RestartCount)
+		}
+		if pod.Status.StartTime != nil {
+			metrics.ObserveDuration(metricsTransferType, metrics.TransferID("", r.pvcList), clientFinishTime(pod, time.Now()).Sub(pod.Status.StartTime.Time))
+		}
+	}
+	status.Phase = transfer.PhaseFromPods(pods)
+	return status, nil
+}
+
+// PVCStatus returns the state of the PVC of the transfer from the rclone client pod, the estimated
+// size of the PVC is counted as transferred once the client pod succeeded
+func (r *RcloneTransfer) PVCStatus(ctx context.Context, c client.Client) (map[transfer.PVCPair]transfer.PVCTransferState, error) {
+	pod, err := r.clientPod(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	pvc := r.pvcList[0]
+	state := transfer.PVCTransferState{Phase: transfer.PVCPhaseFromPod(pod)}
+	if state.Phase == transfer.PVCTransferPhaseCompleted {
+		state.BytesTransferred = transfer.EstimatePVCSize(pvc.Source())
+	}
+	status := map[transfer.PVCPair]transfer.PVCTransferState{pvc: state}
+	metrics.ObservePVCStatus(metricsTransferType, status)
+	return status, nil
+}
+
+// Progress returns the progress of the transfer, the estimated size of the PVC is counted as
+// transferred once the rclone client pod succeeded
+func (r *RcloneTransfer) Progress(ctx context.Context, c client.Client) (*transfer.Progress, error) {
+	pod, err := r.clientPod(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	progress := &transfer.Progress{Estimated: true}
+	if pod != nil && pod.Status.StartTime != nil {
+		progress.StartTime = pod.Status.StartTime.Time
+	}
+	if pod != nil && pod.Status.Phase == v1.PodSucceeded {
+		progress.BytesTransferred = transfer.EstimatePVCSize(r.pvcList[0].Source())
+	}
+	metrics.ObserveProgress(metricsTransferType, metrics.TransferID("", r.pvcList), progress)
+	return progress, nil
+}
+
+// clientPod returns the rclone client pod, nil when it was not created yet
+func (r *RcloneTransfer) clientPod(ctx context.Context, c client.Client) (*v1.Pod, error) {
+	claim := r.pvcList[0].Source().Claim()
+	pod := &v1.Pod{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: claim.Namespace, Name: claim.Name}, pod)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// rcloneStatus returns the status of the rclone container of the given pod, nil until it is known
+func rcloneStatus(pod *v1.Pod) *v1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == RcloneContainer {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// clientFinishTime returns when the rclone container of the given pod terminated, now while it
+// is still running
+func clientFinishTime(pod *v1.Pod, now time.Time) time.Time {
+	if rclone := rcloneStatus(pod); rclone != nil && rclone.State.Terminated != nil {
+		return rclone.State.Terminated.FinishedAt.Time
+	}
+	return now
+}
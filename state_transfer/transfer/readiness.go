@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TransferCompleteCondition is the pod condition set by UpdateReadinessGates, pods listing it in
+// their readiness gates are not ready until the transfer succeeded
+const TransferCompleteCondition corev1.PodConditionType = "crane.konveyor.io/transfer-complete"
+
+// ReadinessGate returns the readiness gate to add to the pods which must not become ready before
+// the transfer completes. In a pod spec, the gate is referenced as:
+//
+//	readinessGates:
+//	- conditionType: crane.konveyor.io/transfer-complete
+func ReadinessGate() corev1.PodReadinessGate {
+	return corev1.PodReadinessGate{ConditionType: TransferCompleteCondition}
+}
+
+// UpdateReadinessGates sets the TransferCompleteCondition of the pods matching the given labels in
+// the destination namespaces of the transfer, to true once the transfer succeeded and to false
+// otherwise, and returns whether the transfer succeeded. Pods which do not have the readiness gate
+// are left untouched. The status of the transfer is read with its source client and the pods are
+// updated with its destination client. Conditions are not watched by the kubelet, so it must be
+// called again, typically from a reconcile loop, until it returns true, and for pods created later.
+// Once set to true, the condition is never reset.
+func UpdateReadinessGates(ctx context.Context, t Transfer, podLabels map[string]string) (bool, error) {
+	reporter, ok := t.(StatusReporter)
+	if !ok {
+		return false, fmt.Errorf("transfer does not report its status")
+	}
+	status, err := reporter.Status(ctx, t.Source())
+	if err != nil {
+		return false, err
+	}
+	complete := status.Phase == TransferPhaseSucceeded
+	condition := corev1.PodCondition{
+		Type:    TransferCompleteCondition,
+		Status:  corev1.ConditionFalse,
+		Reason:  "Transfer" + string(status.Phase),
+		Message: status.Message,
+	}
+	if complete {
+		condition.Status = corev1.ConditionTrue
+	}
+
+	errs := []error{}
+	for _, ns := range t.PVCs().GetDestinationNamespaces() {
+		pods := &corev1.PodList{}
+		if err := t.Destination().List(ctx, pods, client.InNamespace(ns), client.MatchingLabels(podLabels)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !hasReadinessGate(pod) || !setPodCondition(pod, condition) {
+				continue
+			}
+			if err := t.Destination().Status().Update(ctx, pod); err != nil {
+				errs = append(errs, fmt.Errorf("unable to update readiness gate of pod %s/%s: %w", pod.Namespace, pod.Name, err))
+			}
+		}
+	}
+	return complete, errorsutil.NewAggregate(errs)
+}
+
+func hasReadinessGate(pod *corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == TransferCompleteCondition {
+			return true
+		}
+	}
+	return false
+}
+
+// setPodCondition sets the condition on the pod and returns whether the pod changed, a condition
+// which is already true is kept
+func setPodCondition(pod *corev1.Pod, condition corev1.PodCondition) bool {
+	for i := range pod.Status.Conditions {
+		existing := &pod.Status.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == corev1.ConditionTrue || (existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message) {
+			return false
+		}
+		if existing.Status != condition.Status {
+			existing.LastTransitionTime = metav1.Now()
+		}
+		existing.Status, existing.Reason, existing.Message = condition.Status, condition.Reason, condition.Message
+		return true
+	}
+	condition.LastTransitionTime = metav1.Now()
+	pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	return true
+}
@@ -0,0 +1,25 @@
+package transfer
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RerunChecker knows whether re-running a transfer over a partially migrated destination is safe
+type RerunChecker interface {
+	// CanRerunSafely returns whether the transfer can be re-run without corrupting the destination
+	// and the reason why, the client must be able to reach the cluster the transfer client runs in
+	CanRerunSafely(ctx context.Context, c client.Client) (bool, string, error)
+}
+
+// CanRerunSafely returns whether the given transfer can be re-run safely and the reason why, based
+// on the resume semantics of its implementation and the state of the previous run. Transfers which
+// do not implement RerunChecker are reported as unsafe.
+func CanRerunSafely(ctx context.Context, t Transfer, c client.Client) (bool, string, error) {
+	checker, ok := t.(RerunChecker)
+	if !ok {
+		return false, "transfer does not report whether it can be re-run safely", nil
+	}
+	return checker.CanRerunSafely(ctx, c)
+}
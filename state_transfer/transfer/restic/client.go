@@ -0,0 +1,54 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// the repository is initialized by the first backup, concurrent backups may race to do it
+	resticBackupScript = `set -e
+restic cat config >/dev/null 2>&1 || restic init || restic cat config >/dev/null
+restic backup --host %s --tag '%s' %s
+`
+)
+
+// CreateClient creates a backup pod for each source PVC, it initializes the repository when needed
+// and takes a snapshot of the PVC tagged with the tag of the transfer
+func (r *ResticTransfer) CreateClient(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetSourceNamespaces()[0])
+	err := r.createClient(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create restic client")
+	}
+	return err
+}
+
+func (r *ResticTransfer) createClient(ctx context.Context, c client.Client) error {
+	for _, pvc := range r.pvcList {
+		if err := createResticBackup(ctx, c, r, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backupPodName(pvc transfer.PVCPair) string {
+	return resticBackupPrefix + pvc.Source().LabelSafeName()
+}
+
+func createResticBackup(ctx context.Context, c client.Client, r *ResticTransfer, pvc transfer.PVCPair) error {
+	script := fmt.Sprintf(resticBackupScript, resticHost, r.snapshotTags(pvc.Source()), dataPath)
+	pod := r.createPod(backupPodName(pvc), pvc.Source(), script)
+	// the pod is the same when the transfer is created again, see TransferOptions.Tag
+	err := c.Create(ctx, pod, &client.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package restic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ResticContainer is the name of the restic container in transfer pods
+	ResticContainer = "restic"
+)
+
+const (
+	resticImage          = "docker.io/restic/restic:latest"
+	resticBackupPrefix   = "crane2-restic-backup-"
+	resticRestorePrefix  = "crane2-restic-restore-"
+	resticHost           = "crane2"
+	dataVolumeName       = "data"
+	dataPath             = "/data"
+	cacheVolumeName      = "cache"
+	cachePath            = "/cache"
+	credentialsVolume    = "restic-credentials"
+	credentialsPath      = "/etc/restic"
+	restorePollInterval  = 10
+	defaultTagPrefix     = "crane2-"
+	defaultTagHashLength = 16
+)
+
+// Repository is the object storage repository the data of the PVCs goes through
+type Repository struct {
+	// URL is the repository as understood by restic, one of s3:<endpoint>/<bucket>[/<path>],
+	// gs:<bucket>:/<path> or azure:<container>:/<path>
+	URL string
+	// CredentialsSecret is the name of a Secret holding RESTIC_PASSWORD and the credentials of
+	// the storage provider, e.g. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, GOOGLE_PROJECT_ID
+	// or AZURE_ACCOUNT_NAME and AZURE_ACCOUNT_KEY. Its keys are set as environment variables of
+	// the transfer pods and mounted as files in /etc/restic, so that GOOGLE_APPLICATION_CREDENTIALS
+	// can point at a key of the Secret. The Secret must exist in the source and the destination
+	// namespaces.
+	CredentialsSecret string
+}
+
+// TransferOptions are the options of a restic transfer
+type TransferOptions struct {
+	// Image is the restic image of the transfer pods, defaults to the upstream restic image
+	Image string
+	// Tag identifies the snapshots of the transfer in the repository, it must be unique to the
+	// transfer and a valid label value. When empty, the tag is derived from the source and destination
+	// PVCs, so that a transfer created again for the same PVCs finds the snapshots it already took.
+	Tag string
+}
+
+func (t *TransferOptions) getImage() string {
+	if t.Image == "" {
+		return resticImage
+	}
+	return t.Image
+}
+
+// ResticTransfer backs up the source PVCs to a restic repository and restores them in the
+// destination PVCs. The data goes through object storage, so the source and the destination do
+// not need to reach each other and the transfer has no endpoint nor transport. The restore pods
+// created by CreateServer wait for the snapshots taken by the backup pods created by CreateClient.
+type ResticTransfer struct {
+	repository      Repository
+	source          client.Client
+	destination     client.Client
+	pvcList         transfer.PVCPairList
+	tag             string
+	transferOptions *TransferOptions
+	log             logr.Logger
+}
+
+func NewTransfer(repository Repository, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger, options *TransferOptions) (transfer.Transfer, error) {
+	if options == nil {
+		options = &TransferOptions{}
+	}
+	if err := validateRepository(repository); err != nil {
+		return nil, err
+	}
+	if err := validatePVCList(pvcList); err != nil {
+		return nil, err
+	}
+	tag := options.Tag
+	if tag == "" {
+		tag = defaultTag(pvcList)
+	}
+	if err := validateTag(tag); err != nil {
+		return nil, err
+	}
+	return &ResticTransfer{
+		log:             meta.LoggerOrDiscard(log).WithValues("transfer", "restic"),
+		repository:      repository,
+		source:          src,
+		destination:     dest,
+		pvcList:         pvcList,
+		tag:             tag,
+		transferOptions: options,
+	}, nil
+}
+
+// defaultTag returns a tag derived from the source and destination PVCs of the list
+func defaultTag(pvcList transfer.PVCPairList) string {
+	pairs := []string{}
+	for _, pvc := range pvcList {
+		pairs = append(pairs, fmt.Sprintf("%s/%s=%s/%s",
+			pvc.Source().Claim().Namespace, pvc.Source().Claim().Name,
+			pvc.Destination().Claim().Namespace, pvc.Destination().Claim().Name))
+	}
+	sort.Strings(pairs)
+	hash := md5.Sum([]byte(strings.Join(pairs, ",")))
+	return defaultTagPrefix + hex.EncodeToString(hash[:])[:defaultTagHashLength]
+}
+
+func (r *ResticTransfer) PVCs() transfer.PVCPairList {
+	return r.pvcList
+}
+
+// Endpoint returns nil, the data of a restic transfer goes through the repository
+func (r *ResticTransfer) Endpoint() endpoint.Endpoint {
+	return nil
+}
+
+// Transport returns nil, restic encrypts the data it stores in the repository
+func (r *ResticTransfer) Transport() transport.Transport {
+	return nil
+}
+
+func (r *ResticTransfer) Source() client.Client {
+	return r.source
+}
+
+func (r *ResticTransfer) Destination() client.Client {
+	return r.destination
+}
+
+// Repository returns the repository the data goes through
+func (r *ResticTransfer) Repository() Repository {
+	return r.repository
+}
+
+// Tag returns the tag of the snapshots of the transfer
+func (r *ResticTransfer) Tag() string {
+	return r.tag
+}
+
+// snapshotTags returns the tags identifying the snapshot of the given source PVC, as accepted by
+// the --tag flag of restic: a snapshot must have every tag of a comma separated list to match
+func (r *ResticTransfer) snapshotTags(pvc transfer.PVC) string {
+	return fmt.Sprintf("%s,pvc=%s/%s", r.Tag(), pvc.Claim().Namespace, pvc.Claim().Name)
+}
+
+// podLabels returns the labels of the restic pod of the given PVC
+func podLabels(pvc transfer.PVC) map[string]string {
+	labels := map[string]string{}
+	for key, val := range meta.Labels {
+		labels[key] = val
+	}
+	labels["pvc"] = pvc.LabelSafeName()
+	return labels
+}
+
+// createPod returns a pod running the given restic script with the given PVC mounted in /data
+func (r *ResticTransfer) createPod(name string, pvc transfer.PVC, script string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pvc.Claim().Namespace,
+			Labels:    meta.WithOwnerLabel(podLabels(pvc)),
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:    ResticContainer,
+					Image:   r.transferOptions.getImage(),
+					Command: []string{"/bin/sh", "-c", script},
+					Env: []v1.EnvVar{
+						{Name: "RESTIC_REPOSITORY", Value: r.repository.URL},
+						{Name: "RESTIC_CACHE_DIR", Value: cachePath},
+					},
+					EnvFrom: []v1.EnvFromSource{
+						{
+							SecretRef: &v1.SecretEnvSource{
+								LocalObjectReference: v1.LocalObjectReference{
+									Name: r.repository.CredentialsSecret,
+								},
+							},
+						},
+					},
+					VolumeMounts: []v1.VolumeMount{
+						{
+							Name:      dataVolumeName,
+							MountPath: dataPath,
+						},
+						{
+							Name:      cacheVolumeName,
+							MountPath: cachePath,
+						},
+						{
+							Name:      credentialsVolume,
+							MountPath: credentialsPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []v1.Volume{
+				{
+					Name: dataVolumeName,
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Claim().Name,
+						},
+					},
+				},
+				{
+					Name: cacheVolumeName,
+					VolumeSource: v1.VolumeSource{
+						EmptyDir: &v1.EmptyDirVolumeSource{},
+					},
+				},
+				{
+					Name: credentialsVolume,
+					VolumeSource: v1.VolumeSource{
+						Secret: &v1.SecretVolumeSource{
+							SecretName: r.repository.CredentialsSecret,
+						},
+					},
+				},
+			},
+			RestartPolicy: v1.RestartPolicyOnFailure,
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package restic
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testRepository    = "s3:s3.amazonaws.com/bucket/crane"
+	testSecret        = "restic-credentials"
+)
+
+func TestNewTransfer(t *testing.T) {
+	block := corev1.PersistentVolumeBlock
+	blockPVC := testPVC(testNamespace, "block")
+	blockPVC.Spec.VolumeMode = &block
+	tests := []struct {
+		name       string
+		repository Repository
+		pvcList    transfer.PVCPairList
+		options    *TransferOptions
+		wantErr    bool
+	}{
+		{
+			name:       "s3 repository",
+			repository: Repository{URL: testRepository, CredentialsSecret: testSecret},
+			pvcList:    testPVCList("pvc"),
+		},
+		{
+			name:       "gcs repository",
+			repository: Repository{URL: "gs:bucket:/crane", CredentialsSecret: testSecret},
+			pvcList:    testPVCList("pvc"),
+			options:    &TransferOptions{Tag: "migration"},
+		},
+		{
+			name:       "azure repository",
+			repository: Repository{URL: "azure:container:/crane", CredentialsSecret: testSecret},
+			pvcList:    testPVCList("pvc-1", "pvc-2"),
+		},
+		{
+			name:       "unsupported repository",
+			repository: Repository{URL: "/srv/restic", CredentialsSecret: testSecret},
+			pvcList:    testPVCList("pvc"),
+			wantErr:    true,
+		},
+		{
+			name:       "missing credentials",
+			repository: Repository{URL: testRepository},
+			pvcList:    testPVCList("pvc"),
+			wantErr:    true,
+		},
+		{
+			name:       "invalid tag",
+			repository: Repository{URL: testRepository, CredentialsSecret: testSecret},
+			pvcList:    testPVCList("pvc"),
+			options:    &TransferOptions{Tag: "a,b"},
+			wantErr:    true,
+		},
+		{
+			name:       "no pvc",
+			repository: Repository{URL: testRepository, CredentialsSecret: testSecret},
+			wantErr:    true,
+		},
+		{
+			name:       "block pvc",
+			repository: Repository{URL: testRepository, CredentialsSecret: testSecret},
+			pvcList:    transfer.PVCPairList{transfer.NewPVCPair(blockPVC, blockPVC)},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransfer(tt.repository, buildTestClient(), buildTestClient(), tt.pvcList, nil, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTransfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tr.(*ResticTransfer).Tag() == "" {
+				t.Errorf("NewTransfer() should default the tag")
+			}
+		})
+	}
+}
+
+func TestDefaultTag(t *testing.T) {
+	repository := Repository{URL: testRepository, CredentialsSecret: testSecret}
+	tags := []string{}
+	for _, pvcList := range []transfer.PVCPairList{
+		testPVCList("pvc-1", "pvc-2"),
+		testPVCList("pvc-2", "pvc-1"),
+		testPVCList("pvc-1"),
+	} {
+		tr, err := NewTransfer(repository, buildTestClient(), buildTestClient(), pvcList, nil, nil)
+		if err != nil {
+			t.Fatalf("NewTransfer() unexpected error %v", err)
+		}
+		tags = append(tags, tr.(*ResticTransfer).Tag())
+	}
+	if tags[0] != tags[1] {
+		t.Errorf("tags of the same pvcs = %s, %s, want the same tag", tags[0], tags[1])
+	}
+	if tags[0] == tags[2] {
+		t.Errorf("tags of different pvcs = %s, %s, want different tags", tags[0], tags[2])
+	}
+}
+
+func TestCreateServerAndClient(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	tr, err := NewTransfer(Repository{URL: testRepository, CredentialsSecret: testSecret}, src, dest,
+		testPVCList("pvc-1", "pvc-2"), nil, &TransferOptions{Tag: "migration"})
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	// a transfer created again for the same pvcs finds its pods
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() should tolerate existing pods, got %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() should tolerate existing pods, got %v", err)
+	}
+
+	for _, pvc := range tr.PVCs() {
+		name := pvc.Source().Claim().Name
+		backup := &corev1.Pod{}
+		if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: backupPodName(pvc)}, backup); err != nil {
+			t.Fatalf("unable to get backup pod: %v", err)
+		}
+		restore := &corev1.Pod{}
+		if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: restorePodName(pvc)}, restore); err != nil {
+			t.Fatalf("unable to get restore pod: %v", err)
+		}
+		tags := "migration,pvc=" + testNamespace + "/" + name
+		for _, pod := range []*corev1.Pod{backup, restore} {
+			container := pod.Spec.Containers[0]
+			if !strings.Contains(container.Command[2], "--tag '"+tags+"'") {
+				t.Errorf("pod %s does not use tags %s: %s", pod.Name, tags, container.Command[2])
+			}
+			if container.EnvFrom[0].SecretRef.Name != testSecret || container.Env[0].Value != testRepository {
+				t.Errorf("pod %s does not use the repository", pod.Name)
+			}
+			if pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != name {
+				t.Errorf("pod %s does not mount pvc %s", pod.Name, name)
+			}
+			if !meta.IsOwned(pod.Labels) {
+				t.Errorf("pod %s is missing the owner label", pod.Name)
+			}
+		}
+		if !strings.Contains(backup.Spec.Containers[0].Command[2], "restic backup") {
+			t.Errorf("unexpected backup script %s", backup.Spec.Containers[0].Command[2])
+		}
+		if !strings.Contains(restore.Spec.Containers[0].Command[2], "restic restore latest") {
+			t.Errorf("unexpected restore script %s", restore.Spec.Containers[0].Command[2])
+		}
+	}
+
+	if healthy, err := tr.IsServerHealthy(context.TODO(), dest); healthy || err == nil {
+		t.Errorf("IsServerHealthy() = %v, %v, the restore pods are not running", healthy, err)
+	}
+	for _, pvc := range tr.PVCs() {
+		restore := &corev1.Pod{}
+		if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: restorePodName(pvc)}, restore); err != nil {
+			t.Fatalf("unable to get restore pod: %v", err)
+		}
+		restore.Status.Phase = corev1.PodSucceeded
+		if err := dest.Status().Update(context.TODO(), restore); err != nil {
+			t.Fatalf("unable to update restore pod: %v", err)
+		}
+	}
+	if healthy, err := tr.IsServerHealthy(context.TODO(), dest); !healthy || err != nil {
+		t.Errorf("IsServerHealthy() = %v, %v, the restore pods succeeded", healthy, err)
+	}
+}
+
+func TestExportManifests(t *testing.T) {
+	tr, err := NewTransfer(Repository{URL: testRepository, CredentialsSecret: testSecret},
+		buildTestClient(), buildTestClient(), testPVCList("pvc"), nil, nil)
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	manifests, err := transfer.ExportManifests(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("ExportManifests() unexpected error %v", err)
+	}
+	pvc := tr.PVCs()[0]
+	for _, want := range []string{"name: " + backupPodName(pvc), "name: " + restorePodName(pvc)} {
+		if !strings.Contains(string(manifests), want) {
+			t.Errorf("manifests do not contain %q:\n%s", want, manifests)
+		}
+	}
+}
+
+func buildTestClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+}
+
+func testPVC(namespace, name string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func testPVCList(names ...string) transfer.PVCPairList {
+	pvcList := transfer.PVCPairList{}
+	for _, name := range names {
+		pvcList = append(pvcList, transfer.NewPVCPair(testPVC(testNamespace, name), testPVC(testDestNamespace, name)))
+	}
+	return pvcList
+}
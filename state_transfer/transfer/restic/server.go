@@ -0,0 +1,79 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// the snapshot is restored in place, restic restores the absolute path it was taken from
+	resticRestoreScript = `set -e
+until restic snapshots --tag '%[1]s' --json 2>/dev/null | grep -q '"id"'; do
+  echo "waiting for the snapshot of the source volume"
+  sleep %[2]d
+done
+restic restore latest --tag '%[1]s' --target /
+`
+)
+
+// CreateServer creates a restore pod for each destination PVC, it waits for the snapshot of the
+// source PVC to be taken and restores it in the destination PVC
+func (r *ResticTransfer) CreateServer(ctx context.Context, c client.Client) error {
+	log := r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0])
+	err := r.createServer(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create restic server")
+	}
+	return err
+}
+
+func (r *ResticTransfer) createServer(ctx context.Context, c client.Client) error {
+	for _, pvc := range r.pvcList {
+		if err := createResticRestore(ctx, c, r, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsServerHealthy returns whether the restore pods are running or have restored the snapshots
+func (r *ResticTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := r.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(r.log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
+}
+
+func (r *ResticTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	for _, pvc := range r.pvcList {
+		pod := &v1.Pod{}
+		key := client.ObjectKey{Namespace: pvc.Destination().Claim().Namespace, Name: restorePodName(pvc)}
+		if err := c.Get(ctx, key, pod); err != nil {
+			return false, err
+		}
+		if pod.Status.Phase != v1.PodRunning && pod.Status.Phase != v1.PodSucceeded {
+			return false, fmt.Errorf("restore pod %s is not running", key)
+		}
+	}
+	return true, nil
+}
+
+func restorePodName(pvc transfer.PVCPair) string {
+	return resticRestorePrefix + pvc.Destination().LabelSafeName()
+}
+
+func createResticRestore(ctx context.Context, c client.Client, r *ResticTransfer, pvc transfer.PVCPair) error {
+	script := fmt.Sprintf(resticRestoreScript, r.snapshotTags(pvc.Source()), restorePollInterval)
+	pod := r.createPod(restorePodName(pvc), pvc.Destination(), script)
+	// the pod is the same when the transfer is created again, see TransferOptions.Tag
+	err := c.Create(ctx, pod, &client.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package restic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	kubeVirtAnnKey      = "cdi.kubevirt.io/storage.contentType"
+	kubevirtContentType = "kubevirt"
+)
+
+// supportedBackends are the URL prefixes of the object storage backends supported by the transfer
+var supportedBackends = []string{"s3:", "gs:", "azure:"}
+
+// validateRepository validates the repository provided to restic transfer
+// the url must use one of the supported object storage backends
+// the credentials secret must be a valid secret name
+func validateRepository(repository Repository) error {
+	validationErrors := []error{}
+	supported := false
+	for _, prefix := range supportedBackends {
+		if strings.HasPrefix(repository.URL, prefix) && len(repository.URL) > len(prefix) {
+			supported = true
+		}
+	}
+	if !supported {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("repository url %q must start with one of %s", repository.URL, strings.Join(supportedBackends, ", ")))
+	}
+	if errs := validation.IsDNS1123Subdomain(repository.CredentialsSecret); len(errs) > 0 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("credentials secret %q must be a valid secret name", repository.CredentialsSecret))
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+// validateTag validates the tag of the snapshots, restic splits tags on commas
+func validateTag(tag string) error {
+	if errs := validation.IsValidLabelValue(tag); len(errs) > 0 {
+		return fmt.Errorf("tag %s must be a valid label value", tag)
+	}
+	return nil
+}
+
+// validatePVCList validates list of PVCs provided to restic transfer
+// list must contain at least one pvc
+// labelSafeNames of all pvcs must be valid label values
+// pvcs must be filesystem volumes
+func validatePVCList(pvcList transfer.PVCPairList) error {
+	validationErrors := []error{}
+	if len(pvcList) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("at least one pvc must be provided"))
+	}
+	for _, pvcPair := range pvcList {
+		for _, pvc := range []transfer.PVC{pvcPair.Source(), pvcPair.Destination()} {
+			if errs := validation.IsValidLabelValue(pvc.LabelSafeName()); len(errs) > 0 {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("labelSafeName() for %s must be a valid label value", pvc.Claim().Name))
+			}
+			if isBlockOrKubeVirtDisk(pvc.Claim()) {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("restic transfer does not support block or VM disk volume %s", pvc.Claim().Name))
+			}
+		}
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+func isBlockOrKubeVirtDisk(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		return true
+	}
+	return pvc.GetAnnotations()[kubeVirtAnnKey] == kubevirtContentType
+}
@@ -0,0 +1,237 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AttemptAnnotation is set on transfer client pods to the attempt number they were created for
+	AttemptAnnotation = "crane.konveyor.io/attempt"
+	// DefaultMaxRetryBackoff is the longest time waited before retrying a failed client, when the
+	// retry policy does not set MaxBackoff
+	DefaultMaxRetryBackoff = 5 * time.Minute
+)
+
+var (
+	// ErrRetriesExhausted is returned when a transfer still fails after its last retry
+	ErrRetriesExhausted = errors.New("transfer failed after exhausting all retries")
+	// ErrTransferCancelled is returned when a transfer being waited on was cancelled
+	ErrTransferCancelled = errors.New("transfer was cancelled")
+	// ErrNotRetriable is returned when a transfer client failed with an error its retry policy
+	// does not retry
+	ErrNotRetriable = errors.New("transfer failure is not retriable")
+	// ErrTransferFailed is matched by every TransferFailedError
+	ErrTransferFailed = errors.New("transfer failed")
+)
+
+// TransferFailedError is returned when a transfer client failed for good, either because its
+// retries are exhausted or because it failed with an error which is not retriable. Use errors.As
+// to inspect the exit code of the failed client.
+type TransferFailedError struct {
+	// PVC is the name of the source PVC whose client failed, empty when it is not known
+	PVC string
+	// ExitCode is the exit code of the failed client, nil when it is not known
+	ExitCode *int32
+	// Attempts is the number of times the client was run
+	Attempts int
+	// Message explains the failure
+	Message string
+	// Err is ErrRetriesExhausted or ErrNotRetriable
+	Err error
+}
+
+func (e *TransferFailedError) Error() string {
+	msg := ErrTransferFailed.Error()
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *TransferFailedError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrTransferFailed
+func (e *TransferFailedError) Is(target error) bool {
+	return target == ErrTransferFailed
+}
+
+// lastFailedAttempt returns the latest failed attempt of the status history with an exit code
+func lastFailedAttempt(status *Status) *Attempt {
+	var last *Attempt
+	for i := range status.History {
+		attempt := &status.History[i]
+		if attempt.Phase != PVCTransferPhaseFailed || attempt.ExitCode == nil {
+			continue
+		}
+		if last == nil || !attempt.FinishTime.Before(last.FinishTime) {
+			last = attempt
+		}
+	}
+	return last
+}
+
+// RetryPolicy is how RunClientWithRetries retries the failed clients of a transfer, the attempts
+// and the backoff are counted for the client of every PVC separately
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the client of a PVC is run, including the first
+	// attempt, failed clients are not retried when it is less than 2
+	MaxAttempts int
+	// Backoff is the time waited before the first retry of the client of a PVC, it doubles for
+	// every following retry of that client
+	Backoff time.Duration
+	// MaxBackoff caps the time waited before a retry, defaults to DefaultMaxRetryBackoff
+	MaxBackoff time.Duration
+}
+
+// RetryBackoff returns the time waited before retrying the client of a PVC which failed the given
+// attempt, starting at 1
+func (p RetryPolicy) RetryBackoff(attempt int) time.Duration {
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxRetryBackoff
+	}
+	backoff := p.Backoff
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// Retrier knows how to re-run the failed parts of a transfer client
+type Retrier interface {
+	StatusReporter
+	// RetryPolicy returns how the failed clients of the transfer are retried
+	RetryPolicy() RetryPolicy
+	// RetryClient replaces the failed transfer client pods of the given PVCs with new ones,
+	// resuming from the partially transferred data. It returns a TransferFailedError wrapping
+	// ErrNotRetriable when a client failed with an error which is not transient.
+	RetryClient(ctx context.Context, c client.Client, pvcs PVCPairList) error
+}
+
+// RunClientWithRetries creates the transfer client and polls its status at the given interval
+// until it succeeds. The client of a PVC is retried as soon as its backoff elapsed after it
+// failed, while the clients of the other PVCs keep running, until the client of a PVC exhausted
+// the attempts of the retry policy, in which case a TransferFailedError wrapping
+// ErrRetriesExhausted is returned, or until a client fails with an error which is not retriable,
+// see Retrier.RetryClient. Retries rely on the History of the status.
+// The server must have been created beforehand. Transfers which do not implement Retrier are not
+// retried. The last observed status is returned, including the number of attempts.
+func RunClientWithRetries(ctx context.Context, t Transfer, interval time.Duration) (*Status, error) {
+	reporter, ok := t.(StatusReporter)
+	if !ok {
+		return nil, fmt.Errorf("transfer does not report its status")
+	}
+	policy := RetryPolicy{MaxAttempts: 1}
+	retrier, canRetry := t.(Retrier)
+	if canRetry {
+		policy = retrier.RetryPolicy()
+	}
+	if err := CreateClient(ctx, t); err != nil {
+		return nil, err
+	}
+
+	var status *Status
+	var exhausted *Attempt
+	// retryAt is when the failed attempts are retried, by PVC and attempt number
+	retryAt := map[Attempt]time.Time{}
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		var err error
+		status, err = reporter.Status(ctx, t.Source())
+		if err != nil {
+			return false, err
+		}
+		switch status.Phase {
+		case TransferPhaseSucceeded, TransferPhaseCancelled:
+			return true, nil
+		}
+		failed := failedAttempts(status)
+		if status.Phase == TransferPhaseFailed && len(failed) == 0 {
+			return true, nil
+		}
+		now := time.Now()
+		due := map[string]bool{}
+		for _, attempt := range failed {
+			if attempt.Number >= policy.MaxAttempts {
+				exhausted = attempt
+				return true, nil
+			}
+			key := Attempt{PVC: attempt.PVC, Number: attempt.Number}
+			at, ok := retryAt[key]
+			if !ok {
+				at = now.Add(policy.RetryBackoff(attempt.Number))
+				retryAt[key] = at
+			}
+			if !now.Before(at) {
+				due[attempt.PVC] = true
+			}
+		}
+		if len(due) == 0 {
+			return false, nil
+		}
+		pvcs := PVCPairList{}
+		for _, pvc := range t.PVCs() {
+			if due[pvc.Source().Claim().Name] {
+				pvcs = append(pvcs, pvc)
+			}
+		}
+		return false, retrier.RetryClient(ctx, t.Source(), pvcs)
+	}, ctx.Done())
+	if err != nil {
+		return status, err
+	}
+	switch status.Phase {
+	case TransferPhaseSucceeded:
+		return status, nil
+	case TransferPhaseCancelled:
+		return status, ErrTransferCancelled
+	}
+	failed := &TransferFailedError{
+		Attempts: status.Attempts,
+		Message:  fmt.Sprintf("%d attempt(s): %s", status.Attempts, status.Message),
+		Err:      ErrRetriesExhausted,
+	}
+	if exhausted == nil {
+		exhausted = lastFailedAttempt(status)
+	}
+	if exhausted != nil {
+		failed.PVC, failed.ExitCode = exhausted.PVC, exhausted.ExitCode
+	}
+	return status, failed
+}
+
+// failedAttempts returns the latest attempt of every PVC of the status history when it failed
+func failedAttempts(status *Status) []*Attempt {
+	latest := map[string]*Attempt{}
+	order := []string{}
+	for i := range status.History {
+		attempt := &status.History[i]
+		previous, ok := latest[attempt.PVC]
+		if !ok {
+			order = append(order, attempt.PVC)
+		}
+		if !ok || attempt.Number > previous.Number {
+			latest[attempt.PVC] = attempt
+		}
+	}
+	failed := []*Attempt{}
+	for _, pvc := range order {
+		if latest[pvc].Phase == PVCTransferPhaseFailed {
+			failed = append(failed, latest[pvc])
+		}
+	}
+	return failed
+}
@@ -0,0 +1,105 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// retryTransfer fails the first attempts of the client of every PVC as configured by fails
+type retryTransfer struct {
+	hookTransfer
+	policy   RetryPolicy
+	fails    map[string]int
+	attempts map[string]int
+}
+
+func (r *retryTransfer) RetryPolicy() RetryPolicy { return r.policy }
+
+func (r *retryTransfer) RetryClient(_ context.Context, _ client.Client, pvcs PVCPairList) error {
+	for _, pvc := range pvcs {
+		r.attempts[pvc.Source().Claim().Name]++
+	}
+	return nil
+}
+
+func (r *retryTransfer) Status(context.Context, client.Client) (*Status, error) {
+	status := &Status{Phase: TransferPhaseSucceeded}
+	for _, pvc := range r.pvcs {
+		name := pvc.Source().Claim().Name
+		for number := 1; number <= r.attempts[name]; number++ {
+			phase := PVCTransferPhaseCompleted
+			if number <= r.fails[name] {
+				phase = PVCTransferPhaseFailed
+			}
+			status.History = append(status.History, Attempt{PVC: name, Number: number, Phase: phase})
+		}
+		if r.attempts[name] <= r.fails[name] {
+			status.Phase = TransferPhaseFailed
+		}
+		if r.attempts[name] > status.Attempts {
+			status.Attempts = r.attempts[name]
+		}
+	}
+	return status, nil
+}
+
+func TestRunClientWithRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		fails   map[string]int
+		wantErr error
+		wantPVC string
+	}{
+		{
+			name:  "attempts are counted per pvc",
+			fails: map[string]int{"a": 2, "b": 2},
+		},
+		{
+			name:    "retries of a pvc exhausted",
+			fails:   map[string]int{"a": 1, "b": 3},
+			wantErr: ErrRetriesExhausted,
+			wantPVC: "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvcs := PVCPairList{}
+			for _, name := range []string{"a", "b"} {
+				pvcs = append(pvcs, NewPVCPair(&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name}}, nil))
+			}
+			events := []string{}
+			tr := &retryTransfer{
+				hookTransfer: hookTransfer{pvcs: pvcs, events: &events},
+				policy:       RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+				fails:        tt.fails,
+				attempts:     map[string]int{"a": 1, "b": 1},
+			}
+			_, err := RunClientWithRetries(context.TODO(), tr, time.Millisecond)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("RunClientWithRetries() unexpected error %v", err)
+			}
+			failed := &TransferFailedError{}
+			if tt.wantErr != nil && (!errors.Is(err, tt.wantErr) || !errors.As(err, &failed) || failed.PVC != tt.wantPVC) {
+				t.Fatalf("RunClientWithRetries() error = %v, want %v for pvc %s", err, tt.wantErr, tt.wantPVC)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 6: 32 * time.Second, 7: time.Minute, 100: time.Minute} {
+		if got := policy.RetryBackoff(attempt); got != want {
+			t.Errorf("RetryBackoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (RetryPolicy{Backoff: time.Hour}).RetryBackoff(1); got != DefaultMaxRetryBackoff {
+		t.Errorf("RetryBackoff() = %s, want the default maximum %s", got, DefaultMaxRetryBackoff)
+	}
+}
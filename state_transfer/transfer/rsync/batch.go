@@ -0,0 +1,172 @@
+package rsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// BatchPVCLabelValue is the value of PVCLabel on the client pods created with BatchClients, PVC
+	// names are DNS subdomains so it cannot be the label safe name of a PVC
+	BatchPVCLabelValue = "crane2_batch"
+	// BatchedPVCsAnnotation lists the label safe names of the source PVCs transferred by a batched
+	// client pod, separated by commas
+	BatchedPVCsAnnotation = "crane.konveyor.io/batched-pvcs"
+
+	// batchedErrorsFile collects the rsync errors of all PVCs of a batched client
+	batchedErrorsFile = "/tmp/rsync-errors-batch"
+	// batchedCompletedPrefix prefixes the label safe names of the PVCs a batched client transferred
+	// successfully in its termination message, separated by commas
+	batchedCompletedPrefix = "completed pvcs: "
+)
+
+// createBatchedRsyncClient creates a single client pod transferring all the given PVCs one after the
+// other, every PVC is copied to its own module of the rsync server. The rsync errors of every PVC are
+// collected so that the termination message reports all of them, followed by the PVCs transferred
+// successfully so that only the failed PVCs of the batch are retried.
+func createBatchedRsyncClient(ctx context.Context, c client.Client, r *RsyncTransfer, pvcs transfer.PVCPairList, previousAttempts map[string]int) error {
+	transferOptions := r.transferOptions()
+	rsyncOptions, err := transferOptions.AsRsyncCommandOptions()
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	commands := []string{}
+	mounts := []v1.VolumeMount{}
+	volumes := []v1.Volume{}
+	for _, pvc := range pvcs {
+		if !isFileSystemPVC(pvc) {
+			continue
+		}
+		names = append(names, pvc.Source().LabelSafeName())
+		commands = append(commands, fmt.Sprintf(
+			"echo 'transferring pvc %s/%s'; { { %s; } 2>&1 1>&3 | tee %s >&2; } 3>&1; rc=$?; %scat %s >> %s; if [ $rc -ne 0 ]; then failed=$rc; else completed=${completed}%s,; fi;",
+			pvc.Source().Claim().Namespace, pvc.Source().Claim().Name,
+			r.getClientRsyncCommand(pvc, rsyncOptions),
+			rsyncErrorsFile,
+			r.clientExitCode(pvc),
+			rsyncErrorsFile, batchedErrorsFile,
+			pvc.Source().LabelSafeName()))
+		volumeName := batchedVolumeName(pvc)
+		mounts = append(mounts, v1.VolumeMount{
+			Name:             volumeName,
+			MountPath:        getMountPathForPVC(pvc.Source()),
+			MountPropagation: r.options.mountPropagation,
+		})
+		volumes = append(volumes, v1.Volume{
+			Name: volumeName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: r.sourceClaimName(pvc),
+				},
+			},
+		})
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	rsyncCommandBashScript := fmt.Sprintf(
+		"%strap \"%s; echo %s\\$completed >> /dev/termination-log; touch /usr/share/rsync/rsync-client-container-done\" EXIT SIGINT SIGTERM; set -o pipefail; timeout=120; SECONDS=0; while [ $SECONDS -lt $timeout ]; do nc -z localhost %d; rc=$?; if [ $rc -eq 0 ]; then break; fi; done; if [ $rc -ne 0 ]; then exit $rc; fi; failed=0; completed=; %s mv -f %s %s; exit $failed;",
+		r.clientScriptPrefix(),
+		r.clientTerminationMessage(),
+		batchedCompletedPrefix,
+		r.Transport().Port(),
+		strings.Join(commands, " "),
+		batchedErrorsFile, rsyncErrorsFile)
+
+	podLabels := map[string]string{}
+	for k, v := range transferOptions.SourcePodMeta.Labels {
+		podLabels[k] = v
+	}
+	podLabels[PVCLabel] = BatchPVCLabelValue
+	pod := r.newRsyncClientPod(r.pvcList.GetSourceNamespaces()[0], podLabels,
+		map[string]string{BatchedPVCsAnnotation: strings.Join(names, ",")},
+		previousAttempts[BatchPVCLabelValue]+1,
+		rsyncCommandBashScript, mounts, volumes)
+	return c.Create(ctx, &pod, &client.CreateOptions{})
+}
+
+// batchedVolumeName returns the name of the volume of the given PVC in a batched client pod
+func batchedVolumeName(pvc transfer.PVCPair) string {
+	return "mnt-" + pvc.Source().LabelSafeName()
+}
+
+// podTransfersPVC returns whether the given rsync client pod transfers the source PVC of the given
+// pair, either as its only PVC or as one of the PVCs of a batched client
+func podTransfersPVC(pod *v1.Pod, pvc transfer.PVCPair) bool {
+	name := pvc.Source().LabelSafeName()
+	switch pod.Labels[PVCLabel] {
+	case name:
+		return true
+	case BatchPVCLabelValue:
+		for _, batched := range strings.Split(pod.Annotations[BatchedPVCsAnnotation], ",") {
+			if batched == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// batchedFileErrors returns the lines of the termination message of a batched client which report
+// errors for files under the given mount path
+func batchedFileErrors(message string, mountPath string) string {
+	lines := []string{}
+	for _, line := range strings.Split(message, "\n") {
+		if strings.Contains(line, "\""+mountPath+"/") || strings.Contains(line, "\""+mountPath+"\"") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// batchedCompletedPVCs returns the label safe names of the PVCs the given batched client pod
+// transferred successfully. None are returned when the line listing them was not written or was cut
+// off the termination message, so that all PVCs of the batch are retried.
+func batchedCompletedPVCs(pod *v1.Pod) map[string]bool {
+	completed := map[string]bool{}
+	terminated := rsyncTerminated(pod)
+	if terminated == nil {
+		return completed
+	}
+	for _, line := range strings.Split(terminated.Message, "\n") {
+		if !strings.HasPrefix(line, batchedCompletedPrefix) {
+			continue
+		}
+		for _, name := range strings.Split(strings.TrimPrefix(line, batchedCompletedPrefix), ",") {
+			if name != "" {
+				completed[name] = true
+			}
+		}
+	}
+	return completed
+}
+
+// pvcPhase returns the phase of the source PVC of the given pair transferred by the given client
+// pod, the PVCs a failed batched client transferred successfully are completed
+func pvcPhase(pod *v1.Pod, pvc transfer.PVCPair) transfer.PVCTransferPhase {
+	phase := transfer.PVCPhaseFromPod(pod)
+	if phase == transfer.PVCTransferPhaseFailed && !transfer.IsCancelled(pod) &&
+		pod.Labels[PVCLabel] == BatchPVCLabelValue && batchedCompletedPVCs(pod)[pvc.Source().LabelSafeName()] {
+		return transfer.PVCTransferPhaseCompleted
+	}
+	return phase
+}
+
+// latestClientPod returns the client pod of the latest attempt transferring the source PVC of the
+// given pair, a PVC transferred by a batched client is retried with the other failed PVCs of its
+// batch only. nil is returned when there is none.
+func latestClientPod(pods []v1.Pod, pvc transfer.PVCPair) *v1.Pod {
+	var latest *v1.Pod
+	for i := range pods {
+		if podTransfersPVC(&pods[i], pvc) && (latest == nil || podAttempt(&pods[i]) > podAttempt(latest)) {
+			latest = &pods[i]
+		}
+	}
+	return latest
+}
@@ -0,0 +1,144 @@
+package rsync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBatchClients(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, BatchClients(true))
+	pairs := transfer.PVCPairList{}
+	for _, name := range []string{"data", "logs", "cache"} {
+		pairs = append(pairs, transfer.NewPVCPair(createPVC(name, testNamespace), nil))
+	}
+	tr.pvcList = pairs
+	// the batched clients share a single stunnel client
+	s, err := stunnel.Share(testTransport, tr.transport.NamespacedNamePair())
+	if err != nil {
+		t.Fatalf("unable to share transport: %v", err)
+	}
+	if tr.transport, err = transport.CreateClient(context.TODO(), s, srcClient, "fs", tr.endpoint); err != nil {
+		t.Fatalf("unable to create transport client: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("expected a single batched client pod: %v", err)
+	}
+	pod := &pods.Items[0]
+	if pod.Labels[PVCLabel] != BatchPVCLabelValue {
+		t.Errorf("batched client pod label %s=%s, want %s", PVCLabel, pod.Labels[PVCLabel], BatchPVCLabelValue)
+	}
+	names := []string{}
+	for _, pvc := range pairs {
+		names = append(names, pvc.Source().LabelSafeName())
+	}
+	if got := pod.Annotations[BatchedPVCsAnnotation]; got != strings.Join(names, ",") {
+		t.Errorf("batched pvcs annotation %q, want %q", got, strings.Join(names, ","))
+	}
+	script := pod.Spec.Containers[0].Command[2]
+	for _, pvc := range pairs {
+		if want := "localhost/" + pvc.Destination().LabelSafeName() + " --port"; !strings.Contains(script, want) {
+			t.Errorf("client script does not transfer to module %s:\n%s", pvc.Destination().LabelSafeName(), script)
+		}
+		found := false
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == batchedVolumeName(pvc) && v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == pvc.Source().Claim().Name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("pvc %s is not mounted in the batched client pod", pvc.Source().Claim().Name)
+		}
+	}
+	if !strings.Contains(script, "echo "+batchedCompletedPrefix+"\\$completed >> /dev/termination-log;") {
+		t.Errorf("client script should report the completed pvcs:\n%s", script)
+	}
+	if !strings.HasSuffix(script, "mv -f /tmp/rsync-errors-batch /tmp/rsync-errors; exit $failed;") {
+		t.Errorf("client script should fail when a pvc failed:\n%s", script)
+	}
+	transportClients := 0
+	for _, c := range pod.Spec.Containers {
+		if c.Name != RsyncContainer {
+			transportClients++
+		}
+	}
+	if transportClients != 1 {
+		t.Errorf("expected a single transport client container, got %d", transportClients)
+	}
+
+	mountPath := getMountPathForPVC(pairs[1].Source())
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: RsyncContainer,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 23,
+			Message: `rsync: [sender] send_files failed to open "` + mountPath + `/app.log": Permission denied (13)` + "\n" +
+				batchedCompletedPrefix + pairs[0].Source().LabelSafeName() + "," + pairs[2].Source().LabelSafeName() + ",",
+		}},
+	}}
+	if err := srcClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+	failed, err := tr.FailedFiles(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("FailedFiles() unexpected error %v", err)
+	}
+	if len(failed) != 1 || failed[0].PVC.Name != "logs" || failed[0].Path != "app.log" {
+		t.Errorf("FailedFiles() = %v, want app.log of pvc logs only", failed)
+	}
+	status, err := transfer.PVCStatus(context.TODO(), tr, srcClient)
+	if err != nil {
+		t.Fatalf("PVCStatus() unexpected error %v", err)
+	}
+	for i, pvc := range pairs {
+		want := transfer.PVCTransferPhaseCompleted
+		if i == 1 {
+			want = transfer.PVCTransferPhaseFailed
+		}
+		if status[pvc].Phase != want {
+			t.Errorf("pvc %s phase %s, want %s", pvc.Source().Claim().Name, status[pvc].Phase, want)
+		}
+	}
+
+	if err := tr.RetryClient(context.TODO(), srcClient, pairs); err != nil {
+		t.Fatalf("RetryClient() unexpected error %v", err)
+	}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 2 {
+		t.Fatalf("expected a second batched client pod: %v", err)
+	}
+	latest, attempts := latestClientPods(pods.Items)
+	if len(latest) != 1 || attempts != 2 || latest[0].Annotations[BatchedPVCsAnnotation] != names[1] {
+		t.Fatalf("the retry should only transfer the failed pvc of the batch, got %d pod(s) after %d attempt(s)", len(latest), attempts)
+	}
+	latest[0].Status.Phase = corev1.PodSucceeded
+	if err := srcClient.Update(context.TODO(), &latest[0]); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+	status, err = transfer.PVCStatus(context.TODO(), tr, srcClient)
+	if err != nil {
+		t.Fatalf("PVCStatus() unexpected error %v", err)
+	}
+	for _, pvc := range pairs {
+		if status[pvc].Phase != transfer.PVCTransferPhaseCompleted {
+			t.Errorf("pvc %s phase %s, want %s", pvc.Source().Claim().Name, status[pvc].Phase, transfer.PVCTransferPhaseCompleted)
+		}
+	}
+}
+
+func TestBatchClientsWithItemizedLog(t *testing.T) {
+	tr, _, _ := createTransfer(t)
+	_, err := NewTransfer(tr.Transport(), tr.Endpoint(), tr.Source(), tr.Destination(), tr.PVCs(), tr.Log, BatchClients(true), ItemizedLog{})
+	if err == nil {
+		t.Errorf("NewTransfer() should fail when batched clients write itemized logs")
+	}
+}
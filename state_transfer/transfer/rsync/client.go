@@ -2,11 +2,16 @@ package rsync
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"path"
+	"strconv"
 	"strings"
 
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/ssh"
 	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
 
 	v1 "k8s.io/api/core/v1"
@@ -15,137 +20,393 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *RsyncTransfer) CreateClient(c client.Client) error {
+func (r *RsyncTransfer) CreateClient(ctx context.Context, c client.Client) error {
 	sourceNs := r.pvcList.GetSourceNamespaces()[0]
+	log := r.Log.WithValues("namespace", sourceNs)
+	c = meta.NewLoggingClient(metadataClient(c, r.options.SourcePodMeta), log)
+
+	// the client is created again, a previous cancellation does not apply to it
+	if err := transfer.ClearCancelled(ctx, c, r.pvcList); err != nil {
+		return err
+	}
+	if err := r.reuseServerPassword(ctx, r.destination); err != nil {
+		return err
+	}
 
 	errs := []error{}
-	err := createRsyncClientResources(c, r, sourceNs)
+	err := createRsyncClientResources(ctx, c, r, sourceNs)
 	errs = append(errs, err)
 
+	if r.options.snapshotSource != nil {
+		err = transfer.CreateSnapshotSources(ctx, c, r.options.snapshotSource, r.pvcList.InSourceNamespace(sourceNs))
+		errs = append(errs, err)
+	}
+
 	// _, err = transport.CreateClient(r.Transport(), c, r.Endpoint())
 	// errs = append(errs, err)
 
-	err = createRsyncClient(c, r, sourceNs)
+	err = createRsyncClient(ctx, c, r, r.pvcList.InSourceNamespace(sourceNs), map[string]int{})
 	errs = append(errs, err)
 
-	return errorsutil.NewAggregate(errs)
+	if r.options.correlationID != "" {
+		err = r.labelClientCorrelationID(ctx, c, sourceNs)
+		errs = append(errs, err)
+	}
+
+	err = errorsutil.NewAggregate(errs)
+	if err != nil {
+		log.Error(err, "unable to create rsync clients")
+	}
+	return err
 }
 
-func createRsyncClientResources(c client.Client, r *RsyncTransfer, ns string) error {
-	// no resource are created for rsync client side
-	return nil
+// createRsyncClientResources creates the Secret holding the password the client authenticates to the
+// rsync daemon with, so that the password does not appear in the client pod spec
+func createRsyncClientResources(ctx context.Context, c client.Client, r *RsyncTransfer, ns string) error {
+	rsyncSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ns,
+			Name:      defaultRsyncClientSecret,
+			Labels:    meta.WithOwnerLabel(r.transferOptions().SourcePodMeta.Labels),
+		},
+		Data: map[string][]byte{
+			rsyncPasswordKey: []byte(r.transferOptions().password),
+		},
+	}
+	return meta.Apply(ctx, c, rsyncSecret)
 }
 
-func createRsyncClient(c client.Client, r *RsyncTransfer, ns string) error {
+// createRsyncClient creates a client pod for every given PVC, annotated with the attempt number
+// following the previous attempts of its PVC. With BatchClients, a single client pod transfers
+// all the given PVCs.
+func createRsyncClient(ctx context.Context, c client.Client, r *RsyncTransfer, pvcs transfer.PVCPairList, previousAttempts map[string]int) error {
+	if r.options.batchClients {
+		return createBatchedRsyncClient(ctx, c, r, pvcs, previousAttempts)
+	}
 	var errs []error
 	transferOptions := r.transferOptions()
 	rsyncOptions, err := transferOptions.AsRsyncCommandOptions()
 	if err != nil {
 		return err
 	}
-	podLabels := transferOptions.SourcePodMeta.Labels
-	for _, pvc := range r.pvcList.InSourceNamespace(ns) {
-		fileSystemCount := 0
-		// create Rsync command for PVC
-		rsyncCommand := []string{"/usr/bin/rsync"}
-		rsyncCommand = append(rsyncCommand, rsyncOptions...)
-		isFileSystem := pvc.Source().Claim().Spec.VolumeMode == nil || *pvc.Source().Claim().Spec.VolumeMode == v1.PersistentVolumeFilesystem
-		if isFileSystem {
-			fileSystemCount++
-			rsyncCommand = append(rsyncCommand, fmt.Sprintf("%s/", getMountPathForPVC(pvc.Source())))
+	for _, pvc := range pvcs {
+		if !isFileSystemPVC(pvc) {
+			continue
 		}
-		rsyncCommand = append(rsyncCommand,
-			fmt.Sprintf("rsync://%s@%s/%s --port %d",
-				transferOptions.username, transfer.ConnectionHostname(r),
-				pvc.Destination().LabelSafeName(), r.Transport().Port()))
+		// create Rsync command for PVC
+		// rsync errors are copied to the termination message of the container so that
+		// files which failed to transfer can be reported without access to pod logs
 		rsyncCommandBashScript := fmt.Sprintf(
-			"trap \"touch /usr/share/rsync/rsync-client-container-done\" EXIT SIGINT SIGTERM; timeout=120; SECONDS=0; while [ $SECONDS -lt $timeout ]; do nc -z localhost %d; rc=$?; if [ $rc -eq 0 ]; then %s; rc=$?; break; fi; done; exit $rc;",
+			"%strap \"%s; touch /usr/share/rsync/rsync-client-container-done\" EXIT SIGINT SIGTERM; set -o pipefail; timeout=120; SECONDS=0; while [ $SECONDS -lt $timeout ]; do nc -z localhost %d; rc=$?; if [ $rc -eq 0 ]; then { { %s; } 2>&1 1>&3 | tee %s >&2; } 3>&1; rc=$?; break; fi; done; %sexit $rc;",
+			r.clientScriptPrefix(),
+			r.clientTerminationMessage(),
 			r.Transport().Port(),
-			strings.Join(rsyncCommand, " "))
-		rsyncContainerCommand := []string{
-			"/bin/bash",
-			"-c",
-			rsyncCommandBashScript,
-		}
-		// create rsync container
-		containers := []v1.Container{
+			r.getClientRsyncCommand(pvc, rsyncOptions),
+			rsyncErrorsFile,
+			r.clientExitCode(pvc))
+		mounts := []v1.VolumeMount{
 			{
-				Name:    RsyncContainer,
-				Image:   r.getRsyncClientImage(),
-				Command: rsyncContainerCommand,
-				Env: []v1.EnvVar{
-					{
-						Name:  "RSYNC_PASSWORD",
-						Value: transferOptions.password,
-					},
-				},
-
-				VolumeMounts: []v1.VolumeMount{
-					{
-						Name:      "rsync-communication",
-						MountPath: "/usr/share/rsync",
-					},
-				},
+				Name:             "mnt",
+				MountPath:        getMountPathForPVC(pvc.Source()),
+				MountPropagation: r.options.mountPropagation,
 			},
 		}
-		if isFileSystem {
-			containers[0].VolumeMounts = append(containers[0].VolumeMounts, v1.VolumeMount{
-				Name:      "mnt",
-				MountPath: getMountPathForPVC(pvc.Source()),
-			})
-		}
-		// attach transport containers
-		customizeTransportClientContainers(r.Transport())
-		containers = append(containers, r.Transport().ClientContainers()...)
-		// apply container mutations
-		for i := range containers {
-			c := &containers[i]
-			applyContainerMutations(c, r.options.SourceContainerMutations)
+		if r.options.itemizedLog != nil {
+			mounts = append(mounts, itemizedLogVolumeMount(r.options.itemizedLog, pvc))
 		}
-
 		volumes := []v1.Volume{
 			{
-				Name: "rsync-communication",
-				VolumeSource: v1.VolumeSource{
-					EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumDefault},
-				},
-			},
-		}
-		if isFileSystem {
-			volumes = append(volumes, v1.Volume{
 				Name: "mnt",
 				VolumeSource: v1.VolumeSource{
 					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
-						ClaimName: pvc.Source().Claim().Name,
+						ClaimName: r.sourceClaimName(pvc),
 					},
 				},
-			})
+			},
 		}
-		volumes = append(volumes, r.Transport().ClientVolumes()...)
-		podSpec := v1.PodSpec{
-			Containers:    containers,
-			Volumes:       volumes,
-			RestartPolicy: v1.RestartPolicyNever,
+		if r.options.itemizedLog != nil {
+			volumes = append(volumes, itemizedLogVolume(r.options.itemizedLog))
 		}
+		pod := r.newRsyncClientPod(pvc.Source().Claim().Namespace,
+			clientPodLabels(transferOptions.SourcePodMeta.Labels, pvc), nil,
+			previousAttempts[pvc.Source().LabelSafeName()]+1,
+			rsyncCommandBashScript, mounts, volumes)
+		errs = append(errs, c.Create(ctx, &pod, &client.CreateOptions{}))
+	}
+
+	return errorsutil.NewAggregate(errs)
+}
+
+// sourceClaimName returns the name of the PVC the client of the given pair mounts, the clone of the
+// source PVC with SnapshotSource
+func (r *RsyncTransfer) sourceClaimName(pvc transfer.PVCPair) string {
+	if r.options.snapshotSource != nil {
+		return transfer.SnapshotClaimName(pvc.Source())
+	}
+	return pvc.Source().Claim().Name
+}
+
+// isFileSystemPVC returns whether the source PVC of the given pair is a Filesystem PVC, rsync
+// clients are only created for those
+func isFileSystemPVC(pvc transfer.PVCPair) bool {
+	return pvc.Source().Claim().Spec.VolumeMode == nil || *pvc.Source().Claim().Spec.VolumeMode == v1.PersistentVolumeFilesystem
+}
 
-		applyPodMutations(&podSpec, r.options.SourcePodMutations)
+// clientTerminationMessage returns the commands copying rsync errors to the termination message of
+// the rsync client container
+func (r *RsyncTransfer) clientTerminationMessage() string {
+	terminationMessage := fmt.Sprintf(rsyncErrorsToTerminationMessage, rsyncErrorsFile, maxTerminationMessageBytes)
+	if r.options.tolerateVanishedFiles {
+		terminationMessage += "; " + fmt.Sprintf(vanishedFilesToTerminationMessage, vanishedFileMarker, rsyncErrorsFile, vanishedFilesPrefix)
+	}
+	return terminationMessage
+}
 
-		pod := v1.Pod{
-			ObjectMeta: metav1.ObjectMeta{
-				GenerateName: "rsync-",
-				Namespace:    pvc.Source().Claim().Namespace,
-				Labels:       podLabels,
+// clientExitCode returns the commands adjusting the exit code of the rsync command of the given PVC
+// in $rc, they write the completion marker and tolerate vanished files
+func (r *RsyncTransfer) clientExitCode(pvc transfer.PVCPair) string {
+	exitCode := ""
+	if r.options.completionMarker != nil {
+		// the marker is written before vanished files are tolerated so that it is only written on a clean exit
+		exitCode = fmt.Sprintf("if [ $rc -eq 0 ]; then %s; rc=$?; fi; ", r.getCompletionMarkerCommand(pvc))
+	}
+	if r.options.tolerateVanishedFiles {
+		exitCode += fmt.Sprintf(tolerateVanishedFilesExit, rsyncExitVanished, rsyncExitVanished, rsyncErrorsFile, rsyncErrorsFile, rsyncExitVanished)
+	}
+	return exitCode
+}
+
+// newRsyncClientPod returns an rsync client pod running the given script next to the client
+// containers of the transport, the PVCs are mounted with the given mounts and volumes
+func (r *RsyncTransfer) newRsyncClientPod(namespace string, labels map[string]string, annotations map[string]string, attempt int,
+	script string, mounts []v1.VolumeMount, volumes []v1.Volume) v1.Pod {
+	// create rsync container
+	containers := []v1.Container{
+		{
+			Name:            RsyncContainer,
+			Image:           r.getRsyncClientImage(),
+			ImagePullPolicy: r.options.imagePullPolicy,
+			Command:         []string{"/bin/bash", "-c", script},
+			Env: []v1.EnvVar{
+				{
+					Name: "RSYNC_PASSWORD",
+					ValueFrom: &v1.EnvVarSource{
+						SecretKeyRef: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: defaultRsyncClientSecret},
+							Key:                  rsyncPasswordKey,
+						},
+					},
+				},
 			},
-			Spec: podSpec,
+
+			VolumeMounts: append([]v1.VolumeMount{
+				{
+					Name:      "rsync-communication",
+					MountPath: "/usr/share/rsync",
+				},
+			}, mounts...),
+		},
+	}
+	// attach transport containers
+	customizeTransportClientContainers(r.Transport())
+	containers = append(containers, r.Transport().ClientContainers()...)
+	// apply container mutations
+	for i := range containers {
+		c := &containers[i]
+		applyContainerMutations(c, r.options.SourceContainerMutations)
+		if c.Name == RsyncContainer {
+			applyEphemeralStorage(c, r.options.ephemeralStorage)
 		}
+		if r.options.guaranteedQoS {
+			applyGuaranteedQoS(c)
+		}
+	}
 
-		if fileSystemCount > 0 {
-			err := c.Create(context.TODO(), &pod, &client.CreateOptions{})
-			errs = append(errs, err)
+	volumes = append([]v1.Volume{
+		{
+			Name: "rsync-communication",
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumDefault},
+			},
+		},
+	}, volumes...)
+	volumes = append(volumes, r.Transport().ClientVolumes()...)
+	podSpec := v1.PodSpec{
+		Containers:    containers,
+		Volumes:       volumes,
+		RestartPolicy: v1.RestartPolicyNever,
+	}
+
+	applyPodMutations(&podSpec, r.options.SourcePodMutations)
+	if r.options.schedulerName != "" {
+		podSpec.SchedulerName = r.options.schedulerName
+	}
+
+	podAnnotations := r.options.bandwidthLimit.PodAnnotations()
+	podAnnotations[transfer.AttemptAnnotation] = strconv.Itoa(attempt)
+	for k, v := range annotations {
+		podAnnotations[k] = v
+	}
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "rsync-",
+			Namespace:    namespace,
+			Labels:       meta.WithOwnerLabel(labels),
+			Annotations:  podAnnotations,
+		},
+		Spec: podSpec,
+	}
+}
+
+// getClientRsyncCommand returns the rsync command run by the client
+func (r *RsyncTransfer) getClientRsyncCommand(pvc transfer.PVCPair, rsyncOptions []string) string {
+	stream := ""
+	if r.reportsProgress() {
+		stream = pvc.Source().LabelSafeName()
+	}
+	return r.rsyncCommand(pvc, rsyncOptions, stream)
+}
+
+// getRsyncCommand returns the rsync command line that transfers the given PVC to its destination module.
+// When more than one parallel stream is configured, each top-level entry of the volume is copied by a
+// separate rsync process, with at most the configured number of processes running at a time.
+// When the PVC pair has a source path, only that subdirectory of the volume is copied.
+func (r *RsyncTransfer) getRsyncCommand(pvc transfer.PVCPair, rsyncOptions []string) string {
+	return r.rsyncCommand(pvc, rsyncOptions, "")
+}
+
+// rsyncCommand returns the rsync command line of getRsyncCommand, the output of every rsync process
+// is piped to progressFunction with the given stream when it is not empty
+func (r *RsyncTransfer) rsyncCommand(pvc transfer.PVCPair, rsyncOptions []string, stream string) string {
+	rsyncCommand := []string{"/usr/bin/rsync"}
+	rsyncCommand = append(rsyncCommand, filterRules(transfer.GetFilter(pvc))...)
+	rsyncCommand = append(rsyncCommand, rsyncOptions...)
+	sourcePath := transfer.GetSourcePath(pvc)
+	source := getMountPathForPVC(pvc.Source())
+	if sourcePath.Path != "" {
+		source = fmt.Sprintf("%s/%s", source, sourcePath.Path)
+	}
+	module := pvc.Destination().LabelSafeName()
+	if sourcePath.Path != "" && sourcePath.IncludeDirectory && r.transferOptions().parallelism > 1 {
+		module = fmt.Sprintf("%s/%s", module, path.Base(sourcePath.Path))
+	}
+	destination := fmt.Sprintf("rsync://%s@%s/%s --port %d",
+		r.transferOptions().username, transfer.ConnectionHostname(r),
+		module, r.Transport().Port())
+	if r.transferOptions().parallelism > 1 && stream != "" {
+		// the rsync command is passed as arguments so that its quoting is preserved
+		return fmt.Sprintf("cd %s && find . -mindepth 1 -maxdepth 1 -print0 | xargs -0 -P %d -I{} bash -c 'set -o pipefail; \"${@:2}\" | %s \"$1\"' _ %s %s {} %s",
+			source, r.transferOptions().parallelism, progressFunctionName,
+			stream, strings.Join(rsyncCommand, " "), destination)
+	}
+	if r.transferOptions().parallelism > 1 {
+		return fmt.Sprintf("cd %s && find . -mindepth 1 -maxdepth 1 -print0 | xargs -0 -P %d -I{} %s {} %s",
+			source, r.transferOptions().parallelism,
+			strings.Join(rsyncCommand, " "), destination)
+	}
+	// without a trailing slash rsync copies the directory itself rather than its contents
+	if sourcePath.Path == "" || !sourcePath.IncludeDirectory {
+		source = source + "/"
+	}
+	rsyncCommand = append(rsyncCommand, source, destination)
+	if stream != "" {
+		rsyncCommand = append(rsyncCommand, "|", progressFunctionName, stream)
+	}
+	return strings.Join(rsyncCommand, " ")
+}
+
+// reportsProgress returns whether the rsync clients report their progress with --info=progress2,
+// in which case it is tagged by stream in their logs, see ParseProgress
+func (r *RsyncTransfer) reportsProgress() bool {
+	for _, info := range r.transferOptions().Info {
+		if strings.EqualFold(info, "progress2") {
+			return true
 		}
 	}
+	return false
+}
 
-	return errorsutil.NewAggregate(errs)
+// clientScriptPrefix returns the definitions the scripts of the rsync clients start with
+func (r *RsyncTransfer) clientScriptPrefix() string {
+	if !r.reportsProgress() {
+		return ""
+	}
+	return progressFunction
+}
+
+// filterRules returns the rsync filter rules of the given filter of a PVC. rsync applies the first
+// rule a file matches, so the includes come first and the rules of the PVC precede the excludes of
+// the transfer options. The patterns were validated by transfer.NewPVCPairWithFilter, they are
+// quoted so that the shell does not expand them.
+func filterRules(filter transfer.Filter) []string {
+	rules := []string{}
+	for _, pattern := range filter.Include {
+		rules = append(rules, fmt.Sprintf("'"+optInclude+"'", pattern))
+	}
+	for _, pattern := range filter.Exclude {
+		rules = append(rules, fmt.Sprintf("'"+optExclude+"'", pattern))
+	}
+	return rules
+}
+
+// getCompletionMarkerCommand returns the commands writing the completion marker of the given PVC and
+// uploading it to the root of its destination module
+func (r *RsyncTransfer) getCompletionMarkerCommand(pvc transfer.PVCPair) string {
+	marker := r.transferOptions().completionMarker
+	markerFile := completionMarkerDir + "/" + marker.Name
+	content := ""
+	if marker.Content != "" {
+		// the content is encoded so that it needs no quoting
+		content = "echo " + base64.StdEncoding.EncodeToString([]byte(marker.Content)) + " | base64 -d"
+	} else {
+		source := getMountPathForPVC(pvc.Source())
+		if sourcePath := transfer.GetSourcePath(pvc); sourcePath.Path != "" {
+			source = source + "/" + sourcePath.Path
+		}
+		content = strings.Join([]string{
+			"echo \"completed=$(date -u +%Y-%m-%dT%H:%M:%SZ)\"",
+			"echo 'pvc=" + pvc.Source().Claim().Namespace + "/" + pvc.Source().Claim().Name + "'",
+			"echo \"bytes=$(du -sb " + source + " | cut -f1)\"",
+			"echo \"files=$(find " + source + " -type f | wc -l)\"",
+			// the checksum covers the path, size and modification time of every file
+			"echo \"checksum=sha256:$(cd " + source + " && find . -type f -printf '%P %s %T@\\n' | sort | sha256sum | cut -d' ' -f1)\"",
+		}, "; ")
+	}
+	return fmt.Sprintf("mkdir -p %s && { %s; } > %s && /usr/bin/rsync %s rsync://%s@%s/%s/ --port %d",
+		completionMarkerDir, content, markerFile, markerFile,
+		r.transferOptions().username, transfer.ConnectionHostname(r),
+		pvc.Destination().LabelSafeName(), r.Transport().Port())
+}
+
+// itemizedLogVolumeMount returns the mount of the itemized log volume for the client of the given PVC,
+// clients sharing a log PVC write to a sub directory named after their PVC
+func itemizedLogVolumeMount(l *ItemizedLog, pvc transfer.PVCPair) v1.VolumeMount {
+	mount := v1.VolumeMount{
+		Name:      itemizedLogVolumeName,
+		MountPath: itemizedLogDir,
+	}
+	if l.ClaimName != "" {
+		mount.SubPath = pvc.Source().LabelSafeName()
+	}
+	return mount
+}
+
+// itemizedLogVolume returns the volume the itemized logs are written to
+func itemizedLogVolume(l *ItemizedLog) v1.Volume {
+	volume := v1.Volume{
+		Name: itemizedLogVolumeName,
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{Medium: v1.StorageMediumDefault},
+		},
+	}
+	if l.ClaimName != "" {
+		volume.VolumeSource = v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: l.ClaimName,
+			},
+		}
+	}
+	return volume
 }
 
 // customizeTransportClientContainers customizes transport's client containers for specific rsync communication
@@ -162,17 +423,7 @@ func customizeTransportClientContainers(t transport.Transport) {
 		stunnelContainer.Command = []string{
 			"/bin/bash",
 			"-c",
-			`/bin/stunnel /etc/stunnel/stunnel.conf
-while true
-do test -f /usr/share/rsync/rsync-client-container-done
-if [ $? -eq 0 ]
-then
-	break
-else
-	sleep 1
-fi
-done
-exit 0`,
+			stunnel.ClientStartCommand(t) + "\n" + waitForRsyncClientScript,
 		}
 		stunnelContainer.VolumeMounts = append(
 			stunnelContainer.VolumeMounts,
@@ -180,5 +431,36 @@ exit 0`,
 				Name:      "rsync-communication",
 				MountPath: "/usr/share/rsync",
 			})
+	case ssh.TransportTypeSSH:
+		for i := range t.ClientContainers() {
+			c := &t.ClientContainers()[i]
+			if c.Name != ssh.SSHContainer || c.Command[0] == "/bin/bash" {
+				continue
+			}
+			// ssh goes to the background once the port is forwarded
+			command := append([]string{c.Command[0], "-f"}, c.Command[1:]...)
+			c.Command = []string{
+				"/bin/bash",
+				"-c",
+				strings.Join(command, " ") + "\n" + waitForRsyncClientScript,
+			}
+			c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+				Name:      "rsync-communication",
+				MountPath: "/usr/share/rsync",
+			})
+		}
 	}
 }
+
+// waitForRsyncClientScript keeps the transport client container running until the rsync client
+// is done
+const waitForRsyncClientScript = `while true
+do test -f /usr/share/rsync/rsync-client-container-done
+if [ $? -eq 0 ]
+then
+	break
+else
+	sleep 1
+fi
+done
+exit 0`
@@ -0,0 +1,263 @@
+package rsync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGetRsyncCommandSourcePath(t *testing.T) {
+	tr, _, _ := createTransfer(t)
+	pvc := createPVC(testPVCName, testNamespace)
+	mountPath := getMountPathForPVC(tr.PVCs()[0].Source())
+	tests := []struct {
+		name       string
+		sourcePath transfer.SourcePath
+		wantSource string
+	}{
+		{
+			name:       "volume root",
+			wantSource: mountPath + "/ ",
+		},
+		{
+			name:       "contents of a subdirectory",
+			sourcePath: transfer.SourcePath{Path: "/data/app/"},
+			wantSource: mountPath + "/data/app/ ",
+		},
+		{
+			name:       "subdirectory itself",
+			sourcePath: transfer.SourcePath{Path: "data/app", IncludeDirectory: true},
+			wantSource: mountPath + "/data/app ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := transfer.NewPVCPairWithSourcePath(pvc, nil, tt.sourcePath)
+			if err != nil {
+				t.Fatalf("NewPVCPairWithSourcePath() unexpected error %v", err)
+			}
+			pvcList, err := transfer.NewFilesystemPVCPairList(pair)
+			if err != nil {
+				t.Fatalf("invalid pvc list: %v", err)
+			}
+			if cmd := tr.getRsyncCommand(pvcList[0], []string{}); !strings.Contains(cmd, tt.wantSource) {
+				t.Errorf("rsync command %q does not use source %q", cmd, tt.wantSource)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"../other", "data/../../etc", "my data", "$(reboot)"} {
+		if _, err := transfer.NewPVCPairWithSourcePath(pvc, nil, transfer.SourcePath{Path: invalid}); err == nil {
+			t.Errorf("source path %q should be invalid", invalid)
+		}
+	}
+}
+
+func TestGetRsyncCommandFilter(t *testing.T) {
+	tr, _, _ := createTransfer(t, ExcludePathsLostFound)
+	pair, err := transfer.NewPVCPairWithFilter(transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil), transfer.Filter{
+		Include: []string{"/cache/keep/***"},
+		Exclude: []string{"/cache/", "*.tmp"},
+	})
+	if err != nil {
+		t.Fatalf("NewPVCPairWithFilter() unexpected error %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(pair)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	rsyncOptions, err := tr.options.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	want := "/usr/bin/rsync '--include=/cache/keep/***' '--exclude=/cache/' '--exclude=*.tmp' --exclude=/lost+found "
+	if cmd := tr.getRsyncCommand(pvcList[0], rsyncOptions); !strings.HasPrefix(cmd, want) {
+		t.Errorf("rsync command %q does not start with %q", cmd, want)
+	}
+	if cmd := tr.getRsyncCommand(tr.PVCs()[0], rsyncOptions); strings.Contains(cmd, "--include") {
+		t.Errorf("rsync command of a pvc without filter should not include files: %q", cmd)
+	}
+}
+
+func TestItemizedLog(t *testing.T) {
+	tests := []struct {
+		name        string
+		log         ItemizedLog
+		wantSubPath bool
+	}{
+		{
+			name: "emptyDir with default format",
+			log:  ItemizedLog{},
+		},
+		{
+			name:        "dedicated pvc",
+			log:         ItemizedLog{Format: "%t %i %f", ClaimName: "audit-logs"},
+			wantSubPath: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, srcClient, _ := createTransfer(t, StandardProgress(true), tt.log)
+			if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+			pods := &corev1.PodList{}
+			if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+				t.Fatalf("unable to find rsync client pod: %v", err)
+			}
+			pod := pods.Items[0]
+			format := tt.log.Format
+			if format == "" {
+				format = DefaultItemizedLogFormat
+			}
+			cmd := pod.Spec.Containers[0].Command[2]
+			for _, want := range []string{"--itemize-changes", "--log-file=/var/log/rsync/rsync.log", "--log-file-format='" + format + "'"} {
+				if !strings.Contains(cmd, want) {
+					t.Errorf("rsync command does not contain %s: %s", want, cmd)
+				}
+			}
+			if strings.Contains(cmd, "--log-file=/dev/stdout") {
+				t.Errorf("itemized log file should replace the stdout log file: %s", cmd)
+			}
+			var mount *corev1.VolumeMount
+			for i, m := range pod.Spec.Containers[0].VolumeMounts {
+				if m.Name == itemizedLogVolumeName {
+					mount = &pod.Spec.Containers[0].VolumeMounts[i]
+				}
+			}
+			if mount == nil || mount.MountPath != "/var/log/rsync" {
+				t.Fatalf("itemized log volume is not mounted in the rsync container")
+			}
+			if (mount.SubPath != "") != tt.wantSubPath {
+				t.Errorf("unexpected sub path %q for the itemized log mount", mount.SubPath)
+			}
+			for _, v := range pod.Spec.Volumes {
+				if v.Name == itemizedLogVolumeName && tt.log.ClaimName != "" &&
+					(v.PersistentVolumeClaim == nil || v.PersistentVolumeClaim.ClaimName != tt.log.ClaimName) {
+					t.Errorf("itemized log volume does not use pvc %s", tt.log.ClaimName)
+				}
+			}
+		})
+	}
+	if err := (ItemizedLog{Format: "%n'; reboot"}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("log format with quotes should be invalid")
+	}
+}
+
+func TestSnapshotSource(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, StandardProgress(true), SnapshotSource{VolumeSnapshotClassName: "csi-snapclass"})
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	clone := transfer.SnapshotClaimName(tr.PVCs()[0].Source())
+	for _, v := range pods.Items[0].Spec.Volumes {
+		if v.Name == "mnt" && v.PersistentVolumeClaim.ClaimName != clone {
+			t.Errorf("rsync client mounts %s, want the clone %s", v.PersistentVolumeClaim.ClaimName, clone)
+		}
+	}
+	if err := srcClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: clone}, &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Errorf("unable to get the clone of the source pvc: %v", err)
+	}
+	if err := (SnapshotSource{StorageClassName: "Invalid_Class"}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("invalid storage class name should be rejected")
+	}
+}
+
+func TestCompletionMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []TransferOption
+		want     []string
+		wantNone bool
+	}{
+		{
+			name:     "no marker",
+			wantNone: true,
+		},
+		{
+			name:    "default marker",
+			options: []TransferOption{CompletionMarker{}},
+			want:    []string{"if [ $rc -eq 0 ]; then mkdir -p /tmp/crane-marker", "> /tmp/crane-marker/.crane-complete", "pvc=" + testNamespace + "/" + testPVCName, "checksum=sha256:"},
+		},
+		{
+			name:    "custom marker",
+			options: []TransferOption{CompletionMarker{Name: "DONE", Content: "migrated by 'crane'\n"}},
+			want:    []string{"> /tmp/crane-marker/DONE", "echo bWlncmF0ZWQgYnkgJ2NyYW5lJwo= | base64 -d"},
+		},
+		{
+			name:    "marker with vanished files tolerated",
+			options: []TransferOption{TolerateVanishedFiles(true), CompletionMarker{}},
+			want:    []string{"/.crane-complete rsync://crane2@localhost/", "; rc=$?; fi; if [ $rc -eq 24 ]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, srcClient, _ := createTransfer(t, tt.options...)
+			if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+			pods := &corev1.PodList{}
+			if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+				t.Fatalf("unable to find rsync client pod: %v", err)
+			}
+			cmd := pods.Items[0].Spec.Containers[0].Command[2]
+			if tt.wantNone && strings.Contains(cmd, completionMarkerDir) {
+				t.Errorf("rsync command should not write a completion marker: %s", cmd)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(cmd, want) {
+					t.Errorf("rsync command does not contain %q: %s", want, cmd)
+				}
+			}
+		})
+	}
+
+	for _, invalid := range []string{"..", "dir/marker", "$(reboot)", "done marker"} {
+		if err := (CompletionMarker{Name: invalid}).ApplyTo(&TransferOptions{}); err == nil {
+			t.Errorf("completion marker name %q should be invalid", invalid)
+		}
+	}
+}
+
+func TestCustomizeSSHClientContainers(t *testing.T) {
+	c := buildTestClient()
+	e := service.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		statetransfermeta.Labels, "test.host", corev1.ServiceTypeLoadBalancer, nil)
+	s := ssh.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{})
+	if _, err := transport.CreateServer(context.TODO(), s, c, "fs", e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	if _, err := transport.CreateClient(context.TODO(), s, c, "fs", e); err != nil {
+		t.Fatalf("unable to create transport client: %v", err)
+	}
+	// customizing again must not wrap the command twice
+	for i := 0; i < 2; i++ {
+		customizeTransportClientContainers(s)
+	}
+	container := s.ClientContainers()[0]
+	if len(container.Command) != 3 || container.Command[0] != "/bin/bash" {
+		t.Fatalf("unexpected ssh client command %v", container.Command)
+	}
+	if !strings.HasPrefix(container.Command[2], "/usr/bin/ssh -f -N ") || !strings.Contains(container.Command[2], "rsync-client-container-done") {
+		t.Errorf("ssh client should go to the background and wait for rsync, got %s", container.Command[2])
+	}
+	if len(container.VolumeMounts) != 2 {
+		t.Errorf("unexpected ssh client volume mounts %v", container.VolumeMounts)
+	}
+}
@@ -0,0 +1,73 @@
+package rsync
+
+import (
+	"context"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// labelServerCorrelationID sets the correlation ID label on the resources of the endpoint and of the
+// transport server, which are created before the transfer and do not know about its options
+func (r *RsyncTransfer) labelServerCorrelationID(ctx context.Context, c client.Client, ns string) error {
+	objs, err := endpoint.Render(ctx, r.Endpoint(), c)
+	if err != nil {
+		return err
+	}
+	objs = append(objs, r.transportVolumeObjects(ns, r.Transport().ServerVolumes())...)
+	return r.labelCorrelationID(ctx, c, objs)
+}
+
+// labelClientCorrelationID sets the correlation ID label on the resources of the transport client
+func (r *RsyncTransfer) labelClientCorrelationID(ctx context.Context, c client.Client, ns string) error {
+	objs := r.transportVolumeObjects(ns, r.Transport().ClientVolumes())
+	return r.labelCorrelationID(ctx, c, objs)
+}
+
+// transportVolumeObjects returns the ConfigMaps and the Secrets of the transport mounted by the
+// given volumes in the given namespace, which are the resources the transport created for its
+// containers. Secrets shared with other transfers through SecretPrefix are left out as they do
+// not belong to this transfer.
+func (r *RsyncTransfer) transportVolumeObjects(ns string, volumes []corev1.Volume) []client.Object {
+	sharedSecrets := r.Transport().Options() != nil && r.Transport().Options().SecretPrefix != ""
+	objs := []client.Object{}
+	for _, volume := range volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			objs = append(objs, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: volume.ConfigMap.Name}})
+		case volume.Secret != nil && !sharedSecrets:
+			objs = append(objs, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: volume.Secret.SecretName}})
+		}
+	}
+	return objs
+}
+
+// labelCorrelationID sets the correlation ID label on the given objects which exist in the cluster
+// c points at, objects without the owner label of the library are left unchanged
+func (r *RsyncTransfer) labelCorrelationID(ctx context.Context, c client.Client, objs []client.Object) error {
+	errs := []error{}
+	for _, obj := range objs {
+		existing := obj.DeepCopyObject().(client.Object)
+		err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !meta.IsOwned(existing.GetLabels()) || existing.GetLabels()[meta.CorrelationIDLabel] == r.options.correlationID {
+			continue
+		}
+		patch := client.MergeFrom(existing.DeepCopyObject().(client.Object))
+		labels, _ := meta.WithCorrelationID(existing.GetLabels(), r.options.correlationID)
+		existing.SetLabels(labels)
+		errs = append(errs, c.Patch(ctx, existing, patch))
+	}
+	return errorsutil.NewAggregate(errs)
+}
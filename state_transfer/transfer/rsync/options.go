@@ -4,36 +4,123 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/konveyor/crane-lib/state_transfer/meta"
 	metadata "github.com/konveyor/crane-lib/state_transfer/meta"
 	transfer "github.com/konveyor/crane-lib/state_transfer/transfer"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 const (
-	optRecursive     = "--recursive"
-	optSymLinks      = "--links"
-	optPermissions   = "--perms"
-	optModTimes      = "--times"
-	optDeviceFiles   = "--devices"
-	optSpecialFiles  = "--specials"
-	optOwner         = "--owner"
-	optGroup         = "--group"
-	optHardLinks     = "--hard-links"
-	optPartial       = "--partial"
-	optDelete        = "--delete"
-	optBwLimit       = "--bwlimit=%d"
-	optInfo          = "--info=%s"
-	optHumanReadable = "--human-readable"
-	optLogFile       = "--log-file=%s"
-	optExclude       = "--exclude=%s"
+	optRecursive      = "--recursive"
+	optSymLinks       = "--links"
+	optCopyLinks      = "--copy-links"
+	optSafeLinks      = "--safe-links"
+	optPermissions    = "--perms"
+	optModTimes       = "--times"
+	optDeviceFiles    = "--devices"
+	optSpecialFiles   = "--specials"
+	optOwner          = "--owner"
+	optGroup          = "--group"
+	optIgnoreExisting = "--ignore-existing"
+	optHardLinks      = "--hard-links"
+	optXattrs         = "--xattrs"
+	optACLs           = "--acls"
+	optSparse         = "--sparse"
+	optNumericIDs     = "--numeric-ids"
+	optUserMap        = "'--usermap=%s'"
+	optGroupMap       = "'--groupmap=%s'"
+	optPartial        = "--partial"
+	optPartialDir     = "--partial-dir=%s"
+	optTempDir        = "--temp-dir=%s"
+	optDelete         = "--delete"
+	optBwLimit        = "--bwlimit=%d"
+	optInfo           = "--info=%s"
+	optHumanReadable  = "--human-readable"
+	optLogFile        = "--log-file=%s"
+	optLogFileFormat  = "--log-file-format='%s'"
+	optItemize        = "--itemize-changes"
+	optExclude        = "--exclude=%s"
+	optInclude        = "--include=%s"
+	optMaxSize        = "--max-size=%d"
+	optModifyWindow   = "--modify-window=%d"
+	optBlockSize      = "--block-size=%d"
+	optWholeFile      = "--whole-file"
+	optNoWholeFile    = "--no-whole-file"
+)
+
+const (
+	// DefaultRetryBackoff is the time waited before the first retry of a failed transfer
+	DefaultRetryBackoff = 10 * time.Second
+	// rsyncExitStartingProtocol is the rsync exit code of errors starting the client-server protocol
+	rsyncExitStartingProtocol = 5
+	// rsyncExitSocketIO is the rsync exit code of errors in socket I/O
+	rsyncExitSocketIO = 10
+	// rsyncExitProtocolStream is the rsync exit code of errors in the protocol data stream
+	rsyncExitProtocolStream = 12
+	// rsyncExitVanished is the rsync exit code of a partial transfer due to vanished source files
+	rsyncExitVanished = 24
+	// rsyncExitTimeout is the rsync exit code of a timeout in data send or receive
+	rsyncExitTimeout = 30
+	// rsyncExitConnectTimeout is the rsync exit code of a timeout waiting for the daemon connection
+	rsyncExitConnectTimeout = 35
+)
+
+// DefaultRetriableExitCodes are the rsync exit codes of transient failures, such as connections
+// reset through the transport, retried by a RetryPolicy which does not set RetriableExitCodes
+var DefaultRetriableExitCodes = []int{
+	rsyncExitStartingProtocol,
+	rsyncExitSocketIO,
+	rsyncExitProtocolStream,
+	rsyncExitTimeout,
+	rsyncExitConnectTimeout,
+}
+
+const (
+	// DefaultPartialDir is the hidden directory partial files are kept in with PartialDir
+	DefaultPartialDir = ".rsync-partial"
 )
 
 const (
 	logFileStdOut = "/dev/stdout"
+	// itemizedLogDir is where the itemized log volume is mounted in rsync client containers
+	itemizedLogDir = "/var/log/rsync"
+	// itemizedLogFile is the itemized log written by each rsync client
+	itemizedLogFile = itemizedLogDir + "/rsync.log"
+	// itemizedLogVolumeName is the name of the volume the itemized log is written to
+	itemizedLogVolumeName = "rsync-log"
+	// tempDir is where the temporary files volume is mounted in the rsync server container
+	tempDir = "/var/tmp/rsync"
+	// tempDirVolumeName is the name of the volume temporary files are written to
+	tempDirVolumeName = "rsync-temp"
+	// DefaultItemizedLogFormat logs the itemized changes, the file name and the symlink target
+	DefaultItemizedLogFormat = "%i %n%L"
+	// DefaultCompletionMarkerName is the name of the file written by CompletionMarker
+	DefaultCompletionMarkerName = ".crane-complete"
+	// completionMarkerDir is where rsync client containers write the completion marker before uploading it
+	completionMarkerDir = "/tmp/crane-marker"
+)
+
+const (
+	// minBlockSize is the smallest checksum block size accepted by BlockSize
+	minBlockSize = 512
+	// maxBlockSize is the largest checksum block size supported by rsync protocol 30 and later
+	maxBlockSize = 128 * 1024
+	// maxParallelism is the maximum number of parallel rsync streams per PVC
+	maxParallelism = 8
+	// autoParallelismStreamSize is the amount of data in bytes that justifies an additional rsync stream
+	autoParallelismStreamSize = int64(100 * 1024 * 1024 * 1024)
+	// autoParallelismStreamFiles is the number of files that justifies an additional rsync stream,
+	// rsync is bound by the round trips it makes for every file rather than by the bandwidth when
+	// copying many small files
+	autoParallelismStreamFiles = int64(1000000)
 )
 
 // TransferOptions defines customizeable options for Rsync Transfer
@@ -47,9 +134,38 @@ type TransferOptions struct {
 	DestContainerMutations   []meta.ContainerMutation
 	username                 string
 	password                 string
+	generatedPassword        bool
 	rsyncServerImage         string
 	rsyncClientImage         string
 	mungeSymlinks            bool
+	parallelism              int
+	autoParallelism          bool
+	autoParallelismEstimate  *AutoParallelismEstimate
+	correlationID            string
+	guaranteedQoS            bool
+	scheme                   *runtime.Scheme
+	fixPermissions           *FixDestinationPermissions
+	itemizedLog              *ItemizedLog
+	lazyRsync                bool
+	mountPropagation         *v1.MountPropagationMode
+	destinationPolicy        DestinationPolicy
+	separateTransportServer  bool
+	ephemeralStorage         *v1.ResourceRequirements
+	preTransferHook          *transfer.PreTransferHook
+	snapshotSource           *transfer.SnapshotSource
+	destinationPVCs          *transfer.DestinationPVCOptions
+	bandwidthLimit           *transfer.BandwidthLimit
+	ownershipMapping         *transfer.OwnershipMapping
+	retryPolicy              transfer.RetryPolicy
+	retriableExitCodes       []int
+	tempDir                  *TempDir
+	schedulerName            string
+	tolerateVanishedFiles    bool
+	completionMarker         *CompletionMarker
+	symlinkMode              SymlinkMode
+	batchClients             bool
+	imagePullPolicy          v1.PullPolicy
+	clientLogs               *ClientLogs
 }
 
 // TransferOption knows how to apply a user provided option to a given TransferOptions
@@ -69,25 +185,53 @@ func (t *TransferOptions) Apply(opts ...TransferOption) error {
 
 // CommandOptions defines options that can be customized in the Rsync command
 type CommandOptions struct {
-	Recursive     bool
-	SymLinks      bool
-	Permissions   bool
-	ModTimes      bool
-	DeviceFiles   bool
-	SpecialFiles  bool
-	Groups        bool
-	Owners        bool
-	HardLinks     bool
-	Delete        bool
-	Partial       bool
-	BwLimit       *int
-	HumanReadable bool
-	LogFile       string
-	Info          []string
-	ExcludeFiles  []string
-	Extras        []string
+	Recursive    bool
+	SymLinks     bool
+	CopyLinks    bool
+	SafeLinks    bool
+	Permissions  bool
+	ModTimes     bool
+	DeviceFiles  bool
+	SpecialFiles bool
+	Groups       bool
+	Owners       bool
+	HardLinks    bool
+	// Xattrs preserves extended attributes, including SELinux labels
+	Xattrs bool
+	// ACLs preserves POSIX ACLs, it implies Permissions
+	ACLs bool
+	// Sparse recreates the holes of sparse files on the destination
+	Sparse bool
+	// NumericIDs preserves owners and groups by id rather than by name
+	NumericIDs bool
+	// UserMap changes the owners of files on the destination with --usermap, it requires Owners
+	UserMap string
+	// GroupMap changes the groups of files on the destination with --groupmap, it requires Groups
+	GroupMap string
+	Delete   bool
+	// IgnoreExisting skips files which already exist on the destination
+	IgnoreExisting bool
+	Partial        bool
+	PartialDir     string
+	TempDir        string
+	BwLimit        *int
+	WholeFile      *bool
+	MaxSize        *int64
+	ModifyWindow   int
+	BlockSize      int
+	HumanReadable  bool
+	LogFile        string
+	LogFileFormat  string
+	Itemize        bool
+	Info           []string
+	ExcludeFiles   []string
+	Extras         []string
 }
 
+// idMapRegex matches the values of --usermap and --groupmap, comma separated FROM:TO pairs of ids,
+// id ranges, names or wildcards
+var idMapRegex = regexp.MustCompile(`^[A-Za-z0-9_.*-]+:[A-Za-z0-9_.-]+(,[A-Za-z0-9_.*-]+:[A-Za-z0-9_.-]+)*$`)
+
 // AsRsyncCommandOptions returns validated rsync options and validation errors as two lists
 func (c *CommandOptions) AsRsyncCommandOptions() ([]string, error) {
 	var errs []error
@@ -95,8 +239,16 @@ func (c *CommandOptions) AsRsyncCommandOptions() ([]string, error) {
 	if c.Recursive {
 		opts = append(opts, optRecursive)
 	}
-	if c.SymLinks {
+	if c.CopyLinks {
+		if c.SafeLinks {
+			errs = append(errs, fmt.Errorf("rsync copy-links and safe-links cannot be combined"))
+		}
+		opts = append(opts, optCopyLinks)
+	} else if c.SymLinks {
 		opts = append(opts, optSymLinks)
+		if c.SafeLinks {
+			opts = append(opts, optSafeLinks)
+		}
 	}
 	if c.Permissions {
 		opts = append(opts, optPermissions)
@@ -119,12 +271,59 @@ func (c *CommandOptions) AsRsyncCommandOptions() ([]string, error) {
 	if c.HardLinks {
 		opts = append(opts, optHardLinks)
 	}
+	if c.Xattrs {
+		opts = append(opts, optXattrs)
+	}
+	if c.ACLs {
+		opts = append(opts, optACLs)
+	}
+	if c.Sparse {
+		for _, extra := range c.Extras {
+			if extra == "--inplace" {
+				errs = append(errs, fmt.Errorf("rsync sparse and inplace cannot be combined"))
+			}
+		}
+		opts = append(opts, optSparse)
+	}
+	if c.NumericIDs {
+		if !c.Owners && !c.Groups {
+			errs = append(errs, fmt.Errorf("rsync numeric-ids requires preserving owners or groups"))
+		}
+		opts = append(opts, optNumericIDs)
+	}
+	if c.UserMap != "" {
+		if !c.Owners {
+			errs = append(errs, fmt.Errorf("rsync usermap requires preserving owners"))
+		}
+		if !idMapRegex.MatchString(c.UserMap) {
+			errs = append(errs, fmt.Errorf("invalid rsync usermap %q", c.UserMap))
+		}
+		opts = append(opts, fmt.Sprintf(optUserMap, c.UserMap))
+	}
+	if c.GroupMap != "" {
+		if !c.Groups {
+			errs = append(errs, fmt.Errorf("rsync groupmap requires preserving groups"))
+		}
+		if !idMapRegex.MatchString(c.GroupMap) {
+			errs = append(errs, fmt.Errorf("invalid rsync groupmap %q", c.GroupMap))
+		}
+		opts = append(opts, fmt.Sprintf(optGroupMap, c.GroupMap))
+	}
 	if c.Delete {
 		opts = append(opts, optDelete)
 	}
-	if c.Partial {
+	if c.IgnoreExisting {
+		opts = append(opts, optIgnoreExisting)
+	}
+	// --partial-dir implies --partial
+	if c.PartialDir != "" {
+		opts = append(opts, fmt.Sprintf(optPartialDir, c.PartialDir))
+	} else if c.Partial {
 		opts = append(opts, optPartial)
 	}
+	if c.TempDir != "" {
+		opts = append(opts, fmt.Sprintf(optTempDir, c.TempDir))
+	}
 	if c.BwLimit != nil {
 		if *c.BwLimit > 0 {
 			opts = append(opts,
@@ -133,12 +332,48 @@ func (c *CommandOptions) AsRsyncCommandOptions() ([]string, error) {
 			errs = append(errs, fmt.Errorf("rsync bwlimit value must be a positive integer"))
 		}
 	}
+	if c.MaxSize != nil {
+		if *c.MaxSize > 0 {
+			opts = append(opts, fmt.Sprintf(optMaxSize, *c.MaxSize))
+		} else {
+			errs = append(errs, fmt.Errorf("rsync max-size value must be a positive integer"))
+		}
+	}
+	if c.ModifyWindow < 0 {
+		errs = append(errs, fmt.Errorf("rsync modify-window value must not be negative"))
+	} else if c.ModifyWindow > 0 {
+		opts = append(opts, fmt.Sprintf(optModifyWindow, c.ModifyWindow))
+	}
+	if c.BlockSize != 0 {
+		if c.BlockSize >= minBlockSize && c.BlockSize <= maxBlockSize {
+			opts = append(opts, fmt.Sprintf(optBlockSize, c.BlockSize))
+		} else {
+			errs = append(errs, fmt.Errorf("rsync block-size value must be between %d and %d", minBlockSize, maxBlockSize))
+		}
+	}
+	if c.WholeFile != nil {
+		if *c.WholeFile {
+			opts = append(opts, optWholeFile)
+		} else {
+			opts = append(opts, optNoWholeFile)
+		}
+	}
 	if c.HumanReadable {
 		opts = append(opts, optHumanReadable)
 	}
+	if c.Itemize {
+		opts = append(opts, optItemize)
+	}
 	if c.LogFile != "" {
 		opts = append(opts, fmt.Sprintf(optLogFile, c.LogFile))
 	}
+	if c.LogFileFormat != "" {
+		if strings.ContainsAny(c.LogFileFormat, "'\n") {
+			errs = append(errs, fmt.Errorf("rsync log file format must not contain quotes or new lines"))
+		} else {
+			opts = append(opts, fmt.Sprintf(optLogFileFormat, c.LogFileFormat))
+		}
+	}
 	if len(c.Info) > 0 {
 		validatedOptions, err := filterRsyncInfoOptions(c.Info)
 		errs = append(errs, err)
@@ -213,6 +448,141 @@ func (p PreserveOwnership) ApplyTo(opts *TransferOptions) error {
 	return nil
 }
 
+// PreserveHardLinks keeps hard linked files linked on the destination instead of copying each
+// link separately. rsync has to keep every multiply linked file of the volume in memory to detect
+// links, which can take a significant amount of memory in the client pod for very large trees.
+// With Parallelism, links between files in different top-level directories are not preserved.
+type PreserveHardLinks bool
+
+func (p PreserveHardLinks) ApplyTo(opts *TransferOptions) error {
+	opts.HardLinks = bool(p)
+	return nil
+}
+
+// PreserveXattrs preserves the extended attributes of files, such as the SELinux labels and the
+// attributes databases store metadata in, which are dropped otherwise. Attributes outside of the
+// user namespace, including SELinux labels, can only be set when the destination rsync container
+// runs as root, see DestinationContainerMutation.
+type PreserveXattrs bool
+
+func (p PreserveXattrs) ApplyTo(opts *TransferOptions) error {
+	opts.Xattrs = bool(p)
+	return nil
+}
+
+// PreserveACLs preserves the POSIX ACLs of files, it implies preserving permissions. ACLs of files
+// the destination rsync container does not own can only be set when it runs as root.
+type PreserveACLs bool
+
+func (p PreserveACLs) ApplyTo(opts *TransferOptions) error {
+	opts.ACLs = bool(p)
+	if p {
+		opts.Permissions = true
+	}
+	return nil
+}
+
+// Sparse recreates the holes of sparse files, such as virtual machine images and database files,
+// on the destination instead of writing blocks of zeroes. It cannot be combined with --inplace.
+type Sparse bool
+
+func (s Sparse) ApplyTo(opts *TransferOptions) error {
+	opts.Sparse = bool(s)
+	return nil
+}
+
+// NumericIDs preserves owners and groups by their ids rather than by mapping their names, which
+// differ between the source and the destination images. It requires preserving owners or groups,
+// the rsync server modules are configured with numeric ids as well.
+type NumericIDs bool
+
+func (n NumericIDs) ApplyTo(opts *TransferOptions) error {
+	opts.NumericIDs = bool(n)
+	return nil
+}
+
+// MaxFileSize skips files larger than the given size, such as stray core dumps or disk images.
+// The size is a quantity like 10Gi or 500M. Skipped files are logged by the client when the SKIP
+// info flag is set, which StandardProgress does, use SkippedFiles to list them from the logs.
+type MaxFileSize string
+
+func (m MaxFileSize) ApplyTo(opts *TransferOptions) error {
+	q, err := resource.ParseQuantity(string(m))
+	if err != nil {
+		return fmt.Errorf("invalid max file size %q: %w", string(m), err)
+	}
+	size := q.Value()
+	if size <= 0 {
+		return fmt.Errorf("max file size must be positive, got %s", string(m))
+	}
+	opts.MaxSize = &size
+	return nil
+}
+
+// ModifyWindow sets how much the modification times of a file on the source and the destination
+// may differ while still being considered equal, so that files are not copied again when the
+// filesystems store timestamps with a different precision, like FAT or NFS with coarse mtimes.
+// It must be a non-negative whole number of seconds, modification times must match exactly when
+// it is 0 or not set.
+type ModifyWindow time.Duration
+
+func (m ModifyWindow) ApplyTo(opts *TransferOptions) error {
+	window := time.Duration(m)
+	if window < 0 || window%time.Second != 0 {
+		return fmt.Errorf("modify window must be a non-negative whole number of seconds, got %s", window)
+	}
+	opts.ModifyWindow = int(window / time.Second)
+	return nil
+}
+
+// BlockSize sets the size of the blocks rsync computes checksums of to find the changes of a file
+// which exists on both sides. Larger blocks reduce the checksum overhead of large files with
+// localized changes, like database snapshots synchronized repeatedly, smaller blocks transfer less
+// data around each change. The size is a quantity like 64Ki between 512 and 128Ki, rsync sizes the
+// blocks after each file when not set. It has no effect on files copied whole.
+type BlockSize string
+
+func (b BlockSize) ApplyTo(opts *TransferOptions) error {
+	q, err := resource.ParseQuantity(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid block size %q: %w", string(b), err)
+	}
+	size := q.Value()
+	if size < minBlockSize || size > maxBlockSize {
+		return fmt.Errorf("block size must be between %d and %d bytes, got %s", minBlockSize, maxBlockSize, string(b))
+	}
+	opts.BlockSize = int(size)
+	return nil
+}
+
+// ResumePartial keeps partially transferred files so that an interrupted transfer resumes
+// where it stopped instead of copying those files again from the beginning
+type ResumePartial bool
+
+func (r ResumePartial) ApplyTo(opts *TransferOptions) error {
+	opts.Partial = bool(r)
+	return nil
+}
+
+// PartialDir keeps partially transferred files in the given directory, relative to the directory
+// of each file, until they are complete, so that applications scanning the destination never see
+// incomplete files. It implies ResumePartial, an empty value uses DefaultPartialDir. rsync excludes
+// a relative partial dir from the transfer and from deletions on the destination.
+type PartialDir string
+
+func (p PartialDir) ApplyTo(opts *TransferOptions) error {
+	dir := string(p)
+	if dir == "" {
+		dir = DefaultPartialDir
+	}
+	if strings.ContainsAny(dir, " \t\n\"'`$;&|<>()\\*?[]") {
+		return fmt.Errorf("partial dir %q contains whitespace, quotes, wildcards or shell meta characters", dir)
+	}
+	opts.Partial = true
+	opts.PartialDir = dir
+	return nil
+}
+
 type StandardProgress bool
 
 func (s StandardProgress) ApplyTo(opts *TransferOptions) error {
@@ -224,6 +594,23 @@ func (s StandardProgress) ApplyTo(opts *TransferOptions) error {
 	return nil
 }
 
+// ClientLogs reads the progress of the rsync clients from their logs, so that Progress reports the
+// bytes and the files copied while the PVCs are being transferred rather than an estimate once
+// they completed. It requires StandardProgress and the get verb on pods/log in the source
+// namespace.
+type ClientLogs struct {
+	// Pods is a client of the source cluster the logs are read with
+	Pods corev1client.PodsGetter
+}
+
+func (l ClientLogs) ApplyTo(opts *TransferOptions) error {
+	if l.Pods == nil {
+		return fmt.Errorf("client logs require a pods client")
+	}
+	opts.clientLogs = &l
+	return nil
+}
+
 type DeleteDestination bool
 
 func (d DeleteDestination) ApplyTo(opts *TransferOptions) error {
@@ -231,6 +618,37 @@ func (d DeleteDestination) ApplyTo(opts *TransferOptions) error {
 	return nil
 }
 
+// TransferMode selects how rsync transfers changed files
+type TransferMode string
+
+const (
+	// TransferModeAuto lets rsync decide, it copies whole files when both ends are local
+	// and uses the delta algorithm otherwise, which is always the case for a transfer
+	TransferModeAuto TransferMode = ""
+	// TransferModeWholeFile copies changed files entirely, skipping the delta computation.
+	// This is faster when the network is faster than the disks, typically over a LAN.
+	TransferModeWholeFile TransferMode = "WholeFile"
+	// TransferModeDelta only sends the changed parts of files. It costs cpu and disk reads
+	// on both ends but saves bandwidth, which pays off over a slow WAN link.
+	TransferModeDelta TransferMode = "Delta"
+)
+
+func (t TransferMode) ApplyTo(opts *TransferOptions) error {
+	switch t {
+	case TransferModeAuto:
+		opts.WholeFile = nil
+	case TransferModeWholeFile:
+		wholeFile := true
+		opts.WholeFile = &wholeFile
+	case TransferModeDelta:
+		wholeFile := false
+		opts.WholeFile = &wholeFile
+	default:
+		return fmt.Errorf("invalid rsync transfer mode %s", t)
+	}
+	return nil
+}
+
 type WithSourcePodLabels map[string]string
 
 func (w WithSourcePodLabels) ApplyTo(opts *TransferOptions) error {
@@ -281,11 +699,48 @@ type DestinationPodSpecMutation struct {
 }
 
 func (s *DestinationPodSpecMutation) ApplyTo(opts *TransferOptions) error {
-	opts.DestinationPodMutations = append(opts.SourcePodMutations,
+	opts.DestinationPodMutations = append(opts.DestinationPodMutations,
 		meta.NewPodSpecMutation(s.Spec, meta.MutationTypeReplace))
 	return nil
 }
 
+// SourceNodeName pins the rsync client pods to the named node. It sets
+// spec.nodeName directly which bypasses the scheduler entirely, the pods
+// will fail to start if the node cannot run them. This is useful when the
+// source PV is local to a node and must be read from there.
+type SourceNodeName string
+
+func (s SourceNodeName) ApplyTo(opts *TransferOptions) error {
+	if err := validateNodeName(string(s)); err != nil {
+		return err
+	}
+	opts.SourcePodMutations = append(opts.SourcePodMutations,
+		meta.NewPodSpecMutation(&v1.PodSpec{NodeName: string(s)}, meta.MutationTypeReplace))
+	return nil
+}
+
+// DestinationNodeName pins the rsync server pod to the named node. It sets
+// spec.nodeName directly which bypasses the scheduler entirely, the pod
+// will fail to start if the node cannot run it. This is useful when the
+// destination PV is local to a node and must be served from there.
+type DestinationNodeName string
+
+func (d DestinationNodeName) ApplyTo(opts *TransferOptions) error {
+	if err := validateNodeName(string(d)); err != nil {
+		return err
+	}
+	opts.DestinationPodMutations = append(opts.DestinationPodMutations,
+		meta.NewPodSpecMutation(&v1.PodSpec{NodeName: string(d)}, meta.MutationTypeReplace))
+	return nil
+}
+
+func validateNodeName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid node name %s: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 type SourceContainerMutation struct {
 	C *v1.Container
 }
@@ -301,11 +756,12 @@ type DestinationContainerMutation struct {
 }
 
 func (s DestinationContainerMutation) ApplyTo(opts *TransferOptions) error {
-	opts.DestContainerMutations = append(opts.SourceContainerMutations,
+	opts.DestContainerMutations = append(opts.DestContainerMutations,
 		meta.NewContainerMutation(s.C, meta.MutationTypeReplace))
 	return nil
 }
 
+// Username is the user the client authenticates to the rsync daemon as, crane2 when not set
 type Username string
 
 func (u Username) ApplyTo(opts *TransferOptions) error {
@@ -313,6 +769,9 @@ func (u Username) ApplyTo(opts *TransferOptions) error {
 	return nil
 }
 
+// Password is the password the client authenticates to the rsync daemon with. A random password
+// is generated when not set, transfer instances created again for the same PVCs read it back from
+// the rsync server Secret in the destination cluster when the server or the clients are created.
 type Password string
 
 func (p Password) ApplyTo(opts *TransferOptions) error {
@@ -345,9 +804,618 @@ func (e ExcludeFiles) ApplyTo(opts *TransferOptions) error {
 	return nil
 }
 
+// ExcludePathsLostFound excludes the lost+found directory found at the root of most filesystems
+var ExcludePathsLostFound = ExcludePaths{"/lost+found"}
+
+// ExcludePaths excludes paths matching the given rsync patterns from the transfer. Patterns are
+// rendered as --exclude flags in the order they are provided, a leading / anchors a pattern to
+// the root of the volume and a trailing / only matches directories.
+type ExcludePaths []string
+
+func (e ExcludePaths) ApplyTo(opts *TransferOptions) error {
+	errs := []error{}
+	for _, p := range e {
+		if err := validateExcludePattern(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		opts.ExcludeFiles = append(opts.ExcludeFiles, p)
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// validateExcludePattern validates that an rsync exclude pattern is well formed and
+// safe to pass to the rsync command in the client's shell script
+func validateExcludePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("exclude pattern cannot be empty")
+	}
+	if strings.ContainsAny(pattern, " \t\n\"'`$;&|<>()\\") {
+		return fmt.Errorf("exclude pattern %q contains whitespace, quotes or shell meta characters", pattern)
+	}
+	if strings.Count(pattern, "[") != strings.Count(pattern, "]") {
+		return fmt.Errorf("exclude pattern %q has unbalanced brackets", pattern)
+	}
+	return nil
+}
+
 type MungeSymlinks bool
 
 func (e MungeSymlinks) ApplyTo(opts *TransferOptions) error {
 	opts.mungeSymlinks = bool(e)
 	return nil
 }
+
+// SymlinkMode sets how symbolic links are transferred
+type SymlinkMode string
+
+const (
+	// SymlinkModePreserve copies symbolic links as links without following them, whether they
+	// resolve or not, it is what ArchiveFiles does
+	SymlinkModePreserve SymlinkMode = "Preserve"
+	// SymlinkModeSafe copies symbolic links as links, except absolute links and links pointing
+	// outside of the volume, which are skipped. Dangling links within the volume are copied.
+	SymlinkModeSafe SymlinkMode = "Safe"
+	// SymlinkModeFollow replaces symbolic links with the files they point to, dangling links fail
+	// the transfer of the file
+	SymlinkModeFollow SymlinkMode = "Follow"
+	// SymlinkModeSkip does not transfer symbolic links
+	SymlinkModeSkip SymlinkMode = "Skip"
+)
+
+func (s SymlinkMode) ApplyTo(opts *TransferOptions) error {
+	switch s {
+	case SymlinkModePreserve, SymlinkModeSafe, SymlinkModeFollow, SymlinkModeSkip:
+		opts.symlinkMode = s
+		return nil
+	}
+	return fmt.Errorf("unsupported symlink mode %q, must be one of %s, %s, %s or %s",
+		string(s), SymlinkModePreserve, SymlinkModeSafe, SymlinkModeFollow, SymlinkModeSkip)
+}
+
+// applySymlinkMode sets the rsync options of the symlink mode, symbolic links are handled like
+// ArchiveFiles configured them when no mode is set. Munging symlinks requires transferring links.
+func (t *TransferOptions) applySymlinkMode() error {
+	switch t.symlinkMode {
+	case "":
+		return nil
+	case SymlinkModePreserve:
+		t.SymLinks, t.SafeLinks, t.CopyLinks = true, false, false
+	case SymlinkModeSafe:
+		t.SymLinks, t.SafeLinks, t.CopyLinks = true, true, false
+	case SymlinkModeFollow:
+		t.SymLinks, t.SafeLinks, t.CopyLinks = false, false, true
+	case SymlinkModeSkip:
+		t.SymLinks, t.SafeLinks, t.CopyLinks = false, false, false
+	}
+	if t.mungeSymlinks && (t.CopyLinks || !t.SymLinks) {
+		return fmt.Errorf("symlinks can only be munged when they are transferred as links")
+	}
+	return nil
+}
+
+// Parallelism sets the number of rsync processes run concurrently for each PVC. When greater
+// than one, every top-level entry of the source volume is copied by a separate rsync process.
+// Note that with DeleteDestination, extraneous top-level entries on the destination are not deleted.
+type Parallelism int
+
+func (p Parallelism) ApplyTo(opts *TransferOptions) error {
+	if p < 1 || p > maxParallelism {
+		return fmt.Errorf("rsync parallelism must be between 1 and %d", maxParallelism)
+	}
+	opts.parallelism = int(p)
+	return nil
+}
+
+// AutoParallelism picks the number of parallel rsync streams based on the estimated size of the
+// largest source PVC, one additional stream is used for every 100Gi of data up to a maximum of 8.
+// The estimate is based on volume capacity, the chosen value is reported in the transfer Status.
+// The number of files is not taken into account unless given with AutoParallelismEstimate, the
+// library cannot count them without mounting the volume.
+type AutoParallelism bool
+
+func (a AutoParallelism) ApplyTo(opts *TransferOptions) error {
+	opts.autoParallelism = bool(a)
+	return nil
+}
+
+// AutoParallelismEstimate enables AutoParallelism with the amount of data and the number of files
+// of the largest source PVC as known by the caller, from a previous transfer or a scan of the
+// volume for instance. One additional stream is used for every 100Gi of data or for every million
+// files, whichever gives more streams, up to a maximum of 8.
+type AutoParallelismEstimate struct {
+	// Bytes is the amount of data in bytes, the capacity of the largest volume is used when 0
+	Bytes int64
+	// Files is the number of files, only the amount of data is taken into account when 0
+	Files int64
+}
+
+func (a AutoParallelismEstimate) ApplyTo(opts *TransferOptions) error {
+	if a.Bytes < 0 || a.Files < 0 {
+		return fmt.Errorf("auto parallelism estimate must not be negative, got %d bytes and %d files", a.Bytes, a.Files)
+	}
+	opts.autoParallelism = true
+	opts.autoParallelismEstimate = &a
+	return nil
+}
+
+// autoParallelism returns the number of parallel rsync streams for the given list of PVCs, sized
+// with the given estimate when not nil
+func autoParallelism(pvcList transfer.PVCPairList, estimate *AutoParallelismEstimate) int {
+	largest, files := int64(0), int64(0)
+	if estimate != nil {
+		largest, files = estimate.Bytes, estimate.Files
+	}
+	if largest == 0 {
+		for _, pvc := range pvcList {
+			if size := transfer.EstimatePVCSize(pvc.Source()); size > largest {
+				largest = size
+			}
+		}
+	}
+	parallelism := 1 + int(largest/autoParallelismStreamSize)
+	if byFiles := 1 + int(files/autoParallelismStreamFiles); byFiles > parallelism {
+		parallelism = byFiles
+	}
+	if parallelism > maxParallelism {
+		return maxParallelism
+	}
+	return parallelism
+}
+
+// CorrelationID stamps the given ID as a label on every resource created by the transfer and
+// adds it to every log line, so that all resources and logs of a migration can be correlated. It
+// is also the id label of the metrics of the transfer, see metrics.TransferID. The
+// resources of the endpoint and of the transport are created before the transfer, CreateServer and
+// CreateClient add the label to them.
+type CorrelationID string
+
+func (c CorrelationID) ApplyTo(opts *TransferOptions) error {
+	if _, err := metadata.WithCorrelationID(nil, string(c)); err != nil {
+		return err
+	}
+	opts.correlationID = string(c)
+	return nil
+}
+
+// GuaranteedQoS sets resource requests equal to limits on every transfer container, using the
+// resource values configured through container mutations. A resource with only a request or only
+// a limit uses that value for both. Transfer pods get the Guaranteed QoS class when cpu and memory
+// are configured for every container, protecting them from eviction under node pressure.
+type GuaranteedQoS bool
+
+func (g GuaranteedQoS) ApplyTo(opts *TransferOptions) error {
+	opts.guaranteedQoS = bool(g)
+	return nil
+}
+
+// WithScheme supplies a pre-configured scheme to the transfer, such as the scheme of the controller
+// using it, instead of building a new one, see transfer.Scheme. The API types of the endpoint are
+// added to a copy of it, the supplied scheme is left unchanged.
+type WithScheme struct {
+	Scheme *runtime.Scheme
+}
+
+func (w WithScheme) ApplyTo(opts *TransferOptions) error {
+	if w.Scheme == nil {
+		return fmt.Errorf("scheme cannot be nil")
+	}
+	opts.scheme = w.Scheme
+	return nil
+}
+
+// FixDestinationPermissions adds an init container to the rsync server pod which recursively
+// changes the ownership of the destination volumes to UID and GID and makes them writable for
+// both before the transfer starts. GID defaults to the fsGroup of the server pod, the owner is
+// left unchanged when UID is not set. The init container runs as root.
+type FixDestinationPermissions struct {
+	UID *int64
+	GID *int64
+}
+
+func (f FixDestinationPermissions) ApplyTo(opts *TransferOptions) error {
+	if f.UID != nil && *f.UID < 0 {
+		return fmt.Errorf("uid must not be negative")
+	}
+	if f.GID != nil && *f.GID < 0 {
+		return fmt.Errorf("gid must not be negative")
+	}
+	opts.fixPermissions = &f
+	return nil
+}
+
+// ItemizedLog writes an itemized record of every change rsync makes to a log file on a volume
+// mounted in the rsync client pods, so that a log shipper or a post transfer job can collect a
+// durable manifest of the migration. The log replaces the log file of StandardProgress, progress
+// is still reported on stdout.
+type ItemizedLog struct {
+	// Format is the rsync log file format, defaults to DefaultItemizedLogFormat
+	Format string
+	// ClaimName is a PVC in the source namespace to write the logs to, each client writes to a
+	// sub directory named after its PVC. An emptyDir volume is used when not set.
+	ClaimName string
+}
+
+func (i ItemizedLog) ApplyTo(opts *TransferOptions) error {
+	if i.Format == "" {
+		i.Format = DefaultItemizedLogFormat
+	}
+	if strings.ContainsAny(i.Format, "'\n") {
+		return fmt.Errorf("itemized log format must not contain quotes or new lines")
+	}
+	if i.ClaimName != "" {
+		if errs := validation.IsDNS1123Subdomain(i.ClaimName); len(errs) > 0 {
+			return fmt.Errorf("invalid itemized log claim name %s: %s", i.ClaimName, strings.Join(errs, ", "))
+		}
+	}
+	opts.itemizedLog = &i
+	return nil
+}
+
+// CompletionMarker writes a marker file to the root of every destination volume once its data was
+// transferred without errors, giving the destination workload or a downstream job a completion
+// signal that does not require access to the cluster API. The marker is written last by the rsync
+// client, it is not written when the transfer fails, nor when it only succeeds because vanished
+// files are tolerated. By default the marker lists the completion time, the PVC, the size in bytes
+// and the number of files of the transferred data, and a checksum of the file list.
+type CompletionMarker struct {
+	// Name is the file name of the marker, defaults to DefaultCompletionMarkerName
+	Name string
+	// Content replaces the default content of the marker when set
+	Content string
+}
+
+var completionMarkerName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func (c CompletionMarker) ApplyTo(opts *TransferOptions) error {
+	if c.Name == "" {
+		c.Name = DefaultCompletionMarkerName
+	}
+	if !completionMarkerName.MatchString(c.Name) || c.Name == "." || c.Name == ".." {
+		return fmt.Errorf("invalid completion marker name %q, must be a file name of letters, digits, '.', '_' or '-'", c.Name)
+	}
+	opts.completionMarker = &c
+	return nil
+}
+
+// LazyRsync runs the server without an rsync container, the transport server spawns an rsync daemon
+// for every connection it accepts instead of forwarding it to a long running daemon. Only the
+// transport runs while no client is connected, which reduces the resources used by servers waiting
+// a long time for a client. The tradeoff is the latency of starting rsync on every connection. The
+// transport must not be direct, must be created with LazyServerExec as its
+// transport.Options.ServerExec and cannot be combined with SeparateTransportServer. Its server image
+// must ship rsync, see LazyServerExec.
+type LazyRsync bool
+
+func (l LazyRsync) ApplyTo(opts *TransferOptions) error {
+	opts.lazyRsync = bool(l)
+	return nil
+}
+
+// BatchClients transfers all PVCs of the source namespace from a single rsync client pod instead of
+// one pod per PVC. The PVCs are copied one after the other through a single transport client to the
+// server, which already serves every PVC of the namespace as a module of a single rsync daemon.
+// A failed PVC does not stop the others but fails the pod, a retry copies all PVCs again. This
+// cannot be combined with ItemizedLog.
+type BatchClients bool
+
+func (b BatchClients) ApplyTo(opts *TransferOptions) error {
+	opts.batchClients = bool(b)
+	return nil
+}
+
+// MountPropagation sets the mount propagation of the PVC volume mounts in the transfer pods. Volumes
+// which are themselves mount points, like some CSI volumes, need HostToContainer for their submounts
+// to be visible. Bidirectional requires the transfer containers to be privileged. The default mount
+// propagation of the cluster is used when not set.
+type MountPropagation v1.MountPropagationMode
+
+func (m MountPropagation) ApplyTo(opts *TransferOptions) error {
+	mode := v1.MountPropagationMode(m)
+	switch mode {
+	case v1.MountPropagationNone, v1.MountPropagationHostToContainer, v1.MountPropagationBidirectional:
+	default:
+		return fmt.Errorf("unsupported mount propagation %s", mode)
+	}
+	opts.mountPropagation = &mode
+	return nil
+}
+
+// DestinationPolicy controls what the transfer does when a destination PVC already contains data,
+// for instance a partial copy of a previous run
+type DestinationPolicy string
+
+const (
+	// DestinationPolicyOverwrite updates existing files on the destination, this is the default
+	DestinationPolicyOverwrite DestinationPolicy = "Overwrite"
+	// DestinationPolicySkipExisting leaves files which already exist on the destination untouched
+	DestinationPolicySkipExisting DestinationPolicy = "SkipExisting"
+	// DestinationPolicyFail makes VerifyDestinationPolicy fail when a destination is not empty
+	DestinationPolicyFail DestinationPolicy = "Fail"
+)
+
+func (d DestinationPolicy) ApplyTo(opts *TransferOptions) error {
+	switch d {
+	case DestinationPolicyOverwrite, DestinationPolicyFail:
+		opts.IgnoreExisting = false
+	case DestinationPolicySkipExisting:
+		opts.IgnoreExisting = true
+	default:
+		return fmt.Errorf("unsupported destination policy %s", d)
+	}
+	opts.destinationPolicy = d
+	return nil
+}
+
+// SeparateTransportServer runs the transport server and the rsync daemon in two Deployments
+// instead of a single pod, so that they can be scaled and restarted independently. The endpoint
+// routes to the transport pods, which reach the rsync daemon through an additional ClusterIP
+// Service named rsync-server. The transport must be created with ServerConnectHost as its
+// ServerConnectHost option and must not be direct. The rsync daemon then accepts connections
+// from other pods: a NetworkPolicy named rsync-server only admits the transport pods, and access
+// is still authenticated. The server is healthy when both a transport pod and the rsync pod are
+// ready, ServerHealth reports each of them separately.
+type SeparateTransportServer bool
+
+func (s SeparateTransportServer) ApplyTo(opts *TransferOptions) error {
+	opts.separateTransportServer = bool(s)
+	return nil
+}
+
+// EphemeralStorage requests and limits ephemeral storage on the rsync containers of the transfer
+// pods, which rsync uses for temporary files and logs. Values are quantities like 1Gi, either can be
+// left empty. Ephemeral storage is merged into the resources set by container mutations, nodes evict
+// pods exceeding their ephemeral storage limit.
+type EphemeralStorage struct {
+	Request string
+	Limit   string
+}
+
+func (e EphemeralStorage) ApplyTo(opts *TransferOptions) error {
+	resources := &v1.ResourceRequirements{}
+	if e.Request != "" {
+		q, err := resource.ParseQuantity(e.Request)
+		if err != nil {
+			return fmt.Errorf("invalid ephemeral storage request %q: %w", e.Request, err)
+		}
+		resources.Requests = v1.ResourceList{v1.ResourceEphemeralStorage: q}
+	}
+	if e.Limit != "" {
+		q, err := resource.ParseQuantity(e.Limit)
+		if err != nil {
+			return fmt.Errorf("invalid ephemeral storage limit %q: %w", e.Limit, err)
+		}
+		resources.Limits = v1.ResourceList{v1.ResourceEphemeralStorage: q}
+	}
+	if e.Request != "" && e.Limit != "" && resources.Limits.StorageEphemeral().Cmp(*resources.Requests.StorageEphemeral()) < 0 {
+		return fmt.Errorf("ephemeral storage limit %s is lower than the request %s", e.Limit, e.Request)
+	}
+	opts.ephemeralStorage = resources
+	return nil
+}
+
+// PreTransferHook runs a command, such as sync or an application specific flush, in the running
+// pods mounting the source PVCs when PreTransfer is called, so that databases can flush to disk
+// before they are copied. The executor needs the create verb on pods/exec and the list verb on
+// pods in the source namespace.
+type PreTransferHook transfer.PreTransferHook
+
+func (p PreTransferHook) ApplyTo(opts *TransferOptions) error {
+	if len(p.Command) == 0 {
+		return fmt.Errorf("pre-transfer hook requires a command")
+	}
+	if p.Executor == nil {
+		return fmt.Errorf("pre-transfer hook requires an executor")
+	}
+	hook := transfer.PreTransferHook(p)
+	opts.preTransferHook = &hook
+	return nil
+}
+
+// BandwidthLimit limits the rate at which every rsync client sends data with --bwlimit, and
+// optionally with the egress bandwidth annotation of the client pods. It takes precedence over
+// BwLimit of the command options. transfer.GlobalBandwidthLimit applies when it is not set.
+type BandwidthLimit transfer.BandwidthLimit
+
+func (b BandwidthLimit) ApplyTo(opts *TransferOptions) error {
+	limit := transfer.BandwidthLimit(b)
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	opts.bandwidthLimit = &limit
+	return nil
+}
+
+// SnapshotSource makes the rsync clients copy a clone of every source PVC provisioned from a CSI
+// VolumeSnapshot taken when CreateClient is called, see transfer.SnapshotSource. The clones are
+// deleted with the client, the snapshots with transfer.DeleteSnapshotSources.
+type SnapshotSource transfer.SnapshotSource
+
+func (s SnapshotSource) ApplyTo(opts *TransferOptions) error {
+	if s.VolumeSnapshotClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(s.VolumeSnapshotClassName); len(errs) > 0 {
+			return fmt.Errorf("invalid volume snapshot class name %s: %s", s.VolumeSnapshotClassName, strings.Join(errs, ", "))
+		}
+	}
+	if s.StorageClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(s.StorageClassName); len(errs) > 0 {
+			return fmt.Errorf("invalid storage class name %s: %s", s.StorageClassName, strings.Join(errs, ", "))
+		}
+	}
+	snapshot := transfer.SnapshotSource(s)
+	opts.snapshotSource = &snapshot
+	return nil
+}
+
+// DestinationPVCs makes CreateServer create the destination PVCs which do not exist from their
+// source PVC, see transfer.DestinationPVCOptions. They are not deleted with the server.
+type DestinationPVCs transfer.DestinationPVCOptions
+
+func (d DestinationPVCs) ApplyTo(opts *TransferOptions) error {
+	options := transfer.DestinationPVCOptions(d)
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	for _, name := range options.StorageClassMapping {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid storage class name %s: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	opts.destinationPVCs = &options
+	return nil
+}
+
+// OwnershipMapping changes the owner and the group of the transferred files with --usermap and
+// --groupmap, so that they can be used by the pods of the destination namespace, see
+// transfer.OwnershipMapping and transfer.DiscoverOwnershipMapping. It enables Owners and Groups as
+// needed.
+type OwnershipMapping transfer.OwnershipMapping
+
+func (o OwnershipMapping) ApplyTo(opts *TransferOptions) error {
+	mapping := transfer.OwnershipMapping(o)
+	if err := mapping.Validate(); err != nil {
+		return err
+	}
+	opts.ownershipMapping = &mapping
+	return nil
+}
+
+// RetryPolicy retries the rsync client of a PVC with transfer.RunClientWithRetries when it failed
+// with a transient error, the client pod is recreated and resumes from the partially transferred
+// files. It enables ResumePartial unless PartialDir is set. Failed clients are not retried
+// without a RetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the client of a PVC is run, including the first
+	// attempt
+	MaxAttempts int
+	// Backoff is the time waited before the first retry of the client of a PVC, it doubles for
+	// every following retry of that client. Defaults to DefaultRetryBackoff.
+	Backoff time.Duration
+	// MaxBackoff caps the time waited before a retry, defaults to transfer.DefaultMaxRetryBackoff
+	MaxBackoff time.Duration
+	// RetriableExitCodes are the exit codes of the rsync client which are retried, defaults to
+	// DefaultRetriableExitCodes. Client pods failing without an exit code, for instance when they
+	// are evicted, are always retried.
+	RetriableExitCodes []int
+}
+
+func (r RetryPolicy) ApplyTo(opts *TransferOptions) error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts of the retry policy must be at least 1")
+	}
+	if r.Backoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	for _, code := range r.RetriableExitCodes {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("invalid retriable exit code %d, must be between 1 and 255", code)
+		}
+	}
+	opts.retryPolicy = transfer.RetryPolicy{
+		MaxAttempts: r.MaxAttempts,
+		Backoff:     r.Backoff,
+		MaxBackoff:  r.MaxBackoff,
+	}
+	if opts.retryPolicy.Backoff == 0 {
+		opts.retryPolicy.Backoff = DefaultRetryBackoff
+	}
+	opts.retriableExitCodes = DefaultRetriableExitCodes
+	if len(r.RetriableExitCodes) > 0 {
+		opts.retriableExitCodes = r.RetriableExitCodes
+	}
+	return nil
+}
+
+// MaxRetries is the number of times transfer.RunClientWithRetries re-runs the rsync client of a
+// PVC after it failed with a transient error, resuming from the partially transferred files. It
+// sets the MaxAttempts of the RetryPolicy to one more than the retries, the exit codes retried
+// are DefaultRetriableExitCodes unless a RetryPolicy sets them. Defaults to 0, failed clients are
+// not retried.
+type MaxRetries int
+
+func (m MaxRetries) ApplyTo(opts *TransferOptions) error {
+	if m < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	opts.retryPolicy.MaxAttempts = int(m) + 1
+	if opts.retryPolicy.Backoff == 0 {
+		opts.retryPolicy.Backoff = DefaultRetryBackoff
+	}
+	if len(opts.retriableExitCodes) == 0 {
+		opts.retriableExitCodes = DefaultRetriableExitCodes
+	}
+	return nil
+}
+
+// RetryBackoff is the time waited before the first retry of the client of a PVC, it doubles for
+// every following retry. It sets the Backoff of the RetryPolicy and only applies with MaxRetries
+// or a RetryPolicy. Defaults to DefaultRetryBackoff.
+type RetryBackoff time.Duration
+
+func (r RetryBackoff) ApplyTo(opts *TransferOptions) error {
+	if r < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	opts.retryPolicy.Backoff = time.Duration(r)
+	if opts.retryPolicy.Backoff == 0 {
+		opts.retryPolicy.Backoff = DefaultRetryBackoff
+	}
+	return nil
+}
+
+// TempDir makes the rsync server write temporary files to a dedicated volume instead of the
+// destination volumes, an emptyDir volume on the node unless ClaimName is set. Files are then
+// copied rather than renamed into place. With an emptyDir volume, use VerifyTempDirSpace before
+// creating the server to check that a node has enough ephemeral storage.
+type TempDir struct {
+	// ClaimName is a PVC in the destination namespace to write the temporary files to
+	ClaimName string
+}
+
+func (t TempDir) ApplyTo(opts *TransferOptions) error {
+	if t.ClaimName != "" {
+		if errs := validation.IsDNS1123Subdomain(t.ClaimName); len(errs) > 0 {
+			return fmt.Errorf("invalid temp dir claim name %s: %s", t.ClaimName, strings.Join(errs, ", "))
+		}
+	}
+	opts.tempDir = &t
+	opts.TempDir = tempDir
+	return nil
+}
+
+// SchedulerName sets the scheduler of the transfer pods, such as a gang or batch scheduler used for
+// quota accounting. The default scheduler of the cluster is used when not set.
+type SchedulerName string
+
+func (s SchedulerName) ApplyTo(opts *TransferOptions) error {
+	if errs := validation.IsDNS1123Subdomain(string(s)); len(errs) > 0 {
+		return fmt.Errorf("invalid scheduler name %s: %s", string(s), strings.Join(errs, ", "))
+	}
+	opts.schedulerName = string(s)
+	return nil
+}
+
+// TolerateVanishedFiles treats source files deleted while they were being transferred as a success
+// rather than a failure of the client, which is expected when the source volume is in use by a
+// running workload. The number of vanished files is reported in the status of the transfer.
+type TolerateVanishedFiles bool
+
+func (t TolerateVanishedFiles) ApplyTo(opts *TransferOptions) error {
+	opts.tolerateVanishedFiles = bool(t)
+	return nil
+}
+
+// ImagePullPolicy sets the pull policy of the images of the rsync containers, the default policy of
+// the cluster is used when not set. The policy of the transport containers is set in the options of
+// the transport, and their resources and security contexts with container mutations.
+type ImagePullPolicy v1.PullPolicy
+
+func (i ImagePullPolicy) ApplyTo(opts *TransferOptions) error {
+	switch v1.PullPolicy(i) {
+	case v1.PullAlways, v1.PullIfNotPresent, v1.PullNever:
+	default:
+		return fmt.Errorf("unsupported image pull policy %q, must be one of %s, %s or %s", string(i), v1.PullAlways, v1.PullIfNotPresent, v1.PullNever)
+	}
+	opts.imagePullPolicy = v1.PullPolicy(i)
+	return nil
+}
@@ -1,8 +1,25 @@
 package rsync
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/route"
+	metadata "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func Test_filterRsyncExtraOptions(t *testing.T) {
@@ -112,3 +129,850 @@ func Test_filterRsyncInfoOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeName(t *testing.T) {
+	if err := SourceNodeName("Invalid_Node").ApplyTo(&TransferOptions{}); err == nil {
+		t.Fatalf("invalid node name should return an error")
+	}
+
+	tr, srcClient, destClient := createTransfer(t, SourceNodeName("source-node"), DestinationNodeName("dest-node"))
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	if server.Spec.NodeName != "dest-node" {
+		t.Fatalf("server pod nodeName is %q, expected dest-node", server.Spec.NodeName)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil {
+		t.Fatalf("unable to list client pods: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Spec.NodeName != "source-node" {
+		t.Fatalf("client pod nodeName not set to source-node")
+	}
+}
+
+func TestAutoParallelism(t *testing.T) {
+	pvcWithCapacity := func(capacity string) transfer.PVCPair {
+		pvc := createPVC(testPVCName, testNamespace)
+		pvc.Status.Capacity = corev1.ResourceList{
+			corev1.ResourceStorage: resource.MustParse(capacity),
+		}
+		return transfer.NewPVCPair(pvc, nil)
+	}
+	tests := []struct {
+		name     string
+		pvcList  transfer.PVCPairList
+		estimate *AutoParallelismEstimate
+		want     int
+	}{
+		{
+			name:    "small volumes use a single stream",
+			pvcList: transfer.PVCPairList{pvcWithCapacity("10Gi")},
+			want:    1,
+		},
+		{
+			name:     "estimated bytes replace the capacity",
+			pvcList:  transfer.PVCPairList{pvcWithCapacity("1Ti")},
+			estimate: &AutoParallelismEstimate{Bytes: 250 * 1024 * 1024 * 1024},
+			want:     3,
+		},
+		{
+			name:     "many small files use more streams than their size",
+			pvcList:  transfer.PVCPairList{pvcWithCapacity("10Gi")},
+			estimate: &AutoParallelismEstimate{Files: 5000000},
+			want:     6,
+		},
+		{
+			name:     "the amount of data is used when it gives more streams than the files",
+			pvcList:  transfer.PVCPairList{pvcWithCapacity("350Gi")},
+			estimate: &AutoParallelismEstimate{Files: 1000},
+			want:     4,
+		},
+		{
+			name:    "largest volume determines the number of streams",
+			pvcList: transfer.PVCPairList{pvcWithCapacity("10Gi"), pvcWithCapacity("350Gi")},
+			want:    4,
+		},
+		{
+			name:    "number of streams is capped",
+			pvcList: transfer.PVCPairList{pvcWithCapacity("5Ti")},
+			want:    maxParallelism,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoParallelism(tt.pvcList, tt.estimate); got != tt.want {
+				t.Errorf("autoParallelism() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	opts := TransferOptions{}
+	if err := opts.Apply(AutoParallelismEstimate{Files: 2000000}); err != nil {
+		t.Fatalf("valid estimate should not return an error: %v", err)
+	}
+	if !opts.autoParallelism || opts.autoParallelismEstimate == nil || opts.autoParallelismEstimate.Files != 2000000 {
+		t.Errorf("estimate should enable auto parallelism with the estimate, got %v %v", opts.autoParallelism, opts.autoParallelismEstimate)
+	}
+	if err := (AutoParallelismEstimate{Bytes: -1}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("negative estimate should return an error")
+	}
+	if err := Parallelism(maxParallelism + 1).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("parallelism above the maximum should return an error")
+	}
+	tr, _, _ := createTransfer(t, Parallelism(3))
+	if cmd := tr.getRsyncCommand(tr.PVCs()[0], []string{}); !strings.Contains(cmd, "xargs -0 -P 3") {
+		t.Errorf("rsync command does not run 3 parallel streams: %s", cmd)
+	}
+}
+
+func TestExcludePaths(t *testing.T) {
+	opts := TransferOptions{}
+	err := opts.Apply(ExcludePathsLostFound, ExcludePaths{"/cache/", "*.lock", "logs/**"})
+	if err != nil {
+		t.Fatalf("valid exclude paths should not return an error: %v", err)
+	}
+	rsyncOptions, err := opts.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	want := []string{
+		"--exclude=/lost+found", "--exclude=/cache/", "--exclude=*.lock", "--exclude=logs/**",
+	}
+	if !reflect.DeepEqual(rsyncOptions, want) {
+		t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, want)
+	}
+
+	for _, invalid := range []string{"", " ", "my cache", "$(reboot)", "a;b", "[abc"} {
+		if err := (ExcludePaths{invalid}).ApplyTo(&TransferOptions{}); err == nil {
+			t.Errorf("exclude pattern %q should be invalid", invalid)
+		}
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	if err := CorrelationID("not a label value").ApplyTo(&TransferOptions{}); err == nil {
+		t.Fatalf("invalid correlation id should return an error")
+	}
+
+	srcClient := buildTestClient()
+	destClient := buildTestClient()
+	e, err := endpoint.Create(context.TODO(), route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, metadata.Labels, "test.domain", nil), destClient)
+	if err != nil {
+		t.Fatalf("unable to create route endpoint: %v", err)
+	}
+	tp := stunnel.NewTransport(metadata.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{})
+	if _, err := transport.CreateServer(context.TODO(), tp, destClient, "fs", e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	if _, err := transport.CreateClient(context.TODO(), tp, srcClient, "fs", e); err != nil {
+		t.Fatalf("unable to create transport client: %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	tr, err := NewTransfer(tp, e, srcClient, destClient, pvcList, klogr.New(), CorrelationID("migration-1"), WithSourcePodLabels{"app": "crane2"})
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	objects := []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}, &corev1.Pod{}, &routev1.Route{}}
+	names := []string{defaultRsyncServerConfig, defaultRsyncServerSecret, rsyncServerPodName, testRouteName}
+	for i, obj := range objects {
+		if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: names[i]}, obj); err != nil {
+			t.Fatalf("unable to get %s: %v", names[i], err)
+		}
+		if obj.GetLabels()[metadata.CorrelationIDLabel] != "migration-1" {
+			t.Errorf("%s is missing the correlation id label", names[i])
+		}
+	}
+	// the transport resources are labelled although the transport was created before the transfer
+	for side, c := range map[string]client.Client{"server": destClient, "client": srcClient} {
+		configMaps := &corev1.ConfigMapList{}
+		secrets := &corev1.SecretList{}
+		for _, list := range []client.ObjectList{configMaps, secrets} {
+			if err := c.List(context.TODO(), list, client.InNamespace(testNamespace), client.MatchingLabels{metadata.OwnerLabel: metadata.OwnerLabelValue}); err != nil {
+				t.Fatalf("unable to list %s resources: %v", side, err)
+			}
+		}
+		objs := []client.Object{}
+		for i := range configMaps.Items {
+			objs = append(objs, &configMaps.Items[i])
+		}
+		for i := range secrets.Items {
+			objs = append(objs, &secrets.Items[i])
+		}
+		if len(objs) < 3 {
+			t.Fatalf("expected the %s resources of the transport and the transfer, got %d", side, len(objs))
+		}
+		for _, obj := range objs {
+			if obj.GetLabels()[metadata.CorrelationIDLabel] != "migration-1" {
+				t.Errorf("%s %s is missing the correlation id label", side, obj.GetName())
+			}
+		}
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.MatchingLabels{metadata.CorrelationIDLabel: "migration-1", "app": "crane2"}); err != nil {
+		t.Fatalf("unable to list client pods: %v", err)
+	}
+	if len(pods.Items) != 1 {
+		t.Errorf("client pod is missing the correlation id label")
+	}
+}
+
+func TestGuaranteedQoS(t *testing.T) {
+	resources := &corev1.Container{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("100m"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		},
+	}
+	tr, _, destClient := createTransfer(t, GuaranteedQoS(true), DestinationContainerMutation{C: resources})
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	for _, c := range server.Spec.Containers {
+		if len(c.Resources.Requests) != 2 {
+			t.Fatalf("container %s expected requests for cpu and memory, got %v", c.Name, c.Resources.Requests)
+		}
+		for name, limit := range c.Resources.Limits {
+			request := c.Resources.Requests[name]
+			if request.Cmp(limit) != 0 {
+				t.Errorf("container %s %s request %s does not match limit %s", c.Name, name, request.String(), limit.String())
+			}
+		}
+	}
+}
+
+func TestEphemeralStorage(t *testing.T) {
+	if err := (EphemeralStorage{Request: "2Gi", Limit: "1Gi"}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("a limit lower than the request should return an error")
+	}
+	if err := (EphemeralStorage{Request: "lots"}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("an invalid quantity should return an error")
+	}
+
+	mutation := &corev1.Container{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("100m"),
+			},
+		},
+	}
+	tr, srcClient, destClient := createTransfer(t, EphemeralStorage{Request: "1Gi", Limit: "4Gi"},
+		DestinationContainerMutation{C: mutation}, SourceContainerMutation{C: mutation})
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	clients := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), clients, client.InNamespace(testNamespace)); err != nil || len(clients.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	for _, c := range []corev1.Container{server.Spec.Containers[0], clients.Items[0].Spec.Containers[0]} {
+		if c.Resources.Requests.StorageEphemeral().String() != "1Gi" || c.Resources.Limits.StorageEphemeral().String() != "4Gi" {
+			t.Errorf("container %s does not have the ephemeral storage resources: %v", c.Name, c.Resources)
+		}
+		if c.Resources.Requests.Cpu().String() != "100m" {
+			t.Errorf("container %s lost the resources of the container mutation: %v", c.Name, c.Resources)
+		}
+	}
+	if _, ok := mutation.Resources.Requests[corev1.ResourceEphemeralStorage]; ok {
+		t.Errorf("the container mutation should not be modified")
+	}
+}
+
+func TestSchedulerName(t *testing.T) {
+	if err := SchedulerName("Not A Scheduler").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("invalid scheduler name should return an error")
+	}
+	tr, srcClient, destClient := createTransfer(t, SchedulerName("volcano"))
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	clients := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), clients, client.InNamespace(testNamespace)); err != nil || len(clients.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	for _, pod := range []corev1.Pod{*server, clients.Items[0]} {
+		if pod.Spec.SchedulerName != "volcano" {
+			t.Errorf("pod %s scheduler name = %q, want volcano", pod.Name, pod.Spec.SchedulerName)
+		}
+	}
+}
+
+func TestWithOwnerReferences(t *testing.T) {
+	if err := (WithOwnerReferences{{Kind: "Migration"}}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("incomplete owner reference should return an error")
+	}
+	ref := metav1.OwnerReference{APIVersion: "migration.konveyor.io/v1alpha1", Kind: "Migration", Name: "migration", UID: types.UID("migration-uid")}
+	tr, srcClient, destClient := createTransfer(t, WithOwnerReferences{ref})
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	for _, c := range []client.Client{srcClient, destClient} {
+		pods := &corev1.PodList{}
+		if err := c.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) == 0 {
+			t.Fatalf("unable to list pods: %v", err)
+		}
+		secrets := &corev1.SecretList{}
+		if err := c.List(context.TODO(), secrets, client.InNamespace(testNamespace), client.MatchingLabels{metadata.OwnerLabel: metadata.OwnerLabelValue}); err != nil {
+			t.Fatalf("unable to list secrets: %v", err)
+		}
+		objects := []metav1.Object{}
+		for i := range pods.Items {
+			objects = append(objects, &pods.Items[i])
+		}
+		for i := range secrets.Items {
+			// the transport secrets are created by the transport, not by the transfer
+			if strings.Contains(secrets.Items[i].Name, "crane2-rsync") {
+				objects = append(objects, &secrets.Items[i])
+			}
+		}
+		for _, obj := range objects {
+			if refs := obj.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != ref.UID {
+				t.Errorf("%s owner references = %v, want %v", obj.GetName(), refs, ref)
+			}
+		}
+	}
+}
+
+func TestImagePullPolicy(t *testing.T) {
+	if err := ImagePullPolicy("Sometimes").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("invalid image pull policy should return an error")
+	}
+	tr, srcClient, destClient := createTransfer(t, ImagePullPolicy(corev1.PullNever))
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	clients := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), clients, client.InNamespace(testNamespace)); err != nil || len(clients.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	for _, pod := range []corev1.Pod{*server, clients.Items[0]} {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == RsyncContainer && c.ImagePullPolicy != corev1.PullNever {
+				t.Errorf("pod %s rsync container pull policy = %q, want %s", pod.Name, c.ImagePullPolicy, corev1.PullNever)
+			}
+		}
+	}
+}
+
+func TestTransferMode(t *testing.T) {
+	tests := []struct {
+		mode TransferMode
+		want []string
+	}{
+		{mode: TransferModeAuto, want: []string{}},
+		{mode: TransferModeWholeFile, want: []string{"--whole-file"}},
+		{mode: TransferModeDelta, want: []string{"--no-whole-file"}},
+	}
+	for _, tt := range tests {
+		opts := TransferOptions{}
+		if err := opts.Apply(tt.mode); err != nil {
+			t.Fatalf("transfer mode %q should be valid: %v", tt.mode, err)
+		}
+		rsyncOptions, err := opts.AsRsyncCommandOptions()
+		if err != nil {
+			t.Fatalf("unable to render rsync options: %v", err)
+		}
+		if !reflect.DeepEqual(rsyncOptions, tt.want) {
+			t.Errorf("transfer mode %q rendered %v, want %v", tt.mode, rsyncOptions, tt.want)
+		}
+	}
+	if err := TransferMode("Fastest").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("invalid transfer mode should return an error")
+	}
+}
+
+func TestPreserveHardLinks(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(ArchiveFiles(true), PreserveHardLinks(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	rsyncOptions, err := opts.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	found := false
+	for _, o := range rsyncOptions {
+		found = found || o == "--hard-links"
+	}
+	if !found {
+		t.Errorf("expected --hard-links in rsync options %v", rsyncOptions)
+	}
+}
+
+func TestPreserveFileMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []TransferOption
+		extras  []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "xattrs and acls",
+			options: []TransferOption{PreserveXattrs(true), PreserveACLs(true)},
+			want:    []string{"--perms", "--xattrs", "--acls"},
+		},
+		{
+			name:    "sparse",
+			options: []TransferOption{Sparse(true)},
+			want:    []string{"--sparse"},
+		},
+		{
+			name:    "sparse inplace",
+			options: []TransferOption{Sparse(true)},
+			extras:  []string{"--inplace"},
+			wantErr: true,
+		},
+		{
+			name:    "numeric ids",
+			options: []TransferOption{PreserveOwnership(true), NumericIDs(true)},
+			want:    []string{"--owner", "--group", "--numeric-ids"},
+		},
+		{
+			name:    "numeric ids without ownership",
+			options: []TransferOption{NumericIDs(true)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TransferOptions{}
+			if err := opts.Apply(tt.options...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			opts.Extras = tt.extras
+			rsyncOptions, err := opts.AsRsyncCommandOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AsRsyncCommandOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(rsyncOptions, tt.want) {
+				t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, tt.want)
+			}
+		})
+	}
+
+	tr, _, destClient := createTransfer(t, ArchiveFiles(true), NumericIDs(true))
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncServerConfig}, cm); err != nil {
+		t.Fatalf("unable to get server config: %v", err)
+	}
+	if !strings.Contains(cm.Data["rsyncd.conf"], "    read only = false\n    numeric ids = yes\n") {
+		t.Errorf("rsyncd.conf modules should use numeric ids\n%s", cm.Data["rsyncd.conf"])
+	}
+}
+
+func TestModifyWindow(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(ModifyWindow(2 * time.Second)); err != nil {
+		t.Fatalf("valid modify window should not return an error: %v", err)
+	}
+	rsyncOptions, err := opts.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	if want := []string{"--modify-window=2"}; !reflect.DeepEqual(rsyncOptions, want) {
+		t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, want)
+	}
+	if err := opts.Apply(ModifyWindow(0)); err != nil {
+		t.Fatalf("a zero modify window should not return an error: %v", err)
+	}
+	if rsyncOptions, _ := opts.AsRsyncCommandOptions(); len(rsyncOptions) != 0 {
+		t.Errorf("a zero modify window should not render any option, got %v", rsyncOptions)
+	}
+	for _, invalid := range []time.Duration{-time.Second, 500 * time.Millisecond} {
+		err := ModifyWindow(invalid).ApplyTo(&TransferOptions{})
+		if err == nil {
+			t.Errorf("modify window %s should be invalid", invalid)
+		} else if !strings.Contains(err.Error(), "non-negative") {
+			t.Errorf("modify window %s error should say it must be non-negative, got %v", invalid, err)
+		}
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(BlockSize("64Ki")); err != nil {
+		t.Fatalf("valid block size should not return an error: %v", err)
+	}
+	rsyncOptions, err := opts.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	if want := []string{"--block-size=65536"}; !reflect.DeepEqual(rsyncOptions, want) {
+		t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, want)
+	}
+	for _, invalid := range []string{"", "100", "256Ki", "-1Ki", "large"} {
+		if err := BlockSize(invalid).ApplyTo(&TransferOptions{}); err == nil {
+			t.Errorf("block size %q should be invalid", invalid)
+		}
+	}
+	opts = TransferOptions{CommandOptions: CommandOptions{BlockSize: 100}}
+	if _, err := opts.AsRsyncCommandOptions(); err == nil {
+		t.Errorf("out of range block size should not render")
+	}
+}
+
+func TestSymlinkMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []TransferOption
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "archive preserves links",
+			options: []TransferOption{ArchiveFiles(true)},
+			want:    []string{"--links"},
+		},
+		{
+			// links are not followed, so that dangling links do not fail the transfer
+			name:    "safe links whatever the order of options",
+			options: []TransferOption{SymlinkModeSafe, ArchiveFiles(true)},
+			want:    []string{"--links", "--safe-links"},
+		},
+		{
+			name:    "follow links",
+			options: []TransferOption{ArchiveFiles(true), SymlinkModeFollow},
+			want:    []string{"--copy-links"},
+		},
+		{
+			name:    "skip links",
+			options: []TransferOption{ArchiveFiles(true), SymlinkModeSkip},
+		},
+		{
+			name:    "munged links must be transferred as links",
+			options: []TransferOption{ArchiveFiles(true), MungeSymlinks(true), SymlinkModeFollow},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TransferOptions{}
+			if err := opts.Apply(tt.options...); err != nil {
+				t.Fatalf("unable to apply options: %v", err)
+			}
+			err := opts.applySymlinkMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applySymlinkMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			rsyncOptions, err := opts.AsRsyncCommandOptions()
+			if err != nil {
+				t.Fatalf("unable to render rsync options: %v", err)
+			}
+			links := []string{}
+			for _, o := range rsyncOptions {
+				if strings.HasSuffix(o, "-links") || o == "--links" {
+					links = append(links, o)
+				}
+			}
+			if len(links) != len(tt.want) || (len(links) > 0 && !reflect.DeepEqual(links, tt.want)) {
+				t.Errorf("symlink options %v, want %v", links, tt.want)
+			}
+		})
+	}
+	if err := SymlinkMode("Dereference").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("unknown symlink mode should be invalid")
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(MaxFileSize("1Gi")); err != nil {
+		t.Fatalf("valid max file size should not return an error: %v", err)
+	}
+	rsyncOptions, err := opts.AsRsyncCommandOptions()
+	if err != nil {
+		t.Fatalf("unable to render rsync options: %v", err)
+	}
+	if want := []string{"--max-size=1073741824"}; !reflect.DeepEqual(rsyncOptions, want) {
+		t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, want)
+	}
+	for _, invalid := range []string{"", "huge", "0", "-1Gi"} {
+		if err := MaxFileSize(invalid).ApplyTo(&TransferOptions{}); err == nil {
+			t.Errorf("max file size %q should be invalid", invalid)
+		}
+	}
+
+	logs := `2021/06/01 10:00:00 [42] cd+++++++++ data/
+2021/06/01 10:00:01 [42] data/core.1234 is over max-size
+images/disk.img is over max-size
+2021/06/01 10:00:02 [42] >f+++++++++ data/file.txt`
+	skipped, err := SkippedFiles(strings.NewReader(logs))
+	if err != nil {
+		t.Fatalf("SkippedFiles() unexpected error %v", err)
+	}
+	if want := []string{"data/core.1234", "images/disk.img"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("SkippedFiles() = %v, want %v", skipped, want)
+	}
+}
+
+func TestPartialDir(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []TransferOption
+		want []string
+	}{
+		{
+			name: "resume partial files in place",
+			opts: []TransferOption{ResumePartial(true)},
+			want: []string{"--partial"},
+		},
+		{
+			name: "default partial dir",
+			opts: []TransferOption{ResumePartial(true), PartialDir("")},
+			want: []string{"--partial-dir=.rsync-partial"},
+		},
+		{
+			name: "custom partial dir",
+			opts: []TransferOption{PartialDir(".incoming")},
+			want: []string{"--partial-dir=.incoming"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TransferOptions{}
+			if err := opts.Apply(tt.opts...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			rsyncOptions, err := opts.AsRsyncCommandOptions()
+			if err != nil {
+				t.Fatalf("unable to render rsync options: %v", err)
+			}
+			if !reflect.DeepEqual(rsyncOptions, tt.want) {
+				t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, tt.want)
+			}
+		})
+	}
+	if err := PartialDir("my partial").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("partial dir with whitespace should be invalid")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(RetryPolicy{MaxAttempts: 4}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if opts.retryPolicy.MaxAttempts != 4 || opts.retryPolicy.Backoff != DefaultRetryBackoff || !reflect.DeepEqual(opts.retriableExitCodes, DefaultRetriableExitCodes) {
+		t.Errorf("expected 4 attempts of the default exit codes with the default backoff, got %+v of %v", opts.retryPolicy, opts.retriableExitCodes)
+	}
+	for _, policy := range []RetryPolicy{
+		{},
+		{MaxAttempts: 2, Backoff: -time.Second},
+		{MaxAttempts: 2, MaxBackoff: -time.Second},
+		{MaxAttempts: 2, RetriableExitCodes: []int{256}},
+	} {
+		if err := policy.ApplyTo(&TransferOptions{}); err == nil {
+			t.Errorf("retry policy %+v should be invalid", policy)
+		}
+	}
+}
+
+func TestMaxRetries(t *testing.T) {
+	opts := TransferOptions{}
+	if err := opts.Apply(RetryBackoff(time.Minute), MaxRetries(3)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if opts.retryPolicy.MaxAttempts != 4 || opts.retryPolicy.Backoff != time.Minute || !reflect.DeepEqual(opts.retriableExitCodes, DefaultRetriableExitCodes) {
+		t.Errorf("expected 4 attempts of the default exit codes with a minute of backoff, got %+v of %v", opts.retryPolicy, opts.retriableExitCodes)
+	}
+	opts = TransferOptions{}
+	if err := opts.Apply(MaxRetries(1)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if opts.retryPolicy.MaxAttempts != 2 || opts.retryPolicy.Backoff != DefaultRetryBackoff {
+		t.Errorf("expected 2 attempts with the default backoff, got %+v", opts.retryPolicy)
+	}
+	if err := MaxRetries(-1).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("negative max retries should be invalid")
+	}
+	if err := RetryBackoff(-time.Second).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("negative retry backoff should be invalid")
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	if err := (BandwidthLimit{}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("bandwidth limit without a rate should return an error")
+	}
+	bwLimit := 100
+	tests := []struct {
+		name           string
+		opts           []TransferOption
+		global         *transfer.BandwidthLimit
+		wantBwLimit    string
+		wantAnnotation string
+	}{
+		{
+			name:           "per transfer",
+			opts:           []TransferOption{BandwidthLimit{Rate: resource.MustParse("10Mi"), EgressAnnotation: true}},
+			wantBwLimit:    "--bwlimit=10240",
+			wantAnnotation: "83886080",
+		},
+		{
+			name:        "global",
+			global:      &transfer.BandwidthLimit{Rate: resource.MustParse("1Mi")},
+			wantBwLimit: "--bwlimit=1024",
+		},
+		{
+			name:        "command option over global",
+			opts:        []TransferOption{&bwLimitOption{bwLimit: &bwLimit}},
+			global:      &transfer.BandwidthLimit{Rate: resource.MustParse("1Mi"), EgressAnnotation: true},
+			wantBwLimit: "--bwlimit=100",
+		},
+		{
+			name:        "per transfer over global",
+			opts:        []TransferOption{BandwidthLimit{Rate: resource.MustParse("1500")}},
+			global:      &transfer.BandwidthLimit{Rate: resource.MustParse("1Mi")},
+			wantBwLimit: "--bwlimit=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer.GlobalBandwidthLimit = tt.global
+			defer func() { transfer.GlobalBandwidthLimit = nil }()
+			tr, srcClient, _ := createTransfer(t, tt.opts...)
+			if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+			clients := &corev1.PodList{}
+			if err := srcClient.List(context.TODO(), clients, client.InNamespace(testNamespace)); err != nil || len(clients.Items) != 1 {
+				t.Fatalf("unable to find rsync client pod: %v", err)
+			}
+			pod := clients.Items[0]
+			if cmd := pod.Spec.Containers[0].Command[2]; !strings.Contains(cmd, tt.wantBwLimit+" ") || strings.Count(cmd, "--bwlimit") != 1 {
+				t.Errorf("rsync command does not contain %s once: %s", tt.wantBwLimit, cmd)
+			}
+			if got := pod.Annotations[transfer.EgressBandwidthAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("egress bandwidth annotation = %q, want %q", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+type bwLimitOption struct {
+	bwLimit *int
+}
+
+func (b *bwLimitOption) ApplyTo(opts *TransferOptions) error {
+	opts.BwLimit = b.bwLimit
+	return nil
+}
+
+func TestOwnershipMapping(t *testing.T) {
+	uid, gid := int64(1000700000), int64(1000700000)
+	tests := []struct {
+		name    string
+		mapping OwnershipMapping
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "every file",
+			mapping: OwnershipMapping{UID: &uid, GID: &gid},
+			want:    []string{"--owner", "--group", "'--usermap=*:1000700000'", "'--groupmap=*:1000700000'"},
+		},
+		{
+			name: "source ranges",
+			mapping: OwnershipMapping{
+				UID:        &uid,
+				SourceUIDs: &transfer.IDRange{Start: 1000650000, Size: 10000},
+			},
+			want: []string{"--owner", "'--usermap=1000650000-1000659999:1000700000'"},
+		},
+		{
+			name:    "no ids",
+			mapping: OwnershipMapping{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TransferOptions{}
+			err := opts.Apply(tt.mapping)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tr, _, _ := createTransfer(t, tt.mapping)
+			rsyncOptions, err := tr.options.AsRsyncCommandOptions()
+			if err != nil {
+				t.Fatalf("AsRsyncCommandOptions() unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(rsyncOptions, tt.want) {
+				t.Errorf("AsRsyncCommandOptions() = %v, want %v", rsyncOptions, tt.want)
+			}
+		})
+	}
+
+	opts := CommandOptions{Owners: true, UserMap: "*:0; rm -rf /"}
+	if _, err := opts.AsRsyncCommandOptions(); err == nil {
+		t.Errorf("AsRsyncCommandOptions() should reject an invalid usermap")
+	}
+}
@@ -0,0 +1,47 @@
+package rsync
+
+import (
+	"context"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// VerifyDestinationWritable checks that every destination PVC of the transfer can be written to
+// using the rsync server image. It must be called before CreateServer, an error wrapping
+// transfer.ErrDestinationNotWritable is returned when a destination is not writable.
+func (r *RsyncTransfer) VerifyDestinationWritable(ctx context.Context, c client.Client) error {
+	return transfer.VerifyDestinationWritable(ctx, c, r.pvcList, r.getRsyncServerImage())
+}
+
+// VerifyDestinationPolicy enforces DestinationPolicyFail by checking that every destination PVC
+// of the transfer is empty, it is a no-op for other policies. It must be called before CreateServer,
+// an error wrapping transfer.ErrDestinationNotEmpty is returned when a destination contains data.
+func (r *RsyncTransfer) VerifyDestinationPolicy(ctx context.Context, c client.Client) error {
+	if r.options.destinationPolicy != DestinationPolicyFail {
+		return nil
+	}
+	return transfer.VerifyDestinationEmpty(ctx, c, r.pvcList, r.getRsyncServerImage())
+}
+
+// PreTransfer runs the hook configured with PreTransferHook in the source workload, it must be
+// called before CreateClient. An error wrapping transfer.ErrPreTransferFailed is returned when the
+// command or its verification fails in a pod.
+func (r *RsyncTransfer) PreTransfer(ctx context.Context, c client.Client) error {
+	return transfer.RunPreTransferHook(ctx, c, r.options.preTransferHook, r.pvcList)
+}
+
+// VerifyTempDirSpace checks that a node the rsync server can be scheduled on, based on the node
+// name and node selector of the destination pod mutations, has enough ephemeral storage for the
+// temporary files. As an upper bound, the estimated size of all source PVCs is required. It is a
+// no-op unless TempDir uses an emptyDir volume. An error wrapping
+// transfer.ErrInsufficientEphemeralStorage is returned when no node fits.
+func (r *RsyncTransfer) VerifyTempDirSpace(ctx context.Context, c client.Client) error {
+	if r.options.tempDir == nil || r.options.tempDir.ClaimName != "" {
+		return nil
+	}
+	podSpec := corev1.PodSpec{}
+	applyPodMutations(&podSpec, r.options.DestinationPodMutations)
+	return transfer.VerifyNodeEphemeralStorage(ctx, c, podSpec.NodeName, podSpec.NodeSelector, r.pvcList.EstimateSize())
+}
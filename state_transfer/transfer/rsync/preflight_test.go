@@ -0,0 +1,302 @@
+package rsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestVerifyDestinationWritable(t *testing.T) {
+	tests := []struct {
+		name       string
+		probePhase corev1.PodPhase
+		wantErr    error
+	}{
+		{
+			name:       "writable destination",
+			probePhase: corev1.PodSucceeded,
+		},
+		{
+			name:       "read-only destination",
+			probePhase: corev1.PodFailed,
+			wantErr:    transfer.ErrDestinationNotWritable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _, destClient := createTransfer(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			go completeProbePods(ctx, destClient, tt.probePhase)
+
+			err := tr.VerifyDestinationWritable(ctx, destClient)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyDestinationWritable() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyDestinationWritable() error = %v, want %v", err, tt.wantErr)
+			}
+
+			pods := &corev1.PodList{}
+			if err := destClient.List(context.Background(), pods, client.InNamespace(testNamespace)); err != nil {
+				t.Fatalf("unable to list pods: %v", err)
+			}
+			if len(pods.Items) != 0 {
+				t.Errorf("expected probe pods to be cleaned up, found %d", len(pods.Items))
+			}
+		})
+	}
+}
+
+// completeProbePods simulates the probe pods running to completion with the given phase
+func completeProbePods(ctx context.Context, c client.Client, phase corev1.PodPhase) {
+	for ctx.Err() == nil {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(testNamespace)); err == nil && len(pods.Items) > 0 {
+			for i := range pods.Items {
+				pods.Items[i].Status.Phase = phase
+				_ = c.Update(ctx, &pods.Items[i])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDestinationPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         DestinationPolicy
+		probePhase     corev1.PodPhase
+		wantErr        error
+		ignoreExisting bool
+	}{
+		{
+			name:   "overwrite does not probe the destination",
+			policy: DestinationPolicyOverwrite,
+		},
+		{
+			name:           "skip existing ignores existing files",
+			policy:         DestinationPolicySkipExisting,
+			ignoreExisting: true,
+		},
+		{
+			name:       "fail with an empty destination",
+			policy:     DestinationPolicyFail,
+			probePhase: corev1.PodSucceeded,
+		},
+		{
+			name:       "fail with a non-empty destination",
+			policy:     DestinationPolicyFail,
+			probePhase: corev1.PodFailed,
+			wantErr:    transfer.ErrDestinationNotEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _, destClient := createTransfer(t, ArchiveFiles(true), tt.policy)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if tt.probePhase != "" {
+				go completeProbePods(ctx, destClient, tt.probePhase)
+			}
+
+			err := tr.VerifyDestinationPolicy(ctx, destClient)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyDestinationPolicy() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyDestinationPolicy() error = %v, want %v", err, tt.wantErr)
+			}
+
+			opts, err := tr.options.AsRsyncCommandOptions()
+			if err != nil {
+				t.Fatalf("AsRsyncCommandOptions() unexpected error %v", err)
+			}
+			if got := strings.Contains(strings.Join(opts, " "), "--ignore-existing"); got != tt.ignoreExisting {
+				t.Errorf("--ignore-existing set = %v, want %v: %v", got, tt.ignoreExisting, opts)
+			}
+		})
+	}
+	if err := DestinationPolicy("Merge").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("unsupported destination policy should return an error")
+	}
+}
+
+type fakeExecutor struct {
+	calls    []string
+	failWith string
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, pod types.NamespacedName, container string, command []string) (string, string, error) {
+	cmd := strings.Join(command, " ")
+	f.calls = append(f.calls, fmt.Sprintf("%s/%s: %s", pod.Name, container, cmd))
+	if cmd == f.failWith {
+		return "", "flush failed", fmt.Errorf("command terminated with exit code 1")
+	}
+	return "", "", nil
+}
+
+func TestPreTransfer(t *testing.T) {
+	workload := func(name string, phase corev1.PodPhase, claim string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "sidecar"},
+					{Name: "db", VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/db"}}},
+				},
+				Volumes: []corev1.Volume{{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name      string
+		failWith  string
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:      "flush and verify the running workload",
+			wantCalls: []string{"db-0/db: sync", "db-0/db: test -f /var/lib/db/flushed"},
+		},
+		{
+			name:      "failed verification",
+			failWith:  "test -f /var/lib/db/flushed",
+			wantCalls: []string{"db-0/db: sync", "db-0/db: test -f /var/lib/db/flushed"},
+			wantErr:   transfer.ErrPreTransferFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &fakeExecutor{failWith: tt.failWith}
+			tr, srcClient, _ := createTransfer(t, PreTransferHook{
+				Executor:      executor,
+				Command:       []string{"sync"},
+				VerifyCommand: []string{"test", "-f", "/var/lib/db/flushed"},
+			})
+			for _, pod := range []*corev1.Pod{
+				workload("db-0", corev1.PodRunning, testPVCName),
+				workload("db-1", corev1.PodPending, testPVCName),
+				workload("other", corev1.PodRunning, "other-pvc"),
+			} {
+				if err := srcClient.Create(context.TODO(), pod); err != nil {
+					t.Fatalf("unable to create pod: %v", err)
+				}
+			}
+
+			err := transfer.PreTransfer(context.TODO(), tr)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("PreTransfer() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PreTransfer() error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(executor.calls, "\n") != strings.Join(tt.wantCalls, "\n") {
+				t.Errorf("PreTransfer() ran %v, want %v", executor.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	node := func(name, allocatable string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse(allocatable)},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		tempDir TempDir
+		objects []client.Object
+		wantErr error
+	}{
+		{
+			name:    "node with enough ephemeral storage",
+			objects: []client.Object{node("small", "5Gi"), node("large", "20Gi")},
+		},
+		{
+			name: "ephemeral storage requested by other pods",
+			objects: []client.Object{node("large", "20Gi"), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "builder", Namespace: "other"},
+				Spec: corev1.PodSpec{
+					NodeName: "large",
+					Containers: []corev1.Container{{
+						Name: "build",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("15Gi")},
+						},
+					}},
+				},
+			}},
+			wantErr: transfer.ErrInsufficientEphemeralStorage,
+		},
+		{
+			name:    "temp dir on a pvc is not checked",
+			tempDir: TempDir{ClaimName: "rsync-temp"},
+			objects: []client.Object{node("small", "5Gi")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _, destClient := createTransfer(t, tt.tempDir)
+			tr.pvcList[0].Source().Claim().Spec.Resources.Requests = corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("10Gi"),
+			}
+			for _, obj := range tt.objects {
+				if err := destClient.Create(context.TODO(), obj); err != nil {
+					t.Fatalf("unable to create object: %v", err)
+				}
+			}
+
+			err := tr.VerifyTempDirSpace(context.TODO(), destClient)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyTempDirSpace() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyTempDirSpace() error = %v, want %v", err, tt.wantErr)
+			}
+
+			opts, err := tr.options.AsRsyncCommandOptions()
+			if err != nil || !strings.Contains(strings.Join(opts, " "), "--temp-dir=/var/tmp/rsync") {
+				t.Errorf("rsync command does not use the temp dir: %v %v", opts, err)
+			}
+			if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+			server := &corev1.Pod{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+				t.Fatalf("unable to get server pod: %v", err)
+			}
+			for _, volume := range server.Spec.Volumes {
+				if volume.Name != tempDirVolumeName {
+					continue
+				}
+				if (volume.PersistentVolumeClaim != nil) != (tt.tempDir.ClaimName != "") {
+					t.Errorf("unexpected temp dir volume source %v", volume.VolumeSource)
+				}
+				return
+			}
+			t.Errorf("temp dir volume not found in the server pod")
+		})
+	}
+}
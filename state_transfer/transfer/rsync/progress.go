@@ -0,0 +1,198 @@
+package rsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/metrics"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// progressStreamPrefix tags the progress updates of an rsync process in the client logs, it is
+	// followed by the stream of the process and a space
+	progressStreamPrefix = "crane2-stream="
+	// progressFunctionName is the name of the shell function defined by progressFunction
+	progressFunctionName = "crane2_progress"
+	// progressFunction defines a shell function reading the output of an rsync process and
+	// prefixing its --info=progress2 updates, separated by carriage returns, with the stream given
+	// as argument and the pid of the function, so that the progress of parallel and batched rsync
+	// processes can be told apart in the logs. It is exported for the shells run by xargs.
+	progressFunction = progressFunctionName + `() { local re='^ *[0-9.,]+[KMGTP]? +[0-9]+%'; local update; ` +
+		`while IFS= read -r -d $'\r' update || [ -n "$update" ]; do ` +
+		`if [[ $update =~ $re ]]; then printf '` + progressStreamPrefix + `%s.%s %s\r' "$1" "$BASHPID" "$update"; ` +
+		`else printf '%s\r' "$update"; fi; done; }; export -f ` + progressFunctionName + `; `
+)
+
+// progress2Line matches the overall progress rsync reports with --info=progress2, with the default
+// or the --human-readable number format, for example:
+//
+//	1,238,099  42%  146.38kB/s    0:00:08 (xfr#5, to-chk=169/396)
+//	    1.24M 100%  146.38kB/s    0:00:08 (xfr#5, ir-chk=1002/1396)
+var progress2Line = regexp.MustCompile(`^\s*([\d.,]+)([KMGTP]?)\s+(\d+)%\s+\S+\s+\S+(?:\s+\(xfr#(\d+), (?:to|ir)-chk=\d+/(\d+)\))?`)
+
+// ParseProgress returns the progress found in the logs of an rsync client container, rsync must
+// report it with the PROGRESS2 info flag, which StandardProgress sets. The latest progress of
+// every rsync process tagged by the client is summed, so that parallel streams and the PVCs of
+// batched clients are all counted, untagged progress counts as a single process. TotalBytes is
+// derived from the percentage rsync reports and grows while rsync discovers files with
+// incremental recursion. A zero progress is returned when the logs hold none yet.
+func ParseProgress(logs io.Reader) (*transfer.Progress, error) {
+	streams := map[string]*transfer.Progress{}
+	order := []string{}
+	scanner := bufio.NewScanner(logs)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		stream, line := "", scanner.Text()
+		if strings.HasPrefix(line, progressStreamPrefix) {
+			stream, line, _ = strings.Cut(strings.TrimPrefix(line, progressStreamPrefix), " ")
+		}
+		latest := parseProgressLine(line)
+		if latest == nil {
+			continue
+		}
+		if _, ok := streams[stream]; !ok {
+			order = append(order, stream)
+		}
+		streams[stream] = latest
+	}
+	progress := &transfer.Progress{}
+	for _, stream := range order {
+		addProgress(progress, streams[stream])
+	}
+	return progress, scanner.Err()
+}
+
+func parseProgressLine(line string) *transfer.Progress {
+	match := progress2Line.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	transferred, err := parseHumanReadable(match[1], match[2])
+	if err != nil {
+		return nil
+	}
+	percent, err := strconv.Atoi(match[3])
+	if err != nil || percent > 100 {
+		return nil
+	}
+	progress := &transfer.Progress{BytesTransferred: transferred}
+	if percent > 0 {
+		progress.TotalBytes = transferred * 100 / int64(percent)
+	}
+	if match[4] != "" {
+		progress.FilesTransferred, _ = strconv.ParseInt(match[4], 10, 64)
+		progress.FilesTotal, _ = strconv.ParseInt(match[5], 10, 64)
+	}
+	return progress
+}
+
+// parseHumanReadable parses a number printed by rsync, with separators between each set of 3
+// digits by default or with a unit of powers of 1000 with --human-readable
+func parseHumanReadable(number string, unit string) (int64, error) {
+	if unit == "" {
+		return strconv.ParseInt(strings.NewReplacer(",", "", ".", "").Replace(number), 10, 64)
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range "KMGTP" {
+		value *= 1000
+		if string(u) == unit {
+			break
+		}
+	}
+	return int64(value), nil
+}
+
+// scanProgressLines splits logs on line feeds and on the carriage returns rsync separates the
+// updates of its progress with
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// addProgress adds the bytes and the files of another rsync process to the given progress
+func addProgress(progress *transfer.Progress, other *transfer.Progress) {
+	progress.BytesTransferred += other.BytesTransferred
+	progress.TotalBytes += other.TotalBytes
+	progress.FilesTransferred += other.FilesTransferred
+	progress.FilesTotal += other.FilesTotal
+}
+
+// Progress returns the progress of the transfer. With ClientLogs and StandardProgress, the bytes
+// and the files copied are parsed from the logs of the latest rsync client pod of every PVC, see
+// ParseProgress. Otherwise, and for the pods whose logs cannot be read, the estimated size of a
+// PVC is counted as transferred once its rsync client pod succeeded and Estimated is set.
+// LastUpdate is not set as copying a single file can legitimately take longer than the stall
+// timeout of ETA.
+func (r *RsyncTransfer) Progress(ctx context.Context, c client.Client) (*transfer.Progress, error) {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	latest, _ := latestClientPods(pods)
+	fromLogs := r.options.clientLogs != nil && r.reportsProgress()
+	progress := &transfer.Progress{Estimated: !fromLogs}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.StartTime != nil && (progress.StartTime.IsZero() || pod.Status.StartTime.Time.Before(progress.StartTime)) {
+			progress.StartTime = pod.Status.StartTime.Time
+		}
+	}
+	parsed := map[string]bool{}
+	for i := range latest {
+		pod := &latest[i]
+		if fromLogs && pod.Status.Phase != corev1.PodPending {
+			podProgress, err := r.podProgress(ctx, pod)
+			if err == nil {
+				addProgress(progress, podProgress)
+				parsed[pod.Name] = true
+				continue
+			}
+			// the progress of the other pods is still reported
+			r.Log.Error(err, "unable to read the progress of rsync client pod", "namespace", pod.Namespace, "pod", pod.Name)
+			progress.Estimated = true
+		}
+	}
+	// the PVCs completed by a batched client whose failed PVCs are retried are estimated too
+	for _, pvc := range r.pvcList {
+		pod := latestClientPod(pods, pvc)
+		if pod == nil || parsed[pod.Name] || pvcPhase(pod, pvc) != transfer.PVCTransferPhaseCompleted {
+			continue
+		}
+		progress.BytesTransferred += transfer.EstimatePVCSize(pvc.Source())
+		progress.Estimated = true
+	}
+	metrics.ObserveProgress(metricsTransferType, r.metricsID(), progress)
+	return progress, nil
+}
+
+// podProgress returns the progress parsed from the logs of the rsync container of the given pod.
+// The whole logs are read as the final progress of the completed streams must be counted.
+func (r *RsyncTransfer) podProgress(ctx context.Context, pod *corev1.Pod) (*transfer.Progress, error) {
+	logs, err := r.options.clientLogs.Pods.Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: RsyncContainer,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
+	return ParseProgress(logs)
+}
@@ -0,0 +1,148 @@
+package rsync
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	restclient "k8s.io/client-go/rest"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		logs string
+		want *transfer.Progress
+	}{
+		{
+			name: "no progress yet",
+			logs: "2021/07/01 10:00:00 [12] building file list\n",
+			want: &transfer.Progress{},
+		},
+		{
+			name: "default number format",
+			logs: "          32,768   0%    0.00kB/s    0:00:00\r       1,238,099  25%  146.38kB/s    0:00:08 (xfr#5, to-chk=169/396)\r",
+			want: &transfer.Progress{BytesTransferred: 1238099, TotalBytes: 4952396, FilesTransferred: 5, FilesTotal: 396},
+		},
+		{
+			name: "human readable",
+			logs: "2021/07/01 10:00:00 [12] >f+++++++++ data/file\n          1.50G  50%   25.00MB/s    0:01:00 (xfr#120, ir-chk=1002/1396)\r",
+			want: &transfer.Progress{BytesTransferred: 1500000000, TotalBytes: 3000000000, FilesTransferred: 120, FilesTotal: 1396},
+		},
+		{
+			name: "completed",
+			logs: "          1.24M 100%  146.38kB/s    0:00:08 (xfr#5, to-chk=0/396)\n\nsent 1.24M bytes  received 120 bytes\n",
+			want: &transfer.Progress{BytesTransferred: 1240000, TotalBytes: 1240000, FilesTransferred: 5, FilesTotal: 396},
+		},
+		{
+			name: "parallel streams",
+			logs: progressStreamPrefix + "data.12        100 100%  1.00kB/s    0:00:01 (xfr#1, to-chk=0/1)\r" +
+				progressStreamPrefix + "data.13         50  16%  1.00kB/s    0:00:01 (xfr#1, to-chk=9/10)\r" +
+				"2021/07/01 10:00:00 [12] >f+++++++++ data/file\n" +
+				progressStreamPrefix + "data.13         75  25%  1.00kB/s    0:00:01 (xfr#2, to-chk=8/10)\r",
+			want: &transfer.Progress{BytesTransferred: 175, TotalBytes: 400, FilesTransferred: 3, FilesTotal: 11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProgress(strings.NewReader(tt.logs))
+			if err != nil {
+				t.Fatalf("ParseProgress() unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressFunction(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	// two processes of the same stream, like batched pvcs, followed by the summary of rsync
+	script := progressFunction + `for bytes in 100 200; do printf '\r%15s 100%%  1.00kB/s    0:00:01 (xfr#1, to-chk=0/1)\nsent %s bytes\n' $bytes $bytes | ` +
+		progressFunctionName + ` data; done`
+	out, err := exec.Command(bash, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("progress function failed: %v", err)
+	}
+	progress, err := ParseProgress(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("ParseProgress() unexpected error %v", err)
+	}
+	if progress.BytesTransferred != 300 || progress.FilesTransferred != 2 {
+		t.Errorf("ParseProgress() = %+v, want the progress of both processes in %q", progress, out)
+	}
+	if !strings.Contains(string(out), "sent 200 bytes\n") {
+		t.Errorf("the other output of rsync should be kept: %q", out)
+	}
+}
+
+func TestProgressFromClientLogs(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, StandardProgress(true))
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	pod := &pods.Items[0]
+	if script := pod.Spec.Containers[0].Command[2]; !strings.HasPrefix(script, progressFunction) || !strings.Contains(script, "| "+progressFunctionName+" ") {
+		t.Errorf("the client should tag its progress:\n%s", script)
+	}
+	pod.Status = corev1.PodStatus{Phase: corev1.PodSucceeded}
+	if err := srcClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+
+	// the fake clientset serves logs without progress
+	clientset := fakeclientset.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+	})
+	tr.options.clientLogs = &ClientLogs{Pods: clientset.CoreV1()}
+	progress, err := tr.Progress(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("Progress() unexpected error %v", err)
+	}
+	if progress.Estimated || progress.BytesTransferred != 0 {
+		t.Errorf("Progress() = %+v, want the progress of the logs", progress)
+	}
+
+	tr.options.clientLogs = &ClientLogs{Pods: failingLogs{clientset.CoreV1()}}
+	progress, err = tr.Progress(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("Progress() should not fail when the logs of a pod cannot be read: %v", err)
+	}
+	if !progress.Estimated {
+		t.Errorf("Progress() = %+v, want the estimate of the pod whose logs cannot be read", progress)
+	}
+}
+
+// failingLogs serves pods whose logs cannot be streamed
+type failingLogs struct {
+	corev1client.PodsGetter
+}
+
+func (f failingLogs) Pods(namespace string) corev1client.PodInterface {
+	return failingPodLogs{f.PodsGetter.Pods(namespace)}
+}
+
+type failingPodLogs struct {
+	corev1client.PodInterface
+}
+
+func (f failingPodLogs) GetLogs(name string, opts *corev1.PodLogOptions) *restclient.Request {
+	return restclient.NewRequestWithClient(&url.URL{}, "", restclient.ClientContentConfig{}, nil)
+}
@@ -0,0 +1,43 @@
+package rsync
+
+import (
+	"context"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CanRerunSafely returns whether the transfer can be re-run over the data left by a previous run.
+// rsync writes every file to a temporary file which is renamed into place once complete, so a
+// re-run compares files again and completes the interrupted ones, unless the options keep files
+// which are not complete under their final name and skip existing files at the same time.
+func (r *RsyncTransfer) CanRerunSafely(ctx context.Context, c client.Client) (bool, string, error) {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return false, "", err
+	}
+	latest, _ := latestClientPods(pods)
+	if transfer.PhaseFromPods(latest) == transfer.TransferPhaseRunning {
+		return false, "rsync clients of the previous run are still running", nil
+	}
+	if r.options.destinationPolicy == DestinationPolicyFail {
+		return false, "the Fail destination policy rejects destinations holding data of a previous run", nil
+	}
+	for _, extra := range r.options.Extras {
+		if extra == "--append" {
+			return false, "--append assumes existing destination files are a prefix of the source files", nil
+		}
+	}
+	if r.options.IgnoreExisting && r.options.Partial && r.options.PartialDir == "" {
+		return false, "interrupted files are kept under their final name by --partial and skipped by --ignore-existing", nil
+	}
+	switch {
+	case r.options.IgnoreExisting:
+		return true, "files completed by the previous run are skipped, the others are copied again", nil
+	case r.options.PartialDir != "" || r.options.Partial:
+		return true, "completed files are compared again and interrupted files resume from their partial data", nil
+	case r.options.WholeFile != nil && *r.options.WholeFile:
+		return true, "completed files are compared again and interrupted files are copied again in full", nil
+	}
+	return true, "completed files are compared again and interrupted files are copied again", nil
+}
@@ -0,0 +1,82 @@
+package rsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCanRerunSafely(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []TransferOption
+		extras    []string
+		clientRun corev1.PodPhase
+		want      bool
+	}{
+		{
+			name:    "default options",
+			options: GetRsyncCommandDefaultOptions(),
+			want:    true,
+		},
+		{
+			name:    "partial files resume",
+			options: []TransferOption{ArchiveFiles(true), ResumePartial(true)},
+			want:    true,
+		},
+		{
+			name:      "previous run still running",
+			options:   GetRsyncCommandDefaultOptions(),
+			clientRun: corev1.PodRunning,
+		},
+		{
+			name:    "fail destination policy",
+			options: []TransferOption{DestinationPolicyFail},
+		},
+		{
+			name:    "partial files skipped as existing",
+			options: []TransferOption{ResumePartial(true), DestinationPolicySkipExisting},
+		},
+		{
+			name:    "partial dir with skip existing",
+			options: []TransferOption{PartialDir(DefaultPartialDir), DestinationPolicySkipExisting},
+			want:    true,
+		},
+		{
+			name:    "append",
+			options: GetRsyncCommandDefaultOptions(),
+			extras:  []string{"--append"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, srcClient, _ := createTransfer(t, tt.options...)
+			tr.options.Extras = tt.extras
+			if tt.clientRun != "" {
+				if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+					t.Fatalf("unable to create client: %v", err)
+				}
+				pods := &corev1.PodList{}
+				if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil {
+					t.Fatalf("unable to list client pods: %v", err)
+				}
+				for i := range pods.Items {
+					pods.Items[i].Status.Phase = tt.clientRun
+					if err := srcClient.Update(context.TODO(), &pods.Items[i]); err != nil {
+						t.Fatalf("unable to update client pod: %v", err)
+					}
+				}
+			}
+			safe, reason, err := transfer.CanRerunSafely(context.TODO(), tr, srcClient)
+			if err != nil {
+				t.Fatalf("CanRerunSafely() unexpected error %v", err)
+			}
+			if safe != tt.want || reason == "" {
+				t.Errorf("CanRerunSafely() = %v, %q, want %v", safe, reason, tt.want)
+			}
+		})
+	}
+}
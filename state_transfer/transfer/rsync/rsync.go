@@ -9,11 +9,17 @@ import (
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
+	// RsyncContainer is the name of the rsync container in transfer pods
 	RsyncContainer = "rsync"
+	// PermissionsInitContainer is the name of the init container added by FixDestinationPermissions
+	PermissionsInitContainer = "fix-permissions"
+	// PVCLabel is the label set on rsync client pods to identify the PVC they transfer
+	PVCLabel = "pvc"
 )
 
 const (
@@ -23,9 +29,14 @@ const (
 	defaultRsyncClientSecret = "crane2-rsync-client-secret"
 	defaultRsyncServerConfig = "crane2-rsync-server-config"
 	defaultRsyncServerSecret = "crane2-rsync-server-secret"
+	rsyncPasswordKey         = "password"
+	rsyncServerPodName       = "rsync-server"
+	metricsTransferType      = "rsync"
 )
 
 type RsyncTransfer struct {
+	// Log logs the resources created by the transfer, its health checks and its retries, it
+	// discards every message when no logger is given to NewTransfer
 	Log         logr.Logger
 	username    string
 	password    string
@@ -36,20 +47,78 @@ type RsyncTransfer struct {
 	endpoint    endpoint.Endpoint
 	port        int32
 	options     TransferOptions
+	syncMode    transfer.SyncMode
 }
 
 func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client,
 	pvcList transfer.PVCPairList, log logr.Logger, opts ...TransferOption) (transfer.Transfer, error) {
+	log = meta.LoggerOrDiscard(log).WithValues("transfer", metricsTransferType)
 	err := validatePVCList(pvcList)
 	if err != nil {
 		return nil, err
 	}
+	if err := transport.ValidateCompatibility(endpoint.ProtocolTCP, t, e); err != nil {
+		return nil, err
+	}
 	options := TransferOptions{}
 	err = options.Apply(opts...)
 	if err != nil {
 		return nil, err
 	}
+	// applied here so that the order of options does not matter
+	if err := options.applySymlinkMode(); err != nil {
+		return nil, err
+	}
+	if options.batchClients && options.itemizedLog != nil {
+		return nil, fmt.Errorf("batched clients cannot write itemized logs")
+	}
+	if options.itemizedLog != nil {
+		// set here so that the order of options does not matter
+		options.Itemize = true
+		options.LogFile = itemizedLogFile
+		options.LogFileFormat = options.itemizedLog.Format
+	}
+	if options.retryPolicy.MaxAttempts > 1 && options.PartialDir == "" {
+		// retries resume from partially transferred files
+		options.Partial = true
+	}
+	if limit := transfer.EffectiveBandwidthLimit(options.bandwidthLimit); limit != nil && (options.bandwidthLimit != nil || options.BwLimit == nil) {
+		// set here so that the order of options does not matter
+		bwLimit := limit.KiBPerSecond()
+		options.BwLimit = &bwLimit
+		options.bandwidthLimit = limit
+	}
+	if mapping := options.ownershipMapping; mapping != nil {
+		// set here so that the order of options does not matter
+		options.UserMap = mapping.UserMap()
+		options.GroupMap = mapping.GroupMap()
+		options.Owners = options.Owners || options.UserMap != ""
+		options.Groups = options.Groups || options.GroupMap != ""
+	}
+	if options.autoParallelism {
+		options.parallelism = autoParallelism(pvcList, options.autoParallelismEstimate)
+	}
+	if options.username == "" {
+		options.username = defaultRsyncUser
+	}
+	if options.password == "" {
+		// generated once so that the server and the client agree on it, replaced by the password of
+		// an existing server when the server or the clients are created
+		options.password, err = generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		options.generatedPassword = true
+	}
+	if options.correlationID != "" {
+		// labels are merged here so that the order of options does not matter
+		options.SourcePodMeta.Labels, _ = meta.WithCorrelationID(options.SourcePodMeta.Labels, options.correlationID)
+		options.DestinationPodMeta.Labels, _ = meta.WithCorrelationID(options.DestinationPodMeta.Labels, options.correlationID)
+		log = log.WithValues("correlationID", options.correlationID)
+	}
 	return &RsyncTransfer{
+		username:    options.username,
+		password:    options.password,
 		transport:   t,
 		endpoint:    e,
 		source:      src,
@@ -72,6 +141,15 @@ func (r *RsyncTransfer) Transport() transport.Transport {
 	return r.transport
 }
 
+// Copy returns a copy of the transfer with copies of its endpoint and transport, see
+// transfer.Copier
+func (r *RsyncTransfer) Copy() transfer.Transfer {
+	copied := *r
+	copied.endpoint = endpoint.Copy(r.endpoint)
+	copied.transport = transport.Copy(r.transport)
+	return &copied
+}
+
 func (r *RsyncTransfer) Source() client.Client {
 	return r.source
 }
@@ -80,6 +158,11 @@ func (r *RsyncTransfer) Destination() client.Client {
 	return r.destination
 }
 
+// Scheme returns the scheme supplied with WithScheme, if any
+func (r *RsyncTransfer) Scheme() *runtime.Scheme {
+	return r.options.scheme
+}
+
 func (r *RsyncTransfer) Username() string {
 	return r.username
 }
@@ -88,9 +171,14 @@ func (r *RsyncTransfer) Password() string {
 	return r.password
 }
 
-// transferOptions returns options used for the transfer
+// transferOptions returns options used for the transfer, a final sync deletes extraneous
+// destination files
 func (r *RsyncTransfer) transferOptions() TransferOptions {
-	return r.options
+	options := r.options
+	if r.syncMode == transfer.SyncModeFinal {
+		options.Delete = true
+	}
+	return options
 }
 
 // getMountPathForPVC given a PVC, returns a path where PVC can be mounted within a transfer Pod
@@ -98,6 +186,16 @@ func getMountPathForPVC(p transfer.PVC) string {
 	return fmt.Sprintf("/mnt/%s/%s", p.Claim().Namespace, p.LabelSafeName())
 }
 
+// clientPodLabels returns labels for the rsync client pod of the given PVC
+func clientPodLabels(labels map[string]string, pvc transfer.PVCPair) map[string]string {
+	podLabels := map[string]string{}
+	for k, v := range labels {
+		podLabels[k] = v
+	}
+	podLabels[PVCLabel] = pvc.Source().LabelSafeName()
+	return podLabels
+}
+
 func (r *RsyncTransfer) getRsyncServerImage() string {
 	if r.transferOptions().rsyncServerImage == "" {
 		return defaultRsyncImage
@@ -114,6 +212,18 @@ func (r *RsyncTransfer) getRsyncClientImage() string {
 	}
 }
 
+// metadataClient returns a client adding the annotations and the owner references of the given
+// metadata, set with WithOwnerReferences, to every resource the transfer creates
+func metadataClient(c client.Client, podMeta transfer.ResourceMetadata) client.Client {
+	if len(podMeta.Annotations) == 0 && len(podMeta.OwnerReferences) == 0 {
+		return c
+	}
+	return meta.NewMetadataClient(c, meta.Metadata{
+		Annotations:     podMeta.Annotations,
+		OwnerReferences: podMeta.OwnerReferences,
+	})
+}
+
 // applyPodMutations given a pod spec and a list of podSpecMutation, applies
 // each mutation to the given podSpec, only merge type mutations are allowed here
 // Following fields will be mutated:
@@ -125,11 +235,13 @@ func applyPodMutations(podSpec *v1.PodSpec, ms []meta.PodSpecMutation) {
 	for _, m := range ms {
 		switch m.Type() {
 		case meta.MutationTypeReplace:
-			podSpec.NodeSelector = m.NodeSelector()
+			if m.NodeSelector() != nil {
+				podSpec.NodeSelector = m.NodeSelector()
+			}
 			if m.PodSecurityContext() != nil {
 				podSpec.SecurityContext = m.PodSecurityContext()
 			}
-			if m.NodeName() != nil {
+			if m.NodeName() != nil && *m.NodeName() != "" {
 				podSpec.NodeName = *m.NodeName()
 			}
 		}
@@ -149,3 +261,52 @@ func applyContainerMutations(container *v1.Container, ms []meta.ContainerMutatio
 		}
 	}
 }
+
+// applyEphemeralStorage merges the ephemeral storage configured with EphemeralStorage into the
+// resources of the given container
+func applyEphemeralStorage(container *v1.Container, resources *v1.ResourceRequirements) {
+	if resources == nil {
+		return
+	}
+	// copied as the resource lists may be shared with container mutations
+	requests := container.Resources.Requests.DeepCopy()
+	if requests == nil {
+		requests = v1.ResourceList{}
+	}
+	limits := container.Resources.Limits.DeepCopy()
+	if limits == nil {
+		limits = v1.ResourceList{}
+	}
+	for name, q := range resources.Requests {
+		requests[name] = q.DeepCopy()
+	}
+	for name, q := range resources.Limits {
+		limits[name] = q.DeepCopy()
+	}
+	if len(requests) > 0 {
+		container.Resources.Requests = requests
+	}
+	if len(limits) > 0 {
+		container.Resources.Limits = limits
+	}
+}
+
+// applyGuaranteedQoS sets the requests and limits of the given container to the same values
+func applyGuaranteedQoS(container *v1.Container) {
+	limits := v1.ResourceList{}
+	for name, q := range container.Resources.Requests {
+		limits[name] = q.DeepCopy()
+	}
+	for name, q := range container.Resources.Limits {
+		limits[name] = q.DeepCopy()
+	}
+	if len(limits) == 0 {
+		return
+	}
+	requests := v1.ResourceList{}
+	for name, q := range limits {
+		requests[name] = q.DeepCopy()
+	}
+	container.Resources.Limits = limits
+	container.Resources.Requests = requests
+}
@@ -0,0 +1,345 @@
+package rsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/gateway"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/route"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/certmanager"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace = "test-namespace"
+	testPVCName   = "test-pvc"
+	testRouteName = "test-route"
+)
+
+// testTransport holds the certificate shared by the transports of the tests, generating a
+// certificate for every test is slow
+var testTransport = stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+	types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+), nil, &transport.Options{})
+
+func buildTestClient(objects ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	schemeInitFuncs := []func(*runtime.Scheme) error{
+		corev1.AddToScheme,
+		routev1.AddToScheme,
+	}
+	for _, f := range schemeInitFuncs {
+		if err := f(s); err != nil {
+			panic(fmt.Errorf("failed to initiate the scheme %w", err))
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...).Build()
+}
+
+func createPVC(name, namespace string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+func createTransfer(t *testing.T, opts ...TransferOption) (*RsyncTransfer, client.Client, client.Client) {
+	srcClient := buildTestClient()
+	destClient := buildTestClient()
+	e, err := endpoint.Create(context.TODO(), route.NewEndpoint(
+		types.NamespacedName{
+			Namespace: testNamespace,
+			Name:      testRouteName,
+		}, route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil), destClient)
+	if err != nil {
+		t.Fatalf("unable to create route endpoint: %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	tr, err := NewTransfer(null.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil), e, srcClient, destClient, pvcList, klogr.New(), opts...)
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	return tr.(*RsyncTransfer), srcClient, destClient
+}
+
+func TestExportManifests(t *testing.T) {
+	srcClient := buildTestClient()
+	destClient := buildTestClient()
+	e := route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	s := stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{})
+	tr, err := NewTransfer(s, e, srcClient, destClient, pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+
+	hostname := e.Hostname()
+	out, err := transfer.ExportManifests(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("ExportManifests() unexpected error %v", err)
+	}
+	if s.Crt() != nil || e.Hostname() != hostname {
+		t.Errorf("ExportManifests() should leave the transport and the endpoint unchanged")
+	}
+	manifests := string(out)
+	for _, want := range []string{"kind: Route\n", "kind: Service\n", "kind: ConfigMap\n", "kind: Pod\n", transfer.SecretWarning + "\napiVersion: v1\n"} {
+		if !strings.Contains(manifests, want) {
+			t.Errorf("exported manifests do not contain %q\n%s", want, manifests)
+		}
+	}
+	if strings.Contains(manifests, "resourceVersion") {
+		t.Errorf("exported manifests should not contain resource versions")
+	}
+	pods := &corev1.PodList{}
+	if err := destClient.List(context.TODO(), pods); err != nil || len(pods.Items) != 0 {
+		t.Errorf("ExportManifests() should not create objects in the cluster, err %v, pods %d", err, len(pods.Items))
+	}
+
+	out, err = transfer.ExportManifestsWithOptions(context.TODO(), tr, transfer.ExportOptions{OmitSecrets: true})
+	if err != nil {
+		t.Fatalf("ExportManifestsWithOptions() unexpected error %v", err)
+	}
+	if strings.Contains(string(out), "kind: Secret\n") {
+		t.Errorf("exported manifests should not contain secrets\n%s", out)
+	}
+}
+
+func TestExportManifestsGatewayRoute(t *testing.T) {
+	e, err := gateway.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName}, gateway.RouteTypeTCP,
+		statetransfermeta.Labels, types.NamespacedName{Namespace: "gateways", Name: "transfer"}, nil, gateway.Options{Port: 9000})
+	if err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	s := stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{})
+	tr, err := NewTransfer(s, e, buildTestClient(), buildTestClient(), pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	out, err := transfer.ExportManifests(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("ExportManifests() unexpected error %v", err)
+	}
+	if !strings.Contains(string(out), "kind: TCPRoute\n") {
+		t.Errorf("exported manifests do not contain the unstructured TCPRoute\n%s", out)
+	}
+}
+
+func TestExportManifestsCertificateProvider(t *testing.T) {
+	e := route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	provider, err := certmanager.NewProvider("ca", certmanager.IssuerKindClusterIssuer, statetransfermeta.Labels)
+	if err != nil {
+		t.Fatalf("unable to create certificate provider: %v", err)
+	}
+	s := stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{CertificateProvider: provider})
+	tr, err := NewTransfer(s, e, buildTestClient(), buildTestClient(), pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	out, err := transfer.ExportManifests(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("ExportManifests() unexpected error %v", err)
+	}
+	for _, want := range []string{"kind: Certificate\n", "kind: Secret\n", "kind: Pod\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("exported manifests do not contain %q\n%s", want, out)
+		}
+	}
+	if s.Options().CertificateProvider != provider {
+		t.Errorf("ExportManifests() should not change the certificate provider of the transport")
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	c := buildTestClient()
+	e := route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	s := stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil, &transport.Options{})
+	tr, err := NewTransfer(s, e, c, c, pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	if err := e.Create(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	if _, err := transport.CreateServer(context.TODO(), s, c, "", e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	if err := tr.CreateServer(context.TODO(), c); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if _, err := transport.CreateClient(context.TODO(), s, c, "", e); err != nil {
+		t.Fatalf("unable to create transport client: %v", err)
+	}
+	// the client is created twice, as it would be when a failed attempt is retried
+	for i := 0; i < 2; i++ {
+		if err := tr.CreateClient(context.TODO(), c); err != nil {
+			t.Fatalf("unable to create client: %v", err)
+		}
+	}
+	unrelated := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "rsync-unrelated", Namespace: testNamespace}}
+	if err := c.Create(context.TODO(), unrelated); err != nil {
+		t.Fatalf("unable to create pod: %v", err)
+	}
+
+	// finalizing is idempotent
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := transfer.Finalize(ctx, c, tr)
+		cancel()
+		if err != nil {
+			t.Fatalf("Finalize() unexpected error %v", err)
+		}
+	}
+	pods := &corev1.PodList{}
+	if err := c.List(context.TODO(), pods); err != nil || len(pods.Items) != 1 || pods.Items[0].Name != unrelated.Name {
+		t.Errorf("only the unrelated pod should be left, err %v, pods %v", err, pods.Items)
+	}
+	for _, list := range []client.ObjectList{&corev1.ConfigMapList{}, &corev1.SecretList{}, &corev1.ServiceList{}, &routev1.RouteList{}} {
+		if err := c.List(context.TODO(), list); err != nil || apimeta.LenList(list) != 0 {
+			t.Errorf("%T should be empty after Finalize, err %v, items %d", list, err, apimeta.LenList(list))
+		}
+	}
+}
+
+func TestDeleteServerAndClient(t *testing.T) {
+	srcClient, destClient := buildTestClient(), buildTestClient()
+	e := route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	s, err := stunnel.Share(testTransport, statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	))
+	if err != nil {
+		t.Fatalf("unable to share transport: %v", err)
+	}
+	tr, err := NewTransfer(s, e, srcClient, destClient, pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	if err := e.Create(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create endpoint: %v", err)
+	}
+	if _, err := transport.CreateServer(context.TODO(), s, destClient, "fs", e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if _, err := transport.CreateClient(context.TODO(), s, srcClient, "fs", e); err != nil {
+		t.Fatalf("unable to create transport client: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	// the Service of the endpoint was taken over by the user, it must be left alone
+	svc := &corev1.Service{}
+	if err := destClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testRouteName}, svc); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	delete(svc.Labels, statetransfermeta.OwnerLabel)
+	if err := destClient.Update(context.TODO(), svc); err != nil {
+		t.Fatalf("unable to update service: %v", err)
+	}
+
+	if err := transfer.DeleteServer(context.TODO(), tr, "fs"); err != nil {
+		t.Fatalf("DeleteServer() unexpected error %v", err)
+	}
+	for _, list := range []client.ObjectList{&corev1.PodList{}, &corev1.ConfigMapList{}, &corev1.SecretList{}, &routev1.RouteList{}} {
+		if err := destClient.List(context.TODO(), list); err != nil || apimeta.LenList(list) != 0 {
+			t.Errorf("destination %T should be empty after DeleteServer, err %v, items %d", list, err, apimeta.LenList(list))
+		}
+	}
+	services := &corev1.ServiceList{}
+	if err := destClient.List(context.TODO(), services); err != nil || len(services.Items) != 1 {
+		t.Errorf("the service without the owner label should be left, err %v, services %v", err, services.Items)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods); err != nil || len(pods.Items) != 1 {
+		t.Errorf("DeleteServer should not delete client pods, err %v, pods %v", err, pods.Items)
+	}
+
+	// deleting is idempotent
+	for i := 0; i < 2; i++ {
+		if err := transfer.DeleteClient(context.TODO(), tr, "fs"); err != nil {
+			t.Fatalf("DeleteClient() unexpected error %v", err)
+		}
+	}
+	for _, list := range []client.ObjectList{&corev1.PodList{}, &corev1.ConfigMapList{}, &corev1.SecretList{}} {
+		if err := srcClient.List(context.TODO(), list); err != nil || apimeta.LenList(list) != 0 {
+			t.Errorf("source %T should be empty after DeleteClient, err %v, items %d", list, err, apimeta.LenList(list))
+		}
+	}
+}
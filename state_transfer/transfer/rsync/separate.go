@@ -0,0 +1,205 @@
+package rsync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ServerComponentLabel is set on server pods when the transport runs separately, its value
+	// is either ServerComponentRsync or ServerComponentTransport
+	ServerComponentLabel = "crane.konveyor.io/server-component"
+	// ServerComponentRsync identifies the pods running the rsync daemon
+	ServerComponentRsync = "rsync"
+	// ServerComponentTransport identifies the pods running the transport server
+	ServerComponentTransport = "transport"
+
+	rsyncServerServiceName    = "rsync-server"
+	rsyncTransportServerName  = "rsync-server-transport"
+	rsyncServerDeploymentName = "rsync-server"
+)
+
+// ServerConnectHost returns the host of the Service in front of the rsync daemon when the server
+// runs with SeparateTransportServer, the transport must be created with it as its
+// transport.Options.ServerConnectHost so that it forwards connections to the rsync Deployment
+func ServerConnectHost(namespace string) string {
+	return fmt.Sprintf("%s.%s.svc", rsyncServerServiceName, namespace)
+}
+
+// createSeparateRsyncServer creates the rsync daemon and the transport server as two Deployments,
+// the transport reaches the daemon through a ClusterIP Service. The rsync pods do not carry the
+// selector labels of the endpoint so that the endpoint only routes to the transport pods, and a
+// NetworkPolicy only admits connections to the daemon from the transport pods.
+func createSeparateRsyncServer(ctx context.Context, c client.Client, r *RsyncTransfer, ns string, rsyncPodSpec corev1.PodSpec, transportContainers []corev1.Container) error {
+	if r.Transport().Direct() {
+		return fmt.Errorf("a separate transport server requires a transport which is not direct")
+	}
+	if host := ServerConnectHost(ns); r.Transport().Options().ServerConnectHost != host {
+		return fmt.Errorf("transport must be created with server connect host %s to run separately", host)
+	}
+	podLabels := r.transferOptions().DestinationPodMeta.Labels
+	rsyncLabels := separateServerLabels(podLabels, endpoint.SelectorLabels(r.Endpoint()), ServerComponentRsync)
+	transportLabels := separateServerLabels(serverPodLabels(podLabels, r.Endpoint()), nil, ServerComponentTransport)
+
+	for i := range transportContainers {
+		applyContainerMutations(&transportContainers[i], r.options.DestContainerMutations)
+		if r.options.guaranteedQoS {
+			applyGuaranteedQoS(&transportContainers[i])
+		}
+	}
+	transportPodSpec := corev1.PodSpec{
+		Containers: transportContainers,
+		Volumes:    r.Transport().ServerVolumes(),
+	}
+	applyPodMutations(&transportPodSpec, r.options.DestinationPodMutations)
+	if r.options.schedulerName != "" {
+		transportPodSpec.SchedulerName = r.options.schedulerName
+	}
+
+	errs := []error{}
+	// a single rsync pod at a time, destination volumes are typically ReadWriteOnce
+	errs = append(errs, meta.Apply(ctx, c, serverDeployment(rsyncServerDeploymentName, ns, rsyncLabels, rsyncPodSpec, appsv1.RecreateDeploymentStrategyType)))
+	errs = append(errs, meta.Apply(ctx, c, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rsyncServerServiceName,
+			Namespace: ns,
+			Labels:    meta.WithOwnerLabel(rsyncLabels),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "rsyncd",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       r.Transport().ExposedPort(),
+					TargetPort: intstr.FromInt(int(r.Transport().ExposedPort())),
+				},
+			},
+			Selector: rsyncLabels,
+			Type:     corev1.ServiceTypeClusterIP,
+		},
+	}))
+	errs = append(errs, meta.Apply(ctx, c, rsyncNetworkPolicy(ns, rsyncLabels, transportLabels, r.Transport().ExposedPort())))
+	errs = append(errs, meta.Apply(ctx, c, serverDeployment(rsyncTransportServerName, ns, transportLabels, transportPodSpec, appsv1.RollingUpdateDeploymentStrategyType)))
+	return errorsutil.NewAggregate(errs)
+}
+
+// rsyncNetworkPolicy returns the NetworkPolicy of the rsync pods, the daemon accepts connections
+// from remote hosts and only the transport pods may reach it
+func rsyncNetworkPolicy(ns string, rsyncLabels, transportLabels map[string]string, port int32) *networkingv1.NetworkPolicy {
+	protocol := corev1.ProtocolTCP
+	rsyncPort := intstr.FromInt(int(port))
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rsyncServerServiceName,
+			Namespace: ns,
+			Labels:    meta.WithOwnerLabel(rsyncLabels),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: rsyncLabels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: transportLabels}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &rsyncPort}},
+				},
+			},
+		},
+	}
+}
+
+// separateServerLabels returns the destination pod labels without the excluded keys and with the
+// server component label
+func separateServerLabels(labels, excluded map[string]string, component string) map[string]string {
+	componentLabels := map[string]string{}
+	for k, v := range labels {
+		if _, ok := excluded[k]; !ok {
+			componentLabels[k] = v
+		}
+	}
+	componentLabels[ServerComponentLabel] = component
+	return componentLabels
+}
+
+func serverDeployment(name, ns string, labels map[string]string, podSpec corev1.PodSpec, strategy appsv1.DeploymentStrategyType) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    meta.WithOwnerLabel(labels),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{Type: strategy},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       podSpec,
+			},
+		},
+	}
+}
+
+// separateServerHealth adds the health of the transport and the rsync pods of a separate server,
+// each component is healthy when at least one of its pods is ready
+func (r *RsyncTransfer) separateServerHealth(ctx context.Context, c client.Client, health *transfer.Health) error {
+	ns := r.pvcList.GetDestinationNamespaces()[0]
+	podLabels := r.transferOptions().DestinationPodMeta.Labels
+	components := []struct {
+		component  transfer.Component
+		labels     map[string]string
+		containers []string
+	}{
+		{transfer.ComponentTransport, separateServerLabels(serverPodLabels(podLabels, r.Endpoint()), nil, ServerComponentTransport), transport.ServerContainerNames(r.Transport())},
+		{transfer.ComponentServer, separateServerLabels(podLabels, endpoint.SelectorLabels(r.Endpoint()), ServerComponentRsync), []string{RsyncContainer}},
+	}
+	for _, component := range components {
+		pods := &corev1.PodList{}
+		err := c.List(ctx, pods, client.InNamespace(ns), client.MatchingLabels(component.labels))
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) == 0 {
+			health.Add(component.component, fmt.Errorf("no %s server pod found in namespace %s", component.labels[ServerComponentLabel], ns))
+			continue
+		}
+		var podHealth *transfer.Health
+		for i := range pods.Items {
+			podHealth = &transfer.Health{}
+			transfer.PodComponentHealth(podHealth, &pods.Items[i], map[transfer.Component][]string{component.component: component.containers})
+			if podHealth.Healthy() {
+				break
+			}
+		}
+		health.Errors = append(health.Errors, podHealth.Errors...)
+	}
+	return nil
+}
+
+// cancelSeparateServer deletes the Deployments of a separate server, their pods are given their
+// termination grace period to finish the file currently being copied
+func (r *RsyncTransfer) cancelSeparateServer(ctx context.Context, c client.Client) error {
+	ns := r.pvcList.GetDestinationNamespaces()[0]
+	errs := []error{}
+	for _, name := range []string{rsyncTransportServerName, rsyncServerDeploymentName} {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+		err := c.Delete(ctx, deployment, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
@@ -0,0 +1,153 @@
+package rsync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/route"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSeparateTransportServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		connectHost string
+		wantErr     bool
+	}{
+		{
+			name:        "transport forwards to the rsync service",
+			connectHost: ServerConnectHost(testNamespace),
+		},
+		{
+			name:    "transport forwards to the local pod",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcClient := buildTestClient()
+			destClient := buildTestClient()
+			e, err := endpoint.Create(context.TODO(), route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+				route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil), destClient)
+			if err != nil {
+				t.Fatalf("unable to create route endpoint: %v", err)
+			}
+			s, err := transport.CreateServer(context.TODO(), stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+				types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+				types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+			), nil, &transport.Options{ServerConnectHost: tt.connectHost}), destClient, "fs", e)
+			if err != nil {
+				t.Fatalf("unable to create transport server: %v", err)
+			}
+			pvcList, err := transfer.NewFilesystemPVCPairList(
+				transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+			)
+			if err != nil {
+				t.Fatalf("invalid pvc list: %v", err)
+			}
+			tr, err := NewTransfer(s, e, srcClient, destClient, pvcList, klogr.New(),
+				SeparateTransportServer(true), WithDestinationPodLabels(statetransfermeta.Labels))
+			if err != nil {
+				t.Fatalf("NewTransfer should not return an error\n %v", err)
+			}
+
+			err = tr.CreateServer(context.TODO(), destClient)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("CreateServer() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+
+			rsyncDeployment := &appsv1.Deployment{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerDeploymentName}, rsyncDeployment); err != nil {
+				t.Fatalf("unable to get rsync deployment: %v", err)
+			}
+			transportDeployment := &appsv1.Deployment{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncTransportServerName}, transportDeployment); err != nil {
+				t.Fatalf("unable to get transport deployment: %v", err)
+			}
+			service := &corev1.Service{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerServiceName}, service); err != nil {
+				t.Fatalf("unable to get rsync service: %v", err)
+			}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, &corev1.Pod{}); err == nil {
+				t.Errorf("the combined server pod should not be created")
+			}
+
+			rsyncContainers := rsyncDeployment.Spec.Template.Spec.Containers
+			if len(rsyncContainers) != 1 || rsyncContainers[0].Name != RsyncContainer {
+				t.Errorf("rsync deployment should only run the rsync container: %v", rsyncContainers)
+			}
+			transportContainers := transportDeployment.Spec.Template.Spec.Containers
+			if len(transportContainers) != 1 || transportContainers[0].Name != stunnel.StunnelContainer {
+				t.Errorf("transport deployment should only run the stunnel container: %v", transportContainers)
+			}
+			for k, v := range e.Labels() {
+				if rsyncDeployment.Spec.Template.Labels[k] == v {
+					t.Errorf("rsync pods should not be selected by the endpoint, label %s=%s", k, v)
+				}
+				if transportDeployment.Spec.Template.Labels[k] != v {
+					t.Errorf("transport pods should be selected by the endpoint, missing label %s=%s", k, v)
+				}
+			}
+			if service.Spec.Selector[ServerComponentLabel] != ServerComponentRsync || service.Spec.Ports[0].Port != s.ExposedPort() {
+				t.Errorf("rsync service does not select the rsync pods: %v", service.Spec)
+			}
+
+			stunnelConfig := &corev1.ConfigMap{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: "fs-crane2-stunnel-server-config"}, stunnelConfig); err != nil {
+				t.Fatalf("unable to get stunnel config: %v", err)
+			}
+			if !strings.Contains(stunnelConfig.Data["stunnel.conf"], "connect = rsync-server.test-namespace.svc:") {
+				t.Errorf("stunnel does not forward to the rsync service\n%s", stunnelConfig.Data["stunnel.conf"])
+			}
+			rsyncConfig := &corev1.ConfigMap{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncServerConfig}, rsyncConfig); err != nil {
+				t.Fatalf("unable to get rsync config: %v", err)
+			}
+			if strings.Contains(rsyncConfig.Data["rsyncd.conf"], "hosts allow") {
+				t.Errorf("rsync daemon should accept connections from the transport pods\n%s", rsyncConfig.Data["rsyncd.conf"])
+			}
+			policy := &networkingv1.NetworkPolicy{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerServiceName}, policy); err != nil {
+				t.Fatalf("unable to get rsync network policy: %v", err)
+			}
+			if policy.Spec.PodSelector.MatchLabels[ServerComponentLabel] != ServerComponentRsync ||
+				policy.Spec.Ingress[0].From[0].PodSelector.MatchLabels[ServerComponentLabel] != ServerComponentTransport {
+				t.Errorf("network policy should only admit the transport pods to the rsync pods: %v", policy.Spec)
+			}
+
+			health := &transfer.Health{}
+			if err := tr.(*RsyncTransfer).separateServerHealth(context.TODO(), destClient, health); err != nil {
+				t.Fatalf("unable to get server health: %v", err)
+			}
+			if len(health.Unhealthy()) != 2 {
+				t.Errorf("both server components should be unhealthy without pods: %v", health.Unhealthy())
+			}
+
+			if err := transfer.Cancel(context.TODO(), tr, false); err != nil {
+				t.Fatalf("unable to cancel transfer: %v", err)
+			}
+			err = destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerDeploymentName}, &appsv1.Deployment{})
+			if !k8serrors.IsNotFound(err) {
+				t.Errorf("rsync deployment should be deleted on cancel: %v", err)
+			}
+		})
+	}
+}
@@ -3,12 +3,17 @@ package rsync
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"fmt"
-	random "math/rand"
+	"math/big"
+	"reflect"
+	"strings"
 	"text/template"
-	"time"
 
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,7 +28,9 @@ list = yes
 log file = /dev/stdout
 max verbosity = 4
 auth users = {{ $.Username }}
+{{ if not $.AllowRemoteHosts }}
 hosts allow = ::1, 127.0.0.1, localhost
+{{ end }}
 {{ if $.RunAsRoot }}
 uid = root
 gid = root
@@ -44,6 +51,9 @@ munge symlinks = no
     path = /mnt/{{ $pvc.Destination.Claim.Namespace }}/{{ $pvc.Destination.LabelSafeName }}
     list = yes
     read only = false
+{{- if $.NumericIDs }}
+    numeric ids = yes
+{{- end }}
     auth users = {{ $.Username }}
     secrets file = /etc/rsync-secret/rsyncd.secrets
 {{ end }}
@@ -56,35 +66,110 @@ type rsyncConfigData struct {
 	RunAsRoot     bool
 	EnableChroot  bool
 	MungeSymlinks bool
+	NumericIDs    bool
+	// AllowRemoteHosts accepts connections from other pods, required when the transport runs separately
+	// or spawns the daemon
+	AllowRemoteHosts bool
 }
 
-func (r *RsyncTransfer) CreateServer(c client.Client) error {
+func (r *RsyncTransfer) CreateServer(ctx context.Context, c client.Client) error {
 	destNs := r.pvcList.GetDestinationNamespaces()[0]
+	log := r.Log.WithValues("namespace", destNs)
 	errs := []error{}
 
-	err := createRsyncServerResources(c, r, destNs)
+	if r.options.destinationPVCs != nil {
+		// the destination PVCs outlive the server, they do not get the owner references of its pods
+		err := transfer.CreateDestinationPVCs(ctx, meta.NewLoggingClient(c, log), r.options.destinationPVCs, r.pvcList.InDestinationNamespace(destNs))
+		errs = append(errs, err)
+	}
+
+	c = meta.NewLoggingClient(metadataClient(c, r.options.DestinationPodMeta), log)
+
+	if err := r.reuseServerPassword(ctx, c); err != nil {
+		return err
+	}
+
+	err := createRsyncServerResources(ctx, c, r, destNs)
 	errs = append(errs, err)
 
-	err = createRsyncServer(c, r, destNs)
+	err = createRsyncServer(ctx, c, r, destNs)
 	errs = append(errs, err)
 
-	return errorsutil.NewAggregate(errs)
+	if r.options.correlationID != "" {
+		err = r.labelServerCorrelationID(ctx, c, destNs)
+		errs = append(errs, err)
+	}
+
+	err = errorsutil.NewAggregate(errs)
+	if err != nil {
+		log.Error(err, "unable to create rsync server")
+	}
+	return err
+}
+
+func (r *RsyncTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := r.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(r.Log.WithValues("namespace", r.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
 }
 
-func (r *RsyncTransfer) IsServerHealthy(c client.Client) (bool, error) {
-	return transfer.IsPodHealthy(c, client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: "rsync-server"})
+func (r *RsyncTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	if r.options.separateTransportServer {
+		health := &transfer.Health{}
+		if err := r.separateServerHealth(ctx, c, health); err != nil {
+			return false, err
+		}
+		return health.Healthy(), health.Err()
+	}
+	containers := transport.ServerContainerNames(r.Transport())
+	if !r.options.lazyRsync {
+		containers = append([]string{RsyncContainer}, containers...)
+	}
+	return transfer.IsPodHealthy(ctx, c, client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: rsyncServerPodName}, containers...)
+}
+
+// ServerHealth returns the health of the endpoint, the transport containers and the rsync
+// container of the server pod, so that callers can tell which component is not ready
+func (r *RsyncTransfer) ServerHealth(ctx context.Context, c client.Client) (*transfer.Health, error) {
+	health := &transfer.Health{}
+	health.Add(transfer.ComponentEndpoint, transfer.EndpointHealth(ctx, r, c))
+	if r.options.separateTransportServer {
+		if err := r.separateServerHealth(ctx, c, health); err != nil {
+			return nil, err
+		}
+		return health, nil
+	}
+
+	pod := &corev1.Pod{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: rsyncServerPodName}, pod)
+	if k8serrors.IsNotFound(err) {
+		health.Add(transfer.ComponentServer, err)
+		return health, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	serverContainers := []string{RsyncContainer}
+	if r.options.lazyRsync {
+		// the transport containers spawn the rsync daemon
+		serverContainers = transport.ServerContainerNames(r.Transport())
+	}
+	transfer.PodComponentHealth(health, pod, map[transfer.Component][]string{
+		transfer.ComponentTransport: transport.ServerContainerNames(r.Transport()),
+		transfer.ComponentServer:    serverContainers,
+	})
+	return health, nil
 }
 
-func createRsyncServerResources(c client.Client, r *RsyncTransfer, ns string) error {
-	r.username = defaultRsyncUser
+func createRsyncServerResources(ctx context.Context, c client.Client, r *RsyncTransfer, ns string) error {
 	r.port = rsyncPort
 
-	err := createRsyncServerConfig(c, r, ns)
+	err := createRsyncServerConfig(ctx, c, r, ns)
 	if err != nil {
 		return err
 	}
 
-	err = createRsyncServerSecret(c, r, ns)
+	err = createRsyncServerSecret(ctx, c, r, ns)
 	if err != nil {
 		return err
 	}
@@ -92,11 +177,14 @@ func createRsyncServerResources(c client.Client, r *RsyncTransfer, ns string) er
 	return nil
 }
 
-func createRsyncServerConfig(c client.Client, r *RsyncTransfer, ns string) error {
+func createRsyncServerConfig(ctx context.Context, c client.Client, r *RsyncTransfer, ns string) error {
 	var rsyncConf bytes.Buffer
 	runRsyncAsRoot := false
 	runRsyncAsPrivileged := false
 	for _, ops := range r.options.DestContainerMutations {
+		if ops.SecurityContext() == nil {
+			continue
+		}
 		if ops.SecurityContext().RunAsUser != nil && *ops.SecurityContext().RunAsUser == int64(0) {
 			// running rsync as root
 			runRsyncAsRoot = true
@@ -111,66 +199,99 @@ func createRsyncServerConfig(c client.Client, r *RsyncTransfer, ns string) error
 		return err
 	}
 
+	// a daemon spawned by the transport for a connection does not see a local address as its peer
+	allowRemoteHosts := r.options.separateTransportServer || r.options.lazyRsync
 	configdata := rsyncConfigData{
-		Username:      r.options.username,
-		PVCPairList:   r.pvcList.InDestinationNamespace(ns),
-		RunAsRoot:     runRsyncAsRoot || runRsyncAsPrivileged,
-		EnableChroot:  runRsyncAsPrivileged,
-		MungeSymlinks: r.options.mungeSymlinks,
+		Username:         r.options.username,
+		PVCPairList:      r.pvcList.InDestinationNamespace(ns),
+		RunAsRoot:        runRsyncAsRoot || runRsyncAsPrivileged,
+		EnableChroot:     runRsyncAsPrivileged,
+		MungeSymlinks:    r.options.mungeSymlinks,
+		NumericIDs:       r.options.NumericIDs,
+		AllowRemoteHosts: allowRemoteHosts,
 	}
 
 	err = rsyncConfTemplate.Execute(&rsyncConf, configdata)
 	if err != nil {
 		return err
 	}
+	r.Log.V(1).Info("rendered rsync server config", "namespace", ns, "pvcs", len(configdata.PVCPairList))
 
 	rsyncConfigMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: ns,
 			Name:      defaultRsyncServerConfig,
-			Labels:    r.transferOptions().DestinationPodMeta.Labels,
+			Labels:    meta.WithOwnerLabel(r.transferOptions().DestinationPodMeta.Labels),
 		},
 		Data: map[string]string{
 			"rsyncd.conf": rsyncConf.String(),
 		},
 	}
-	err = c.Create(context.TODO(), rsyncConfigMap, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	}
-	return nil
+	return meta.Apply(ctx, c, rsyncConfigMap)
 }
 
-func createRsyncServerSecret(c client.Client, r *RsyncTransfer, ns string) error {
-	var letters = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	random.Seed(time.Now().UnixNano())
-	password := make([]byte, 24)
-	for i := range password {
-		password[i] = letters[random.Intn(len(letters))]
-	}
-	r.password = string(password)
-
+func createRsyncServerSecret(ctx context.Context, c client.Client, r *RsyncTransfer, ns string) error {
 	rsyncSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: ns,
 			Name:      defaultRsyncServerSecret,
-			Labels:    r.transferOptions().DestinationPodMeta.Labels,
+			Labels:    meta.WithOwnerLabel(r.transferOptions().DestinationPodMeta.Labels),
 		},
 		Data: map[string][]byte{
 			"credentials": []byte(r.transferOptions().username + ":" + r.transferOptions().password),
 		},
 	}
-	err := c.Create(context.TODO(), rsyncSecret, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
+	return meta.Apply(ctx, c, rsyncSecret)
+}
+
+// generatePassword returns a random password for the rsync daemon
+func generatePassword() (string, error) {
+	letters := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	password := make([]byte, 24)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = letters[n.Int64()]
 	}
+	return string(password), nil
+}
 
+// reuseServerPassword replaces a password generated by NewTransfer with the password of the rsync
+// server Secret left by a previous instance of the transfer in the destination cluster, so that a
+// transfer built again, after a restart of its process for instance, keeps the password the server
+// and the clients already run with. Passwords set with the Password option are left unchanged.
+func (r *RsyncTransfer) reuseServerPassword(ctx context.Context, c client.Client) error {
+	if !r.options.generatedPassword || c == nil {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: defaultRsyncServerSecret}, secret)
+	switch {
+	case k8serrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("unable to read the password of the rsync server: %w", err)
+	}
+	credentials := string(secret.Data["credentials"])
+	prefix := r.options.username + ":"
+	if !meta.IsOwned(secret.Labels) || !strings.HasPrefix(credentials, prefix) || len(credentials) == len(prefix) {
+		return nil
+	}
+	r.options.password = strings.TrimPrefix(credentials, prefix)
+	r.password = r.options.password
 	return nil
 }
 
-func createRsyncServer(c client.Client, r *RsyncTransfer, ns string) error {
+func createRsyncServer(ctx context.Context, c client.Client, r *RsyncTransfer, ns string) error {
+	if r.options.lazyRsync {
+		if err := validateLazyRsync(r); err != nil {
+			return err
+		}
+	}
 	transferOptions := r.transferOptions()
-	podLabels := transferOptions.DestinationPodMeta.Labels
+	podLabels := serverPodLabels(transferOptions.DestinationPodMeta.Labels, r.Endpoint())
 	volumeMounts := []corev1.VolumeMount{}
 	configVolumeMounts := []corev1.VolumeMount{
 		{
@@ -189,24 +310,41 @@ func createRsyncServer(c client.Client, r *RsyncTransfer, ns string) error {
 			pvcVolumeMounts = append(
 				pvcVolumeMounts,
 				corev1.VolumeMount{
-					Name:      pvc.Destination().LabelSafeName(),
-					MountPath: fmt.Sprintf("/mnt/%s/%s", pvc.Destination().Claim().Namespace, pvc.Destination().LabelSafeName()),
+					Name:             pvc.Destination().LabelSafeName(),
+					MountPath:        fmt.Sprintf("/mnt/%s/%s", pvc.Destination().Claim().Namespace, pvc.Destination().LabelSafeName()),
+					MountPropagation: r.options.mountPropagation,
 				})
 		}
 	}
 	volumeMounts = append(volumeMounts, configVolumeMounts...)
 	volumeMounts = append(volumeMounts, pvcVolumeMounts...)
-	containers := []corev1.Container{
-		{
-			Name:  RsyncContainer,
-			Image: r.getRsyncServerImage(),
-			Command: []string{
-				"/usr/bin/rsync",
-				"--daemon",
-				"--no-detach",
-				fmt.Sprintf("--port=%d", r.Transport().ExposedPort()),
-				"-vvv",
-			},
+	if r.options.tempDir != nil {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: tempDirVolumeName, MountPath: tempDir})
+	}
+	rsyncCommand := []string{
+		"/usr/bin/rsync",
+		"--daemon",
+		"--no-detach",
+		fmt.Sprintf("--port=%d", r.Transport().ExposedPort()),
+		"-vvv",
+	}
+	// with a direct transport rsync itself is exposed through the endpoint
+	if bindAddress := endpoint.BindAddress(r.Endpoint()); bindAddress != "" && r.Transport().Direct() {
+		rsyncCommand = append(rsyncCommand, fmt.Sprintf("--address=%s", bindAddress))
+	}
+	containers := []corev1.Container{}
+	transportContainers := r.transport.ServerContainers()
+	if r.options.lazyRsync {
+		// the transport spawns the rsync daemon for every connection, it needs the volumes of the daemon
+		for i := range transportContainers {
+			transportContainers[i].VolumeMounts = append(transportContainers[i].VolumeMounts, volumeMounts...)
+		}
+	} else {
+		containers = append(containers, corev1.Container{
+			Name:            RsyncContainer,
+			Image:           r.getRsyncServerImage(),
+			ImagePullPolicy: r.options.imagePullPolicy,
+			Command:         rsyncCommand,
 			Ports: []corev1.ContainerPort{
 				{
 					Name:          "rsyncd",
@@ -215,14 +353,21 @@ func createRsyncServer(c client.Client, r *RsyncTransfer, ns string) error {
 				},
 			},
 			VolumeMounts: volumeMounts,
-		},
+		})
+	}
+	if !r.options.separateTransportServer {
+		containers = append(containers, transportContainers...)
 	}
-
-	containers = append(containers, r.transport.ServerContainers()...)
 	// apply container mutations
 	for i := range containers {
 		c := &containers[i]
 		applyContainerMutations(c, r.options.DestContainerMutations)
+		if c.Name == RsyncContainer || r.options.lazyRsync {
+			applyEphemeralStorage(c, r.options.ephemeralStorage)
+		}
+		if r.options.guaranteedQoS {
+			applyGuaranteedQoS(c)
+		}
 	}
 
 	mode := int32(0600)
@@ -273,7 +418,12 @@ func createRsyncServer(c client.Client, r *RsyncTransfer, ns string) error {
 		}
 	}
 	volumes := append(pvcVolumes, configVolumes...)
-	volumes = append(volumes, r.Transport().ServerVolumes()...)
+	if r.options.tempDir != nil {
+		volumes = append(volumes, tempDirVolume(r.options.tempDir))
+	}
+	if !r.options.separateTransportServer {
+		volumes = append(volumes, r.Transport().ServerVolumes()...)
+	}
 
 	podSpec := corev1.PodSpec{
 		Containers: containers,
@@ -281,22 +431,136 @@ func createRsyncServer(c client.Client, r *RsyncTransfer, ns string) error {
 	}
 
 	applyPodMutations(&podSpec, r.options.DestinationPodMutations)
+	if r.options.schedulerName != "" {
+		podSpec.SchedulerName = r.options.schedulerName
+	}
+
+	if r.options.fixPermissions != nil {
+		initContainer, err := r.permissionsInitContainer(&podSpec, pvcVolumeMounts)
+		if err != nil {
+			return err
+		}
+		// use the resources of an rsync container so that the QoS class of the pod is kept, rather
+		// than those of the first container which is the transport with LazyRsync
+		initContainer.Resources = r.rsyncServerResources()
+		podSpec.InitContainers = append(podSpec.InitContainers, *initContainer)
+	}
+
+	if filesystemCount == 0 {
+		return nil
+	}
+	if r.options.separateTransportServer {
+		return createSeparateRsyncServer(ctx, c, r, ns, podSpec, transportContainers)
+	}
 
 	server := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "rsync-server",
+			Name:      rsyncServerPodName,
 			Namespace: ns,
-			Labels:    podLabels,
+			Labels:    meta.WithOwnerLabel(podLabels),
 		},
 		Spec: podSpec,
 	}
 
-	if filesystemCount > 0 {
-		err := c.Create(context.TODO(), server, &client.CreateOptions{})
-		if err != nil && !k8serrors.IsAlreadyExists(err) {
-			return err
-		}
+	err := c.Create(ctx, server, &client.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
 		return err
 	}
+	return err
+}
+
+// serverPodLabels returns the labels of the server pods, the destination pod labels and the selector
+// labels of the endpoint, which take precedence so that the endpoint Service always selects the pods
+func serverPodLabels(labels map[string]string, e endpoint.Endpoint) map[string]string {
+	return endpoint.MergeLabels(endpoint.SelectorLabels(e), labels)
+}
+
+// tempDirVolume returns the volume the rsync server writes temporary files to
+func tempDirVolume(t *TempDir) corev1.Volume {
+	volume := corev1.Volume{
+		Name: tempDirVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumDefault},
+		},
+	}
+	if t.ClaimName != "" {
+		volume.VolumeSource = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: t.ClaimName,
+			},
+		}
+	}
+	return volume
+}
+
+// permissionsInitContainer returns an init container which changes the ownership and permissions
+// of the given destination volume mounts as configured with FixDestinationPermissions
+func (r *RsyncTransfer) permissionsInitContainer(podSpec *corev1.PodSpec, mounts []corev1.VolumeMount) (*corev1.Container, error) {
+	uid := r.options.fixPermissions.UID
+	gid := r.options.fixPermissions.GID
+	if gid == nil && podSpec.SecurityContext != nil {
+		gid = podSpec.SecurityContext.FSGroup
+	}
+	if uid == nil && gid == nil {
+		return nil, fmt.Errorf("fixing destination permissions requires a uid, a gid or a pod fsGroup")
+	}
+	owner := ""
+	if uid != nil {
+		owner = fmt.Sprintf("%d", *uid)
+	}
+	if gid != nil {
+		owner = fmt.Sprintf("%s:%d", owner, *gid)
+	}
+	commands := []string{}
+	for _, mount := range mounts {
+		commands = append(commands,
+			fmt.Sprintf("chown -R %s %s", owner, mount.MountPath),
+			fmt.Sprintf("chmod -R ug+rwX %s", mount.MountPath))
+	}
+	runAsRoot := int64(0)
+	return &corev1.Container{
+		Name:            PermissionsInitContainer,
+		Image:           r.getRsyncServerImage(),
+		ImagePullPolicy: r.options.imagePullPolicy,
+		Command:         []string{"/bin/bash", "-c", strings.Join(commands, " && ")},
+		VolumeMounts:    mounts,
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser: &runAsRoot,
+		},
+	}, nil
+}
+
+// rsyncServerResources returns the resources of the rsync container of the server, set by the
+// container mutations, EphemeralStorage and GuaranteedQoS
+func (r *RsyncTransfer) rsyncServerResources() corev1.ResourceRequirements {
+	container := corev1.Container{}
+	applyContainerMutations(&container, r.options.DestContainerMutations)
+	applyEphemeralStorage(&container, r.options.ephemeralStorage)
+	if r.options.guaranteedQoS {
+		applyGuaranteedQoS(&container)
+	}
+	return container.Resources
+}
+
+// LazyServerExec returns the command the transport server runs for every connection with LazyRsync,
+// the transport must be created with it as its transport.Options.ServerExec. The rsync daemon serves
+// the connection on its standard input and output, and exits when the connection is closed. The
+// image of the transport server must therefore ship rsync at /usr/bin/rsync, which the default
+// stunnel image does, connections fail as soon as they are accepted otherwise.
+func LazyServerExec() []string {
+	return []string{"/usr/bin/rsync", "--daemon", "--config=/etc/rsyncd.conf"}
+}
+
+// validateLazyRsync returns an error when the transport of the transfer cannot spawn the rsync daemon
+func validateLazyRsync(r *RsyncTransfer) error {
+	if r.options.separateTransportServer {
+		return fmt.Errorf("lazy rsync cannot be used with a separate transport server")
+	}
+	if r.Transport().Direct() {
+		return fmt.Errorf("lazy rsync requires a transport which is not direct")
+	}
+	if !reflect.DeepEqual(r.Transport().Options().ServerExec, LazyServerExec()) {
+		return fmt.Errorf("transport must be created with server exec %q to run rsync lazily", strings.Join(LazyServerExec(), " "))
+	}
 	return nil
 }
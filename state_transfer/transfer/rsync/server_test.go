@@ -0,0 +1,597 @@
+package rsync
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/route"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreateServerWithoutRouteAPI(t *testing.T) {
+	// a vanilla Kubernetes destination without the OpenShift Route API
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	destClient := fake.NewClientBuilder().WithScheme(s).Build()
+	srcClient := fake.NewClientBuilder().WithScheme(s).Build()
+
+	e, err := endpoint.Create(context.TODO(), service.NewEndpoint(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		statetransfermeta.Labels, "", corev1.ServiceTypeLoadBalancer, nil), destClient)
+	if err != nil {
+		t.Fatalf("unable to create service endpoint: %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	tr, err := NewTransfer(null.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil), e, srcClient, destClient, pvcList, klogr.New())
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("unable to create server without the Route API: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+}
+
+func TestIsServerHealthy(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []corev1.ContainerStatus
+		want     bool
+	}{
+		{
+			name:     "rsync container ready",
+			statuses: []corev1.ContainerStatus{{Name: RsyncContainer, Ready: true}},
+			want:     true,
+		},
+		{
+			name:     "rsync container not ready",
+			statuses: []corev1.ContainerStatus{{Name: RsyncContainer}},
+		},
+		{
+			name:     "rsync container missing",
+			statuses: []corev1.ContainerStatus{{Name: "sidecar", Ready: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _, destClient := createTransfer(t)
+			if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+			pod := &corev1.Pod{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, pod); err != nil {
+				t.Fatalf("unable to get server pod: %v", err)
+			}
+			pod.Status.ContainerStatuses = tt.statuses
+			if err := destClient.Update(context.TODO(), pod); err != nil {
+				t.Fatalf("unable to update server pod: %v", err)
+			}
+
+			healthy, err := tr.IsServerHealthy(context.TODO(), destClient)
+			if healthy != tt.want {
+				t.Errorf("IsServerHealthy() = %v, want %v", healthy, tt.want)
+			}
+			if !tt.want && err == nil {
+				t.Errorf("IsServerHealthy() expected an error explaining why the server is unhealthy")
+			}
+		})
+	}
+}
+
+func TestCreateServerWithScheme(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := corev1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	tr, _, _ := createTransfer(t, WithScheme{Scheme: s})
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	got, err := transfer.Scheme(tr)
+	if err != nil {
+		t.Fatalf("Scheme() unexpected error %v", err)
+	}
+	if got == s || s.IsGroupRegistered(routev1.GroupName) {
+		t.Fatalf("Scheme() should not change the supplied scheme")
+	}
+	if !got.IsGroupRegistered(routev1.GroupName) || !got.Recognizes(corev1.SchemeGroupVersion.WithKind("Pod")) {
+		t.Errorf("Scheme() should add the route API types of the endpoint to the supplied types")
+	}
+	if got.IsGroupRegistered("apps") {
+		t.Errorf("default API types should not be added to the supplied scheme")
+	}
+}
+
+func TestCreateDestinationPVCs(t *testing.T) {
+	tr, _, destClient := createTransfer(t,
+		DestinationPVCs{
+			StorageClassMapping: map[string]string{"": "ceph-rbd"},
+			AccessModeMapping:   map[corev1.PersistentVolumeAccessMode]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce: corev1.ReadWriteMany},
+			SizeFactor:          1.5,
+		},
+		WithOwnerReferences{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "1234"}},
+	)
+	source := tr.pvcList[0].Source().Claim()
+	source.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	source.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := destClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testPVCName}, pvc); err != nil {
+		t.Fatalf("destination pvc was not created: %v", err)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "ceph-rbd" {
+		t.Errorf("destination storage class = %v, want ceph-rbd", pvc.Spec.StorageClassName)
+	}
+	if !reflect.DeepEqual(pvc.Spec.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}) {
+		t.Errorf("destination access modes = %v, want ReadWriteMany", pvc.Spec.AccessModes)
+	}
+	if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != "1536Mi" {
+		t.Errorf("destination size = %s, want 1536Mi", size.String())
+	}
+	if len(pvc.OwnerReferences) != 0 || statetransfermeta.IsOwned(pvc.Labels) {
+		t.Errorf("destination pvc should not be owned by the transfer, it holds the transferred data")
+	}
+	if err := (DestinationPVCs{SizeFactor: 0.5}).ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("a size factor less than 1 should be rejected")
+	}
+}
+
+func TestFixDestinationPermissions(t *testing.T) {
+	uid := int64(1000)
+	fsGroup := int64(2000)
+	tests := []struct {
+		name    string
+		opts    []TransferOption
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "uid and gid",
+			opts: []TransferOption{FixDestinationPermissions{UID: &uid, GID: &fsGroup}},
+			want: "chown -R 1000:2000 ",
+		},
+		{
+			name: "gid defaults to fsGroup",
+			opts: []TransferOption{
+				FixDestinationPermissions{},
+				&DestinationPodSpecMutation{Spec: &corev1.PodSpec{SecurityContext: &corev1.PodSecurityContext{FSGroup: &fsGroup}}},
+			},
+			want: "chown -R :2000 ",
+		},
+		{
+			name:    "no uid, gid or fsGroup",
+			opts:    []TransferOption{FixDestinationPermissions{}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _, destClient := createTransfer(t, tt.opts...)
+			err := tr.CreateServer(context.TODO(), destClient)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("CreateServer() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+			pod := &corev1.Pod{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, pod); err != nil {
+				t.Fatalf("unable to get server pod: %v", err)
+			}
+			if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Name != PermissionsInitContainer {
+				t.Fatalf("expected the %s init container, got %v", PermissionsInitContainer, pod.Spec.InitContainers)
+			}
+			initContainer := pod.Spec.InitContainers[0]
+			mountPath := getMountPathForPVC(tr.PVCs()[0].Destination())
+			if cmd := initContainer.Command[2]; !strings.Contains(cmd, tt.want+mountPath) || !strings.Contains(cmd, "chmod -R ug+rwX "+mountPath) {
+				t.Errorf("unexpected init container command %q", cmd)
+			}
+			if initContainer.SecurityContext == nil || *initContainer.SecurityContext.RunAsUser != 0 {
+				t.Errorf("init container should run as root")
+			}
+		})
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	tr, _, destClient := createTransfer(t)
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	pod := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, pod); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: RsyncContainer, Ready: true}}
+	if err := destClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update server pod: %v", err)
+	}
+
+	health, err := transfer.ServerHealth(context.TODO(), tr, destClient)
+	if err != nil {
+		t.Fatalf("ServerHealth() unexpected error %v", err)
+	}
+	if unhealthy := health.Unhealthy(); len(unhealthy) != 1 || unhealthy[0] != transfer.ComponentEndpoint {
+		t.Fatalf("expected only the endpoint to be unhealthy, got %v", unhealthy)
+	}
+
+	route := &routev1.Route{}
+	if err := destClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testRouteName}, route); err != nil {
+		t.Fatalf("unable to get route: %v", err)
+	}
+	route.Status.Ingress = []routev1.RouteIngress{{
+		Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue}},
+	}}
+	if err := destClient.Update(context.TODO(), route); err != nil {
+		t.Fatalf("unable to update route: %v", err)
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: RsyncContainer}}
+	if err := destClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update server pod: %v", err)
+	}
+
+	health, err = transfer.ServerHealth(context.TODO(), tr, destClient)
+	if err != nil {
+		t.Fatalf("ServerHealth() unexpected error %v", err)
+	}
+	if unhealthy := health.Unhealthy(); len(unhealthy) != 1 || unhealthy[0] != transfer.ComponentServer {
+		t.Fatalf("expected only the server to be unhealthy, got %v: %v", unhealthy, health.Err())
+	}
+}
+
+func TestRsyncAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []TransferOption
+		wantUser     string
+		wantPassword string
+	}{
+		{
+			name:     "generated password",
+			wantUser: defaultRsyncUser,
+		},
+		{
+			name:         "configured credentials",
+			options:      []TransferOption{Username("migrator"), Password("s3cret")},
+			wantUser:     "migrator",
+			wantPassword: "s3cret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, srcClient, destClient := createTransfer(t, tt.options...)
+			if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+			if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+			password := tr.Password()
+			if password == "" || (tt.wantPassword != "" && password != tt.wantPassword) {
+				t.Fatalf("unexpected password %q", password)
+			}
+
+			cm := &corev1.ConfigMap{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncServerConfig}, cm); err != nil {
+				t.Fatalf("unable to get server config: %v", err)
+			}
+			for _, want := range []string{"auth users = " + tt.wantUser + "\n", "secrets file = /etc/rsync-secret/rsyncd.secrets\n"} {
+				if !strings.Contains(cm.Data["rsyncd.conf"], want) {
+					t.Errorf("rsyncd.conf does not contain %q\n%s", want, cm.Data["rsyncd.conf"])
+				}
+			}
+			serverSecret := &corev1.Secret{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncServerSecret}, serverSecret); err != nil {
+				t.Fatalf("unable to get server secret: %v", err)
+			}
+			if got := string(serverSecret.Data["credentials"]); got != tt.wantUser+":"+password {
+				t.Errorf("server credentials = %q, want %q", got, tt.wantUser+":"+password)
+			}
+			clientSecret := &corev1.Secret{}
+			if err := srcClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncClientSecret}, clientSecret); err != nil {
+				t.Fatalf("unable to get client secret: %v", err)
+			}
+			if got := string(clientSecret.Data[rsyncPasswordKey]); got != password {
+				t.Errorf("client password = %q, want %q", got, password)
+			}
+
+			pods := &corev1.PodList{}
+			if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+				t.Fatalf("unable to find rsync client pod: %v", err)
+			}
+			rsync := pods.Items[0].Spec.Containers[0]
+			if !strings.Contains(rsync.Command[2], "rsync://"+tt.wantUser+"@") {
+				t.Errorf("client does not connect as %s: %s", tt.wantUser, rsync.Command[2])
+			}
+			env := rsync.Env[0]
+			if env.Name != "RSYNC_PASSWORD" || env.Value != "" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil ||
+				env.ValueFrom.SecretKeyRef.Name != defaultRsyncClientSecret || env.ValueFrom.SecretKeyRef.Key != rsyncPasswordKey {
+				t.Errorf("client password should be read from the client secret, got %+v", env)
+			}
+		})
+	}
+}
+
+func TestRsyncPasswordReused(t *testing.T) {
+	tr, srcClient, destClient := createTransfer(t)
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	// the transfer is built again, as a controller does after a restart
+	rebuilt, err := NewTransfer(tr.Transport(), tr.Endpoint(), srcClient, destClient, tr.PVCs(), nil)
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	if rebuilt.(*RsyncTransfer).Password() == tr.Password() {
+		t.Fatalf("a new transfer should generate a new password before reading the server secret")
+	}
+	if err := rebuilt.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if got := rebuilt.(*RsyncTransfer).Password(); got != tr.Password() {
+		t.Errorf("rebuilt transfer password = %q, want the password of the server %q", got, tr.Password())
+	}
+	clientSecret := &corev1.Secret{}
+	if err := srcClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncClientSecret}, clientSecret); err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if got := string(clientSecret.Data[rsyncPasswordKey]); got != tr.Password() {
+		t.Errorf("client password = %q, want the password of the server %q", got, tr.Password())
+	}
+
+	configured, err := NewTransfer(tr.Transport(), tr.Endpoint(), srcClient, destClient, tr.PVCs(), nil, Password("s3cret"))
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	if err := configured.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if err := srcClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: defaultRsyncClientSecret}, clientSecret); err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if got := string(clientSecret.Data[rsyncPasswordKey]); got != "s3cret" || configured.(*RsyncTransfer).Password() != "s3cret" {
+		t.Errorf("a configured password should not be replaced by the password of the server, got %q", got)
+	}
+}
+
+func TestLazyRsync(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverExec []string
+		wantErr    bool
+	}{
+		{
+			name:       "transport spawns the rsync daemon",
+			serverExec: LazyServerExec(),
+		},
+		{
+			name:    "transport forwards to the rsync daemon",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcClient := buildTestClient()
+			destClient := buildTestClient()
+			e, err := endpoint.Create(context.TODO(), route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+				route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil), destClient)
+			if err != nil {
+				t.Fatalf("unable to create route endpoint: %v", err)
+			}
+			s, err := transport.CreateServer(context.TODO(), stunnel.NewTransport(statetransfermeta.NewNamespacedPair(
+				types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+				types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+			), nil, &transport.Options{ServerExec: tt.serverExec, ContainerResources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+			}}), destClient, "fs", e)
+			if err != nil {
+				t.Fatalf("unable to create transport server: %v", err)
+			}
+			pvcList, err := transfer.NewFilesystemPVCPairList(
+				transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+			)
+			if err != nil {
+				t.Fatalf("invalid pvc list: %v", err)
+			}
+			uid := int64(1000)
+			tr, err := NewTransfer(s, e, srcClient, destClient, pvcList, klogr.New(), LazyRsync(true),
+				FixDestinationPermissions{UID: &uid}, EphemeralStorage{Request: "1Gi"})
+			if err != nil {
+				t.Fatalf("NewTransfer should not return an error\n %v", err)
+			}
+
+			err = tr.CreateServer(context.TODO(), destClient)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("CreateServer() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create server: %v", err)
+			}
+
+			server := &corev1.Pod{}
+			if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+				t.Fatalf("unable to get server pod: %v", err)
+			}
+			if len(server.Spec.Containers) != 1 || server.Spec.Containers[0].Name != stunnel.StunnelContainer {
+				t.Fatalf("server should only run the transport container: %+v", server.Spec.Containers)
+			}
+			mounts := map[string]string{}
+			for _, m := range server.Spec.Containers[0].VolumeMounts {
+				mounts[m.Name] = m.MountPath
+			}
+			pvc := tr.(*RsyncTransfer).pvcList[0].Destination()
+			if mounts[pvc.LabelSafeName()] != "/mnt/"+testNamespace+"/"+pvc.LabelSafeName() || mounts[defaultRsyncServerConfig] != "/etc/rsyncd.conf" {
+				t.Errorf("transport container does not mount the volumes of the rsync daemon: %v", mounts)
+			}
+			if len(server.Spec.InitContainers) != 1 {
+				t.Fatalf("expected the %s init container, got %v", PermissionsInitContainer, server.Spec.InitContainers)
+			}
+			initResources := server.Spec.InitContainers[0].Resources
+			if _, ok := initResources.Requests[corev1.ResourceCPU]; ok {
+				t.Errorf("init container should not get the resources of the transport container: %v", initResources)
+			}
+			if q := initResources.Requests[corev1.ResourceEphemeralStorage]; q.String() != "1Gi" {
+				t.Errorf("init container should get the resources of an rsync container: %v", initResources)
+			}
+		})
+	}
+}
+
+func TestMountPropagation(t *testing.T) {
+	if err := MountPropagation("Shared").ApplyTo(&TransferOptions{}); err == nil {
+		t.Errorf("unsupported mount propagation should return an error")
+	}
+
+	tr, srcClient, destClient := createTransfer(t, MountPropagation(corev1.MountPropagationHostToContainer))
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	clients := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), clients, client.InNamespace(testNamespace)); err != nil || len(clients.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	mounts := map[string]corev1.VolumeMount{}
+	for _, m := range server.Spec.Containers[0].VolumeMounts {
+		mounts["server/"+m.Name] = m
+	}
+	for _, m := range clients.Items[0].Spec.Containers[0].VolumeMounts {
+		mounts["client/"+m.Name] = m
+	}
+	for _, name := range []string{"server/" + tr.pvcList[0].Destination().LabelSafeName(), "client/mnt"} {
+		m, ok := mounts[name]
+		if !ok {
+			t.Fatalf("volume mount %s not found", name)
+		}
+		if m.MountPropagation == nil || *m.MountPropagation != corev1.MountPropagationHostToContainer {
+			t.Errorf("volume mount %s does not use HostToContainer propagation: %v", name, m.MountPropagation)
+		}
+	}
+	if m := mounts["server/"+defaultRsyncServerConfig]; m.MountPropagation != nil {
+		t.Errorf("mount propagation should only be set on PVC volume mounts")
+	}
+}
+
+func TestServerSelectorLabels(t *testing.T) {
+	srcClient := buildTestClient()
+	destClient := buildTestClient()
+	e := service.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		statetransfermeta.Labels, "", corev1.ServiceTypeClusterIP, nil)
+	selector := map[string]string{"crane.konveyor.io/server": testPVCName}
+	if err := endpoint.SetSelectorLabels(e, selector); err != nil {
+		t.Fatalf("unable to set selector labels: %v", err)
+	}
+	if err := e.Create(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create service endpoint: %v", err)
+	}
+	pvcList, err := transfer.NewFilesystemPVCPairList(
+		transfer.NewPVCPair(createPVC(testPVCName, testNamespace), nil),
+	)
+	if err != nil {
+		t.Fatalf("invalid pvc list: %v", err)
+	}
+	tr, err := NewTransfer(null.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+		types.NamespacedName{Namespace: testNamespace, Name: testPVCName},
+	), nil), e, srcClient, destClient, pvcList, klogr.New(), WithDestinationPodLabels(map[string]string{"team": "storage"}))
+	if err != nil {
+		t.Fatalf("NewTransfer should not return an error\n %v", err)
+	}
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+
+	svc := &corev1.Service{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, svc); err != nil {
+		t.Fatalf("unable to get service: %v", err)
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err != nil {
+		t.Fatalf("unable to get server pod: %v", err)
+	}
+	for k, v := range svc.Spec.Selector {
+		if server.Labels[k] != v {
+			t.Errorf("server pod labels %v do not match the service selector %v", server.Labels, svc.Spec.Selector)
+		}
+	}
+	if server.Labels["team"] != "storage" {
+		t.Errorf("server pod should keep the destination pod labels, got %v", server.Labels)
+	}
+	if _, ok := svc.Spec.Selector["app"]; ok {
+		t.Errorf("service should not select the resource labels, got %v", svc.Spec.Selector)
+	}
+}
+
+func TestRenderServerAndClient(t *testing.T) {
+	tr, srcClient, destClient := createTransfer(t)
+	objects, err := transfer.RenderServer(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("RenderServer() unexpected error %v", err)
+	}
+	kinds := map[string]bool{}
+	for _, obj := range objects {
+		kinds[obj.GetObjectKind().GroupVersionKind().Kind] = true
+	}
+	if !kinds["ConfigMap"] || !kinds["Secret"] || !kinds["Pod"] {
+		t.Errorf("RenderServer() should render the config, the credentials and the server pod, got %v", kinds)
+	}
+	pods := &corev1.PodList{}
+	if err := destClient.List(context.TODO(), pods); err != nil || len(pods.Items) != 0 {
+		t.Errorf("RenderServer() should not create the server pod, found %d pods: %v", len(pods.Items), err)
+	}
+
+	objects, err = transfer.RenderClient(context.TODO(), tr)
+	if err != nil {
+		t.Fatalf("RenderClient() unexpected error %v", err)
+	}
+	if len(objects) == 0 {
+		t.Errorf("RenderClient() should render the client pods")
+	}
+	if err := srcClient.List(context.TODO(), pods); err != nil || len(pods.Items) != 0 {
+		t.Errorf("RenderClient() should not create the client pods, found %d pods: %v", len(pods.Items), err)
+	}
+}
@@ -0,0 +1,330 @@
+package rsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/metrics"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Conditions returns the conditions of the transfer, see transfer.Conditions. Failed clients
+// report the rsync errors of their termination message in the Failed condition.
+func (r *RsyncTransfer) Conditions(ctx context.Context) ([]metav1.Condition, error) {
+	return transfer.Conditions(ctx, r)
+}
+
+// metricsID returns the ID the metrics of the transfer are labelled with, the correlation ID when set
+func (r *RsyncTransfer) metricsID() string {
+	return metrics.TransferID(r.options.correlationID, r.pvcList)
+}
+
+// Status returns the observed state of the transfer based on the rsync client pods
+func (r *RsyncTransfer) Status(ctx context.Context, c client.Client) (*transfer.Status, error) {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := transfer.WasCancelled(ctx, c, r.pvcList)
+	if err != nil {
+		return nil, err
+	}
+	all := pods
+	pods, attempts := latestClientPods(pods)
+	phase := transfer.PhaseFromPods(pods)
+	if cancelled {
+		phase = transfer.TransferPhaseCancelled
+	}
+	parallelism := r.options.parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	message := fmt.Sprintf("%d rsync client pod(s) found for %d pvc(s)", len(pods), len(r.pvcList))
+	vanished := 0
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == RsyncContainer && status.State.Terminated != nil {
+				vanished += vanishedFiles(status.State.Terminated.Message)
+			}
+		}
+	}
+	if vanished > 0 {
+		message = fmt.Sprintf("%s, %d source file(s) vanished during the transfer", message, vanished)
+	}
+	if start, end := clientPodsRunTime(pods, time.Now()); !start.IsZero() {
+		metrics.ObserveDuration(metricsTransferType, r.metricsID(), end.Sub(start))
+	}
+	return &transfer.Status{
+		Phase:         phase,
+		Message:       message,
+		Parallelism:   parallelism,
+		Attempts:      attempts,
+		VanishedFiles: vanished,
+		History:       r.attemptHistory(all),
+	}, nil
+}
+
+// attemptHistory returns every attempt of the rsync client of every PVC, by PVC and attempt number
+func (r *RsyncTransfer) attemptHistory(pods []corev1.Pod) []transfer.Attempt {
+	history := []transfer.Attempt{}
+	for _, pvc := range r.pvcList {
+		attempts := []transfer.Attempt{}
+		for i := range pods {
+			pod := &pods[i]
+			if !podTransfersPVC(pod, pvc) {
+				continue
+			}
+			attempt := transfer.Attempt{
+				PVC:    pvc.Source().Claim().Name,
+				Number: podAttempt(pod),
+				Phase:  pvcPhase(pod, pvc),
+			}
+			if pod.Status.StartTime != nil {
+				attempt.StartTime = pod.Status.StartTime.Time
+			}
+			if terminated := rsyncTerminated(pod); terminated != nil {
+				exitCode := terminated.ExitCode
+				attempt.ExitCode = &exitCode
+				attempt.FinishTime = terminated.FinishedAt.Time
+				attempt.Message = terminated.Message
+			}
+			attempts = append(attempts, attempt)
+		}
+		sort.SliceStable(attempts, func(i, j int) bool {
+			return attempts[i].Number < attempts[j].Number
+		})
+		history = append(history, attempts...)
+	}
+	return history
+}
+
+// CancelClient gracefully stops all rsync client pods of the transfer
+func (r *RsyncTransfer) CancelClient(ctx context.Context, c client.Client) error {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for i := range pods {
+		errs = append(errs, transfer.CancelPod(ctx, c, &pods[i]))
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// CancelServer gracefully stops the rsync server pod of the transfer
+func (r *RsyncTransfer) CancelServer(ctx context.Context, c client.Client) error {
+	if r.options.separateTransportServer {
+		return r.cancelSeparateServer(ctx, c)
+	}
+	pod := &corev1.Pod{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: r.pvcList.GetDestinationNamespaces()[0], Name: rsyncServerPodName}, pod)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return transfer.CancelPod(ctx, c, pod)
+}
+
+// listClientPods returns rsync client pods created for the PVCs of this transfer
+func (r *RsyncTransfer) listClientPods(ctx context.Context, c client.Client) ([]corev1.Pod, error) {
+	pvcNames := []string{}
+	for _, pvc := range r.pvcList {
+		pvcNames = append(pvcNames, pvc.Source().LabelSafeName())
+	}
+	if r.options.batchClients {
+		pvcNames = append(pvcNames, BatchPVCLabelValue)
+	}
+	selector := labels.SelectorFromSet(r.options.SourcePodMeta.Labels)
+	requirement, err := labels.NewRequirement(PVCLabel, selection.In, pvcNames)
+	if err != nil {
+		return nil, err
+	}
+	selector = selector.Add(*requirement)
+
+	podList := &corev1.PodList{}
+	err = c.List(ctx, podList,
+		client.InNamespace(r.pvcList.GetSourceNamespaces()[0]),
+		client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// PVCStatus returns the state of every PVC pair of the transfer from the latest rsync client pod of
+// each PVC. Like Progress, the estimated size of a PVC is counted as transferred once its client
+// pod succeeded.
+func (r *RsyncTransfer) PVCStatus(ctx context.Context, c client.Client) (map[transfer.PVCPair]transfer.PVCTransferState, error) {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cancelled, err := transfer.WasCancelled(ctx, c, r.pvcList)
+	if err != nil {
+		return nil, err
+	}
+	status := map[transfer.PVCPair]transfer.PVCTransferState{}
+	for _, pvc := range r.pvcList {
+		clientPod := latestClientPod(pods, pvc)
+		state := transfer.PVCTransferState{Phase: pvcPhase(clientPod, pvc)}
+		if cancelled && state.Phase != transfer.PVCTransferPhaseCompleted {
+			state.Phase = transfer.PVCTransferPhaseFailed
+		}
+		if state.Phase == transfer.PVCTransferPhaseCompleted {
+			state.BytesTransferred = transfer.EstimatePVCSize(pvc.Source())
+		}
+		status[pvc] = state
+	}
+	metrics.ObservePVCStatus(metricsTransferType, status)
+	return status, nil
+}
+
+// RetryPolicy returns the retries configured with RetryPolicy, failed clients are not retried
+// without one
+func (r *RsyncTransfer) RetryPolicy() transfer.RetryPolicy {
+	return r.options.retryPolicy
+}
+
+// RetryClient creates a new rsync client pod for every given PVC whose latest client pod failed, with
+// BatchClients a single client pod retries the PVCs a failed batched client did not transfer.
+// Failed pods are kept for troubleshooting, Status only considers the latest attempt of every
+// PVC. No client is retried and a transfer.TransferFailedError wrapping transfer.ErrNotRetriable
+// is returned when one of them exited with a code which is not retriable.
+func (r *RsyncTransfer) RetryClient(ctx context.Context, c client.Client, pvcList transfer.PVCPairList) error {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return err
+	}
+	latest, _ := latestClientPods(pods)
+	attempts := map[string]int{}
+	for _, pod := range latest {
+		attempts[pod.Labels[PVCLabel]] = podAttempt(&pod)
+	}
+	pvcs := transfer.PVCPairList{}
+	for _, pvc := range pvcList {
+		pod := latestClientPod(pods, pvc)
+		if pod == nil || pod.Status.Phase != corev1.PodFailed || pvcPhase(pod, pvc) != transfer.PVCTransferPhaseFailed {
+			continue
+		}
+		if terminated := rsyncTerminated(pod); terminated != nil && !r.retriable(terminated.ExitCode) {
+			exitCode := terminated.ExitCode
+			return &transfer.TransferFailedError{
+				PVC:      pvc.Source().Claim().Name,
+				ExitCode: &exitCode,
+				Attempts: podAttempt(pod),
+				Message:  fmt.Sprintf("rsync client of pvc %s exited with code %d", pvc.Source().Claim().Name, exitCode),
+				Err:      transfer.ErrNotRetriable,
+			}
+		}
+		pvcs = append(pvcs, pvc)
+	}
+	if len(pvcs) == 0 {
+		return nil
+	}
+	log := r.Log.WithValues("namespace", r.pvcList.GetSourceNamespaces()[0])
+	names := []string{}
+	for _, pvc := range pvcs {
+		names = append(names, pvc.Source().Claim().Name)
+	}
+	log.Info("retrying failed rsync clients", "pvcs", names)
+	if err := createRsyncClient(ctx, meta.NewLoggingClient(metadataClient(c, r.options.SourcePodMeta), log), r, pvcs, attempts); err != nil {
+		return err
+	}
+	metrics.ObserveRetry(metricsTransferType, r.metricsID())
+	return nil
+}
+
+// retriable returns whether a client exiting with the given code is retried by the RetryPolicy
+func (r *RsyncTransfer) retriable(exitCode int32) bool {
+	if len(r.options.retriableExitCodes) == 0 {
+		return true
+	}
+	for _, code := range r.options.retriableExitCodes {
+		if int32(code) == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// rsyncTerminated returns the terminated state of the rsync container of the given pod, nil while
+// it did not terminate
+func rsyncTerminated(pod *corev1.Pod) *corev1.ContainerStateTerminated {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == RsyncContainer {
+			return status.State.Terminated
+		}
+	}
+	return nil
+}
+
+// clientPodsRunTime returns when the first of the given client pods started and when the last one
+// finished, now while one of them is still running. The start time is zero when none started yet.
+func clientPodsRunTime(pods []corev1.Pod, now time.Time) (time.Time, time.Time) {
+	var start, end time.Time
+	for _, pod := range pods {
+		if pod.Status.StartTime == nil {
+			continue
+		}
+		if start.IsZero() || pod.Status.StartTime.Time.Before(start) {
+			start = pod.Status.StartTime.Time
+		}
+		finished := now
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == RsyncContainer && status.State.Terminated != nil {
+				finished = status.State.Terminated.FinishedAt.Time
+			}
+		}
+		if finished.After(end) {
+			end = finished
+		}
+	}
+	return start, end
+}
+
+// latestClientPods returns the client pod of the latest attempt of every PVC and the highest
+// attempt number, pods created before attempts were recorded count as a first attempt
+func latestClientPods(pods []corev1.Pod) ([]corev1.Pod, int) {
+	latest := map[string]corev1.Pod{}
+	order := []string{}
+	maxAttempt := 0
+	for _, pod := range pods {
+		pvc := pod.Labels[PVCLabel]
+		previous, ok := latest[pvc]
+		if !ok {
+			order = append(order, pvc)
+		}
+		if !ok || podAttempt(&pod) > podAttempt(&previous) {
+			latest[pvc] = pod
+		}
+		if attempt := podAttempt(&pod); attempt > maxAttempt {
+			maxAttempt = attempt
+		}
+	}
+	result := []corev1.Pod{}
+	for _, pvc := range order {
+		result = append(result, latest[pvc])
+	}
+	return result, maxAttempt
+}
+
+func podAttempt(pod *corev1.Pod) int {
+	attempt, err := strconv.Atoi(pod.Annotations[transfer.AttemptAnnotation])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
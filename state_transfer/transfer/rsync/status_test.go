@@ -0,0 +1,517 @@
+package rsync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/metrics"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCancel(t *testing.T) {
+	tr, srcClient, destClient := createTransfer(t, GetRsyncCommandDefaultOptions()...)
+	if err := srcClient.Create(context.TODO(), createPVC(testPVCName, testNamespace)); err != nil {
+		t.Fatalf("unable to create source pvc: %v", err)
+	}
+	if err := tr.CreateServer(context.TODO(), destClient); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	status, err := tr.Status(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get status: %v", err)
+	}
+	if status.Phase != transfer.TransferPhasePending {
+		t.Fatalf("expected phase %s, got %s", transfer.TransferPhasePending, status.Phase)
+	}
+
+	if err := transfer.Cancel(context.TODO(), tr, true); err != nil {
+		t.Fatalf("unable to cancel transfer: %v", err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil {
+		t.Fatalf("unable to list client pods: %v", err)
+	}
+	if len(pods.Items) != 0 {
+		t.Fatalf("client pods should be deleted after cancel, found %d", len(pods.Items))
+	}
+	server := &corev1.Pod{}
+	if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: rsyncServerPodName}, server); err == nil {
+		t.Fatalf("server pod should be deleted after cancel")
+	}
+
+	// the cancellation outlives the pods, a transfer built again reports it until its client is created
+	claim := &corev1.PersistentVolumeClaim{}
+	if err := srcClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: testPVCName}, claim); err != nil {
+		t.Fatalf("unable to get source pvc: %v", err)
+	}
+	if _, ok := claim.Annotations[transfer.CancelledAnnotation]; ok {
+		t.Fatalf("the source pvc should not be marked as cancelled")
+	}
+	original := tr
+	tr, _, _ = createTransfer(t, GetRsyncCommandDefaultOptions()...)
+	status, err = tr.Status(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get status: %v", err)
+	}
+	if status.Phase != transfer.TransferPhaseCancelled {
+		t.Fatalf("expected phase %s, got %s", transfer.TransferPhaseCancelled, status.Phase)
+	}
+	pvcStatus, err := tr.PVCStatus(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get pvc status: %v", err)
+	}
+	if phase := pvcStatus[tr.PVCs()[0]].Phase; phase != transfer.PVCTransferPhaseFailed {
+		t.Fatalf("expected pvc phase %s, got %s", transfer.PVCTransferPhaseFailed, phase)
+	}
+
+	// a transfer created again is not cancelled
+	recreated, _, _ := createTransfer(t, GetRsyncCommandDefaultOptions()...)
+	if err := recreated.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	status, err = recreated.Status(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get status: %v", err)
+	}
+	if status.Phase == transfer.TransferPhaseCancelled {
+		t.Fatalf("a transfer whose client is created again should not be cancelled")
+	}
+
+	// so is a finalized transfer
+	if err := transfer.Cancel(context.TODO(), original, false); err != nil {
+		t.Fatalf("unable to cancel transfer: %v", err)
+	}
+	if cancelled, err := transfer.WasCancelled(context.TODO(), srcClient, original.PVCs()); err != nil || !cancelled {
+		t.Fatalf("the transfer should be cancelled, got %t, %v", cancelled, err)
+	}
+	if err := transfer.Finalize(context.TODO(), srcClient, original); err != nil {
+		t.Fatalf("unable to finalize transfer: %v", err)
+	}
+	if cancelled, err := transfer.WasCancelled(context.TODO(), srcClient, original.PVCs()); err != nil || cancelled {
+		t.Fatalf("a finalized transfer should not be cancelled, got %t, %v", cancelled, err)
+	}
+
+	if err := transfer.Cancel(context.TODO(), original, true); err != nil {
+		t.Fatalf("unable to cancel transfer: %v", err)
+	}
+	if err := tr.PrepareSync(context.TODO(), srcClient, transfer.SyncModeIncremental); err != nil {
+		t.Fatalf("unable to prepare sync: %v", err)
+	}
+	status, err = tr.Status(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get status: %v", err)
+	}
+	if status.Phase == transfer.TransferPhaseCancelled {
+		t.Fatalf("a sync should clear the cancellation")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, GetRsyncCommandDefaultOptions()...)
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	description := transfer.Describe(tr)
+	for _, want := range []string{
+		"Transfer:   RsyncTransfer\n",
+		"Transport:  null\n",
+		"Endpoint:   RouteEndpoint test-namespace/test-route\n",
+		"  test-namespace/test-pvc -> test-namespace/test-pvc (unknown size)\n",
+		"Status:     Pending (1 rsync client pod(s) found for 1 pvc(s))\n",
+	} {
+		if !strings.Contains(description, want) {
+			t.Errorf("description does not contain %q\n%s", want, description)
+		}
+	}
+
+	// the description degrades gracefully when the cluster cannot be queried
+	tr.source = fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	description = transfer.Describe(tr)
+	if !strings.Contains(description, "Status:     unavailable (") {
+		t.Errorf("description should report the status as unavailable\n%s", description)
+	}
+}
+
+func TestRunClientWithRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []TransferOption
+		failAttempts int
+		exitCode     int32
+		wantPhase    transfer.TransferPhase
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "succeeds after a retry",
+			options:      []TransferOption{RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, RetriableExitCodes: []int{1}}},
+			failAttempts: 1,
+			exitCode:     1,
+			wantPhase:    transfer.TransferPhaseSucceeded,
+			wantAttempts: 2,
+		},
+		{
+			name:         "retries exhausted",
+			options:      []TransferOption{RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, RetriableExitCodes: []int{1}}},
+			failAttempts: 5,
+			exitCode:     1,
+			wantPhase:    transfer.TransferPhaseFailed,
+			wantAttempts: 3,
+			wantErr:      transfer.ErrRetriesExhausted,
+		},
+		{
+			name:         "max retries retry a connection reset",
+			options:      []TransferOption{MaxRetries(1), RetryBackoff(time.Millisecond)},
+			failAttempts: 1,
+			exitCode:     rsyncExitProtocolStream,
+			wantPhase:    transfer.TransferPhaseSucceeded,
+			wantAttempts: 2,
+		},
+		{
+			name:         "policy retries a connection reset",
+			options:      []TransferOption{RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}},
+			failAttempts: 2,
+			exitCode:     rsyncExitProtocolStream,
+			wantPhase:    transfer.TransferPhaseSucceeded,
+			wantAttempts: 3,
+		},
+		{
+			name:         "policy does not retry other exit codes",
+			options:      []TransferOption{RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}},
+			failAttempts: 2,
+			exitCode:     23,
+			wantPhase:    transfer.TransferPhaseFailed,
+			wantAttempts: 1,
+			wantErr:      transfer.ErrNotRetriable,
+		},
+		{
+			name:         "policy retries the given exit codes",
+			options:      []TransferOption{RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, RetriableExitCodes: []int{23}}},
+			failAttempts: 1,
+			exitCode:     23,
+			wantPhase:    transfer.TransferPhaseSucceeded,
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, srcClient, _ := createTransfer(t, tt.options...)
+			if !tr.options.Partial {
+				t.Errorf("retries should resume partially transferred files")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			go completeClientPods(ctx, srcClient, tt.failAttempts, tt.exitCode)
+
+			status, err := transfer.RunClientWithRetries(ctx, tr, 10*time.Millisecond)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("RunClientWithRetries() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunClientWithRetries() error = %v, want %v", err, tt.wantErr)
+			}
+			failed := &transfer.TransferFailedError{}
+			if tt.wantErr != nil && (!errors.Is(err, transfer.ErrTransferFailed) || !errors.As(err, &failed)) {
+				t.Fatalf("RunClientWithRetries() error = %v, want a TransferFailedError", err)
+			}
+			if tt.wantErr != nil && (failed.PVC != testPVCName || failed.ExitCode == nil || *failed.ExitCode != tt.exitCode) {
+				t.Errorf("TransferFailedError reports pvc %q and exit code %v, want %q and %d", failed.PVC, failed.ExitCode, testPVCName, tt.exitCode)
+			}
+			if status.Phase != tt.wantPhase || status.Attempts != tt.wantAttempts {
+				t.Errorf("RunClientWithRetries() phase %s after %d attempt(s), want %s after %d", status.Phase, status.Attempts, tt.wantPhase, tt.wantAttempts)
+			}
+			if len(status.History) != tt.wantAttempts {
+				t.Fatalf("expected %d attempt(s) in the history, got %v", tt.wantAttempts, status.History)
+			}
+			for i, attempt := range status.History {
+				if attempt.PVC != testPVCName || attempt.Number != i+1 || attempt.ExitCode == nil {
+					t.Errorf("unexpected attempt %d in the history %+v", i+1, attempt)
+					continue
+				}
+				wantExitCode := int32(0)
+				if attempt.Number <= tt.failAttempts {
+					wantExitCode = tt.exitCode
+				}
+				if *attempt.ExitCode != wantExitCode {
+					t.Errorf("attempt %d exited with %d, want %d", attempt.Number, *attempt.ExitCode, wantExitCode)
+				}
+			}
+		})
+	}
+}
+
+func TestTolerateVanishedFiles(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, TolerateVanishedFiles(true))
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find the client pod: %v", err)
+	}
+	pod := &pods.Items[0]
+	script := pod.Spec.Containers[0].Command[2]
+	for _, want := range []string{
+		"grep -c 'file has vanished: ' /tmp/rsync-errors 2>/dev/null | sed 's/^/vanished files: /' >> /dev/termination-log",
+		"if [ $rc -eq 24 ] ||",
+		"rc=0; fi; exit $rc;",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("client script does not contain %q\n%s", want, script)
+		}
+	}
+
+	pod.Status.Phase = corev1.PodSucceeded
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: RsyncContainer,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			Message: "rsync: [sender] send_files failed to open \"/mnt/f\": Permission denied (13)\nvanished files: 3\n",
+		}},
+	}}
+	if err := srcClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+	status, err := tr.Status(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("unable to get status: %v", err)
+	}
+	if status.Phase != transfer.TransferPhaseSucceeded || status.VanishedFiles != 3 {
+		t.Errorf("expected phase %s with 3 vanished files, got %s with %d", transfer.TransferPhaseSucceeded, status.Phase, status.VanishedFiles)
+	}
+	if !strings.Contains(status.Message, "3 source file(s) vanished") {
+		t.Errorf("status message does not report vanished files: %s", status.Message)
+	}
+
+	tr, srcClient, _ = createTransfer(t)
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find the client pod: %v", err)
+	}
+	if strings.Contains(pods.Items[0].Spec.Containers[0].Command[2], "vanished") {
+		t.Errorf("vanished files should only be tolerated when enabled")
+	}
+}
+
+// completeClientPods simulates client pods completing, pods of the first failAttempts attempts fail
+// with the given exit code
+func completeClientPods(ctx context.Context, c client.Client, failAttempts int, exitCode int32) {
+	for ctx.Err() == nil {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(testNamespace)); err == nil {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != "" {
+					continue
+				}
+				pod.Status.Phase = corev1.PodSucceeded
+				terminated := &corev1.ContainerStateTerminated{}
+				if podAttempt(pod) <= failAttempts {
+					pod.Status.Phase = corev1.PodFailed
+					terminated.ExitCode = exitCode
+				}
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:  RsyncContainer,
+					State: corev1.ContainerState{Terminated: terminated},
+				}}
+				_ = c.Update(ctx, pod)
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPVCStatus(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t)
+	pairs := transfer.PVCPairList{}
+	for _, name := range []string{"completed", "failed", "pending"} {
+		pvc := createPVC(name, testNamespace)
+		pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}
+		pairs = append(pairs, transfer.NewPVCPair(pvc, nil))
+	}
+	tr.pvcList = pairs
+	if err := createRsyncClient(context.TODO(), srcClient, tr, pairs[:2], map[string]int{}); err != nil {
+		t.Fatalf("unable to create client pods: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 2 {
+		t.Fatalf("unable to find the client pods: %v", err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		pod.Status.Phase = corev1.PodFailed
+		if pod.Labels[PVCLabel] == pairs[0].Source().LabelSafeName() {
+			pod.Status.Phase = corev1.PodSucceeded
+		}
+		if err := srcClient.Update(context.TODO(), pod); err != nil {
+			t.Fatalf("unable to update client pod: %v", err)
+		}
+	}
+
+	status, err := transfer.PVCStatus(context.TODO(), tr, srcClient)
+	if err != nil {
+		t.Fatalf("PVCStatus() unexpected error %v", err)
+	}
+	want := map[transfer.PVCPair]transfer.PVCTransferState{
+		pairs[0]: {Phase: transfer.PVCTransferPhaseCompleted, BytesTransferred: 1024 * 1024 * 1024},
+		pairs[1]: {Phase: transfer.PVCTransferPhaseFailed},
+		pairs[2]: {Phase: transfer.PVCTransferPhasePending},
+	}
+	if len(status) != len(want) {
+		t.Fatalf("PVCStatus() returned %d pvc(s), want %d", len(status), len(want))
+	}
+	for pvc, state := range want {
+		if status[pvc] != state {
+			t.Errorf("pvc %s state %+v, want %+v", pvc.Source().Claim().Name, status[pvc], state)
+		}
+		if state.Phase == transfer.PVCTransferPhaseCompleted {
+			continue
+		}
+		if got := testutil.ToFloat64(metrics.PVCStatus.WithLabelValues(metricsTransferType, testNamespace, pvc.Source().Claim().Name, string(state.Phase))); got != 1 {
+			t.Errorf("pvc %s status metric for phase %s is %v, want 1", pvc.Source().Claim().Name, state.Phase, got)
+		}
+	}
+}
+
+func TestClientPodsRunTime(t *testing.T) {
+	now := time.Now()
+	started := func(minutes int) *metav1.Time {
+		start := metav1.NewTime(now.Add(time.Duration(-minutes) * time.Minute))
+		return &start
+	}
+	finished := corev1.Pod{Status: corev1.PodStatus{
+		StartTime: started(30),
+		ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  RsyncContainer,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: *started(20)}},
+		}},
+	}}
+	running := corev1.Pod{Status: corev1.PodStatus{StartTime: started(10)}}
+
+	if start, _ := clientPodsRunTime([]corev1.Pod{{}}, now); !start.IsZero() {
+		t.Errorf("start time should be zero before a pod started, got %s", start)
+	}
+	if start, end := clientPodsRunTime([]corev1.Pod{finished}, now); end.Sub(start) != 10*time.Minute {
+		t.Errorf("completed pods ran for %s, want 10m", end.Sub(start))
+	}
+	if start, end := clientPodsRunTime([]corev1.Pod{finished, running}, now); end.Sub(start) != 30*time.Minute {
+		t.Errorf("running pods ran for %s, want 30m", end.Sub(start))
+	}
+}
+
+func TestUpdateReadinessGates(t *testing.T) {
+	tr, srcClient, destClient := createTransfer(t)
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	for _, name := range []string{"gated", "ungated"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: map[string]string{"app": "downstream"}},
+		}
+		if name == "gated" {
+			pod.Spec.ReadinessGates = []corev1.PodReadinessGate{transfer.ReadinessGate()}
+		}
+		if err := destClient.Create(context.TODO(), pod); err != nil {
+			t.Fatalf("unable to create downstream pod: %v", err)
+		}
+	}
+	condition := func(name string) *corev1.PodCondition {
+		pod := &corev1.Pod{}
+		if err := destClient.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: name}, pod); err != nil {
+			t.Fatalf("unable to get downstream pod: %v", err)
+		}
+		for i := range pod.Status.Conditions {
+			if pod.Status.Conditions[i].Type == transfer.TransferCompleteCondition {
+				return &pod.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	complete, err := transfer.UpdateReadinessGates(context.TODO(), tr, map[string]string{"app": "downstream"})
+	if err != nil || complete {
+		t.Fatalf("UpdateReadinessGates() = %v, %v, want an incomplete transfer", complete, err)
+	}
+	if c := condition("gated"); c == nil || c.Status != corev1.ConditionFalse {
+		t.Errorf("readiness gate should be false while the transfer runs, got %v", c)
+	}
+
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find the client pod: %v", err)
+	}
+	pods.Items[0].Status.Phase = corev1.PodSucceeded
+	if err := srcClient.Update(context.TODO(), &pods.Items[0]); err != nil {
+		t.Fatalf("unable to update client pod: %v", err)
+	}
+	complete, err = transfer.UpdateReadinessGates(context.TODO(), tr, map[string]string{"app": "downstream"})
+	if err != nil || !complete {
+		t.Fatalf("UpdateReadinessGates() = %v, %v, want a complete transfer", complete, err)
+	}
+	if c := condition("gated"); c == nil || c.Status != corev1.ConditionTrue {
+		t.Errorf("readiness gate should be true once the transfer succeeded, got %v", c)
+	}
+	if c := condition("ungated"); c != nil {
+		t.Errorf("pods without the readiness gate should not be updated, got %v", c)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t)
+	conditions, err := tr.Conditions(context.TODO())
+	if err != nil {
+		t.Fatalf("Conditions() unexpected error %v", err)
+	}
+	want := map[string]metav1.ConditionStatus{
+		transfer.ConditionServerReady:   metav1.ConditionFalse,
+		transfer.ConditionClientRunning: metav1.ConditionFalse,
+		transfer.ConditionCompleted:     metav1.ConditionFalse,
+		transfer.ConditionFailed:        metav1.ConditionFalse,
+		transfer.ConditionStalled:       metav1.ConditionFalse,
+	}
+	checkConditions(t, conditions, want)
+	if conditions[0].Reason != "EndpointNotReady" {
+		t.Errorf("the route of the server is not admitted, got reason %s", conditions[0].Reason)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	go completeClientPods(ctx, srcClient, 0, 0)
+	if _, err := transfer.RunClientWithRetries(ctx, tr, 10*time.Millisecond); err != nil {
+		t.Fatalf("RunClientWithRetries() unexpected error %v", err)
+	}
+	conditions, err = tr.Conditions(context.TODO())
+	if err != nil {
+		t.Fatalf("Conditions() unexpected error %v", err)
+	}
+	want[transfer.ConditionCompleted] = metav1.ConditionTrue
+	checkConditions(t, conditions, want)
+}
+
+func checkConditions(t *testing.T, conditions []metav1.Condition, want map[string]metav1.ConditionStatus) {
+	t.Helper()
+	if len(conditions) != len(want) {
+		t.Fatalf("expected %d conditions, got %v", len(want), conditions)
+	}
+	for _, condition := range conditions {
+		if condition.Status != want[condition.Type] {
+			t.Errorf("condition %s is %s (%s), want %s", condition.Type, condition.Status, condition.Reason, want[condition.Type])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package rsync
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// skippedOversizedSuffix is logged by rsync for files skipped because of MaxFileSize
+	skippedOversizedSuffix = " is over max-size"
+	// rsyncErrorsFile is where the rsync client keeps a copy of its stderr
+	rsyncErrorsFile = "/tmp/rsync-errors"
+	// rsyncErrorsToTerminationMessage copies the last rsync errors to the termination message
+	rsyncErrorsToTerminationMessage = "grep '^rsync: ' %s 2>/dev/null | tail -c %d > /dev/termination-log"
+	// maxTerminationMessageBytes is the maximum size of a container termination message
+	maxTerminationMessageBytes = 4096
+	// vanishedFileMarker is logged by rsync for source files deleted during the transfer
+	vanishedFileMarker = "file has vanished: "
+	// vanishedFilesToTerminationMessage appends the number of vanished files to the termination
+	// message, the kubelet keeps the end of messages exceeding maxTerminationMessageBytes
+	vanishedFilesToTerminationMessage = "grep -c '%s' %s 2>/dev/null | sed 's/^/%s/' >> /dev/termination-log"
+	// vanishedFilesPrefix prefixes the number of vanished files in the termination message
+	vanishedFilesPrefix = "vanished files: "
+	// tolerateVanishedFilesExit makes the client succeed when rsync only failed because of vanished
+	// files. With parallel streams, xargs exits with 123 rather than the exit code of rsync, the exit
+	// codes logged by rsync are checked instead.
+	tolerateVanishedFilesExit = "if [ $rc -eq %d ] || { [ $rc -eq 123 ] && grep -q '(code %d)' %s && ! grep '(code [0-9]*)' %s | grep -qv '(code %d)'; }; then echo 'some files vanished before they could be transferred' >&2; rc=0; fi; "
+)
+
+var (
+	// logFilePrefix matches the timestamp and pid rsync prefixes lines written to its log file with
+	logFilePrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} \[\d+\] `)
+	// fileErrorLine matches rsync errors about a single file, for example:
+	// rsync: [sender] send_files failed to open "/mnt/ns/pvc/file": Permission denied (13)
+	// rsync: opendir "/mnt/ns/pvc/dir" failed: Permission denied (13)
+	fileErrorLine = regexp.MustCompile(`^rsync: .*?"([^"]+)".*: (.+)$`)
+	// vanishedFilesLine matches the number of vanished files in a termination message
+	vanishedFilesLine = regexp.MustCompile(`^` + vanishedFilesPrefix + `(\d+)$`)
+)
+
+// SkippedFiles returns the files rsync skipped because they exceeded MaxFileSize, given the
+// logs of an rsync client container. Paths are relative to the root of the transferred volume.
+func SkippedFiles(logs io.Reader) ([]string, error) {
+	skipped := []string{}
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		line := logFilePrefix.ReplaceAllString(scanner.Text(), "")
+		if strings.HasSuffix(line, skippedOversizedSuffix) {
+			skipped = append(skipped, strings.TrimSuffix(line, skippedOversizedSuffix))
+		}
+	}
+	return skipped, scanner.Err()
+}
+
+// FailedFiles returns the files the rsync clients failed to copy. The errors are read from the
+// termination messages of the rsync client containers, which are limited in size, only the last
+// errors are reported for a PVC with many failures.
+func (r *RsyncTransfer) FailedFiles(ctx context.Context, c client.Client) ([]transfer.FileError, error) {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	failed := []transfer.FileError{}
+	for _, pvc := range r.pvcList {
+		for _, pod := range pods {
+			if !podTransfersPVC(&pod, pvc) {
+				continue
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name != RsyncContainer || status.State.Terminated == nil {
+					continue
+				}
+				message := status.State.Terminated.Message
+				if pod.Labels[PVCLabel] == BatchPVCLabelValue {
+					// a batched client reports the errors of all its PVCs
+					message = batchedFileErrors(message, getMountPathForPVC(pvc.Source()))
+				}
+				failed = append(failed, parseFileErrors(
+					strings.NewReader(message),
+					types.NamespacedName{Namespace: pvc.Source().Claim().Namespace, Name: pvc.Source().Claim().Name},
+					getMountPathForPVC(pvc.Source()))...)
+			}
+		}
+	}
+	return failed, nil
+}
+
+// parseFileErrors returns per file errors found in rsync's stderr, paths are made relative to mountPath
+func parseFileErrors(stderr io.Reader, pvc types.NamespacedName, mountPath string) []transfer.FileError {
+	failed := []transfer.FileError{}
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		match := fileErrorLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		path := strings.TrimPrefix(strings.TrimPrefix(match[1], mountPath), "/")
+		if path == "" {
+			path = "."
+		}
+		failed = append(failed, transfer.FileError{
+			PVC:    pvc,
+			Path:   path,
+			Reason: match[2],
+		})
+	}
+	return failed
+}
+
+// vanishedFiles returns the number of vanished files reported in the termination message of an
+// rsync client container
+func vanishedFiles(message string) int {
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(message))
+	for scanner.Scan() {
+		match := vanishedFilesLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		n, err := strconv.Atoi(match[1])
+		if err == nil {
+			count = n
+		}
+	}
+	return count
+}
@@ -0,0 +1,58 @@
+package rsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFailedFiles(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t)
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find rsync client pod: %v", err)
+	}
+	mountPath := getMountPathForPVC(tr.PVCs()[0].Source())
+	pod := &pods.Items[0]
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: RsyncContainer,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 23,
+					Message: `rsync: [sender] send_files failed to open "` + mountPath + `/secret.key": Permission denied (13)
+rsync: opendir "` + mountPath + `/private" failed: Permission denied (13)
+rsync error: some files/attrs were not transferred (see previous errors) (code 23)`,
+				},
+			},
+		},
+	}
+	if err := srcClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update rsync client pod: %v", err)
+	}
+
+	failed, err := tr.FailedFiles(context.TODO(), srcClient)
+	if err != nil {
+		t.Fatalf("FailedFiles() unexpected error %v", err)
+	}
+	pvc := types.NamespacedName{Namespace: testNamespace, Name: testPVCName}
+	want := []transfer.FileError{
+		{PVC: pvc, Path: "secret.key", Reason: "Permission denied (13)"},
+		{PVC: pvc, Path: "private", Reason: "Permission denied (13)"},
+	}
+	if len(failed) != len(want) {
+		t.Fatalf("FailedFiles() = %v, want %v", failed, want)
+	}
+	for i := range want {
+		if failed[i] != want[i] {
+			t.Errorf("FailedFiles()[%d] = %s, want %s", i, failed[i], want[i])
+		}
+	}
+}
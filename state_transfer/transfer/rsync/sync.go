@@ -0,0 +1,63 @@
+package rsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	syncPollInterval = 2 * time.Second
+)
+
+// PrepareSync deletes the rsync client pods of the previous sync so that transfer.Sync creates new
+// ones. rsync only copies the files which differ from the destination, so every sync after the
+// first one is a delta sync. A final sync deletes the destination files which no longer exist on
+// the source, like DeleteDestination. The clients of the previous sync must have completed, or
+// have been cancelled, the cancellation is then cleared.
+func (r *RsyncTransfer) PrepareSync(ctx context.Context, c client.Client, mode transfer.SyncMode) error {
+	pods, err := r.listClientPods(ctx, c)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			return fmt.Errorf("rsync client pod %s of the previous sync is still %s", pod.Name, pod.Status.Phase)
+		}
+		err := c.Delete(ctx, pod, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return err
+	}
+	// Status would otherwise report the phase of the previous sync
+	err = wait.PollImmediateUntil(syncPollInterval, func() (bool, error) {
+		pods, err := r.listClientPods(ctx, c)
+		return len(pods) == 0, err
+	}, ctx.Done())
+	if err != nil {
+		return err
+	}
+	// a cancelled transfer is synced again from where it stopped
+	if err := transfer.ClearCancelled(ctx, c, r.pvcList); err != nil {
+		return err
+	}
+	r.syncMode = mode
+	r.Log.Info("prepared sync", "namespace", r.pvcList.GetSourceNamespaces()[0], "mode", mode, "deletedPods", len(pods))
+	return nil
+}
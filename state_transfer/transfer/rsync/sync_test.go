@@ -0,0 +1,60 @@
+package rsync
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSync(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	go completeClientPods(ctx, srcClient, 0, 0)
+
+	quiesced := false
+	options := transfer.SyncOptions{
+		Quiesce: func(ctx context.Context) error {
+			quiesced = true
+			return nil
+		},
+		Interval: 10 * time.Millisecond,
+	}
+	for _, mode := range []transfer.SyncMode{transfer.SyncModeIncremental, transfer.SyncModeIncremental, transfer.SyncModeFinal} {
+		options.Mode = mode
+		status, err := transfer.Sync(ctx, tr, options)
+		if err != nil {
+			t.Fatalf("%s sync failed: %v", mode, err)
+		}
+		if status.Phase != transfer.TransferPhaseSucceeded {
+			t.Fatalf("%s sync ended in phase %s", mode, status.Phase)
+		}
+		if quiesced != (mode == transfer.SyncModeFinal) {
+			t.Errorf("the source should only be quiesced before the final sync")
+		}
+		pods := &corev1.PodList{}
+		if err := srcClient.List(ctx, pods, client.InNamespace(testNamespace)); err != nil {
+			t.Fatalf("unable to list client pods: %v", err)
+		}
+		if len(pods.Items) != 1 {
+			t.Fatalf("expected the client pods of previous syncs to be deleted, found %d pods", len(pods.Items))
+		}
+		deletes := strings.Contains(pods.Items[0].Spec.Containers[0].Command[2], "--delete")
+		if deletes != (mode == transfer.SyncModeFinal) {
+			t.Errorf("only the final sync should delete extraneous destination files, %s sync does: %t", mode, deletes)
+		}
+	}
+
+	tr, srcClient, _ = createTransfer(t)
+	if err := tr.CreateClient(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if err := tr.PrepareSync(context.TODO(), srcClient, transfer.SyncModeIncremental); err == nil {
+		t.Errorf("a sync should not start while clients of the previous one are running")
+	}
+}
@@ -0,0 +1,258 @@
+package rsync
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// VerifyPVCLabel is the label set on rsync verification pods to identify the PVC they verify,
+	// verification pods are not client pods and do not count in the status of the transfer
+	VerifyPVCLabel = "verify-pvc"
+	// rsyncVerifyOutputFile is where the verification pod keeps the output of rsync
+	rsyncVerifyOutputFile = "/tmp/rsync-verify"
+	// mismatchesToTerminationMessage copies the number of mismatched files and the last itemized
+	// changes which fit in the termination message, whole lines only
+	mismatchesToTerminationMessage = "grep -E '^(\\*deleting|[<>ch.][fdLDS])' %s > %s.changes; echo \"%s$(wc -l < %s.changes)\" > /dev/termination-log; tac %s.changes | awk '{n+=length($0)+1; if (n>%d) exit; print}' | tac >> /dev/termination-log"
+	// mismatchedFilesPrefix prefixes the number of mismatched files in the termination message
+	mismatchedFilesPrefix = "mismatched files: "
+)
+
+var (
+	// itemizedChangeLine matches the changes itemized by rsync, for example:
+	// >fc.t...... dir/file
+	// *deleting   dir/extra
+	itemizedChangeLine = regexp.MustCompile(`^(\*deleting|[<>ch.][fdLDS][^ ]{9}) +(.+)$`)
+	// mismatchedFilesLine matches the number of mismatched files in a termination message
+	mismatchedFilesLine = regexp.MustCompile(`^` + mismatchedFilesPrefix + `(\d+)$`)
+)
+
+// CreateVerifier creates a verification pod for every filesystem PVC of the transfer. It runs the
+// rsync command of the client with --checksum and --dry-run, so that rsync compares the checksum
+// of every file with the destination without changing it and itemizes the files which differ.
+// The rsync server must still be running, and with SnapshotSource the clones which were copied
+// must not be deleted yet. Verification pods of previous verifications are deleted first.
+func (r *RsyncTransfer) CreateVerifier(ctx context.Context, c client.Client) error {
+	if err := r.DeleteVerifier(ctx, c); err != nil {
+		return err
+	}
+	sourceNs := r.pvcList.GetSourceNamespaces()[0]
+	log := r.Log.WithValues("namespace", sourceNs)
+	c = meta.NewLoggingClient(metadataClient(c, r.options.SourcePodMeta), log)
+
+	errs := []error{createRsyncClientResources(ctx, c, r, sourceNs)}
+	rsyncOptions, err := r.verifyOptions()
+	if err != nil {
+		return err
+	}
+	for _, pvc := range r.pvcList.InSourceNamespace(sourceNs) {
+		if !isFileSystemPVC(pvc) {
+			continue
+		}
+		script := fmt.Sprintf(
+			"trap \"touch /usr/share/rsync/rsync-client-container-done\" EXIT SIGINT SIGTERM; timeout=120; SECONDS=0; while [ $SECONDS -lt $timeout ]; do nc -z localhost %d; rc=$?; if [ $rc -eq 0 ]; then %s > %s 2> %s; rc=$?; break; fi; done; if [ $rc -eq 0 ]; then %s; else %s; fi; exit $rc;",
+			r.Transport().Port(),
+			r.getRsyncCommand(pvc, rsyncOptions),
+			rsyncVerifyOutputFile,
+			rsyncErrorsFile,
+			fmt.Sprintf(mismatchesToTerminationMessage, rsyncVerifyOutputFile, rsyncVerifyOutputFile, mismatchedFilesPrefix,
+				rsyncVerifyOutputFile, rsyncVerifyOutputFile, maxTerminationMessageBytes-len(mismatchedFilesPrefix)-16),
+			fmt.Sprintf(rsyncErrorsToTerminationMessage, rsyncErrorsFile, maxTerminationMessageBytes))
+		mounts := []v1.VolumeMount{
+			{
+				Name:             "mnt",
+				MountPath:        getMountPathForPVC(pvc.Source()),
+				MountPropagation: r.options.mountPropagation,
+			},
+		}
+		volumes := []v1.Volume{
+			{
+				Name: "mnt",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+						ClaimName: r.sourceClaimName(pvc),
+						ReadOnly:  true,
+					},
+				},
+			},
+		}
+		pod := r.newRsyncClientPod(pvc.Source().Claim().Namespace,
+			verifyPodLabels(r.options.SourcePodMeta.Labels, pvc), nil, 1, script, mounts, volumes)
+		errs = append(errs, c.Create(ctx, &pod, &client.CreateOptions{}))
+	}
+
+	err = errorsutil.NewAggregate(errs)
+	if err != nil {
+		log.Error(err, "unable to create rsync verification pods")
+	}
+	return err
+}
+
+// verifyOptions returns the rsync options of the verification, the options of the client with
+// the changes itemized instead of logged. Files existing in the destination are compared too.
+func (r *RsyncTransfer) verifyOptions() ([]string, error) {
+	options := r.transferOptions().CommandOptions
+	options.IgnoreExisting = false
+	options.LogFile = ""
+	options.LogFileFormat = ""
+	options.Info = nil
+	options.Itemize = true
+	rsyncOptions, err := options.AsRsyncCommandOptions()
+	if err != nil {
+		return nil, err
+	}
+	return append(rsyncOptions, "--checksum", "--dry-run"), nil
+}
+
+// verifyPodLabels returns labels for the rsync verification pod of the given PVC
+func verifyPodLabels(labels map[string]string, pvc transfer.PVCPair) map[string]string {
+	podLabels := map[string]string{}
+	for k, v := range labels {
+		podLabels[k] = v
+	}
+	podLabels[VerifyPVCLabel] = pvc.Source().LabelSafeName()
+	return podLabels
+}
+
+// Verification returns the outcome of the verification, it is completed once the rsync container of
+// every verification pod terminated. The mismatched files are read from the termination messages
+// of the containers, only the last ones are listed for a PVC with many mismatches.
+func (r *RsyncTransfer) Verification(ctx context.Context, c client.Client) (*transfer.Verification, error) {
+	pods, err := r.listVerifyPods(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	verification := &transfer.Verification{Completed: true, Mismatches: []transfer.FileMismatch{}}
+	errs := []error{}
+	for _, pvc := range r.pvcList {
+		if !isFileSystemPVC(pvc) {
+			continue
+		}
+		claim := types.NamespacedName{Namespace: pvc.Source().Claim().Namespace, Name: pvc.Source().Claim().Name}
+		var terminated *v1.ContainerStateTerminated
+		for i := range pods {
+			// pods of a previous verification may still be terminating
+			if pods[i].Labels[VerifyPVCLabel] == pvc.Source().LabelSafeName() && pods[i].DeletionTimestamp == nil {
+				terminated = rsyncTerminated(&pods[i])
+			}
+		}
+		switch {
+		case terminated == nil:
+			verification.Completed = false
+		case terminated.ExitCode != 0:
+			errs = append(errs, fmt.Errorf("%w: pvc %s: rsync exited with %d: %s",
+				transfer.ErrVerificationFailed, claim, terminated.ExitCode, strings.TrimSpace(terminated.Message)))
+		default:
+			count, mismatches := parseMismatches(terminated.Message, claim)
+			verification.MismatchCount += count
+			verification.Mismatches = append(verification.Mismatches, mismatches...)
+		}
+	}
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return verification, err
+	}
+	return verification, nil
+}
+
+// DeleteVerifier deletes the verification pods of the transfer
+func (r *RsyncTransfer) DeleteVerifier(ctx context.Context, c client.Client) error {
+	pods, err := r.listVerifyPods(ctx, c)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for i := range pods {
+		if !meta.IsOwned(pods[i].Labels) {
+			continue
+		}
+		err := c.Delete(ctx, &pods[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+// listVerifyPods returns the rsync verification pods created for the PVCs of this transfer
+func (r *RsyncTransfer) listVerifyPods(ctx context.Context, c client.Client) ([]v1.Pod, error) {
+	pvcNames := []string{}
+	for _, pvc := range r.pvcList {
+		pvcNames = append(pvcNames, pvc.Source().LabelSafeName())
+	}
+	selector := labels.SelectorFromSet(r.options.SourcePodMeta.Labels)
+	requirement, err := labels.NewRequirement(VerifyPVCLabel, selection.In, pvcNames)
+	if err != nil {
+		return nil, err
+	}
+	selector = selector.Add(*requirement)
+
+	podList := &v1.PodList{}
+	err = c.List(ctx, podList,
+		client.InNamespace(r.pvcList.GetSourceNamespaces()[0]),
+		client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// parseMismatches returns the number of mismatched files and the files listed in the termination
+// message of a verification pod
+func parseMismatches(message string, pvc types.NamespacedName) (int, []transfer.FileMismatch) {
+	count := 0
+	mismatches := []transfer.FileMismatch{}
+	scanner := bufio.NewScanner(strings.NewReader(message))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := mismatchedFilesLine.FindStringSubmatch(line); match != nil {
+			count, _ = strconv.Atoi(match[1])
+			continue
+		}
+		match := itemizedChangeLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		path := match[2]
+		if match[1][1] == 'L' {
+			// symlinks are itemized with their target
+			path = strings.SplitN(path, " -> ", 2)[0]
+		}
+		mismatches = append(mismatches, transfer.FileMismatch{
+			PVC:    pvc,
+			Path:   path,
+			Reason: mismatchReason(match[1]),
+		})
+	}
+	if count < len(mismatches) {
+		count = len(mismatches)
+	}
+	return count, mismatches
+}
+
+// mismatchReason returns how a file differs given the change itemized by rsync
+func mismatchReason(change string) string {
+	switch {
+	case change == "*deleting":
+		return "extraneous in destination"
+	case strings.Trim(change[2:], "+") == "":
+		return "missing in destination"
+	case change[2] == 'c' || change[3] == 's':
+		return "content differs"
+	}
+	return fmt.Sprintf("attributes differ (%s)", change)
+}
@@ -0,0 +1,133 @@
+package rsync
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestVerify(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t, ArchiveFiles(true), ItemizedLog{})
+	tr.options.IgnoreExisting = true
+	if err := tr.CreateVerifier(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create verifier: %v", err)
+	}
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find the verification pod: %v", err)
+	}
+	pod := pods.Items[0]
+	if pod.Labels[VerifyPVCLabel] == "" || pod.Labels[PVCLabel] != "" {
+		t.Errorf("verification pod labels %v, want only %s", pod.Labels, VerifyPVCLabel)
+	}
+	script := pod.Spec.Containers[0].Command[2]
+	for _, want := range []string{"--itemize-changes", "--checksum --dry-run", "> /tmp/rsync-verify 2> /tmp/rsync-errors"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("verification script does not contain %q\n%s", want, script)
+		}
+	}
+	for _, unwanted := range []string{"--ignore-existing", "--log-file"} {
+		if strings.Contains(script, unwanted) {
+			t.Errorf("verification script should not contain %q\n%s", unwanted, script)
+		}
+	}
+	status, err := tr.Status(context.TODO(), srcClient)
+	if err != nil || !strings.HasPrefix(status.Message, "0 rsync client pod(s)") {
+		t.Errorf("verification pods should not count as client pods, status %v, %v", status, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go completeVerifyPods(ctx, srcClient, "mismatched files: 3\n>fc.t...... data/db\n*deleting   tmp/extra\ncL+++++++++ link -> target\n")
+	verification, err := transfer.Verify(ctx, tr, 5*time.Millisecond)
+	if !errors.Is(err, transfer.ErrDataMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, transfer.ErrDataMismatch)
+	}
+	pvc := types.NamespacedName{Namespace: testNamespace, Name: testPVCName}
+	want := []transfer.FileMismatch{
+		{PVC: pvc, Path: "data/db", Reason: "content differs"},
+		{PVC: pvc, Path: "tmp/extra", Reason: "extraneous in destination"},
+		{PVC: pvc, Path: "link", Reason: "missing in destination"},
+	}
+	if verification == nil || verification.MismatchCount != 3 || !reflect.DeepEqual(verification.Mismatches, want) {
+		t.Errorf("Verify() = %+v, want mismatches %v", verification, want)
+	}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 0 {
+		t.Errorf("verification pods should be deleted, found %d: %v", len(pods.Items), err)
+	}
+}
+
+func TestVerifyFailed(t *testing.T) {
+	tr, srcClient, _ := createTransfer(t)
+	if err := tr.CreateVerifier(context.TODO(), srcClient); err != nil {
+		t.Fatalf("unable to create verifier: %v", err)
+	}
+	verification, err := tr.Verification(context.TODO(), srcClient)
+	if err != nil || verification.Completed {
+		t.Errorf("Verification() = %+v, %v, want not completed", verification, err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := srcClient.List(context.TODO(), pods, client.InNamespace(testNamespace)); err != nil || len(pods.Items) != 1 {
+		t.Fatalf("unable to find the verification pod: %v", err)
+	}
+	pod := &pods.Items[0]
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: RsyncContainer,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 10,
+			Message:  "rsync: failed to connect to localhost: Connection refused (111)\n",
+		}},
+	}}
+	if err := srcClient.Update(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to update verification pod: %v", err)
+	}
+	if _, err := tr.Verification(context.TODO(), srcClient); !errors.Is(err, transfer.ErrVerificationFailed) {
+		t.Errorf("Verification() error = %v, want %v", err, transfer.ErrVerificationFailed)
+	}
+}
+
+func TestParseMismatches(t *testing.T) {
+	pvc := types.NamespacedName{Namespace: testNamespace, Name: testPVCName}
+	count, mismatches := parseMismatches("mismatched files: 120\n.f...p..... etc/config\n.d..t...... var/\nsent 1,234 bytes\n", pvc)
+	want := []transfer.FileMismatch{
+		{PVC: pvc, Path: "etc/config", Reason: "attributes differ (.f...p.....)"},
+		{PVC: pvc, Path: "var/", Reason: "attributes differ (.d..t......)"},
+	}
+	if count != 120 || !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("parseMismatches() = %d, %v, want 120, %v", count, mismatches, want)
+	}
+	if count, mismatches := parseMismatches("mismatched files: 0\n", pvc); count != 0 || len(mismatches) != 0 {
+		t.Errorf("parseMismatches() = %d, %v, want no mismatches", count, mismatches)
+	}
+}
+
+// completeVerifyPods simulates verification pods completing with the given termination message
+func completeVerifyPods(ctx context.Context, c client.Client, message string) {
+	for ctx.Err() == nil {
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(testNamespace), client.HasLabels{VerifyPVCLabel}); err == nil {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != "" {
+					continue
+				}
+				pod.Status.Phase = corev1.PodSucceeded
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:  RsyncContainer,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+				}}
+				_ = c.Update(ctx, pod)
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
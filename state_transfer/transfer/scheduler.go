@@ -0,0 +1,205 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// SelectedNodeAnnotation is set on PVCs by the scheduler to the node their volume is
+	// provisioned for when their storage class binds volumes on first consumer
+	SelectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+	// DefaultSchedulerPollInterval is the interval the health of the servers and the status of
+	// the clients of scheduled transfers are polled at
+	DefaultSchedulerPollInterval = 5 * time.Second
+)
+
+// TransferFactory returns the transfer of the given PVCs, with its own endpoint and transport,
+// the Scheduler calls it with a single PVC pair
+type TransferFactory func(ctx context.Context, pvcList PVCPairList) (Transfer, error)
+
+// SchedulerOptions are the limits and settings of a Scheduler
+type SchedulerOptions struct {
+	// MaxConcurrent is the maximum number of transfers running at once, it must be at least 1
+	MaxConcurrent int
+	// MaxPerNamespace is the maximum number of transfers running at once for source PVCs of the
+	// same namespace, the number of transfers is not limited per namespace when 0
+	MaxPerNamespace int
+	// MaxPerNode is the maximum number of transfers running at once for source PVCs attached to
+	// the same node, see NodeName. The number of transfers is not limited per node when 0.
+	MaxPerNode int
+	// NodeName returns the node the source PVC of a pair is attached to, or an empty string when
+	// it is not known, such PVCs are only limited by MaxConcurrent and MaxPerNamespace. Defaults
+	// to SelectedNode.
+	NodeName func(pvc PVCPair) string
+	// PollInterval is the interval the health of the servers and the status of the clients are
+	// polled at, defaults to DefaultSchedulerPollInterval
+	PollInterval time.Duration
+	// ServerTimeout is the time a server is given to become healthy, the transfer fails when it
+	// does not. The server is waited for as long as the context is not done when 0.
+	ServerTimeout time.Duration
+	// Cleanup deletes the client and the server of every succeeded transfer, freeing their pods
+	// before the next transfers start
+	Cleanup bool
+	// TransportPrefix is the prefix of the transport resources deleted with Cleanup, as passed to
+	// transport.CreateServer
+	TransportPrefix string
+}
+
+// ScheduledTransfer is the outcome of the transfer of a PVC pair run by a Scheduler
+type ScheduledTransfer struct {
+	// PVC is the pair which was transferred
+	PVC PVCPair
+	// Status is the last observed status of the transfer, nil when its client was not created
+	Status *Status
+	// Err is why the transfer failed, nil when it succeeded
+	Err error
+}
+
+// Scheduler runs the transfers of many PVCs, one transfer per PVC pair, without running more
+// transfers at once than its limits allow. A transfer is started as soon as a running one
+// completes and the limits of its namespace and node allow it, in the order of the list.
+type Scheduler struct {
+	factory TransferFactory
+	options SchedulerOptions
+}
+
+// NewScheduler returns a scheduler building the transfers with the given factory
+func NewScheduler(factory TransferFactory, options SchedulerOptions) (*Scheduler, error) {
+	validationErrors := []error{}
+	if factory == nil {
+		validationErrors = append(validationErrors, fmt.Errorf("transfer factory must be set"))
+	}
+	if options.MaxConcurrent < 1 {
+		validationErrors = append(validationErrors, fmt.Errorf("max concurrent transfers must be at least 1"))
+	}
+	if options.MaxPerNamespace < 0 || options.MaxPerNode < 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("max transfers per namespace and per node must not be negative"))
+	}
+	if err := errorsutil.NewAggregate(validationErrors); err != nil {
+		return nil, err
+	}
+	if options.NodeName == nil {
+		options.NodeName = SelectedNode
+	}
+	if options.PollInterval == 0 {
+		options.PollInterval = DefaultSchedulerPollInterval
+	}
+	return &Scheduler{factory: factory, options: options}, nil
+}
+
+// SelectedNode returns the node the volume of the source PVC of the pair was provisioned for,
+// as set in SelectedNodeAnnotation, or an empty string
+func SelectedNode(pvc PVCPair) string {
+	return pvc.Source().Claim().Annotations[SelectedNodeAnnotation]
+}
+
+// Run transfers every pair of the list and returns the outcome of every transfer, in the order
+// of the list, once all of them completed. A failed transfer does not stop the others, the
+// returned error aggregates the failures. Transfers which were not started when the context is
+// done fail with the error of the context.
+func (s *Scheduler) Run(ctx context.Context, pvcList PVCPairList) ([]ScheduledTransfer, error) {
+	results := make([]ScheduledTransfer, len(pvcList))
+	pending := []int{}
+	for i, pvc := range pvcList {
+		results[i].PVC = pvc
+		pending = append(pending, i)
+	}
+
+	running := 0
+	perNamespace := map[string]int{}
+	perNode := map[string]int{}
+	done := make(chan int)
+	for len(pending) > 0 || running > 0 {
+		waiting := []int{}
+		for _, i := range pending {
+			ns, node := pvcList[i].Source().Claim().Namespace, s.options.NodeName(pvcList[i])
+			if ctx.Err() != nil || !s.fits(running, perNamespace[ns], perNode[node], node) {
+				waiting = append(waiting, i)
+				continue
+			}
+			running++
+			perNamespace[ns]++
+			perNode[node]++
+			go func(i int) {
+				results[i].Status, results[i].Err = s.runTransfer(ctx, pvcList[i])
+				done <- i
+			}(i)
+		}
+		pending = waiting
+		if running == 0 {
+			// nothing fits only once the context is done
+			for _, i := range pending {
+				results[i].Err = ctx.Err()
+			}
+			break
+		}
+		i := <-done
+		running--
+		perNamespace[pvcList[i].Source().Claim().Namespace]--
+		perNode[s.options.NodeName(pvcList[i])]--
+	}
+
+	errs := []error{}
+	for _, result := range results {
+		if result.Err != nil {
+			claim := result.PVC.Source().Claim()
+			errs = append(errs, fmt.Errorf("transfer of pvc %s/%s: %w", claim.Namespace, claim.Name, result.Err))
+		}
+	}
+	return results, errorsutil.NewAggregate(errs)
+}
+
+// fits returns whether one more transfer can run given the transfers running in total, in its
+// namespace and on its node
+func (s *Scheduler) fits(running, inNamespace, onNode int, node string) bool {
+	if running >= s.options.MaxConcurrent {
+		return false
+	}
+	if s.options.MaxPerNamespace > 0 && inNamespace >= s.options.MaxPerNamespace {
+		return false
+	}
+	return node == "" || s.options.MaxPerNode == 0 || onNode < s.options.MaxPerNode
+}
+
+// runTransfer creates the server of the transfer of the given pair, waits for it to be healthy
+// and runs the client until it completes, see RunClientWithRetries
+func (s *Scheduler) runTransfer(ctx context.Context, pvc PVCPair) (*Status, error) {
+	t, err := s.factory(ctx, PVCPairList{pvc})
+	if err != nil {
+		return nil, err
+	}
+	if err := CreateServer(ctx, t); err != nil {
+		return nil, err
+	}
+	serverCtx := ctx
+	if s.options.ServerTimeout > 0 {
+		var cancel context.CancelFunc
+		serverCtx, cancel = context.WithTimeout(ctx, s.options.ServerTimeout)
+		defer cancel()
+	}
+	// the server is not found or not ready until its pods started, the last error is reported
+	// when it does not become healthy in time
+	var healthErr error
+	err = wait.PollImmediateUntil(s.options.PollInterval, func() (bool, error) {
+		var healthy bool
+		healthy, healthErr = t.IsServerHealthy(serverCtx, t.Destination())
+		return healthy, nil
+	}, serverCtx.Done())
+	if err != nil && healthErr != nil {
+		return nil, fmt.Errorf("server is not healthy: %w", healthErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("server is not healthy: %w", err)
+	}
+	status, err := RunClientWithRetries(ctx, t, s.options.PollInterval)
+	if err != nil || !s.options.Cleanup {
+		return status, err
+	}
+	errs := []error{DeleteClient(ctx, t, s.options.TransportPrefix), DeleteServer(ctx, t, s.options.TransportPrefix)}
+	return status, errorsutil.NewAggregate(errs)
+}
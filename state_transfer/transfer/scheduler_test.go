@@ -0,0 +1,160 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scheduledTransfer succeeds once its status was polled a few times, and records how many
+// transfers run at once
+type scheduledTransfer struct {
+	pvcs     PVCPairList
+	counters *concurrency
+	polls    int
+	fail     bool
+}
+
+func (s *scheduledTransfer) Source() client.Client                             { return nil }
+func (s *scheduledTransfer) Destination() client.Client                        { return nil }
+func (s *scheduledTransfer) Endpoint() endpoint.Endpoint                       { return nil }
+func (s *scheduledTransfer) Transport() transport.Transport                    { return nil }
+func (s *scheduledTransfer) CreateServer(context.Context, client.Client) error { return nil }
+func (s *scheduledTransfer) IsServerHealthy(context.Context, client.Client) (bool, error) {
+	return true, nil
+}
+func (s *scheduledTransfer) PVCs() PVCPairList { return s.pvcs }
+
+func (s *scheduledTransfer) CreateClient(context.Context, client.Client) error {
+	s.counters.start(s.pvcs[0])
+	return nil
+}
+
+func (s *scheduledTransfer) Status(context.Context, client.Client) (*Status, error) {
+	s.polls++
+	if s.polls < 20 {
+		return &Status{Phase: TransferPhaseRunning}, nil
+	}
+	s.counters.finish(s.pvcs[0])
+	if s.fail {
+		return &Status{Phase: TransferPhaseFailed, Attempts: 1, Message: "rsync failed"}, nil
+	}
+	return &Status{Phase: TransferPhaseSucceeded, Attempts: 1}, nil
+}
+
+type concurrency struct {
+	sync.Mutex
+	running, maxRunning int
+	perKey, maxPerKey   map[string]int
+	key                 func(PVCPair) string
+}
+
+func (c *concurrency) start(pvc PVCPair) {
+	c.Lock()
+	defer c.Unlock()
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	key := c.key(pvc)
+	c.perKey[key]++
+	if c.perKey[key] > c.maxPerKey[key] {
+		c.maxPerKey[key] = c.perKey[key]
+	}
+}
+
+func (c *concurrency) finish(pvc PVCPair) {
+	c.Lock()
+	defer c.Unlock()
+	c.running--
+	c.perKey[c.key(pvc)]--
+}
+
+func TestScheduler(t *testing.T) {
+	pvcList := PVCPairList{}
+	for i := 0; i < 12; i++ {
+		pvc := newTestPVC(fmt.Sprintf("ns-%d", i%3), fmt.Sprintf("data-%d", i), nil)
+		pvc.Annotations = map[string]string{SelectedNodeAnnotation: fmt.Sprintf("node-%d", i%2)}
+		pvcList = append(pvcList, NewPVCPair(pvc, nil))
+	}
+	tests := []struct {
+		name       string
+		options    SchedulerOptions
+		key        func(PVCPair) string
+		wantMax    int
+		wantPerKey int
+	}{
+		{
+			name:       "per namespace",
+			options:    SchedulerOptions{MaxConcurrent: 5, MaxPerNamespace: 1},
+			key:        func(pvc PVCPair) string { return pvc.Source().Claim().Namespace },
+			wantMax:    3,
+			wantPerKey: 1,
+		},
+		{
+			name:       "per node",
+			options:    SchedulerOptions{MaxConcurrent: 5, MaxPerNode: 2},
+			key:        SelectedNode,
+			wantMax:    4,
+			wantPerKey: 2,
+		},
+		{
+			name:       "concurrent",
+			options:    SchedulerOptions{MaxConcurrent: 3},
+			key:        func(PVCPair) string { return "" },
+			wantMax:    3,
+			wantPerKey: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counters := &concurrency{perKey: map[string]int{}, maxPerKey: map[string]int{}, key: tt.key}
+			factory := func(ctx context.Context, pvcs PVCPairList) (Transfer, error) {
+				return &scheduledTransfer{pvcs: pvcs, counters: counters, fail: pvcs[0].Source().Claim().Name == "data-5"}, nil
+			}
+			tt.options.PollInterval = time.Millisecond
+			scheduler, err := NewScheduler(factory, tt.options)
+			if err != nil {
+				t.Fatalf("NewScheduler() unexpected error %v", err)
+			}
+			results, err := scheduler.Run(context.TODO(), pvcList)
+			if err == nil || !strings.Contains(err.Error(), "ns-2/data-5") || !errors.Is(err, ErrTransferFailed) {
+				t.Errorf("Run() error = %v, want the failure of data-5", err)
+			}
+			for i, result := range results {
+				if result.PVC != pvcList[i] || result.Status == nil {
+					t.Fatalf("result %d is not the outcome of pvc %s", i, pvcList[i].Source().Claim().Name)
+				}
+				if (result.Err != nil) != (i == 5) {
+					t.Errorf("transfer of %s error = %v", pvcList[i].Source().Claim().Name, result.Err)
+				}
+			}
+			if counters.maxRunning != tt.wantMax {
+				t.Errorf("%d transfers ran at once, want %d", counters.maxRunning, tt.wantMax)
+			}
+			for key, max := range counters.maxPerKey {
+				if max > tt.wantPerKey {
+					t.Errorf("%d transfers ran at once for %q, want at most %d", max, key, tt.wantPerKey)
+				}
+			}
+		})
+	}
+
+	if _, err := NewScheduler(func(context.Context, PVCPairList) (Transfer, error) { return nil, nil }, SchedulerOptions{}); err == nil {
+		t.Errorf("NewScheduler() should require a concurrency limit")
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	scheduler, _ := NewScheduler(func(context.Context, PVCPairList) (Transfer, error) { return nil, nil }, SchedulerOptions{MaxConcurrent: 1})
+	if results, err := scheduler.Run(ctx, pvcList); !errors.Is(err, context.Canceled) || !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("Run() error = %v, transfers should not start once the context is done", err)
+	}
+}
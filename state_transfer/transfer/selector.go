@@ -0,0 +1,58 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PVCPairsFromSelector lists the PVCs matching the selector in the source namespace and pairs
+// each of them with the PVC of the same name in the destination namespace. The returned pairs
+// can be passed to NewFilesystemPVCPairList or NewBlockOrVMDiskPVCPairList.
+func PVCPairsFromSelector(ctx context.Context, src, dst client.Client, srcNamespace, dstNamespace string, selector labels.Selector) (PVCPairList, error) {
+	return PVCPairsFromSelectorWithMapping(ctx, src, dst, srcNamespace, dstNamespace, selector, nil)
+}
+
+// PVCPairsFromSelectorWithMapping works like PVCPairsFromSelector, source PVCs found in the
+// mapping are paired with the destination PVC it maps their name to instead of the same name.
+// An error is returned for every source PVC without a destination counterpart.
+func PVCPairsFromSelectorWithMapping(ctx context.Context, src, dst client.Client, srcNamespace, dstNamespace string, selector labels.Selector, mapping map[string]string) (PVCPairList, error) {
+	srcPVCs := &v1.PersistentVolumeClaimList{}
+	err := src.List(ctx, srcPVCs, client.InNamespace(srcNamespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	if len(srcPVCs.Items) == 0 {
+		return nil, fmt.Errorf("no pvcs matching selector %q found in namespace %s", selector, srcNamespace)
+	}
+
+	pvcList := PVCPairList{}
+	errs := []error{}
+	for i := range srcPVCs.Items {
+		srcPVC := &srcPVCs.Items[i]
+		dstName := srcPVC.Name
+		if name, exists := mapping[srcPVC.Name]; exists {
+			dstName = name
+		}
+		dstPVC := &v1.PersistentVolumeClaim{}
+		err := dst.Get(ctx, client.ObjectKey{Namespace: dstNamespace, Name: dstName}, dstPVC)
+		if k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("source pvc %s/%s has no destination pvc %s/%s", srcNamespace, srcPVC.Name, dstNamespace, dstName))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pvcList = append(pvcList, NewPVCPair(srcPVC, dstPVC))
+	}
+	if len(errs) > 0 {
+		return nil, errorsutil.NewAggregate(errs)
+	}
+	return pvcList, nil
+}
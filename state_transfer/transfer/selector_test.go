@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestPVC(namespace, name string, l map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: l},
+	}
+}
+
+func buildTestClient(t *testing.T, objects ...client.Object) client.Client {
+	s := runtime.NewScheme()
+	if err := v1.AddToScheme(s); err != nil {
+		t.Fatalf("unable to build scheme: %v", err)
+	}
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+}
+
+func TestPVCPairsFromSelector(t *testing.T) {
+	app := map[string]string{"app": "foo"}
+	src := buildTestClient(t,
+		newTestPVC("src", "data", app),
+		newTestPVC("src", "logs", app),
+		newTestPVC("src", "other", nil),
+	)
+	selector := labels.SelectorFromSet(app)
+
+	dst := buildTestClient(t, newTestPVC("dst", "data", nil), newTestPVC("dst", "logs", nil))
+	pvcList, err := PVCPairsFromSelector(context.TODO(), src, dst, "src", "dst", selector)
+	if err != nil {
+		t.Fatalf("PVCPairsFromSelector() unexpected error %v", err)
+	}
+	if len(pvcList) != 2 {
+		t.Fatalf("expected 2 pvc pairs, got %d", len(pvcList))
+	}
+	for _, pair := range pvcList {
+		if pair.Destination().Claim().Namespace != "dst" || pair.Destination().Claim().Name != pair.Source().Claim().Name {
+			t.Errorf("source pvc %s paired with unexpected destination %s/%s", pair.Source().Claim().Name,
+				pair.Destination().Claim().Namespace, pair.Destination().Claim().Name)
+		}
+	}
+
+	dst = buildTestClient(t, newTestPVC("dst", "data", nil), newTestPVC("dst", "app-logs", nil))
+	pvcList, err = PVCPairsFromSelectorWithMapping(context.TODO(), src, dst, "src", "dst", selector, map[string]string{"logs": "app-logs"})
+	if err != nil {
+		t.Fatalf("PVCPairsFromSelectorWithMapping() unexpected error %v", err)
+	}
+	if len(pvcList) != 2 {
+		t.Fatalf("expected 2 pvc pairs, got %d", len(pvcList))
+	}
+
+	if _, err := PVCPairsFromSelector(context.TODO(), src, dst, "src", "dst", selector); err == nil {
+		t.Errorf("expected an error for source pvc logs without a destination counterpart")
+	}
+}
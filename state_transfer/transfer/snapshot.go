@@ -0,0 +1,183 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	snapshotPrefix = "crane2-snapshot-"
+	clonePrefix    = "crane2-clone-"
+)
+
+// VolumeSnapshotGroupVersion is the version of the CSI snapshot API the snapshots are created with
+var VolumeSnapshotGroupVersion = schema.GroupVersion{Group: "snapshot.storage.k8s.io", Version: "v1"}
+
+// ErrSnapshotFailed is returned when the CSI driver failed to take the snapshot of a source PVC
+var ErrSnapshotFailed = errors.New("volume snapshot failed")
+
+// SnapshotSource makes a transfer copy the data of a temporary clone of every source PVC instead
+// of the PVC itself. A CSI VolumeSnapshot of the source PVC is taken when the client is created and
+// the clone is provisioned from it, so that the workloads using the source PVC are not disturbed and
+// the copy is crash-consistent. The source PVCs must be provisioned by a CSI driver supporting
+// snapshots. The snapshots are taken once, they are reused by retries and later syncs until
+// DeleteSnapshotSources is called.
+type SnapshotSource struct {
+	// VolumeSnapshotClassName is the class of the snapshots, defaults to the default class of the
+	// CSI driver
+	VolumeSnapshotClassName string
+	// StorageClassName is the storage class of the clones, defaults to the class of the source PVC
+	StorageClassName string
+}
+
+// SnapshotName returns the name of the VolumeSnapshot taken of the given source PVC
+func SnapshotName(pvc PVC) string {
+	return snapshotPrefix + pvc.LabelSafeName()
+}
+
+// SnapshotClaimName returns the name of the clone of the given source PVC provisioned from its
+// snapshot, transfer clients mount it instead of the source PVC
+func SnapshotClaimName(pvc PVC) string {
+	return clonePrefix + pvc.LabelSafeName()
+}
+
+// CreateSnapshotSources is a utility function that can be used by various implementations to take
+// a VolumeSnapshot of every source PVC of the list and provision its clone. Existing snapshots and
+// clones are left as they are. Creating them requires the create verb on volumesnapshots and
+// persistentvolumeclaims in the source namespaces.
+func CreateSnapshotSources(ctx context.Context, c client.Client, snapshot *SnapshotSource, pvcList PVCPairList) error {
+	errs := []error{}
+	for _, pvc := range pvcList {
+		if err := createSnapshot(ctx, c, snapshot, pvc.Source()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, createClone(ctx, c, snapshot, pvc.Source()))
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func createSnapshot(ctx context.Context, c client.Client, snapshot *SnapshotSource, pvc PVC) error {
+	volumeSnapshot := newVolumeSnapshot()
+	volumeSnapshot.SetNamespace(pvc.Claim().Namespace)
+	volumeSnapshot.SetName(SnapshotName(pvc))
+	volumeSnapshot.SetLabels(meta.WithOwnerLabel(meta.Labels))
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvc.Claim().Name,
+		},
+	}
+	if snapshot.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = snapshot.VolumeSnapshotClassName
+	}
+	if err := unstructured.SetNestedMap(volumeSnapshot.Object, spec, "spec"); err != nil {
+		return err
+	}
+	err := c.Create(ctx, volumeSnapshot, &client.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func createClone(ctx context.Context, c client.Client, snapshot *SnapshotSource, pvc PVC) error {
+	source := pvc.Claim()
+	storageClassName := source.Spec.StorageClassName
+	if snapshot.StorageClassName != "" {
+		storageClassName = &snapshot.StorageClassName
+	}
+	// the clone must be at least as large as the snapshot, which is the capacity of the source
+	size, ok := source.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		size = source.Spec.Resources.Requests[corev1.ResourceStorage]
+	}
+	apiGroup := VolumeSnapshotGroupVersion.Group
+	clone := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: source.Namespace,
+			Name:      SnapshotClaimName(pvc),
+			Labels:    meta.WithOwnerLabel(meta.Labels),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      source.Spec.AccessModes,
+			StorageClassName: storageClassName,
+			VolumeMode:       source.Spec.VolumeMode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     SnapshotName(pvc),
+			},
+		},
+	}
+	err := c.Create(ctx, clone, &client.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// AreSnapshotSourcesReady returns whether the snapshot of every source PVC of the list was taken,
+// the clones are provisioned once their snapshot is ready. An error wrapping ErrSnapshotFailed is
+// returned for every snapshot the CSI driver reported an error for.
+func AreSnapshotSourcesReady(ctx context.Context, c client.Client, pvcList PVCPairList) (bool, error) {
+	ready := true
+	errs := []error{}
+	for _, pvc := range pvcList {
+		volumeSnapshot := newVolumeSnapshot()
+		key := client.ObjectKey{Namespace: pvc.Source().Claim().Namespace, Name: SnapshotName(pvc.Source())}
+		if err := c.Get(ctx, key, volumeSnapshot); err != nil {
+			return false, err
+		}
+		if message, found, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "error", "message"); found {
+			errs = append(errs, fmt.Errorf("%w: snapshot %s of pvc %s: %s", ErrSnapshotFailed, key, pvc.Source().Claim().Name, message))
+		}
+		if readyToUse, _, _ := unstructured.NestedBool(volumeSnapshot.Object, "status", "readyToUse"); !readyToUse {
+			ready = false
+		}
+	}
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return false, err
+	}
+	return ready, nil
+}
+
+// DeleteSnapshotSources deletes the clones and the snapshots created by CreateSnapshotSources for
+// the source PVCs of the list, once the transfer clients are done with them. The next
+// CreateSnapshotSources takes new snapshots, e.g. for the final sync of a warm migration.
+func DeleteSnapshotSources(ctx context.Context, c client.Client, pvcList PVCPairList) error {
+	errs := []error{}
+	for _, pvc := range pvcList {
+		clone := &corev1.PersistentVolumeClaim{}
+		clone.Namespace = pvc.Source().Claim().Namespace
+		clone.Name = SnapshotClaimName(pvc.Source())
+		volumeSnapshot := newVolumeSnapshot()
+		volumeSnapshot.SetNamespace(pvc.Source().Claim().Namespace)
+		volumeSnapshot.SetName(SnapshotName(pvc.Source()))
+		for _, obj := range []client.Object{clone, volumeSnapshot} {
+			err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if err != nil && !k8serrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errorsutil.NewAggregate(errs)
+}
+
+func newVolumeSnapshot() *unstructured.Unstructured {
+	volumeSnapshot := &unstructured.Unstructured{}
+	volumeSnapshot.SetGroupVersionKind(VolumeSnapshotGroupVersion.WithKind("VolumeSnapshot"))
+	return volumeSnapshot
+}
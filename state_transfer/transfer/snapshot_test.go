@@ -0,0 +1,87 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSnapshotSources(t *testing.T) {
+	source := newTestPVC("src", "data", nil)
+	storageClass := "standard"
+	source.Spec.StorageClassName = &storageClass
+	source.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	source.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}
+	source.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse("2Gi")}
+	pvcs := PVCPairList{NewPVCPair(source, newTestPVC("dst", "data", nil))}
+	c := buildTestClient(t, source)
+
+	snapshot := &SnapshotSource{VolumeSnapshotClassName: "csi-snapclass"}
+	if err := CreateSnapshotSources(context.TODO(), c, snapshot, pvcs); err != nil {
+		t.Fatalf("CreateSnapshotSources() unexpected error %v", err)
+	}
+	// existing snapshots and clones are reused
+	if err := CreateSnapshotSources(context.TODO(), c, snapshot, pvcs); err != nil {
+		t.Fatalf("CreateSnapshotSources() unexpected error on existing snapshots %v", err)
+	}
+
+	snapshotKey := client.ObjectKey{Namespace: "src", Name: SnapshotName(pvcs[0].Source())}
+	volumeSnapshot := newVolumeSnapshot()
+	if err := c.Get(context.TODO(), snapshotKey, volumeSnapshot); err != nil {
+		t.Fatalf("unable to get volume snapshot: %v", err)
+	}
+	if name, _, _ := unstructured.NestedString(volumeSnapshot.Object, "spec", "source", "persistentVolumeClaimName"); name != "data" {
+		t.Errorf("snapshot source = %q, want data", name)
+	}
+	if class, _, _ := unstructured.NestedString(volumeSnapshot.Object, "spec", "volumeSnapshotClassName"); class != "csi-snapclass" {
+		t.Errorf("snapshot class = %q, want csi-snapclass", class)
+	}
+
+	clone := &v1.PersistentVolumeClaim{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "src", Name: SnapshotClaimName(pvcs[0].Source())}, clone); err != nil {
+		t.Fatalf("unable to get clone: %v", err)
+	}
+	if clone.Spec.DataSource == nil || clone.Spec.DataSource.Kind != "VolumeSnapshot" || clone.Spec.DataSource.Name != volumeSnapshot.GetName() {
+		t.Errorf("clone data source = %v, want the snapshot", clone.Spec.DataSource)
+	}
+	if size := clone.Spec.Resources.Requests[v1.ResourceStorage]; size.String() != "2Gi" {
+		t.Errorf("clone size = %s, want the capacity of the source 2Gi", size.String())
+	}
+	if *clone.Spec.StorageClassName != storageClass {
+		t.Errorf("clone storage class = %s, want the class of the source", *clone.Spec.StorageClassName)
+	}
+
+	if ready, err := AreSnapshotSourcesReady(context.TODO(), c, pvcs); ready || err != nil {
+		t.Errorf("AreSnapshotSourcesReady() = %v, %v, the snapshot is not ready", ready, err)
+	}
+	_ = unstructured.SetNestedField(volumeSnapshot.Object, true, "status", "readyToUse")
+	if err := c.Update(context.TODO(), volumeSnapshot); err != nil {
+		t.Fatalf("unable to update volume snapshot: %v", err)
+	}
+	if ready, err := AreSnapshotSourcesReady(context.TODO(), c, pvcs); !ready || err != nil {
+		t.Errorf("AreSnapshotSourcesReady() = %v, %v, the snapshot is ready", ready, err)
+	}
+	_ = unstructured.SetNestedField(volumeSnapshot.Object, "driver failure", "status", "error", "message")
+	if err := c.Update(context.TODO(), volumeSnapshot); err != nil {
+		t.Fatalf("unable to update volume snapshot: %v", err)
+	}
+	if _, err := AreSnapshotSourcesReady(context.TODO(), c, pvcs); !errors.Is(err, ErrSnapshotFailed) {
+		t.Errorf("AreSnapshotSourcesReady() error = %v, want %v", err, ErrSnapshotFailed)
+	}
+
+	if err := DeleteSnapshotSources(context.TODO(), c, pvcs); err != nil {
+		t.Fatalf("DeleteSnapshotSources() unexpected error %v", err)
+	}
+	if err := c.Get(context.TODO(), snapshotKey, newVolumeSnapshot()); !k8serrors.IsNotFound(err) {
+		t.Errorf("snapshot should be deleted, got %v", err)
+	}
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: "src", Name: clone.Name}, &v1.PersistentVolumeClaim{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("clone should be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package transfer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// SourcePath selects a subdirectory of the source volume to transfer instead of the volume root
+type SourcePath struct {
+	// Path is the subdirectory to transfer, relative to the root of the source volume
+	Path string
+	// IncludeDirectory copies the directory itself into the destination volume,
+	// by default only the contents of the directory are copied
+	IncludeDirectory bool
+}
+
+// SourcePathPVCPair is a PVCPair which transfers a subdirectory of the source volume
+type SourcePathPVCPair interface {
+	PVCPair
+	// SourcePath returns the subdirectory of the source volume to transfer
+	SourcePath() SourcePath
+}
+
+// NewPVCPairWithSourcePath returns a PVCPair which transfers the given subdirectory of the
+// source volume. The path is validated so that it cannot point outside of the volume.
+func NewPVCPairWithSourcePath(src *v1.PersistentVolumeClaim, dest *v1.PersistentVolumeClaim, sourcePath SourcePath) (PVCPair, error) {
+	cleaned, err := cleanSourcePath(sourcePath.Path)
+	if err != nil {
+		return nil, err
+	}
+	sourcePath.Path = cleaned
+	p := NewPVCPair(src, dest).(pvcPair)
+	p.sourcePath = sourcePath
+	return p, nil
+}
+
+// GetSourcePath returns the subdirectory of the source volume the given PVCPair transfers,
+// an empty Path means the whole volume is transferred
+func GetSourcePath(p PVCPair) SourcePath {
+	if s, ok := p.(SourcePathPVCPair); ok {
+		return s.SourcePath()
+	}
+	return SourcePath{}
+}
+
+// cleanSourcePath returns the given path relative to the volume root, rejecting paths which
+// traverse outside of the volume or cannot be used safely in a transfer command
+func cleanSourcePath(p string) (string, error) {
+	if strings.ContainsAny(p, " \t\n\"'`$;&|<>()\\*?[]") {
+		return "", fmt.Errorf("source path %q contains whitespace, quotes, wildcards or shell meta characters", p)
+	}
+	cleaned := path.Clean("/" + p)
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("source path %q must not traverse outside of the volume", p)
+		}
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
@@ -0,0 +1,100 @@
+package transfer
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TransferPhase is a simple, high-level summary of where a transfer is in its lifecycle
+type TransferPhase string
+
+const (
+	// TransferPhasePending means the transfer client has not started yet
+	TransferPhasePending TransferPhase = "Pending"
+	// TransferPhaseRunning means at least one transfer client is running
+	TransferPhaseRunning TransferPhase = "Running"
+	// TransferPhaseSucceeded means all transfer clients completed successfully
+	TransferPhaseSucceeded TransferPhase = "Succeeded"
+	// TransferPhaseFailed means at least one transfer client failed
+	TransferPhaseFailed TransferPhase = "Failed"
+	// TransferPhaseCancelled means the transfer was aborted using Cancel, the
+	// destination is left in an incomplete state
+	TransferPhaseCancelled TransferPhase = "Cancelled"
+)
+
+// Status defines the observed state of a transfer
+type Status struct {
+	// Phase is the current phase of the transfer
+	Phase TransferPhase
+	// Message is a human readable message explaining the phase
+	Message string
+	// Parallelism is the number of parallel streams used to transfer each PVC
+	Parallelism int
+	// Attempts is the highest number of times the client of a PVC was run, it is
+	// greater than 1 when a client was retried after a failure
+	Attempts int
+	// VanishedFiles is the number of source files deleted while the transfer was running, it is
+	// only reported by transfers tolerating vanished files
+	VanishedFiles int
+	// History lists every run of the client of every PVC, including the failed attempts which
+	// were retried, by PVC and attempt number
+	History []Attempt
+}
+
+// Attempt is a single run of the transfer client of a PVC
+type Attempt struct {
+	// PVC is the name of the source PVC, transfers copying several PVCs with a single client
+	// report one attempt per PVC
+	PVC string
+	// Number is the attempt number, starting at 1
+	Number int
+	// Phase is the phase of the transfer of the PVC during this attempt
+	Phase PVCTransferPhase
+	// ExitCode is the exit code of the transfer client, only set once it terminated
+	ExitCode *int32
+	// StartTime is when the client started, zero until then
+	StartTime time.Time
+	// FinishTime is when the client terminated, zero until then
+	FinishTime time.Time
+	// Message is the termination message of the client
+	Message string
+}
+
+// StatusReporter knows how to report the observed state of a transfer
+type StatusReporter interface {
+	// Status returns the observed state of the transfer, the client must
+	// be able to reach the cluster the transfer client runs in
+	Status(ctx context.Context, c client.Client) (*Status, error)
+}
+
+// PhaseFromPods summarizes the phases of a list of transfer client pods into a TransferPhase
+func PhaseFromPods(pods []corev1.Pod) TransferPhase {
+	if len(pods) == 0 {
+		return TransferPhasePending
+	}
+	succeeded := 0
+	running := false
+	for _, pod := range pods {
+		if IsCancelled(&pod) {
+			return TransferPhaseCancelled
+		}
+		switch pod.Status.Phase {
+		case corev1.PodFailed:
+			return TransferPhaseFailed
+		case corev1.PodSucceeded:
+			succeeded++
+		case corev1.PodRunning:
+			running = true
+		}
+	}
+	switch {
+	case succeeded == len(pods):
+		return TransferPhaseSucceeded
+	case running || succeeded > 0:
+		return TransferPhaseRunning
+	}
+	return TransferPhasePending
+}
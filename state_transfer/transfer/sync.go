@@ -0,0 +1,80 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultSyncInterval is the interval the status of the clients of a sync is polled at
+	DefaultSyncInterval = 10 * time.Second
+)
+
+// SyncMode selects what a sync run with Sync copies
+type SyncMode string
+
+const (
+	// SyncModeIncremental copies the files which changed since the previous sync, the first sync
+	// copies everything. Files deleted from the source are kept on the destination.
+	SyncModeIncremental SyncMode = "Incremental"
+	// SyncModeFinal copies the files which changed since the previous sync and deletes the
+	// destination files which no longer exist on the source, so that the destination matches the
+	// source before workloads are cut over to it
+	SyncModeFinal SyncMode = "Final"
+)
+
+// Syncer knows how to run the client of a transfer repeatedly against the same server
+type Syncer interface {
+	// PrepareSync deletes the transfer client pods of the previous sync, it only returns once
+	// they are gone, and configures the clients of the next sync for the given mode
+	PrepareSync(ctx context.Context, c client.Client, mode SyncMode) error
+}
+
+// SyncOptions configures a sync run with Sync
+type SyncOptions struct {
+	// Mode is the mode of the sync, defaults to SyncModeIncremental
+	Mode SyncMode
+	// Quiesce is called before a final sync, typically to scale down the source workloads so that
+	// the source data no longer changes, see state_transfer.QuiesceApplicationsWithClient
+	Quiesce func(ctx context.Context) error
+	// Interval is the interval the status of the clients is polled at, defaults to
+	// DefaultSyncInterval
+	Interval time.Duration
+}
+
+// Sync runs the client of the transfer once and waits for it to complete like
+// RunClientWithRetries, replacing the clients of the previous sync. Warm migrations call it
+// repeatedly while the source workloads are running, every sync only copying what changed since
+// the previous one, and once with SyncModeFinal to quiesce the source and copy the last changes
+// before cutting over. The server must have been created beforehand and is kept running between
+// syncs. Transfers which do not implement Syncer cannot be synced repeatedly.
+func Sync(ctx context.Context, t Transfer, options SyncOptions) (*Status, error) {
+	syncer, ok := t.(Syncer)
+	if !ok {
+		return nil, fmt.Errorf("transfer does not support repeated syncs")
+	}
+	mode := options.Mode
+	switch mode {
+	case "":
+		mode = SyncModeIncremental
+	case SyncModeIncremental, SyncModeFinal:
+	default:
+		return nil, fmt.Errorf("unsupported sync mode %s", mode)
+	}
+	if mode == SyncModeFinal && options.Quiesce != nil {
+		if err := options.Quiesce(ctx); err != nil {
+			return nil, fmt.Errorf("unable to quiesce the source before the final sync: %w", err)
+		}
+	}
+	if err := syncer.PrepareSync(ctx, t.Source(), mode); err != nil {
+		return nil, err
+	}
+	interval := options.Interval
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	return RunClientWithRetries(ctx, t, interval)
+}
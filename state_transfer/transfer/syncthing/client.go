@@ -0,0 +1,120 @@
+package syncthing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// the client is ready once it is connected to the server and neither side needs anything
+	// from the other in any folder
+	syncthingConvergedScript = `set -e
+api() { wget -qO- --header "X-API-Key: ${SYNCTHING_API_KEY}" "http://%[1]s/rest/$1"; }
+api system/connections | grep -q '"connected": true'
+for folder in %[2]s; do
+  api "db/completion?folder=${folder}&device=%[3]s" | grep -q '"needBytes": 0,'
+  api "db/completion?folder=${folder}&device=%[3]s" | grep -q '"needDeletes": 0,'
+%[4]sdone
+`
+	syncthingLocalConvergedCheck = `  api "db/status?folder=${folder}" | grep -q '"needBytes": 0,'
+  api "db/status?folder=${folder}" | grep -q '"needDeletes": 0,'
+`
+)
+
+// CreateClient creates the syncthing client, it connects to the server through the transport.
+// The server must have been created beforehand as it generates the identities of the devices,
+// they are read from the destination cluster when the server was created by another transfer.
+func (s *SyncthingTransfer) CreateClient(ctx context.Context, c client.Client) error {
+	log := s.log.WithValues("namespace", s.pvcList.GetSourceNamespaces()[0])
+	err := s.createClient(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create syncthing client")
+	}
+	return err
+}
+
+func (s *SyncthingTransfer) createClient(ctx context.Context, c client.Client) error {
+	found, err := s.loadDevices(ctx, s.Destination())
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("the syncthing server must be created before its client")
+	}
+
+	_, err = transport.CreateClient(ctx, s.Transport(), c, "", s.Endpoint())
+	if err != nil {
+		return err
+	}
+
+	return createSyncthingClient(ctx, c, s)
+}
+
+func createSyncthingClient(ctx context.Context, c client.Client, s *SyncthingTransfer) error {
+	namespace := s.pvcList.GetSourceNamespaces()[0]
+	labels := s.podLabels(clientComponent)
+	folders, pvcs := s.folders(transfer.PVCPair.Source)
+
+	folderType := folderTypeSendReceive
+	if s.transferOptions.OneWay {
+		folderType = folderTypeSendOnly
+	}
+	clientConfig := &config{
+		Name:          clientComponent,
+		DeviceID:      s.client.id,
+		PeerName:      serverComponent,
+		PeerDeviceID:  s.server.id,
+		PeerAddress:   fmt.Sprintf("tcp://%s:%d", transfer.ConnectionHostname(s), transfer.ConnectionPort(s)),
+		ListenAddress: syncthingClientListen,
+		GUIAddress:    syncthingGUIAddress,
+		APIKey:        s.client.apiKey,
+		FolderType:    folderType,
+		Folders:       folders,
+	}
+	rendered, err := clientConfig.render()
+	if err != nil {
+		return err
+	}
+	s.log.V(1).Info("rendered syncthing client config", "namespace", namespace, "folders", len(folders))
+
+	data := deviceSecretData(s.client, s.server)
+	data[configKey] = []byte(rendered)
+	data[convergedKey] = []byte(convergedScript(s, folders))
+	err = createSecret(ctx, c, syncthingClientName, namespace, labels, data)
+	if err != nil {
+		return err
+	}
+
+	probe := &v1.Probe{
+		Handler: v1.Handler{
+			Exec: &v1.ExecAction{
+				Command: []string{"/bin/sh", syncthingSecretPath + "/" + convergedKey},
+			},
+		},
+		PeriodSeconds: convergedCheckPeriod,
+	}
+	return s.createDeployment(ctx, c, syncthingClientName, namespace, labels, pvcs, probe,
+		s.Transport().ClientContainers(), s.Transport().ClientVolumes())
+}
+
+// convergedScript returns the script checking whether the client converged with the server, it
+// is the readiness probe of the client and its result is reported in the logs, see IsConverged
+func convergedScript(s *SyncthingTransfer, folders []folder) string {
+	ids := []string{}
+	for _, f := range folders {
+		ids = append(ids, f.ID)
+	}
+	localCheck := syncthingLocalConvergedCheck
+	if s.transferOptions.OneWay {
+		localCheck = ""
+	}
+	return fmt.Sprintf(syncthingConvergedScript, syncthingGUIAddress, strings.Join(ids, " "), s.server.id, localCheck)
+}
@@ -0,0 +1,92 @@
+package syncthing
+
+import (
+	"bytes"
+	"text/template"
+)
+
+const (
+	// discovery, relays and NAT traversal are disabled, the client dials the server through the
+	// transport and the devices only share the folders of the transfer with each other
+	syncthingConfigTemplate = `<configuration version="37">
+{{- range .Folders }}
+    <folder id="{{ .ID }}" label="{{ .ID }}" path="{{ .Path }}" type="{{ $.FolderType }}" rescanIntervalS="60" fsWatcherEnabled="true" fsWatcherDelayS="10" ignorePerms="false" autoNormalize="true">
+        <device id="{{ $.DeviceID }}"></device>
+        <device id="{{ $.PeerDeviceID }}"></device>
+    </folder>
+{{- end }}
+    <device id="{{ .DeviceID }}" name="{{ .Name }}" compression="metadata" introducer="false">
+        <address>dynamic</address>
+    </device>
+    <device id="{{ .PeerDeviceID }}" name="{{ .PeerName }}" compression="metadata" introducer="false">
+        <address>{{ .PeerAddress }}</address>
+    </device>
+    <gui enabled="true" tls="false">
+        <address>{{ .GUIAddress }}</address>
+        <apikey>{{ .APIKey }}</apikey>
+    </gui>
+    <options>
+        <listenAddress>{{ .ListenAddress }}</listenAddress>
+        <globalAnnounceEnabled>false</globalAnnounceEnabled>
+        <localAnnounceEnabled>false</localAnnounceEnabled>
+        <relaysEnabled>false</relaysEnabled>
+        <natEnabled>false</natEnabled>
+        <urAccepted>-1</urAccepted>
+        <crashReportingEnabled>false</crashReportingEnabled>
+        <autoUpgradeIntervalH>0</autoUpgradeIntervalH>
+        <startBrowser>false</startBrowser>
+    </options>
+</configuration>
+`
+	// syncthing rewrites its configuration, it is copied from the Secret to a writable home. The
+	// client reports whether it converged in its logs every convergedCheckPeriod, see IsConverged.
+	syncthingStartScript = `set -e
+mkdir -p ` + syncthingHomePath + `
+cp ` + syncthingSecretPath + `/` + certKey + ` ` + syncthingSecretPath + `/` + keyKey + ` ` + syncthingSecretPath + `/` + configKey + ` ` + syncthingHomePath + `/
+for folder in ` + syncthingDataPath + `/*/; do mkdir -p "${folder}.stfolder"; done
+if [ -f ` + syncthingSecretPath + `/` + convergedKey + ` ]; then
+  while sleep 10; do
+    if /bin/sh ` + syncthingSecretPath + `/` + convergedKey + ` >/dev/null 2>&1; then echo ` + convergedStatusPrefix + `true; else echo ` + convergedStatusPrefix + `false; fi
+  done &
+fi
+exec syncthing serve --no-browser --no-restart --home=` + syncthingHomePath + `
+`
+)
+
+const (
+	folderTypeSendReceive = "sendreceive"
+	folderTypeSendOnly    = "sendonly"
+	folderTypeReceiveOnly = "receiveonly"
+)
+
+type folder struct {
+	ID   string
+	Path string
+}
+
+// config holds the values of the configuration of one side of the transfer
+type config struct {
+	Name          string
+	DeviceID      string
+	PeerName      string
+	PeerDeviceID  string
+	PeerAddress   string
+	ListenAddress string
+	GUIAddress    string
+	APIKey        string
+	FolderType    string
+	Folders       []folder
+}
+
+func (c *config) render() (string, error) {
+	var out bytes.Buffer
+	configTemplate, err := template.New("config").Parse(syncthingConfigTemplate)
+	if err != nil {
+		return "", err
+	}
+	err = configTemplate.Execute(&out, c)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
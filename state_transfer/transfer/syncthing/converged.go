@@ -0,0 +1,86 @@
+package syncthing
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// convergedCheckPeriod is how often, in seconds, the client checks whether it converged with
+	// the server, the start script of the pods runs the checks as often
+	convergedCheckPeriod = 10
+	// convergedStatusPrefix prefixes the result of the checks the client reports in its logs
+	convergedStatusPrefix = "crane2-syncthing-converged="
+	// convergedStatusMaxAge is the age beyond which the latest reported check is ignored, the
+	// client stopped checking
+	convergedStatusMaxAge = 3 * convergedCheckPeriod * time.Second
+	// convergedLogTailLines is the number of lines of the client logs IsConverged reads
+	convergedLogTailLines = int64(50)
+)
+
+// IsConverged returns whether the source and the destination hold the same data: the client is
+// connected to the server and, in its latest check, none of them needed any change from the other
+// in any folder. The client checks its completion and the completion of the server with the
+// syncthing API and reports the result in its logs, which IsConverged reads with the pods
+// client. Both c and pods must reach the source cluster, the get verb on pods/log is required. In
+// one way transfers only the destination is checked.
+func (s *SyncthingTransfer) IsConverged(ctx context.Context, c client.Client, pods corev1client.PodsGetter) (bool, error) {
+	podList := &v1.PodList{}
+	err := c.List(ctx, podList, client.InNamespace(s.pvcList.GetSourceNamespaces()[0]), client.MatchingLabels(s.podLabels(clientComponent)))
+	if err != nil {
+		return false, err
+	}
+	tailLines := convergedLogTailLines
+	errs := []error{}
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		logs, err := pods.Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container:  SyncthingContainer,
+			TailLines:  &tailLines,
+			Timestamps: true,
+		}).Stream(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		converged, err := parseConverged(logs, time.Now())
+		logs.Close()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if converged {
+			return true, nil
+		}
+	}
+	return false, errorsutil.NewAggregate(errs)
+}
+
+// parseConverged returns whether the latest check reported in the given logs, read with their
+// timestamps, found the client converged and is recent enough
+func parseConverged(logs io.Reader, now time.Time) (bool, error) {
+	converged := false
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		timestamp, message, found := strings.Cut(scanner.Text(), " ")
+		if !found || !strings.HasPrefix(message, convergedStatusPrefix) {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			continue
+		}
+		converged = message == convergedStatusPrefix+"true" && now.Sub(at) <= convergedStatusMaxAge
+	}
+	return converged, scanner.Err()
+}
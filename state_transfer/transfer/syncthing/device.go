@@ -0,0 +1,98 @@
+package syncthing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base32"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// luhnBase32 is the alphabet of device IDs, the base32 alphabet of RFC 4648
+	luhnBase32 = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	// certificateCommonName is the common name syncthing gives to device certificates
+	certificateCommonName = "syncthing"
+)
+
+// GenerateCertificate returns a PEM encoded self signed certificate and its private key, usable as
+// the identity of a syncthing device
+func GenerateCertificate() ([]byte, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, nil, err
+	}
+	certTemp := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: certificateCommonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(20, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, &certTemp, &certTemp, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	return cert, key, nil
+}
+
+// DeviceID returns the syncthing device ID of the given PEM encoded certificate: the base32 encoded
+// SHA-256 hash of the certificate, with a Luhn check character after every 13 characters, in
+// groups of 7 characters separated by dashes
+func DeviceID(cert []byte) (string, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("invalid certificate, no PEM encoded certificate found")
+	}
+	hash := sha256.Sum256(block.Bytes)
+	id := strings.Trim(base32.StdEncoding.EncodeToString(hash[:]), "=")
+
+	var luhnified strings.Builder
+	for i := 0; i < 4; i++ {
+		group := id[i*13 : (i+1)*13]
+		luhnified.WriteString(group)
+		luhnified.WriteByte(luhn32(group))
+	}
+
+	id = luhnified.String()
+	groups := []string{}
+	for i := 0; i < len(id); i += 7 {
+		groups = append(groups, id[i:i+7])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// luhn32 returns the Luhn mod 32 check character of a base32 string
+func luhn32(s string) byte {
+	const n = len(luhnBase32)
+	factor, sum := 1, 0
+	for i := range s {
+		addend := factor * strings.IndexByte(luhnBase32, s[i])
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+		sum += addend/n + addend%n
+	}
+	return luhnBase32[(n-sum%n)%n]
+}
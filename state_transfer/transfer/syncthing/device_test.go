@@ -0,0 +1,45 @@
+package syncthing
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLuhn32(t *testing.T) {
+	// groups of the example device ID of the syncthing documentation
+	// MFZWI3D-BONSGYC-YLTMRWG-C43ENR5-QXGZDMM-FZWI3DP-BONSGYY-LTMRWAD
+	tests := map[string]byte{
+		"MFZWI3DBONSGY": 'C',
+		"YLTMRWGC43ENR": '5',
+		"QXGZDMMFZWI3D": 'P',
+		"BONSGYYLTMRWA": 'D',
+	}
+	for group, want := range tests {
+		if got := luhn32(group); got != want {
+			t.Errorf("luhn32(%s) = %c, want %c", group, got, want)
+		}
+	}
+}
+
+func TestDeviceID(t *testing.T) {
+	cert, key, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("GenerateCertificate() unexpected error %v", err)
+	}
+	if len(key) == 0 {
+		t.Fatalf("GenerateCertificate() returned an empty key")
+	}
+	id, err := DeviceID(cert)
+	if err != nil {
+		t.Fatalf("DeviceID() unexpected error %v", err)
+	}
+	if !regexp.MustCompile(`^([A-Z2-7]{7}-){7}[A-Z2-7]{7}$`).MatchString(id) {
+		t.Errorf("DeviceID() = %s is not formatted like a device ID", id)
+	}
+	if again, _ := DeviceID(cert); again != id {
+		t.Errorf("DeviceID() is not stable, got %s and %s", id, again)
+	}
+	if _, err := DeviceID(key); err == nil {
+		t.Errorf("DeviceID() should fail for a private key")
+	}
+}
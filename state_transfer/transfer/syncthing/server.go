@@ -0,0 +1,114 @@
+package syncthing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	serverComponent = "server"
+	clientComponent = "client"
+)
+
+// CreateServer creates the syncthing server, it listens on the exposed port of the transport. The
+// identities of both devices are read from the Secrets of a server created before, they are
+// generated and stored in the destination namespace otherwise.
+func (s *SyncthingTransfer) CreateServer(ctx context.Context, c client.Client) error {
+	log := s.log.WithValues("namespace", s.pvcList.GetDestinationNamespaces()[0])
+	err := s.createServer(ctx, meta.NewLoggingClient(c, log))
+	if err != nil {
+		log.Error(err, "unable to create syncthing server")
+	}
+	return err
+}
+
+func (s *SyncthingTransfer) createServer(ctx context.Context, c client.Client) error {
+	found, err := s.loadDevices(ctx, c)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err := s.generateDevices(); err != nil {
+			return err
+		}
+	}
+
+	err = createSyncthingServer(ctx, c, s)
+	if err != nil {
+		return err
+	}
+
+	_, err = endpoint.Create(ctx, s.Endpoint(), c)
+	return err
+}
+
+func (s *SyncthingTransfer) IsServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	healthy, err := s.isServerHealthy(ctx, c)
+	meta.LogHealthCheck(s.log.WithValues("namespace", s.pvcList.GetDestinationNamespaces()[0]), healthy, err)
+	return healthy, err
+}
+
+func (s *SyncthingTransfer) isServerHealthy(ctx context.Context, c client.Client) (bool, error) {
+	containers := append([]string{SyncthingContainer}, transport.ServerContainerNames(s.Transport())...)
+	return transfer.AreFilteredPodsHealthy(ctx, c, s.pvcList.GetDestinationNamespaces()[0], s.podLabels(serverComponent), containers...)
+}
+
+func createSyncthingServer(ctx context.Context, c client.Client, s *SyncthingTransfer) error {
+	namespace := s.pvcList.GetDestinationNamespaces()[0]
+	labels := s.podLabels(serverComponent)
+	folders, pvcs := s.folders(transfer.PVCPair.Destination)
+
+	folderType := folderTypeSendReceive
+	if s.transferOptions.OneWay {
+		folderType = folderTypeReceiveOnly
+	}
+	serverConfig := &config{
+		Name:         serverComponent,
+		DeviceID:     s.server.id,
+		PeerName:     clientComponent,
+		PeerDeviceID: s.client.id,
+		// the server does not dial the client
+		PeerAddress:   "dynamic",
+		ListenAddress: fmt.Sprintf("tcp://:%d", s.Transport().ExposedPort()),
+		GUIAddress:    syncthingGUIAddress,
+		APIKey:        s.server.apiKey,
+		FolderType:    folderType,
+		Folders:       folders,
+	}
+	rendered, err := serverConfig.render()
+	if err != nil {
+		return err
+	}
+	s.log.V(1).Info("rendered syncthing server config", "namespace", namespace, "folders", len(folders))
+
+	// the identity of the client is not mounted in the server pods
+	err = createSecret(ctx, c, syncthingIdentityName, namespace, labels, deviceSecretData(s.client, s.server))
+	if err != nil {
+		return err
+	}
+	data := deviceSecretData(s.server, s.client)
+	data[configKey] = []byte(rendered)
+	err = createSecret(ctx, c, syncthingServerName, namespace, labels, data)
+	if err != nil {
+		return err
+	}
+
+	probe := &v1.Probe{
+		Handler: v1.Handler{
+			Exec: &v1.ExecAction{
+				Command: []string{"wget", "-qO", "/dev/null", "http://" + syncthingGUIAddress + "/rest/noauth/health"},
+			},
+		},
+		PeriodSeconds: 10,
+	}
+	return s.createDeployment(ctx, c, syncthingServerName, namespace, labels, pvcs, probe,
+		s.Transport().ServerContainers(), s.Transport().ServerVolumes())
+}
@@ -0,0 +1,381 @@
+package syncthing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SyncthingContainer is the name of the syncthing container in transfer pods
+	SyncthingContainer = "syncthing"
+)
+
+const (
+	syncthingImage        = "docker.io/syncthing/syncthing:latest"
+	syncthingServerName   = "crane2-syncthing-server"
+	syncthingClientName   = "crane2-syncthing-client"
+	syncthingSecretPath   = "/etc/syncthing"
+	syncthingHomePath     = "/var/syncthing/config"
+	syncthingDataPath     = "/data"
+	syncthingClientListen = "tcp://127.0.0.1:22000"
+	syncthingGUIAddress   = "127.0.0.1:8384"
+	syncthingComponent    = "syncthing"
+	homeVolumeName        = "home"
+	apiKeyLength          = 32
+
+	// syncthingIdentityName is the Secret of the destination the identity of the client is kept
+	// in, so that the client can be created by another process than the server
+	syncthingIdentityName = "crane2-syncthing-client-identity"
+)
+
+// keys of the Secrets of the server and the client
+const (
+	certKey         = "cert.pem"
+	keyKey          = "key.pem"
+	configKey       = "config.xml"
+	apiKeyKey       = "api-key"
+	deviceIDKey     = "device-id"
+	peerDeviceIDKey = "peer-device-id"
+	convergedKey    = "converged.sh"
+)
+
+// TransferOptions are the options of a syncthing transfer
+type TransferOptions struct {
+	// Image is the syncthing image of the transfer pods, defaults to the upstream syncthing image
+	Image string
+	// OneWay only synchronizes changes from the source to the destination, changes made in the
+	// destination are not sent to the source. Both sides send their changes by default.
+	OneWay bool
+}
+
+func (t *TransferOptions) getImage() string {
+	if t.Image == "" {
+		return syncthingImage
+	}
+	return t.Image
+}
+
+// device is the identity of one side of the transfer
+type device struct {
+	cert   []byte
+	key    []byte
+	id     string
+	apiKey string
+}
+
+// SyncthingTransfer keeps the source and the destination PVCs synchronized with Syncthing until it
+// is torn down, rather than copying them once. The server on the destination listens through the
+// transport and the endpoint, the client on the source connects to it. Each PVC is a folder shared
+// by both devices, the devices only accept each other. Their certificates are generated when the
+// server is created and stored in Secrets of the destination namespace, so the server must be
+// created before the client, possibly by another process. Use IsConverged to know when both sides
+// hold the same data, e.g. to cut over to the destination.
+type SyncthingTransfer struct {
+	source          client.Client
+	destination     client.Client
+	pvcList         transfer.PVCPairList
+	transport       transport.Transport
+	endpoint        endpoint.Endpoint
+	transferOptions *TransferOptions
+	server          *device
+	client          *device
+	log             logr.Logger
+}
+
+func NewTransfer(t transport.Transport, e endpoint.Endpoint, src client.Client, dest client.Client, pvcList transfer.PVCPairList, log logr.Logger, options *TransferOptions) (transfer.Transfer, error) {
+	if options == nil {
+		options = &TransferOptions{}
+	}
+	err := validatePVCList(pvcList)
+	if err != nil {
+		return nil, err
+	}
+	if err := transport.ValidateCompatibility(endpoint.ProtocolTCP, t, e); err != nil {
+		return nil, err
+	}
+	return &SyncthingTransfer{
+		log:             meta.LoggerOrDiscard(log).WithValues("transfer", "syncthing"),
+		transport:       t,
+		endpoint:        e,
+		source:          src,
+		destination:     dest,
+		pvcList:         pvcList,
+		transferOptions: options,
+	}, nil
+}
+
+func (s *SyncthingTransfer) PVCs() transfer.PVCPairList {
+	return s.pvcList
+}
+
+func (s *SyncthingTransfer) Endpoint() endpoint.Endpoint {
+	return s.endpoint
+}
+
+func (s *SyncthingTransfer) Transport() transport.Transport {
+	return s.transport
+}
+
+// Copy returns a copy of the transfer with copies of its endpoint and transport, see
+// transfer.Copier
+func (s *SyncthingTransfer) Copy() transfer.Transfer {
+	copied := *s
+	copied.endpoint = endpoint.Copy(s.endpoint)
+	copied.transport = transport.Copy(s.transport)
+	return &copied
+}
+
+func (s *SyncthingTransfer) Source() client.Client {
+	return s.source
+}
+
+func (s *SyncthingTransfer) Destination() client.Client {
+	return s.destination
+}
+
+// ServerDeviceID returns the device ID of the server, empty until the server or the client is created
+func (s *SyncthingTransfer) ServerDeviceID() string {
+	if s.server == nil {
+		return ""
+	}
+	return s.server.id
+}
+
+// ClientDeviceID returns the device ID of the client, empty until the server or the client is created
+func (s *SyncthingTransfer) ClientDeviceID() string {
+	if s.client == nil {
+		return ""
+	}
+	return s.client.id
+}
+
+// loadDevices reads the identities of the server and the client from the Secrets the server
+// stored in the destination namespace, c must point at the destination cluster. It returns false
+// when the server was not created yet.
+func (s *SyncthingTransfer) loadDevices(ctx context.Context, c client.Client) (bool, error) {
+	if s.server != nil && s.client != nil {
+		return true, nil
+	}
+	namespace := s.pvcList.GetDestinationNamespaces()[0]
+	secrets := map[string]*v1.Secret{syncthingServerName: {}, syncthingIdentityName: {}}
+	for name, secret := range secrets {
+		err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret)
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	server, err := deviceFromSecret(secrets[syncthingServerName])
+	if err != nil {
+		return false, err
+	}
+	client, err := deviceFromSecret(secrets[syncthingIdentityName])
+	if err != nil {
+		return false, err
+	}
+	s.server, s.client = server, client
+	return true, nil
+}
+
+// generateDevices generates the identities of the server and the client
+func (s *SyncthingTransfer) generateDevices() error {
+	server, err := newDevice()
+	if err != nil {
+		return err
+	}
+	client, err := newDevice()
+	if err != nil {
+		return err
+	}
+	s.server, s.client = server, client
+	return nil
+}
+
+func newDevice() (*device, error) {
+	cert, key, err := GenerateCertificate()
+	if err != nil {
+		return nil, err
+	}
+	id, err := DeviceID(cert)
+	if err != nil {
+		return nil, err
+	}
+	return &device{
+		cert:   cert,
+		key:    key,
+		id:     id,
+		apiKey: utilrand.String(apiKeyLength),
+	}, nil
+}
+
+// deviceFromSecret returns the identity of the device stored in the given Secret
+func deviceFromSecret(secret *v1.Secret) (*device, error) {
+	d := &device{
+		cert:   secret.Data[certKey],
+		key:    secret.Data[keyKey],
+		apiKey: string(secret.Data[apiKeyKey]),
+	}
+	id, err := DeviceID(d.cert)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syncthing identity in secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	d.id = id
+	return d, nil
+}
+
+// deviceSecretData returns the data of a Secret holding the identity of a device and the device
+// ID of its peer
+func deviceSecretData(self, peer *device) map[string][]byte {
+	return map[string][]byte{
+		certKey:         self.cert,
+		keyKey:          self.key,
+		apiKeyKey:       []byte(self.apiKey),
+		deviceIDKey:     []byte(self.id),
+		peerDeviceIDKey: []byte(peer.id),
+	}
+}
+
+// folderID returns the ID of the folder of the given PVC, it is the same on both sides
+func folderID(pvc transfer.PVCPair) string {
+	return "crane2-" + pvc.Source().LabelSafeName()
+}
+
+// podLabels returns the labels of the server or the client pods
+func (s *SyncthingTransfer) podLabels(component string) map[string]string {
+	labels := map[string]string{syncthingComponent: component}
+	for key, val := range s.Endpoint().Labels() {
+		labels[key] = val
+	}
+	return labels
+}
+
+// createSecret creates the Secret holding the identity and the configuration of a device
+func createSecret(ctx context.Context, c client.Client, name, namespace string, labels map[string]string, data map[string][]byte) error {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    meta.WithOwnerLabel(labels),
+		},
+		Data: data,
+	}
+	return meta.Apply(ctx, c, secret)
+}
+
+// createDeployment creates or updates a syncthing deployment with the given PVCs mounted in their folders,
+// the identity and the configuration of the device are read from the Secret of the same name
+func (s *SyncthingTransfer) createDeployment(ctx context.Context, c client.Client, name, namespace string, labels map[string]string,
+	pvcs map[string]transfer.PVC, probe *v1.Probe, transportContainers []v1.Container, transportVolumes []v1.Volume) error {
+	container := v1.Container{
+		Name:    SyncthingContainer,
+		Image:   s.transferOptions.getImage(),
+		Command: []string{"/bin/sh", "-c", syncthingStartScript},
+		Env: []v1.EnvVar{
+			{
+				Name:  "STNOUPGRADE",
+				Value: "1",
+			},
+			{
+				Name: "SYNCTHING_API_KEY",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: name},
+						Key:                  apiKeyKey,
+					},
+				},
+			},
+		},
+		ReadinessProbe: probe,
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      name,
+				MountPath: syncthingSecretPath,
+			},
+			{
+				Name:      homeVolumeName,
+				MountPath: syncthingHomePath,
+			},
+		},
+	}
+	volumes := []v1.Volume{
+		{
+			Name: name,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: name,
+				},
+			},
+		},
+		{
+			Name: homeVolumeName,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	for _, pvc := range s.pvcList {
+		id := folderID(pvc)
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      id,
+			MountPath: syncthingDataPath + "/" + id,
+		})
+		volumes = append(volumes, v1.Volume{
+			Name: id,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcs[id].Claim().Name,
+				},
+			},
+		})
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    meta.WithOwnerLabel(labels),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					Containers: append([]v1.Container{container}, transportContainers...),
+					Volumes:    append(volumes, transportVolumes...),
+				},
+			},
+		},
+	}
+
+	return meta.Apply(ctx, c, deployment)
+}
+
+// folders returns the folders of the transfer and the PVCs of one side mounted in them
+func (s *SyncthingTransfer) folders(side func(transfer.PVCPair) transfer.PVC) ([]folder, map[string]transfer.PVC) {
+	folders := []folder{}
+	pvcs := map[string]transfer.PVC{}
+	for _, pvc := range s.pvcList {
+		id := folderID(pvc)
+		folders = append(folders, folder{ID: id, Path: syncthingDataPath + "/" + id})
+		pvcs[id] = side(pvc)
+	}
+	return folders, pvcs
+}
@@ -0,0 +1,245 @@
+package syncthing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testHostname      = "test.host"
+)
+
+func TestCreateServerAndClient(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	tr := createTransfer(t, src, dest, &TransferOptions{})
+	if err := tr.CreateClient(context.TODO(), src); err == nil {
+		t.Fatalf("CreateClient() should fail when the server was not created")
+	}
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	// the deployments are reconciled when the transfer is created again
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() should not fail when the server exists: %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() should not fail when the client exists: %v", err)
+	}
+
+	serverSecret := &corev1.Secret{}
+	if err := dest.Get(context.TODO(), client.ObjectKey{Namespace: testDestNamespace, Name: syncthingServerName}, serverSecret); err != nil {
+		t.Fatalf("unable to get server secret: %v", err)
+	}
+	clientSecret := &corev1.Secret{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: syncthingClientName}, clientSecret); err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if string(serverSecret.Data[deviceIDKey]) != tr.ServerDeviceID() || string(serverSecret.Data[peerDeviceIDKey]) != tr.ClientDeviceID() {
+		t.Errorf("server secret does not hold the device IDs")
+	}
+	if string(clientSecret.Data[deviceIDKey]) != tr.ClientDeviceID() || string(clientSecret.Data[peerDeviceIDKey]) != tr.ServerDeviceID() {
+		t.Errorf("client secret does not hold the device IDs")
+	}
+	if id, err := DeviceID(serverSecret.Data[certKey]); err != nil || id != tr.ServerDeviceID() {
+		t.Errorf("server certificate does not match the server device ID")
+	}
+
+	serverConfig := string(serverSecret.Data[configKey])
+	clientConfig := string(clientSecret.Data[configKey])
+	for _, pvc := range tr.PVCs() {
+		want := `<folder id="` + folderID(pvc) + `" label="` + folderID(pvc) + `" path="/data/` + folderID(pvc) + `" type="sendreceive"`
+		for _, config := range []string{serverConfig, clientConfig} {
+			if !strings.Contains(config, want) {
+				t.Errorf("config does not contain %s:\n%s", want, config)
+			}
+		}
+	}
+	if want := "<listenAddress>tcp://:6443</listenAddress>"; !strings.Contains(serverConfig, want) {
+		t.Errorf("server config does not contain %s:\n%s", want, serverConfig)
+	}
+	if want := "<address>tcp://" + testHostname + ":6443</address>"; !strings.Contains(clientConfig, want) {
+		t.Errorf("client config does not contain %s:\n%s", want, clientConfig)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: syncthingClientName}, deployment); err != nil {
+		t.Fatalf("unable to get client deployment: %v", err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if len(container.VolumeMounts) != 4 || len(deployment.Spec.Template.Spec.Volumes) != 4 {
+		t.Errorf("client deployment should mount its secret, its home and both pvcs: %v", container.VolumeMounts)
+	}
+	if probe := container.ReadinessProbe.Exec.Command; probe[1] != syncthingSecretPath+"/"+convergedKey {
+		t.Errorf("client readiness probe should run the converged check: %v", probe)
+	}
+	script := string(clientSecret.Data[convergedKey])
+	for _, want := range []string{"device=" + tr.ServerDeviceID(), "db/status?folder="} {
+		if !strings.Contains(script, want) {
+			t.Errorf("client converged check does not contain %s:\n%s", want, script)
+		}
+	}
+	if _, ok := serverSecret.Data[convergedKey]; ok {
+		t.Errorf("server secret should not hold the converged check")
+	}
+}
+
+func TestCreateClientFromAnotherTransfer(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	server := createTransfer(t, src, dest, &TransferOptions{})
+	if err := transfer.CreateServer(context.TODO(), server); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	// the client is created by a transfer which did not create the server, e.g. after a restart
+	tr := createTransfer(t, src, dest, &TransferOptions{})
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	if tr.ServerDeviceID() != server.ServerDeviceID() || tr.ClientDeviceID() != server.ClientDeviceID() {
+		t.Errorf("the devices were not loaded from the secrets of the server")
+	}
+	clientSecret := &corev1.Secret{}
+	if err := src.Get(context.TODO(), client.ObjectKey{Namespace: testNamespace, Name: syncthingClientName}, clientSecret); err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if id, err := DeviceID(clientSecret.Data[certKey]); err != nil || id != server.ClientDeviceID() {
+		t.Errorf("client certificate does not match the client device ID generated with the server")
+	}
+}
+
+func TestOneWay(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	tr := createTransfer(t, src, dest, &TransferOptions{OneWay: true})
+	if err := transfer.CreateServer(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := transfer.CreateClient(context.TODO(), tr); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+	secrets := map[client.ObjectKey]client.Client{
+		{Namespace: testDestNamespace, Name: syncthingServerName}: dest,
+		{Namespace: testNamespace, Name: syncthingClientName}:     src,
+	}
+	configs := map[string]string{}
+	for key, c := range secrets {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), key, secret); err != nil {
+			t.Fatalf("unable to get secret %s: %v", key, err)
+		}
+		configs[key.Name] = string(secret.Data[configKey])
+		if key.Name == syncthingClientName && strings.Contains(string(secret.Data[convergedKey]), "db/status") {
+			t.Errorf("one way transfers should not check the source folders:\n%s", secret.Data[convergedKey])
+		}
+	}
+	if want := `type="receiveonly"`; !strings.Contains(configs[syncthingServerName], want) {
+		t.Errorf("server config does not contain %s:\n%s", want, configs[syncthingServerName])
+	}
+	if want := `type="sendonly"`; !strings.Contains(configs[syncthingClientName], want) {
+		t.Errorf("client config does not contain %s:\n%s", want, configs[syncthingClientName])
+	}
+}
+
+func TestIsConverged(t *testing.T) {
+	src, dest := buildTestClient(), buildTestClient()
+	tr := createTransfer(t, src, dest, &TransferOptions{})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "client",
+			Namespace: testNamespace,
+			Labels:    tr.podLabels(clientComponent),
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if err := src.Create(context.TODO(), pod); err != nil {
+		t.Fatalf("unable to create pod: %v", err)
+	}
+	// the fake clientset serves logs without any check
+	clientset := fakeclientset.NewSimpleClientset(pod)
+	if converged, err := tr.IsConverged(context.TODO(), src, clientset.CoreV1()); converged || err != nil {
+		t.Errorf("IsConverged() = %v, %v before the client reported a check", converged, err)
+	}
+}
+
+func TestParseConverged(t *testing.T) {
+	now := time.Date(2021, 7, 1, 10, 0, 30, 0, time.UTC)
+	tests := []struct {
+		name string
+		logs string
+		want bool
+	}{
+		{
+			name: "no check",
+			logs: "2021-07-01T10:00:00.000000000Z [ABCDE] INFO: My ID: ABCDE\n",
+		},
+		{
+			name: "converged",
+			logs: "2021-07-01T10:00:10.000000000Z " + convergedStatusPrefix + "false\n2021-07-01T10:00:20.000000000Z " + convergedStatusPrefix + "true\n",
+			want: true,
+		},
+		{
+			name: "diverged after converging",
+			logs: "2021-07-01T10:00:10.000000000Z " + convergedStatusPrefix + "true\n2021-07-01T10:00:20.000000000Z " + convergedStatusPrefix + "false\n",
+		},
+		{
+			name: "stale check",
+			logs: "2021-07-01T09:50:00.000000000Z " + convergedStatusPrefix + "true\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConverged(strings.NewReader(tt.logs), now)
+			if err != nil || got != tt.want {
+				t.Errorf("parseConverged() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func buildTestClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+}
+
+func createTransfer(t *testing.T, src, dest client.Client, options *TransferOptions) *SyncthingTransfer {
+	pvcList := transfer.PVCPairList{}
+	for _, name := range []string{"pvc-1", "pvc-2"} {
+		pvcList = append(pvcList, transfer.NewPVCPair(
+			&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}},
+			&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: testDestNamespace, Name: name}},
+		))
+	}
+	var e endpoint.Endpoint = service.NewEndpoint(types.NamespacedName{Namespace: testDestNamespace, Name: "syncthing"},
+		statetransfermeta.Labels, testHostname, corev1.ServiceTypeLoadBalancer, nil)
+	tp := null.NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: "syncthing"},
+		types.NamespacedName{Namespace: testDestNamespace, Name: "syncthing"},
+	), nil)
+	if _, err := transport.CreateServer(context.TODO(), tp, dest, "fs", e); err != nil {
+		t.Fatalf("unable to create transport server: %v", err)
+	}
+	tr, err := NewTransfer(tp, e, src, dest, pvcList, nil, options)
+	if err != nil {
+		t.Fatalf("NewTransfer() unexpected error %v", err)
+	}
+	return tr.(*SyncthingTransfer)
+}
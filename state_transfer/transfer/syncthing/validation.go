@@ -0,0 +1,54 @@
+package syncthing
+
+import (
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/transfer"
+	corev1 "k8s.io/api/core/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	kubeVirtAnnKey      = "cdi.kubevirt.io/storage.contentType"
+	kubevirtContentType = "kubevirt"
+)
+
+// validatePVCList validates list of PVCs provided to syncthing transfer
+// list cannot contain pvcs belonging to two or more source/destination namespaces
+// list must contain at least one pvc
+// labelSafeNames of all pvcs must be valid label values
+// pvcs must be filesystem volumes
+func validatePVCList(pvcList transfer.PVCPairList) error {
+	validationErrors := []error{}
+
+	srcNamespaces := pvcList.GetSourceNamespaces()
+	destNamespaces := pvcList.GetDestinationNamespaces()
+	if len(srcNamespaces) > 1 || len(destNamespaces) > 1 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("syncthing transfer does not support migrating PVCs belonging to multiple source/destination namespaces"))
+	}
+	if len(pvcList) == 0 {
+		validationErrors = append(validationErrors, fmt.Errorf("at least one pvc must be provided"))
+	}
+	for _, pvcPair := range pvcList {
+		for _, pvc := range []transfer.PVC{pvcPair.Source(), pvcPair.Destination()} {
+			if errs := validation.IsValidLabelValue(pvc.LabelSafeName()); len(errs) > 0 {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("labelSafeName() for %s must be a valid label value", pvc.Claim().Name))
+			}
+			if isBlockOrKubeVirtDisk(pvc.Claim()) {
+				validationErrors = append(validationErrors,
+					fmt.Errorf("syncthing transfer does not support block or VM disk volume %s", pvc.Claim().Name))
+			}
+		}
+	}
+	return errorsutil.NewAggregate(validationErrors)
+}
+
+func isBlockOrKubeVirtDisk(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		return true
+	}
+	return pvc.GetAnnotations()[kubeVirtAnnKey] == kubevirtContentType
+}
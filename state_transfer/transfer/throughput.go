@@ -0,0 +1,134 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultThroughputSamples is the number of samples kept when no size is given
+	DefaultThroughputSamples = 360
+	// MaxThroughputSamples is the maximum number of samples a ThroughputMonitor keeps
+	MaxThroughputSamples = 10000
+)
+
+// ThroughputSample is the throughput of a transfer observed at a point in time
+type ThroughputSample struct {
+	// Time is when the sample was taken
+	Time time.Time
+	// BytesTransferred is the amount of data transferred when the sample was taken
+	BytesTransferred int64
+	// BytesPerSecond is the average rate since the previous sample, or since the transfer
+	// started for the first sample
+	BytesPerSecond float64
+}
+
+// ThroughputMonitor periodically samples the progress of a transfer and keeps the most recent
+// samples in a bounded ring buffer, so that a transfer which is consistently slow can be told
+// apart from one which stalled and then recovered
+type ThroughputMonitor struct {
+	mu       sync.Mutex
+	samples  []ThroughputSample
+	next     int
+	full     bool
+	previous *ThroughputSample
+}
+
+// NewThroughputMonitor returns a monitor keeping at most size samples, DefaultThroughputSamples
+// when size is not positive
+func NewThroughputMonitor(size int) (*ThroughputMonitor, error) {
+	if size <= 0 {
+		size = DefaultThroughputSamples
+	}
+	if size > MaxThroughputSamples {
+		return nil, fmt.Errorf("throughput history size %d exceeds the maximum of %d", size, MaxThroughputSamples)
+	}
+	return &ThroughputMonitor{samples: make([]ThroughputSample, size)}, nil
+}
+
+// StartThroughputMonitor samples the progress of the transfer every interval in a background
+// goroutine, until the context is done or the transfer reaches a final phase. The transfer must
+// implement ProgressReporter, the client must be able to reach the cluster its client runs in.
+func StartThroughputMonitor(ctx context.Context, t Transfer, c client.Client, interval time.Duration, size int) (*ThroughputMonitor, error) {
+	reporter, ok := t.(ProgressReporter)
+	if !ok {
+		return nil, fmt.Errorf("transfer does not report progress")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("throughput sampling interval must be positive, got %s", interval)
+	}
+	m, err := NewThroughputMonitor(size)
+	if err != nil {
+		return nil, err
+	}
+	go m.run(ctx, t, reporter, c, interval)
+	return m, nil
+}
+
+// run samples the progress until the context is done or the transfer reached a final phase, errors
+// reading the progress are ignored as the next sample may succeed
+func (m *ThroughputMonitor) run(ctx context.Context, t Transfer, reporter ProgressReporter, c client.Client, interval time.Duration) {
+	_ = wait.PollImmediateUntil(interval, func() (bool, error) {
+		if progress, err := reporter.Progress(ctx, c); err == nil {
+			m.Record(progress, time.Now())
+		}
+		return isFinished(ctx, t, c), nil
+	}, ctx.Done())
+}
+
+// isFinished returns whether the transfer reached a final phase, transfers which do not report
+// their status are never finished
+func isFinished(ctx context.Context, t Transfer, c client.Client) bool {
+	reporter, ok := t.(StatusReporter)
+	if !ok {
+		return false
+	}
+	status, err := reporter.Status(ctx, c)
+	if err != nil {
+		return false
+	}
+	switch status.Phase {
+	case TransferPhaseSucceeded, TransferPhaseFailed, TransferPhaseCancelled:
+		return true
+	}
+	return false
+}
+
+// Record adds a sample for the given progress observed at now, the oldest sample is dropped
+// when the history is full
+func (m *ThroughputMonitor) Record(progress *Progress, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sample := ThroughputSample{Time: now, BytesTransferred: progress.BytesTransferred}
+	switch {
+	case m.previous != nil:
+		if elapsed := now.Sub(m.previous.Time).Seconds(); elapsed > 0 {
+			sample.BytesPerSecond = float64(progress.BytesTransferred-m.previous.BytesTransferred) / elapsed
+		}
+	case !progress.StartTime.IsZero():
+		if elapsed := now.Sub(progress.StartTime).Seconds(); elapsed > 0 {
+			sample.BytesPerSecond = float64(progress.BytesTransferred) / elapsed
+		}
+	}
+	m.samples[m.next] = sample
+	m.previous = &sample
+	m.next = (m.next + 1) % len(m.samples)
+	if m.next == 0 {
+		m.full = true
+	}
+}
+
+// ThroughputHistory returns the recorded samples, oldest first
+func (m *ThroughputMonitor) ThroughputHistory() []ThroughputSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.full {
+		return append([]ThroughputSample{}, m.samples[:m.next]...)
+	}
+	return append(append([]ThroughputSample{}, m.samples[m.next:]...), m.samples[:m.next]...)
+}
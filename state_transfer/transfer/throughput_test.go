@@ -0,0 +1,63 @@
+package transfer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// progressTransfer is a transfer which only reports a fixed progress
+type progressTransfer struct {
+	Transfer
+	progress *Progress
+}
+
+func (p *progressTransfer) Progress(ctx context.Context, c client.Client) (*Progress, error) {
+	return p.progress, nil
+}
+
+func TestThroughputMonitor(t *testing.T) {
+	m, err := NewThroughputMonitor(3)
+	if err != nil {
+		t.Fatalf("NewThroughputMonitor() unexpected error %v", err)
+	}
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i, bytes := range []int64{100, 300, 300, 1300} {
+		m.Record(&Progress{BytesTransferred: bytes, StartTime: start}, start.Add(time.Duration(i+1)*10*time.Second))
+	}
+	history := m.ThroughputHistory()
+	// the first sample was dropped as the history only keeps 3 samples
+	want := []float64{20, 0, 100}
+	if len(history) != len(want) {
+		t.Fatalf("ThroughputHistory() returned %d samples, want %d", len(history), len(want))
+	}
+	for i, sample := range history {
+		if sample.BytesPerSecond != want[i] {
+			t.Errorf("sample %d rate = %v, want %v", i, sample.BytesPerSecond, want[i])
+		}
+	}
+	if !history[0].Time.Before(history[2].Time) {
+		t.Errorf("samples should be ordered oldest first")
+	}
+
+	if _, err := NewThroughputMonitor(MaxThroughputSamples + 1); err == nil {
+		t.Errorf("NewThroughputMonitor() should reject sizes above the maximum")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := &progressTransfer{progress: &Progress{BytesTransferred: 10, StartTime: time.Now()}}
+	m, err = StartThroughputMonitor(ctx, tr, nil, time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("StartThroughputMonitor() unexpected error %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.ThroughputHistory()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(m.ThroughputHistory()) < 2 {
+		t.Errorf("the background monitor did not record samples")
+	}
+}
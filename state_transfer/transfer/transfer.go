@@ -2,11 +2,13 @@ package transfer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
-	routev1 "github.com/openshift/api/route/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -15,6 +17,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrPodNotReady is returned when the containers of a transfer pod are not ready yet
+var ErrPodNotReady = errors.New("pod not ready")
+
 // Transfer knows how to transfer PV data from a source to a destination
 type Transfer interface {
 	// Source returns a source client
@@ -26,27 +31,76 @@ type Transfer interface {
 	// Transport returns the transport used by the transfer
 	Transport() transport.Transport
 	// CreateServer creates a transfer server either on source or the destination
-	CreateServer(client.Client) error
+	CreateServer(context.Context, client.Client) error
 	// CreateClient creates a transfer client either on source or the destination
-	CreateClient(client.Client) error
-	IsServerHealthy(c client.Client) (bool, error)
+	CreateClient(context.Context, client.Client) error
+	IsServerHealthy(ctx context.Context, c client.Client) (bool, error)
 	// PVCs returns the list of PVCs the transfer will migrate
 	PVCs() PVCPairList
 }
 
-func CreateServer(t Transfer) error {
-	scheme := runtime.NewScheme()
-	if err := routev1.AddToScheme(scheme); err != nil {
-		return err
+// SchemeProvider is implemented by transfers which were given a pre-configured scheme
+type SchemeProvider interface {
+	// Scheme returns the scheme supplied by the caller, nil when none was supplied
+	Scheme() *runtime.Scheme
+}
+
+// Copier is implemented by transfers which can be copied along with their endpoint and transport,
+// so that their resources can be rendered without changing them, see endpoint.Copier and
+// transport.Copier
+type Copier interface {
+	// Copy returns a copy of the transfer, creating the copy leaves the transfer unchanged
+	Copy() Transfer
+}
+
+// Copy returns a copy of the given Transfer, transfers which do not implement Copier are returned
+// as they are
+func Copy(t Transfer) Transfer {
+	if c, ok := t.(Copier); ok {
+		return c.Copy()
 	}
-	if err := appsv1.AddToScheme(scheme); err != nil {
-		return err
+	return t
+}
+
+// Scheme returns a scheme with the API types used by the given transfer, such as the scheme its
+// resources are rendered with by ExportManifests. When the transfer was given a scheme, a copy of
+// it is returned, otherwise a new scheme with the apps and core API types is built. The API types
+// of the endpoint in use are added in both cases, the scheme given to the transfer is left
+// unchanged. The clients of the transfer keep their own scheme, build the destination client with
+// the returned scheme when it must handle the API types of the endpoint.
+func Scheme(t Transfer) (*runtime.Scheme, error) {
+	var scheme *runtime.Scheme
+	if p, ok := t.(SchemeProvider); ok && p.Scheme() != nil {
+		scheme = copyScheme(p.Scheme())
+	} else {
+		scheme = runtime.NewScheme()
+		if err := appsv1.AddToScheme(scheme); err != nil {
+			return nil, err
+		}
+		if err := corev1.AddToScheme(scheme); err != nil {
+			return nil, err
+		}
 	}
-	if err := corev1.AddToScheme(scheme); err != nil {
-		return err
+	// only register API types of the endpoint in use, e.g. Routes are only
+	// registered for Route endpoints so that non OpenShift destinations work
+	if err := endpoint.AddToScheme(t.Endpoint(), scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// copyScheme returns a new scheme with the API types registered in s, conversion and defaulting
+// functions are not copied as rendering does not use them
+func copyScheme(s *runtime.Scheme) *runtime.Scheme {
+	copied := runtime.NewScheme()
+	for gvk, t := range s.AllKnownTypes() {
+		copied.AddKnownTypeWithName(gvk, reflect.New(t).Interface().(runtime.Object))
 	}
+	return copied
+}
 
-	err := t.CreateServer(t.Destination())
+func CreateServer(ctx context.Context, t Transfer) error {
+	err := t.CreateServer(ctx, t.Destination())
 	if err != nil {
 		return err
 	}
@@ -54,25 +108,74 @@ func CreateServer(t Transfer) error {
 	return nil
 }
 
-func DeleteServer(t Transfer) error {
-	return nil
+// DeleteServer deletes the resources created in the destination cluster by CreateServer: the server
+// pods or deployments with their ConfigMaps and Secrets, the transport server resources created
+// with the given prefix and the Services, Routes or Ingresses of the endpoint. Only resources
+// carrying the owner label of the library are deleted, and never PVCs nor the Secrets shared with
+// other transfers through SecretPrefix. It does not wait for the resources to be gone, use
+// Finalize for that.
+func DeleteServer(ctx context.Context, t Transfer, transportPrefix string) error {
+	destination, _, err := render(ctx, t, transportPrefix)
+	if err != nil {
+		return err
+	}
+	return deleteOwnedObjects(ctx, t.Destination(), t, destination)
 }
 
-func CreateClient(t Transfer) error {
-	return t.CreateClient(t.Source())
+func CreateClient(ctx context.Context, t Transfer) error {
+	return t.CreateClient(ctx, t.Source())
 }
 
-func DeleteClient(t Transfer) error {
-	return nil
+// RenderServer returns the resources CreateServer would create in the destination cluster
+// without creating them, see meta.NewRenderClient. The endpoint and the transport server are
+// not included unless the transfer creates them itself. A copy of the transfer is rendered, see
+// Copy.
+func RenderServer(ctx context.Context, t Transfer) ([]client.Object, error) {
+	c := meta.NewRenderClient(t.Destination())
+	if err := Copy(t).CreateServer(ctx, c); err != nil {
+		return nil, err
+	}
+	return c.Objects(), nil
+}
+
+// RenderClient returns the resources CreateClient would create in the source cluster without
+// creating them, see meta.NewRenderClient. Like RenderServer, a copy of the transfer is rendered.
+func RenderClient(ctx context.Context, t Transfer) ([]client.Object, error) {
+	c := meta.NewRenderClient(t.Source())
+	if err := Copy(t).CreateClient(ctx, c); err != nil {
+		return nil, err
+	}
+	return c.Objects(), nil
+}
+
+// DeleteClient deletes the resources created in the source cluster by CreateClient, including the
+// client pods of previous attempts and the transport client resources created with the given
+// prefix. Like DeleteServer, only resources carrying the owner label of the library are deleted
+// and PVCs and shared Secrets are kept.
+func DeleteClient(ctx context.Context, t Transfer, transportPrefix string) error {
+	_, source, err := render(ctx, t, transportPrefix)
+	if err != nil {
+		return err
+	}
+	return deleteOwnedObjects(ctx, t.Source(), t, source)
 }
 
+// ConnectionHostname returns the hostname a transfer client connects to. For direct
+// transports this is the hostname advertised by the endpoint, which may differ from
+// the address the server binds to, or the DNS name of the Service of cluster local
+// endpoints so that transfers within a cluster only use its internal network.
 func ConnectionHostname(t Transfer) string {
 	if t.Transport().Direct() {
+		if endpoint.IsClusterLocal(t.Endpoint()) {
+			return endpoint.ClusterLocalHostname(t.Endpoint().NamespacedName())
+		}
 		return t.Endpoint().Hostname()
 	}
 	return "localhost"
 }
 
+// ConnectionPort returns the port a transfer client connects to. For direct
+// transports this is the port advertised by the endpoint.
 func ConnectionPort(t Transfer) int32 {
 	if t.Transport().Direct() {
 		return t.Endpoint().ExposedPort()
@@ -81,38 +184,60 @@ func ConnectionPort(t Transfer) int32 {
 }
 
 // IsPodHealthy is a utility function that can be used by various
-// implementations to check if the server pod deployed is healthy
-func IsPodHealthy(c client.Client, pod client.ObjectKey) (bool, error) {
+// implementations to check if the server pod deployed is healthy. The
+// containers are looked up by name, when no names are given all containers
+// of the pod must be ready.
+func IsPodHealthy(ctx context.Context, c client.Client, pod client.ObjectKey, containers ...string) (bool, error) {
 	p := &corev1.Pod{}
 
-	err := c.Get(context.Background(), pod, p)
+	err := c.Get(ctx, pod, p)
 	if err != nil {
 		return false, err
 	}
 
-	return areContainersReady(p)
+	return areContainersReady(p, containers)
 }
 
-func areContainersReady(pod *corev1.Pod) (bool, error) {
-	if len(pod.Status.ContainerStatuses) != 2 {
-		return false, fmt.Errorf("expected two container statuses found %d, for pod %s", len(pod.Status.ContainerStatuses), client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name})
+func areContainersReady(pod *corev1.Pod, containers []string) (bool, error) {
+	key := client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}
+	if len(containers) == 0 {
+		if len(pod.Status.ContainerStatuses) == 0 {
+			return false, fmt.Errorf("%w: no container statuses found for pod %s", ErrPodNotReady, key)
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			containers = append(containers, containerStatus.Name)
+		}
 	}
 
-	for _, containerStatus := range pod.Status.ContainerStatuses {
+	for _, name := range containers {
+		containerStatus := findContainerStatus(pod, name)
+		if containerStatus == nil {
+			return false, fmt.Errorf("%w: container %s not found in pod %s", ErrPodNotReady, name, key)
+		}
 		if !containerStatus.Ready {
-			return false, fmt.Errorf("container %s in pod %s is not ready", containerStatus.Name, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name})
+			return false, fmt.Errorf("%w: container %s in pod %s is not ready", ErrPodNotReady, name, key)
 		}
 	}
 	return true, nil
 }
 
+func findContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
 // AreFilteredPodsHealthy is a utility function that can be used by various
 // implementations to check if the server pods deployed with some label selectors
-// are healthy. If atleast 1 replica will be healthy the function will return true
-func AreFilteredPodsHealthy(c client.Client, namespace string, labels fields.Set) (bool, error) {
+// are healthy. If atleast 1 replica will be healthy the function will return true.
+// The containers are looked up by name like in IsPodHealthy.
+func AreFilteredPodsHealthy(ctx context.Context, c client.Client, namespace string, labels fields.Set, containers ...string) (bool, error) {
 	pList := &corev1.PodList{}
 
-	err := c.List(context.Background(), pList, client.InNamespace(namespace), client.MatchingFields(labels))
+	err := c.List(ctx, pList, client.InNamespace(namespace), client.MatchingFields(labels))
 	if err != nil {
 		return false, err
 	}
@@ -120,7 +245,7 @@ func AreFilteredPodsHealthy(c client.Client, namespace string, labels fields.Set
 	errs := []error{}
 
 	for _, p := range pList.Items {
-		podReady, err := areContainersReady(&p)
+		podReady, err := areContainersReady(&p, containers)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -0,0 +1,142 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	"github.com/konveyor/crane-lib/state_transfer/transport/stunnel"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// endpointTransfer is a transfer which only has a transport and an endpoint
+type endpointTransfer struct {
+	Transfer
+	transport transport.Transport
+	endpoint  endpoint.Endpoint
+}
+
+func (e *endpointTransfer) Transport() transport.Transport {
+	return e.transport
+}
+
+func (e *endpointTransfer) Endpoint() endpoint.Endpoint {
+	return e.endpoint
+}
+
+func TestConnectionHostname(t *testing.T) {
+	name := types.NamespacedName{Namespace: "dest-namespace", Name: "rsync-server"}
+	pair := meta.NewNamespacedPair(types.NamespacedName{Namespace: "src-namespace", Name: "rsync-server"}, name)
+	tests := []struct {
+		name      string
+		transport transport.Transport
+		endpoint  endpoint.Endpoint
+		want      string
+	}{
+		{
+			name:      "direct transport through a load balancer",
+			transport: null.NewTransport(pair, nil),
+			endpoint:  service.NewEndpoint(name, nil, "lb.example.com", corev1.ServiceTypeLoadBalancer, nil),
+			want:      "lb.example.com",
+		},
+		{
+			name:      "direct transport through a cluster ip service",
+			transport: null.NewTransport(pair, nil),
+			endpoint:  service.NewClusterIPEndpoint(name, nil, nil),
+			want:      "rsync-server.dest-namespace.svc",
+		},
+		{
+			name:      "tunnelled transport through a cluster ip service",
+			transport: stunnel.NewTransport(pair, nil, &transport.Options{}),
+			endpoint:  service.NewClusterIPEndpoint(name, nil, nil),
+			want:      "localhost",
+		},
+		{
+			name:      "advertised address of a cluster ip service",
+			transport: null.NewTransport(pair, nil),
+			endpoint:  endpoint.NewAddressedEndpoint(service.NewClusterIPEndpoint(name, nil, nil), "10.0.0.1", 0, ""),
+			want:      "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.transport.Type() == null.TransportTypeNull {
+				// the null transport is direct once its server is created
+				if err := tt.transport.CreateServer(context.TODO(), nil, "fs", tt.endpoint); err != nil {
+					t.Fatalf("unable to create null transport server: %v", err)
+				}
+			}
+			tr := &endpointTransfer{transport: tt.transport, endpoint: tt.endpoint}
+			if got := ConnectionHostname(tr); got != tt.want {
+				t.Errorf("ConnectionHostname() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAreContainersReady(t *testing.T) {
+	pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "rsync", Ready: true},
+		{Name: "stunnel", Ready: false},
+	}}}
+	tests := []struct {
+		name       string
+		pod        *corev1.Pod
+		containers []string
+		wantReady  bool
+	}{
+		{name: "no container statuses", pod: &corev1.Pod{}},
+		{name: "container not found", pod: pod, containers: []string{"ssh"}},
+		{name: "container not ready", pod: pod},
+		{name: "ready", pod: pod, containers: []string{"rsync"}, wantReady: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, err := areContainersReady(tt.pod, tt.containers)
+			if ready != tt.wantReady {
+				t.Errorf("areContainersReady() = %t, want %t", ready, tt.wantReady)
+			}
+			if !tt.wantReady && !errors.Is(err, ErrPodNotReady) {
+				t.Errorf("areContainersReady() error = %v, want %v", err, ErrPodNotReady)
+			}
+		})
+	}
+}
+
+func TestDeletableObjects(t *testing.T) {
+	owned := meta.WithOwnerLabel(nil)
+	newObject := func(kind string, name string, labels map[string]string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetLabels(labels)
+		return obj
+	}
+	tr := &endpointTransfer{transport: stunnel.NewTransport(meta.NewNamespacedPair(
+		types.NamespacedName{Namespace: "src", Name: "pvc"},
+		types.NamespacedName{Namespace: "dest", Name: "pvc"},
+	), nil, &transport.Options{SecretPrefix: "shared"})}
+
+	deletable := deletableObjects(tr, []unstructured.Unstructured{
+		newObject("Pod", "rsync-server", owned),
+		newObject("Pod", "user", nil),
+		newObject("PersistentVolumeClaim", "pvc", owned),
+		newObject("Secret", "shared-crane2-stunnel-server-secret", owned),
+		newObject("Secret", "fs-crane2-rsync-server-secret", owned),
+	})
+	names := []string{}
+	for _, obj := range deletable {
+		names = append(names, obj.GetName())
+	}
+	if len(names) != 2 || names[0] != "rsync-server" || names[1] != "fs-crane2-rsync-server-secret" {
+		t.Errorf("deletableObjects() = %v, want the owned pod and the secret which is not shared", names)
+	}
+}
@@ -0,0 +1,95 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrDataMismatch is returned by Verify when files differ between the source and the destination
+	ErrDataMismatch = errors.New("source and destination data differ")
+	// ErrVerificationFailed is returned when the verification of a PVC could not run to completion
+	ErrVerificationFailed = errors.New("verification failed")
+)
+
+// FileMismatch describes a file which differs between a source PVC and its destination
+type FileMismatch struct {
+	// PVC is the source PVC the file belongs to
+	PVC types.NamespacedName
+	// Path is the path of the file relative to the root of the volume
+	Path string
+	// Reason is how the file differs, e.g. content differs or missing in destination
+	Reason string
+}
+
+func (f FileMismatch) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.PVC, f.Path, f.Reason)
+}
+
+// Verification is the outcome of the verification of the PVCs of a transfer
+type Verification struct {
+	// Completed is true once every PVC was verified
+	Completed bool
+	// MismatchCount is the number of files which differ, it is larger than the length of Mismatches
+	// when the list was truncated
+	MismatchCount int
+	// Mismatches are the files which differ, only the last ones are listed for a PVC with many
+	// differences
+	Mismatches []FileMismatch
+}
+
+// Verified returns whether every PVC was verified and no file differs
+func (v *Verification) Verified() bool {
+	return v.Completed && v.MismatchCount == 0
+}
+
+// Verifier knows how to verify the integrity of the data of a completed transfer
+type Verifier interface {
+	// CreateVerifier starts comparing the content of the source PVCs with their destination, the
+	// server of the transfer must still be running. Previous verifications are discarded.
+	CreateVerifier(ctx context.Context, c client.Client) error
+	// Verification returns the outcome of the verification started by CreateVerifier, an error
+	// wrapping ErrVerificationFailed is returned for every PVC which could not be verified
+	Verification(ctx context.Context, c client.Client) (*Verification, error)
+	// DeleteVerifier deletes the resources created by CreateVerifier
+	DeleteVerifier(ctx context.Context, c client.Client) error
+}
+
+// Verify runs an integrity pass over the data of the given transfer once its client completed, so
+// that the data can be proven identical before applications are cut over. It starts the
+// verification, polls it at the given interval until it completes and deletes its resources. An
+// error wrapping ErrDataMismatch is returned along with the verification when files differ.
+func Verify(ctx context.Context, t Transfer, interval time.Duration) (*Verification, error) {
+	verifier, ok := t.(Verifier)
+	if !ok {
+		return nil, fmt.Errorf("transfer does not support verification")
+	}
+	if err := verifier.CreateVerifier(ctx, t.Source()); err != nil {
+		return nil, err
+	}
+	var verification *Verification
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		var err error
+		verification, err = verifier.Verification(ctx, t.Source())
+		if err != nil {
+			return false, err
+		}
+		return verification.Completed, nil
+	}, ctx.Done())
+	errs := []error{err}
+	errs = append(errs, verifier.DeleteVerifier(ctx, t.Source()))
+	if err := errorsutil.NewAggregate(errs); err != nil {
+		return verification, err
+	}
+	if !verification.Verified() {
+		return verification, fmt.Errorf("%w: %d file(s)", ErrDataMismatch, verification.MismatchCount)
+	}
+	return verification, nil
+}
@@ -0,0 +1,77 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	pvcBoundPollInterval = 2 * time.Second
+)
+
+// WaitForPVCsBound waits until all source and destination PVCs of the list are Bound, so that
+// transfer pods do not hang in ContainerCreating waiting for a volume. The given client must be
+// able to reach the PVCs of both sides. PVCs using a StorageClass with WaitForFirstConsumer
+// binding are only bound once a pod uses them, they are considered ready while Pending. When
+// the timeout expires or the context is done, the error lists the PVCs which are not Bound.
+func WaitForPVCsBound(ctx context.Context, c client.Client, pvcs PVCPairList, timeout time.Duration) error {
+	pending := map[types.NamespacedName]bool{}
+	for _, pvc := range pvcs {
+		for _, p := range []PVC{pvc.Source(), pvc.Destination()} {
+			if p != nil && p.Claim() != nil {
+				pending[types.NamespacedName{Namespace: p.Claim().Namespace, Name: p.Claim().Name}] = true
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := wait.PollImmediateUntil(pvcBoundPollInterval, func() (bool, error) {
+		for key := range pending {
+			pvc := &v1.PersistentVolumeClaim{}
+			err := c.Get(ctx, key, pvc)
+			if err != nil {
+				return false, fmt.Errorf("unable to get pvc %s: %w", key, err)
+			}
+			if pvc.Status.Phase == v1.ClaimLost {
+				return false, fmt.Errorf("pvc %s lost its volume", key)
+			}
+			if pvc.Status.Phase == v1.ClaimBound || isWaitForFirstConsumer(ctx, c, pvc) {
+				delete(pending, key)
+			}
+		}
+		return len(pending) == 0, nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		names := []string{}
+		for key := range pending {
+			names = append(names, key.String())
+		}
+		sort.Strings(names)
+		return fmt.Errorf("timed out waiting for pvcs to be bound: %s", strings.Join(names, ", "))
+	}
+	return err
+}
+
+// isWaitForFirstConsumer returns whether the pvc is waiting for a pod to be scheduled before it is
+// bound, a StorageClass which cannot be read is assumed to bind immediately
+func isWaitForFirstConsumer(ctx context.Context, c client.Client, pvc *v1.PersistentVolumeClaim) bool {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false
+	}
+	storageClass := &storagev1.StorageClass{}
+	err := c.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, storageClass)
+	if err != nil {
+		return false
+	}
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+}
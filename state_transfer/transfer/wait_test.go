@@ -0,0 +1,35 @@
+package transfer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestWaitForPVCsBound(t *testing.T) {
+	bound := newTestPVC("src", "data", nil)
+	bound.Status.Phase = v1.ClaimBound
+	pending := newTestPVC("dst", "data", nil)
+	pending.Status.Phase = v1.ClaimPending
+	boundDest := newTestPVC("dst", "data", nil)
+	boundDest.Status.Phase = v1.ClaimBound
+
+	c := buildTestClient(t, bound, boundDest)
+	pvcs := PVCPairList{NewPVCPair(bound, boundDest)}
+	if err := WaitForPVCsBound(context.TODO(), c, pvcs, time.Second); err != nil {
+		t.Fatalf("WaitForPVCsBound() unexpected error %v", err)
+	}
+
+	c = buildTestClient(t, bound, pending)
+	pvcs = PVCPairList{NewPVCPair(bound, pending)}
+	err := WaitForPVCsBound(context.TODO(), c, pvcs, 100*time.Millisecond)
+	if err == nil {
+		t.Fatalf("WaitForPVCsBound() expected a timeout error")
+	}
+	if !strings.Contains(err.Error(), "dst/data") || strings.Contains(err.Error(), "src/data") {
+		t.Errorf("timeout error should only list the pending pvc: %v", err)
+	}
+}
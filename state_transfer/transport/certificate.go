@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrCertificateNotReady is returned by certificate providers while the certificate they requested
+// is not issued yet, the transport server should be created again later
+var ErrCertificateNotReady = errors.New("certificate not ready")
+
+// ErrCertMissing is returned when the certificate or key of a transport, or a key of the secret
+// holding them, is missing
+var ErrCertMissing = errors.New("certificate missing")
+
+// Certificate is a PEM encoded certificate and key, with the CA bundle clients use to verify it
+type Certificate struct {
+	Crt *bytes.Buffer
+	Key *bytes.Buffer
+	// CA is the bundle of the CA which issued the certificate, nil for self signed certificates
+	CA *bytes.Buffer
+}
+
+// CertificateProvider provides the certificate of the transport servers, so that it can be issued
+// by an external authority rather than generated by the transport
+type CertificateProvider interface {
+	// Certificate returns the certificate identified by name for the given DNS names, using c to
+	// create the resources needed to issue it in the namespace of name. The same certificate is
+	// returned for a name once it was issued. ErrCertificateNotReady is wrapped in the returned
+	// error while it is being issued.
+	Certificate(ctx context.Context, c client.Client, name types.NamespacedName, dnsNames []string) (*Certificate, error)
+}
+
+// SelfSignedCertificateProvider generates a self signed certificate with GenerateSSLCert, it is the
+// provider used when none is set. The certificate is not stored, a new one is generated every call.
+type SelfSignedCertificateProvider struct{}
+
+func (SelfSignedCertificateProvider) Certificate(ctx context.Context, c client.Client, name types.NamespacedName, dnsNames []string) (*Certificate, error) {
+	crt, _, key, err := GenerateSSLCert()
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{Crt: crt, Key: key}, nil
+}
+
+// placeholderCertificateProvider falls back to a self signed certificate while the certificate of
+// its provider is not issued, see PlaceholderCertificateProvider
+type placeholderCertificateProvider struct {
+	provider CertificateProvider
+}
+
+// PlaceholderCertificateProvider returns a provider returning the certificate of the given provider
+// once it is issued, and a self signed placeholder while it is not, so that the resources depending
+// on the certificate can be rendered before it is issued. The resources requesting the certificate
+// are still created with c. It must only be used to render resources, never to create servers.
+func PlaceholderCertificateProvider(p CertificateProvider) CertificateProvider {
+	return &placeholderCertificateProvider{provider: p}
+}
+
+func (p *placeholderCertificateProvider) Certificate(ctx context.Context, c client.Client, name types.NamespacedName, dnsNames []string) (*Certificate, error) {
+	cert, err := p.provider.Certificate(ctx, c, name, dnsNames)
+	if errors.Is(err, ErrCertificateNotReady) {
+		return SelfSignedCertificateProvider{}.Certificate(ctx, c, name, dnsNames)
+	}
+	return cert, err
+}
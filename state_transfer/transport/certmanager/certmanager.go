@@ -0,0 +1,161 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IssuerKind is the kind of the cert-manager issuer signing the certificates
+type IssuerKind string
+
+const (
+	// IssuerKindIssuer is an Issuer, it must be in the namespace of the transport server
+	IssuerKindIssuer IssuerKind = "Issuer"
+	// IssuerKindClusterIssuer is a ClusterIssuer, usable from every namespace
+	IssuerKindClusterIssuer IssuerKind = "ClusterIssuer"
+)
+
+// GroupVersion is the version of the cert-manager API the Certificates are created with
+var GroupVersion = schema.GroupVersion{Group: "cert-manager.io", Version: "v1"}
+
+// Provider is a transport.CertificateProvider requesting the certificates of the transports from a
+// cert-manager issuer with Certificate resources. cert-manager stores the issued certificate in a
+// Secret named after the Certificate, which the transport copies to its own Secrets. The
+// cert-manager types are not vendored, the Certificates are managed as unstructured objects.
+type Provider struct {
+	issuer     string
+	issuerKind IssuerKind
+	labels     map[string]string
+}
+
+// NewProvider returns a provider requesting certificates from the issuer of the given name and kind,
+// the Certificates are labelled with the given labels
+func NewProvider(issuer string, issuerKind IssuerKind, labels map[string]string) (transport.CertificateProvider, error) {
+	if issuer == "" {
+		return nil, fmt.Errorf("issuer of the certificate provider must be set")
+	}
+	switch issuerKind {
+	case IssuerKindIssuer, IssuerKindClusterIssuer:
+	default:
+		return nil, fmt.Errorf("unsupported issuer kind %s, must be %s or %s", issuerKind, IssuerKindIssuer, IssuerKindClusterIssuer)
+	}
+	return &Provider{
+		issuer:     issuer,
+		issuerKind: issuerKind,
+		labels:     labels,
+	}, nil
+}
+
+// Certificate creates a Certificate for the given DNS names when it does not exist yet and returns
+// the certificate once cert-manager issued it. The DNS names of an existing Certificate are not
+// updated.
+func (p *Provider) Certificate(ctx context.Context, c client.Client, name types.NamespacedName, dnsNames []string) (*transport.Certificate, error) {
+	if len(dnsNames) == 0 {
+		return nil, fmt.Errorf("certificate %s requires at least one DNS name", name)
+	}
+	certificate := newCertificate()
+	err := c.Get(ctx, name, certificate)
+	switch {
+	case k8serrors.IsNotFound(err):
+		if err := meta.Apply(ctx, c, p.certificateFor(name, dnsNames)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("certificate %s was requested: %w", name, transport.ErrCertificateNotReady)
+	case err != nil:
+		return nil, err
+	}
+	ready, err := certificateReady(certificate)
+	if err != nil {
+		return nil, err
+	}
+	if !ready {
+		return nil, fmt.Errorf("certificate %s is not issued yet: %w", name, transport.ErrCertificateNotReady)
+	}
+
+	secret := &corev1.Secret{}
+	err = c.Get(ctx, name, secret)
+	if err != nil {
+		return nil, err
+	}
+	crt, ok := secret.Data[corev1.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid secret for certificate %s, %s key not found", transport.ErrCertMissing, name, corev1.TLSCertKey)
+	}
+	key, ok := secret.Data[corev1.TLSPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid secret for certificate %s, %s key not found", transport.ErrCertMissing, name, corev1.TLSPrivateKeyKey)
+	}
+	cert := &transport.Certificate{
+		Crt: bytes.NewBuffer(crt),
+		Key: bytes.NewBuffer(key),
+	}
+	if ca, ok := secret.Data["ca.crt"]; ok && len(ca) > 0 {
+		cert.CA = bytes.NewBuffer(ca)
+	}
+	return cert, nil
+}
+
+func (p *Provider) certificateFor(name types.NamespacedName, dnsNames []string) *unstructured.Unstructured {
+	names := []interface{}{}
+	for _, n := range dnsNames {
+		names = append(names, n)
+	}
+	ownerLabels := meta.WithOwnerLabel(p.labels)
+	labels := map[string]interface{}{}
+	for k, v := range ownerLabels {
+		labels[k] = v
+	}
+	certificate := newCertificate()
+	certificate.SetNamespace(name.Namespace)
+	certificate.SetName(name.Name)
+	certificate.SetLabels(ownerLabels)
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": name.Name,
+		// the issued Secret is labelled like the Certificate so that it is cleaned up with it
+		"secretTemplate": map[string]interface{}{
+			"labels": labels,
+		},
+		"dnsNames": names,
+		"usages":   []interface{}{"server auth", "client auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  p.issuer,
+			"kind":  string(p.issuerKind),
+			"group": GroupVersion.Group,
+		},
+	}
+	return certificate
+}
+
+func newCertificate() *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(GroupVersion.WithKind("Certificate"))
+	return certificate
+}
+
+// certificateReady returns whether the Ready condition of the given Certificate is True
+func certificateReady(certificate *unstructured.Unstructured) (bool, error) {
+	conditions, _, err := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Ready" {
+			return condition["status"] == string(corev1.ConditionTrue), nil
+		}
+	}
+	return false, nil
+}
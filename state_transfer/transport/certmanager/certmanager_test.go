@@ -0,0 +1,111 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testName = types.NamespacedName{Namespace: "test-namespace", Name: "fs-crane2-stunnel-server-cert"}
+
+func TestNewProvider(t *testing.T) {
+	if _, err := NewProvider("", IssuerKindIssuer, nil); err == nil {
+		t.Errorf("NewProvider() should fail without an issuer")
+	}
+	if _, err := NewProvider("ca", "Vault", nil); err == nil {
+		t.Errorf("NewProvider() should fail for an unknown issuer kind")
+	}
+	if _, err := NewProvider("ca", IssuerKindClusterIssuer, nil); err != nil {
+		t.Errorf("NewProvider() unexpected error %v", err)
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	p, err := NewProvider("ca", IssuerKindClusterIssuer, map[string]string{"app": "crane"})
+	if err != nil {
+		t.Fatalf("NewProvider() unexpected error %v", err)
+	}
+	if _, err := p.Certificate(context.TODO(), c, testName, nil); err == nil || errors.Is(err, transport.ErrCertificateNotReady) {
+		t.Errorf("Certificate() should fail without DNS names, got %v", err)
+	}
+
+	_, err = p.Certificate(context.TODO(), c, testName, []string{"test.host"})
+	if !errors.Is(err, transport.ErrCertificateNotReady) {
+		t.Fatalf("Certificate() should wait for the certificate to be issued, got %v", err)
+	}
+	certificate := newCertificate()
+	if err := c.Get(context.TODO(), testName, certificate); err != nil {
+		t.Fatalf("unable to get certificate: %v", err)
+	}
+	if !meta.IsOwned(certificate.GetLabels()) || certificate.GetLabels()["app"] != "crane" {
+		t.Errorf("unexpected certificate labels %v", certificate.GetLabels())
+	}
+	for _, field := range []struct {
+		path []string
+		want string
+	}{
+		{[]string{"spec", "secretName"}, testName.Name},
+		{[]string{"spec", "issuerRef", "name"}, "ca"},
+		{[]string{"spec", "issuerRef", "kind"}, "ClusterIssuer"},
+		{[]string{"spec", "issuerRef", "group"}, "cert-manager.io"},
+	} {
+		if got, _, _ := unstructured.NestedString(certificate.Object, field.path...); got != field.want {
+			t.Errorf("certificate %v = %q, want %q", field.path, got, field.want)
+		}
+	}
+	if names, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames"); len(names) != 1 || names[0] != "test.host" {
+		t.Errorf("unexpected certificate DNS names %v", names)
+	}
+
+	_, err = p.Certificate(context.TODO(), c, testName, []string{"test.host"})
+	if !errors.Is(err, transport.ErrCertificateNotReady) {
+		t.Fatalf("Certificate() should wait for the Ready condition, got %v", err)
+	}
+
+	setReady(t, c, certificate)
+	crt, _, key, err := transport.GenerateSSLCert()
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testName.Namespace, Name: testName.Name},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       crt.Bytes(),
+			corev1.TLSPrivateKeyKey: key.Bytes(),
+			"ca.crt":                crt.Bytes(),
+		},
+	}
+	if err := c.Create(context.TODO(), secret); err != nil {
+		t.Fatalf("unable to create secret: %v", err)
+	}
+	cert, err := p.Certificate(context.TODO(), c, testName, []string{"test.host"})
+	if err != nil {
+		t.Fatalf("Certificate() unexpected error %v", err)
+	}
+	if cert.Crt.String() != crt.String() || cert.Key.String() != key.String() || cert.CA == nil || cert.CA.String() != crt.String() {
+		t.Errorf("Certificate() should return the issued certificate")
+	}
+}
+
+func setReady(t *testing.T, c client.Client, certificate *unstructured.Unstructured) {
+	err := unstructured.SetNestedSlice(certificate.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True"},
+	}, "status", "conditions")
+	if err != nil {
+		t.Fatalf("unable to set certificate status: %v", err)
+	}
+	if err := c.Update(context.TODO(), certificate); err != nil {
+		t.Fatalf("unable to update certificate: %v", err)
+	}
+}
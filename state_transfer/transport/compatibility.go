@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+)
+
+// ErrIncompatible is returned when a transfer cannot work through the combination of its
+// transport and its endpoint
+var ErrIncompatible = errors.New("incompatible transport and endpoint")
+
+// Tunneler is implemented by transports carrying the protocol of the transfer through a tunnel
+// of their own, such as stunnel or ssh
+type Tunneler interface {
+	// TunnelProtocol returns the protocol of the connections the transport client opens to the endpoint
+	TunnelProtocol() endpoint.Protocol
+}
+
+// ConnectionProtocol returns the protocol of the connections the client of the given Transport
+// opens to the endpoint for a transfer speaking protocol: the protocol of its tunnel for a
+// Tunneler, the protocol of the transfer for a direct transport. An empty protocol is returned
+// for other transports, like a null transport whose server was not created yet.
+func ConnectionProtocol(t Transport, protocol endpoint.Protocol) endpoint.Protocol {
+	if tunneler, ok := t.(Tunneler); ok {
+		return tunneler.TunnelProtocol()
+	}
+	if t.Direct() {
+		return protocol
+	}
+	return ""
+}
+
+// ValidateCompatibility returns an error wrapping ErrIncompatible when a transfer speaking the
+// given protocol cannot work through the given Transport and Endpoint, for instance when the
+// connections of the null transport cannot be routed by a passthrough route. Endpoints reject the
+// protocols they cannot carry, see endpoint.ProtocolValidator, and transports report the protocol
+// of their connections, see ConnectionProtocol. Transfers call it when they are created, so that
+// misconfigurations are reported before any resource is created.
+func ValidateCompatibility(protocol endpoint.Protocol, t Transport, e endpoint.Endpoint) error {
+	if t == nil || e == nil {
+		return nil
+	}
+	connection := ConnectionProtocol(t, protocol)
+	if connection == "" {
+		return nil
+	}
+	if err := endpoint.ValidateProtocol(e, connection); err != nil {
+		return fmt.Errorf("%w: the %s transport cannot be used with the endpoint %s: %v", ErrIncompatible, t.Type(), e.NamespacedName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// testTransport is a transport tunnelling its connections with tunnel, or a direct transport
+// when tunnel is empty
+type testTransport struct {
+	Transport
+	tunnel endpoint.Protocol
+	direct bool
+}
+
+func (t *testTransport) Type() TransportType {
+	return "test"
+}
+
+func (t *testTransport) Direct() bool {
+	return t.direct
+}
+
+type tunnelTransport struct {
+	testTransport
+}
+
+func (t *tunnelTransport) TunnelProtocol() endpoint.Protocol {
+	return t.tunnel
+}
+
+// tlsEndpoint is an endpoint only accepting TLS connections, like a passthrough route
+type tlsEndpoint struct {
+	endpoint.Endpoint
+}
+
+func (e *tlsEndpoint) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: "test-namespace", Name: "test"}
+}
+
+func (e *tlsEndpoint) ValidateProtocol(p endpoint.Protocol) error {
+	if p != endpoint.ProtocolTLS {
+		return fmt.Errorf("%s connections do not use TLS", p)
+	}
+	return nil
+}
+
+func TestValidateCompatibility(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport Transport
+		wantErr   bool
+	}{
+		{name: "tls tunnel", transport: &tunnelTransport{testTransport{tunnel: endpoint.ProtocolTLS}}},
+		{name: "ssh tunnel", transport: &tunnelTransport{testTransport{tunnel: endpoint.ProtocolSSH}}, wantErr: true},
+		{name: "direct transport", transport: &testTransport{direct: true}, wantErr: true},
+		{name: "transport without server", transport: &testTransport{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCompatibility(endpoint.ProtocolTCP, tt.transport, &tlsEndpoint{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCompatibility() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrIncompatible) {
+				t.Errorf("ValidateCompatibility() error %v does not wrap ErrIncompatible", err)
+			}
+		})
+	}
+}
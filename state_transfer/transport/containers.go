@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultServerPidFile is the pid file of a transport server running in the background when no
+	// PidFile is set, its container needs the pid to wait for the server
+	defaultServerPidFile = "/tmp/transport-server.pid"
+	// serverPidFileTimeoutSeconds is how long the container of a transport server running in the
+	// background waits for the server to write its pid file
+	serverPidFileTimeoutSeconds = 30
+)
+
+// RestrictedSecurityContext returns a security context satisfying the restricted Pod Security
+// Standard: the container runs as a non root user with a read only root filesystem, without
+// privilege escalation nor capabilities, and with the seccomp profile of the runtime. The image
+// must not require root, set RunAsUser when it does not define a non root user.
+func RestrictedSecurityContext() *v1.SecurityContext {
+	runAsNonRoot := true
+	readOnlyRootFilesystem := true
+	allowPrivilegeEscalation := false
+	return &v1.SecurityContext{
+		RunAsNonRoot:             &runAsNonRoot,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &v1.Capabilities{
+			Drop: []v1.Capability{"ALL"},
+		},
+		SeccompProfile: &v1.SeccompProfile{
+			Type: v1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// ValidateContainerOptions validates the image pull policy of the given options, an empty policy
+// is valid
+func ValidateContainerOptions(options *Options) error {
+	if options == nil {
+		return nil
+	}
+	switch options.ImagePullPolicy {
+	case "", v1.PullAlways, v1.PullIfNotPresent, v1.PullNever:
+		return nil
+	default:
+		return fmt.Errorf("unsupported image pull policy %q, must be one of %s, %s or %s",
+			options.ImagePullPolicy, v1.PullAlways, v1.PullIfNotPresent, v1.PullNever)
+	}
+}
+
+// ApplyContainerOptions sets the resources, the security context and the image pull policy of the
+// given options on the transport containers, the fields which are not set in the options are kept
+func ApplyContainerOptions(containers []v1.Container, options *Options) {
+	if options == nil {
+		return
+	}
+	for i := range containers {
+		c := &containers[i]
+		if options.ContainerResources != nil {
+			c.Resources = *options.ContainerResources.DeepCopy()
+		}
+		if options.ContainerSecurityContext != nil {
+			c.SecurityContext = options.ContainerSecurityContext.DeepCopy()
+		}
+		if options.ImagePullPolicy != "" {
+			c.ImagePullPolicy = options.ImagePullPolicy
+		}
+	}
+}
+
+// ServerForeground returns whether the transport server process stays in the foreground, see
+// Options.Foreground
+func ServerForeground(options *Options) bool {
+	return options == nil || options.Foreground == nil || *options.Foreground
+}
+
+// ServerPidFile returns the pid file of the transport server, the PidFile of the given options or a
+// default pid file when the server runs in the background without one
+func ServerPidFile(options *Options) string {
+	if options != nil && options.PidFile != "" {
+		return options.PidFile
+	}
+	if !ServerForeground(options) {
+		return defaultServerPidFile
+	}
+	return ""
+}
+
+// ServerCommand returns the command of the container running the given transport server command.
+// A server running in the background returns as soon as it detached, the container would then
+// terminate with it: the returned command waits for the pid written to ServerPidFile instead, so
+// that the container runs as long as the server and stops the server when it is terminated.
+func ServerCommand(options *Options, command []string) []string {
+	if ServerForeground(options) {
+		return command
+	}
+	pidFile := ServerPidFile(options)
+	script := []string{
+		fmt.Sprintf("%s || exit $?", strings.Join(command, " ")),
+		fmt.Sprintf("for i in $(seq %d); do [ -s %s ] && break; sleep 1; done", serverPidFileTimeoutSeconds, pidFile),
+		fmt.Sprintf("pid=$(cat %s) || exit 1", pidFile),
+		"trap 'kill $pid; exit 0' TERM INT",
+		"while kill -0 $pid 2>/dev/null; do sleep 1; done",
+		"exit 1",
+	}
+	return []string{"/bin/bash", "-c", strings.Join(script, "\n")}
+}
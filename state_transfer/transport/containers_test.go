@@ -0,0 +1,30 @@
+package transport
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerCommand(t *testing.T) {
+	command := []string{"/bin/stunnel", "/etc/stunnel/stunnel.conf"}
+	if got := ServerCommand(&Options{}, command); !reflect.DeepEqual(got, command) {
+		t.Errorf("a server in the foreground should run its command as is, got %v", got)
+	}
+
+	// the server detaches and exits after a while, the container must wait for it
+	background := false
+	pidFile := filepath.Join(t.TempDir(), "server.pid")
+	daemon := []string{"/bin/bash", "-c", "'sleep 2 & echo $! > " + pidFile + "'"}
+	got := ServerCommand(&Options{Foreground: &background, PidFile: pidFile}, daemon)
+	start := time.Now()
+	err := exec.Command(got[0], got[1:]...).Run()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("container command returned after %s, before the server exited", elapsed)
+	}
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Errorf("container command should fail once the server exited, got %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package null
 
 import (
+	"context"
+
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (s *NullTransport) CreateClient(c client.Client, prefix string, endpoint endpoint.Endpoint) error {
+func (s *NullTransport) CreateClient(ctx context.Context, c client.Client, prefix string, endpoint endpoint.Endpoint) error {
 	return nil
 }
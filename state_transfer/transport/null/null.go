@@ -3,6 +3,8 @@ package null
 import (
 	"bytes"
 
+	"github.com/go-logr/logr"
+
 	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 
@@ -23,11 +25,13 @@ type NullTransport struct {
 	direct           bool
 	options          *transport.Options
 	nsNamePair       meta.NamespacedNamePair
+	log              logr.Logger
 }
 
-func NewTransport(nsNamePair meta.NamespacedNamePair) transport.Transport {
+func NewTransport(nsNamePair meta.NamespacedNamePair, log logr.Logger) transport.Transport {
 	return &NullTransport{
 		nsNamePair: nsNamePair,
+		log:        meta.LoggerOrDiscard(log).WithValues("transport", TransportTypeNull),
 	}
 }
 
@@ -43,6 +47,10 @@ func (s *NullTransport) Key() *bytes.Buffer {
 	return s.key
 }
 
+func (s *NullTransport) CertFingerprint() (string, error) {
+	return transport.CertFingerprint(s.crt)
+}
+
 func (s *NullTransport) Port() int32 {
 	return s.port
 }
@@ -75,6 +83,16 @@ func (s *NullTransport) Options() *transport.Options {
 	return s.options
 }
 
+// Copy returns a copy of the transport and of its options, see transport.Copier
+func (s *NullTransport) Copy() transport.Transport {
+	copied := *s
+	if s.options != nil {
+		options := *s.options
+		copied.options = &options
+	}
+	return &copied
+}
+
 func (s *NullTransport) NamespacedNamePair() meta.NamespacedNamePair {
 	return s.nsNamePair
 }
@@ -1,12 +1,16 @@
 package null
 
 import (
+	"context"
+
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (s *NullTransport) CreateServer(c client.Client, prefix string, e endpoint.Endpoint) error {
+func (s *NullTransport) CreateServer(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
 	s.direct = true
 	s.port = e.Port()
+	s.log.V(1).Info("no tunnel created, the transfer connects to the endpoint directly", "namespace",
+		s.nsNamePair.Destination().Namespace, "port", s.port)
 	return nil
 }
@@ -0,0 +1,273 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterProxyName is the name of the cluster-wide OpenShift Proxy
+const clusterProxyName = "cluster"
+
+// ProxyType is the protocol the transport client speaks with its proxy
+type ProxyType string
+
+const (
+	// ProxyTypeHTTP is a proxy tunneling connections with the HTTP CONNECT method
+	ProxyTypeHTTP ProxyType = "http"
+	// ProxyTypeSOCKS5 is a SOCKS5 proxy, for clusters which only permit SOCKS egress
+	ProxyTypeSOCKS5 ProxyType = "socks5"
+)
+
+// Proxy is the proxy the transport client connects to its endpoint through
+type Proxy struct {
+	// Type is the protocol of the proxy
+	Type ProxyType
+	// Host is the host:port of the proxy
+	Host string
+	// Username and Password are the credentials of the proxy, optional
+	Username string
+	Password string
+}
+
+// Validate returns an error when the type of the proxy is unknown, its host is not a host:port, or
+// the credentials of a SOCKS5 proxy cannot be passed to the forwarder of the client, which does not
+// accept single quotes and line breaks, nor colons in the username
+func (p *Proxy) Validate() error {
+	switch p.Type {
+	case ProxyTypeHTTP:
+		return nil
+	case ProxyTypeSOCKS5:
+	default:
+		return fmt.Errorf("unsupported proxy type %q", p.Type)
+	}
+	if _, port, err := net.SplitHostPort(p.Host); err != nil || port == "" {
+		return fmt.Errorf("invalid socks5 proxy %q, a host:port is required", p.Host)
+	}
+	if strings.ContainsAny(p.Host, unsafeProxyChars) {
+		return fmt.Errorf("invalid socks5 proxy %q", p.Host)
+	}
+	if strings.ContainsAny(p.Username, unsafeProxyChars+":") || strings.ContainsAny(p.Password, unsafeProxyChars) {
+		return fmt.Errorf("socks5 proxy credentials must not contain single quotes or line breaks, nor colons in the username")
+	}
+	if p.Username == "" && p.Password != "" {
+		return fmt.Errorf("socks5 proxy password requires a username")
+	}
+	return nil
+}
+
+// unsafeProxyChars are the characters rejected in the host and the credentials of SOCKS5 proxies,
+// which are single quoted in the script of the forwarder
+const unsafeProxyChars = "'\r\n"
+
+// String returns the proxy without its credentials
+func (p *Proxy) String() string {
+	return fmt.Sprintf("%s://%s", p.Type, p.Host)
+}
+
+// ProxySettings are the proxies of a cluster in the conventions of the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables
+type ProxySettings struct {
+	// HTTPSProxy is the URL of the proxy of TLS connections
+	HTTPSProxy string
+	// HTTPProxy is the URL of the proxy used when HTTPSProxy is not set
+	HTTPProxy string
+	// NoProxy is the comma separated list of hostnames, domains, IP addresses and CIDRs which are
+	// reached without the proxy, * disables the proxy
+	NoProxy string
+}
+
+// ProxyFromEnvironment returns the proxy settings of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// environment variables, or of their lower case variants
+func ProxyFromEnvironment() ProxySettings {
+	env := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return os.Getenv(strings.ToLower(name))
+	}
+	return ProxySettings{
+		HTTPSProxy: env("HTTPS_PROXY"),
+		HTTPProxy:  env("HTTP_PROXY"),
+		NoProxy:    env("NO_PROXY"),
+	}
+}
+
+// DetectProxy returns the proxy settings of the cluster of the given client, read from the status
+// of the cluster-wide OpenShift Proxy. The settings of the environment are returned when the
+// cluster has no Proxy resource or its API is not known to the client, see ProxyFromEnvironment.
+func DetectProxy(ctx context.Context, c client.Client) (ProxySettings, error) {
+	proxy := &configv1.Proxy{}
+	err := c.Get(ctx, client.ObjectKey{Name: clusterProxyName}, proxy)
+	switch {
+	case k8serrors.IsNotFound(err) || apimeta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err):
+		return ProxyFromEnvironment(), nil
+	case err != nil:
+		return ProxySettings{}, err
+	}
+	return ProxySettings{
+		HTTPSProxy: proxy.Status.HTTPSProxy,
+		HTTPProxy:  proxy.Status.HTTPProxy,
+		NoProxy:    proxy.Status.NoProxy,
+	}, nil
+}
+
+// ProxyFor returns the URL of the proxy connections to the given hostname go through, nil when
+// no proxy is set or the hostname matches NoProxy
+func (p ProxySettings) ProxyFor(hostname string) (*url.URL, error) {
+	raw := p.HTTPSProxy
+	if raw == "" {
+		raw = p.HTTPProxy
+	}
+	if raw == "" || p.bypass(hostname) {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil || proxy.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy url %q", redactedProxy(raw))
+	}
+	return proxy, nil
+}
+
+// bypass returns whether the hostname matches an entry of NoProxy. Entries match the hostname
+// itself and its sub domains, a leading dot or *. only matches sub domains, and IP addresses and
+// CIDRs match the hostname when it is an IP address. Ports of entries are ignored.
+func (p ProxySettings) bypass(hostname string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	ip := net.ParseIP(hostname)
+	for _, entry := range strings.Split(p.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if host, _, err := net.SplitHostPort(entry); err == nil {
+			entry = host
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(entry, "*")
+		if strings.HasPrefix(entry, ".") {
+			if strings.HasSuffix(hostname, entry) {
+				return true
+			}
+			continue
+		}
+		if hostname == entry || strings.HasSuffix(hostname, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientProxy returns the proxy the transport client connects to the given hostname through, nil
+// when the client connects directly. The proxy set in the options is used when ProxyURL is set,
+// otherwise, with AutoDetectProxy, the proxy of the cluster of the client is detected with
+// DetectProxy and skipped for hostnames matching its NoProxy. ProxyType, ProxyUsername and
+// ProxyPassword of the options override the type and the credentials of a detected proxy, so that
+// a single transfer can use a different proxy than the one of the cluster.
+func ClientProxy(ctx context.Context, c client.Client, options *Options, hostname string) (*Proxy, error) {
+	if options == nil {
+		return nil, nil
+	}
+	if options.ProxyURL == "" && !options.AutoDetectProxy {
+		return nil, nil
+	}
+	var proxy *Proxy
+	if options.ProxyURL != "" {
+		var err error
+		if proxy, err = parseProxy(options.ProxyURL); err != nil {
+			return nil, err
+		}
+	} else {
+		settings, err := DetectProxy(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("unable to detect the proxy of the cluster: %w", err)
+		}
+		u, err := settings.ProxyFor(hostname)
+		if err != nil || u == nil {
+			return nil, err
+		}
+		if proxy, err = proxyFromURL(u); err != nil {
+			return nil, err
+		}
+	}
+	if options.ProxyType != "" {
+		proxy.Type = options.ProxyType
+	}
+	if options.ProxyUsername != "" {
+		proxy.Username, proxy.Password = options.ProxyUsername, options.ProxyPassword
+	}
+	if err := proxy.Validate(); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// parseProxy parses the ProxyURL of the options, either a host:port or a URL whose scheme sets the
+// type of the proxy
+func parseProxy(raw string) (*Proxy, error) {
+	if !strings.Contains(raw, "://") {
+		return &Proxy{Type: ProxyTypeHTTP, Host: raw}, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy url %q", redactedProxy(raw))
+	}
+	return proxyFromURL(u)
+}
+
+// proxyFromURL returns the proxy of the given URL, the port defaults to the well known port of its
+// scheme
+func proxyFromURL(u *url.URL) (*Proxy, error) {
+	proxy := &Proxy{Type: ProxyTypeHTTP, Host: u.Host}
+	port := "80"
+	switch u.Scheme {
+	case "http", "":
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		proxy.Type = ProxyTypeSOCKS5
+		port = "1080"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		proxy.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	proxy.Username = u.User.Username()
+	proxy.Password, _ = u.User.Password()
+	return proxy, nil
+}
+
+// redactedProxy returns the proxy URL without its password
+func redactedProxy(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return "<invalid url>"
+}
@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CreateClient creates the Secret holding the key of the client and the host key it trusts, the
+// server must have been created with the same transport beforehand as it generates the keys
+func (s *SSHTransport) CreateClient(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
+	if err := validateClientOptions(s.Options()); err != nil {
+		return err
+	}
+	if s.clientKey == nil || s.hostPublicKey == "" {
+		return fmt.Errorf("the ssh transport server must be created before its client")
+	}
+	s.port = e.Port()
+	log := s.log.WithValues("namespace", s.nsNamePair.Source().Namespace, "prefix", prefix)
+
+	err := createSSHClientSecret(ctx, meta.NewLoggingClient(c, log), s, prefix, e)
+	if err != nil {
+		log.Error(err, "unable to create ssh client resources")
+		return err
+	}
+
+	setClientContainers(s, e)
+
+	createClientVolumes(s, prefix)
+
+	return nil
+}
+
+// validateClientOptions rejects the transport options the ssh client cannot honor
+func validateClientOptions(options *transport.Options) error {
+	if options.ProxyURL != "" {
+		return fmt.Errorf("the ssh transport does not support connecting through a proxy")
+	}
+	return transport.ValidateContainerOptions(options)
+}
+
+func createSSHClientSecret(ctx context.Context, c client.Client, s *SSHTransport, prefix string, e endpoint.Endpoint) error {
+	sshSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.nsNamePair.Source().Namespace,
+			Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultSSHClientSecret),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
+		},
+		Data: map[string][]byte{
+			identityKey:   s.clientKey,
+			knownHostsKey: []byte(knownHostsLine(e.Hostname(), e.ExposedPort(), s.hostPublicKey)),
+		},
+	}
+
+	// the known host depends on the endpoint, which may have changed
+	return meta.Apply(ctx, c, sshSecret)
+}
+
+// clientCommand returns the ssh command forwarding the local port of the endpoint to the
+// transfer server through the endpoint
+func clientCommand(s *SSHTransport, e endpoint.Endpoint) []string {
+	return []string{
+		"/usr/bin/ssh",
+		"-N",
+		"-F", "/dev/null",
+		"-i", sshClientKeysPath + "/" + identityKey,
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UserKnownHostsFile=" + sshClientKeysPath + "/" + knownHostsKey,
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-p", fmt.Sprintf("%d", e.ExposedPort()),
+		"-L", fmt.Sprintf("%d:%s:%d", e.Port(), connectHost(s.Options()), s.ExposedPort()),
+		fmt.Sprintf("%s@%s", sshUser, e.Hostname()),
+	}
+}
+
+func setClientContainers(s *SSHTransport, e endpoint.Endpoint) {
+	s.clientContainers = []corev1.Container{
+		{
+			Name:    SSHContainer,
+			Image:   s.getSSHClientImage(),
+			Command: clientCommand(s, e),
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      defaultSSHClientSecret,
+					MountPath: sshClientKeysPath,
+				},
+			},
+		},
+	}
+	transport.ApplyContainerOptions(s.clientContainers, s.Options())
+}
+
+func createClientVolumes(s *SSHTransport, prefix string) {
+	mode := keyFileMode
+	s.clientVolumes = []corev1.Volume{
+		{
+			Name: defaultSSHClientSecret,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  withPrefix(secretPrefix(s.Options(), prefix), defaultSSHClientSecret),
+					DefaultMode: &mode,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCreateClient(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint()
+	s := createSSH(&transport.Options{})
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err == nil {
+		t.Fatalf("CreateClient() should fail when the server was not created")
+	}
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("CreateClient() unexpected error %v", err)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "fs-" + defaultSSHClientSecret}, secret); err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if string(secret.Data[identityKey]) != string(s.clientKey) {
+		t.Errorf("client secret does not hold the client key")
+	}
+	if want := "[test.host]:6443 " + s.hostPublicKey + "\n"; string(secret.Data[knownHostsKey]) != want {
+		t.Errorf("client secret known hosts %q, want %q", secret.Data[knownHostsKey], want)
+	}
+	if s.Port() != e.Port() {
+		t.Errorf("transport port %d, want the port of the endpoint %d", s.Port(), e.Port())
+	}
+
+	containers := s.ClientContainers()
+	if len(containers) != 1 || containers[0].Name != SSHContainer {
+		t.Fatalf("unexpected client containers %v", containers)
+	}
+	command := strings.Join(containers[0].Command, " ")
+	for _, want := range []string{
+		"-o StrictHostKeyChecking=yes",
+		"-o UserKnownHostsFile=/etc/ssh-client/keys/known_hosts",
+		"-p 6443",
+		"-L 6443:localhost:2222",
+		"root@test.host",
+	} {
+		if !strings.Contains(command, want) {
+			t.Errorf("client command %q does not contain %q", command, want)
+		}
+	}
+	if volumes := s.ClientVolumes(); len(volumes) != 1 || volumes[0].Secret.SecretName != "fs-"+defaultSSHClientSecret {
+		t.Errorf("unexpected client volumes %v", volumes)
+	}
+}
+
+func TestCreateClientWithProxy(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint()
+	s := createSSH(&transport.Options{ProxyURL: "proxy:3128"})
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err == nil {
+		t.Errorf("CreateClient() should fail when a proxy is set")
+	}
+}
@@ -0,0 +1,66 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+const (
+	keyType   = "ecdsa-sha2-nistp256"
+	curveName = "nistp256"
+)
+
+// GenerateKeyPair generates an ECDSA P-256 key pair, the private key is PEM encoded and the public
+// key is in the authorized_keys format, both can be used by OpenSSH as they are
+func GenerateKeyPair() ([]byte, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	private := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	point := elliptic.Marshal(elliptic.P256(), key.PublicKey.X, key.PublicKey.Y)
+	blob := append(append(wireString([]byte(keyType)), wireString([]byte(curveName))...), wireString(point)...)
+	return private, keyType + " " + base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a public key in the authorized_keys format,
+// formatted like ssh-keygen -l does
+func Fingerprint(publicKey string) (string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid public key %q", publicKey)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// knownHostsLine returns the known_hosts entry trusting the public key for the host and port
+func knownHostsLine(hostname string, port int32, publicKey string) string {
+	if port == 22 {
+		return fmt.Sprintf("%s %s\n", hostname, publicKey)
+	}
+	return fmt.Sprintf("[%s]:%d %s\n", hostname, port, publicKey)
+}
+
+// wireString encodes b as a string of the ssh wire format
+func wireString(b []byte) []byte {
+	out := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(out, uint32(len(b)))
+	return append(out, b...)
+}
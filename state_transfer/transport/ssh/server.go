@@ -0,0 +1,197 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"text/template"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	sshServerConfTemplate = `Port {{ $.acceptPort }}
+{{- if $.bindAddress }}
+ListenAddress {{ $.bindAddress }}
+{{- end }}
+HostKey {{ $.keysPath }}/ssh_host_key
+AuthorizedKeysFile {{ $.keysPath }}/authorized_keys
+PidFile {{ if $.pidFile }}{{ $.pidFile }}{{ else }}none{{ end }}
+PermitRootLogin prohibit-password
+PubkeyAuthentication yes
+PasswordAuthentication no
+ChallengeResponseAuthentication no
+StrictModes no
+AllowAgentForwarding no
+X11Forwarding no
+PermitTTY no
+AllowTcpForwarding local
+PermitOpen {{ $.connectHost }}:{{ $.connectPort }}
+ForceCommand /bin/false
+`
+)
+
+func (s *SSHTransport) CreateServer(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
+	s.port = e.Port()
+	log := s.log.WithValues("namespace", s.nsNamePair.Destination().Namespace, "prefix", prefix)
+	c = meta.NewLoggingClient(c, log)
+	errs := []error{}
+
+	err := createSSHServerConfig(ctx, c, s, prefix, e)
+	errs = append(errs, err)
+
+	err = createSSHServerSecret(ctx, c, s, prefix, e)
+	errs = append(errs, err)
+
+	createSSHServerContainers(s, e)
+
+	createSSHServerVolumes(s, prefix)
+
+	err = errorsutil.NewAggregate(errs)
+	if err != nil {
+		log.Error(err, "unable to create ssh server resources")
+	}
+	return err
+}
+
+// connectHost returns the host sshd lets clients forward connections to
+func connectHost(options *transport.Options) string {
+	if options.ServerConnectHost != "" {
+		return options.ServerConnectHost
+	}
+	return "localhost"
+}
+
+func createSSHServerConfig(ctx context.Context, c client.Client, s *SSHTransport, prefix string, e endpoint.Endpoint) error {
+	if err := transport.ValidatePidFile(s.Options().PidFile); err != nil {
+		return err
+	}
+	if len(s.Options().ServerExec) > 0 {
+		return fmt.Errorf("ssh transport only forwards connections, it cannot exec a server")
+	}
+	if err := transport.ValidateContainerOptions(s.Options()); err != nil {
+		return err
+	}
+	values := map[string]interface{}{
+		// port on which sshd listens on, must connect with endpoint
+		"acceptPort": strconv.Itoa(int(e.Port())),
+		// address on which sshd listens on, empty means all addresses
+		"bindAddress": endpoint.BindAddress(e),
+		// the only destination clients are allowed to forward connections to
+		"connectHost": connectHost(s.Options()),
+		"connectPort": strconv.Itoa(int(s.ExposedPort())),
+		"keysPath":    sshServerKeysPath,
+		"pidFile":     transport.ServerPidFile(s.Options()),
+	}
+
+	var sshdConf bytes.Buffer
+	sshdConfTemplate, err := template.New("config").Parse(sshServerConfTemplate)
+	if err != nil {
+		return err
+	}
+	err = sshdConfTemplate.Execute(&sshdConf, values)
+	if err != nil {
+		return err
+	}
+	s.log.V(1).Info("rendered sshd config", "namespace", s.nsNamePair.Destination().Namespace,
+		"acceptPort", values["acceptPort"], "connectHost", values["connectHost"], "connectPort", values["connectPort"])
+
+	sshdConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.nsNamePair.Destination().Namespace,
+			Name:      withPrefix(prefix, defaultSSHServerConfig),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
+		},
+		Data: map[string]string{
+			"sshd_config": sshdConf.String(),
+		},
+	}
+
+	return meta.Apply(ctx, c, sshdConfigMap)
+}
+
+func createSSHServerSecret(ctx context.Context, c client.Client, s *SSHTransport, prefix string, e endpoint.Endpoint) error {
+	if err := s.generateKeys(); err != nil {
+		return err
+	}
+
+	sshSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.nsNamePair.Destination().Namespace,
+			Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultSSHServerSecret),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
+		},
+		Data: map[string][]byte{
+			hostKeyKey:        s.hostKey,
+			authorizedKeysKey: []byte(s.clientPublicKey + "\n"),
+		},
+	}
+
+	return meta.Apply(ctx, c, sshSecret)
+}
+
+func createSSHServerContainers(s *SSHTransport, e endpoint.Endpoint) {
+	command := []string{"/usr/sbin/sshd", "-e", "-f", sshServerConfigPath}
+	if transport.ServerForeground(s.Options()) {
+		command = append(command, "-D")
+	}
+	s.serverContainers = []corev1.Container{
+		{
+			Name:    SSHContainer,
+			Image:   s.getSSHServerImage(),
+			Command: transport.ServerCommand(s.Options(), command),
+			Ports: []corev1.ContainerPort{
+				{
+					Name:          "ssh",
+					Protocol:      corev1.ProtocolTCP,
+					ContainerPort: e.Port(),
+				},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      defaultSSHServerConfig,
+					MountPath: sshServerConfigPath,
+					SubPath:   "sshd_config",
+				},
+				{
+					Name:      defaultSSHServerSecret,
+					MountPath: sshServerKeysPath,
+				},
+			},
+		},
+	}
+	transport.ApplyContainerOptions(s.serverContainers, s.Options())
+}
+
+func createSSHServerVolumes(s *SSHTransport, prefix string) {
+	mode := keyFileMode
+	s.serverVolumes = []corev1.Volume{
+		{
+			Name: defaultSSHServerConfig,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: withPrefix(prefix, defaultSSHServerConfig),
+					},
+				},
+			},
+		},
+		{
+			Name: defaultSSHServerSecret,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  withPrefix(secretPrefix(s.Options(), prefix), defaultSSHServerSecret),
+					DefaultMode: &mode,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCreateServer(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint()
+	s := createSSH(&transport.Options{PidFile: "/tmp/sshd.pid"})
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testDestNamespace, Name: "fs-" + defaultSSHServerConfig}, cm); err != nil {
+		t.Fatalf("unable to get server config: %v", err)
+	}
+	for _, want := range []string{
+		"Port 6443\n",
+		"PidFile /tmp/sshd.pid\n",
+		"PasswordAuthentication no\n",
+		"AllowTcpForwarding local\n",
+		"PermitOpen localhost:2222\n",
+	} {
+		if !strings.Contains(cm.Data["sshd_config"], want) {
+			t.Errorf("server config does not contain %q:\n%s", want, cm.Data["sshd_config"])
+		}
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testDestNamespace, Name: "fs-" + defaultSSHServerSecret}, secret); err != nil {
+		t.Fatalf("unable to get server secret: %v", err)
+	}
+	if string(secret.Data[hostKeyKey]) != string(s.hostKey) {
+		t.Errorf("server secret does not hold the host key")
+	}
+	if string(secret.Data[authorizedKeysKey]) != s.clientPublicKey+"\n" {
+		t.Errorf("server secret does not authorize the client key, got %q", secret.Data[authorizedKeysKey])
+	}
+	if fingerprint, err := s.CertFingerprint(); err != nil || !strings.HasPrefix(fingerprint, "SHA256:") {
+		t.Errorf("CertFingerprint() = %s, %v", fingerprint, err)
+	}
+
+	containers := s.ServerContainers()
+	if len(containers) != 1 || containers[0].Name != SSHContainer {
+		t.Fatalf("unexpected server containers %v", containers)
+	}
+	if command := strings.Join(containers[0].Command, " "); command != "/usr/sbin/sshd -e -f /etc/ssh-server/sshd_config -D" {
+		t.Errorf("unexpected server command %s", command)
+	}
+	if volumes := s.ServerVolumes(); len(volumes) != 2 || *volumes[1].Secret.DefaultMode != keyFileMode {
+		t.Errorf("unexpected server volumes %v", volumes)
+	}
+
+	// the keys are kept when the server is created again
+	hostKey := s.hostKey
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("CreateServer() unexpected error %v", err)
+	}
+	if string(hostKey) != string(s.hostKey) {
+		t.Errorf("the host key should not be generated again")
+	}
+}
+
+func TestCreateServerInvalidPidFile(t *testing.T) {
+	s := createSSH(&transport.Options{PidFile: "sshd.pid"})
+	if err := s.CreateServer(context.TODO(), buildTestClient(), "fs", createEndpoint()); err == nil {
+		t.Errorf("CreateServer() should fail for a relative pid file")
+	}
+}
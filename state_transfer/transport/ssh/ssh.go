@@ -0,0 +1,195 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultSSHImage        = "quay.io/konveyor/rsync-transfer:latest"
+	defaultSSHServerConfig = "crane2-ssh-server-config"
+	defaultSSHServerSecret = "crane2-ssh-server-secret"
+	defaultSSHClientSecret = "crane2-ssh-client-secret"
+	sshServerConfigPath    = "/etc/ssh-server/sshd_config"
+	sshServerKeysPath      = "/etc/ssh-server/keys"
+	sshClientKeysPath      = "/etc/ssh-client/keys"
+	hostKeyKey             = "ssh_host_key"
+	authorizedKeysKey      = "authorized_keys"
+	identityKey            = "id_ecdsa"
+	knownHostsKey          = "known_hosts"
+	// sshUser is the user the client logs in as, the transfer pods run as root
+	sshUser = "root"
+	// keyFileMode is the mode of the mounted keys, ssh refuses private keys readable by others
+	keyFileMode = int32(0400)
+)
+
+const (
+	TransportTypeSSH = "ssh"
+	// SSHContainer is the name of the ssh container in transfer pods
+	SSHContainer = "ssh"
+)
+
+// SSHTransport tunnels the connections of a transfer through an ssh port forward. The server
+// runs sshd next to the transfer server, the client runs ssh -L next to the transfer client.
+// The host key of the server and the key of the client are ephemeral key pairs generated when
+// the server is created, the client only trusts the generated host key.
+type SSHTransport struct {
+	hostKey          []byte
+	hostPublicKey    string
+	clientKey        []byte
+	clientPublicKey  string
+	port             int32
+	serverContainers []corev1.Container
+	serverVolumes    []corev1.Volume
+	clientContainers []corev1.Container
+	clientVolumes    []corev1.Volume
+	options          *transport.Options
+	nsNamePair       meta.NamespacedNamePair
+	// log logs the resources created by the transport and the configs it renders
+	log logr.Logger
+}
+
+func NewTransport(nsNamePair meta.NamespacedNamePair, log logr.Logger, options *transport.Options) transport.Transport {
+	if options == nil {
+		options = &transport.Options{}
+	}
+	return &SSHTransport{
+		nsNamePair: nsNamePair,
+		options:    options,
+		log:        meta.LoggerOrDiscard(log).WithValues("transport", TransportTypeSSH),
+	}
+}
+
+// CA returns nil, ssh does not use certificates
+func (s *SSHTransport) CA() *bytes.Buffer {
+	return nil
+}
+
+// Crt returns nil, ssh does not use certificates
+func (s *SSHTransport) Crt() *bytes.Buffer {
+	return nil
+}
+
+// Key returns nil, ssh does not use certificates
+func (s *SSHTransport) Key() *bytes.Buffer {
+	return nil
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of the host key of the server, formatted like
+// ssh-keygen -l does
+func (s *SSHTransport) CertFingerprint() (string, error) {
+	if s.hostPublicKey == "" {
+		return "", fmt.Errorf("%w: the host key of the ssh transport was not generated", transport.ErrCertMissing)
+	}
+	return Fingerprint(s.hostPublicKey)
+}
+
+func (s *SSHTransport) Port() int32 {
+	return s.port
+}
+
+func (s *SSHTransport) ExposedPort() int32 {
+	return int32(2222)
+}
+
+func (s *SSHTransport) ClientContainers() []corev1.Container {
+	return s.clientContainers
+}
+
+func (s *SSHTransport) ServerContainers() []corev1.Container {
+	return s.serverContainers
+}
+
+func (s *SSHTransport) ClientVolumes() []corev1.Volume {
+	return s.clientVolumes
+}
+
+func (s *SSHTransport) ServerVolumes() []corev1.Volume {
+	return s.serverVolumes
+}
+
+func (s *SSHTransport) Direct() bool {
+	return false
+}
+
+func (s *SSHTransport) NamespacedNamePair() meta.NamespacedNamePair {
+	return s.nsNamePair
+}
+
+func (s *SSHTransport) Type() transport.TransportType {
+	return transport.TransportType(TransportTypeSSH)
+}
+
+// TunnelProtocol returns the ssh protocol, see transport.Tunneler
+func (s *SSHTransport) TunnelProtocol() endpoint.Protocol {
+	return endpoint.ProtocolSSH
+}
+
+func (s *SSHTransport) Options() *transport.Options {
+	return s.options
+}
+
+// Copy returns a copy of the transport and of its options, see transport.Copier
+func (s *SSHTransport) Copy() transport.Transport {
+	copied := *s
+	if s.options != nil {
+		options := *s.options
+		copied.options = &options
+	}
+	return &copied
+}
+
+func (s *SSHTransport) getSSHServerImage() string {
+	if s.options != nil && s.options.SSHServerImage != "" {
+		return s.options.SSHServerImage
+	}
+	return defaultSSHImage
+}
+
+func (s *SSHTransport) getSSHClientImage() string {
+	if s.options != nil && s.options.SSHClientImage != "" {
+		return s.options.SSHClientImage
+	}
+	return defaultSSHImage
+}
+
+// generateKeys generates the host key pair and the client key pair once, so that the server and
+// the client always agree on them
+func (s *SSHTransport) generateKeys() error {
+	if s.hostKey != nil && s.clientKey != nil {
+		return nil
+	}
+	hostKey, hostPublicKey, err := GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+	clientKey, clientPublicKey, err := GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+	s.hostKey, s.hostPublicKey = hostKey, hostPublicKey
+	s.clientKey, s.clientPublicKey = clientKey, clientPublicKey
+	return nil
+}
+
+// secretPrefix returns the prefix of the Secrets of the transport, SecretPrefix when it is set
+func secretPrefix(options *transport.Options, prefix string) string {
+	if options != nil && options.SecretPrefix != "" {
+		return options.SecretPrefix
+	}
+	return prefix
+}
+
+func withPrefix(prefix string, name string) string {
+	if prefix == "" {
+		prefix = "fs"
+	}
+	return fmt.Sprintf("%s-%s", prefix, name)
+}
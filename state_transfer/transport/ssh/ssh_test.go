@@ -0,0 +1,101 @@
+package ssh
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/service"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testNamespace     = "test-namespace"
+	testDestNamespace = "test-dest-namespace"
+	testName          = "test-ssh"
+	testHostname      = "test.host"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	private, public, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() unexpected error %v", err)
+	}
+	block, _ := pem.Decode(private)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Fatalf("private key is not a PEM encoded EC key: %s", private)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("unable to parse private key: %v", err)
+	}
+
+	fields := strings.Fields(public)
+	if len(fields) != 2 || fields[0] != keyType {
+		t.Fatalf("public key %q is not in the authorized_keys format", public)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatalf("unable to decode public key: %v", err)
+	}
+	parts := [][]byte{}
+	for len(blob) > 0 {
+		n := binary.BigEndian.Uint32(blob)
+		parts = append(parts, blob[4:4+n])
+		blob = blob[4+n:]
+	}
+	if len(parts) != 3 || string(parts[0]) != keyType || string(parts[1]) != curveName {
+		t.Fatalf("unexpected public key blob %q", parts)
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), parts[2])
+	if x == nil || key.PublicKey.X.Cmp(x) != 0 || key.PublicKey.Y.Cmp(y) != 0 {
+		t.Errorf("public key does not match the private key")
+	}
+
+	fingerprint, err := Fingerprint(public)
+	if err != nil {
+		t.Fatalf("Fingerprint() unexpected error %v", err)
+	}
+	if !strings.HasPrefix(fingerprint, "SHA256:") || len(fingerprint) != len("SHA256:")+43 {
+		t.Errorf("unexpected fingerprint %s", fingerprint)
+	}
+	if _, err := Fingerprint("invalid"); err == nil {
+		t.Errorf("Fingerprint() should fail for an invalid key")
+	}
+}
+
+func TestKnownHostsLine(t *testing.T) {
+	if got := knownHostsLine(testHostname, 22, "ecdsa-sha2-nistp256 AAAA"); got != "test.host ecdsa-sha2-nistp256 AAAA\n" {
+		t.Errorf("unexpected known hosts line for port 22: %q", got)
+	}
+	if got := knownHostsLine(testHostname, 6443, "ecdsa-sha2-nistp256 AAAA"); got != "[test.host]:6443 ecdsa-sha2-nistp256 AAAA\n" {
+		t.Errorf("unexpected known hosts line for port 6443: %q", got)
+	}
+}
+
+func buildTestClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+}
+
+func createEndpoint() endpoint.Endpoint {
+	return service.NewEndpoint(types.NamespacedName{Namespace: testDestNamespace, Name: testName},
+		statetransfermeta.Labels, testHostname, corev1.ServiceTypeLoadBalancer, nil)
+}
+
+func createSSH(options *transport.Options) *SSHTransport {
+	return NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Namespace: testNamespace, Name: testName},
+		types.NamespacedName{Namespace: testDestNamespace, Name: testName},
+	), nil, options).(*SSHTransport)
+}
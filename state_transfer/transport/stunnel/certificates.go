@@ -0,0 +1,112 @@
+package stunnel
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// issueCertificates sets the certificate of the server from the CertificateProvider of the
+// transport and, with MutualTLS, generates the client certificate and the CA verifying it
+func (s *StunnelTransport) issueCertificates(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
+	if s.options == nil || s.options.CertificateProvider == nil {
+		return s.generateCertificates()
+	}
+	if s.mutualTLS() {
+		certs, err := transport.GenerateMutualTLSCerts()
+		if err != nil {
+			return err
+		}
+		s.clientCrt, s.clientKey, s.clientCA = certs.Client.Crt, certs.Client.Key, certs.CA
+	}
+	// the hostname of a Route which is not admitted yet is derived from its subdomain
+	dnsNames := endpoint.DNSNames(e)
+	cert, err := s.options.CertificateProvider.Certificate(ctx, c, types.NamespacedName{
+		Namespace: s.nsNamePair.Destination().Namespace,
+		Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelServerCert),
+	}, dnsNames)
+	if err != nil {
+		return err
+	}
+	s.crt, s.key = cert.Crt, cert.Key
+	if cert.CA != nil {
+		s.setCA(cert.CA)
+	}
+	return nil
+}
+
+// generateCertificates generates a self signed certificate used by both the server and the
+// clients or, with MutualTLS, a CA with the server and the client certificates it signs
+func (s *StunnelTransport) generateCertificates() error {
+	if s.mutualTLS() {
+		certs, err := transport.GenerateMutualTLSCerts()
+		if err != nil {
+			return err
+		}
+		s.crt, s.key = certs.Server.Crt, certs.Server.Key
+		s.clientCrt, s.clientKey, s.clientCA = certs.Client.Crt, certs.Client.Key, certs.CA
+		s.setCA(certs.CA)
+		return nil
+	}
+	_, crt, key, err := transport.GenerateSSLCert()
+	if err != nil {
+		return err
+	}
+	s.crt, s.key = crt, key
+	return nil
+}
+
+// setCA sets the CA the clients verify the server with, unless a CABundle is set in the options
+func (s *StunnelTransport) setCA(ca *bytes.Buffer) {
+	if s.options != nil && len(s.options.CABundle) > 0 {
+		return
+	}
+	s.ca = ca
+}
+
+func (s *StunnelTransport) mutualTLS() bool {
+	return s.options != nil && s.options.MutualTLS
+}
+
+// serverSecretData returns the contents of the server Secret
+func (s *StunnelTransport) serverSecretData() map[string][]byte {
+	data := map[string][]byte{
+		"tls.crt": s.Crt().Bytes(),
+		"tls.key": s.Key().Bytes(),
+	}
+	if s.clientCA != nil {
+		data[clientCAKey] = s.clientCA.Bytes()
+	}
+	return data
+}
+
+// clientSecretData returns the contents of the client Secret: the client certificate and its key
+// with MutualTLS, otherwise only the certificate of the server so that its key never leaves the
+// destination namespace. The CA verifying the server is added when there is one.
+func (s *StunnelTransport) clientSecretData() map[string][]byte {
+	data := map[string][]byte{
+		corev1.TLSCertKey: s.Crt().Bytes(),
+	}
+	if s.mutualTLS() {
+		data[corev1.TLSCertKey] = s.clientCrt.Bytes()
+		data[corev1.TLSPrivateKeyKey] = s.clientKey.Bytes()
+	}
+	if s.CA() != nil {
+		data[caBundleKey] = s.CA().Bytes()
+	}
+	return data
+}
+
+// clientSecretType returns the type of the client Secret, Secrets of the kubernetes.io/tls type
+// must hold a private key which the client only has with MutualTLS
+func (s *StunnelTransport) clientSecretType() corev1.SecretType {
+	if s.mutualTLS() {
+		return corev1.SecretTypeTLS
+	}
+	return corev1.SecretTypeOpaque
+}
@@ -9,8 +9,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -18,17 +19,37 @@ import (
 
 const (
 	stunnelClientConfTemplate = `
- pid =
+ pid = {{ .pidFile }}
+{{- if not (eq .minTLSVersion "") }}
+ sslVersionMin = {{ .minTLSVersion }}
+{{- else }}
  sslVersion = TLSv1.2
+{{- end }}
+{{- if not (eq .maxTLSVersion "") }}
+ sslVersionMax = {{ .maxTLSVersion }}
+{{- end }}
+{{- if not (eq .ciphers "") }}
+ ciphers = {{ .ciphers }}
+{{- end }}
+{{- if not (eq .cipherSuites "") }}
+ ciphersuites = {{ .cipherSuites }}
+{{- end }}
+{{- if not (eq .curves "") }}
+ curves = {{ .curves }}
+{{- end }}
  client = yes
  syslog = no
  output = /dev/stdout
  [rsync]
  debug = 7
  accept = {{ .stunnelPort }}
+{{- if eq .mutualTLS "true" }}
  cert = /etc/stunnel/certs/tls.crt
  key = /etc/stunnel/certs/tls.key
-{{- if not (eq .proxyHost "") }}
+{{- end }}
+{{- if eq .socksProxy "true" }}
+ connect = 127.0.0.1:{{ .socksForwarderPort }}
+{{- else if not (eq .proxyHost "") }}
  protocol = connect
  connect = {{ .proxyHost }}
  protocolHost = {{ .hostname }}:{{ .port }}
@@ -43,23 +64,71 @@ const (
 {{- end }}
 {{- if not (eq .noVerifyCA "false") }}
  verify = {{ .caVerifyLevel }}
+{{- else if not (eq .caFile "") }}
+ verify = {{ .caVerifyLevel }}
+{{- end }}
+{{- if not (eq .caFile "") }}
+ CAfile = {{ .caFile }}
+{{- end }}
+{{- if not (eq .sessionCacheSize "") }}
+ sessionCacheSize = {{ .sessionCacheSize }}
+{{- end }}
+{{- if eq .delay "true" }}
+ delay = yes
+{{- end }}
+`
+	// socksForwarderTemplate is the script forwarding the connections of the stunnel client through
+	// a SOCKS5 proxy, which stunnel cannot connect through by itself. The values are single quoted,
+	// see transport.Proxy.Validate.
+	socksForwarderTemplate = `#!/bin/bash
+export PROXY='{{ .proxyHost }}' PROXY_AUTH='{{ .proxyAuth }}' TARGET_HOST='{{ .hostname }}' TARGET_PORT='{{ .port }}'
+exec nc --listen --keep-open 127.0.0.1 {{ .socksForwarderPort }} --sh-exec \
+{{- if eq .proxyAuth "" }}
+  'exec nc --proxy "$PROXY" --proxy-type socks5 "$TARGET_HOST" "$TARGET_PORT"'
+{{- else }}
+  'exec nc --proxy "$PROXY" --proxy-type socks5 --proxy-auth "$PROXY_AUTH" "$TARGET_HOST" "$TARGET_PORT"'
 {{- end }}
 `
+	// socksForwarderPort is the local port the SOCKS5 forwarder listens on for the stunnel client
+	socksForwarderPort = 1081
+	socksForwarderKey  = "socks-forwarder.sh"
+	socksForwarderPath = "/etc/stunnel/" + socksForwarderKey
+	clientStartCommand = "/bin/stunnel /etc/stunnel/stunnel.conf"
 )
 
-func (s *StunnelTransport) CreateClient(c client.Client, prefix string, e endpoint.Endpoint) error {
-	err := createClientResources(c, s, prefix, e)
+// ClientStartCommand returns the shell commands starting the stunnel client of the given transport,
+// for transfers which run the stunnel client container with a script of their own. With a SOCKS5
+// proxy, the forwarder stunnel connects through is started in the background first. The client
+// must have been created, see CreateClient.
+func ClientStartCommand(t transport.Transport) string {
+	if s, ok := t.(*StunnelTransport); ok && s.socksProxy() {
+		return "/bin/bash " + socksForwarderPath + " &\n" + clientStartCommand
+	}
+	return clientStartCommand
+}
+
+// socksProxy returns whether the client connects through a SOCKS5 proxy
+func (s *StunnelTransport) socksProxy() bool {
+	return s.proxy != nil && s.proxy.Type == transport.ProxyTypeSOCKS5
+}
+
+func (s *StunnelTransport) CreateClient(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
+	log := s.log.WithValues("namespace", s.nsNamePair.Source().Namespace, "prefix", prefix)
+	err := createClientResources(ctx, meta.NewLoggingClient(c, log), s, prefix, e)
+	if err != nil {
+		log.Error(err, "unable to create stunnel client resources")
+	}
 	return err
 }
 
-func createClientResources(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+func createClientResources(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
 	errs := []error{}
 
 	// assuming the name of the endpoint is the same as the name of the PVC
-	err := createClientConfig(c, s, prefix, e)
+	err := createClientConfig(ctx, c, s, prefix, e)
 	errs = append(errs, err)
 
-	err = createClientSecret(c, s, prefix, e)
+	err = createClientSecret(ctx, c, s, prefix, e)
 	errs = append(errs, err)
 
 	setClientContainers(s, e)
@@ -69,16 +138,16 @@ func createClientResources(c client.Client, s *StunnelTransport, prefix string,
 	return errorsutil.NewAggregate(errs)
 }
 
-func getClientConfig(c client.Client, obj types.NamespacedName, prefix string) (*corev1.ConfigMap, error) {
+func getClientConfig(ctx context.Context, c client.Client, obj types.NamespacedName, prefix string) (*corev1.ConfigMap, error) {
 	cm := &corev1.ConfigMap{}
-	err := c.Get(context.Background(), types.NamespacedName{
+	err := c.Get(ctx, types.NamespacedName{
 		Namespace: obj.Namespace,
 		Name:      withPrefix(prefix, defaultStunnelClientConfig),
 	}, cm)
 	return cm, err
 }
 
-func createClientConfig(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+func createClientConfig(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
 	var caVerifyLevel string
 
 	if s.Options().CAVerifyLevel == "" {
@@ -87,15 +156,61 @@ func createClientConfig(c client.Client, s *StunnelTransport, prefix string, e e
 		caVerifyLevel = s.Options().CAVerifyLevel
 	}
 
+	if err := transport.ValidatePidFile(s.Options().PidFile); err != nil {
+		return err
+	}
+	if err := transport.ValidateContainerOptions(s.Options()); err != nil {
+		return err
+	}
+	if err := transport.ValidateSessionCacheSize(s.Options().SessionCacheSize); err != nil {
+		return err
+	}
+	if err := transport.ValidateTLSOptions(s.Options()); err != nil {
+		return err
+	}
+	minTLSVersion, maxTLSVersion := transport.TLSVersionRange(s.Options())
+	proxy, err := transport.ClientProxy(ctx, c, s.Options(), e.Hostname())
+	if err != nil {
+		return err
+	}
+	// the containers of the client depend on the type of the proxy
+	s.proxy = proxy
 	connections := map[string]string{
-		"stunnelPort":   strconv.Itoa(int(e.Port())),
-		"hostname":      e.Hostname(),
-		"port":          strconv.Itoa(int(e.ExposedPort())),
-		"proxyHost":     s.Options().ProxyURL,
-		"proxyUsername": s.Options().ProxyUsername,
-		"proxyPassword": s.Options().ProxyPassword,
-		"caVerifyLevel": caVerifyLevel,
-		"noVerifyCA":    strconv.FormatBool(s.Options().NoVerifyCA),
+		"stunnelPort":        strconv.Itoa(int(e.Port())),
+		"hostname":           e.Hostname(),
+		"port":               strconv.Itoa(int(e.ExposedPort())),
+		"proxyHost":          "",
+		"proxyUsername":      "",
+		"proxyPassword":      "",
+		"proxyAuth":          "",
+		"socksProxy":         strconv.FormatBool(s.socksProxy()),
+		"mutualTLS":          strconv.FormatBool(s.mutualTLS()),
+		"socksForwarderPort": strconv.Itoa(socksForwarderPort),
+		"caVerifyLevel":      caVerifyLevel,
+		"noVerifyCA":         strconv.FormatBool(s.Options().NoVerifyCA),
+		"caFile":             "",
+		"pidFile":            s.Options().PidFile,
+		"delay":              strconv.FormatBool(s.Options().Delay),
+		"minTLSVersion":      minTLSVersion,
+		"maxTLSVersion":      maxTLSVersion,
+		"ciphers":            s.Options().Ciphers,
+		"cipherSuites":       s.Options().CipherSuites,
+		"curves":             s.Options().Curves,
+	}
+	if proxy != nil {
+		connections["proxyHost"] = proxy.Host
+		connections["proxyUsername"] = proxy.Username
+		connections["proxyPassword"] = proxy.Password
+		if proxy.Username != "" {
+			connections["proxyAuth"] = proxy.Username + ":" + proxy.Password
+		}
+	}
+	if len(s.Options().CABundle) > 0 || s.CA() != nil {
+		connections["caFile"] = stunnelCertsPath + "/" + caBundleKey
+	}
+	connections["sessionCacheSize"] = ""
+	if s.Options().SessionCacheSize != nil {
+		connections["sessionCacheSize"] = strconv.Itoa(*s.Options().SessionCacheSize)
 	}
 
 	var stunnelConf bytes.Buffer
@@ -108,67 +223,77 @@ func createClientConfig(c client.Client, s *StunnelTransport, prefix string, e e
 	if err != nil {
 		return err
 	}
+	data := map[string]string{
+		"stunnel.conf": stunnelConf.String(),
+	}
+	if s.socksProxy() {
+		var forwarder bytes.Buffer
+		forwarderTemplate, err := template.New("forwarder").Parse(socksForwarderTemplate)
+		if err != nil {
+			return err
+		}
+		if err := forwarderTemplate.Execute(&forwarder, connections); err != nil {
+			return err
+		}
+		data[socksForwarderKey] = forwarder.String()
+	}
+	// the config holds the proxy credentials, only the connection is logged
+	proxyName := ""
+	if proxy != nil {
+		proxyName = proxy.String()
+	}
+	s.log.V(1).Info("rendered stunnel client config", "namespace", s.nsNamePair.Source().Namespace,
+		"hostname", connections["hostname"], "port", connections["port"], "proxy", proxyName)
 
 	stunnelConfigMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.nsNamePair.Source().Namespace,
 			Name:      withPrefix(prefix, defaultStunnelClientConfig),
-			Labels:    e.Labels(),
-		},
-		Data: map[string]string{
-			"stunnel.conf": stunnelConf.String(),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
 		},
+		Data: data,
 	}
-	err = c.Create(context.TODO(), stunnelConfigMap, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	} else if k8serrors.IsAlreadyExists(err) {
-		err = c.Update(context.TODO(), stunnelConfigMap, &client.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return meta.Apply(ctx, c, stunnelConfigMap)
 }
 
-func getClientSecret(c client.Client, obj types.NamespacedName, prefix string) (*corev1.Secret, error) {
+func getClientSecret(ctx context.Context, c client.Client, obj types.NamespacedName, prefix string) (*corev1.Secret, error) {
 	secret := &corev1.Secret{}
-	err := c.Get(context.Background(), types.NamespacedName{
+	err := c.Get(ctx, types.NamespacedName{
 		Namespace: obj.Namespace,
 		Name:      withPrefix(prefix, defaultStunnelClientSecret),
 	}, secret)
 	return secret, err
 }
 
-func createClientSecret(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+func createClientSecret(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+	if s.Options() != nil && len(s.Options().CABundle) > 0 {
+		if err := transport.ValidateCABundle(s.Options().CABundle); err != nil {
+			return err
+		}
+		s.ca = bytes.NewBuffer(s.Options().CABundle)
+	}
+
 	stunnelSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.nsNamePair.Source().Namespace,
-			Name:      withPrefix(prefix, defaultStunnelClientSecret),
-			Labels:    e.Labels(),
-		},
-		Data: map[string][]byte{
-			"tls.crt": s.Crt().Bytes(),
-			"tls.key": s.Key().Bytes(),
+			Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelClientSecret),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
 		},
+		// with MutualTLS the kubernetes.io/tls type is recognized by TLS aware
+		// tooling, the optional CA bundle is stored in the additional ca.crt key
+		Type: s.clientSecretType(),
+		Data: s.clientSecretData(),
 	}
 
-	err := c.Create(context.TODO(), stunnelSecret, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	}
-	return nil
+	return meta.Apply(ctx, c, stunnelSecret)
 }
 
 func setClientContainers(s *StunnelTransport, e endpoint.Endpoint) {
 	s.clientContainers = []corev1.Container{
 		{
-			Name:  StunnelContainer,
-			Image: s.getStunnelClientImage(),
-			Command: []string{
-				"/bin/stunnel",
-				"/etc/stunnel/stunnel.conf",
-			},
+			Name:    StunnelContainer,
+			Image:   s.getStunnelClientImage(),
+			Command: clientCommand(s),
 			Ports: []corev1.ContainerPort{
 				{
 					Name:          "stunnel",
@@ -184,14 +309,49 @@ func setClientContainers(s *StunnelTransport, e endpoint.Endpoint) {
 				},
 				{
 					Name:      defaultStunnelClientSecret,
-					MountPath: "/etc/stunnel/certs",
+					MountPath: stunnelCertsPath,
 				},
 			},
 		},
 	}
+	if s.socksProxy() {
+		s.clientContainers[0].VolumeMounts = append(s.clientContainers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      defaultStunnelClientConfig,
+			MountPath: socksForwarderPath,
+			SubPath:   socksForwarderKey,
+		})
+	}
+	transport.ApplyContainerOptions(s.clientContainers, s.Options())
+}
+
+// clientCommand returns the command of the stunnel client container, the SOCKS5 forwarder and
+// stunnel share the container so that they stop together
+func clientCommand(s *StunnelTransport) []string {
+	if s.socksProxy() {
+		return []string{"/bin/bash", "-c", "/bin/bash " + socksForwarderPath + " &\nexec " + clientStartCommand}
+	}
+	return []string{"/bin/stunnel", "/etc/stunnel/stunnel.conf"}
 }
 
 func createClientVolumes(s *StunnelTransport, prefix string) {
+	secretItems := []corev1.KeyToPath{
+		{
+			Key:  "tls.crt",
+			Path: "tls.crt",
+		},
+	}
+	if s.mutualTLS() {
+		secretItems = append(secretItems, corev1.KeyToPath{
+			Key:  "tls.key",
+			Path: "tls.key",
+		})
+	}
+	if s.CA() != nil {
+		secretItems = append(secretItems, corev1.KeyToPath{
+			Key:  caBundleKey,
+			Path: caBundleKey,
+		})
+	}
 	s.clientVolumes = []corev1.Volume{
 		{
 			Name: defaultStunnelClientConfig,
@@ -207,17 +367,8 @@ func createClientVolumes(s *StunnelTransport, prefix string) {
 			Name: defaultStunnelClientSecret,
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName: withPrefix(prefix, defaultStunnelClientSecret),
-					Items: []corev1.KeyToPath{
-						{
-							Key:  "tls.crt",
-							Path: "tls.crt",
-						},
-						{
-							Key:  "tls.key",
-							Path: "tls.key",
-						},
-					},
+					SecretName: withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelClientSecret),
+					Items:      secretItems,
 				},
 			},
 		},
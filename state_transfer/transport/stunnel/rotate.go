@@ -0,0 +1,108 @@
+package stunnel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CertFingerprintAnnotation is set on rotated Secrets to the fingerprint of the certificate
+	// they hold
+	CertFingerprintAnnotation = "crane.konveyor.io/cert-fingerprint"
+	// DefaultRotationThreshold is the remaining validity below which NeedsRotation reports that
+	// the certificate must be rotated when no threshold is given
+	DefaultRotationThreshold = 30 * 24 * time.Hour
+)
+
+// CertExpiry returns the time after which the certificate of the transport is no longer valid
+func (s *StunnelTransport) CertExpiry() (time.Time, error) {
+	return transport.CertExpiry(s.crt)
+}
+
+// NeedsRotation returns whether the certificate of the transport expires within the given
+// threshold, DefaultRotationThreshold when it is not positive. Expired certificates always
+// need a rotation.
+func (s *StunnelTransport) NeedsRotation(threshold time.Duration) (bool, error) {
+	if threshold <= 0 {
+		threshold = DefaultRotationThreshold
+	}
+	expiry, err := s.CertExpiry()
+	if err != nil {
+		return false, err
+	}
+	return time.Now().Add(threshold).After(expiry), nil
+}
+
+// RotateCertificates gets a new key pair for the transport from its CertificateProvider, a new
+// self signed certificate is generated when none is set, and writes it to the server
+// Secret in the destination namespace and the client Secret in the source namespace, creating
+// them when they do not exist. Providers such as cert-manager renew their certificates on their
+// own, rotating then copies the renewed certificate. The transport must be fetched with the same
+// prefix and options it was created with.
+//
+// stunnel only reads its certificate when it starts, the returned bool reports that the
+// certificate changed and that the server and client pods running the transport containers
+// must be restarted to use it. Transports sharing the certificate with Share
+// must be rotated together: the Secrets they share are rolled, but the other transports keep
+// the previous key pair in memory until they are fetched again.
+func RotateCertificates(ctx context.Context, srcClient client.Client, destClient client.Client, t transport.Transport, prefix string, e endpoint.Endpoint) (bool, error) {
+	s, ok := t.(*StunnelTransport)
+	if !ok {
+		return false, fmt.Errorf("only stunnel transports can be rotated, got %s", t.Type())
+	}
+	// the transport has no certificate when it was not fetched, the Secrets are then rolled
+	previous, _ := transport.CertFingerprint(s.crt)
+	if err := s.issueCertificates(ctx, destClient, prefix, e); err != nil {
+		return false, err
+	}
+	fingerprint, err := s.CertFingerprint()
+	if err != nil {
+		return false, err
+	}
+
+	err = rollSecret(ctx, destClient, types.NamespacedName{
+		Namespace: s.nsNamePair.Destination().Namespace,
+		Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelServerSecret),
+	}, corev1.SecretTypeTLS, s.serverSecretData(), fingerprint, e)
+	if err != nil {
+		return false, err
+	}
+
+	err = rollSecret(ctx, srcClient, types.NamespacedName{
+		Namespace: s.nsNamePair.Source().Namespace,
+		Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelClientSecret),
+	}, s.clientSecretType(), s.clientSecretData(), fingerprint, e)
+	// the client certificate and the CA verifying it are always generated again with MutualTLS
+	restart := fingerprint != previous || s.mutualTLS()
+	if err != nil {
+		// the server Secret already holds the new certificate, restart it even though the
+		// client Secret must be rotated again
+		return restart, err
+	}
+	return restart, nil
+}
+
+func rollSecret(ctx context.Context, c client.Client, nn types.NamespacedName, secretType corev1.SecretType, data map[string][]byte, fingerprint string, e endpoint.Endpoint) error {
+	// keys which are not part of the new data, such as a key the Secret no longer holds, are dropped
+	// as they are no longer applied
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   nn.Namespace,
+			Name:        nn.Name,
+			Labels:      meta.WithOwnerLabel(e.Labels()),
+			Annotations: map[string]string{CertFingerprintAnnotation: fingerprint},
+		},
+		Type: secretType,
+		Data: data,
+	}
+	return meta.Apply(ctx, c, secret)
+}
@@ -4,49 +4,81 @@ import (
 	"bytes"
 	"context"
 	"strconv"
+	"strings"
 	"text/template"
 
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/meta"
 
 	"github.com/konveyor/crane-lib/state_transfer/transport"
 
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	stunnelServerConfTemplate = `foreground = yes
-pid =
+	stunnelServerConfTemplate = `foreground = {{ if $.foreground }}yes{{ else }}no{{ end }}
+pid = {{ $.pidFile }}
 socket = l:TCP_NODELAY=1
 socket = r:TCP_NODELAY=1
 debug = 7
-sslVersion = TLSv1.2
+{{ if $.minTLSVersion }}sslVersionMin = {{ $.minTLSVersion }}{{ else }}sslVersion = TLSv1.2{{ end }}
+{{- if $.maxTLSVersion }}
+sslVersionMax = {{ $.maxTLSVersion }}
+{{- end }}
+{{- if $.ciphers }}
+ciphers = {{ $.ciphers }}
+{{- end }}
+{{- if $.cipherSuites }}
+ciphersuites = {{ $.cipherSuites }}
+{{- end }}
+{{- if $.curves }}
+curves = {{ $.curves }}
+{{- end }}
 [rsync]
-accept = {{ $.acceptPort }}
-connect = {{ $.connectPort }}
+accept = {{ if $.bindAddress }}{{ $.bindAddress }}:{{ end }}{{ $.acceptPort }}
+{{- if $.exec }}
+exec = {{ $.exec }}
+execArgs = {{ $.execArgs }}
+{{- else }}
+connect = {{ if $.connectHost }}{{ $.connectHost }}:{{ end }}{{ $.connectPort }}
+{{- end }}
 key = /etc/stunnel/certs/tls.key
 cert = /etc/stunnel/certs/tls.crt
 TIMEOUTclose = 0
+{{- if $.clientCAFile }}
+verify = 2
+CAfile = {{ $.clientCAFile }}
+{{- end }}
+{{- if $.sessionCacheSize }}
+sessionCacheSize = {{ $.sessionCacheSize }}
+{{- end }}
+{{- if $.delay }}
+delay = yes
+{{- end }}
 `
 )
 
-func (s *StunnelTransport) CreateServer(c client.Client, prefix string, e endpoint.Endpoint) error {
-	err := createStunnelServerResources(c, s, prefix, e)
+func (s *StunnelTransport) CreateServer(ctx context.Context, c client.Client, prefix string, e endpoint.Endpoint) error {
+	log := s.log.WithValues("namespace", s.nsNamePair.Destination().Namespace, "prefix", prefix)
+	err := createStunnelServerResources(ctx, meta.NewLoggingClient(c, log), s, prefix, e)
+	if err != nil {
+		log.Error(err, "unable to create stunnel server resources")
+	}
 	return err
 }
 
-func createStunnelServerResources(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+func createStunnelServerResources(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
 	errs := []error{}
 
-	err := createStunnelServerConfig(c, s, prefix, e)
+	err := createStunnelServerConfig(ctx, c, s, prefix, e)
 	errs = append(errs, err)
 
-	err = createStunnelServerSecret(c, s, prefix, e)
+	err = createStunnelServerSecret(ctx, c, s, prefix, e)
 	errs = append(errs, err)
 
 	createStunnelServerContainers(s, e)
@@ -56,12 +88,58 @@ func createStunnelServerResources(c client.Client, s *StunnelTransport, prefix s
 	return errorsutil.NewAggregate(errs)
 }
 
-func createStunnelServerConfig(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
-	ports := map[string]string{
+func createStunnelServerConfig(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+	if err := transport.ValidatePidFile(s.Options().PidFile); err != nil {
+		return err
+	}
+	if err := transport.ValidateContainerOptions(s.Options()); err != nil {
+		return err
+	}
+	if err := transport.ValidateSessionCacheSize(s.Options().SessionCacheSize); err != nil {
+		return err
+	}
+	if err := transport.ValidateTLSOptions(s.Options()); err != nil {
+		return err
+	}
+	minTLSVersion, maxTLSVersion := transport.TLSVersionRange(s.Options())
+	ports := map[string]interface{}{
 		// port on which Stunnel service listens on, must connect with endpoint
 		"acceptPort": strconv.Itoa(int(e.Port())),
 		// port in the container on which filesystem Transfer is listening
 		"connectPort": strconv.Itoa(int(s.ExposedPort())),
+		// host on which filesystem Transfer is listening, empty when it runs in the same pod
+		"connectHost": s.Options().ServerConnectHost,
+		// program run for every connection instead of connecting to the port, with its arguments
+		// including argv[0]
+		"exec":     "",
+		"execArgs": "",
+		// address on which Stunnel service listens on, empty means all addresses
+		"bindAddress": endpoint.BindAddress(e),
+		// whether stunnel stays in the foreground, and where it writes its pid
+		"foreground": transport.ServerForeground(s.Options()),
+		"pidFile":    transport.ServerPidFile(s.Options()),
+		// TLS session cache and DNS resolution tuning, the stunnel defaults are used when unset
+		"sessionCacheSize": "",
+		"delay":            s.Options().Delay,
+		// TLS versions accepted, exactly TLSv1.2 is accepted when both are empty
+		"minTLSVersion": minTLSVersion,
+		"maxTLSVersion": maxTLSVersion,
+		// ciphers, TLSv1.3 cipher suites and curves, the OpenSSL defaults are used when empty
+		"ciphers":      s.Options().Ciphers,
+		"cipherSuites": s.Options().CipherSuites,
+		"curves":       s.Options().Curves,
+		// CA the client certificates are verified with, clients are not verified when empty
+		"clientCAFile": "",
+	}
+	if s.mutualTLS() {
+		ports["clientCAFile"] = stunnelCertsPath + "/" + clientCAKey
+	}
+	if exec := s.Options().ServerExec; len(exec) > 0 {
+		ports["exec"] = exec[0]
+		ports["execArgs"] = strings.Join(exec, " ")
+	}
+	if s.Options().SessionCacheSize != nil {
+		ports["sessionCacheSize"] = strconv.Itoa(*s.Options().SessionCacheSize)
 	}
 
 	var stunnelConf bytes.Buffer
@@ -74,69 +152,58 @@ func createStunnelServerConfig(c client.Client, s *StunnelTransport, prefix stri
 	if err != nil {
 		return err
 	}
+	s.log.V(1).Info("rendered stunnel server config", "namespace", s.nsNamePair.Destination().Namespace,
+		"acceptPort", ports["acceptPort"], "connectPort", ports["connectPort"], "mutualTLS", s.mutualTLS())
 
 	stunnelConfigMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.nsNamePair.Destination().Namespace,
 			Name:      withPrefix(prefix, defaultStunnelServerConfig),
-			Labels:    e.Labels(),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
 		},
 		Data: map[string]string{
 			"stunnel.conf": stunnelConf.String(),
 		},
 	}
 
-	err = c.Create(context.TODO(), stunnelConfigMap, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	} else if k8serrors.IsAlreadyExists(err) {
-		err = c.Update(context.TODO(), stunnelConfigMap, &client.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return meta.Apply(ctx, c, stunnelConfigMap)
 }
 
-func getServerConfig(c client.Client, obj types.NamespacedName, prefix string) (*corev1.ConfigMap, error) {
+func getServerConfig(ctx context.Context, c client.Client, obj types.NamespacedName, prefix string) (*corev1.ConfigMap, error) {
 	cm := &corev1.ConfigMap{}
-	err := c.Get(context.Background(), types.NamespacedName{
+	err := c.Get(ctx, types.NamespacedName{
 		Namespace: obj.Namespace,
 		Name:      withPrefix(prefix, defaultStunnelServerConfig),
 	}, cm)
 	return cm, err
 }
 
-func createStunnelServerSecret(c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
-	_, crt, key, err := transport.GenerateSSLCert()
-	s.key = key
-	s.crt = crt
-	if err != nil {
-		return err
+func createStunnelServerSecret(ctx context.Context, c client.Client, s *StunnelTransport, prefix string, e endpoint.Endpoint) error {
+	// the certificate is only generated once, transfers sharing the transport share it
+	if s.crt == nil || s.key == nil {
+		if err := s.issueCertificates(ctx, c, prefix, e); err != nil {
+			return err
+		}
 	}
 
 	stunnelSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: s.nsNamePair.Destination().Namespace,
-			Name:      withPrefix(prefix, defaultStunnelServerSecret),
-			Labels:    e.Labels(),
-		},
-		Data: map[string][]byte{
-			"tls.crt": s.Crt().Bytes(),
-			"tls.key": s.Key().Bytes(),
+			Name:      withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelServerSecret),
+			Labels:    meta.WithOwnerLabel(e.Labels()),
 		},
+		// the kubernetes.io/tls type is recognized by TLS aware tooling, the CA verifying the
+		// clients is stored in the additional client-ca.crt key with MutualTLS
+		Type: corev1.SecretTypeTLS,
+		Data: s.serverSecretData(),
 	}
 
-	err = c.Create(context.TODO(), stunnelSecret, &client.CreateOptions{})
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
-		return err
-	}
-	return nil
+	return meta.Apply(ctx, c, stunnelSecret)
 }
 
-func getServerSecret(c client.Client, obj types.NamespacedName, prefix string) (*corev1.Secret, error) {
+func getServerSecret(ctx context.Context, c client.Client, obj types.NamespacedName, prefix string) (*corev1.Secret, error) {
 	secret := &corev1.Secret{}
-	err := c.Get(context.Background(), types.NamespacedName{
+	err := c.Get(ctx, types.NamespacedName{
 		Namespace: obj.Namespace,
 		Name:      withPrefix(prefix, defaultStunnelServerSecret),
 	}, secret)
@@ -148,10 +215,10 @@ func createStunnelServerContainers(s *StunnelTransport, e endpoint.Endpoint) {
 		{
 			Name:  StunnelContainer,
 			Image: s.getStunnelServerImage(),
-			Command: []string{
+			Command: transport.ServerCommand(s.Options(), []string{
 				"/bin/stunnel",
 				"/etc/stunnel/stunnel.conf",
-			},
+			}),
 			Ports: []corev1.ContainerPort{
 				{
 					Name:          "stunnel",
@@ -172,9 +239,26 @@ func createStunnelServerContainers(s *StunnelTransport, e endpoint.Endpoint) {
 			},
 		},
 	}
+	transport.ApplyContainerOptions(s.serverContainers, s.Options())
 }
 
 func createStunnelServerVolumes(s *StunnelTransport, prefix string) {
+	secretItems := []corev1.KeyToPath{
+		{
+			Key:  "tls.crt",
+			Path: "tls.crt",
+		},
+		{
+			Key:  "tls.key",
+			Path: "tls.key",
+		},
+	}
+	if s.mutualTLS() {
+		secretItems = append(secretItems, corev1.KeyToPath{
+			Key:  clientCAKey,
+			Path: clientCAKey,
+		})
+	}
 	s.serverVolumes = []corev1.Volume{
 		{
 			Name: defaultStunnelServerConfig,
@@ -190,17 +274,8 @@ func createStunnelServerVolumes(s *StunnelTransport, prefix string) {
 			Name: defaultStunnelServerSecret,
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName: withPrefix(prefix, defaultStunnelServerSecret),
-					Items: []corev1.KeyToPath{
-						{
-							Key:  "tls.crt",
-							Path: "tls.crt",
-						},
-						{
-							Key:  "tls.key",
-							Path: "tls.key",
-						},
-					},
+					SecretName: withPrefix(secretPrefix(s.Options(), prefix), defaultStunnelServerSecret),
+					Items:      secretItems,
 				},
 			},
 		},
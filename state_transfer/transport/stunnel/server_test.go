@@ -2,11 +2,20 @@ package stunnel
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+	"github.com/konveyor/crane-lib/state_transfer/endpoint/route"
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
+	"github.com/konveyor/crane-lib/state_transfer/transport"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -21,10 +30,10 @@ func TestCreateServerConfig(t *testing.T) {
 		t.Fatalf("unable to create endpoint")
 	}
 	stunnelTransport := createStunnel(testTunnelName, testNamespace, testRouteName, testNamespace)
-	if err := createStunnelServerConfig(client, stunnelTransport, "fs", e); err != nil {
+	if err := createStunnelServerConfig(context.TODO(), client, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create server config: %v", err)
 	}
-	cm, err := getServerConfig(client, types.NamespacedName{
+	cm, err := getServerConfig(context.TODO(), client, types.NamespacedName{
 		Namespace: testNamespace,
 		Name:      testTunnelName,
 	}, "fs")
@@ -34,7 +43,7 @@ func TestCreateServerConfig(t *testing.T) {
 	if cm == nil {
 		t.Fatalf("server config not found")
 	}
-	if len(e.Labels()) != len(cm.Labels) {
+	if len(statetransfermeta.WithOwnerLabel(e.Labels())) != len(cm.Labels) {
 		t.Fatalf("server config labels length does not match, on new CM")
 	}
 	for k, v := range e.Labels() {
@@ -49,17 +58,18 @@ func TestCreateServerConfig(t *testing.T) {
 		t.Fatalf("server config does not contain the correct accept port %s", cm.Data[stunnelCMKey])
 	}
 	t.Run("CreateServerConfigUpdate", func(t *testing.T) {
-		// Ensure that if the config map already exists, the contents are updated.
+		// Ensure that if the config map already exists, the contents are updated and the labels
+		// set concurrently are kept.
 		cm.Labels = map[string]string{"test": "label"}
 		cm.Data[stunnelCMKey] = "test"
 		err = client.Update(context.Background(), cm)
 		if err != nil {
 			t.Fatalf("unable to update server config map with old data: %v", err)
 		}
-		if err := createStunnelServerConfig(client, stunnelTransport, "fs", e); err != nil {
+		if err := createStunnelServerConfig(context.TODO(), client, stunnelTransport, "fs", e); err != nil {
 			t.Fatalf("unable to create server config: %v", err)
 		}
-		cm, err := getServerConfig(client, types.NamespacedName{
+		cm, err := getServerConfig(context.TODO(), client, types.NamespacedName{
 			Namespace: testNamespace,
 			Name:      testTunnelName,
 		}, "fs")
@@ -69,7 +79,7 @@ func TestCreateServerConfig(t *testing.T) {
 		if cm == nil {
 			t.Fatalf("server config not found")
 		}
-		if len(e.Labels()) != len(cm.Labels) {
+		if len(statetransfermeta.WithOwnerLabel(e.Labels()))+1 != len(cm.Labels) || cm.Labels["test"] != "label" {
 			t.Fatalf("server config labels do not match")
 		}
 		for k, v := range e.Labels() {
@@ -94,10 +104,10 @@ func TestCreateServerSecret(t *testing.T) {
 		t.Fatalf("unable to create endpoint")
 	}
 	stunnelTransport := createStunnel("test-stunnel", testNamespace, testRouteName, testNamespace)
-	if err := createStunnelServerSecret(client, stunnelTransport, "fs", e); err != nil {
+	if err := createStunnelServerSecret(context.TODO(), client, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create server secret: %v", err)
 	}
-	secret, err := getServerSecret(client, types.NamespacedName{
+	secret, err := getServerSecret(context.TODO(), client, types.NamespacedName{
 		Namespace: testNamespace,
 		Name:      testTunnelName,
 	}, "fs")
@@ -107,7 +117,7 @@ func TestCreateServerSecret(t *testing.T) {
 	if secret == nil {
 		t.Fatalf("server secret not found")
 	}
-	if len(e.Labels()) != len(secret.Labels) {
+	if len(statetransfermeta.WithOwnerLabel(e.Labels())) != len(secret.Labels) {
 		t.Fatalf("server secret labels length does not match, on new secret")
 	}
 	for k, v := range e.Labels() {
@@ -115,6 +125,9 @@ func TestCreateServerSecret(t *testing.T) {
 			t.Fatalf("server secret labels do not match, on new secret")
 		}
 	}
+	if secret.Type != corev1.SecretTypeTLS {
+		t.Fatalf("server secret type is %s, expected %s", secret.Type, corev1.SecretTypeTLS)
+	}
 	if len(secret.Data) != 2 {
 		t.Fatalf("server secret does not contain the correct number of keys")
 	}
@@ -134,7 +147,7 @@ func TestCreateServer(t *testing.T) {
 	}
 	stunnelTransport := createStunnel(testTunnelName, testNamespace, testRouteName, testNamespace)
 
-	if err := stunnelTransport.CreateServer(client, "", e); err != nil {
+	if err := stunnelTransport.CreateServer(context.TODO(), client, "", e); err != nil {
 		t.Fatalf("unable to create server: %v", err)
 	}
 
@@ -147,3 +160,278 @@ func TestCreateServer(t *testing.T) {
 		t.Fatalf("Number of server volumes is not the expected 2, %d", len(volumes))
 	}
 }
+
+func TestCreateServerConfigWithBindAddress(t *testing.T) {
+	client := buildTestClient()
+	e := createEndpoint(t, testRouteName, testNamespace, client)
+	if e == nil {
+		t.Fatalf("unable to create endpoint")
+	}
+	e = endpoint.NewAddressedEndpoint(e, "advertised.example.com", 8443, "10.0.0.5")
+	if e.Hostname() != "advertised.example.com" || e.ExposedPort() != 8443 {
+		t.Fatalf("endpoint does not advertise the configured address %s:%d", e.Hostname(), e.ExposedPort())
+	}
+	stunnelTransport := createStunnel(testTunnelName, testNamespace, testRouteName, testNamespace)
+	if err := createStunnelServerConfig(context.TODO(), client, stunnelTransport, "fs", e); err != nil {
+		t.Fatalf("unable to create server config: %v", err)
+	}
+	cm, err := getServerConfig(context.TODO(), client, types.NamespacedName{
+		Namespace: testNamespace,
+		Name:      testTunnelName,
+	}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get server config: %v", err)
+	}
+	if !strings.Contains(cm.Data[stunnelCMKey], fmt.Sprintf("accept = 10.0.0.5:%d", e.Port())) {
+		t.Fatalf("server config does not bind to the configured address %s", cm.Data[stunnelCMKey])
+	}
+}
+
+func TestCreateServerConfigForeground(t *testing.T) {
+	background := false
+	tests := []struct {
+		name        string
+		options     transport.Options
+		want        []string
+		wantCommand string
+		wantErr     bool
+	}{
+		{
+			name:        "defaults to foreground without pid file",
+			want:        []string{"foreground = yes\n", "pid = \n"},
+			wantCommand: "/bin/stunnel /etc/stunnel/stunnel.conf",
+		},
+		{
+			name:        "background with pid file",
+			options:     transport.Options{Foreground: &background, PidFile: "/var/run/stunnel.pid"},
+			want:        []string{"foreground = no\n", "pid = /var/run/stunnel.pid\n"},
+			wantCommand: "/bin/bash -c /bin/stunnel /etc/stunnel/stunnel.conf || exit $?\nfor i in $(seq 30); do [ -s /var/run/stunnel.pid ]",
+		},
+		{
+			name:        "background without pid file",
+			options:     transport.Options{Foreground: &background},
+			want:        []string{"foreground = no\n", "pid = /tmp/transport-server.pid\n"},
+			wantCommand: "/bin/bash -c /bin/stunnel /etc/stunnel/stunnel.conf || exit $?\nfor i in $(seq 30); do [ -s /tmp/transport-server.pid ]",
+		},
+		{
+			name:    "relative pid file",
+			options: transport.Options{PidFile: "stunnel.pid"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := buildTestClient()
+			e := createEndpoint(t, testRouteName, testNamespace, client)
+			stunnelTransport := createStunnel(testTunnelName, testNamespace, testRouteName, testNamespace)
+			stunnelTransport.options = &tt.options
+			err := createStunnelServerConfig(context.TODO(), client, stunnelTransport, "fs", e)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("createStunnelServerConfig() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to create server config: %v", err)
+			}
+			cm, err := getServerConfig(context.TODO(), client, types.NamespacedName{Namespace: testNamespace, Name: testTunnelName}, "fs")
+			if err != nil {
+				t.Fatalf("unable to get server config: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(cm.Data[stunnelCMKey], want) {
+					t.Errorf("server config does not contain %q\n%s", want, cm.Data[stunnelCMKey])
+				}
+			}
+			createStunnelServerContainers(stunnelTransport, e)
+			if command := strings.Join(stunnelTransport.ServerContainers()[0].Command, " "); !strings.HasPrefix(command, tt.wantCommand) {
+				t.Errorf("server command %q does not start with %q", command, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestCreateServerConfigExec(t *testing.T) {
+	client := buildTestClient()
+	e := createEndpoint(t, testRouteName, testNamespace, client)
+	stunnelTransport := createStunnel(testTunnelName, testNamespace, testRouteName, testNamespace)
+	stunnelTransport.options = &transport.Options{ServerExec: []string{"/usr/bin/rsync", "--daemon", "--config=/etc/rsyncd.conf"}}
+	if err := createStunnelServerConfig(context.TODO(), client, stunnelTransport, "fs", e); err != nil {
+		t.Fatalf("unable to create server config: %v", err)
+	}
+	cm, err := getServerConfig(context.TODO(), client, types.NamespacedName{Namespace: testNamespace, Name: testTunnelName}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get server config: %v", err)
+	}
+	for _, want := range []string{"exec = /usr/bin/rsync\n", "execArgs = /usr/bin/rsync --daemon --config=/etc/rsyncd.conf\n"} {
+		if !strings.Contains(cm.Data[stunnelCMKey], want) {
+			t.Errorf("server config does not contain %q\n%s", want, cm.Data[stunnelCMKey])
+		}
+	}
+	if strings.Contains(cm.Data[stunnelCMKey], "connect =") {
+		t.Errorf("server config forwards connections instead of executing the server\n%s", cm.Data[stunnelCMKey])
+	}
+}
+
+type testCertificateProvider struct {
+	cert     *transport.Certificate
+	names    []types.NamespacedName
+	dnsNames []string
+	ready    bool
+}
+
+func (p *testCertificateProvider) Certificate(ctx context.Context, c client.Client, name types.NamespacedName, dnsNames []string) (*transport.Certificate, error) {
+	p.names = append(p.names, name)
+	p.dnsNames = dnsNames
+	if !p.ready {
+		return nil, fmt.Errorf("certificate %s: %w", name, transport.ErrCertificateNotReady)
+	}
+	return p.cert, nil
+}
+
+func TestCreateServerWithCertificateProvider(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint(t, testRouteName, testNamespace, c)
+	crt, _, key, err := transport.GenerateSSLCert()
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+	provider := &testCertificateProvider{cert: &transport.Certificate{Crt: crt, Key: key, CA: crt}}
+	s := NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Name: testTunnelName, Namespace: testNamespace},
+		types.NamespacedName{Name: testRouteName, Namespace: testNamespace},
+	), nil, &transport.Options{CertificateProvider: provider}).(*StunnelTransport)
+
+	if err := s.CreateServer(context.TODO(), c, "fs", e); !errors.Is(err, transport.ErrCertificateNotReady) {
+		t.Fatalf("CreateServer() should wait for the certificate, got %v", err)
+	}
+	provider.ready = true
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if want := (types.NamespacedName{Namespace: testNamespace, Name: "fs-" + defaultStunnelServerCert}); provider.names[1] != want {
+		t.Errorf("certificate requested as %s, want %s", provider.names[1], want)
+	}
+	secret, err := getServerSecret(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get server secret: %v", err)
+	}
+	if string(secret.Data[crtKey]) != crt.String() || string(secret.Data[keyKey]) != key.String() {
+		t.Errorf("server secret does not hold the certificate of the provider")
+	}
+
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	clientSecret, err := getClientSecret(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	if string(clientSecret.Data[caBundleKey]) != crt.String() {
+		t.Errorf("client secret does not hold the CA of the provider")
+	}
+	if _, ok := clientSecret.Data[keyKey]; ok {
+		t.Errorf("client secret should not hold the key issued by the provider")
+	}
+	cm, err := getClientConfig(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get client config: %v", err)
+	}
+	if !strings.Contains(cm.Data["stunnel.conf"], "CAfile = "+stunnelCertsPath+"/"+caBundleKey) {
+		t.Errorf("client config should verify the server with the CA of the provider:\n%s", cm.Data["stunnel.conf"])
+	}
+}
+
+func TestCertificateProviderDNSNames(t *testing.T) {
+	c := buildTestClient()
+	// the Route is not admitted yet, its hostname is derived from its subdomain
+	e := route.NewEndpoint(types.NamespacedName{Namespace: testNamespace, Name: testRouteName},
+		route.EndpointTypePassthrough, statetransfermeta.Labels, "test.domain", nil)
+	provider := &testCertificateProvider{}
+	s := NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Name: testTunnelName, Namespace: testNamespace},
+		types.NamespacedName{Name: testRouteName, Namespace: testNamespace},
+	), nil, &transport.Options{CertificateProvider: provider}).(*StunnelTransport)
+
+	if err := s.CreateServer(context.TODO(), c, "fs", e); !errors.Is(err, transport.ErrCertificateNotReady) {
+		t.Fatalf("CreateServer() should wait for the certificate, got %v", err)
+	}
+	if want := testRouteName + "-" + testNamespace + ".test.domain"; len(provider.dnsNames) != 1 || provider.dnsNames[0] != want {
+		t.Errorf("certificate requested for %v, want %s", provider.dnsNames, want)
+	}
+}
+
+func TestCreateMutualTLS(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint(t, testRouteName, testNamespace, c)
+	s := NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Name: testTunnelName, Namespace: testNamespace},
+		types.NamespacedName{Name: testRouteName, Namespace: testNamespace},
+	), nil, &transport.Options{MutualTLS: true}).(*StunnelTransport)
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	cm, err := getServerConfig(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get server config: %v", err)
+	}
+	for _, want := range []string{"verify = 2\n", "CAfile = /etc/stunnel/certs/client-ca.crt\n"} {
+		if !strings.Contains(cm.Data[stunnelCMKey], want) {
+			t.Errorf("server config does not contain %q\n%s", want, cm.Data[stunnelCMKey])
+		}
+	}
+	if items := s.ServerVolumes()[1].Secret.Items; len(items) != 3 || items[2].Key != clientCAKey {
+		t.Errorf("server volume should mount the client CA, got %v", items)
+	}
+
+	serverSecret, err := getServerSecret(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get server secret: %v", err)
+	}
+	clientSecret, err := getClientSecret(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, "fs")
+	if err != nil {
+		t.Fatalf("unable to get client secret: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(serverSecret.Data[clientCAKey]) {
+		t.Fatalf("server secret does not hold the client CA")
+	}
+	if string(clientSecret.Data[caBundleKey]) != string(serverSecret.Data[clientCAKey]) {
+		t.Errorf("client should verify the server with the generated CA")
+	}
+	for _, leaf := range []struct {
+		crt   []byte
+		usage x509.ExtKeyUsage
+	}{
+		{serverSecret.Data[crtKey], x509.ExtKeyUsageServerAuth},
+		{clientSecret.Data[crtKey], x509.ExtKeyUsageClientAuth},
+	} {
+		block, _ := pem.Decode(leaf.crt)
+		if block == nil {
+			t.Fatalf("invalid certificate %s", leaf.crt)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("unable to parse certificate: %v", err)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{leaf.usage}}); err != nil {
+			t.Errorf("certificate %s is not signed by the CA: %v", cert.Subject.CommonName, err)
+		}
+	}
+	if string(serverSecret.Data[crtKey]) == string(clientSecret.Data[crtKey]) {
+		t.Errorf("the server and the clients should have their own certificates")
+	}
+
+	fetched, err := GetTransportFromKubeObjects(context.TODO(), c, c, "fs", s.NamespacedNamePair(), e, nil, &transport.Options{MutualTLS: true})
+	if err != nil {
+		t.Fatalf("unable to get transport: %v", err)
+	}
+	if fetched.Crt().String() != s.Crt().String() || fetched.(*StunnelTransport).clientCrt.String() != s.clientCrt.String() {
+		t.Errorf("fetched transport should use the server and the client certificates of the secrets")
+	}
+}
@@ -2,8 +2,10 @@ package stunnel
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
+	"github.com/go-logr/logr"
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
 	"github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
@@ -19,17 +21,25 @@ const (
 	defaultStunnelServerSecret = "crane2-stunnel-server-secret"
 	defaultStunnelClientConfig = "crane2-stunnel-client-config"
 	defaultStunnelClientSecret = "crane2-stunnel-client-secret"
+	defaultStunnelServerCert   = "crane2-stunnel-server-cert"
+	stunnelCertsPath           = "/etc/stunnel/certs"
+	caBundleKey                = "ca.crt"
+	clientCAKey                = "client-ca.crt"
 )
 
 const (
 	TransportTypeStunnel = "stunnel"
-	StunnelContainer     = "stunnel"
+	// StunnelContainer is the name of the stunnel container in transfer pods
+	StunnelContainer = "stunnel"
 )
 
 type StunnelTransport struct {
 	crt              *bytes.Buffer
 	key              *bytes.Buffer
 	ca               *bytes.Buffer
+	clientCrt        *bytes.Buffer
+	clientKey        *bytes.Buffer
+	clientCA         *bytes.Buffer
 	port             int32
 	serverContainers []corev1.Container
 	serverVolumes    []corev1.Volume
@@ -38,12 +48,17 @@ type StunnelTransport struct {
 	direct           bool
 	options          *transport.Options
 	nsNamePair       meta.NamespacedNamePair
+	// log logs the resources created by the transport and the configs it renders
+	log logr.Logger
+	// proxy is the proxy the client connects through, set when the client is created
+	proxy *transport.Proxy
 }
 
-func NewTransport(nsNamePair meta.NamespacedNamePair, options *transport.Options) transport.Transport {
+func NewTransport(nsNamePair meta.NamespacedNamePair, log logr.Logger, options *transport.Options) transport.Transport {
 	return &StunnelTransport{
 		nsNamePair: nsNamePair,
 		options:    options,
+		log:        meta.LoggerOrDiscard(log).WithValues("transport", TransportTypeStunnel),
 	}
 }
 
@@ -59,6 +74,10 @@ func (s *StunnelTransport) Key() *bytes.Buffer {
 	return s.key
 }
 
+func (s *StunnelTransport) CertFingerprint() (string, error) {
+	return transport.CertFingerprint(s.crt)
+}
+
 func (s *StunnelTransport) Port() int32 {
 	return s.port
 }
@@ -95,6 +114,12 @@ func (s *StunnelTransport) Type() transport.TransportType {
 	return transport.TransportType(TransportTypeStunnel)
 }
 
+// TunnelProtocol returns TLS, the stunnel client connects to the endpoint with the hostname of the
+// endpoint as server name, see transport.Tunneler
+func (s *StunnelTransport) TunnelProtocol() endpoint.Protocol {
+	return endpoint.ProtocolTLS
+}
+
 func (s *StunnelTransport) getStunnelServerImage() string {
 	if s.options != nil && s.options.StunnelServerImage != "" {
 		return s.options.StunnelServerImage
@@ -115,8 +140,8 @@ func (s *StunnelTransport) getStunnelClientImage() string {
 // . It populates the fields for the Transport needed for transfer object.
 // NOTE: this method will be removed in the future interfaces. 'options' are not persisted in the system
 // therefore, they require to be passed from outside by the consumers every time a transport is fetched
-func GetTransportFromKubeObjects(srcClient client.Client, destClient client.Client, prefix string, nnPair meta.NamespacedNamePair, e endpoint.Endpoint, options *transport.Options) (transport.Transport, error) {
-	_, err := getClientConfig(srcClient, nnPair.Source(), prefix)
+func GetTransportFromKubeObjects(ctx context.Context, srcClient client.Client, destClient client.Client, prefix string, nnPair meta.NamespacedNamePair, e endpoint.Endpoint, log logr.Logger, options *transport.Options) (transport.Transport, error) {
+	_, err := getClientConfig(ctx, srcClient, nnPair.Source(), prefix)
 	switch {
 	case errors.IsNotFound(err):
 		fmt.Printf("transport: %s Client Config is not created, prefix: %s", nnPair.Source(), prefix)
@@ -125,7 +150,7 @@ func GetTransportFromKubeObjects(srcClient client.Client, destClient client.Clie
 		return nil, err
 	}
 
-	_, err = getServerConfig(destClient, nnPair.Destination(), prefix)
+	_, err = getServerConfig(ctx, destClient, nnPair.Destination(), prefix)
 	switch {
 	case errors.IsNotFound(err):
 		fmt.Printf("transport: %s Server Config is not created, prefix: %s", nnPair.Destination(), prefix)
@@ -134,7 +159,7 @@ func GetTransportFromKubeObjects(srcClient client.Client, destClient client.Clie
 		return nil, err
 	}
 
-	clientSecretCreated, err := getClientSecret(srcClient, nnPair.Source(), prefix)
+	clientSecretCreated, err := getClientSecret(ctx, srcClient, nnPair.Source(), secretPrefix(options, prefix))
 	switch {
 	case errors.IsNotFound(err):
 		fmt.Printf("transport: %s Client secret is not created, prefix: %s", nnPair.Source(), prefix)
@@ -143,7 +168,7 @@ func GetTransportFromKubeObjects(srcClient client.Client, destClient client.Clie
 		return nil, err
 	}
 
-	_, err = getServerSecret(destClient, nnPair.Destination(), prefix)
+	serverSecretCreated, err := getServerSecret(ctx, destClient, nnPair.Destination(), secretPrefix(options, prefix))
 	switch {
 	case errors.IsNotFound(err):
 		fmt.Printf("transport: %s Server secret is not created, prefix: %s", nnPair.Destination(), prefix)
@@ -155,22 +180,37 @@ func GetTransportFromKubeObjects(srcClient client.Client, destClient client.Clie
 	s := &StunnelTransport{
 		port:    e.Port(),
 		options: options,
+		log:     meta.LoggerOrDiscard(log).WithValues("transport", TransportTypeStunnel),
 	}
 
-	key, ok := clientSecretCreated.Data["tls.key"]
+	// the server certificate is read from the server secret, the client secret only holds the
+	// client certificate with MutualTLS
+	key, ok := serverSecretCreated.Data["tls.key"]
 	if !ok {
-		fmt.Printf("invalid secret for transport %s, tls.key key not found", nnPair.Source())
-		return nil, fmt.Errorf("invalid secret for transport %s, tls.key key not found", nnPair.Source())
+		return nil, fmt.Errorf("%w: invalid secret for transport %s, tls.key key not found", transport.ErrCertMissing, nnPair.Destination())
 	}
-
-	crt, ok := clientSecretCreated.Data["tls.crt"]
+	crt, ok := serverSecretCreated.Data["tls.crt"]
 	if !ok {
-		fmt.Printf("invalid secret for transport %s, tls.crt key not found", nnPair.Source())
-		return nil, fmt.Errorf("invalid secret for transport %s, tls.crt key not found", nnPair.Source())
+		return nil, fmt.Errorf("%w: invalid secret for transport %s, tls.crt key not found", transport.ErrCertMissing, nnPair.Destination())
 	}
-
 	s.key = bytes.NewBuffer(key)
 	s.crt = bytes.NewBuffer(crt)
+	if ca, ok := clientSecretCreated.Data[caBundleKey]; ok {
+		s.ca = bytes.NewBuffer(ca)
+	}
+	if s.mutualTLS() {
+		clientKey, ok := clientSecretCreated.Data["tls.key"]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid secret for transport %s, tls.key key not found", transport.ErrCertMissing, nnPair.Source())
+		}
+		s.clientCrt = bytes.NewBuffer(clientSecretCreated.Data["tls.crt"])
+		s.clientKey = bytes.NewBuffer(clientKey)
+		clientCA, ok := serverSecretCreated.Data[clientCAKey]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid secret for transport %s, %s key not found", transport.ErrCertMissing, nnPair.Destination(), clientCAKey)
+		}
+		s.clientCA = bytes.NewBuffer(clientCA)
+	}
 
 	createStunnelServerVolumes(s, prefix)
 	createClientVolumes(s, prefix)
@@ -184,6 +224,58 @@ func (s *StunnelTransport) Options() *transport.Options {
 	return s.options
 }
 
+// Copy returns a copy of the transport and of its options, see transport.Copier
+func (s *StunnelTransport) Copy() transport.Transport {
+	copied := *s
+	if s.options != nil {
+		options := *s.options
+		copied.options = &options
+	}
+	return &copied
+}
+
+// Share returns a transport for another transfer using the certificate of the given stunnel
+// transport, so that the certificate is only generated once when migrating many PVCs. The
+// certificate is generated if the transport was not created yet. Each transfer still needs its
+// own transport config as it depends on its endpoint, set SecretPrefix in the options so that
+// the transports also share a single pair of Secrets per namespace.
+//
+// Every transfer sharing the certificate trusts the others: the key of any of them lets a
+// server impersonate the server of every other transfer, and a leaked key must be considered
+// leaked for all of them. Transfers which must be isolated from each other must not share it.
+func Share(t transport.Transport, nsNamePair meta.NamespacedNamePair) (transport.Transport, error) {
+	s, ok := t.(*StunnelTransport)
+	if !ok {
+		return nil, fmt.Errorf("only stunnel transports can be shared, got %s", t.Type())
+	}
+	// certificates of providers are obtained when the servers are created, transports sharing
+	// SecretPrefix get the same certificate as it is named after the prefix
+	if (s.crt == nil || s.key == nil) && (s.options == nil || s.options.CertificateProvider == nil) {
+		if err := s.generateCertificates(); err != nil {
+			return nil, err
+		}
+	}
+	return &StunnelTransport{
+		crt:        s.crt,
+		key:        s.key,
+		ca:         s.ca,
+		clientCrt:  s.clientCrt,
+		clientKey:  s.clientKey,
+		clientCA:   s.clientCA,
+		nsNamePair: nsNamePair,
+		options:    s.options,
+		log:        s.log,
+	}, nil
+}
+
+// secretPrefix returns the prefix of the Secrets of the transport, SecretPrefix when it is set
+func secretPrefix(options *transport.Options, prefix string) string {
+	if options != nil && options.SecretPrefix != "" {
+		return options.SecretPrefix
+	}
+	return prefix
+}
+
 func withPrefix(prefix string, name string) string {
 	if prefix == "" {
 		prefix = "fs"
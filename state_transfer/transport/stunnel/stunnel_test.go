@@ -1,9 +1,21 @@
 package stunnel
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	statetransfermeta "github.com/konveyor/crane-lib/state_transfer/meta"
 	"github.com/konveyor/crane-lib/state_transfer/transport"
+	"github.com/konveyor/crane-lib/state_transfer/transport/null"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -33,44 +45,44 @@ func TestGetTransportFromKubeObjects(t *testing.T) {
 	stunnelTransport := createStunnel(sourceName, sourceNamespace, destName, destNamespace)
 
 	t.Run("GetTransportFromKubeObjectsNoClientConfig", func(t *testing.T) {
-		_, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, nil)
+		_, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, nil)
 		if err == nil {
 			t.Fatalf("No client config set, should get error")
 		}
 	})
 	// Create client and server config maps
-	if err := createClientConfig(srcClient, stunnelTransport, "fs", e); err != nil {
+	if err := createClientConfig(context.TODO(), srcClient, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create client config: %v", err)
 	}
 	t.Run("GetTransportFromKubeObjectsNoServerConfig", func(t *testing.T) {
-		_, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, nil)
+		_, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, nil)
 		if err == nil {
 			t.Fatalf("No server config set, should get error")
 		}
 	})
-	if err := createStunnelServerConfig(destClient, stunnelTransport, "fs", e); err != nil {
+	if err := createStunnelServerConfig(context.TODO(), destClient, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create server config: %v", err)
 	}
 	t.Run("GetTransportFromKubeObjectsNoClientSecret", func(t *testing.T) {
-		_, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, nil)
+		_, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, nil)
 		if err == nil {
 			t.Fatalf("No client secret set, should get error")
 		}
 	})
 	// Create client and server secrets
-	if err := createClientSecret(srcClient, stunnelTransport, "fs", e); err != nil {
+	if err := createClientSecret(context.TODO(), srcClient, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create client secret: %v", err)
 	}
 	t.Run("GetTransportFromKubeObjectsNoServerSecret", func(t *testing.T) {
-		_, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, nil)
+		_, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, nil)
 		if err == nil {
 			t.Fatalf("No server secret set, should get error")
 		}
 	})
-	if err := createStunnelServerSecret(destClient, stunnelTransport, "fs", e); err != nil {
+	if err := createStunnelServerSecret(context.TODO(), destClient, stunnelTransport, "fs", e); err != nil {
 		t.Fatalf("unable to create server secret: %v", err)
 	}
-	tr, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, nil)
+	tr, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, nil)
 	if err != nil {
 		t.Fatalf("unable to get transport: %v", err)
 	}
@@ -85,7 +97,7 @@ func TestGetTransportFromKubeObjects(t *testing.T) {
 			StunnelClientImage: clientImage,
 			StunnelServerImage: serverImage,
 		}
-		tr, err := GetTransportFromKubeObjects(srcClient, destClient, "fs", nnPair, e, options)
+		tr, err := GetTransportFromKubeObjects(context.TODO(), srcClient, destClient, "fs", nnPair, e, nil, options)
 		if err != nil {
 			t.Fatalf("unable to get transport: %v", err)
 		}
@@ -166,3 +178,151 @@ func (t *testNamespacedPair) Source() types.NamespacedName {
 func (t *testNamespacedPair) Destination() types.NamespacedName {
 	return t.dest
 }
+
+func TestCertFingerprint(t *testing.T) {
+	stunnelTransport := createStunnel(sourceName, sourceNamespace, destName, destNamespace)
+	fingerprint, err := stunnelTransport.CertFingerprint()
+	if err != nil {
+		t.Fatalf("unable to get cert fingerprint: %v", err)
+	}
+	block, _ := pem.Decode(stunnelTransport.Crt().Bytes())
+	sum := sha256.Sum256(block.Bytes)
+	expected := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if strings.ReplaceAll(fingerprint, ":", "") != expected {
+		t.Fatalf("fingerprint %s does not match expected %s", fingerprint, expected)
+	}
+	if len(strings.Split(fingerprint, ":")) != sha256.Size {
+		t.Fatalf("fingerprint %s is not formatted as colon separated pairs", fingerprint)
+	}
+
+	empty := &StunnelTransport{}
+	if _, err := empty.CertFingerprint(); !errors.Is(err, transport.ErrCertMissing) {
+		t.Fatalf("transport without a certificate should return %v, got %v", transport.ErrCertMissing, err)
+	}
+}
+
+func TestShare(t *testing.T) {
+	c := buildTestClient()
+	first := NewTransport(statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Name: "pvc-1", Namespace: testNamespace},
+		types.NamespacedName{Name: "pvc-1", Namespace: testNamespace},
+	), nil, &transport.Options{SecretPrefix: "shared"})
+	second, err := Share(first, statetransfermeta.NewNamespacedPair(
+		types.NamespacedName{Name: "pvc-2", Namespace: testNamespace},
+		types.NamespacedName{Name: "pvc-2", Namespace: testNamespace},
+	))
+	if err != nil {
+		t.Fatalf("Share() unexpected error %v", err)
+	}
+	if first.Crt() == nil || !bytes.Equal(first.Crt().Bytes(), second.Crt().Bytes()) || !bytes.Equal(first.Key().Bytes(), second.Key().Bytes()) {
+		t.Fatalf("shared transports should use the same certificate")
+	}
+	crt := first.Crt().String()
+
+	for i, tr := range []transport.Transport{first, second} {
+		prefix := fmt.Sprintf("pvc-%d", i+1)
+		e := createEndpoint(t, prefix, testNamespace, c)
+		if err := tr.CreateServer(context.TODO(), c, prefix, e); err != nil {
+			t.Fatalf("unable to create server: %v", err)
+		}
+		if err := tr.CreateClient(context.TODO(), c, prefix, e); err != nil {
+			t.Fatalf("unable to create client: %v", err)
+		}
+		if _, err := getServerConfig(context.TODO(), c, types.NamespacedName{Namespace: testNamespace}, prefix); err != nil {
+			t.Errorf("every transfer should have its own server config: %v", err)
+		}
+		for _, volume := range append(tr.ServerVolumes(), tr.ClientVolumes()...) {
+			if volume.Secret != nil && !strings.HasPrefix(volume.Secret.SecretName, "shared-") {
+				t.Errorf("volume %s should use the shared secret, got %s", volume.Name, volume.Secret.SecretName)
+			}
+		}
+	}
+	if first.Crt().String() != crt {
+		t.Errorf("creating the server should not generate a new certificate")
+	}
+	secrets := &corev1.SecretList{}
+	if err := c.List(context.TODO(), secrets); err != nil || len(secrets.Items) != 2 {
+		t.Fatalf("expected a single server and client secret, err %v, secrets %d", err, len(secrets.Items))
+	}
+	for _, secret := range secrets.Items {
+		if string(secret.Data[crtKey]) != crt {
+			t.Errorf("secret %s does not hold the shared certificate", secret.Name)
+		}
+	}
+
+	if _, err := Share(null.NewTransport(first.NamespacedNamePair(), nil), first.NamespacedNamePair()); err == nil {
+		t.Errorf("Share() should only accept stunnel transports")
+	}
+}
+
+func TestRotateCertificates(t *testing.T) {
+	c := buildTestClient()
+	e := createEndpoint(t, sourceName, sourceNamespace, c)
+	s := createStunnel(sourceName, sourceNamespace, destName, destNamespace)
+	if err := s.CreateServer(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create server: %v", err)
+	}
+	if err := s.CreateClient(context.TODO(), c, "fs", e); err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	expiry, err := s.CertExpiry()
+	if err != nil {
+		t.Fatalf("CertExpiry() unexpected error %v", err)
+	}
+	if expiry.Before(time.Now().AddDate(9, 0, 0)) {
+		t.Errorf("unexpected certificate expiry %s", expiry)
+	}
+	if rotate, err := s.NeedsRotation(0); err != nil || rotate {
+		t.Errorf("NeedsRotation(0) = %t, %v, a new certificate should not need a rotation", rotate, err)
+	}
+	if rotate, err := s.NeedsRotation(time.Until(expiry) + time.Hour); err != nil || !rotate {
+		t.Errorf("NeedsRotation() = %t, %v, a certificate expiring within the threshold should need a rotation", rotate, err)
+	}
+	if _, err := (&StunnelTransport{}).NeedsRotation(0); err == nil {
+		t.Errorf("NeedsRotation() should fail without a certificate")
+	}
+
+	previous := s.Crt().String()
+	restart, err := RotateCertificates(context.TODO(), c, c, s, "fs", e)
+	if err != nil {
+		t.Fatalf("RotateCertificates() unexpected error %v", err)
+	}
+	if !restart {
+		t.Errorf("RotateCertificates() should report that the sidecars must be restarted")
+	}
+	if s.Crt().String() == previous {
+		t.Fatalf("RotateCertificates() should generate a new certificate")
+	}
+	fingerprint, _ := s.CertFingerprint()
+	for _, nn := range []types.NamespacedName{
+		{Namespace: destNamespace, Name: "fs-" + defaultStunnelServerSecret},
+		{Namespace: sourceNamespace, Name: "fs-" + defaultStunnelClientSecret},
+	} {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), nn, secret); err != nil {
+			t.Fatalf("unable to get secret %s: %v", nn, err)
+		}
+		if string(secret.Data[crtKey]) != s.Crt().String() {
+			t.Errorf("secret %s does not hold the new certificate", nn)
+		}
+		if _, hasKey := secret.Data[keyKey]; hasKey != (nn.Namespace == destNamespace) || (hasKey && string(secret.Data[keyKey]) != s.Key().String()) {
+			t.Errorf("only the server secret %s should hold the new key", nn)
+		}
+		if secret.Annotations[CertFingerprintAnnotation] != fingerprint {
+			t.Errorf("secret %s fingerprint annotation %q, want %q", nn, secret.Annotations[CertFingerprintAnnotation], fingerprint)
+		}
+	}
+
+	fetched, err := GetTransportFromKubeObjects(context.TODO(), c, c, "fs", s.NamespacedNamePair(), e, nil, &transport.Options{})
+	if err != nil {
+		t.Fatalf("unable to get transport: %v", err)
+	}
+	if fetched.Crt().String() != s.Crt().String() {
+		t.Errorf("fetched transport should use the rotated certificate")
+	}
+
+	if _, err := RotateCertificates(context.TODO(), c, c, null.NewTransport(s.NamespacedNamePair(), nil), "fs", e); err == nil {
+		t.Errorf("RotateCertificates() should only accept stunnel transports")
+	}
+}
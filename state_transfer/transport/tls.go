@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/crane-lib/state_transfer/endpoint"
+)
+
+// TLS protocol versions, named the way stunnel names them
+const (
+	TLSVersion10 = "TLSv1"
+	TLSVersion11 = "TLSv1.1"
+	TLSVersion12 = "TLSv1.2"
+	TLSVersion13 = "TLSv1.3"
+)
+
+// ErrTLSVersionBelowMinimum is returned when a transport server accepts a connection using a TLS
+// version lower than its configured minimum
+var ErrTLSVersionBelowMinimum = errors.New("tls version below minimum accepted")
+
+var tlsVersions = map[string]uint16{
+	TLSVersion10: tls.VersionTLS10,
+	TLSVersion11: tls.VersionTLS11,
+	TLSVersion12: tls.VersionTLS12,
+	TLSVersion13: tls.VersionTLS13,
+}
+
+// ValidateTLSVersion validates that the given version is one of the supported TLS versions,
+// an empty version is valid
+func ValidateTLSVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	if _, ok := tlsVersions[version]; !ok {
+		return fmt.Errorf("unsupported tls version %q, must be one of %s, %s, %s or %s",
+			version, TLSVersion10, TLSVersion11, TLSVersion12, TLSVersion13)
+	}
+	return nil
+}
+
+// TLS 1.3 cipher suites, named the way OpenSSL names them
+var tlsCipherSuites = map[string]bool{
+	"TLS_AES_128_GCM_SHA256":       true,
+	"TLS_AES_256_GCM_SHA384":       true,
+	"TLS_CHACHA20_POLY1305_SHA256": true,
+	"TLS_AES_128_CCM_SHA256":       true,
+	"TLS_AES_128_CCM_8_SHA256":     true,
+}
+
+// elliptic curves for the key exchange, named the way OpenSSL names them
+var tlsCurves = map[string]bool{
+	"X25519":     true,
+	"X448":       true,
+	"prime256v1": true,
+	"secp384r1":  true,
+	"secp521r1":  true,
+}
+
+// cipherListPattern matches OpenSSL cipher lists, made of cipher names, aliases and operators
+// such as !aNULL or @STRENGTH, without any whitespace that would break the config
+var cipherListPattern = regexp.MustCompile(`^[A-Za-z0-9_+!@=.:-]+$`)
+
+// TLSVersionRange returns the lowest and the highest TLS versions of the given options, the lowest
+// version defaults to TLSv1.2 when only the highest is set. Both are empty when none is set.
+func TLSVersionRange(options *Options) (string, string) {
+	if options == nil {
+		return "", ""
+	}
+	min := options.MinTLSVersion
+	if min == "" && options.MaxTLSVersion != "" {
+		min = TLSVersion12
+	}
+	return min, options.MaxTLSVersion
+}
+
+// ValidateTLSOptions validates the TLS versions, ciphers, cipher suites and curves of the given
+// options, empty values are valid
+func ValidateTLSOptions(options *Options) error {
+	if options == nil {
+		return nil
+	}
+	if err := ValidateTLSVersion(options.MinTLSVersion); err != nil {
+		return err
+	}
+	if err := ValidateTLSVersion(options.MaxTLSVersion); err != nil {
+		return err
+	}
+	if min, max := TLSVersionRange(options); max != "" && tlsVersions[max] < tlsVersions[min] {
+		return fmt.Errorf("maximum tls version %s is lower than the minimum %s", max, min)
+	}
+	if options.Ciphers != "" && !cipherListPattern.MatchString(options.Ciphers) {
+		return fmt.Errorf("invalid cipher list %q", options.Ciphers)
+	}
+	if err := validateList("tls 1.3 cipher suite", options.CipherSuites, tlsCipherSuites); err != nil {
+		return err
+	}
+	return validateList("curve", options.Curves, tlsCurves)
+}
+
+// validateList validates that every element of the colon separated list is allowed
+func validateList(kind string, list string, allowed map[string]bool) error {
+	if list == "" {
+		return nil
+	}
+	for _, item := range strings.Split(list, ":") {
+		if !allowed[item] {
+			names := []string{}
+			for name := range allowed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unsupported %s %q, must be one of %s", kind, item, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// ProbeTLSVersion connects to the transport server through the given endpoint and returns the TLS
+// version negotiated with it. The server must present the certificate of the transport. The
+// connection is made directly, the proxy of the transport is not used.
+func ProbeTLSVersion(ctx context.Context, t Transport, e endpoint.Endpoint) (string, error) {
+	return probeTLSVersion(ctx, t.Crt(), endpointAddress(e), tls.VersionTLS10, tls.VersionTLS13)
+}
+
+// VerifyMinTLSVersion connects to the transport server through the given endpoint using the TLS
+// version right below the MinTLSVersion of the transport and returns an error wrapping
+// ErrTLSVersionBelowMinimum when the server accepts the connection. Nothing is verified when no
+// minimum is set, or when the minimum is the lowest supported version. An error is also returned
+// when the server cannot be reached.
+func VerifyMinTLSVersion(ctx context.Context, t Transport, e endpoint.Endpoint) error {
+	if t.Options() == nil {
+		return nil
+	}
+	return verifyMinTLSVersion(ctx, t.Crt(), endpointAddress(e), t.Options().MinTLSVersion)
+}
+
+func verifyMinTLSVersion(ctx context.Context, crt *bytes.Buffer, address, minVersion string) error {
+	if err := ValidateTLSVersion(minVersion); err != nil {
+		return err
+	}
+	if minVersion == "" || tlsVersions[minVersion] == tls.VersionTLS10 {
+		return nil
+	}
+	// the server must be reachable for a rejected handshake to be meaningful
+	if _, err := probeTLSVersion(ctx, crt, address, tls.VersionTLS10, tls.VersionTLS13); err != nil {
+		return err
+	}
+	version, err := probeTLSVersion(ctx, crt, address, tls.VersionTLS10, tlsVersions[minVersion]-1)
+	if err != nil {
+		// the handshake is expected to be rejected
+		return nil
+	}
+	return fmt.Errorf("%w: server at %s accepted %s, the minimum is %s", ErrTLSVersionBelowMinimum, address, version, minVersion)
+}
+
+// probeTLSVersion makes a TLS handshake with the server at address using a version between min
+// and max, the certificate presented by the server must have the fingerprint of crt as the
+// transport certificates are self-signed
+func probeTLSVersion(ctx context.Context, crt *bytes.Buffer, address string, min, max uint16) (string, error) {
+	fingerprint, err := CertFingerprint(crt)
+	if err != nil {
+		return "", err
+	}
+	config := &tls.Config{
+		MinVersion: min,
+		MaxVersion: max,
+		// the certificate is pinned rather than verified against a CA and a hostname
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("server at %s did not present a certificate", address)
+			}
+			presented, err := CertFingerprint(bytes.NewBuffer(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rawCerts[0]})))
+			if err != nil {
+				return err
+			}
+			if presented != fingerprint {
+				return fmt.Errorf("server at %s presented certificate %s, expected %s", address, presented, fingerprint)
+			}
+			return nil
+		},
+	}
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	state := conn.(*tls.Conn).ConnectionState()
+	for name, version := range tlsVersions {
+		if version == state.Version {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tls version %#x", state.Version)
+}
+
+func endpointAddress(e endpoint.Endpoint) string {
+	return net.JoinHostPort(e.Hostname(), strconv.Itoa(int(e.ExposedPort())))
+}
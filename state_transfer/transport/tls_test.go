@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestVerifyMinTLSVersion(t *testing.T) {
+	crt, _, key, err := GenerateSSLCert()
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+	otherCrt, _, _, err := GenerateSSLCert()
+	if err != nil {
+		t.Fatalf("unable to generate certificate: %v", err)
+	}
+	tests := []struct {
+		name          string
+		serverMin     uint16
+		crt           *bytes.Buffer
+		minTLSVersion string
+		wantVersion   string
+		wantErr       error
+		wantProbeErr  bool
+	}{
+		{
+			name:          "server enforces the minimum",
+			serverMin:     tls.VersionTLS12,
+			crt:           crt,
+			minTLSVersion: TLSVersion12,
+			wantVersion:   TLSVersion13,
+		},
+		{
+			name:          "server accepts a lower version",
+			serverMin:     tls.VersionTLS10,
+			crt:           crt,
+			minTLSVersion: TLSVersion12,
+			wantVersion:   TLSVersion13,
+			wantErr:       ErrTLSVersionBelowMinimum,
+		},
+		{
+			name:          "unexpected server certificate",
+			serverMin:     tls.VersionTLS12,
+			crt:           otherCrt,
+			minTLSVersion: TLSVersion12,
+			wantProbeErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startTLSServer(t, crt, key, tt.serverMin)
+			version, err := probeTLSVersion(context.TODO(), tt.crt, address, tls.VersionTLS10, tls.VersionTLS13)
+			if tt.wantProbeErr {
+				if err == nil {
+					t.Fatalf("probeTLSVersion() expected an error")
+				}
+				if err := verifyMinTLSVersion(context.TODO(), tt.crt, address, tt.minTLSVersion); err == nil {
+					t.Fatalf("verifyMinTLSVersion() expected an error")
+				}
+				return
+			}
+			if err != nil || version != tt.wantVersion {
+				t.Fatalf("probeTLSVersion() = %s, %v, want %s", version, err, tt.wantVersion)
+			}
+			err = verifyMinTLSVersion(context.TODO(), tt.crt, address, tt.minTLSVersion)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("verifyMinTLSVersion() unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyMinTLSVersion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := ValidateTLSVersion("SSLv3"); err == nil {
+		t.Errorf("ValidateTLSVersion() expected an error for SSLv3")
+	}
+}
+
+// startTLSServer starts a server completing TLS handshakes with the given certificate
+func startTLSServer(t *testing.T, crt, key *bytes.Buffer, minVersion uint16) string {
+	certificate, err := tls.X509KeyPair(crt.Bytes(), key.Bytes())
+	if err != nil {
+		t.Fatalf("unable to load certificate: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   minVersion,
+	})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
@@ -2,12 +2,18 @@ package transport
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/konveyor/crane-lib/state_transfer/endpoint"
@@ -26,6 +32,8 @@ type Transport interface {
 	// Crt returns certificate used by the transport for encryption
 	Crt() *bytes.Buffer
 	Key() *bytes.Buffer
+	// CertFingerprint returns the SHA-256 fingerprint of the certificate used by the transport
+	CertFingerprint() (string, error)
 	// Port returns a port on which the transport listens for connections
 	Port() int32
 	// ExposedPort returns an exposed port for transfers to use
@@ -39,8 +47,8 @@ type Transport interface {
 	// ServerVolumes returns a list of volumes transfers can add to their server Pods
 	ServerVolumes() []v1.Volume
 	Direct() bool
-	CreateServer(client.Client, string, endpoint.Endpoint) error
-	CreateClient(client.Client, string, endpoint.Endpoint) error
+	CreateServer(context.Context, client.Client, string, endpoint.Endpoint) error
+	CreateClient(context.Context, client.Client, string, endpoint.Endpoint) error
 	Options() *Options
 	// Type
 	Type() TransportType
@@ -54,12 +62,104 @@ type Options struct {
 	CAVerifyLevel      string
 	StunnelClientImage string
 	StunnelServerImage string
+	// SSHClientImage and SSHServerImage are the images of the ssh transport containers, they must
+	// provide the ssh client and the sshd server of OpenSSH respectively
+	SSHClientImage string
+	SSHServerImage string
+	// ContainerResources are the CPU and memory requests and limits of the transport containers
+	ContainerResources *v1.ResourceRequirements
+	// ContainerSecurityContext is the security context of the transport containers, use
+	// RestrictedSecurityContext for namespaces enforcing the restricted Pod Security Standard
+	ContainerSecurityContext *v1.SecurityContext
+	// ImagePullPolicy is the pull policy of the images of the transport containers, the default
+	// policy of the cluster is used when empty
+	ImagePullPolicy v1.PullPolicy
+	// CABundle is a list of PEM encoded CA certificates the client trusts when
+	// verifying the server. Multiple certificates can be concatenated so that
+	// clients trust both the old and the new CA while a CA is being rotated.
+	CABundle []byte
+	// Foreground controls whether the transport server process stays in the foreground, which
+	// lets it receive SIGTERM and flush its logs when the pod terminates. Defaults to true, only
+	// set it to false for images which expect the process to daemonize: the server container then
+	// waits for the pid of the server, see ServerCommand. Clients always run in the background as
+	// the transfer client waits for them.
+	Foreground *bool
+	// PidFile is the absolute path of the file the transport process writes its pid to, no pid
+	// file is written by default unless the server runs in the background, see ServerPidFile
+	PidFile string
+	// ServerConnectHost is the host the transport server forwards connections to when the transfer
+	// server runs in a separate pod, typically the address of a Service in front of it. Connections
+	// are forwarded to the local pod when empty.
+	ServerConnectHost string
+	// ServerExec is the command the transport server runs for every connection it accepts, with the
+	// connection as its standard input and output, instead of forwarding the connection to a port.
+	// The transfer server then only runs while clients are connected. Only supported by stunnel.
+	ServerExec []string
+	// SessionCacheSize is the number of TLS sessions the transport caches, a larger cache lets many
+	// short connections such as parallel streams and retries resume sessions rather than doing full
+	// handshakes. 0 means unlimited, the default of the transport is used when nil.
+	SessionCacheSize *int
+	// Delay defers resolving the host the transport connects to until a connection is made, rather
+	// than resolving it once at startup, so that DNS changes such as a load balancer failover are
+	// picked up by new connections
+	Delay bool
+	// MinTLSVersion is the lowest TLS version the transport accepts, for example TLSv1.3. Connections
+	// are restricted to exactly TLSv1.2 when empty. Use VerifyMinTLSVersion to check that a running
+	// server rejects lower versions and ProbeTLSVersion to report the negotiated version.
+	MinTLSVersion string
+	// MaxTLSVersion is the highest TLS version the transport accepts, for example TLSv1.2 to disable
+	// TLSv1.3. The minimum defaults to TLSv1.2 when only the maximum is set.
+	MaxTLSVersion string
+	// Ciphers is the OpenSSL cipher list used up to TLSv1.2, for example ECDHE-RSA-AES256-GCM-SHA384,
+	// the default of the transport is used when empty
+	Ciphers string
+	// CipherSuites is the colon separated list of TLSv1.3 cipher suites, for example
+	// TLS_AES_256_GCM_SHA384, the default of the transport is used when empty
+	CipherSuites string
+	// Curves is the colon separated list of elliptic curves used for the key exchange, for example
+	// X25519:prime256v1, the default of the transport is used when empty
+	Curves string
+	// SecretPrefix is the prefix of the Secrets holding the certificate of the transport. When set,
+	// it replaces the prefix given to CreateServer and CreateClient for Secrets only, so that
+	// transports sharing a certificate also share a single pair of Secrets per namespace.
+	SecretPrefix string
+	// MutualTLS requires the clients to present a certificate signed by a CA dedicated to the
+	// transport, so that no one else can connect to an exposed server. The CA and the client
+	// certificate are generated, the server certificate is still the one of CertificateProvider.
+	MutualTLS bool
+	// CertificateProvider provides the certificate of the transport server, a self signed certificate
+	// is generated when nil. See the certmanager package for certificates issued by cert-manager.
+	CertificateProvider CertificateProvider
+	// AutoDetectProxy makes the stunnel client connect through the proxy of the source cluster when
+	// ProxyURL is not set, read from the cluster-wide OpenShift Proxy or from the HTTPS_PROXY,
+	// HTTP_PROXY and NO_PROXY environment variables, see DetectProxy. The proxy is skipped for
+	// endpoints matching NO_PROXY.
+	AutoDetectProxy bool
+	// ProxyType is the protocol of the proxy of ProxyURL or of the detected proxy, see ClientProxy.
+	// It defaults to ProxyTypeHTTP, or to ProxyTypeSOCKS5 for proxy URLs with the socks5 scheme.
+	ProxyType ProxyType
 }
 
 type TransportType string
 
-func CreateServer(t Transport, c client.Client, prefix string, e endpoint.Endpoint) (Transport, error) {
-	err := t.CreateServer(c, prefix, e)
+// Copier is implemented by transports which can be copied, so that their resources can be rendered
+// without changing them, e.g. certificates are generated when the server is created
+type Copier interface {
+	// Copy returns a copy of the transport, creating the copy leaves the transport unchanged
+	Copy() Transport
+}
+
+// Copy returns a copy of the given Transport, transports which do not implement Copier are
+// returned as they are
+func Copy(t Transport) Transport {
+	if c, ok := t.(Copier); ok {
+		return c.Copy()
+	}
+	return t
+}
+
+func CreateServer(ctx context.Context, t Transport, c client.Client, prefix string, e endpoint.Endpoint) (Transport, error) {
+	err := t.CreateServer(ctx, c, prefix, e)
 	if err != nil {
 		return nil, err
 	}
@@ -67,8 +167,8 @@ func CreateServer(t Transport, c client.Client, prefix string, e endpoint.Endpoi
 	return t, nil
 }
 
-func CreateClient(t Transport, c client.Client, prefix string, e endpoint.Endpoint) (Transport, error) {
-	err := t.CreateClient(c, prefix, e)
+func CreateClient(ctx context.Context, t Transport, c client.Client, prefix string, e endpoint.Endpoint) (Transport, error) {
+	err := t.CreateClient(ctx, c, prefix, e)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +184,107 @@ func DestroyClient(t Transport) error {
 	return nil
 }
 
+// ServerContainerNames returns the names of the containers the transport adds to the server pod
+func ServerContainerNames(t Transport) []string {
+	return containerNames(t.ServerContainers())
+}
+
+// ClientContainerNames returns the names of the containers the transport adds to the client pod
+func ClientContainerNames(t Transport) []string {
+	return containerNames(t.ClientContainers())
+}
+
+func containerNames(containers []v1.Container) []string {
+	names := []string{}
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of the first PEM encoded certificate
+// in crt, formatted as colon separated upper case hex pairs like openssl does
+func CertFingerprint(crt *bytes.Buffer) (string, error) {
+	if crt == nil || crt.Len() == 0 {
+		return "", fmt.Errorf("%w: no certificate found", ErrCertMissing)
+	}
+	block, _ := pem.Decode(crt.Bytes())
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("unable to decode PEM encoded certificate")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":"), nil
+}
+
+// CertExpiry returns the time after which the first PEM encoded certificate in crt is no longer valid
+func CertExpiry(crt *bytes.Buffer) (time.Time, error) {
+	if crt == nil || crt.Len() == 0 {
+		return time.Time{}, fmt.Errorf("%w: no certificate found", ErrCertMissing)
+	}
+	block, _ := pem.Decode(crt.Bytes())
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("unable to decode PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+	return cert.NotAfter, nil
+}
+
+// ValidatePidFile validates that the given pid file path is absolute and safe to render in a config file
+func ValidatePidFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("pid file %q must be an absolute path", path)
+	}
+	if strings.ContainsAny(path, " \t\r\n") {
+		return fmt.Errorf("pid file %q must not contain whitespace", path)
+	}
+	return nil
+}
+
+// ValidateSessionCacheSize validates that the given session cache size is not negative
+func ValidateSessionCacheSize(size *int) error {
+	if size != nil && *size < 0 {
+		return fmt.Errorf("session cache size %d must not be negative", *size)
+	}
+	return nil
+}
+
+// ValidateCABundle validates that the given bundle consists of one or more PEM encoded certificates
+func ValidateCABundle(bundle []byte) error {
+	count := 0
+	rest := bundle
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("invalid CA bundle, unexpected PEM block of type %s", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("invalid CA bundle, unable to parse certificate %d: %w", count+1, err)
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("invalid CA bundle, no PEM encoded certificates found")
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return fmt.Errorf("invalid CA bundle, unexpected data after certificate %d", count)
+	}
+	return nil
+}
+
 func GenerateSSLCert() (*bytes.Buffer, *bytes.Buffer, *bytes.Buffer, error) {
 	caPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
@@ -139,3 +340,83 @@ func GenerateSSLCert() (*bytes.Buffer, *bytes.Buffer, *bytes.Buffer, error) {
 
 	return crt, crt, key, nil
 }
+
+// MutualTLSCertificates is a CA with the server and the client certificates it signed, for
+// transports verifying the certificates of both sides
+type MutualTLSCertificates struct {
+	CA     *bytes.Buffer
+	Server Certificate
+	Client Certificate
+}
+
+// GenerateMutualTLSCerts generates a CA and the server and client certificates it signs. The keys
+// are ECDSA P-256 keys, the certificates are valid for the same 10 years as GenerateSSLCert.
+func GenerateMutualTLSCerts() (*MutualTLSCertificates, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	caTemp := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "crane-transfer-ca", Organization: []string{"Migration Engineering"}},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(10, 0, 0),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+	}
+	caCrt, err := signCert(caTemp, caTemp, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caCrt.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	certs := &MutualTLSCertificates{CA: bytes.NewBuffer(pem.EncodeToMemory(caCrt))}
+	for _, leaf := range []struct {
+		cert  *Certificate
+		name  string
+		usage x509.ExtKeyUsage
+	}{
+		{&certs.Server, "crane-transfer-server", x509.ExtKeyUsageServerAuth},
+		{&certs.Client, "crane-transfer-client", x509.ExtKeyUsageClientAuth},
+	} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		crt, err := signCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: leaf.name, Organization: []string{"Migration Engineering"}},
+			NotBefore:   now,
+			NotAfter:    now.AddDate(10, 0, 0),
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{leaf.usage},
+		}, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+		keyBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		leaf.cert.Crt = bytes.NewBuffer(pem.EncodeToMemory(crt))
+		leaf.cert.Key = bytes.NewBuffer(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}))
+		leaf.cert.CA = certs.CA
+	}
+	return certs, nil
+}
+
+// signCert signs the template with the key of the parent certificate using a random serial number
+func signCert(template, parent *x509.Certificate, pub *ecdsa.PublicKey, parentKey *ecdsa.PrivateKey) (*pem.Block, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "CERTIFICATE", Bytes: der}, nil
+}